@@ -0,0 +1,93 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IngressPosture is the default posture for traffic entering pods in the namespace
+type IngressPosture struct {
+	// AllowSameNamespace allows traffic from other pods in the same namespace
+	// +optional
+	AllowSameNamespace bool `json:"allowSameNamespace,omitempty"`
+
+	// DenyOther blocks all ingress traffic not allowed by a more specific rule
+	// +optional
+	DenyOther bool `json:"denyOther,omitempty"`
+}
+
+// EgressPosture is the default posture for traffic leaving pods in the namespace
+type EgressPosture struct {
+	// AllowSameNamespace allows traffic to other pods in the same namespace
+	// +optional
+	AllowSameNamespace bool `json:"allowSameNamespace,omitempty"`
+
+	// AllowDNS allows UDP and TCP traffic to port 53 of the DNS servers
+	// +optional
+	AllowDNS bool `json:"allowDNS,omitempty"`
+
+	// DenyOther blocks all egress traffic not allowed by a more specific rule
+	// +optional
+	DenyOther bool `json:"denyOther,omitempty"`
+}
+
+// NamespaceDefaultPolicySpec defines the default ingress and egress posture
+// for every pod in the namespace
+type NamespaceDefaultPolicySpec struct {
+	// Ingress is the default ingress posture
+	// +optional
+	Ingress IngressPosture `json:"ingress,omitempty"`
+
+	// Egress is the default egress posture
+	// +optional
+	Egress EgressPosture `json:"egress,omitempty"`
+
+	// DNSServers are the addresses or CIDRs allowed by egress.allowDNS.
+	// Empty allows DNS to any address.
+	// +optional
+	DNSServers []string `json:"dnsServers,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=ndp
+// +kubebuilder:printcolumn:name="Ingress-Deny",type=boolean,JSONPath=`.spec.ingress.denyOther`
+// +kubebuilder:printcolumn:name="Egress-Deny",type=boolean,JSONPath=`.spec.egress.denyOther`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NamespaceDefaultPolicy is the Schema for the namespacedefaultpolicies API.
+// Its rules are programmed below every NetworkPolicy rule, so NetworkPolicies
+// always take precedence over the namespace defaults.
+type NamespaceDefaultPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec NamespaceDefaultPolicySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NamespaceDefaultPolicyList contains a list of NamespaceDefaultPolicy
+type NamespaceDefaultPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespaceDefaultPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NamespaceDefaultPolicy{}, &NamespaceDefaultPolicyList{})
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PeerMappingSpec gives the addresses of workloads carrying a set of labels,
+// for clusters where the agent cannot read pods
+type PeerMappingSpec struct {
+	// Labels are matched by the podSelector of NetworkPolicy peers in the
+	// mapping's namespace
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Addresses are the IPs of the workloads
+	// +kubebuilder:validation:MinItems=1
+	Addresses []string `json:"addresses"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=pm
+// +kubebuilder:printcolumn:name="Addresses",type=string,JSONPath=`.spec.addresses`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PeerMapping is the Schema for the peermappings API. With --peer-resolver=crd
+// selector peers of NetworkPolicies resolve to the addresses of the matching
+// PeerMappings instead of pods.
+type PeerMapping struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PeerMappingSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PeerMappingList contains a list of PeerMapping
+type PeerMappingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PeerMapping `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PeerMapping{}, &PeerMappingList{})
+}
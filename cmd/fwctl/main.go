@@ -0,0 +1,539 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//go:build windows
+
+// fwctl talks to the admin API of the networkpolicy agent running on this node
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/knabben/firewall-controller/internal/admin"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	"github.com/knabben/firewall-controller/internal/logging"
+)
+
+const usage = `Usage: fwctl [--server URL] <command> [arguments]
+
+Commands:
+  resync endpoint <endpoint-id>   Re-program every policy on one HCN endpoint
+  resync policy <policy-key>      Re-program one policy (e.g. netpol/default/allow-http) on every endpoint
+  policies [--namespace NS] [--endpoint ID] [--status applied|failed]
+                                  List tracked policies with the outcome of their last sync
+  inspect policy <policy-key> [--endpoint ID]
+                                  Show the rules and labels tracked for one policy on each endpoint
+  backups <endpoint-id>           List the ACL backups taken before destructive changes on an endpoint
+  restore endpoint <endpoint-id> [backup]
+                                  Restore a backup (default: newest) and hold it until the endpoint is resynced
+  history <endpoint-id>           Show how the controller-owned rules of an endpoint changed, oldest first
+  enforcement [--json]            Show which NetworkPolicy fields the agent enforces with its current configuration
+  networks [--json]               List the policies of the node's HNS networks and rules conflicting with remote subnet routes
+  dry-run [--json]                List the policy changes the agent kept from HNS (requires --dry-run on the agent)
+  migrate --from azure-npm|calico [--remove|--adopt]
+                                  List the ACLs another agent left on the node's endpoints, then remove or adopt them
+  faults                          List the failures simulated on the node (requires --fault-injection on the agent)
+  fault inject <kind> --duration D [--delay D]
+                                  Simulate pause-applies, hns-outage or reconcile-delay for a game day
+  fault clear [kind]              End a simulated failure, or all of them
+`
+
+func main() {
+	server := flag.String("server", "http://127.0.0.1:8082", "Address of the agent admin API.")
+	tokenFile := flag.String("token-file", "", "File holding the admin token (the agent's --admin-token-file).")
+	timeout := flag.Duration("timeout", 30*time.Second, "Timeout for the admin request.")
+	logOpts := logging.Options{Level: "error", Encoding: "console", TimeFormat: "iso8601"}
+	logOpts.BindFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	logger, err := logging.New(logOpts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fwctl:", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	client := admin.NewClient(*server, nil)
+	client.SetLogger(logger)
+	if *tokenFile != "" {
+		token, err := admin.ReadTokenFile(*tokenFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "fwctl:", err)
+			os.Exit(1)
+		}
+		client.SetToken(token)
+	}
+	if err := run(ctx, client, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "fwctl:", err)
+		os.Exit(1)
+	}
+}
+
+// run dispatches a fwctl command
+func run(ctx context.Context, client *admin.Client, args []string) error {
+	switch {
+	case len(args) == 3 && args[0] == "resync":
+		return resync(ctx, client, args[1], args[2])
+	case len(args) >= 1 && args[0] == "policies":
+		return listPolicies(ctx, client, args[1:])
+	case len(args) >= 3 && args[0] == "inspect" && args[1] == "policy":
+		return inspectPolicy(ctx, client, args[2], args[3:])
+	case len(args) == 2 && args[0] == "backups":
+		return listBackups(ctx, client, args[1])
+	case (len(args) == 3 || len(args) == 4) && args[0] == "restore" && args[1] == "endpoint":
+		return restoreEndpoint(ctx, client, args[2], args[3:])
+	case len(args) == 2 && args[0] == "history":
+		return ruleHistory(ctx, client, args[1])
+	case len(args) >= 1 && args[0] == "enforcement":
+		return enforcementMatrix(ctx, client, args[1:])
+	case len(args) >= 1 && args[0] == "networks":
+		return listNetworks(ctx, client, args[1:])
+	case len(args) >= 1 && args[0] == "dry-run":
+		return dryRunChanges(ctx, client, args[1:])
+	case len(args) >= 1 && args[0] == "migrate":
+		return migrate(ctx, client, args[1:])
+	case len(args) == 1 && args[0] == "faults":
+		return listFaults(ctx, client)
+	case len(args) >= 3 && args[0] == "fault" && args[1] == "inject":
+		return injectFault(ctx, client, args[2], args[3:])
+	case (len(args) == 2 || len(args) == 3) && args[0] == "fault" && args[1] == "clear":
+		return clearFault(ctx, client, args[2:])
+	default:
+		flag.Usage()
+		return fmt.Errorf("invalid arguments")
+	}
+}
+
+// resync re-programs an endpoint or a policy
+func resync(ctx context.Context, client *admin.Client, target, name string) error {
+	switch target {
+	case "endpoint":
+		if err := client.ResyncEndpoint(ctx, name); err != nil {
+			return err
+		}
+	case "policy":
+		if err := client.ResyncPolicy(ctx, name); err != nil {
+			return err
+		}
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown resync target %q", target)
+	}
+
+	fmt.Printf("%s %s resynced\n", target, name)
+	return nil
+}
+
+// listPolicies prints the tracked policies matching the filter flags
+func listPolicies(ctx context.Context, client *admin.Client, args []string) error {
+	flags := flag.NewFlagSet("policies", flag.ContinueOnError)
+	var opts admin.ListOptions
+	flags.StringVar(&opts.Namespace, "namespace", "", "Only list policies of this namespace.")
+	flags.StringVar(&opts.EndpointID, "endpoint", "", "Only list policies programmed on this HCN endpoint.")
+	flags.StringVar(&opts.Status, "status", "", "Only list policies whose last sync is applied or failed.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tSTATUS\tENDPOINTS\tRULES\tERROR")
+	for {
+		list, err := client.ListPolicies(ctx, opts)
+		if err != nil {
+			return err
+		}
+		for _, policy := range list.Items {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", policy.Key, policy.Status, policy.Endpoints, policy.Rules, policy.Error)
+		}
+		if list.Continue == "" {
+			return w.Flush()
+		}
+		opts.Continue = list.Continue
+	}
+}
+
+// inspectPolicy prints the rules tracked for a policy on each endpoint
+func inspectPolicy(ctx context.Context, client *admin.Client, policyKey string, args []string) error {
+	flags := flag.NewFlagSet("inspect policy", flag.ContinueOnError)
+	var opts admin.ListOptions
+	flags.StringVar(&opts.EndpointID, "endpoint", "", "Only show the rules programmed on this HCN endpoint.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ENDPOINT\tPRIORITY\tNAME\tACTION\tDIRECTION\tPROTOCOL\tLOCAL PORTS\tREMOTE ADDRESSES\tLABELS")
+	for {
+		policy, err := client.GetPolicy(ctx, policyKey, opts)
+		if err != nil {
+			return err
+		}
+		for _, endpoint := range policy.Endpoints {
+			for _, rule := range endpoint.Rules {
+				fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					endpoint.EndpointID, rule.Priority, rule.Name, rule.Action, rule.Direction,
+					orAny(rule.Protocol), orAny(rule.LocalPorts), orAny(rule.RemoteAddresses), formatLabels(rule.Labels))
+				// Packed rules are listed under the ACL they were merged into
+				for _, source := range rule.Packed {
+					fmt.Fprintf(w, "\t%d\t  packed: %s\t\t\t%s\t%s\t%s\t\n",
+						source.Priority, source.Name, orAny(source.Protocol), orAny(source.LocalPorts), orAny(source.RemoteAddresses))
+				}
+			}
+		}
+		if policy.Continue == "" {
+			return w.Flush()
+		}
+		opts.Continue = policy.Continue
+	}
+}
+
+// listBackups prints the stored backups of an endpoint, newest first
+func listBackups(ctx context.Context, client *admin.Client, endpointID string) error {
+	backups, err := client.ListBackups(ctx, endpointID)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTIME\tREASON")
+	for _, backup := range backups.Items {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", backup.Name, backup.Time.Local().Format(time.RFC3339), backup.Reason)
+	}
+	return w.Flush()
+}
+
+// restoreEndpoint restores a backup, the newest unless one is named
+func restoreEndpoint(ctx context.Context, client *admin.Client, endpointID string, args []string) error {
+	var name string
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if err := client.RestoreEndpoint(ctx, endpointID, name); err != nil {
+		return err
+	}
+	fmt.Printf("endpoint %s restored; run \"fwctl resync endpoint %s\" to return it to its policies\n", endpointID, endpointID)
+	return nil
+}
+
+// ruleHistory prints the rule table snapshots of an endpoint, oldest first,
+// as the rules each one added (+) and removed (-)
+func ruleHistory(ctx context.Context, client *admin.Client, endpointID string) error {
+	history, err := client.RuleHistory(ctx, endpointID)
+	if err != nil {
+		return err
+	}
+	if len(history.Items) == 0 {
+		fmt.Printf("no rule history for endpoint %s\n", endpointID)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	previous := map[string]bool{}
+	for i := len(history.Items) - 1; i >= 0; i-- {
+		table := history.Items[i]
+		current := make(map[string]bool)
+		for key, rules := range table.Policies {
+			for _, rule := range rules {
+				current[formatRule(key, rule)] = true
+			}
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%d rules\n", table.Time.Local().Format(time.RFC3339), table.Cause, len(current))
+		for _, line := range sortedKeys(previous, current) {
+			fmt.Fprintf(w, "  -\t%s\n", line)
+		}
+		for _, line := range sortedKeys(current, previous) {
+			fmt.Fprintf(w, "  +\t%s\n", line)
+		}
+		previous = current
+	}
+	return w.Flush()
+}
+
+// enforcementMatrix prints how the agent enforces every NetworkPolicy field
+func enforcementMatrix(ctx context.Context, client *admin.Client, args []string) error {
+	flags := flag.NewFlagSet("enforcement", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "Print the matrix as JSON.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	matrix, err := client.EnforcementMatrix(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(matrix)
+	}
+
+	fmt.Printf("node %s, Windows build %d\n", matrix.Node, matrix.WindowsBuild)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FIELD\tLEVEL\tDETAIL")
+	for _, field := range matrix.Fields {
+		detail := field.Description
+		if field.Error != "" {
+			detail = field.Error
+		} else if len(field.Warnings) > 0 {
+			detail = strings.Join(field.Warnings, "; ")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", field.Field, field.Level, detail)
+	}
+	return w.Flush()
+}
+
+// listNetworks prints the policies of every HNS network, then the Block rules
+// conflicting with remote subnet routes
+func listNetworks(ctx context.Context, client *admin.Client, args []string) error {
+	flags := flag.NewFlagSet("networks", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "Print the networks as JSON, policy settings included.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	list, err := client.ListNetworks(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(list)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NETWORK\tTYPE\tPOLICY\tOWNER\tSETTINGS")
+	for _, network := range list.Networks {
+		for _, policy := range network.Policies {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", network.Name, network.Type, policy.Type,
+				ownerOf(policy.PolicyKey), policy.Settings)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(list.Conflicts) == 0 {
+		return nil
+	}
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "POLICY\tRULE\tPRIORITY\tREMOTE SUBNET\tPROVIDER ADDRESS")
+	for _, conflict := range list.Conflicts {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", conflict.PolicyKey, conflict.Rule, conflict.Priority,
+			conflict.Route.DestinationPrefix, conflict.Route.ProviderAddress)
+	}
+	return w.Flush()
+}
+
+// dryRunChanges prints the policy changes the agent kept from HNS, oldest first
+func dryRunChanges(ctx context.Context, client *admin.Client, args []string) error {
+	flags := flag.NewFlagSet("dry-run", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "Print the changes as JSON.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	list, err := client.DryRunChanges(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(list)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tOPERATION\tTARGET\tSETTINGS")
+	for _, change := range list.Changes {
+		target := "endpoint/" + change.EndpointID
+		if change.NetworkID != "" {
+			target = "network/" + change.NetworkID
+		}
+		for _, setting := range change.Settings {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", change.Time.Format(time.RFC3339), change.Operation, target, setting)
+		}
+	}
+	return w.Flush()
+}
+
+// migrate prints the ACLs another agent programmed on the node's endpoints and
+// what was done with them; without --remove or --adopt nothing is changed
+func migrate(ctx context.Context, client *admin.Client, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	from := flags.String("from", "", "Agent whose ACLs are migrated: azure-npm or calico.")
+	remove := flags.Bool("remove", false, "Remove the agent's ACLs.")
+	adopt := flags.Bool("adopt", false, "Adopt the agent's ACLs that match plain IPs and CIDRs and remove the others.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	agent, err := hcnpkg.ParseForeignAgent(*from)
+	if err != nil {
+		return err
+	}
+	mode := hcnpkg.MigrationReport
+	switch {
+	case *remove && *adopt:
+		return fmt.Errorf("--remove and --adopt are mutually exclusive")
+	case *remove:
+		mode = hcnpkg.MigrationRemove
+	case *adopt:
+		mode = hcnpkg.MigrationAdopt
+	}
+
+	result, err := client.Migrate(ctx, agent, mode)
+	if len(result.ACLs) == 0 {
+		if err == nil {
+			fmt.Printf("no %s ACLs found\n", agent)
+		}
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ENDPOINT\tID\tPRIORITY\tACTION\tDIRECTION\tPROTOCOL\tLOCAL PORTS\tREMOTE ADDRESSES\tADOPTABLE\tOUTCOME")
+	for _, acl := range result.ACLs {
+		rule, outcome := acl.Rule, acl.Outcome
+		if outcome == "" {
+			outcome = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%t\t%s\n",
+			acl.EndpointID, acl.ID, rule.Priority, rule.Action, rule.Direction,
+			orAny(rule.Protocol), orAny(rule.LocalPorts), orAny(rule.RemoteAddresses), acl.Adoptable, outcome)
+	}
+	if flushErr := w.Flush(); flushErr != nil {
+		return flushErr
+	}
+	return err
+}
+
+// listFaults prints the failures simulated on the node
+func listFaults(ctx context.Context, client *admin.Client) error {
+	list, err := client.Faults(ctx)
+	if err != nil {
+		return err
+	}
+	if len(list.Items) == 0 {
+		fmt.Println("no faults injected")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tUNTIL\tDELAY")
+	for _, fault := range list.Items {
+		delay := "-"
+		if fault.Delay > 0 {
+			delay = fault.Delay.String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", fault.Kind, fault.Until.Local().Format(time.RFC3339), delay)
+	}
+	return w.Flush()
+}
+
+// injectFault simulates a failure on the node
+func injectFault(ctx context.Context, client *admin.Client, kind string, args []string) error {
+	flags := flag.NewFlagSet("fault inject", flag.ContinueOnError)
+	duration := flags.Duration("duration", 0, "How long the fault lasts, at most an hour.")
+	delay := flags.Duration("delay", 0, "How long each reconcile waits, for reconcile-delay.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	fault, err := client.InjectFault(ctx, hcnpkg.FaultKind(kind), *duration, *delay)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s injected until %s; run \"fwctl fault clear %s\" to end it earlier\n",
+		fault.Kind, fault.Until.Local().Format(time.RFC3339), fault.Kind)
+	return nil
+}
+
+// clearFault ends a simulated failure, or all of them
+func clearFault(ctx context.Context, client *admin.Client, args []string) error {
+	var kind hcnpkg.FaultKind
+	if len(args) > 0 {
+		kind = hcnpkg.FaultKind(args[0])
+	}
+	if err := client.ClearFault(ctx, kind); err != nil {
+		return err
+	}
+	if kind == "" {
+		fmt.Println("all faults cleared")
+	} else {
+		fmt.Printf("%s cleared\n", kind)
+	}
+	return nil
+}
+
+// formatRule renders a rule of a policy as one tab-separated line
+func formatRule(policyKey string, rule admin.Rule) string {
+	return fmt.Sprintf("%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s",
+		policyKey, rule.Priority, rule.Action, rule.Direction,
+		orAny(rule.Protocol), orAny(rule.LocalPorts), orAny(rule.RemotePorts), orAny(rule.RemoteAddresses))
+}
+
+// sortedKeys returns the keys of set missing from other, sorted
+func sortedKeys(set, other map[string]bool) []string {
+	var keys []string
+	for key := range set {
+		if !other[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// orAny renders an unset rule field, which matches anything
+func orAny(value string) string {
+	if value == "" {
+		return "*"
+	}
+	return value
+}
+
+// ownerOf renders the policy key owning a network policy, "-" for policies the agent did not program
+func ownerOf(policyKey string) string {
+	if policyKey == "" {
+		return "-"
+	}
+	return policyKey
+}
+
+// formatLabels renders labels as sorted key=value pairs
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "<none>"
+	}
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,354 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-winfw is a kubectl plugin that shows what the networkpolicy agent
+// programmed for a pod, read from the admin API of the agent on the pod's node.
+// It runs on workstations, so unlike the agent and fwctl it is not Windows-only
+// and reads the admin API's Kubernetes-shaped objects as unstructured data.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/yaml"
+)
+
+const usage = `Usage: kubectl winfw [flags] <command> [arguments]
+
+Commands:
+  inspect pod/<name> [-n NS] [-o table|yaml|json]
+                                  Show the policies and rules programmed on a pod's HCN endpoint
+
+The admin API is reached through the API server node proxy, which needs the
+agent to listen on the node address (--admin-bind-address=:8082) and the
+nodes/proxy permission. Pass --admin-url to use a port-forward instead.
+Either way --admin-token-file must hold the agent's admin token.
+
+Flags:
+`
+
+const (
+	// endpointStateKind is the kind of the objects served by GET /v1/endpoints/by-ip/{ip}
+	endpointStateKind = "EndpointState"
+
+	// tokenHeader carries the admin token, like admin.TokenHeader
+	tokenHeader = "X-Admin-Token"
+
+	// podAnnotation and nodeAnnotation record which pod and node an exported
+	// EndpointState was looked up for
+	podAnnotation  = "networking.knabben.github.io/pod"
+	nodeAnnotation = "networking.knabben.github.io/node"
+)
+
+// options are the global plugin flags
+type options struct {
+	kubeconfig     string
+	kubeContext    string
+	adminURL       string
+	adminPort      int
+	adminTokenFile string
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file; defaults to the usual kubectl lookup.")
+	flag.StringVar(&opts.kubeContext, "context", "", "Kubeconfig context to use.")
+	flag.StringVar(&opts.adminURL, "admin-url", "",
+		"Base URL of an agent admin API (e.g. http://127.0.0.1:8082 through kubectl port-forward); skips the node proxy.")
+	flag.IntVar(&opts.adminPort, "admin-port", 8082, "Port of the agent admin API on the node, used with the node proxy.")
+	flag.StringVar(&opts.adminTokenFile, "admin-token-file", "", "File holding the agent's admin token (its --admin-token-file).")
+	timeout := flag.Duration("timeout", 30*time.Second, "Timeout for the whole command.")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	if err := run(ctx, opts, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "kubectl-winfw:", err)
+		os.Exit(1)
+	}
+}
+
+// run dispatches a plugin command
+func run(ctx context.Context, opts options, args []string) error {
+	switch {
+	case len(args) >= 2 && args[0] == "inspect":
+		return inspect(ctx, opts, args[1], args[2:])
+	default:
+		flag.Usage()
+		return fmt.Errorf("invalid arguments")
+	}
+}
+
+// inspect prints the EndpointState of the pod named by target
+func inspect(ctx context.Context, opts options, target string, args []string) error {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	var namespace, output string
+	flags.StringVar(&namespace, "namespace", "", "Namespace of the pod; defaults to the kubeconfig context's.")
+	flags.StringVar(&namespace, "n", "", "Shorthand for --namespace.")
+	flags.StringVar(&output, "output", "table", "Output format: table, yaml or json.")
+	flags.StringVar(&output, "o", "table", "Shorthand for --output.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	switch output {
+	case "table", "yaml", "json":
+	default:
+		return fmt.Errorf("unknown output format %q: must be table, yaml or json", output)
+	}
+
+	name, err := podName(target)
+	if err != nil {
+		return err
+	}
+
+	loader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: opts.kubeconfig, Precedence: clientcmd.NewDefaultClientConfigLoadingRules().Precedence},
+		&clientcmd.ConfigOverrides{CurrentContext: opts.kubeContext})
+	config, err := loader.ClientConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	if namespace == "" {
+		if namespace, _, err = loader.Namespace(); err != nil {
+			return fmt.Errorf("failed to read the kubeconfig namespace: %w", err)
+		}
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if err := inspectable(pod); err != nil {
+		return err
+	}
+
+	baseURL, httpClient, err := adminAPI(config, opts, pod.Spec.NodeName)
+	if err != nil {
+		return err
+	}
+	token, err := adminToken(opts.adminTokenFile)
+	if err != nil {
+		return err
+	}
+	state, err := endpointState(ctx, httpClient, baseURL, token, pod.Status.PodIP)
+	if err != nil {
+		return fmt.Errorf("pod %s/%s on node %s: %w", pod.Namespace, pod.Name, pod.Spec.NodeName, err)
+	}
+	annotations := state.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string, 2)
+	}
+	annotations[podAnnotation] = pod.Namespace + "/" + pod.Name
+	annotations[nodeAnnotation] = pod.Spec.NodeName
+	state.SetAnnotations(annotations)
+
+	switch output {
+	case "yaml":
+		out, err := yaml.Marshal(state.Object)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(out)
+		return err
+	case "json":
+		out, err := json.MarshalIndent(state.Object, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Println(string(out))
+		return err
+	default:
+		return printState(os.Stdout, state)
+	}
+}
+
+// podName parses "pod/<name>" (or "pods/", "po/", or a bare name)
+func podName(target string) (string, error) {
+	kind, name, found := strings.Cut(target, "/")
+	if !found {
+		return target, nil
+	}
+	switch strings.ToLower(kind) {
+	case "pod", "pods", "po":
+		return name, nil
+	default:
+		return "", fmt.Errorf("unsupported resource %q: only pods can be inspected", kind)
+	}
+}
+
+// inspectable rejects pods without an HCN endpoint of their own
+func inspectable(pod *corev1.Pod) error {
+	switch {
+	case pod.Spec.HostNetwork:
+		return fmt.Errorf("pod %s/%s uses the host network and has no HCN endpoint", pod.Namespace, pod.Name)
+	case pod.Spec.NodeName == "":
+		return fmt.Errorf("pod %s/%s is not scheduled to a node", pod.Namespace, pod.Name)
+	case pod.Status.PodIP == "":
+		return fmt.Errorf("pod %s/%s has no IP address yet", pod.Namespace, pod.Name)
+	}
+	return nil
+}
+
+// adminAPI returns the base URL and HTTP client reaching the admin API of
+// the agent on node: the --admin-url as is, or the API server node proxy
+// authenticated like every other kubectl request
+func adminAPI(config *rest.Config, opts options, node string) (string, *http.Client, error) {
+	if opts.adminURL != "" {
+		return strings.TrimSuffix(opts.adminURL, "/"), http.DefaultClient, nil
+	}
+	httpClient, err := rest.HTTPClientFor(config)
+	if err != nil {
+		return "", nil, err
+	}
+	host := strings.TrimSuffix(config.Host, "/")
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	proxy := fmt.Sprintf("%s/api/v1/nodes/%s:%d/proxy", host, url.PathEscape(node), opts.adminPort)
+	return proxy, httpClient, nil
+}
+
+// adminToken reads the admin token from path; no path sends none
+func adminToken(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read admin token: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// endpointState fetches the EndpointState of the endpoint owning ip
+func endpointState(ctx context.Context, httpClient *http.Client, baseURL, token, ip string) (*unstructured.Unstructured, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/v1/endpoints/by-ip/"+url.PathEscape(ip), nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set(tokenHeader, token)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("admin request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read admin response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &failure) != nil || failure.Error == "" {
+			// Not an admin API response, e.g. the node proxy failing to connect
+			failure.Error = strings.TrimSpace(string(body))
+		}
+		return nil, fmt.Errorf("admin request failed (HTTP %d): %s", resp.StatusCode, failure.Error)
+	}
+
+	state := &unstructured.Unstructured{}
+	if err := state.UnmarshalJSON(body); err != nil {
+		return nil, fmt.Errorf("failed to decode admin response: %w", err)
+	}
+	if state.GetKind() != endpointStateKind {
+		return nil, fmt.Errorf("unexpected admin response kind %q", state.GetKind())
+	}
+	return state, nil
+}
+
+// printState writes the policies and rules of an EndpointState as a table
+func printState(out io.Writer, state *unstructured.Unstructured) error {
+	endpointID, _, _ := unstructured.NestedString(state.Object, "status", "endpointID")
+	addresses, _, _ := unstructured.NestedStringSlice(state.Object, "status", "ipAddresses")
+	policies, _, _ := unstructured.NestedSlice(state.Object, "status", "policies")
+
+	annotations := state.GetAnnotations()
+	fmt.Fprintf(out, "Pod:       %s\n", annotations[podAnnotation])
+	fmt.Fprintf(out, "Node:      %s\n", annotations[nodeAnnotation])
+	fmt.Fprintf(out, "Endpoint:  %s (%s)\n", endpointID, strings.Join(addresses, ", "))
+	if len(policies) == 0 {
+		fmt.Fprintln(out, "Policies:  <none>")
+		return nil
+	}
+	fmt.Fprintln(out)
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "POLICY\tSTATUS\tPRIORITY\tNAME\tACTION\tDIRECTION\tPROTOCOL\tLOCAL PORTS\tREMOTE ADDRESSES")
+	for _, item := range policies {
+		policy, _ := item.(map[string]any)
+		key, status := field(policy, "key"), field(policy, "status")
+		rules, _ := policy["rules"].([]any)
+		if len(rules) == 0 {
+			fmt.Fprintf(w, "%s\t%s\t\t<no rules>\t\t\t\t\t\n", key, status)
+		}
+		for _, item := range rules {
+			rule, _ := item.(map[string]any)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				key, status, field(rule, "priority"), field(rule, "name"), field(rule, "action"), field(rule, "direction"),
+				orAny(field(rule, "protocol")), orAny(field(rule, "localPorts")), orAny(field(rule, "remoteAddresses")))
+		}
+		if message := field(policy, "error"); message != "" {
+			fmt.Fprintf(w, "%s\t%s\t\terror: %s\t\t\t\t\t\n", key, status, message)
+		}
+	}
+	return w.Flush()
+}
+
+// field renders a scalar field of a decoded JSON object; unset fields are empty
+func field(object map[string]any, name string) string {
+	value, exists := object[name]
+	if !exists || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// orAny renders an unset rule field, which matches anything
+func orAny(value string) string {
+	if value == "" {
+		return "*"
+	}
+	return value
+}
@@ -19,29 +19,56 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
+	"fmt"
+	"math"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
-	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
+	v1alpha1 "github.com/knabben/firewall-controller/api/v1alpha1"
+	"github.com/knabben/firewall-controller/internal/admin"
 	"github.com/knabben/firewall-controller/internal/controller"
+	"github.com/knabben/firewall-controller/internal/converter"
+	"github.com/knabben/firewall-controller/internal/dryrun"
+	"github.com/knabben/firewall-controller/internal/events"
+	"github.com/knabben/firewall-controller/internal/features"
 	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	"github.com/knabben/firewall-controller/internal/logging"
+	"github.com/knabben/firewall-controller/internal/notify"
+	"github.com/knabben/firewall-controller/internal/peers"
+	"github.com/knabben/firewall-controller/internal/perfcounters"
+	"github.com/knabben/firewall-controller/internal/tuning"
+	"github.com/knabben/firewall-controller/internal/verify"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -53,6 +80,7 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(networkingv1.AddToScheme(scheme))
+	utilruntime.Must(v1alpha1.AddToScheme(scheme))
 
 	// +kubebuilder:scaffold:scheme
 }
@@ -64,15 +92,84 @@ func main() {
 	var webhookCertPath, webhookCertName, webhookCertKey string
 	var enableLeaderElection bool
 	var probeAddr string
+	var hcnHealthTimeout, hcnLivenessGrace time.Duration
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var resyncPeriod time.Duration
+	var hnsNotifications bool
+	var staticRulesFile string
+	var includeNamespaceEndpoints bool
+	var hnsNamespaces string
+	var basePriority, priorityStride uint
+	var reservedPriorities string
+	var adminNetworkPolicyBand string
+	var priorityBlock uint
+	var autoAllowDNS bool
+	var kubeDNSIP, nodeLocalDNSIP string
+	var egressGateways string
+	var healthProbeSources string
+	var adminAddr, adminTokenFile string
+	var faultInjection bool
+	var notifyWebhookURL, notifyWebhookTokenFile string
+	var perfCountersInterval time.Duration
+	var stateDir string
+	var stateEncryptionFlag string
+	var stateless bool
+	var endpointBackups int
+	var ruleHistory int
+	var maxConcurrentReconciles, endpointWorkers, maxInFlightHCNCalls int
+	var applyTimeout time.Duration
+	var maxRemoteAddresses int
+	var packRules bool
+	var strictEnforcement bool
+	var isolateIngress bool
+	var isolateEgress bool
+	var podReadinessGate bool
+	var enforcementPause bool
+	var podExemptions bool
+	var exemptPodSelectors string
+	var programPodEndpoints bool
+	var podEventDelay time.Duration
+	var verifyRules bool
+	var verifyTimeout time.Duration
+	var applyScopeFlag string
+	var endpointFailureThreshold int
+	var endpointBackoffInitial, endpointBackoffMax time.Duration
+	var retryOpts controller.RetryOptions
+	var staleCheckInterval time.Duration
+	var anyAddressForm, allPortsForm string
+	var aclFeatures string
+	var addressFamily string
+	var remoteSubnetConflicts string
+	var hyperVEndpoints string
+	var priorityCollisions string
+	var peerResolverKind, peerHostsFile string
+	var gogc string
+	var memoryLimit string
+	var perfMode bool
+	var policySources string
+	var watchNamespace string
+	var localPodsOnly bool
+	var dryRunManifests string
+	var dryRun bool
+	var disallowedCIDRs string
+	var gracefulShutdownTimeout time.Duration
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	flag.DurationVar(&hcnHealthTimeout, "hcn-health-timeout", 5*time.Second,
+		"How long HNS may take to list its networks before /readyz fails. 0 leaves HCN out of the health probes.")
+	flag.DurationVar(&hcnLivenessGrace, "hcn-liveness-grace", 2*time.Minute,
+		"How long HCN may fail the health probe before /healthz fails too, so the kubelet restarts the agent. "+
+			"0 only reports HCN failures through /readyz.")
+	flag.DurationVar(&gracefulShutdownTimeout, "graceful-shutdown-timeout", 8*time.Second,
+		"How long the agent waits for in-flight reconciles and HCN calls to finish once it is asked to stop. "+
+			"Keep it below the pod's terminationGracePeriodSeconds so the kubelet does not kill the agent mid-apply.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
-			"Enabling this will ensure there is only one active controller manager.")
+			"Enabling this will ensure there is only one active controller manager, so only one node would "+
+			"enforce policies; leave it off when running as a DaemonSet.")
 	flag.BoolVar(&secureMetrics, "metrics-secure", true,
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.StringVar(&webhookCertPath, "webhook-cert-path", "", "The directory that contains the webhook certificate.")
@@ -84,13 +181,370 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
-	opts := zap.Options{
-		Development: true,
-	}
-	opts.BindFlags(flag.CommandLine)
+	flag.DurationVar(&resyncPeriod, "resync-period", 5*time.Minute,
+		"How often the full desired ACL state is reconciled against all HCN endpoints.")
+	flag.BoolVar(&hnsNotifications, "hns-notifications", true,
+		"Reconcile as soon as HNS reports endpoints being attached or detached, in addition to the periodic resync.")
+	flag.StringVar(&staticRulesFile, "static-rules-file", "",
+		"Path to a JSON file of node-wide ACL rule sets applied to every endpoint.")
+	flag.BoolVar(&includeNamespaceEndpoints, "include-namespace-endpoints", true,
+		"Also discover endpoints attached to HNS namespaces (network compartments) missed by the default listing.")
+	flag.StringVar(&hnsNamespaces, "hns-namespaces", "",
+		"Comma-separated HNS namespace IDs to restrict endpoint discovery to. Empty means all namespaces.")
+	flag.UintVar(&basePriority, "base-priority", 100, "Priority of the first ACL rule generated for a NetworkPolicy.")
+	flag.UintVar(&priorityStride, "priority-stride", 1, "Gap between the priorities of consecutive generated ACL rules.")
+	flag.UintVar(&priorityBlock, "priority-block", 16,
+		"Size of the blocks priority ranges are handed out in, one range per NetworkPolicy so rules of different "+
+			"policies never share a priority. 0 lets every policy start at --base-priority, as in earlier releases.")
+	flag.StringVar(&reservedPriorities, "reserved-priorities", "",
+		"Comma-separated priority ranges owned by other agents, e.g. 1-99,4000-4100. No rules are emitted in them.")
+	flag.StringVar(&adminNetworkPolicyBand, "admin-network-policy-band", "",
+		"Priority range, e.g. 70-99, AdminNetworkPolicies are programmed in, ahead of every NetworkPolicy. "+
+			"Empty disables AdminNetworkPolicy support; the policy.networking.k8s.io CRDs must be installed otherwise.")
+	flag.BoolVar(&autoAllowDNS, "auto-allow-dns", false,
+		"Inject UDP/TCP 53 egress allow rules to the DNS servers into every policy that restricts egress.")
+	flag.StringVar(&kubeDNSIP, "kube-dns-ip", "10.96.0.10", "Service IP of kube-dns, allowed by --auto-allow-dns.")
+	flag.StringVar(&nodeLocalDNSIP, "node-local-dns-ip", "",
+		"Node-local DNS cache IP (e.g. 169.254.20.10), allowed by --auto-allow-dns.")
+	flag.StringVar(&egressGateways, "egress-gateways", "",
+		"Comma-separated SNAT or egress gateway IPs/CIDRs traffic to ipBlock peers leaves through; "+
+			"egress rules to ipBlock peers also match them.")
+	flag.StringVar(&healthProbeSources, "health-probe-sources", "",
+		"Comma-separated IPs/CIDRs (node CIDR, load-balancer probe IPs) always allowed on ingress, above any default-deny.")
+	flag.DurationVar(&perfCountersInterval, "perf-counters-interval", 0,
+		"How often Windows performance counters are updated; 0 disables them. "+
+			"Requires config/perfcounters/networkpolicy-agent.man installed with lodctr.")
+	flag.StringVar(&stateDir, "state-dir", "",
+		"Directory for the agent's node-local state, such as endpoint ACL backups and priority assignments. Empty disables them.")
+	flag.StringVar(&stateEncryptionFlag, "state-encryption", string(hcnpkg.StateEncryptionNone),
+		"How the priority assignments file and endpoint backups in --state-dir are protected at rest: none (plain JSON), "+
+			"user (DPAPI, readable only by the agent's account) or machine (DPAPI, readable by any account on the node).")
+	flag.BoolVar(&stateless, "stateless", false,
+		"Reconstruct the rules a previous run programmed from the ACLs on the endpoints instead of a state file, "+
+			"for hosts where no state may be written to disk. Cannot be combined with --state-dir.")
+	flag.IntVar(&endpointBackups, "endpoint-backups", 5,
+		"Number of ACL backups kept per endpoint in --state-dir.")
+	flag.IntVar(&ruleHistory, "rule-history", 10,
+		"Number of rule table snapshots kept in memory per endpoint for fwctl history; 0 disables them.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"Number of NetworkPolicies converted and programmed at once per policy source.")
+	flag.IntVar(&endpointWorkers, "endpoint-workers", 1,
+		"Number of endpoints a single policy apply programs in parallel. Raise on nodes with many endpoints.")
+	flag.IntVar(&maxInFlightHCNCalls, "max-inflight-hcn-calls", 0,
+		"Maximum number of HCN calls in flight across all applies. 0 means unlimited.")
+	flag.DurationVar(&applyTimeout, "apply-timeout", 0,
+		"Deadline for programming one NetworkPolicy's endpoints in a reconcile. Interrupted applies are "+
+			"requeued and resume with the endpoints they missed. 0 means no deadline.")
+	flag.IntVar(&maxRemoteAddresses, "max-remote-addresses", 0,
+		"Maximum number of resolved peer addresses per ACL rule; larger peers are split into several rules. "+
+			"0 means unlimited.")
+	flag.BoolVar(&packRules, "pack-rules", false,
+		"Merge the rules of a NetworkPolicy that differ only in remote addresses or ports into one ACL each, "+
+			"to stay under the number of policies an endpoint accepts.")
+	flag.BoolVar(&strictEnforcement, "strict-enforcement", false,
+		"Reject NetworkPolicies using constructs that would not be enforced (unresolved selectors or named ports, "+
+			"ipBlock except) instead of enforcing the rest: nothing of such a policy is programmed, "+
+			"and an event and metric report it.")
+	flag.BoolVar(&isolateIngress, "isolate-ingress", true,
+		"Deny the ingress of pods selected by a NetworkPolicy with the Ingress policy type that no policy allows, "+
+			"with a Block-all rule after every generated rule.")
+	flag.BoolVar(&isolateEgress, "isolate-egress", true,
+		"Deny the egress of pods selected by a NetworkPolicy with the Egress policy type that no policy allows, "+
+			"with a Block-all rule after every generated rule.")
+	flag.BoolVar(&enforcementPause, "enforcement-pause", false,
+		"Honor the networking.knabben.github.io/enforcement: paused pod annotation, which removes every rule from "+
+			"the pod's endpoint. Anyone allowed to annotate a pod can then lift its NetworkPolicies.")
+	flag.BoolVar(&podExemptions, "pod-exemptions", false,
+		"Honor the networking.knabben.github.io/exempt: \"true\" pod label, which exempts the pod's endpoint from "+
+			"every rule. Anyone allowed to label a pod can then lift its NetworkPolicies.")
+	flag.StringVar(&exemptPodSelectors, "exempt-pod-selectors", "",
+		"Label selectors, separated by semicolons, of system pods whose endpoints are exempt from every rule, "+
+			"e.g. \"k8s-app=kube-dns;app=csi-proxy\".")
+	flag.BoolVar(&programPodEndpoints, "program-pod-endpoints", true,
+		"Program the endpoint of every pod starting on this node with the rules of the policies covering it as soon "+
+			"as HNS creates it, instead of at the next --resync-period.")
+	flag.BoolVar(&podReadinessGate, "pod-readiness-gate", false,
+		"Serve a mutating webhook adding the "+string(controller.PolicyReadinessGate)+" readiness gate to Windows pods, "+
+			"and keep pods on this node that list it NotReady until their endpoint carries the rules of every policy.")
+	flag.DurationVar(&podEventDelay, "pod-event-delay", 0,
+		"How long pod events are collected before the NetworkPolicies they affect are reconciled. "+
+			"Set to a few seconds for namespaces with thousands of pods. 0 reconciles on every event.")
+	flag.BoolVar(&verifyRules, "verify-rules", false,
+		"After applying a NetworkPolicy, send TCP connection attempts to the ports its ingress rules name on its endpoints "+
+			"and report VerificationFailed events when HNS does not enforce the verdict of the rules.")
+	flag.DurationVar(&verifyTimeout, "verify-timeout", 2*time.Second,
+		"How long a --verify-rules connection attempt may take before it counts as blocked.")
+	flag.StringVar(&applyScopeFlag, "apply-scope", string(controller.ApplyScopeSelector),
+		"Which endpoints receive a NetworkPolicy's rules: selector (only the pods selected by spec.podSelector), "+
+			"all-endpoints (every endpoint on the node, the behavior of earlier releases) or network (once per HNS "+
+			"network hosting the node's endpoints, as NetworkACL policies).")
+	flag.IntVar(&endpointFailureThreshold, "endpoint-failure-threshold",
+		hcnpkg.DefaultEndpointBackoffOptions().FailureThreshold,
+		"Consecutive failed applies after which an endpoint is suspended from policy syncs and only probed "+
+			"with backoff. 0 never suspends endpoints.")
+	flag.DurationVar(&endpointBackoffInitial, "endpoint-backoff-initial", hcnpkg.DefaultEndpointBackoffOptions().InitialDelay,
+		"How long an endpoint is first suspended; every failed probe doubles it.")
+	flag.DurationVar(&endpointBackoffMax, "endpoint-backoff-max", hcnpkg.DefaultEndpointBackoffOptions().MaxDelay,
+		"Longest an endpoint is suspended between probes.")
+	flag.DurationVar(&retryOpts.InitialBackoff, "requeue-initial-backoff", controller.DefaultRetryOptions().InitialBackoff,
+		"How long a policy whose reconcile failed waits for its first retry; each consecutive failure doubles it.")
+	flag.DurationVar(&retryOpts.MaxBackoff, "requeue-max-backoff", controller.DefaultRetryOptions().MaxBackoff,
+		"Longest a failing policy waits between retries, and how long a policy waits for ACL priorities to be freed.")
+	flag.Float64Var(&retryOpts.QPS, "requeue-qps", controller.DefaultRetryOptions().QPS,
+		"Retries of failed reconciles per second, across the policies of each controller.")
+	flag.IntVar(&retryOpts.Burst, "requeue-burst", controller.DefaultRetryOptions().Burst,
+		"Retries of failed reconciles allowed above --requeue-qps at once.")
+	flag.DurationVar(&staleCheckInterval, "stale-policy-check-interval", time.Minute,
+		"How often to check whether an API server watch dropped since the last check; if so, NetworkPolicies are "+
+			"listed and the rules of those deleted meanwhile are removed. 0 disables the check.")
+	flag.StringVar(&addressFamily, "address-family", string(converter.AddressFamilyIPv4),
+		"IP families of the cluster: IPv4, IPv6 or DualStack. Rules without peers match 0.0.0.0/0, ::/0 or both; "+
+			"each endpoint only receives the rules and addresses of its own families.")
+	flag.StringVar(&remoteSubnetConflicts, "remote-subnet-conflicts", string(hcnpkg.RemoteSubnetWarn),
+		"How Block rules covering the provider address of an overlay remote subnet route are handled: ignore, "+
+			"warn (log and count them) or exclude (also leave the provider addresses out of the rules).")
+	flag.StringVar(&hyperVEndpoints, "hyperv-endpoints", string(hcnpkg.HyperVEnforce),
+		"How endpoints of Hyper-V isolated containers are programmed: ignore (as process-isolated), enforce "+
+			"(with Host rules moved to the virtual switch, the only place their traffic is seen) or exclude "+
+			"(no rules, with a warning per endpoint).")
+	flag.StringVar(&priorityCollisions, "priority-collisions", string(hcnpkg.PriorityCollisionRemap),
+		"What happens to a rule whose priority an out-of-band ACL on the endpoint already holds, as found by the "+
+			"drift check: remap (move it to the next free priority) or fail (stop programming the policy on the endpoint).")
+	flag.StringVar(&anyAddressForm, "any-address-form", string(hcnpkg.AnyAddressCIDR),
+		"How \"any remote address\" is sent to HNS: cidr (0.0.0.0/0, ::/0) or empty (empty RemoteAddresses). "+
+			"It is not detected from the Windows build; switch to empty on nodes where allow-all rules have no effect.")
+	flag.StringVar(&allPortsForm, "all-ports-form", string(hcnpkg.AllPortsOmit),
+		"How TCP/UDP rules matching every port are sent to HNS: omit (no port field) or range (\""+
+			hcnpkg.AllPortsRangeValue+"\"). It is not detected from the Windows build; switch to range on nodes "+
+			"that reject or ignore rules without a port field.")
+	flag.StringVar(&aclFeatures, "acl-features", "",
+		"Comma-separated optional ACL fields the node's HNS honors and static rules may use: "+
+			hcnpkg.ACLFeatureLocalAddresses+", "+hcnpkg.ACLFeatureRuleType+". They are not detected; "+
+			"empty sends only the fields every build accepts.")
+	flag.StringVar(&adminAddr, "admin-bind-address", "",
+		"The address the node-local admin API (used by fwctl) binds to, e.g. 127.0.0.1:8082. "+
+			"Empty or 0 disables it; enabling it requires --admin-token-file.")
+	flag.StringVar(&adminTokenFile, "admin-token-file", "",
+		"File holding the token every admin API request must send in the X-Admin-Token header.")
+	flag.BoolVar(&faultInjection, "fault-injection", false,
+		"Serve the admin API endpoints simulating failures (paused applies, HNS outage, delayed reconciles) for game "+
+			"days. Faults are applied before HCN calls reach HNS and end on their own after at most an hour.")
+	flag.StringVar(&notifyWebhookURL, "notify-webhook-url", "",
+		"HTTP(S) URL receiving a JSON event each time a NetworkPolicy's rules are applied, removed or fail on this node. "+
+			"Empty disables notifications.")
+	flag.StringVar(&notifyWebhookTokenFile, "notify-webhook-token-file", "",
+		"File holding a bearer token sent with every --notify-webhook-url request; it is read again for each request.")
+	flag.StringVar(&gogc, "gogc", "",
+		"Go GC target percentage with the meanings of GOGC: off (or a negative value) leaves collection to "+
+			"--memory-limit and 0 collects continuously. Empty keeps the runtime default.")
+	flag.StringVar(&memoryLimit, "memory-limit", "",
+		"Soft Go memory limit as a quantity, e.g. 900Mi (like GOMEMLIMIT). Set it a little below the container limit.")
+	flag.BoolVar(&perfMode, "perf-mode", false,
+		"Trade memory for fewer GC pauses during mass resyncs (GOGC=400 unless --gogc is set). Requires --memory-limit.")
+	flag.StringVar(&policySources, "policy-sources", "",
+		"Additional clusters whose NetworkPolicies are enforced on this node, as comma-separated name=kubeconfig pairs. "+
+			"The priority band is split evenly between the local cluster and each source.")
+	flag.StringVar(&watchNamespace, "watch-namespace", "",
+		"Only enforce the NetworkPolicies of this namespace, watching nothing else, for shared clusters where a team "+
+			"runs the agent for its own namespace with namespace-scoped RBAC. Requires --apply-scope=selector. "+
+			"Empty watches every namespace.")
+	flag.BoolVar(&localPodsOnly, "local-pods-only", false,
+		"Only watch the pods scheduled on this node, with a spec.nodeName field selector, instead of every pod of "+
+			"the cluster. Same-namespace peers and named ports of egress peers then only see local pods. "+
+			"Cannot be combined with --peer-resolver=informer or --admin-network-policy-band.")
+	flag.StringVar(&dryRunManifests, "dry-run-manifests", "",
+		"Validate the NetworkPolicy and NamespaceDefaultPolicy manifests in this directory against a fake HCN "+
+			"with the configured conversion flags, then exit non-zero if any fails. No cluster or HNS is needed.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Compute the ACLs of every policy but never change HNS: each policy that would be added to or removed from "+
+			"an endpoint or network is logged, counted and listed by fwctl dry-run instead. Cannot be combined "+
+			"with --state-dir.")
+	flag.StringVar(&peerResolverKind, "peer-resolver", string(peers.KindNone),
+		"How podSelector peers are resolved to IPs: none (skipped), informer (pods in the cluster), "+
+			"file (--peer-hosts-file) or crd (PeerMapping objects).")
+	flag.StringVar(&peerHostsFile, "peer-hosts-file", "",
+		"JSON hosts file podSelector peers are resolved against with --peer-resolver=file.")
+	flag.StringVar(&disallowedCIDRs, "disallowed-cidrs", "",
+		"Comma-separated CIDRs removed from every NetworkPolicy allow rule, e.g. the cloud metadata endpoint.")
+	flag.Var(features.DefaultGate, "feature-gates",
+		"Comma-separated Feature=true|false pairs toggling experimental subsystems. Known features:\n"+
+			strings.Join(features.DefaultGate.KnownFeatures(), "\n"))
+	logOpts := logging.Options{Level: "debug", Encoding: "console", TimeFormat: "iso8601"}
+	logOpts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
-	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	logger, err := logging.New(logOpts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	ctrl.SetLogger(logger)
+	setupLog.Info("Feature gates", "features", features.DefaultGate.State())
+
+	// Tune the garbage collector before any rules are loaded
+	gcOpts := tuning.GCOptions{GOGC: gogc, PerfMode: perfMode}
+	if memoryLimit != "" {
+		quantity, err := resource.ParseQuantity(memoryLimit)
+		if err != nil {
+			setupLog.Error(err, "invalid memory limit", "memory-limit", memoryLimit)
+			os.Exit(1)
+		}
+		gcOpts.MemoryLimit = quantity.Value()
+	}
+	gcSettings, err := tuning.Apply(gcOpts)
+	if err != nil {
+		setupLog.Error(err, "unable to apply GC settings")
+		os.Exit(1)
+	}
+	setupLog.Info("Go GC settings", "gogc", gcSettings.GOGC, "memoryLimitBytes", gcSettings.MemoryLimit, "perfMode", perfMode)
+
+	// Build NetworkPolicy conversion options from flags
+	conversionOpts := converter.DefaultConversionOptions()
+	if basePriority > math.MaxUint16 || priorityStride > math.MaxUint16 {
+		setupLog.Error(nil, "priority flags must fit in 16 bits",
+			"base-priority", basePriority, "priority-stride", priorityStride)
+		os.Exit(1)
+	}
+	conversionOpts.BasePriority = uint16(basePriority)
+	conversionOpts.PriorityStride = uint16(priorityStride)
+	conversionOpts.MaxRemoteAddresses = maxRemoteAddresses
+	conversionOpts.PackRules = packRules
+	conversionOpts.Strict = strictEnforcement
+	conversionOpts.IsolateIngress = isolateIngress
+	conversionOpts.IsolateEgress = isolateEgress
+	conversionOpts.AddressFamily, err = converter.ParseAddressFamily(addressFamily)
+	if err != nil {
+		setupLog.Error(err, "unable to parse address family")
+		os.Exit(1)
+	}
+	conversionOpts.ReservedPriorities, err = hcnpkg.ParsePriorityRanges(reservedPriorities)
+	if err != nil {
+		setupLog.Error(err, "unable to parse reserved priorities")
+		os.Exit(1)
+	}
+	var adminTier *converter.AdminTierOptions
+	if adminNetworkPolicyBand != "" {
+		bands, err := hcnpkg.ParsePriorityRanges(adminNetworkPolicyBand)
+		if err == nil && len(bands) != 1 {
+			err = fmt.Errorf("expected a single priority range, got %q", adminNetworkPolicyBand)
+		}
+		if err != nil {
+			setupLog.Error(err, "unable to parse admin network policy band")
+			os.Exit(1)
+		}
+		adminTier = &converter.AdminTierOptions{BasePriority: bands[0].Start, MaxPriority: bands[0].End}
+		if err := adminTier.Validate(conversionOpts.BasePriority); err != nil {
+			setupLog.Error(err, "invalid admin network policy band")
+			os.Exit(1)
+		}
+		// Quarantine and health probe rules must keep winning over the admin tier
+		if adminTier.BasePriority <= hcnpkg.HealthProbePriority {
+			setupLog.Error(nil, "admin network policy band must start after the health probe priority",
+				"admin-network-policy-band", adminNetworkPolicyBand, "healthProbePriority", hcnpkg.HealthProbePriority)
+			os.Exit(1)
+		}
+	}
+	if autoAllowDNS {
+		for _, ip := range []string{kubeDNSIP, nodeLocalDNSIP} {
+			if ip != "" {
+				conversionOpts.AutoAllowDNS = append(conversionOpts.AutoAllowDNS, ip)
+			}
+		}
+	}
+	conversionOpts.EgressGateways, err = converter.ParseEgressGateways(strings.Split(egressGateways, ","))
+	if err != nil {
+		setupLog.Error(err, "unable to parse egress gateways")
+		os.Exit(1)
+	}
+	// Hooks compiled into the agent run first, then the built-in ones enabled by flags
+	conversionOpts.PreHooks, conversionOpts.PostHooks = converter.RegisteredHooks()
+	if disallowedCIDRs != "" {
+		stripHook, err := converter.NewStripCIDRsHook(strings.Split(disallowedCIDRs, ","))
+		if err != nil {
+			setupLog.Error(err, "unable to parse disallowed CIDRs")
+			os.Exit(1)
+		}
+		conversionOpts.PostHooks = append(conversionOpts.PostHooks, stripHook)
+	}
+	if err := conversionOpts.Validate(); err != nil {
+		setupLog.Error(err, "invalid NetworkPolicy conversion options")
+		os.Exit(1)
+	}
+
+	// Give every policy source its own slice of the priority band so rules from
+	// different clusters never share a priority on an endpoint
+	sources, err := controller.ParsePolicySources(policySources)
+	if err != nil {
+		setupLog.Error(err, "unable to parse policy sources")
+		os.Exit(1)
+	}
+	if len(sources) > 0 && !features.Enabled(features.PolicySources) {
+		setupLog.Error(nil, "--policy-sources requires the PolicySources feature gate")
+		os.Exit(1)
+	}
+	sourceOpts := []converter.ConversionOptions{conversionOpts}
+	if len(sources) > 0 {
+		bands, err := hcnpkg.SplitPriorityBand(conversionOpts.BasePriority, conversionOpts.MaxPriority, len(sources)+1)
+		if err != nil {
+			setupLog.Error(err, "unable to split the priority band between policy sources")
+			os.Exit(1)
+		}
+		sourceOpts = sourceOpts[:0]
+		for _, band := range bands {
+			bandOpts := conversionOpts
+			bandOpts.BasePriority, bandOpts.MaxPriority = band.Start, band.End
+			if err := bandOpts.Validate(); err != nil {
+				setupLog.Error(err, "invalid priority band for policy source", "band", band.String())
+				os.Exit(1)
+			}
+			sourceOpts = append(sourceOpts, bandOpts)
+		}
+	}
+
+	peerResolver, err := peers.New(peers.Kind(peerResolverKind), peerHostsFile)
+	if err != nil {
+		setupLog.Error(err, "invalid peer resolver")
+		os.Exit(1)
+	}
+
+	applyScope, err := controller.ParseApplyScope(applyScopeFlag)
+	if err != nil {
+		setupLog.Error(err, "invalid apply scope")
+		os.Exit(1)
+	}
+	if err := retryOpts.Validate(); err != nil {
+		setupLog.Error(err, "invalid requeue options")
+		os.Exit(1)
+	}
+	if err := controller.ValidateWatchNamespace(watchNamespace); err != nil {
+		setupLog.Error(err, "invalid watch namespace")
+		os.Exit(1)
+	}
+	if watchNamespace != "" && (applyScope != controller.ApplyScopeSelector || adminTier != nil) {
+		// Both would program rules on the endpoints of pods outside the namespace
+		setupLog.Error(nil, "--watch-namespace requires --apply-scope=selector and no --admin-network-policy-band")
+		os.Exit(1)
+	}
+	if localPodsOnly && (peers.Kind(peerResolverKind) == peers.KindInformer || adminTier != nil) {
+		// Both resolve the pods of other nodes as peers
+		setupLog.Error(nil, "--local-pods-only cannot be combined with --peer-resolver=informer or --admin-network-policy-band")
+		os.Exit(1)
+	}
+	exemptSelectors, err := controller.ParseExemptSelectors(exemptPodSelectors)
+	if err != nil {
+		setupLog.Error(err, "invalid exempt pod selectors")
+		os.Exit(1)
+	}
+	exemptions := &controller.PodExemptions{Label: podExemptions, Selectors: exemptSelectors}
+	if applyScope != controller.ApplyScopeSelector && (isolateIngress || isolateEgress) {
+		setupLog.Info("WARNING: pod isolation with this apply scope isolates every endpoint of the node",
+			"applyScope", applyScope)
+	}
+
+	// Validate manifests against a fake HCN and exit, for CI pipelines
+	if dryRunManifests != "" {
+		os.Exit(runDryRun(dryRunManifests, conversionOpts, peerResolver))
+	}
 
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
@@ -144,6 +598,14 @@ func main() {
 		BindAddress:   metricsAddr,
 		SecureServing: secureMetrics,
 		TLSOpts:       tlsOpts,
+		// Exemplars, such as the provenance of rules HNS rejected, are only
+		// exposed in the OpenMetrics format
+		ExtraHandlers: map[string]http.Handler{
+			"/metrics/openmetrics": promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{
+				ErrorHandling:     promhttp.HTTPErrorOnError,
+				EnableOpenMetrics: true,
+			}),
+		},
 	}
 
 	if secureMetrics {
@@ -181,13 +643,40 @@ func main() {
 		})
 	}
 
+	// Get NODE_NAME from environment variable (set by DaemonSet)
+	nodeName := os.Getenv("NODE_NAME")
+	if nodeName == "" {
+		setupLog.Info("NODE_NAME environment variable not set, using hostname")
+		var err error
+		nodeName, err = os.Hostname()
+		if err != nil {
+			setupLog.Error(err, "unable to get hostname")
+			os.Exit(1)
+		}
+	}
+
+	// Dropped watches may hide NetworkPolicy deletions; sweep for them afterwards
+	watchMonitor := &controller.WatchMonitor{}
+	cacheOpts := cache.Options{DefaultWatchErrorHandler: watchMonitor.WatchErrorHandler}
+	if watchNamespace != "" {
+		cacheOpts = controller.NamespacedCacheOptions(cacheOpts, watchNamespace)
+		setupLog.Info("Watching a single namespace", "namespace", watchNamespace)
+	}
+	if localPodsOnly {
+		cacheOpts = controller.LocalPodsCacheOptions(cacheOpts, nodeName)
+		setupLog.Info("Watching the pods of this node only", "nodeName", nodeName)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
+		Cache:                  cacheOpts,
 		Metrics:                metricsServerOptions,
 		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "9286c889.knabben.github.io",
+		// The DaemonSet gives the agent 10s to stop; finish within them
+		GracefulShutdownTimeout: &gracefulShutdownTimeout,
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -205,34 +694,529 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Get NODE_NAME from environment variable (set by DaemonSet)
-	nodeName := os.Getenv("NODE_NAME")
-	if nodeName == "" {
-		setupLog.Info("NODE_NAME environment variable not set, using hostname")
-		var err error
-		nodeName, err = os.Hostname()
+	// Initialize HCN client and manager
+	setupLog.Info("Initializing HCN client", "nodeName", nodeName)
+	clientOpts := hcnpkg.ClientOptions{IncludeNamespaceEndpoints: includeNamespaceEndpoints}
+	if hnsNamespaces != "" {
+		clientOpts.Namespaces = strings.Split(hnsNamespaces, ",")
+	}
+	hcnClient := hcnpkg.NewHCNClientWithOptions(clientOpts)
+	hcnManager := hcnpkg.NewManager(hcnClient, ctrl.Log.WithName("hcn"))
+	if dryRun {
+		// Simulated rules must not outlive the agent in the state directory
+		if stateDir != "" {
+			setupLog.Error(nil, "--dry-run cannot be combined with --state-dir")
+			os.Exit(1)
+		}
+		hcnManager.SetDryRun(1000)
+	}
+
+	// Subsystems react to what the HCN Manager does through the event bus
+	eventBus := events.NewBus()
+	hcnManager.SetEventBus(eventBus)
+	events.Subscribe(eventBus, func(restart events.HNSRestarted) {
+		// HNS may have come back without the rules it carried before
+		go func() {
+			if err := hcnManager.RepairDrift(); err != nil {
+				setupLog.Error(err, "Drift repair after HNS restart failed", "downtime", restart.Downtime)
+			}
+		}()
+	})
+	anyAddress, err := hcnpkg.ParseAnyAddressForm(anyAddressForm)
+	if err != nil {
+		setupLog.Error(err, "invalid any-address form")
+		os.Exit(1)
+	}
+	anyAddress = hcnManager.SetAnyAddressForm(anyAddress, conversionOpts.AddressFamily != converter.AddressFamilyDualStack)
+	allPorts, err := hcnpkg.ParseAllPortsForm(allPortsForm)
+	if err != nil {
+		setupLog.Error(err, "invalid all-ports form")
+		os.Exit(1)
+	}
+	allPorts = hcnManager.SetAllPortsForm(allPorts)
+	aclCapabilities, err := hcnpkg.ParseACLFeatures(aclFeatures)
+	if err != nil {
+		setupLog.Error(err, "invalid ACL features")
+		os.Exit(1)
+	}
+	hcnManager.SetACLCapabilities(aclCapabilities)
+	setupLog.Info("HNS ACL capabilities",
+		"localAddresses", aclCapabilities.LocalAddresses, "ruleType", aclCapabilities.RuleType)
+	remoteSubnetMode, err := hcnpkg.ParseRemoteSubnetMode(remoteSubnetConflicts)
+	if err != nil {
+		setupLog.Error(err, "invalid remote subnet conflict mode")
+		os.Exit(1)
+	}
+	hcnManager.SetRemoteSubnetMode(remoteSubnetMode)
+	hyperVMode, err := hcnpkg.ParseHyperVMode(hyperVEndpoints)
+	if err != nil {
+		setupLog.Error(err, "invalid Hyper-V endpoint mode")
+		os.Exit(1)
+	}
+	hcnManager.SetHyperVMode(hyperVMode)
+	priorityCollisionMode, err := hcnpkg.ParsePriorityCollisionMode(priorityCollisions)
+	if err != nil {
+		setupLog.Error(err, "invalid priority collision mode")
+		os.Exit(1)
+	}
+	hcnManager.SetPriorityCollisionMode(priorityCollisionMode)
+	setupLog.Info("HNS settings forms", "anyAddress", anyAddress, "allPorts", allPorts)
+	if err := hcnManager.SetConcurrency(hcnpkg.ConcurrencyOptions{
+		EndpointWorkers:  endpointWorkers,
+		MaxInFlightCalls: maxInFlightHCNCalls,
+	}); err != nil {
+		setupLog.Error(err, "invalid HCN concurrency options")
+		os.Exit(1)
+	}
+	if err := hcnManager.SetEndpointBackoff(hcnpkg.EndpointBackoffOptions{
+		FailureThreshold: endpointFailureThreshold,
+		InitialDelay:     endpointBackoffInitial,
+		MaxDelay:         endpointBackoffMax,
+	}); err != nil {
+		setupLog.Error(err, "invalid endpoint backoff options")
+		os.Exit(1)
+	}
+
+	var faults *hcnpkg.FaultInjector
+	if faultInjection {
+		faults = hcnpkg.NewFaultInjector()
+		hcnManager.SetFaultInjector(faults)
+	}
+
+	// Export cache sizes alongside the controller-runtime metrics
+	metrics.Registry.MustRegister(hcnManager.Collector())
+
+	// Back up endpoint ACLs before rules are removed or replaced on them
+	if stateless && stateDir != "" {
+		setupLog.Error(nil, "--stateless cannot be combined with --state-dir")
+		os.Exit(1)
+	}
+	if stateless {
+		// Everything in the agent's priority bands is taken for its own
+		owned := []hcnpkg.PriorityRange{
+			{Start: hcnpkg.QuarantinePriority, End: hcnpkg.HealthProbePriority},
+			{Start: conversionOpts.BasePriority, End: hcnpkg.MaxPriority},
+		}
+		if err := hcnManager.WarmStartFromEndpoints(owned, conversionOpts.ReservedPriorities); err != nil {
+			setupLog.Error(err, "unable to reconstruct programmed rules from endpoints")
+			os.Exit(1)
+		}
+	}
+	if stateDir != "" {
+		stateEncryption, err := hcnpkg.ParseStateEncryption(stateEncryptionFlag)
 		if err != nil {
-			setupLog.Error(err, "unable to get hostname")
+			setupLog.Error(err, "invalid state encryption")
+			os.Exit(1)
+		}
+		backups, err := hcnpkg.NewBackupStore(filepath.Join(stateDir, "backups"), endpointBackups)
+		if err != nil {
+			setupLog.Error(err, "unable to create endpoint backup store")
+			os.Exit(1)
+		}
+		backups.SetEncryption(stateEncryption)
+		hcnManager.SetBackupStore(backups)
+
+		// Take over the rules a previous run programmed instead of adding them again
+		priorities, err := hcnpkg.NewPriorityStore(filepath.Join(stateDir, "priorities.json"))
+		if err == nil {
+			priorities.SetEncryption(stateEncryption)
+			err = hcnManager.SetPriorityStore(priorities)
+		}
+		if err != nil {
+			setupLog.Error(err, "unable to restore priority assignments")
 			os.Exit(1)
 		}
 	}
 
-	// Initialize HCN client and manager
-	setupLog.Info("Initializing HCN client", "nodeName", nodeName)
-	hcnClient := hcnpkg.NewHCNClient()
-	hcnManager := hcnpkg.NewManager(hcnClient, ctrl.Log.WithName("hcn"))
+	// Keep recent rule tables of each endpoint for fwctl history
+	hcnManager.SetRuleHistory(ruleHistory)
+
+	// Register additional rule providers alongside the NetworkPolicy store
+	if staticRulesFile != "" {
+		staticProvider, err := hcnpkg.LoadStaticProvider(staticRulesFile)
+		if err != nil {
+			setupLog.Error(err, "unable to load static rules", "path", staticRulesFile)
+			os.Exit(1)
+		}
+		hcnManager.RegisterProvider(staticProvider)
+	}
+	if healthProbeSources != "" {
+		probeProvider, err := hcnpkg.NewHealthProbeProvider(strings.Split(healthProbeSources, ","))
+		if err != nil {
+			setupLog.Error(err, "unable to configure health probe sources")
+			os.Exit(1)
+		}
+		hcnManager.RegisterProvider(probeProvider)
+	}
+	hcnManager.RegisterProvider(hcnpkg.NewQuarantineProvider())
+	namespaceDefaults := hcnpkg.NewTargetedProvider("namespacedefault")
+	hcnManager.RegisterProvider(namespaceDefaults)
+	adminNetworkPolicies := hcnpkg.NewTargetedProvider("adminnetworkpolicy")
+	if adminTier != nil {
+		hcnManager.RegisterProvider(adminNetworkPolicies)
+	}
+
+	// Warn about rules written by other agents inside our priority band
+	conflicts, err := hcnManager.ValidatePriorityBand(conversionOpts.BasePriority, conversionOpts.ReservedPriorities)
+	if err != nil {
+		setupLog.Error(err, "unable to validate ACL priority band against endpoints")
+	}
+	for _, conflict := range conflicts {
+		setupLog.Info("WARNING: foreign ACL found in controller priority band, consider --reserved-priorities",
+			"endpointID", conflict.EndpointID,
+			"priority", conflict.Priority,
+			"direction", conflict.Direction)
+	}
+
+	// Hold rule programming back until every informer policies are read from has synced
+	cacheSync := controller.NewCacheSyncGate(ctrl.Log.WithName("controller").WithName("CacheSync"))
+	localObjects := informedObjects(peerResolver,
+		&networkingv1.NetworkPolicy{}, &corev1.Pod{}, &corev1.Namespace{}, &v1alpha1.NamespaceDefaultPolicy{})
+	if adminTier != nil {
+		anp := &unstructured.Unstructured{}
+		anp.SetGroupVersionKind(converter.AdminNetworkPolicyGVK)
+		localObjects = append(localObjects, anp)
+	}
+	cacheSync.AddCache("local", mgr.GetCache(), localObjects...)
+	if err := mgr.Add(cacheSync); err != nil {
+		setupLog.Error(err, "unable to add cache sync gate to manager")
+		os.Exit(1)
+	}
+
+	// Reconcile policies again when the HCN Manager cannot keep them converged;
+	// the work queue collapses repeated requests for one policy
+	requeues := controller.NewRequeueDispatcher(hcnManager.EnableRequeues(256),
+		ctrl.Log.WithName("controller").WithName("Requeue"))
+	if err := mgr.Add(requeues); err != nil {
+		setupLog.Error(err, "unable to add requeue dispatcher to manager")
+		os.Exit(1)
+	}
+
+	// Periodically converge all endpoints toward the desired ACL state
+	if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		if err := cacheSync.Wait(ctx); err != nil {
+			return nil
+		}
+		return hcnManager.Run(ctx, resyncPeriod)
+	})); err != nil {
+		setupLog.Error(err, "unable to add HCN resync loop to manager")
+		os.Exit(1)
+	}
+	if hnsNotifications {
+		// Endpoints created between resyncs get their rules right away
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			if err := cacheSync.Wait(ctx); err != nil {
+				return nil
+			}
+			notifications, err := hcnpkg.SubscribeNotifications(ctx, 1)
+			if err != nil {
+				setupLog.Info("HNS notifications unavailable, relying on the periodic resync", "error", err.Error())
+				return nil
+			}
+			return hcnManager.WatchNotifications(ctx, notifications)
+		})); err != nil {
+			setupLog.Error(err, "unable to add HNS notification watch to manager")
+			os.Exit(1)
+		}
+	}
+
+	// Serve node-local operator actions for fwctl
+	var adminServer *admin.Server
+	if adminAddr != "" && adminAddr != "0" {
+		// The admin actions rewrite ACLs and any process on the node can connect
+		if adminTokenFile == "" {
+			setupLog.Error(nil, "--admin-bind-address requires --admin-token-file")
+			os.Exit(1)
+		}
+		adminToken, err := admin.ReadTokenFile(adminTokenFile)
+		if err != nil {
+			setupLog.Error(err, "unable to load admin token", "path", adminTokenFile)
+			os.Exit(1)
+		}
+		adminServer = admin.NewServer(adminAddr, hcnManager, ctrl.Log.WithName("admin"))
+		adminServer.SetToken(adminToken)
+		if faults != nil {
+			adminServer.SetFaultInjector(faults)
+		}
+		if err := mgr.Add(adminServer); err != nil {
+			setupLog.Error(err, "unable to add admin server to manager")
+			os.Exit(1)
+		}
+	}
+
+	// Publish controller health to Windows monitoring agents
+	if perfCountersInterval > 0 {
+		publisher := perfcounters.NewPublisher(func() perfcounters.Values {
+			applyStats, stats := hcnManager.ApplyStats(), hcnManager.Stats()
+			return perfcounters.Values{
+				RulesProgrammed: applyStats.RulesProgrammed,
+				Applies:         applyStats.Applies,
+				ApplyFailures:   applyStats.Failures,
+				ApplyLatency:    applyStats.LastDuration,
+				TrackedPolicies: uint64(stats.TrackedPolicies),
+				Endpoints:       uint64(stats.Endpoints),
+			}
+		}, perfCountersInterval, ctrl.Log.WithName("perfcounters"))
+		if err := mgr.Add(publisher); err != nil {
+			setupLog.Error(err, "unable to add performance counter publisher to manager")
+			os.Exit(1)
+		}
+	}
+
+	// Send enforcement changes to an external receiver
+	var notifier notify.Notifier
+	if notifyWebhookURL != "" {
+		webhookOpts := notify.DefaultWebhookOptions()
+		webhookOpts.URL = notifyWebhookURL
+		webhookOpts.BearerTokenFile = notifyWebhookTokenFile
+		sink, err := notify.NewWebhookSink(webhookOpts, ctrl.Log.WithName("notify"))
+		if err != nil {
+			setupLog.Error(err, "invalid notification webhook")
+			os.Exit(1)
+		}
+		if err := mgr.Add(sink); err != nil {
+			setupLog.Error(err, "unable to add notification webhook to manager")
+			os.Exit(1)
+		}
+		notifier = sink
+	}
+
+	// Reads bypassing the cache must stay within the RBAC of namespaced mode
+	apiReader := mgr.GetAPIReader()
+	if watchNamespace != "" {
+		apiReader = controller.NamespacedReader{Reader: apiReader, Namespace: watchNamespace}
+	}
 
 	// Setup NetworkPolicy controller
-	if err = controller.NewNetworkPolicyReconciler(
+	reconciler := controller.NewNetworkPolicyReconciler(
 		mgr.GetClient(),
 		mgr.GetScheme(),
 		hcnManager,
 		nodeName,
 		ctrl.Log.WithName("controller").WithName("NetworkPolicy"),
-	).SetupWithManager(mgr); err != nil {
+	)
+	reconciler.ConversionOptions = sourceOpts[0]
+	reconciler.Priorities = newPriorityAllocator(hcnManager, sourceOpts[0], priorityBlock)
+	reconciler.Recorder = mgr.GetEventRecorderFor("networkpolicy-agent")
+	reconciler.Notifier = notifier
+	reconciler.MaxConcurrentReconciles = maxConcurrentReconciles
+	reconciler.ApplyTimeout = applyTimeout
+	reconciler.PodEventDelay = podEventDelay
+	reconciler.Retry = retryOpts
+	reconciler.ApplyScope = applyScope
+	reconciler.PeerResolver = peerResolver
+	reconciler.CacheSync = cacheSync
+	reconciler.Requeues = requeues
+	reconciler.Faults = faults
+	if verifyRules {
+		verifier := controller.NewPolicyVerifier(hcnManager, verify.TCPProber{Timeout: verifyTimeout},
+			mgr.GetEventRecorderFor("networkpolicy-agent"),
+			controller.VerificationOptions{MaxEndpoints: 4, MaxProbes: 4},
+			ctrl.Log.WithName("controller").WithName("PolicyVerifier"))
+		if err := mgr.Add(verifier); err != nil {
+			setupLog.Error(err, "unable to add policy verifier to manager")
+			os.Exit(1)
+		}
+		reconciler.Verifier = verifier
+	}
+	if err = reconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NetworkPolicy")
 		os.Exit(1)
 	}
+	if adminServer != nil {
+		adminServer.SetEnforcementMatrix(reconciler.EnforcementMatrix)
+	}
+	if staleCheckInterval > 0 {
+		sweeper := controller.NewStalePolicySweeper(reconciler, apiReader, hcnManager, watchMonitor,
+			staleCheckInterval, ctrl.Log.WithName("controller").WithName("StalePolicySweeper"))
+		if err := mgr.Add(sweeper); err != nil {
+			setupLog.Error(err, "unable to add stale policy sweeper to manager")
+			os.Exit(1)
+		}
+	}
+	if stateDir != "" {
+		// Remove the restored rules of policies deleted while the agent was down
+		cleaner := controller.NewOrphanCleaner(reconciler, apiReader, 30*time.Second,
+			ctrl.Log.WithName("controller").WithName("OrphanCleaner"))
+		if err := mgr.Add(cleaner); err != nil {
+			setupLog.Error(err, "unable to add orphaned rule cleaner to manager")
+			os.Exit(1)
+		}
+	}
+
+	// Hold gated pods NotReady until their endpoint is programmed
+	if podReadinessGate {
+		if err := (&controller.PodReadinessGateInjector{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PodReadinessGate")
+			os.Exit(1)
+		}
+		readinessReconciler := &controller.PodReadinessReconciler{
+			Client:     mgr.GetClient(),
+			HCNManager: hcnManager,
+			NodeName:   nodeName,
+			Policies:   reconciler,
+			CacheSync:  cacheSync,
+			Exemptions: exemptions,
+		}
+		if err := readinessReconciler.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "PodReadiness")
+			os.Exit(1)
+		}
+	}
+
+	// Program the endpoints of pods started after their policies were applied
+	if programPodEndpoints {
+		podEndpointReconciler := &controller.PodEndpointReconciler{
+			Client:     mgr.GetClient(),
+			HCNManager: hcnManager,
+			NodeName:   nodeName,
+			Policies:   reconciler,
+			CacheSync:  cacheSync,
+			Exemptions: exemptions,
+		}
+		if err := podEndpointReconciler.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "PodEndpoint")
+			os.Exit(1)
+		}
+	}
+
+	// Lift the rules from the endpoints of pods annotated for debugging
+	if enforcementPause {
+		pauseReconciler := &controller.PodEnforcementReconciler{
+			Client:     mgr.GetClient(),
+			HCNManager: hcnManager,
+			NodeName:   nodeName,
+			Recorder:   mgr.GetEventRecorderFor("networkpolicy-agent"),
+		}
+		if err := pauseReconciler.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "PodEnforcement")
+			os.Exit(1)
+		}
+	}
+
+	// Exempt the endpoints of system pods from every rule
+	if exemptions.Enabled() {
+		exemptionReconciler := &controller.PodExemptionReconciler{
+			Client:     mgr.GetClient(),
+			HCNManager: hcnManager,
+			NodeName:   nodeName,
+			Exemptions: exemptions,
+			Recorder:   mgr.GetEventRecorderFor("networkpolicy-agent"),
+		}
+		if err := exemptionReconciler.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "PodExemption")
+			os.Exit(1)
+		}
+	}
+
+	// Setup a NetworkPolicy controller per additional policy source
+	for i, src := range sources {
+		sourceConfig, err := clientcmd.BuildConfigFromFlags("", src.Kubeconfig)
+		if err != nil {
+			setupLog.Error(err, "unable to load policy source kubeconfig", "source", src.Name)
+			os.Exit(1)
+		}
+		sourceMonitor := &controller.WatchMonitor{}
+		sourceCluster, err := cluster.New(sourceConfig, func(o *cluster.Options) {
+			o.Scheme = scheme
+			o.Cache.DefaultWatchErrorHandler = sourceMonitor.WatchErrorHandler
+			if watchNamespace != "" {
+				o.Cache = controller.NamespacedCacheOptions(o.Cache, watchNamespace)
+			}
+		})
+		if err != nil {
+			setupLog.Error(err, "unable to create policy source cluster", "source", src.Name)
+			os.Exit(1)
+		}
+		if err := mgr.Add(sourceCluster); err != nil {
+			setupLog.Error(err, "unable to add policy source cluster to manager", "source", src.Name)
+			os.Exit(1)
+		}
+
+		sourceReconciler := controller.NewNetworkPolicyReconciler(
+			sourceCluster.GetClient(),
+			sourceCluster.GetScheme(),
+			hcnManager,
+			nodeName,
+			ctrl.Log.WithName("controller").WithName("NetworkPolicy").WithValues("source", src.Name),
+		)
+		sourceReconciler.SourceName = src.Name
+		sourceReconciler.ConversionOptions = sourceOpts[i+1]
+		sourceReconciler.Priorities = newPriorityAllocator(hcnManager, sourceOpts[i+1], priorityBlock)
+		sourceReconciler.Recorder = sourceCluster.GetEventRecorderFor("networkpolicy-agent")
+		sourceReconciler.Notifier = notifier
+		sourceReconciler.MaxConcurrentReconciles = maxConcurrentReconciles
+		sourceReconciler.ApplyTimeout = applyTimeout
+		sourceReconciler.PodEventDelay = podEventDelay
+		sourceReconciler.Retry = retryOpts
+		sourceReconciler.ApplyScope = applyScope
+		sourceReconciler.PeerResolver = peerResolver
+		sourceReconciler.CacheSync = cacheSync
+		sourceReconciler.Requeues = requeues
+		sourceReconciler.Faults = faults
+		sourceReader := sourceCluster.GetAPIReader()
+		if watchNamespace != "" {
+			sourceReader = controller.NamespacedReader{Reader: sourceReader, Namespace: watchNamespace}
+		}
+		cacheSync.AddCache(src.Name, sourceCluster.GetCache(), informedObjects(peerResolver,
+			&networkingv1.NetworkPolicy{}, &corev1.Pod{}, &corev1.Namespace{})...)
+		if staleCheckInterval > 0 {
+			sweeper := controller.NewStalePolicySweeper(sourceReconciler, sourceReader, hcnManager,
+				sourceMonitor, staleCheckInterval,
+				ctrl.Log.WithName("controller").WithName("StalePolicySweeper").WithValues("source", src.Name))
+			if err := mgr.Add(sweeper); err != nil {
+				setupLog.Error(err, "unable to add stale policy sweeper to manager", "source", src.Name)
+				os.Exit(1)
+			}
+		}
+		if stateDir != "" {
+			cleaner := controller.NewOrphanCleaner(sourceReconciler, sourceReader, 30*time.Second,
+				ctrl.Log.WithName("controller").WithName("OrphanCleaner").WithValues("source", src.Name))
+			if err := mgr.Add(cleaner); err != nil {
+				setupLog.Error(err, "unable to add orphaned rule cleaner to manager", "source", src.Name)
+				os.Exit(1)
+			}
+		}
+		if err := sourceReconciler.SetupWithCluster(mgr, sourceCluster); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "NetworkPolicy", "source", src.Name)
+			os.Exit(1)
+		}
+		setupLog.Info("Watching NetworkPolicies of policy source", "source", src.Name,
+			"priorityBand", fmt.Sprintf("%d-%d", sourceOpts[i+1].BasePriority, sourceOpts[i+1].MaxPriority))
+	}
+
+	// Setup NamespaceDefaultPolicy controller
+	namespaceDefaultReconciler := controller.NewNamespaceDefaultPolicyReconciler(
+		mgr.GetClient(),
+		mgr.GetScheme(),
+		hcnManager,
+		namespaceDefaults,
+	)
+	namespaceDefaultReconciler.AutoAllowDNS = conversionOpts.AutoAllowDNS
+	namespaceDefaultReconciler.CacheSync = cacheSync
+	namespaceDefaultReconciler.Retry = retryOpts
+	if err = namespaceDefaultReconciler.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NamespaceDefaultPolicy")
+		os.Exit(1)
+	}
+
+	// Setup AdminNetworkPolicy controller
+	if adminTier != nil {
+		adminReconciler := &controller.AdminNetworkPolicyReconciler{
+			Client:     mgr.GetClient(),
+			HCNManager: hcnManager,
+			Rules:      adminNetworkPolicies,
+			NodeName:   nodeName,
+			Tier:       *adminTier,
+			CacheSync:  cacheSync,
+			Retry:      retryOpts,
+		}
+		if err = adminReconciler.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "AdminNetworkPolicy")
+			os.Exit(1)
+		}
+		setupLog.Info("Programming AdminNetworkPolicies", "priorityBand", adminNetworkPolicyBand)
+	}
 
 	// +kubebuilder:scaffold:builder
 
@@ -260,6 +1244,25 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if err := mgr.AddReadyzCheck("cache-sync", cacheSync.ReadyzCheck); err != nil {
+		setupLog.Error(err, "unable to set up cache sync ready check")
+		os.Exit(1)
+	}
+	if hcnHealthTimeout > 0 {
+		// Fail the probes while HNS is unreachable instead of failing reconciles silently
+		hcnHealth := hcnpkg.NewHealthChecker(hcnManager, hcnpkg.HealthOptions{
+			Timeout:       hcnHealthTimeout,
+			LivenessGrace: hcnLivenessGrace,
+		})
+		if err := mgr.AddReadyzCheck("hcn", hcnHealth.ReadyzCheck); err != nil {
+			setupLog.Error(err, "unable to set up HCN ready check")
+			os.Exit(1)
+		}
+		if err := mgr.AddHealthzCheck("hcn", hcnHealth.HealthzCheck); err != nil {
+			setupLog.Error(err, "unable to set up HCN health check")
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -267,3 +1270,72 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// newPriorityAllocator returns the per-policy priority allocator of a policy
+// source's band, seeded with the ranges its tracked rules use so a restart
+// does not move them; nil when block is 0
+func newPriorityAllocator(manager *hcnpkg.Manager, opts converter.ConversionOptions, block uint) *hcnpkg.PriorityAllocator {
+	if block == 0 {
+		return nil
+	}
+	band := opts.PriorityBand()
+	allocator, err := hcnpkg.NewPriorityAllocator(band, opts.ReservedPriorities, uint16(min(block, uint(hcnpkg.MaxPriority))))
+	if err != nil {
+		setupLog.Error(err, "invalid priority allocation", "band", band.String())
+		os.Exit(1)
+	}
+	allocator.Restore(manager.TrackedPriorityRanges(band))
+	return allocator
+}
+
+// informedObjects returns objects plus the object the peer resolver watches, if any
+func informedObjects(peerResolver peers.PeerResolver, objects ...client.Object) []client.Object {
+	if watcher, ok := peerResolver.(peers.ObjectWatcher); ok {
+		objects = append(objects, watcher.WatchObject())
+	}
+	return objects
+}
+
+// runDryRun validates the manifests under dir and returns the process exit code.
+// Only the file peer resolver works without a cluster; others leave peers unresolved.
+func runDryRun(dir string, conversionOpts converter.ConversionOptions, peerResolver peers.PeerResolver) int {
+	logger := ctrl.Log.WithName("dry-run")
+	opts := dryrun.Options{Conversion: conversionOpts}
+	if fileResolver, ok := peerResolver.(*peers.FileResolver); ok {
+		opts.PeerResolver = fileResolver
+	}
+	report, err := dryrun.Run(dir, opts, scheme, logger.V(1))
+	if err != nil {
+		logger.Error(err, "unable to run dry run")
+		return 1
+	}
+
+	for _, result := range report.Results {
+		for _, warning := range result.Warnings {
+			logger.Info("WARNING: policy not enforced exactly as written",
+				"file", result.File,
+				"namespace", result.Namespace,
+				"name", result.Name,
+				"reason", warning.Reason,
+				"warning", warning.Message)
+		}
+	}
+
+	failed := report.Failed()
+	for _, result := range failed {
+		logger.Error(result.Err, "Policy failed validation",
+			"file", result.File,
+			"kind", result.Kind,
+			"namespace", result.Namespace,
+			"name", result.Name)
+	}
+	logger.Info("Dry run finished",
+		"policies", len(report.Results),
+		"failed", len(failed),
+		"skipped", report.Skipped)
+
+	if len(failed) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -9,27 +9,33 @@ import (
 
 	"github.com/Microsoft/hcsshim/hcn"
 	"github.com/go-logr/logr"
-	"github.com/go-logr/zapr"
 	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
-	"go.uber.org/zap"
+	"github.com/knabben/firewall-controller/internal/logging"
 )
 
 func main() {
 	var (
 		action     = flag.String("action", "apply", "Action to perform: apply or remove")
-		policyKey  = flag.String("policy", "test/example-policy", "Policy key (namespace/name)")
-		verbose    = flag.Bool("verbose", false, "Enable verbose logging")
+		policyFlag = flag.String("policy", "netpol/test/example-policy", "Policy key (kind/name, e.g. netpol/namespace/name)")
+		verbose    = flag.Bool("verbose", false, "Enable verbose logging (same as -log-level=debug)")
 	)
+	logOpts := logging.Options{Level: "info", Encoding: "json", TimeFormat: "epoch"}
+	logOpts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
 	// Setup logger
-	var logger logr.Logger
 	if *verbose {
-		zapLog, _ := zap.NewDevelopment()
-		logger = zapr.NewLogger(zapLog)
-	} else {
-		zapLog, _ := zap.NewProduction()
-		logger = zapr.NewLogger(zapLog)
+		logOpts.Level = "debug"
+	}
+	logger, err := logging.New(logOpts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	policyKey, err := hcnpkg.ParsePolicyKey(*policyFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
 	// Create HCN client and manager
@@ -38,14 +44,14 @@ func main() {
 
 	switch *action {
 	case "apply":
-		if err := applyExampleRules(manager, *policyKey); err != nil {
+		if err := applyExampleRules(manager, policyKey); err != nil {
 			logger.Error(err, "Failed to apply ACL rules")
 			os.Exit(1)
 		}
 		fmt.Println("Successfully applied ACL rules")
 
 	case "remove":
-		if err := manager.RemoveACLRules(*policyKey); err != nil {
+		if err := manager.RemoveACLRules(policyKey); err != nil {
 			logger.Error(err, "Failed to remove ACL rules")
 			os.Exit(1)
 		}
@@ -64,7 +70,7 @@ func main() {
 	}
 }
 
-func applyExampleRules(manager *hcnpkg.Manager, policyKey string) error {
+func applyExampleRules(manager *hcnpkg.Manager, policyKey hcnpkg.PolicyKey) error {
 	// Example NetworkPolicy: Allow HTTP/HTTPS ingress and DNS egress
 	rules := []hcnpkg.ACLRule{
 		{
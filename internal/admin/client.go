@@ -0,0 +1,151 @@
+//go:build windows
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-logr/logr"
+)
+
+// Client calls the admin API of a local agent
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	logger     logr.Logger
+	token      string
+}
+
+// NewClient creates a client for the admin server at baseURL (e.g. http://127.0.0.1:8082)
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: httpClient,
+		logger:     logr.Discard(),
+	}
+}
+
+// SetLogger sets the logger admin requests are logged to at V(1)
+func (c *Client) SetLogger(logger logr.Logger) {
+	c.logger = logger
+}
+
+// SetToken sets the admin token sent with every request
+func (c *Client) SetToken(token string) {
+	c.token = token
+}
+
+// ResyncEndpoint asks the agent to re-program every policy on an endpoint
+func (c *Client) ResyncEndpoint(ctx context.Context, endpointID string) error {
+	return c.post(ctx, "/v1/resync/endpoints/"+url.PathEscape(endpointID))
+}
+
+// ResyncPolicy asks the agent to re-program a policy (e.g. "namespace/name") on every endpoint
+func (c *Client) ResyncPolicy(ctx context.Context, policyKey string) error {
+	return c.post(ctx, "/v1/resync/policies/"+escapeKey(policyKey))
+}
+
+// ListPolicies returns one page of tracked policies matching opts
+func (c *Client) ListPolicies(ctx context.Context, opts ListOptions) (PolicyList, error) {
+	var list PolicyList
+	err := c.do(ctx, http.MethodGet, withQuery("/v1/policies", opts.query()), &list)
+	return list, err
+}
+
+// GetPolicy returns one page of the rules tracked for a policy key on each
+// endpoint; opts.EndpointID limits it to a single endpoint
+func (c *Client) GetPolicy(ctx context.Context, policyKey string, opts ListOptions) (PolicyRules, error) {
+	var rules PolicyRules
+	err := c.do(ctx, http.MethodGet, withQuery("/v1/policies/"+escapeKey(policyKey), opts.query()), &rules)
+	return rules, err
+}
+
+// withQuery appends encoded query parameters to path
+func withQuery(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
+
+// ListBackups returns the stored backups of an endpoint, newest first
+func (c *Client) ListBackups(ctx context.Context, endpointID string) (BackupList, error) {
+	var list BackupList
+	err := c.do(ctx, http.MethodGet, "/v1/backups/endpoints/"+url.PathEscape(endpointID), &list)
+	return list, err
+}
+
+// RestoreEndpoint asks the agent to restore an endpoint backup (the newest when
+// name is empty) and hold it until the endpoint is resynced
+func (c *Client) RestoreEndpoint(ctx context.Context, endpointID, name string) error {
+	query := url.Values{}
+	if name != "" {
+		query.Set("backup", name)
+	}
+	return c.post(ctx, withQuery("/v1/restore/endpoints/"+url.PathEscape(endpointID), query))
+}
+
+// RuleHistory returns the rule table snapshots of an endpoint, newest first
+func (c *Client) RuleHistory(ctx context.Context, endpointID string) (RuleHistory, error) {
+	var history RuleHistory
+	err := c.do(ctx, http.MethodGet, "/v1/history/endpoints/"+url.PathEscape(endpointID), &history)
+	return history, err
+}
+
+// escapeKey escapes each segment of a policy key for use in a path
+func escapeKey(policyKey string) string {
+	segments := strings.Split(policyKey, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// post issues a bodyless POST and decodes the admin Response
+func (c *Client) post(ctx context.Context, path string) error {
+	return c.do(ctx, http.MethodPost, path, nil)
+}
+
+// do issues a bodyless request. Successful responses are decoded into out
+// when set; failures are decoded as a Response carrying the error.
+func (c *Client) do(ctx context.Context, method, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set(TokenHeader, c.token)
+	}
+
+	c.logger.V(1).Info("Sending admin request", "method", method, "url", req.URL.String())
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("admin request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	c.logger.V(1).Info("Received admin response", "method", method, "url", req.URL.String(), "status", resp.StatusCode)
+
+	if resp.StatusCode != http.StatusOK || out == nil {
+		var body Response
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return fmt.Errorf("failed to decode admin response (HTTP %d): %w", resp.StatusCode, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("admin request failed (HTTP %d): %s", resp.StatusCode, body.Error)
+		}
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode admin response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+//go:build windows
+
+package admin
+
+import (
+	"context"
+	"net/http"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// DryRunInspector is the part of the HCN Manager that reports the changes
+// kept from HNS in dry-run mode. Backends without it, or not in dry-run
+// mode, answer GET /v1/dry-run with 404.
+type DryRunInspector interface {
+	// DryRunChanges returns the most recent changes suppressed in dry-run mode; nil unless enabled
+	DryRunChanges() []hcnpkg.DryRunChange
+}
+
+// DryRunList is the body of GET /v1/dry-run
+type DryRunList struct {
+	// Changes are the most recent changes kept from HNS, oldest first
+	Changes []hcnpkg.DryRunChange `json:"changes"`
+}
+
+// DryRunChanges returns the policy changes the agent would have made in HNS
+func (c *Client) DryRunChanges(ctx context.Context) (DryRunList, error) {
+	var list DryRunList
+	err := c.do(ctx, http.MethodGet, "/v1/dry-run", &list)
+	return list, err
+}
+
+func (s *Server) handleDryRun(w http.ResponseWriter, _ *http.Request) {
+	inspector, ok := s.backend.(DryRunInspector)
+	if !ok {
+		s.writeJSON(w, http.StatusNotFound, Response{Error: "dry run is not available"})
+		return
+	}
+	changes := inspector.DryRunChanges()
+	if changes == nil {
+		s.writeJSON(w, http.StatusNotFound, Response{Error: "the agent is not running in dry-run mode"})
+		return
+	}
+	s.writeJSON(w, http.StatusOK, DryRunList{Changes: changes})
+}
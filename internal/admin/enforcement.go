@@ -0,0 +1,31 @@
+//go:build windows
+
+package admin
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/knabben/firewall-controller/internal/converter"
+)
+
+// SetEnforcementMatrix serves matrix at GET /v1/enforcement. It is called on
+// every request, so the answer follows the agent's configuration.
+func (s *Server) SetEnforcementMatrix(matrix func() converter.EnforcementMatrix) {
+	s.enforcement = matrix
+}
+
+// EnforcementMatrix returns how the agent enforces every NetworkPolicy field
+func (c *Client) EnforcementMatrix(ctx context.Context) (converter.EnforcementMatrix, error) {
+	var matrix converter.EnforcementMatrix
+	err := c.do(ctx, http.MethodGet, "/v1/enforcement", &matrix)
+	return matrix, err
+}
+
+func (s *Server) handleEnforcement(w http.ResponseWriter, _ *http.Request) {
+	if s.enforcement == nil {
+		s.writeJSON(w, http.StatusNotFound, Response{Error: "the enforcement matrix is not available"})
+		return
+	}
+	s.writeJSON(w, http.StatusOK, s.enforcement())
+}
@@ -0,0 +1,119 @@
+//go:build windows
+
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// SetFaultInjector serves the faults of injector at /v1/faults for game days.
+// Without it the fault endpoints answer 404.
+func (s *Server) SetFaultInjector(injector *hcnpkg.FaultInjector) {
+	s.faults = injector
+}
+
+// FaultList is the body of GET /v1/faults
+type FaultList struct {
+	Items []hcnpkg.Fault `json:"items"`
+}
+
+// Faults returns the simulated failures in effect on the node
+func (c *Client) Faults(ctx context.Context) (FaultList, error) {
+	var list FaultList
+	err := c.do(ctx, http.MethodGet, "/v1/faults", &list)
+	return list, err
+}
+
+// InjectFault simulates a failure for duration; delay is the reconcile delay of
+// hcnpkg.FaultReconcileDelay
+func (c *Client) InjectFault(ctx context.Context, kind hcnpkg.FaultKind, duration, delay time.Duration) (hcnpkg.Fault, error) {
+	query := url.Values{}
+	query.Set("duration", duration.String())
+	if delay > 0 {
+		query.Set("delay", delay.String())
+	}
+	var fault hcnpkg.Fault
+	err := c.do(ctx, http.MethodPost, withQuery("/v1/faults/"+url.PathEscape(string(kind)), query), &fault)
+	return fault, err
+}
+
+// ClearFault ends a simulated failure, or all of them when kind is empty
+func (c *Client) ClearFault(ctx context.Context, kind hcnpkg.FaultKind) error {
+	path := "/v1/faults"
+	if kind != "" {
+		path += "/" + url.PathEscape(string(kind))
+	}
+	return c.do(ctx, http.MethodDelete, path, nil)
+}
+
+func (s *Server) handleListFaults(w http.ResponseWriter, _ *http.Request) {
+	if s.faults == nil {
+		s.writeJSON(w, http.StatusNotFound, Response{Error: "fault injection is disabled"})
+		return
+	}
+	s.writeJSON(w, http.StatusOK, FaultList{Items: s.faults.Active()})
+}
+
+func (s *Server) handleInjectFault(w http.ResponseWriter, r *http.Request) {
+	if s.faults == nil {
+		s.writeJSON(w, http.StatusNotFound, Response{Error: "fault injection is disabled"})
+		return
+	}
+	kind, err := hcnpkg.ParseFaultKind(r.PathValue("kind"))
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+	duration, delay, err := parseFaultDurations(r)
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+	fault, err := s.faults.Inject(kind, duration, delay)
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+	s.logger.Info("Injected fault", "kind", kind, "until", fault.Until, "delay", fault.Delay)
+	s.writeJSON(w, http.StatusOK, fault)
+}
+
+func (s *Server) handleClearFault(w http.ResponseWriter, r *http.Request) {
+	if s.faults == nil {
+		s.writeJSON(w, http.StatusNotFound, Response{Error: "fault injection is disabled"})
+		return
+	}
+	var kind hcnpkg.FaultKind
+	if value := r.PathValue("kind"); value != "" {
+		var err error
+		if kind, err = hcnpkg.ParseFaultKind(value); err != nil {
+			s.writeJSON(w, http.StatusBadRequest, Response{Error: err.Error()})
+			return
+		}
+	}
+	s.faults.Clear(kind)
+	s.logger.Info("Cleared fault", "kind", kind)
+	s.writeJSON(w, http.StatusOK, Response{Status: "cleared"})
+}
+
+// parseFaultDurations reads the duration and delay query parameters
+func parseFaultDurations(r *http.Request) (time.Duration, time.Duration, error) {
+	query := r.URL.Query()
+	duration, err := time.ParseDuration(query.Get("duration"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid duration %q: %w", query.Get("duration"), err)
+	}
+	var delay time.Duration
+	if value := query.Get("delay"); value != "" {
+		if delay, err = time.ParseDuration(value); err != nil {
+			return 0, 0, fmt.Errorf("invalid delay %q: %w", value, err)
+		}
+	}
+	return duration, delay, nil
+}
@@ -0,0 +1,307 @@
+//go:build windows
+
+package admin
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+const (
+	// DefaultPageSize is the page size of list responses when no limit is given
+	DefaultPageSize = 500
+
+	// MaxPageSize caps the limit a client may ask for
+	MaxPageSize = 5000
+)
+
+// PolicyList is the body of GET /v1/policies
+type PolicyList struct {
+	Items []PolicySummary `json:"items"`
+
+	// Continue is set when more items exist; pass it back to fetch the next page
+	Continue string `json:"continue,omitempty"`
+}
+
+// PolicySummary is the JSON form of a tracked policy without its rules
+type PolicySummary struct {
+	Key       string `json:"key"`
+	Status    string `json:"status"`
+	Endpoints int    `json:"endpoints"`
+	Rules     int    `json:"rules"`
+	Error     string `json:"error,omitempty"`
+}
+
+// PolicyRules is the body of GET /v1/policies/{key}: the rules tracked for a
+// policy key on each endpoint
+type PolicyRules struct {
+	Key       string          `json:"key"`
+	Endpoints []EndpointRules `json:"endpoints"`
+
+	// Continue is set when more endpoints exist; pass it back to fetch the next page
+	Continue string `json:"continue,omitempty"`
+}
+
+// EndpointRules are the rules of one policy programmed on one endpoint
+type EndpointRules struct {
+	EndpointID string `json:"endpointID"`
+	Rules      []Rule `json:"rules"`
+}
+
+// Rule is the JSON form of a tracked ACL rule
+type Rule struct {
+	Name            string            `json:"name,omitempty"`
+	Action          string            `json:"action"`
+	Direction       string            `json:"direction"`
+	Protocol        string            `json:"protocol,omitempty"`
+	LocalPorts      string            `json:"localPorts,omitempty"`
+	RemotePorts     string            `json:"remotePorts,omitempty"`
+	RemoteAddresses string            `json:"remoteAddresses,omitempty"`
+	LocalAddresses  string            `json:"localAddresses,omitempty"`
+	RuleType        string            `json:"ruleType,omitempty"`
+	Priority        uint16            `json:"priority"`
+	Labels          map[string]string `json:"labels,omitempty"`
+
+	// Packed are the rules merged into this one, when rule packing is enabled
+	Packed []Rule `json:"packed,omitempty"`
+}
+
+// ListOptions filters and pages list requests; empty fields match everything
+type ListOptions struct {
+	Namespace  string
+	EndpointID string
+	Status     string
+
+	// Limit is the page size; 0 uses DefaultPageSize
+	Limit int
+
+	// Continue is the token returned with the previous page
+	Continue string
+}
+
+// query encodes the options as URL query parameters
+func (o ListOptions) query() url.Values {
+	values := url.Values{}
+	for name, value := range map[string]string{
+		"namespace": o.Namespace,
+		"endpoint":  o.EndpointID,
+		"status":    o.Status,
+		"continue":  o.Continue,
+	} {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+	if o.Limit > 0 {
+		values.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return values
+}
+
+// parseListOptions reads and validates the list query parameters
+func parseListOptions(r *http.Request) (ListOptions, string, error) {
+	query := r.URL.Query()
+	opts := ListOptions{
+		Namespace:  query.Get("namespace"),
+		EndpointID: query.Get("endpoint"),
+		Status:     query.Get("status"),
+		Limit:      DefaultPageSize,
+	}
+
+	switch hcnpkg.PolicyStatus(opts.Status) {
+	case "", hcnpkg.PolicyStatusApplied, hcnpkg.PolicyStatusFailed:
+	default:
+		return opts, "", fmt.Errorf("invalid status %q: must be %s or %s", opts.Status, hcnpkg.PolicyStatusApplied, hcnpkg.PolicyStatusFailed)
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > MaxPageSize {
+			return opts, "", fmt.Errorf("invalid limit %q: must be between 1 and %d", limit, MaxPageSize)
+		}
+		opts.Limit = parsed
+	}
+
+	var after string
+	if token := query.Get("continue"); token != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			return opts, "", fmt.Errorf("invalid continue token")
+		}
+		after = string(decoded)
+	}
+	return opts, after, nil
+}
+
+// page returns the items of a sorted list after the continue position, up to
+// limit, and the token for the next page
+func page[T any](items []T, id func(T) string, after string, limit int) ([]T, string) {
+	start := sort.Search(len(items), func(i int) bool {
+		return id(items[i]) > after
+	})
+	items = items[start:]
+	if len(items) <= limit {
+		return items, ""
+	}
+	items = items[:limit]
+	return items, base64.RawURLEncoding.EncodeToString([]byte(id(items[limit-1])))
+}
+
+func (s *Server) handleListPolicies(w http.ResponseWriter, r *http.Request) {
+	opts, after, err := parseListOptions(r)
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	summaries := s.backend.Snapshot().ListPolicies(hcnpkg.PolicyFilter{
+		Namespace:  opts.Namespace,
+		EndpointID: opts.EndpointID,
+		Status:     hcnpkg.PolicyStatus(opts.Status),
+	})
+	summaries, next := page(summaries, func(summary hcnpkg.PolicySummary) string { return summary.Key }, after, opts.Limit)
+
+	list := PolicyList{Items: make([]PolicySummary, 0, len(summaries)), Continue: next}
+	for _, summary := range summaries {
+		list.Items = append(list.Items, PolicySummary{
+			Key:       summary.Key,
+			Status:    string(summary.Status),
+			Endpoints: summary.Endpoints,
+			Rules:     summary.Rules,
+			Error:     summary.Error,
+		})
+	}
+	s.writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) handleGetPolicy(w http.ResponseWriter, r *http.Request) {
+	opts, after, err := parseListOptions(r)
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	policyKey := r.PathValue("key")
+	ruleSets, found := s.backend.Snapshot().GetAppliedPolicies(policyKey)
+	if !found {
+		s.writeJSON(w, http.StatusNotFound, Response{Error: "policy " + policyKey + " is not tracked"})
+		return
+	}
+
+	result := policyRules(policyKey, ruleSets, opts.EndpointID)
+	result.Endpoints, result.Continue = page(result.Endpoints, func(endpoint EndpointRules) string { return endpoint.EndpointID }, after, opts.Limit)
+	s.writeJSON(w, http.StatusOK, result)
+}
+
+// policyRules converts tracked rule sets to their JSON form, sorted by
+// endpoint and limited to endpointID when set
+func policyRules(policyKey string, ruleSets []hcnpkg.RuleSet, endpointID string) PolicyRules {
+	result := PolicyRules{Key: policyKey, Endpoints: make([]EndpointRules, 0, len(ruleSets))}
+	for _, ruleSet := range ruleSets {
+		if endpointID != "" && ruleSet.EndpointID != endpointID {
+			continue
+		}
+		result.Endpoints = append(result.Endpoints, EndpointRules{EndpointID: ruleSet.EndpointID, Rules: jsonRules(ruleSet.Rules)})
+	}
+	sort.Slice(result.Endpoints, func(i, j int) bool {
+		return result.Endpoints[i].EndpointID < result.Endpoints[j].EndpointID
+	})
+	return result
+}
+
+// jsonRules converts ACL rules to their JSON form
+func jsonRules(rules []hcnpkg.ACLRule) []Rule {
+	out := make([]Rule, 0, len(rules))
+	for _, rule := range rules {
+		out = append(out, Rule{
+			Name:            rule.Name,
+			Action:          string(rule.Action),
+			Direction:       string(rule.Direction),
+			Protocol:        rule.Protocol,
+			LocalPorts:      rule.LocalPorts,
+			RemotePorts:     rule.RemotePorts,
+			RemoteAddresses: rule.RemoteAddresses,
+			LocalAddresses:  rule.LocalAddresses,
+			RuleType:        string(rule.RuleType),
+			Priority:        rule.Priority,
+			Labels:          rule.Labels,
+		})
+		if len(rule.Packed) > 0 {
+			out[len(out)-1].Packed = jsonRules(rule.Packed)
+		}
+	}
+	return out
+}
+
+// BackupList is the body of GET /v1/backups/endpoints/{id}
+type BackupList struct {
+	EndpointID string   `json:"endpointID"`
+	Items      []Backup `json:"items"`
+}
+
+// Backup describes a stored endpoint backup
+type Backup struct {
+	Name   string    `json:"name"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason"`
+}
+
+func (s *Server) handleListBackups(w http.ResponseWriter, r *http.Request) {
+	endpointID := r.PathValue("id")
+	backups, err := s.backend.ListBackups(endpointID)
+	if err != nil {
+		s.writeJSON(w, errorStatus(err), Response{Error: err.Error()})
+		return
+	}
+
+	list := BackupList{EndpointID: endpointID, Items: make([]Backup, 0, len(backups))}
+	for _, backup := range backups {
+		list.Items = append(list.Items, Backup{Name: backup.Name, Time: backup.Time, Reason: backup.Reason})
+	}
+	s.writeJSON(w, http.StatusOK, list)
+}
+
+// RuleHistory is the body of GET /v1/history/endpoints/{id}
+type RuleHistory struct {
+	EndpointID string `json:"endpointID"`
+
+	// Items are the endpoint's rule table snapshots, newest first
+	Items []RuleTable `json:"items"`
+}
+
+// RuleTable is the controller-owned rule table of an endpoint at one point in time
+type RuleTable struct {
+	Time time.Time `json:"time"`
+
+	// Cause is the key of the policy whose change produced the table
+	Cause string `json:"cause"`
+
+	// Policies are the rules on the endpoint, by policy key
+	Policies map[string][]Rule `json:"policies"`
+}
+
+func (s *Server) handleRuleHistory(w http.ResponseWriter, r *http.Request) {
+	endpointID := r.PathValue("id")
+	snapshots, err := s.backend.RuleHistory(endpointID)
+	if err != nil {
+		s.writeJSON(w, errorStatus(err), Response{Error: err.Error()})
+		return
+	}
+
+	history := RuleHistory{EndpointID: endpointID, Items: make([]RuleTable, 0, len(snapshots))}
+	for _, snapshot := range snapshots {
+		table := RuleTable{Time: snapshot.Time, Cause: snapshot.Cause, Policies: make(map[string][]Rule, len(snapshot.Policies))}
+		for key, rules := range snapshot.Policies {
+			table.Policies[key] = jsonRules(rules)
+		}
+		history.Items = append(history.Items, table)
+	}
+	s.writeJSON(w, http.StatusOK, history)
+}
@@ -0,0 +1,75 @@
+//go:build windows
+
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// Migrator is the part of the HCN Manager that takes over from another
+// network policy agent. Backends without it answer POST /v1/migrate with 404.
+type Migrator interface {
+	// Migrate reports, removes or adopts the ACLs agent programmed on the node
+	Migrate(agent hcnpkg.ForeignAgent, mode hcnpkg.MigrationMode) ([]hcnpkg.ForeignACL, error)
+}
+
+// MigrationResult is the body of POST /v1/migrate/{agent}
+type MigrationResult struct {
+	ACLs []hcnpkg.ForeignACL `json:"acls"`
+
+	// Error is set when some endpoints could not be migrated; ACLs still
+	// lists what was found and done
+	Error string `json:"error,omitempty"`
+}
+
+// Migrate reports the ACLs agent programmed on the node and, unless mode is
+// report, removes or adopts them
+func (c *Client) Migrate(ctx context.Context, agent hcnpkg.ForeignAgent, mode hcnpkg.MigrationMode) (MigrationResult, error) {
+	var result MigrationResult
+	query := url.Values{}
+	query.Set("mode", string(mode))
+	if err := c.do(ctx, http.MethodPost, withQuery("/v1/migrate/"+url.PathEscape(string(agent)), query), &result); err != nil {
+		return result, err
+	}
+	if result.Error != "" {
+		return result, errors.New(result.Error)
+	}
+	return result, nil
+}
+
+func (s *Server) handleMigrate(w http.ResponseWriter, r *http.Request) {
+	migrator, ok := s.backend.(Migrator)
+	if !ok {
+		s.writeJSON(w, http.StatusNotFound, Response{Error: "migration is not available"})
+		return
+	}
+	agent, err := hcnpkg.ParseForeignAgent(r.PathValue("agent"))
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+	mode := hcnpkg.MigrationReport
+	if value := r.URL.Query().Get("mode"); value != "" {
+		if mode, err = hcnpkg.ParseMigrationMode(value); err != nil {
+			s.writeJSON(w, http.StatusBadRequest, Response{Error: err.Error()})
+			return
+		}
+	}
+
+	acls, err := migrator.Migrate(agent, mode)
+	result := MigrationResult{ACLs: acls}
+	if err != nil {
+		s.logger.Error(err, "Failed to migrate ACLs", "agent", agent, "mode", mode)
+		if len(acls) == 0 {
+			s.writeJSON(w, errorStatus(err), Response{Error: err.Error()})
+			return
+		}
+		result.Error = err.Error()
+	}
+	s.writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,51 @@
+//go:build windows
+
+package admin
+
+import (
+	"context"
+	"net/http"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// NetworkInspector is the part of the HCN Manager that reports the policies of
+// the node's HNS networks. Backends without it answer GET /v1/networks with 404.
+type NetworkInspector interface {
+	// ListNetworkPolicies returns the policies of every HNS network
+	ListNetworkPolicies() ([]hcnpkg.NetworkPolicies, error)
+
+	// RemoteSubnetConflicts returns the desired Block rules covering the provider address of a remote subnet route
+	RemoteSubnetConflicts() []hcnpkg.RemoteSubnetConflict
+}
+
+// NetworkList is the body of GET /v1/networks
+type NetworkList struct {
+	Networks []hcnpkg.NetworkPolicies `json:"networks"`
+
+	// Conflicts are the Block rules covering the provider address of a remote subnet route
+	Conflicts []hcnpkg.RemoteSubnetConflict `json:"conflicts,omitempty"`
+}
+
+// ListNetworks returns the policies of the node's HNS networks and the rules
+// conflicting with their remote subnet routes
+func (c *Client) ListNetworks(ctx context.Context) (NetworkList, error) {
+	var list NetworkList
+	err := c.do(ctx, http.MethodGet, "/v1/networks", &list)
+	return list, err
+}
+
+func (s *Server) handleListNetworks(w http.ResponseWriter, _ *http.Request) {
+	inspector, ok := s.backend.(NetworkInspector)
+	if !ok {
+		s.writeJSON(w, http.StatusNotFound, Response{Error: "network policies are not available"})
+		return
+	}
+	networks, err := inspector.ListNetworkPolicies()
+	if err != nil {
+		s.logger.Error(err, "Failed to list network policies")
+		s.writeJSON(w, errorStatus(err), Response{Error: err.Error()})
+		return
+	}
+	s.writeJSON(w, http.StatusOK, NetworkList{Networks: networks, Conflicts: inspector.RemoteSubnetConflicts()})
+}
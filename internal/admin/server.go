@@ -0,0 +1,236 @@
+//go:build windows
+
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+
+	"github.com/knabben/firewall-controller/internal/converter"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// Resyncer is the part of the HCN Manager driven by the admin API
+type Resyncer interface {
+	// ForceResyncEndpoint re-programs every policy on one endpoint
+	ForceResyncEndpoint(endpointID string) error
+
+	// ForceResyncPolicy re-programs one policy on every endpoint
+	ForceResyncPolicy(policyKey hcnpkg.PolicyKey) error
+}
+
+// Inspector is the read-only part of the HCN Manager exposed by the admin API
+type Inspector interface {
+	// Snapshot returns a read-consistent view of all tracked policies
+	Snapshot() hcnpkg.Snapshot
+}
+
+// Restorer is the part of the HCN Manager that restores endpoint backups
+type Restorer interface {
+	// ListBackups returns the stored backups of an endpoint, newest first
+	ListBackups(endpointID string) ([]hcnpkg.BackupInfo, error)
+
+	// RestoreEndpoint restores a backup (the newest when name is empty) and pins the endpoint
+	RestoreEndpoint(endpointID, name string) error
+}
+
+// Historian is the part of the HCN Manager that keeps rule table history
+type Historian interface {
+	// RuleHistory returns the rule table snapshots of an endpoint, newest first
+	RuleHistory(endpointID string) ([]hcnpkg.RuleTableSnapshot, error)
+}
+
+// Backend is everything the admin API needs from the HCN Manager
+type Backend interface {
+	Resyncer
+	Inspector
+	Restorer
+	Historian
+	Locator
+}
+
+// Response is the JSON body returned by every admin action
+type Response struct {
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// TokenHeader carries the admin token. It is not Authorization, which the API
+// server consumes when kubectl winfw goes through the node proxy.
+const TokenHeader = "X-Admin-Token"
+
+// Server exposes node-local operator actions over HTTP. It is meant to be
+// bound to localhost and reached with fwctl from the node, or with
+// kubectl winfw through port-forward or the node proxy.
+type Server struct {
+	addr    string
+	backend Backend
+	logger  logr.Logger
+
+	// token must be sent in TokenHeader by every request; empty accepts any request
+	token string
+
+	// enforcement computes the enforcement matrix; nil until set
+	enforcement func() converter.EnforcementMatrix
+
+	// faults simulates failures for game days; nil unless fault injection is enabled
+	faults *hcnpkg.FaultInjector
+}
+
+// NewServer creates an admin server listening on addr
+func NewServer(addr string, backend Backend, logger logr.Logger) *Server {
+	return &Server{
+		addr:    addr,
+		backend: backend,
+		logger:  logger,
+	}
+}
+
+// SetToken makes every request carry token in TokenHeader, answering 401
+// otherwise. Any process on the node can reach the admin API, and its actions
+// rewrite ACLs, so the agent refuses to serve it without a token.
+func (s *Server) SetToken(token string) {
+	s.token = token
+}
+
+// ReadTokenFile reads an admin token, ignoring surrounding whitespace
+func ReadTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read admin token: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("admin token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// Handler returns the admin API routes
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/resync/endpoints/{id}", s.handleResyncEndpoint)
+	mux.HandleFunc("POST /v1/resync/policies/{key...}", s.handleResyncPolicy)
+	mux.HandleFunc("GET /v1/policies", s.handleListPolicies)
+	mux.HandleFunc("GET /v1/policies/{key...}", s.handleGetPolicy)
+	mux.HandleFunc("GET /v1/backups/endpoints/{id}", s.handleListBackups)
+	mux.HandleFunc("POST /v1/restore/endpoints/{id}", s.handleRestoreEndpoint)
+	mux.HandleFunc("GET /v1/history/endpoints/{id}", s.handleRuleHistory)
+	mux.HandleFunc("GET /v1/endpoints/by-ip/{ip}", s.handleEndpointByIP)
+	mux.HandleFunc("GET /v1/enforcement", s.handleEnforcement)
+	mux.HandleFunc("GET /v1/networks", s.handleListNetworks)
+	mux.HandleFunc("GET /v1/dry-run", s.handleDryRun)
+	mux.HandleFunc("POST /v1/migrate/{agent}", s.handleMigrate)
+	mux.HandleFunc("GET /v1/faults", s.handleListFaults)
+	mux.HandleFunc("POST /v1/faults/{kind}", s.handleInjectFault)
+	mux.HandleFunc("DELETE /v1/faults", s.handleClearFault)
+	mux.HandleFunc("DELETE /v1/faults/{kind}", s.handleClearFault)
+	if s.token == "" {
+		return mux
+	}
+	return s.authenticate(mux)
+}
+
+// authenticate rejects requests without the admin token
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(TokenHeader)), []byte(s.token)) != 1 {
+			s.writeJSON(w, http.StatusUnauthorized, Response{Error: "missing or invalid admin token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Start serves the admin API until the context is cancelled.
+// It implements the controller-runtime Runnable interface.
+func (s *Server) Start(ctx context.Context) error {
+	server := &http.Server{
+		Addr:              s.addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			s.logger.Error(err, "Failed to shut down admin server")
+		}
+	}()
+
+	s.logger.Info("Starting admin server", "address", s.addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// NeedLeaderElection implements LeaderElectionRunnable; every node serves its own admin API
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *Server) handleResyncEndpoint(w http.ResponseWriter, r *http.Request) {
+	endpointID := r.PathValue("id")
+	s.respond(w, "resynced", s.backend.ForceResyncEndpoint(endpointID), "endpointID", endpointID)
+}
+
+func (s *Server) handleResyncPolicy(w http.ResponseWriter, r *http.Request) {
+	policyKey, err := hcnpkg.ParsePolicyKey(r.PathValue("key"))
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+	s.respond(w, "resynced", s.backend.ForceResyncPolicy(policyKey), "policyKey", policyKey)
+}
+
+func (s *Server) handleRestoreEndpoint(w http.ResponseWriter, r *http.Request) {
+	endpointID, backup := r.PathValue("id"), r.URL.Query().Get("backup")
+	s.respond(w, "restored", s.backend.RestoreEndpoint(endpointID, backup), "endpointID", endpointID, "backup", backup)
+}
+
+// respond writes the outcome of an admin action as JSON
+func (s *Server) respond(w http.ResponseWriter, action string, err error, keysAndValues ...any) {
+	status := http.StatusOK
+	body := Response{Status: action}
+	if err != nil {
+		s.logger.Error(err, "Admin action failed", append([]any{"action", action}, keysAndValues...)...)
+		status = errorStatus(err)
+		body = Response{Error: err.Error()}
+	}
+
+	s.writeJSON(w, status, body)
+}
+
+// errorStatus maps a backend error to an HTTP status
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, hcnpkg.ErrPolicyNotFound), errors.Is(err, hcnpkg.ErrBackupNotFound), hcn.IsNotFoundError(err):
+		return http.StatusNotFound
+	case errors.Is(err, hcnpkg.ErrBackupsDisabled), errors.Is(err, hcnpkg.ErrHistoryDisabled):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeJSON writes body as a JSON response
+func (s *Server) writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		s.logger.Error(err, "Failed to write admin response")
+	}
+}
@@ -0,0 +1,452 @@
+//go:build windows
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+
+	"github.com/knabben/firewall-controller/internal/converter"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// mockResyncer records resync requests and serves tracked rule sets
+type mockResyncer struct {
+	endpoints  []string
+	policies   []string
+	tracked    map[string][]hcnpkg.RuleSet
+	syncErrors map[string]string
+	restored   []string
+	history    map[string][]hcnpkg.RuleTableSnapshot
+	byIP       map[string]hcn.HostComputeEndpoint
+}
+
+func (m *mockResyncer) ForceResyncEndpoint(endpointID string) error {
+	m.endpoints = append(m.endpoints, endpointID)
+	return nil
+}
+
+func (m *mockResyncer) ForceResyncPolicy(policyKey hcnpkg.PolicyKey) error {
+	if policyKey == "netpol/default/missing" {
+		return fmt.Errorf("%w: %s", hcnpkg.ErrPolicyNotFound, policyKey)
+	}
+	m.policies = append(m.policies, string(policyKey))
+	return nil
+}
+
+func (m *mockResyncer) Snapshot() hcnpkg.Snapshot {
+	return hcnpkg.NewSnapshot(m.tracked, m.syncErrors)
+}
+
+func (m *mockResyncer) ListBackups(endpointID string) ([]hcnpkg.BackupInfo, error) {
+	if endpointID != "ep-1" {
+		return nil, nil
+	}
+	return []hcnpkg.BackupInfo{{Name: "20250101T000000.000000000Z-replace", Reason: "replace"}}, nil
+}
+
+func (m *mockResyncer) RestoreEndpoint(endpointID, name string) error {
+	if name == "missing" {
+		return fmt.Errorf("%w: %s/%s", hcnpkg.ErrBackupNotFound, endpointID, name)
+	}
+	m.restored = append(m.restored, endpointID+"@"+name)
+	return nil
+}
+
+func (m *mockResyncer) RuleHistory(endpointID string) ([]hcnpkg.RuleTableSnapshot, error) {
+	if m.history == nil {
+		return nil, hcnpkg.ErrHistoryDisabled
+	}
+	return m.history[endpointID], nil
+}
+
+func TestServer_Resync(t *testing.T) {
+	resyncer := &mockResyncer{}
+	server := httptest.NewServer(NewServer("", resyncer, logr.Discard()).Handler())
+	defer server.Close()
+
+	client := NewClient(server.URL, server.Client())
+
+	if err := client.ResyncEndpoint(context.Background(), "ep-1"); err != nil {
+		t.Fatalf("ResyncEndpoint failed: %v", err)
+	}
+	if err := client.ResyncPolicy(context.Background(), "netpol/default/allow-http"); err != nil {
+		t.Fatalf("ResyncPolicy failed: %v", err)
+	}
+
+	if len(resyncer.endpoints) != 1 || resyncer.endpoints[0] != "ep-1" {
+		t.Errorf("Expected endpoint ep-1 to be resynced, got %v", resyncer.endpoints)
+	}
+	if len(resyncer.policies) != 1 || resyncer.policies[0] != "netpol/default/allow-http" {
+		t.Errorf("Expected policy netpol/default/allow-http to be resynced, got %v", resyncer.policies)
+	}
+
+	err := client.ResyncPolicy(context.Background(), "netpol/default/missing")
+	if err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Errorf("Expected HTTP 404 for unknown policy, got %v", err)
+	}
+	err = client.ResyncPolicy(context.Background(), "default/allow-http")
+	if err == nil || !strings.Contains(err.Error(), "HTTP 400") {
+		t.Errorf("Expected HTTP 400 for a key without its source kind, got %v", err)
+	}
+}
+
+func TestServer_Token(t *testing.T) {
+	resyncer := &mockResyncer{}
+	adminServer := NewServer("", resyncer, logr.Discard())
+	adminServer.SetToken("s3cret")
+	server := httptest.NewServer(adminServer.Handler())
+	defer server.Close()
+
+	client := NewClient(server.URL, server.Client())
+	err := client.ResyncEndpoint(context.Background(), "ep-1")
+	if err == nil || !strings.Contains(err.Error(), "HTTP 401") {
+		t.Errorf("Expected HTTP 401 without a token, got %v", err)
+	}
+	client.SetToken("wrong")
+	err = client.ResyncEndpoint(context.Background(), "ep-1")
+	if err == nil || !strings.Contains(err.Error(), "HTTP 401") {
+		t.Errorf("Expected HTTP 401 for a wrong token, got %v", err)
+	}
+	if len(resyncer.endpoints) != 0 {
+		t.Fatalf("Expected no resync without the token, got %v", resyncer.endpoints)
+	}
+
+	client.SetToken("s3cret")
+	if err := client.ResyncEndpoint(context.Background(), "ep-1"); err != nil {
+		t.Fatalf("ResyncEndpoint with the token failed: %v", err)
+	}
+}
+
+func (m *mockResyncer) EndpointByIP(ip string) (hcn.HostComputeEndpoint, bool) {
+	endpoint, exists := m.byIP[ip]
+	return endpoint, exists
+}
+
+func TestServer_InspectPolicy(t *testing.T) {
+	resyncer := &mockResyncer{tracked: map[string][]hcnpkg.RuleSet{
+		"default/allow-http": {
+			{EndpointID: "ep-2", Rules: []hcnpkg.ACLRule{{Name: "b", Priority: 101}}},
+			{EndpointID: "ep-1", Rules: []hcnpkg.ACLRule{{
+				Name:     "a",
+				Priority: 100,
+				Labels:   map[string]string{"team": "payments", "ticket": "NET-1"},
+			}}},
+		},
+		"audit/deny-all": nil,
+	}}
+	server := httptest.NewServer(NewServer("", resyncer, logr.Discard()).Handler())
+	defer server.Close()
+
+	client := NewClient(server.URL, server.Client())
+
+	list, err := client.ListPolicies(context.Background(), ListOptions{})
+	if err != nil {
+		t.Fatalf("ListPolicies failed: %v", err)
+	}
+	if len(list.Items) != 2 || list.Items[0].Key != "audit/deny-all" || list.Items[1].Key != "default/allow-http" {
+		t.Errorf("Expected sorted policies, got %+v", list.Items)
+	}
+
+	rules, err := client.GetPolicy(context.Background(), "default/allow-http", ListOptions{})
+	if err != nil {
+		t.Fatalf("GetPolicy failed: %v", err)
+	}
+	if len(rules.Endpoints) != 2 || rules.Endpoints[0].EndpointID != "ep-1" {
+		t.Fatalf("Expected endpoints sorted by ID, got %+v", rules.Endpoints)
+	}
+	if labels := rules.Endpoints[0].Rules[0].Labels; labels["team"] != "payments" || labels["ticket"] != "NET-1" {
+		t.Errorf("Expected rule labels to be returned, got %v", labels)
+	}
+
+	_, err = client.GetPolicy(context.Background(), "default/missing", ListOptions{})
+	if err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Errorf("Expected HTTP 404 for unknown policy, got %v", err)
+	}
+}
+
+func TestServer_ListPoliciesPagination(t *testing.T) {
+	resyncer := &mockResyncer{
+		tracked:    map[string][]hcnpkg.RuleSet{"default/healthy": {{EndpointID: "ep-1"}}},
+		syncErrors: map[string]string{},
+	}
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("default/policy-%d", i)
+		resyncer.tracked[key] = []hcnpkg.RuleSet{{EndpointID: "ep-1"}}
+		resyncer.syncErrors[key] = "apply failed"
+	}
+	server := httptest.NewServer(NewServer("", resyncer, logr.Discard()).Handler())
+	defer server.Close()
+
+	client := NewClient(server.URL, server.Client())
+	opts := ListOptions{Namespace: "default", EndpointID: "ep-1", Status: "failed", Limit: 2}
+
+	var keys []string
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("Expected 3 pages")
+		}
+		list, err := client.ListPolicies(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("ListPolicies failed: %v", err)
+		}
+		for _, item := range list.Items {
+			keys = append(keys, item.Key)
+		}
+		if list.Continue == "" {
+			break
+		}
+		opts.Continue = list.Continue
+	}
+
+	if strings.Join(keys, ",") != "default/policy-0,default/policy-1,default/policy-2,default/policy-3,default/policy-4" {
+		t.Errorf("Expected every policy exactly once, got %v", keys)
+	}
+
+	for _, invalid := range []ListOptions{{Status: "pending"}, {Limit: MaxPageSize + 1}, {Continue: "%%%"}} {
+		if _, err := client.ListPolicies(context.Background(), invalid); err == nil || !strings.Contains(err.Error(), "HTTP 400") {
+			t.Errorf("Expected HTTP 400 for %+v, got %v", invalid, err)
+		}
+	}
+}
+
+func TestServer_GetPolicyFilterEndpoint(t *testing.T) {
+	resyncer := &mockResyncer{tracked: map[string][]hcnpkg.RuleSet{
+		"default/web": {{EndpointID: "ep-1"}, {EndpointID: "ep-2"}, {EndpointID: "ep-3"}},
+	}}
+	server := httptest.NewServer(NewServer("", resyncer, logr.Discard()).Handler())
+	defer server.Close()
+
+	client := NewClient(server.URL, server.Client())
+
+	rules, err := client.GetPolicy(context.Background(), "default/web", ListOptions{EndpointID: "ep-2"})
+	if err != nil {
+		t.Fatalf("GetPolicy failed: %v", err)
+	}
+	if len(rules.Endpoints) != 1 || rules.Endpoints[0].EndpointID != "ep-2" {
+		t.Errorf("Expected only ep-2, got %+v", rules.Endpoints)
+	}
+
+	rules, err = client.GetPolicy(context.Background(), "default/web", ListOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("GetPolicy failed: %v", err)
+	}
+	if len(rules.Endpoints) != 2 || rules.Continue == "" {
+		t.Fatalf("Expected a first page of 2 endpoints, got %+v", rules)
+	}
+	rules, err = client.GetPolicy(context.Background(), "default/web", ListOptions{Limit: 2, Continue: rules.Continue})
+	if err != nil {
+		t.Fatalf("GetPolicy failed: %v", err)
+	}
+	if len(rules.Endpoints) != 1 || rules.Endpoints[0].EndpointID != "ep-3" || rules.Continue != "" {
+		t.Errorf("Expected a last page with ep-3, got %+v", rules)
+	}
+}
+
+func TestServer_Restore(t *testing.T) {
+	resyncer := &mockResyncer{}
+	server := httptest.NewServer(NewServer("", resyncer, logr.Discard()).Handler())
+	defer server.Close()
+
+	client := NewClient(server.URL, server.Client())
+
+	backups, err := client.ListBackups(context.Background(), "ep-1")
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+	if len(backups.Items) != 1 || backups.Items[0].Reason != "replace" {
+		t.Errorf("Expected one replace backup, got %+v", backups.Items)
+	}
+
+	if err := client.RestoreEndpoint(context.Background(), "ep-1", ""); err != nil {
+		t.Fatalf("RestoreEndpoint failed: %v", err)
+	}
+	if err := client.RestoreEndpoint(context.Background(), "ep-1", "20250101T000000.000000000Z-replace"); err != nil {
+		t.Fatalf("RestoreEndpoint failed: %v", err)
+	}
+	if len(resyncer.restored) != 2 || resyncer.restored[0] != "ep-1@" || resyncer.restored[1] != "ep-1@20250101T000000.000000000Z-replace" {
+		t.Errorf("Expected the newest and a named backup to be restored, got %v", resyncer.restored)
+	}
+
+	err = client.RestoreEndpoint(context.Background(), "ep-1", "missing")
+	if err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Errorf("Expected HTTP 404 for an unknown backup, got %v", err)
+	}
+}
+
+func TestServer_RuleHistory(t *testing.T) {
+	resyncer := &mockResyncer{history: map[string][]hcnpkg.RuleTableSnapshot{
+		"ep-1": {
+			{Cause: "default/allow-http", Policies: map[string][]hcnpkg.ACLRule{
+				"default/allow-http": {{Name: "a", Priority: 100}},
+			}},
+			{Cause: "default/allow-http", Policies: map[string][]hcnpkg.ACLRule{}},
+		},
+	}}
+	server := httptest.NewServer(NewServer("", resyncer, logr.Discard()).Handler())
+	defer server.Close()
+
+	client := NewClient(server.URL, server.Client())
+
+	history, err := client.RuleHistory(context.Background(), "ep-1")
+	if err != nil {
+		t.Fatalf("RuleHistory failed: %v", err)
+	}
+	if len(history.Items) != 2 || history.Items[0].Policies["default/allow-http"][0].Priority != 100 {
+		t.Errorf("Expected 2 snapshots newest first, got %+v", history.Items)
+	}
+
+	resyncer.history = nil
+	_, err = client.RuleHistory(context.Background(), "ep-1")
+	if err == nil || !strings.Contains(err.Error(), "HTTP 409") {
+		t.Errorf("Expected HTTP 409 with history disabled, got %v", err)
+	}
+}
+
+func TestServer_EndpointByIP(t *testing.T) {
+	endpoint := hcn.HostComputeEndpoint{
+		Id:               "ep-1",
+		MacAddress:       "00-15-5d-00-00-01",
+		IpConfigurations: []hcn.IpConfig{{IpAddress: "10.0.0.5"}},
+	}
+	resyncer := &mockResyncer{
+		tracked: map[string][]hcnpkg.RuleSet{
+			"default/allow-http": {
+				{EndpointID: "ep-1", Rules: []hcnpkg.ACLRule{{Name: "a", Priority: 100}}},
+				{EndpointID: "ep-2", Rules: []hcnpkg.ACLRule{{Name: "b", Priority: 101}}},
+			},
+			"default/deny-all": {{EndpointID: "ep-1", Rules: []hcnpkg.ACLRule{{Name: "c", Priority: 200}}}},
+			"other/allow-dns":  {{EndpointID: "ep-2", Rules: []hcnpkg.ACLRule{{Name: "d", Priority: 300}}}},
+		},
+		syncErrors: map[string]string{"default/deny-all": "endpoint ep-1: access denied"},
+		byIP:       map[string]hcn.HostComputeEndpoint{"10.0.0.5": endpoint},
+	}
+	server := httptest.NewServer(NewServer("", resyncer, logr.Discard()).Handler())
+	defer server.Close()
+
+	client := NewClient(server.URL, server.Client())
+	state, err := client.EndpointStateByIP(context.Background(), "10.0.0.5")
+	if err != nil {
+		t.Fatalf("EndpointStateByIP failed: %v", err)
+	}
+	if state.APIVersion != StateAPIVersion || state.Kind != EndpointStateKind || state.Name != "ep-1" {
+		t.Errorf("Expected an EndpointState object named ep-1, got %+v", state.TypeMeta)
+	}
+	policies := state.Status.Policies
+	if len(policies) != 2 || policies[0].Key != "default/allow-http" || policies[1].Key != "default/deny-all" {
+		t.Fatalf("Expected the two policies on ep-1 sorted by key, got %+v", policies)
+	}
+	if len(policies[0].Rules) != 1 || policies[0].Rules[0].Name != "a" {
+		t.Errorf("Expected only the rules of ep-1, got %+v", policies[0].Rules)
+	}
+	if policies[1].Status != string(hcnpkg.PolicyStatusFailed) || policies[1].Error == "" {
+		t.Errorf("Expected the failed sync to be reported, got %+v", policies[1])
+	}
+
+	_, err = client.EndpointStateByIP(context.Background(), "10.0.0.6")
+	if err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Errorf("Expected HTTP 404 for an unknown IP, got %v", err)
+	}
+}
+
+func TestServer_Enforcement(t *testing.T) {
+	adminServer := NewServer("", &mockResyncer{}, logr.Discard())
+	server := httptest.NewServer(adminServer.Handler())
+	defer server.Close()
+	client := NewClient(server.URL, server.Client())
+
+	if _, err := client.EnforcementMatrix(context.Background()); err == nil {
+		t.Error("Expected an error before the matrix is set")
+	}
+
+	adminServer.SetEnforcementMatrix(func() converter.EnforcementMatrix {
+		return converter.BuildEnforcementMatrix(converter.DefaultConversionOptions(), converter.ProbeEnvironment{})
+	})
+	matrix, err := client.EnforcementMatrix(context.Background())
+	if err != nil {
+		t.Fatalf("EnforcementMatrix failed: %v", err)
+	}
+	if len(matrix.Fields) == 0 || matrix.Fields[0].Level == "" {
+		t.Errorf("Expected the fields with their enforcement level, got %+v", matrix)
+	}
+}
+
+func TestServer_Faults(t *testing.T) {
+	adminServer := NewServer("", &mockResyncer{}, logr.Discard())
+	server := httptest.NewServer(adminServer.Handler())
+	defer server.Close()
+	client := NewClient(server.URL, server.Client())
+
+	_, err := client.Faults(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Errorf("Expected HTTP 404 with fault injection disabled, got %v", err)
+	}
+
+	adminServer.SetFaultInjector(hcnpkg.NewFaultInjector())
+	fault, err := client.InjectFault(context.Background(), hcnpkg.FaultReconcileDelay, time.Minute, 2*time.Second)
+	if err != nil {
+		t.Fatalf("InjectFault failed: %v", err)
+	}
+	if fault.Kind != hcnpkg.FaultReconcileDelay || fault.Delay != 2*time.Second {
+		t.Errorf("Expected a 2s reconcile delay, got %+v", fault)
+	}
+	_, err = client.InjectFault(context.Background(), hcnpkg.FaultHNSOutage, 2*hcnpkg.MaxFaultDuration, 0)
+	if err == nil || !strings.Contains(err.Error(), "HTTP 400") {
+		t.Errorf("Expected HTTP 400 for a fault outlasting the maximum, got %v", err)
+	}
+
+	list, err := client.Faults(context.Background())
+	if err != nil {
+		t.Fatalf("Faults failed: %v", err)
+	}
+	if len(list.Items) != 1 {
+		t.Errorf("Expected one active fault, got %+v", list.Items)
+	}
+	if err := client.ClearFault(context.Background(), ""); err != nil {
+		t.Fatalf("ClearFault failed: %v", err)
+	}
+	if list, _ := client.Faults(context.Background()); len(list.Items) != 0 {
+		t.Errorf("Expected no faults after clearing, got %+v", list.Items)
+	}
+}
+
+// dryRunBackend is a mockResyncer in dry-run mode
+type dryRunBackend struct {
+	*mockResyncer
+	changes []hcnpkg.DryRunChange
+}
+
+func (b *dryRunBackend) DryRunChanges() []hcnpkg.DryRunChange {
+	return b.changes
+}
+
+func TestServer_DryRun(t *testing.T) {
+	backend := &dryRunBackend{mockResyncer: &mockResyncer{}, changes: []hcnpkg.DryRunChange{
+		{Operation: "add", EndpointID: "ep-1", Settings: []json.RawMessage{json.RawMessage(`{"Priority":100}`)}},
+	}}
+	server := httptest.NewServer(NewServer("", backend, logr.Discard()).Handler())
+	defer server.Close()
+
+	client := NewClient(server.URL, server.Client())
+	list, err := client.DryRunChanges(context.Background())
+	if err != nil {
+		t.Fatalf("DryRunChanges failed: %v", err)
+	}
+	if len(list.Changes) != 1 || list.Changes[0].EndpointID != "ep-1" || string(list.Changes[0].Settings[0]) != `{"Priority":100}` {
+		t.Errorf("Expected the recorded change, got %+v", list.Changes)
+	}
+
+	// Agents not in dry-run mode answer 404
+	backend.changes = nil
+	if _, err := client.DryRunChanges(context.Background()); err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Errorf("Expected HTTP 404 outside dry-run mode, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+//go:build windows
+
+package admin
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+const (
+	// StateAPIVersion is the apiVersion of the objects the admin API exports
+	StateAPIVersion = "admin.networking.knabben.github.io/v1alpha1"
+
+	// EndpointStateKind is the kind of EndpointState objects
+	EndpointStateKind = "EndpointState"
+)
+
+// Locator is the part of the HCN Manager that finds endpoints by address
+type Locator interface {
+	// EndpointByIP returns the endpoint owning ip as of the last endpoint listing
+	EndpointByIP(ip string) (hcn.HostComputeEndpoint, bool)
+}
+
+// EndpointState is the body of GET /v1/endpoints/by-ip/{ip}: everything the
+// agent programmed on one endpoint. It is shaped like a Kubernetes API object
+// so kubectl plugins can print it with the usual -o yaml and -o json.
+type EndpointState struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Status EndpointStateStatus `json:"status"`
+}
+
+// EndpointStateStatus is the observed state of an endpoint
+type EndpointStateStatus struct {
+	EndpointID  string   `json:"endpointID"`
+	IPAddresses []string `json:"ipAddresses,omitempty"`
+	MACAddress  string   `json:"macAddress,omitempty"`
+
+	// Policies are the policies programmed on the endpoint, sorted by key
+	Policies []EndpointPolicy `json:"policies"`
+}
+
+// EndpointPolicy is one policy programmed on an endpoint
+type EndpointPolicy struct {
+	Key    string `json:"key"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Rules  []Rule `json:"rules"`
+}
+
+// EndpointStateByIP returns the state of the endpoint owning ip
+func (c *Client) EndpointStateByIP(ctx context.Context, ip string) (EndpointState, error) {
+	var state EndpointState
+	err := c.do(ctx, http.MethodGet, "/v1/endpoints/by-ip/"+url.PathEscape(ip), &state)
+	return state, err
+}
+
+func (s *Server) handleEndpointByIP(w http.ResponseWriter, r *http.Request) {
+	ip := r.PathValue("ip")
+	endpoint, found := s.backend.EndpointByIP(ip)
+	if !found {
+		s.writeJSON(w, http.StatusNotFound, Response{Error: "no endpoint owns " + ip})
+		return
+	}
+	s.writeJSON(w, http.StatusOK, endpointState(endpoint, s.backend.Snapshot()))
+}
+
+// endpointState collects the policies tracked on endpoint from snapshot
+func endpointState(endpoint hcn.HostComputeEndpoint, snapshot hcnpkg.Snapshot) EndpointState {
+	status := EndpointStateStatus{
+		EndpointID: endpoint.Id,
+		MACAddress: endpoint.MacAddress,
+		Policies:   []EndpointPolicy{},
+	}
+	for _, ipConfig := range endpoint.IpConfigurations {
+		if ipConfig.IpAddress != "" {
+			status.IPAddresses = append(status.IPAddresses, ipConfig.IpAddress)
+		}
+	}
+	sort.Strings(status.IPAddresses)
+
+	// ListPolicies is sorted by key, and so are the policies
+	for _, summary := range snapshot.ListPolicies(hcnpkg.PolicyFilter{EndpointID: endpoint.Id}) {
+		policy := EndpointPolicy{Key: summary.Key, Status: string(summary.Status), Error: summary.Error, Rules: []Rule{}}
+		ruleSets, _ := snapshot.GetAppliedPolicies(summary.Key)
+		for _, ruleSet := range ruleSets {
+			if ruleSet.EndpointID == endpoint.Id {
+				policy.Rules = jsonRules(ruleSet.Rules)
+			}
+		}
+		status.Policies = append(status.Policies, policy)
+	}
+
+	return EndpointState{
+		TypeMeta:   metav1.TypeMeta{APIVersion: StateAPIVersion, Kind: EndpointStateKind},
+		ObjectMeta: metav1.ObjectMeta{Name: endpoint.Id},
+		Status:     status,
+	}
+}
@@ -0,0 +1,302 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/knabben/firewall-controller/internal/converter"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// adminNetworkPoliciesRequest is the request every AdminNetworkPolicy, pod and
+// namespace change maps to. The policies selecting a pod are flattened
+// together, since a Pass rule of one skips the rules of those after it.
+var adminNetworkPoliciesRequest = reconcile.Request{NamespacedName: types.NamespacedName{Name: "adminnetworkpolicies"}}
+
+// AdminNetworkPolicyReconciler programs the sig-network AdminNetworkPolicies
+// selecting the pods of this node in the admin tier, a band of priorities ahead
+// of every NetworkPolicy. The pods selected by the same set of policies share
+// one policy key, named after the policies.
+type AdminNetworkPolicyReconciler struct {
+	client.Client
+	HCNManager hcnpkg.HCNManager
+
+	// Rules holds the compiled admin tier; it must be registered with the HCN Manager
+	Rules *hcnpkg.TargetedProvider
+
+	// NodeName limits the subjects to the pods of this node; empty takes every pod
+	NodeName string
+
+	// Tier is the priority band of the admin tier
+	Tier converter.AdminTierOptions
+
+	// CacheSync holds reconciles back until the informers have synced; nil does not wait
+	CacheSync *CacheSyncGate
+
+	// Retry tunes the backoff of failed reconciles; unset uses DefaultRetryOptions
+	Retry RetryOptions
+}
+
+// adminSubjectGroup is the pods selected by the same AdminNetworkPolicies
+type adminSubjectGroup struct {
+	policies []converter.AdminPolicy
+	ips      []string
+}
+
+// Reconcile compiles every AdminNetworkPolicy against the current pods and
+// namespaces and reconciles the HCN endpoints toward the result
+func (r *AdminNetworkPolicyReconciler) Reconcile(ctx context.Context, _ ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	// Pods listed from a partially synced cache would leave endpoints uncovered
+	if err := r.CacheSync.Wait(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	anps, err := r.listAdminNetworkPolicies(ctx)
+	if err != nil {
+		logger.Error(err, "Failed to list AdminNetworkPolicies")
+		return ctrl.Result{}, err
+	}
+	var namespaces corev1.NamespaceList
+	if err := r.List(ctx, &namespaces, client.UnsafeDisableDeepCopy); err != nil {
+		logger.Error(err, "Failed to list namespaces")
+		return ctrl.Result{}, err
+	}
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.UnsafeDisableDeepCopy); err != nil {
+		logger.Error(err, "Failed to list pods")
+		return ctrl.Result{}, err
+	}
+	namespaceLabels := make(map[string]labels.Set, len(namespaces.Items))
+	for _, ns := range namespaces.Items {
+		namespaceLabels[ns.Name] = ns.Labels
+	}
+	selector := adminSelector{namespaces: namespaceLabels, pods: pods.Items}
+
+	podGroups := make(map[string]*adminSubjectGroup)
+	var convertErrors []error
+	for _, anp := range anps {
+		policy, warnings, err := converter.AdminNetworkPolicyToAdminPolicy(anp, selector.resolvePeer)
+		if err != nil {
+			convertErrors = append(convertErrors, err)
+			continue
+		}
+		for _, warning := range warnings {
+			logger.Info("WARNING: AdminNetworkPolicy not enforced as written",
+				"adminNetworkPolicy", anp.Name,
+				"warning", warning.String())
+		}
+
+		subjects, err := selector.subjects(anp.Spec.Subject, r.NodeName)
+		if err != nil {
+			convertErrors = append(convertErrors, fmt.Errorf("admin policy %s subject: %w", anp.Name, err))
+			continue
+		}
+		for _, pod := range subjects {
+			// Policies are visited by name, so every pod of a group lists them alike
+			groupPod(podGroups, pod, policy)
+		}
+	}
+	groups := regroup(podGroups)
+
+	live := make(map[hcnpkg.PolicyKey]bool, len(groups))
+	for key, group := range groups {
+		live[key] = true
+		rules, err := converter.AdminPoliciesToACLRules(group.policies, r.Tier)
+		if err != nil {
+			// The tier programmed before stays until the policies can be flattened
+			convertErrors = append(convertErrors, err)
+			continue
+		}
+		r.Rules.Set(key, group.ips, rules)
+	}
+	for _, key := range r.Rules.Keys() {
+		if !live[key] {
+			r.Rules.Delete(key)
+		}
+	}
+	logger.Info("Compiled AdminNetworkPolicies",
+		"policyCount", len(anps),
+		"subjectGroups", len(groups))
+
+	if err := r.HCNManager.Reconcile(); err != nil {
+		logger.Error(err, "Failed to reconcile HCN ACL rules for AdminNetworkPolicies")
+		return ctrl.Result{}, err
+	}
+	if len(convertErrors) > 0 {
+		err := errors.Join(convertErrors...)
+		logger.Error(err, "Failed to convert AdminNetworkPolicies")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// groupPod adds the addresses of pod to its entry, keyed by its pod name
+// until regroup keys the entries by the policies selecting them
+func groupPod(groups map[string]*adminSubjectGroup, pod *corev1.Pod, policy converter.AdminPolicy) {
+	name := pod.Namespace + "/" + pod.Name
+	group, found := groups[name]
+	if !found {
+		group = &adminSubjectGroup{}
+		for _, podIP := range pod.Status.PodIPs {
+			group.ips = append(group.ips, podIP.IP)
+		}
+		groups[name] = group
+	}
+	group.policies = append(group.policies, policy)
+}
+
+// regroup merges the per-pod entries selected by the same policies into one,
+// keyed by the policy names
+func regroup(pods map[string]*adminSubjectGroup) map[hcnpkg.PolicyKey]*adminSubjectGroup {
+	groups := make(map[hcnpkg.PolicyKey]*adminSubjectGroup)
+	for _, pod := range pods {
+		names := make([]string, len(pod.policies))
+		for i, policy := range pod.policies {
+			names[i] = policy.Name
+		}
+		key := hcnpkg.NewPolicyKey(hcnpkg.SourceAdminNetworkPolicy, strings.Join(names, ","))
+		group, found := groups[key]
+		if !found {
+			group = &adminSubjectGroup{policies: pod.policies}
+			groups[key] = group
+		}
+		group.ips = append(group.ips, pod.ips...)
+	}
+	for _, group := range groups {
+		sort.Strings(group.ips)
+	}
+	return groups
+}
+
+// listAdminNetworkPolicies returns the AdminNetworkPolicies of the cluster, sorted by name
+func (r *AdminNetworkPolicyReconciler) listAdminNetworkPolicies(ctx context.Context) ([]*converter.AdminNetworkPolicy, error) {
+	var list unstructured.UnstructuredList
+	list.SetGroupVersionKind(converter.AdminNetworkPolicyGVK.GroupVersion().WithKind(converter.AdminNetworkPolicyGVK.Kind + "List"))
+	if err := r.List(ctx, &list); err != nil {
+		return nil, err
+	}
+
+	anps := make([]*converter.AdminNetworkPolicy, 0, len(list.Items))
+	for i := range list.Items {
+		anp, err := converter.AdminNetworkPolicyFromUnstructured(&list.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		anps = append(anps, anp)
+	}
+	sort.Slice(anps, func(i, j int) bool { return anps[i].Name < anps[j].Name })
+	return anps, nil
+}
+
+// adminSelector matches AdminNetworkPolicy selectors against one listing of
+// the cluster's namespaces and pods
+type adminSelector struct {
+	namespaces map[string]labels.Set
+	pods       []corev1.Pod
+}
+
+// subjects returns the running, non-host-network pods of nodeName subject selects
+func (s adminSelector) subjects(subject converter.AdminNetworkPolicySubject, nodeName string) ([]*corev1.Pod, error) {
+	pods, err := s.selectPods(subject.Namespaces, subject.Pods)
+	if err != nil {
+		return nil, err
+	}
+	local := pods[:0]
+	for _, pod := range pods {
+		if nodeName == "" || pod.Spec.NodeName == nodeName {
+			local = append(local, pod)
+		}
+	}
+	return local, nil
+}
+
+// resolvePeer implements converter.AdminPeerResolver for namespaces and pods peers
+func (s adminSelector) resolvePeer(peer converter.AdminNetworkPolicyPeer) ([]string, bool, error) {
+	if peer.Namespaces == nil && peer.Pods == nil {
+		return nil, false, nil
+	}
+	pods, err := s.selectPods(peer.Namespaces, peer.Pods)
+	if err != nil {
+		return nil, true, err
+	}
+	var ips []string
+	for _, pod := range pods {
+		for _, podIP := range pod.Status.PodIPs {
+			ips = append(ips, podIP.IP)
+		}
+	}
+	return ips, true, nil
+}
+
+// selectPods returns the running, non-host-network pods of the namespaces
+// selected by namespaces, or those selected by pods
+func (s adminSelector) selectPods(namespaces *metav1.LabelSelector, pods *converter.NamespacedPod) ([]*corev1.Pod, error) {
+	var namespaceSelector, podSelector labels.Selector
+	var err error
+	switch {
+	case namespaces != nil:
+		if namespaceSelector, err = metav1.LabelSelectorAsSelector(namespaces); err != nil {
+			return nil, err
+		}
+		podSelector = labels.Everything()
+	case pods != nil:
+		if namespaceSelector, err = metav1.LabelSelectorAsSelector(&pods.NamespaceSelector); err != nil {
+			return nil, err
+		}
+		if podSelector, err = metav1.LabelSelectorAsSelector(&pods.PodSelector); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+
+	var selected []*corev1.Pod
+	for i := range s.pods {
+		pod := &s.pods[i]
+		if pod.Spec.HostNetwork || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		nsLabels, found := s.namespaces[pod.Namespace]
+		if !found || !namespaceSelector.Matches(nsLabels) || !podSelector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		selected = append(selected, pod)
+	}
+	return selected, nil
+}
+
+// SetupWithManager sets up the controller with the Manager. AdminNetworkPolicy,
+// pod and namespace changes all recompile the admin tier.
+func (r *AdminNetworkPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	anp := &unstructured.Unstructured{}
+	anp.SetGroupVersionKind(converter.AdminNetworkPolicyGVK)
+	all := handler.EnqueueRequestsFromMapFunc(func(context.Context, client.Object) []reconcile.Request {
+		return []reconcile.Request{adminNetworkPoliciesRequest}
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("adminnetworkpolicy").
+		Watches(anp, all).
+		Watches(&corev1.Pod{}, all).
+		Watches(&corev1.Namespace{}, all).
+		WithOptions(crcontroller.Options{RateLimiter: r.Retry.RateLimiter()}).
+		Complete(r)
+}
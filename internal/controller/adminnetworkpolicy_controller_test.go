@@ -0,0 +1,135 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/knabben/firewall-controller/internal/converter"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// adminNetworkPolicy builds an unstructured AdminNetworkPolicy
+func adminNetworkPolicy(name string, spec map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	obj.SetGroupVersionKind(converter.AdminNetworkPolicyGVK)
+	obj.SetName(name)
+	return obj
+}
+
+func TestReconcile_AdminNetworkPolicy(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	gv := converter.AdminNetworkPolicyGVK.GroupVersion()
+	scheme.AddKnownTypeWithName(converter.AdminNetworkPolicyGVK, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(gv.WithKind(converter.AdminNetworkPolicyGVK.Kind+"List"), &unstructured.UnstructuredList{})
+
+	pod := func(namespace, name, node, ip string, podLabels map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: podLabels},
+			Spec:       corev1.PodSpec{NodeName: node},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIPs: []corev1.PodIP{{IP: ip}}},
+		}
+	}
+	baseline := adminNetworkPolicy("a-baseline", map[string]interface{}{
+		"priority": int64(10),
+		"subject": map[string]interface{}{"namespaces": map[string]interface{}{
+			"matchLabels": map[string]interface{}{"env": "prod"},
+		}},
+		"ingress": []interface{}{map[string]interface{}{
+			"action": "Allow",
+			"from": []interface{}{map[string]interface{}{"namespaces": map[string]interface{}{
+				"matchLabels": map[string]interface{}{"team": "monitoring"},
+			}}},
+		}},
+		"egress": []interface{}{map[string]interface{}{
+			"action": "Deny",
+			"to":     []interface{}{map[string]interface{}{"networks": []interface{}{"169.254.169.254/32"}}},
+		}},
+	})
+	api := adminNetworkPolicy("b-api", map[string]interface{}{
+		"priority": int64(20),
+		"subject": map[string]interface{}{"pods": map[string]interface{}{
+			"namespaceSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"env": "prod"}},
+			"podSelector":       map[string]interface{}{"matchLabels": map[string]interface{}{"app": "api"}},
+		}},
+		"ingress": []interface{}{map[string]interface{}{
+			"action": "Deny",
+			"from":   []interface{}{map[string]interface{}{"namespaces": map[string]interface{}{}}},
+		}},
+	})
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod", Labels: map[string]string{"env": "prod"}}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "monitoring", Labels: map[string]string{"team": "monitoring"}}},
+			pod("prod", "web", "node-a", "10.0.0.1", nil),
+			pod("prod", "api", "node-a", "10.0.0.2", map[string]string{"app": "api"}),
+			pod("prod", "batch", "node-b", "10.0.0.3", nil),
+			pod("monitoring", "prometheus", "node-b", "10.0.9.1", nil),
+			baseline, api,
+		).
+		Build()
+
+	mockHCN := newMockHCNManager()
+	rules := hcnpkg.NewTargetedProvider("adminnetworkpolicy")
+	reconciler := &AdminNetworkPolicyReconciler{
+		Client:     fakeClient,
+		HCNManager: mockHCN,
+		Rules:      rules,
+		NodeName:   "node-a",
+		Tier:       converter.AdminTierOptions{BasePriority: 70, MaxPriority: 99},
+	}
+	if _, err := reconciler.Reconcile(context.Background(), adminNetworkPoliciesRequest); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if mockHCN.reconcileCount != 1 {
+		t.Errorf("Expected 1 HCN reconciliation, got %d", mockHCN.reconcileCount)
+	}
+
+	keys := rules.Keys()
+	if len(keys) != 2 || keys[0] != "anp/a-baseline" || keys[1] != "anp/a-baseline,b-api" {
+		t.Fatalf("Expected a subject group per set of policies on node-a, got %v", keys)
+	}
+
+	apiEndpoint := hcnlib.HostComputeEndpoint{Id: "ep-api", IpConfigurations: []hcnlib.IpConfig{{IpAddress: "10.0.0.2"}}}
+	compiled := rules.DesiredRulesFor(apiEndpoint)["anp/a-baseline,b-api"]
+	if len(compiled) != 3 {
+		t.Fatalf("Expected 3 admin-tier rules on the api endpoint, got %d: %+v", len(compiled), compiled)
+	}
+	if compiled[0].Action != hcnlib.ActionTypeAllow || compiled[0].RemoteAddresses != "10.0.9.1" || compiled[0].Priority != 70 {
+		t.Errorf("Expected the monitoring allow first at priority 70, got %+v", compiled[0])
+	}
+	if compiled[2].Action != hcnlib.ActionTypeBlock || compiled[2].Priority != 72 ||
+		compiled[2].RemoteAddresses != "10.0.0.1,10.0.0.2,10.0.0.3,10.0.9.1" {
+		t.Errorf("Expected the lower-precedence deny last at priority 72, got %+v", compiled[2])
+	}
+
+	batchEndpoint := hcnlib.HostComputeEndpoint{Id: "ep-batch", IpConfigurations: []hcnlib.IpConfig{{IpAddress: "10.0.0.3"}}}
+	if table := rules.DesiredRulesFor(batchEndpoint); len(table) != 0 {
+		t.Errorf("Expected no rules for a pod of another node, got %v", table)
+	}
+
+	// Deleting a policy moves its subjects to the group of the remaining ones
+	if err := fakeClient.Delete(context.Background(), api); err != nil {
+		t.Fatalf("Failed to delete AdminNetworkPolicy: %v", err)
+	}
+	if _, err := reconciler.Reconcile(context.Background(), adminNetworkPoliciesRequest); err != nil {
+		t.Fatalf("Reconcile after delete failed: %v", err)
+	}
+	if keys := rules.Keys(); len(keys) != 1 || keys[0] != "anp/a-baseline" {
+		t.Errorf("Expected only the baseline group after delete, got %v", keys)
+	}
+	if compiled := rules.DesiredRulesFor(apiEndpoint)["anp/a-baseline"]; len(compiled) != 2 {
+		t.Errorf("Expected the baseline rules on the api endpoint, got %+v", compiled)
+	}
+}
@@ -0,0 +1,31 @@
+//go:build windows
+
+package controller
+
+import (
+	"time"
+
+	"github.com/go-logr/logr"
+	networkingv1 "k8s.io/api/networking/v1"
+
+	"github.com/knabben/firewall-controller/internal/converter"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// auditApply writes an audit record attributing the rules programmed for a
+// NetworkPolicy to the client that last modified it, as recorded in managedFields
+func auditApply(logger logr.Logger, np *networkingv1.NetworkPolicy, policyKey hcnpkg.PolicyKey, ruleCount int) {
+	keysAndValues := []any{
+		"policyKey", policyKey,
+		"ruleCount", ruleCount,
+		"resourceVersion", np.ResourceVersion,
+		"generation", np.Generation,
+	}
+	if modification, found := converter.LastModification(np); found {
+		keysAndValues = append(keysAndValues,
+			"modifiedBy", modification.Manager,
+			"operation", modification.Operation,
+			"modifiedAt", modification.Time.UTC().Format(time.RFC3339))
+	}
+	logger.WithName("audit").Info("NetworkPolicy rules programmed", keysAndValues...)
+}
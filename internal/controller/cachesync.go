@@ -0,0 +1,122 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrCachesNotSynced is reported by the readiness check until every informer has synced
+var ErrCachesNotSynced = errors.New("informer caches have not synced")
+
+// CacheSyncGate holds rule programming back until the informers policies are
+// converted from have synced. controller-runtime only waits for each
+// controller's own watches; pods, namespaces and policies read through the
+// cache elsewhere would otherwise resolve selectors against a partial view and
+// program rules that are too restrictive or too permissive.
+type CacheSyncGate struct {
+	caches []gatedCache
+	synced chan struct{}
+	once   sync.Once
+	logger logr.Logger
+}
+
+// gatedCache is a cache and the objects whose informers must sync in it
+type gatedCache struct {
+	name    string
+	cache   cache.Informers
+	objects []client.Object
+}
+
+// NewCacheSyncGate creates a gate with no caches; add them with AddCache
+func NewCacheSyncGate(logger logr.Logger) *CacheSyncGate {
+	return &CacheSyncGate{synced: make(chan struct{}), logger: logger}
+}
+
+// AddCache makes the gate wait for the informers of objects in c. Informers
+// not yet started by a controller watch are started by the gate. It must be
+// called before the manager starts.
+func (g *CacheSyncGate) AddCache(name string, c cache.Informers, objects ...client.Object) {
+	g.caches = append(g.caches, gatedCache{name: name, cache: c, objects: objects})
+}
+
+// Start waits for every informer to sync and opens the gate. It implements
+// the controller-runtime Runnable interface.
+func (g *CacheSyncGate) Start(ctx context.Context) error {
+	for _, c := range g.caches {
+		for _, obj := range c.objects {
+			// GetInformer blocks until the informer has synced
+			if _, err := c.cache.GetInformer(ctx, obj); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("failed to sync %s informer of cache %q: %w", kindOf(obj), c.name, err)
+			}
+		}
+		if !c.cache.WaitForCacheSync(ctx) {
+			return nil
+		}
+		g.logger.Info("Informer cache synced", "cache", c.name, "informerCount", len(c.objects))
+	}
+	g.open()
+	return nil
+}
+
+// NeedLeaderElection implements LeaderElectionRunnable; every node programs its own endpoints
+func (g *CacheSyncGate) NeedLeaderElection() bool {
+	return false
+}
+
+// Synced reports whether every informer has synced
+func (g *CacheSyncGate) Synced() bool {
+	if g == nil {
+		return true
+	}
+	select {
+	case <-g.synced:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait blocks until every informer has synced or ctx is done. A nil gate
+// does not wait.
+func (g *CacheSyncGate) Wait(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+	select {
+	case <-g.synced:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ReadyzCheck fails until every informer has synced. It is a healthz.Checker.
+func (g *CacheSyncGate) ReadyzCheck(_ *http.Request) error {
+	if !g.Synced() {
+		return ErrCachesNotSynced
+	}
+	return nil
+}
+
+// open releases everything waiting on the gate
+func (g *CacheSyncGate) open() {
+	g.once.Do(func() { close(g.synced) })
+}
+
+// kindOf names the Go type of obj for logs and errors
+func kindOf(obj client.Object) string {
+	return reflect.TypeOf(obj).Elem().Name()
+}
@@ -0,0 +1,61 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+)
+
+func TestCacheSyncGate(t *testing.T) {
+	synced := false
+	informers := &informertest.FakeInformers{Synced: &synced}
+	gate := NewCacheSyncGate(logr.Discard())
+	gate.AddCache("local", informers, &networkingv1.NetworkPolicy{}, &corev1.Pod{})
+
+	// The cache never syncs before the context is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := gate.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if gate.Synced() {
+		t.Fatal("Expected the gate to stay closed while caches are unsynced")
+	}
+	if err := gate.ReadyzCheck(nil); !errors.Is(err, ErrCachesNotSynced) {
+		t.Errorf("Expected ErrCachesNotSynced, got %v", err)
+	}
+	if err := gate.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Wait to return when the context is done, got %v", err)
+	}
+
+	synced = true
+	if err := gate.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if !gate.Synced() || gate.ReadyzCheck(nil) != nil {
+		t.Error("Expected the gate to open once caches synced")
+	}
+	if err := gate.Wait(context.Background()); err != nil {
+		t.Errorf("Wait failed after sync: %v", err)
+	}
+	if _, err := informers.FakeInformerFor(context.Background(), &corev1.Pod{}); err != nil {
+		t.Errorf("Expected the gate to start the Pod informer: %v", err)
+	}
+}
+
+func TestCacheSyncGate_Nil(t *testing.T) {
+	var gate *CacheSyncGate
+	if !gate.Synced() {
+		t.Error("Expected a nil gate to report synced")
+	}
+	if err := gate.Wait(context.Background()); err != nil {
+		t.Errorf("Expected a nil gate not to wait, got %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build windows
+
+package controller
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+
+	"github.com/knabben/firewall-controller/internal/converter"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	"github.com/knabben/firewall-controller/internal/peers"
+)
+
+// EnforcementMatrix reports how every NetworkPolicy field is enforced with the
+// reconciler's conversion options, peer resolver, named port resolution and
+// apply scope
+func (r *NetworkPolicyReconciler) EnforcementMatrix() converter.EnforcementMatrix {
+	matrix := converter.BuildEnforcementMatrix(r.ConversionOptions, converter.ProbeEnvironment{
+		ResolvesPeer: func(peer networkingv1.NetworkPolicyPeer) bool {
+			return peers.Resolves(r.PeerResolver, peer)
+		},
+		ResolvesNamedPorts: r.NamedPorts != nil,
+	})
+	// The converter does not target endpoints; the selector scope does
+	if r.selectsPods() {
+		for i := range matrix.Fields {
+			if matrix.Fields[i].Field == "spec.podSelector" {
+				matrix.Fields[i].Level = converter.EnforcementEnforced
+			}
+		}
+	}
+	matrix.Node = r.NodeName
+	matrix.WindowsBuild = hcnpkg.WindowsBuild()
+	return matrix
+}
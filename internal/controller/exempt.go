@@ -0,0 +1,181 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// ExemptLabel set to "true" on a pod exempts its endpoint from every rule
+// when PodExemptions.Label is set, for system pods the node depends on
+const ExemptLabel = "networking.knabben.github.io/exempt"
+
+// PodExemptions selects the pods whose endpoints carry no rules at all. The
+// zero value exempts no pod.
+type PodExemptions struct {
+	// Label exempts the pods with ExemptLabel set to "true"
+	Label bool
+
+	// Selectors exempt the pods they select, in any namespace
+	Selectors []labels.Selector
+}
+
+// ParseExemptSelectors parses an --exempt-pod-selectors value: label
+// selectors separated by semicolons, since a selector may contain commas
+func ParseExemptSelectors(value string) ([]labels.Selector, error) {
+	var selectors []labels.Selector
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		selector, err := labels.Parse(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exempt pod selector %q: %w", entry, err)
+		}
+		if selector.Empty() {
+			return nil, fmt.Errorf("invalid exempt pod selector %q: it would exempt every pod", entry)
+		}
+		selectors = append(selectors, selector)
+	}
+	return selectors, nil
+}
+
+// Enabled reports whether any pod can be exempt
+func (e *PodExemptions) Enabled() bool {
+	return e != nil && (e.Label || len(e.Selectors) > 0)
+}
+
+// ExemptBy returns what exempts pod, the label or the first selector
+// selecting it; empty when pod is not exempt
+func (e *PodExemptions) ExemptBy(pod *corev1.Pod) string {
+	if !e.Enabled() {
+		return ""
+	}
+	if e.Label && pod.Labels[ExemptLabel] == "true" {
+		return ExemptLabel + "=true"
+	}
+	podLabels := labels.Set(pod.Labels)
+	for _, selector := range e.Selectors {
+		if selector.Matches(podLabels) {
+			return selector.String()
+		}
+	}
+	return ""
+}
+
+// PodExemptionReconciler exempts the endpoints of the exempt pods on this
+// node from every rule, and lifts the exemption once the pod no longer
+// matches or is deleted
+type PodExemptionReconciler struct {
+	client.Client
+	HCNManager hcnpkg.EndpointExempter
+	NodeName   string
+
+	// Exemptions selects the exempt pods
+	Exemptions *PodExemptions
+
+	// Recorder emits EndpointExempt and EndpointExemptionLifted events on the pod; nil disables events
+	Recorder record.EventRecorder
+
+	mu sync.Mutex
+
+	// exempt maps pod -> the IP its endpoint was exempted for
+	exempt map[types.NamespacedName]string
+}
+
+// Reconcile exempts a pod's endpoint, or lifts its exemption, to match its labels
+func (r *PodExemptionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var pod *corev1.Pod
+	var current corev1.Pod
+	if err := r.Get(ctx, req.NamespacedName, &current); err == nil {
+		pod = &current
+	} else if !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	wantIP, exemptBy := "", ""
+	if pod != nil && pod.DeletionTimestamp == nil && pod.Spec.NodeName == r.NodeName && !pod.Spec.HostNetwork {
+		if exemptBy = r.Exemptions.ExemptBy(pod); exemptBy != "" {
+			wantIP = pod.Status.PodIP
+		}
+	}
+
+	r.mu.Lock()
+	exemptIP := r.exempt[req.NamespacedName]
+	r.mu.Unlock()
+	if exemptIP == wantIP {
+		return ctrl.Result{}, nil
+	}
+
+	if exemptIP != "" {
+		if err := r.HCNManager.SetEndpointExempt(ctx, exemptIP, req.String(), false); err != nil {
+			logger.Error(err, "Failed to lift exemption of pod endpoint", "podIP", exemptIP)
+			return ctrl.Result{}, err
+		}
+		r.setExempt(req.NamespacedName, "")
+		logger.Info("Lifted exemption of pod endpoint", "podIP", exemptIP)
+		r.event(pod, "EndpointExemptionLifted", "NetworkPolicy rules are programmed on the pod's endpoint again")
+	}
+	if wantIP != "" {
+		if err := r.HCNManager.SetEndpointExempt(ctx, wantIP, req.String(), true); err != nil {
+			logger.Error(err, "Failed to exempt pod endpoint", "podIP", wantIP)
+			return ctrl.Result{}, err
+		}
+		r.setExempt(req.NamespacedName, wantIP)
+		logger.Info("Exempted pod endpoint from every rule", "podIP", wantIP, "exemptBy", exemptBy)
+		r.event(pod, "EndpointExempt", "No NetworkPolicy rules are programmed on the pod's endpoint: the pod is exempt by "+
+			exemptBy)
+	}
+	return ctrl.Result{}, nil
+}
+
+// setExempt records the IP a pod's endpoint is exempt for; empty forgets the pod
+func (r *PodExemptionReconciler) setExempt(name types.NamespacedName, ip string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.exempt == nil {
+		r.exempt = make(map[types.NamespacedName]string)
+	}
+	if ip == "" {
+		delete(r.exempt, name)
+		return
+	}
+	r.exempt[name] = ip
+}
+
+// event records a normal event on pod, if it still exists
+func (r *PodExemptionReconciler) event(pod *corev1.Pod, reason, message string) {
+	if r.Recorder != nil && pod != nil {
+		r.Recorder.Event(pod, corev1.EventTypeNormal, reason, message)
+	}
+}
+
+// SetupWithManager sets up the controller to watch the pods of this node
+func (r *PodExemptionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	onNode := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		pod, ok := obj.(*corev1.Pod)
+		return ok && pod.Spec.NodeName == r.NodeName
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("pod-exemption").
+		For(&corev1.Pod{}, builder.WithPredicates(onNode)).
+		Complete(r)
+}
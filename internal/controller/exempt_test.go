@@ -0,0 +1,126 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// recordingExempter records the exempt and lift calls it receives
+type recordingExempter struct {
+	calls []string
+}
+
+func (e *recordingExempter) SetEndpointExempt(ctx context.Context, ip, pod string, exempt bool) error {
+	e.calls = append(e.calls, fmt.Sprintf("%s %s %t", pod, ip, exempt))
+	return nil
+}
+
+func TestParseExemptSelectors(t *testing.T) {
+	selectors, err := ParseExemptSelectors("k8s-app=kube-dns; app in (csi-proxy,calico-node),tier=system;")
+	if err != nil || len(selectors) != 2 {
+		t.Fatalf("Expected two selectors, got %v, %v", selectors, err)
+	}
+	for _, value := range []string{"app in (", " ; "} {
+		if selectors, err := ParseExemptSelectors(value); err == nil && len(selectors) > 0 {
+			t.Errorf("ParseExemptSelectors(%q) = %v, want an error or no selector", value, selectors)
+		}
+	}
+}
+
+func TestPodExemptions_ExemptBy(t *testing.T) {
+	selectors, err := ParseExemptSelectors("k8s-app=kube-dns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exemptions := &PodExemptions{Label: true, Selectors: selectors}
+	pod := func(podLabels map[string]string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: podLabels}}
+	}
+
+	if by := exemptions.ExemptBy(pod(map[string]string{ExemptLabel: "true"})); by != ExemptLabel+"=true" {
+		t.Errorf("Expected the label to exempt the pod, got %q", by)
+	}
+	if by := exemptions.ExemptBy(pod(map[string]string{"k8s-app": "kube-dns"})); by != "k8s-app=kube-dns" {
+		t.Errorf("Expected the selector to exempt the pod, got %q", by)
+	}
+	if by := exemptions.ExemptBy(pod(map[string]string{ExemptLabel: "false", "app": "web"})); by != "" {
+		t.Errorf("Expected the pod not exempt, got %q", by)
+	}
+	var none *PodExemptions
+	if by := none.ExemptBy(pod(map[string]string{ExemptLabel: "true"})); by != "" {
+		t.Errorf("Expected no exemption without PodExemptions, got %q", by)
+	}
+}
+
+func TestPodExemptionReconciler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "coredns-0",
+			Namespace: "kube-system",
+			Labels:    map[string]string{ExemptLabel: "true"},
+		},
+		Spec:   corev1.PodSpec{NodeName: "node-1"},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.244.0.2"},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod).Build()
+	exempter := &recordingExempter{}
+	reconciler := &PodExemptionReconciler{
+		Client:     fakeClient,
+		HCNManager: exempter,
+		NodeName:   "node-1",
+		Exemptions: &PodExemptions{Label: true},
+	}
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "kube-system", Name: "coredns-0"}}
+	reconcile := func() {
+		t.Helper()
+		if _, err := reconciler.Reconcile(ctx, req); err != nil {
+			t.Fatalf("Reconcile failed: %v", err)
+		}
+	}
+
+	// Reconciling an unchanged pod again makes no call
+	reconcile()
+	reconcile()
+
+	// Removing the label lifts the exemption
+	pod.Labels = nil
+	if err := fakeClient.Update(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+
+	// Labelling it again and deleting it exempts, then lifts, once more
+	pod.Labels = map[string]string{ExemptLabel: "true"}
+	if err := fakeClient.Update(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+	if err := fakeClient.Delete(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+
+	want := []string{
+		"kube-system/coredns-0 10.244.0.2 true",
+		"kube-system/coredns-0 10.244.0.2 false",
+		"kube-system/coredns-0 10.244.0.2 true",
+		"kube-system/coredns-0 10.244.0.2 false",
+	}
+	if fmt.Sprint(exempter.calls) != fmt.Sprint(want) {
+		t.Errorf("Expected calls %v, got %v", want, exempter.calls)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// LocalPodsCacheOptions restricts the pod informer of a cache to the pods
+// scheduled on nodeName with a spec.nodeName field selector, so each agent of
+// a large fleet watches and holds its own pods instead of every pod of the
+// cluster. Pods of other nodes are then never seen: features resolving them
+// as peers, such as the informer peer resolver, cannot be used.
+func LocalPodsCacheOptions(opts cache.Options, nodeName string) cache.Options {
+	if opts.ByObject == nil {
+		opts.ByObject = make(map[client.Object]cache.ByObject)
+	}
+	opts.ByObject[&corev1.Pod{}] = cache.ByObject{
+		Field: fields.OneTermEqualSelector("spec.nodeName", nodeName),
+	}
+	return opts
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package controller
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+func TestLocalPodsCacheOptions(t *testing.T) {
+	opts := LocalPodsCacheOptions(NamespacedCacheOptions(cache.Options{}, "team-a"), "node-1")
+	if len(opts.ByObject) != 2 {
+		t.Fatalf("Expected the namespace restriction kept, got %v", opts.ByObject)
+	}
+	for object, byObject := range opts.ByObject {
+		if _, ok := object.(*corev1.Pod); !ok {
+			continue
+		}
+		if got := byObject.Field.String(); got != "spec.nodeName=node-1" {
+			t.Errorf("Expected pods selected by node, got %q", got)
+		}
+		return
+	}
+	t.Error("Expected the pod informer restricted")
+}
@@ -0,0 +1,79 @@
+//go:build windows
+
+package controller
+
+import (
+	"github.com/knabben/firewall-controller/internal/converter"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// conversionWarnings counts the current conversion warnings of each
+// NetworkPolicy, so partially enforced policies can be found from a dashboard
+var conversionWarnings = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "networkpolicy_agent",
+	Subsystem: "controller",
+	Name:      "conversion_warnings",
+	Help:      "Number of conversion warnings of each NetworkPolicy, by reason.",
+}, []string{"policy", "reason"})
+
+// rejectedPolicies marks the NetworkPolicies strict mode refuses to enforce,
+// by the reason of each unsupported construct
+var rejectedPolicies = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "networkpolicy_agent",
+	Subsystem: "controller",
+	Name:      "rejected_policies",
+	Help:      "NetworkPolicies rejected in strict mode, by reason; 1 while the policy is rejected.",
+}, []string{"policy", "reason"})
+
+// verificationProbes and verificationFailures export the outcome of the last
+// verification of each NetworkPolicy (see PolicyVerifier)
+var (
+	verificationProbes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "networkpolicy_agent",
+		Subsystem: "controller",
+		Name:      "verification_probes",
+		Help:      "Probes sent by the last verification of each NetworkPolicy.",
+	}, []string{"policy"})
+	verificationFailures = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "networkpolicy_agent",
+		Subsystem: "controller",
+		Name:      "verification_failures",
+		Help:      "Probes of the last verification of each NetworkPolicy whose outcome contradicted the programmed rules.",
+	}, []string{"policy"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(conversionWarnings, rejectedPolicies, verificationProbes, verificationFailures)
+}
+
+// recordWarnings replaces the warning counts exported for policyKey; nil
+// warnings clear them
+func recordWarnings(policyKey hcnpkg.PolicyKey, warnings []converter.Warning) {
+	conversionWarnings.DeletePartialMatch(prometheus.Labels{"policy": string(policyKey)})
+	for _, warning := range warnings {
+		conversionWarnings.WithLabelValues(string(policyKey), string(warning.Reason)).Inc()
+	}
+}
+
+// recordRejection replaces the rejection reasons exported for policyKey; nil
+// reasons clear them
+func recordRejection(policyKey hcnpkg.PolicyKey, reasons []converter.WarningReason) {
+	rejectedPolicies.DeletePartialMatch(prometheus.Labels{"policy": string(policyKey)})
+	for _, reason := range reasons {
+		rejectedPolicies.WithLabelValues(string(policyKey), string(reason)).Set(1)
+	}
+}
+
+// recordVerification exports the outcome of a verification of policyKey
+func recordVerification(policyKey hcnpkg.PolicyKey, probes, failures int) {
+	verificationProbes.WithLabelValues(string(policyKey)).Set(float64(probes))
+	verificationFailures.WithLabelValues(string(policyKey)).Set(float64(failures))
+}
+
+// forgetVerification drops the verification outcome exported for a removed policy
+func forgetVerification(policyKey hcnpkg.PolicyKey) {
+	verificationProbes.DeleteLabelValues(string(policyKey))
+	verificationFailures.DeleteLabelValues(string(policyKey))
+}
@@ -0,0 +1,72 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcile_ConversionWarningMetrics(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = networkingv1.AddToScheme(scheme)
+
+	namedPort := intstr.FromString("http")
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "partial", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				Ports: []networkingv1.NetworkPolicyPort{{Port: &namedPort}},
+				From: []networkingv1.NetworkPolicyPeer{
+					{PodSelector: &metav1.LabelSelector{}},
+					{NamespaceSelector: &metav1.LabelSelector{}},
+					{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8", Except: []string{"10.1.0.0/16"}}},
+				},
+			}},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(np).Build()
+
+	reconciler := &NetworkPolicyReconciler{
+		Client:     fakeClient,
+		Scheme:     scheme,
+		HCNManager: newMockHCNManager(),
+		ApplyScope: ApplyScopeAllEndpoints,
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "partial", Namespace: "default"}}
+	if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	expected := map[string]float64{
+		"named-port-dropped":   1,
+		"selector-unsupported": 2,
+		"except-ignored":       1,
+	}
+	for reason, count := range expected {
+		if got := testutil.ToFloat64(conversionWarnings.WithLabelValues("netpol/default/partial", reason)); got != count {
+			t.Errorf("Expected %v %s warnings, got %v", count, reason, got)
+		}
+	}
+
+	// Deleting the policy clears its series
+	if err := fakeClient.Delete(context.Background(), np); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if deleted := conversionWarnings.DeletePartialMatch(prometheus.Labels{"policy": "netpol/default/partial"}); deleted != 0 {
+		t.Errorf("Expected no warning series after deletion, got %d", deleted)
+	}
+}
@@ -0,0 +1,324 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+	"sort"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/knabben/firewall-controller/internal/converter"
+	"github.com/knabben/firewall-controller/internal/peers"
+)
+
+// NamedPortCache resolves NetworkPolicy named ports to the container port
+// numbers declared by the pods a selector matches. The named ports of each
+// namespace's pods are cached until a pod with named ports is added, removed
+// or changes its ports.
+type NamedPortCache struct {
+	mu sync.Mutex
+
+	// namespaces maps namespace -> the pods of the namespace with named ports
+	namespaces map[string][]podPorts
+}
+
+// podPorts are the labels and named ports of one pod
+type podPorts struct {
+	labels labels.Set
+
+	// ports maps converter.NamedPortKey -> port numbers
+	ports map[string][]int32
+}
+
+// NewNamedPortCache creates an empty named port cache
+func NewNamedPortCache() *NamedPortCache {
+	return &NamedPortCache{
+		namespaces: make(map[string][]podPorts),
+	}
+}
+
+// Resolve returns the named ports of the pods of namespace matching selector,
+// listing the namespace's pods on a cache miss
+func (c *NamedPortCache) Resolve(ctx context.Context, reader client.Reader, namespace string, selector labels.Selector) (map[string][]int32, error) {
+	pods, err := c.namespacePods(ctx, reader, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := make(map[string][]int32)
+	for _, pod := range pods {
+		if !selector.Matches(pod.labels) {
+			continue
+		}
+		for key, numbers := range pod.ports {
+			ports[key] = mergePorts(ports[key], numbers)
+		}
+	}
+	return ports, nil
+}
+
+// namespacePods returns the pods of namespace with named ports
+func (c *NamedPortCache) namespacePods(ctx context.Context, reader client.Reader, namespace string) ([]podPorts, error) {
+	c.mu.Lock()
+	pods, cached := c.namespaces[namespace]
+	c.mu.Unlock()
+	if cached {
+		return pods, nil
+	}
+
+	var list corev1.PodList
+	if err := reader.List(ctx, &list, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	pods = []podPorts{}
+	for i := range list.Items {
+		if ports := podNamedPorts(&list.Items[i]); ports != nil {
+			pods = append(pods, podPorts{labels: labels.Set(list.Items[i].Labels), ports: ports})
+		}
+	}
+
+	c.mu.Lock()
+	c.namespaces[namespace] = pods
+	c.mu.Unlock()
+	return pods, nil
+}
+
+// Invalidate drops the cached named ports of a namespace
+func (c *NamedPortCache) Invalidate(namespace string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.namespaces, namespace)
+}
+
+// resolveNamedPorts resolves the named ports of np: those of ingress rules
+// against the pods np selects, and those of egress rules against the pods of
+// each To peer. Egress rules to anywhere or to ipBlock peers resolve against
+// every pod, under converter.AnyPeerKey.
+func (r *NetworkPolicyReconciler) resolveNamedPorts(ctx context.Context, np *networkingv1.NetworkPolicy) (map[string][]int32, map[string]map[string][]int32, error) {
+	var ingress map[string][]int32
+	for _, rule := range np.Spec.Ingress {
+		if !hasNamedPorts(rule.Ports) {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(&np.Spec.PodSelector)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid podSelector: %w", err)
+		}
+		if ingress, err = r.NamedPorts.Resolve(ctx, r.Client, np.Namespace, selector); err != nil {
+			return nil, nil, err
+		}
+		break
+	}
+
+	var egress map[string]map[string][]int32
+	for _, rule := range np.Spec.Egress {
+		if !hasNamedPorts(rule.Ports) {
+			continue
+		}
+		if egress == nil {
+			egress = make(map[string]map[string][]int32)
+		}
+		if len(rule.To) == 0 {
+			if err := r.resolvePeerNamedPorts(ctx, np, networkingv1.NetworkPolicyPeer{}, egress); err != nil {
+				return nil, nil, err
+			}
+		}
+		for _, peer := range rule.To {
+			if err := r.resolvePeerNamedPorts(ctx, np, peer, egress); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return ingress, egress, nil
+}
+
+// resolvePeerNamedPorts adds the named ports of the pods peer selects to
+// resolved, keyed by converter.PeerKey; ipBlock and empty peers get those of
+// every pod
+func (r *NetworkPolicyReconciler) resolvePeerNamedPorts(ctx context.Context, np *networkingv1.NetworkPolicy, peer networkingv1.NetworkPolicyPeer, resolved map[string]map[string][]int32) error {
+	key := converter.PeerKey(peer)
+	if _, done := resolved[key]; done {
+		return nil
+	}
+
+	var namespaces []string
+	selector := labels.Everything()
+	if key == converter.AnyPeerKey {
+		var list corev1.NamespaceList
+		if err := r.List(ctx, &list, client.UnsafeDisableDeepCopy); err != nil {
+			return err
+		}
+		for i := range list.Items {
+			namespaces = append(namespaces, list.Items[i].Name)
+		}
+	} else {
+		var err error
+		if selector, err = peers.PodSelector(peer); err != nil {
+			return err
+		}
+		if namespaces, err = peers.PeerNamespaces(ctx, r.Client, np.Namespace, peer); err != nil {
+			return err
+		}
+	}
+
+	ports := make(map[string][]int32)
+	for _, namespace := range namespaces {
+		namespacePorts, err := r.NamedPorts.Resolve(ctx, r.Client, namespace, selector)
+		if err != nil {
+			return err
+		}
+		for name, numbers := range namespacePorts {
+			ports[name] = mergePorts(ports[name], numbers)
+		}
+	}
+	resolved[key] = ports
+	return nil
+}
+
+// policiesUsingPortsOf returns the NetworkPolicies outside namespace whose
+// egress named ports resolve against its pods
+func (r *NetworkPolicyReconciler) policiesUsingPortsOf(ctx context.Context, namespace string) []reconcile.Request {
+	logger := log.FromContext(ctx)
+	var ns corev1.Namespace
+	if err := r.Get(ctx, client.ObjectKey{Name: namespace}, &ns); err != nil {
+		if !apierrors.IsNotFound(err) {
+			logger.Error(err, "Failed to get Namespace for named port change", "namespace", namespace)
+		}
+		return nil
+	}
+
+	var policies networkingv1.NetworkPolicyList
+	if err := r.List(ctx, &policies, client.UnsafeDisableDeepCopy); err != nil {
+		logger.Error(err, "Failed to list NetworkPolicies for named port change", "namespace", namespace)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range policies.Items {
+		policy := &policies.Items[i]
+		if policy.Namespace != namespace && egressNamedPortsSelect(policy, &ns) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: policy.Namespace, Name: policy.Name},
+			})
+		}
+	}
+	return requests
+}
+
+// podNamedPorts returns the named container ports of a pod, keyed by converter.NamedPortKey
+func podNamedPorts(pod *corev1.Pod) map[string][]int32 {
+	var ports map[string][]int32
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Name == "" {
+				continue
+			}
+			if ports == nil {
+				ports = make(map[string][]int32)
+			}
+			key := converter.NamedPortKey(port.Name, port.Protocol)
+			ports[key] = mergePorts(ports[key], []int32{port.ContainerPort})
+		}
+	}
+	return ports
+}
+
+// namedPortsChanged reports whether a pod update renamed, added or removed
+// named ports, or relabelled a pod with named ports, changing the policies
+// selecting it that they resolve for
+func namedPortsChanged(oldPod, newPod *corev1.Pod) bool {
+	oldPorts, newPorts := podNamedPorts(oldPod), podNamedPorts(newPod)
+	if !reflect.DeepEqual(oldPorts, newPorts) {
+		return true
+	}
+	return len(newPorts) > 0 && !maps.Equal(oldPod.Labels, newPod.Labels)
+}
+
+// hasNamedPorts reports whether any of ports is referenced by name
+func hasNamedPorts(ports []networkingv1.NetworkPolicyPort) bool {
+	for _, port := range ports {
+		if port.Port != nil && port.Port.Type == intstr.String {
+			return true
+		}
+	}
+	return false
+}
+
+// usesNamedPorts reports whether a NetworkPolicy references any port by name
+func usesNamedPorts(np *networkingv1.NetworkPolicy) bool {
+	for _, rule := range np.Spec.Ingress {
+		if hasNamedPorts(rule.Ports) {
+			return true
+		}
+	}
+	return usesEgressNamedPorts(np)
+}
+
+// usesEgressNamedPorts reports whether a NetworkPolicy's egress rules
+// reference any port by name
+func usesEgressNamedPorts(np *networkingv1.NetworkPolicy) bool {
+	for _, rule := range np.Spec.Egress {
+		if hasNamedPorts(rule.Ports) {
+			return true
+		}
+	}
+	return false
+}
+
+// egressNamedPortsSelect reports whether the egress named ports of np resolve
+// against the pods of namespace: rules to anywhere or to ipBlock peers use
+// every pod, others those of the namespaces their peers select
+func egressNamedPortsSelect(np *networkingv1.NetworkPolicy, namespace *corev1.Namespace) bool {
+	for _, rule := range np.Spec.Egress {
+		if !hasNamedPorts(rule.Ports) {
+			continue
+		}
+		if len(rule.To) == 0 {
+			return true
+		}
+		for _, peer := range rule.To {
+			switch {
+			case peer.IPBlock != nil:
+				return true
+			case peer.NamespaceSelector == nil:
+				if np.Namespace == namespace.Name {
+					return true
+				}
+			default:
+				selector, err := metav1.LabelSelectorAsSelector(peer.NamespaceSelector)
+				if err == nil && selector.Matches(labels.Set(namespace.Labels)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// mergePorts returns the sorted union of two port lists
+func mergePorts(a, b []int32) []int32 {
+	seen := make(map[int32]bool, len(a)+len(b))
+	merged := make([]int32, 0, len(a)+len(b))
+	for _, port := range append(append([]int32{}, a...), b...) {
+		if !seen[port] {
+			seen[port] = true
+			merged = append(merged, port)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i] < merged[j] })
+	return merged
+}
@@ -0,0 +1,177 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/knabben/firewall-controller/internal/converter"
+)
+
+func podWithPort(name, portName string, port int32) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"app": name}},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "app",
+				Ports: []corev1.ContainerPort{{Name: portName, ContainerPort: port, Protocol: corev1.ProtocolTCP}},
+			}},
+		},
+	}
+}
+
+func TestNamedPortCache_ResolveAndInvalidate(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(podWithPort("web-1", "http", 8080), podWithPort("web-2", "http", 9090)).
+		Build()
+
+	cache := NewNamedPortCache()
+	ports, err := cache.Resolve(context.Background(), fakeClient, "default", labels.Everything())
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	key := converter.NamedPortKey("http", corev1.ProtocolTCP)
+	if !reflect.DeepEqual(ports[key], []int32{8080, 9090}) {
+		t.Fatalf("Expected http -> [8080 9090], got %v", ports[key])
+	}
+
+	// Cached results survive pod changes until invalidated
+	if err := fakeClient.Delete(context.Background(), podWithPort("web-2", "http", 9090)); err != nil {
+		t.Fatalf("Failed to delete pod: %v", err)
+	}
+	ports, _ = cache.Resolve(context.Background(), fakeClient, "default", labels.Everything())
+	if len(ports[key]) != 2 {
+		t.Errorf("Expected cached result, got %v", ports[key])
+	}
+
+	cache.Invalidate("default")
+	ports, _ = cache.Resolve(context.Background(), fakeClient, "default", labels.Everything())
+	if !reflect.DeepEqual(ports[key], []int32{8080}) {
+		t.Errorf("Expected http -> [8080] after invalidation, got %v", ports[key])
+	}
+}
+
+func TestNamedPortCache_ResolveSelectedPods(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(podWithPort("web-1", "http", 8080), podWithPort("web-2", "http", 9090)).
+		Build()
+
+	ports, err := NewNamedPortCache().Resolve(context.Background(), fakeClient, "default",
+		labels.SelectorFromSet(labels.Set{"app": "web-2"}))
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if key := converter.NamedPortKey("http", corev1.ProtocolTCP); !reflect.DeepEqual(ports[key], []int32{9090}) {
+		t.Errorf("Expected http -> [9090] for web-2 only, got %v", ports[key])
+	}
+}
+
+func TestResolveNamedPorts_IngressAndEgressPeers(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+
+	db := podWithPort("db-0", "sql", 5432)
+	db.Namespace = "data"
+	data := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "data", Labels: map[string]string{"tier": "data"}}}
+	web := intstr.FromString("http")
+	sql := intstr.FromString("sql")
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web-1"}},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				Ports: []networkingv1.NetworkPolicyPort{{Port: &web}},
+			}},
+			Egress: []networkingv1.NetworkPolicyEgressRule{{
+				To: []networkingv1.NetworkPolicyPeer{{
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "data"}},
+				}},
+				Ports: []networkingv1.NetworkPolicyPort{{Port: &sql}},
+			}},
+		},
+	}
+
+	reconciler := &NetworkPolicyReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(podWithPort("web-1", "http", 8080), podWithPort("web-2", "http", 9090), db, data).Build(),
+		NamedPorts: NewNamedPortCache(),
+	}
+	ingress, egress, err := reconciler.resolveNamedPorts(context.Background(), np)
+	if err != nil {
+		t.Fatalf("resolveNamedPorts failed: %v", err)
+	}
+
+	// Ingress opens the port numbers of the selected pods only
+	if got := ingress[converter.NamedPortKey("http", corev1.ProtocolTCP)]; !reflect.DeepEqual(got, []int32{8080}) {
+		t.Errorf("Expected ingress http -> [8080], got %v", got)
+	}
+	// Egress resolves against the peer's pods in another namespace
+	peerPorts := egress[converter.PeerKey(np.Spec.Egress[0].To[0])]
+	if got := peerPorts[converter.NamedPortKey("sql", corev1.ProtocolTCP)]; !reflect.DeepEqual(got, []int32{5432}) {
+		t.Errorf("Expected egress sql -> [5432], got %v", got)
+	}
+
+	// A pod in the peer's namespace changing its ports requeues the policy
+	if requests := reconciler.policiesUsingPortsOf(context.Background(), "data"); len(requests) != 1 {
+		t.Errorf("Expected the policy requeued for a named port change in data, got %v", requests)
+	}
+}
+
+func TestNamedPortsChanged(t *testing.T) {
+	if namedPortsChanged(podWithPort("p", "http", 8080), podWithPort("p", "http", 8080)) {
+		t.Error("Expected identical ports to be unchanged")
+	}
+	if !namedPortsChanged(podWithPort("p", "http", 8080), podWithPort("p", "web", 8080)) {
+		t.Error("Expected a renamed port to be a change")
+	}
+	if !namedPortsChanged(podWithPort("p", "http", 8080), podWithPort("p", "http", 8081)) {
+		t.Error("Expected a renumbered port to be a change")
+	}
+	relabelled := podWithPort("p", "http", 8080)
+	relabelled.Labels = map[string]string{"app": "other"}
+	if !namedPortsChanged(podWithPort("p", "http", 8080), relabelled) {
+		t.Error("Expected a relabelled pod with named ports to be a change")
+	}
+}
+
+func TestUsesNamedPorts(t *testing.T) {
+	named := intstr.FromString("http")
+	numbered := intstr.FromInt32(80)
+
+	np := &networkingv1.NetworkPolicy{
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				Ports: []networkingv1.NetworkPolicyPort{{Port: &numbered}},
+			}},
+		},
+	}
+	if usesNamedPorts(np) {
+		t.Error("Expected numbered ports not to count as named")
+	}
+
+	np.Spec.Egress = []networkingv1.NetworkPolicyEgressRule{{
+		Ports: []networkingv1.NetworkPolicyPort{{Port: &named}},
+	}}
+	if !usesNamedPorts(np) {
+		t.Error("Expected named egress port to be detected")
+	}
+}
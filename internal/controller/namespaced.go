@@ -0,0 +1,60 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ValidateWatchNamespace checks a --watch-namespace value; empty watches every namespace
+func ValidateWatchNamespace(namespace string) error {
+	if namespace == "" {
+		return nil
+	}
+	if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+		return fmt.Errorf("invalid namespace %q: %v", namespace, errs)
+	}
+	return nil
+}
+
+// NamespacedCacheOptions restricts an informer cache to one namespace: its
+// namespaced objects, and the Namespace object itself so namespaceSelector
+// peers still see its labels. Every list and watch the cache sends is then
+// allowed by a Role in the namespace plus a ClusterRole granting the
+// Namespace by resourceNames, instead of cluster-wide read access.
+func NamespacedCacheOptions(opts cache.Options, namespace string) cache.Options {
+	opts.DefaultNamespaces = map[string]cache.Config{namespace: {}}
+	if opts.ByObject == nil {
+		opts.ByObject = make(map[client.Object]cache.ByObject)
+	}
+	opts.ByObject[&corev1.Namespace{}] = cache.ByObject{
+		Field: fields.OneTermEqualSelector("metadata.name", namespace),
+	}
+	return opts
+}
+
+// NamespacedReader restricts the lists of a reader reaching the API server
+// directly to one namespace, like NamespacedCacheOptions does for the cache
+type NamespacedReader struct {
+	client.Reader
+	Namespace string
+}
+
+// List implements client.Reader
+func (r NamespacedReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if _, isNamespaces := list.(*corev1.NamespaceList); isNamespaces {
+		opts = append(opts, client.MatchingFieldsSelector{
+			Selector: fields.OneTermEqualSelector("metadata.name", r.Namespace),
+		})
+	} else {
+		opts = append(opts, client.InNamespace(r.Namespace))
+	}
+	return r.Reader.List(ctx, list, opts...)
+}
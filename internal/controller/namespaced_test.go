@@ -0,0 +1,77 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidateWatchNamespace(t *testing.T) {
+	for _, value := range []string{"", "team-a"} {
+		if err := ValidateWatchNamespace(value); err != nil {
+			t.Errorf("ValidateWatchNamespace(%q) failed: %v", value, err)
+		}
+	}
+	if err := ValidateWatchNamespace("Team_A"); err == nil {
+		t.Error("Expected an invalid namespace to be rejected")
+	}
+}
+
+func TestNamespacedCacheOptions(t *testing.T) {
+	opts := NamespacedCacheOptions(cache.Options{}, "team-a")
+	if _, ok := opts.DefaultNamespaces["team-a"]; !ok || len(opts.DefaultNamespaces) != 1 {
+		t.Errorf("Expected the cache restricted to team-a, got %v", opts.DefaultNamespaces)
+	}
+	for object, byObject := range opts.ByObject {
+		if _, ok := object.(*corev1.Namespace); !ok {
+			continue
+		}
+		if got := byObject.Field.String(); got != "metadata.name=team-a" {
+			t.Errorf("Expected Namespaces selected by name, got %q", got)
+		}
+		return
+	}
+	t.Error("Expected a Namespace field selector")
+}
+
+func TestNamespacedReader_List(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+	reader := fake.NewClientBuilder().WithScheme(scheme).
+		WithIndex(&corev1.Namespace{}, "metadata.name", func(obj client.Object) []string {
+			return []string{obj.GetName()}
+		}).
+		WithObjects(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+			&networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-a"}},
+			&networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-b"}},
+		).Build()
+	namespaced := NamespacedReader{Reader: reader, Namespace: "team-a"}
+
+	var policies networkingv1.NetworkPolicyList
+	if err := namespaced.List(context.Background(), &policies); err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(policies.Items) != 1 || policies.Items[0].Namespace != "team-a" {
+		t.Errorf("Expected only the policy of team-a, got %+v", policies.Items)
+	}
+
+	var namespaces corev1.NamespaceList
+	if err := namespaced.List(context.Background(), &namespaces); err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(namespaces.Items) != 1 || namespaces.Items[0].Name != "team-a" {
+		t.Errorf("Expected only the team-a Namespace, got %+v", namespaces.Items)
+	}
+}
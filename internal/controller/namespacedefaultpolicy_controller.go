@@ -0,0 +1,134 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	v1alpha1 "github.com/knabben/firewall-controller/api/v1alpha1"
+	"github.com/knabben/firewall-controller/internal/converter"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// NamespaceDefaultPolicyReconciler reconciles NamespaceDefaultPolicy objects and
+// programs the namespace's default posture on the endpoints of its pods
+type NamespaceDefaultPolicyReconciler struct {
+	client.Client
+	Scheme     *runtime.Scheme
+	HCNManager hcnpkg.HCNManager
+
+	// Rules holds the compiled defaults; it must be registered with the HCN Manager
+	Rules *hcnpkg.TargetedProvider
+
+	// AutoAllowDNS are DNS server IPs allowed whenever a policy denies egress
+	AutoAllowDNS []string
+
+	// CacheSync holds reconciles back until the informers have synced; nil does not wait
+	CacheSync *CacheSyncGate
+
+	// Retry tunes the backoff of failed reconciles; unset uses DefaultRetryOptions
+	Retry RetryOptions
+}
+
+// Reconcile compiles a NamespaceDefaultPolicy against the current pods of its
+// namespace and reconciles the HCN endpoints toward the result
+func (r *NamespaceDefaultPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	logger.Info("Reconciling NamespaceDefaultPolicy", "namespace", req.Namespace, "name", req.Name)
+
+	// Pods listed from a partially synced cache would leave endpoints uncovered
+	if err := r.CacheSync.Wait(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	policyKey := namespaceDefaultPolicyKey(req.NamespacedName)
+
+	var ndp v1alpha1.NamespaceDefaultPolicy
+	if err := r.Get(ctx, req.NamespacedName, &ndp); err != nil {
+		if !apierrors.IsNotFound(err) {
+			logger.Error(err, "Failed to get NamespaceDefaultPolicy")
+			return ctrl.Result{}, err
+		}
+		logger.Info("NamespaceDefaultPolicy not found, cleaning up HCN rules", "policyKey", policyKey)
+		r.Rules.Delete(policyKey)
+	} else {
+		podIPs, err := listNamespacePodIPs(ctx, r.Client, ndp.Namespace)
+		if err != nil {
+			logger.Error(err, "Failed to list pods in namespace")
+			return ctrl.Result{}, err
+		}
+
+		rules := converter.NamespaceDefaultPolicyToACLRules(&ndp, podIPs, r.AutoAllowDNS)
+		logger.Info("Generated ACL rules from NamespaceDefaultPolicy",
+			"ruleCount", len(rules),
+			"podIPCount", len(podIPs))
+		r.Rules.Set(policyKey, podIPs, rules)
+	}
+
+	if err := r.HCNManager.Reconcile(); err != nil {
+		logger.Error(err, "Failed to reconcile HCN ACL rules", "policyKey", policyKey)
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// policiesForPod maps a pod event to every NamespaceDefaultPolicy in the pod's namespace
+func (r *NamespaceDefaultPolicyReconciler) policiesForPod(ctx context.Context, obj client.Object) []reconcile.Request {
+	var policies v1alpha1.NamespaceDefaultPolicyList
+	if err := r.List(ctx, &policies, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list NamespaceDefaultPolicies for pod",
+			"namespace", obj.GetNamespace(),
+			"pod", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(policies.Items))
+	for _, policy := range policies.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: policy.Namespace, Name: policy.Name},
+		})
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager. Pod changes requeue
+// the policies of their namespace so the same-namespace peers stay current.
+func (r *NamespaceDefaultPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.NamespaceDefaultPolicy{}).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(r.policiesForPod)).
+		WithOptions(crcontroller.Options{RateLimiter: r.Retry.RateLimiter()}).
+		Complete(r)
+}
+
+// namespaceDefaultPolicyKey returns the policy key a NamespaceDefaultPolicy is tracked under
+func namespaceDefaultPolicyKey(name types.NamespacedName) hcnpkg.PolicyKey {
+	return hcnpkg.NewPolicyKey(hcnpkg.SourceNamespaceDefault, name.String())
+}
+
+// NewNamespaceDefaultPolicyReconciler creates a new NamespaceDefaultPolicyReconciler
+func NewNamespaceDefaultPolicyReconciler(
+	client client.Client,
+	scheme *runtime.Scheme,
+	hcnManager hcnpkg.HCNManager,
+	rules *hcnpkg.TargetedProvider,
+) *NamespaceDefaultPolicyReconciler {
+	return &NamespaceDefaultPolicyReconciler{
+		Client:     client,
+		Scheme:     scheme,
+		HCNManager: hcnManager,
+		Rules:      rules,
+	}
+}
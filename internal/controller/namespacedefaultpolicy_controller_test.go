@@ -0,0 +1,88 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	v1alpha1 "github.com/knabben/firewall-controller/api/v1alpha1"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+func TestReconcile_NamespaceDefaultPolicy(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+
+	ndp := &v1alpha1.NamespaceDefaultPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "baseline", Namespace: "team-a"},
+		Spec: v1alpha1.NamespaceDefaultPolicySpec{
+			Ingress: v1alpha1.IngressPosture{AllowSameNamespace: true, DenyOther: true},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-a"},
+		Status: corev1.PodStatus{
+			Phase:  corev1.PodRunning,
+			PodIPs: []corev1.PodIP{{IP: "10.0.0.1"}},
+		},
+	}
+	hostPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "host", Namespace: "team-a"},
+		Spec:       corev1.PodSpec{HostNetwork: true},
+		Status: corev1.PodStatus{
+			Phase:  corev1.PodRunning,
+			PodIPs: []corev1.PodIP{{IP: "192.168.0.10"}},
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(ndp, pod, hostPod).
+		Build()
+
+	mockHCN := newMockHCNManager()
+	rules := hcnpkg.NewTargetedProvider("namespacedefault")
+	reconciler := NewNamespaceDefaultPolicyReconciler(fakeClient, scheme, mockHCN, rules)
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "baseline", Namespace: "team-a"}}
+	if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	if mockHCN.reconcileCount != 1 {
+		t.Errorf("Expected 1 HCN reconciliation, got %d", mockHCN.reconcileCount)
+	}
+
+	endpoint := hcnlib.HostComputeEndpoint{
+		Id:               "ep-1",
+		IpConfigurations: []hcnlib.IpConfig{{IpAddress: "10.0.0.1"}},
+	}
+	compiled := rules.DesiredRulesFor(endpoint)["namespacedefault/team-a/baseline"]
+	if len(compiled) != 2 {
+		t.Fatalf("Expected 2 rules on namespace endpoint, got %d: %+v", len(compiled), compiled)
+	}
+	if compiled[0].RemoteAddresses != "10.0.0.1" {
+		t.Errorf("Expected host-network pods to be excluded, got %q", compiled[0].RemoteAddresses)
+	}
+
+	// Deleting the policy drops its rules
+	if err := fakeClient.Delete(context.Background(), ndp); err != nil {
+		t.Fatalf("Failed to delete NamespaceDefaultPolicy: %v", err)
+	}
+	if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile after delete failed: %v", err)
+	}
+	if table := rules.DesiredRulesFor(endpoint); len(table) != 0 {
+		t.Errorf("Expected no rules after delete, got %v", table)
+	}
+}
@@ -4,34 +4,124 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"maps"
 	"time"
 
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	crcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/knabben/firewall-controller/internal/converter"
 	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	"github.com/knabben/firewall-controller/internal/notify"
+	"github.com/knabben/firewall-controller/internal/peers"
 )
 
 // NetworkPolicyReconciler reconciles NetworkPolicy objects and applies HCN ACL rules
 type NetworkPolicyReconciler struct {
 	client.Client
 	Scheme     *runtime.Scheme
-	HCNManager *hcnpkg.Manager
+	HCNManager hcnpkg.HCNManager
 	NodeName   string // Name of the node this agent is running on
+
+	// ConversionOptions tunes the NetworkPolicy -> ACL translation
+	ConversionOptions converter.ConversionOptions
+
+	// NamedPorts resolves named ports from pod specs; nil leaves them unresolved
+	NamedPorts *NamedPortCache
+
+	// PeerResolver resolves pod selector peers to IPs; nil leaves them unresolved
+	PeerResolver peers.PeerResolver
+
+	// Recorder emits events on the NetworkPolicy when HNS rejects its rules; nil disables events
+	Recorder record.EventRecorder
+
+	// Notifier receives an event each time a policy's rules are applied,
+	// removed or fail; nil disables notifications
+	Notifier notify.Notifier
+
+	// SourceName names the cluster the policies are read from; empty for the
+	// agent's own cluster (see PolicySource)
+	SourceName string
+
+	// MaxConcurrentReconciles is how many NetworkPolicies are converted and
+	// programmed at once; 0 keeps the controller-runtime default of 1
+	MaxConcurrentReconciles int
+
+	// ApplyTimeout bounds how long one reconcile programs endpoints; an
+	// interrupted apply is requeued and resumes with the endpoints it missed.
+	// 0 applies without a deadline.
+	ApplyTimeout time.Duration
+
+	// CacheSync holds reconciles back until the informers have synced; nil does not wait
+	CacheSync *CacheSyncGate
+
+	// Requeues feeds the controller the policies the HCN Manager asks to
+	// reconcile again; nil leaves them to the periodic resync
+	Requeues *RequeueDispatcher
+
+	// Priorities hands each policy a priority range of its own; nil lets
+	// every policy draw its priorities from the start of the band
+	Priorities *hcnpkg.PriorityAllocator
+
+	// ApplyScope selects the endpoints a policy's rules are programmed on;
+	// empty means ApplyScopeSelector
+	ApplyScope ApplyScope
+
+	// PodEventDelay holds the reconcile a pod event triggers back for this
+	// long, so a rollout in a large namespace converts and programs the
+	// affected policies once per window instead of once per pod. 0 reconciles
+	// right away.
+	PodEventDelay time.Duration
+
+	// Verifier probes the endpoints of each applied policy to check HNS
+	// enforces its rules; nil skips verification
+	Verifier *PolicyVerifier
+
+	// Faults delays reconciles while a reconcile-delay fault is injected; nil
+	// injects nothing
+	Faults *hcnpkg.FaultInjector
+
+	// Retry tunes the backoff of failed reconciles; unset uses DefaultRetryOptions
+	Retry RetryOptions
 }
 
 // Reconcile implements the reconciliation loop for NetworkPolicy resources
-// It converts NetworkPolicy rules to HCN ACL rules and applies them to all endpoints
+// It converts NetworkPolicy rules to HCN ACL rules and applies them to the endpoints of its ApplyScope
 func (r *NetworkPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 	logger.Info("Reconciling NetworkPolicy", "namespace", req.Namespace, "name", req.Name)
 
+	// Selectors resolved against partially synced caches produce wrong rules
+	if err := r.CacheSync.Wait(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Game days simulate a slow control loop
+	if delay := r.Faults.ReconcileDelay(); delay > 0 {
+		logger.V(1).Info("Delaying reconcile for an injected fault", "delay", delay)
+		select {
+		case <-ctx.Done():
+			return ctrl.Result{}, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+
 	// Fetch the NetworkPolicy
 	var np networkingv1.NetworkPolicy
 	if err := r.Get(ctx, req.NamespacedName, &np); err != nil {
@@ -40,7 +130,7 @@ func (r *NetworkPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 			logger.Info("NetworkPolicy not found, cleaning up HCN rules",
 				"namespace", req.Namespace,
 				"name", req.Name)
-			return r.reconcileDelete(ctx, req.NamespacedName.String())
+			return r.reconcileDelete(ctx, r.policyKey(req.NamespacedName))
 		}
 		logger.Error(err, "Failed to get NetworkPolicy")
 		return ctrl.Result{}, err
@@ -51,66 +141,320 @@ func (r *NetworkPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		"ingressRules", len(np.Spec.Ingress),
 		"egressRules", len(np.Spec.Egress))
 
-	rules := converter.NetworkPolicyToACLRules(&np)
+	opts := r.ConversionOptions
+	sameNamespaceIngress, sameNamespaceEgress, err := converter.SameNamespaceDirections(&np)
+	if err != nil {
+		logger.Error(err, "Invalid same-namespace peer annotation")
+		return ctrl.Result{}, nil
+	}
+	if sameNamespaceIngress || sameNamespaceEgress {
+		opts.NamespacePodIPs, err = listNamespacePodIPs(ctx, r.Client, np.Namespace)
+		if err != nil {
+			logger.Error(err, "Failed to list pods for same-namespace peer")
+			return ctrl.Result{}, err
+		}
+	}
+
+	aggregation, err := converter.PeerAggregationOf(&np)
+	if err != nil {
+		logger.Error(err, "Invalid peer aggregation annotation")
+		return ctrl.Result{}, nil
+	}
+	if aggregation == converter.PeerAggregationNamespace {
+		opts.NamespacePodCIDRs, err = r.namespacePodCIDRs(ctx, &np)
+		if err != nil {
+			logger.Error(err, "Failed to get Namespaces for peer aggregation")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if r.NamedPorts != nil && usesNamedPorts(&np) {
+		opts.NamedPorts, opts.PeerNamedPorts, err = r.resolveNamedPorts(ctx, &np)
+		if err != nil {
+			logger.Error(err, "Failed to resolve named ports")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if r.PeerResolver != nil && peers.HasSelectorPeers(&np) {
+		opts.PeerAddresses, err = peers.Resolve(ctx, r.PeerResolver, r.Client, &np)
+		if err != nil {
+			logger.Error(err, "Failed to resolve selector peers")
+			return ctrl.Result{}, err
+		}
+	}
+
+	policyKey := r.policyKey(req.NamespacedName) // e.g., "default/allow-http"
+	conversion, err := converter.ConvertNetworkPolicy(&np, opts)
+	var rejection *converter.UnsupportedFieldsError
+	if errors.As(err, &rejection) {
+		r.notify(notify.EventFailed, &np, policyKey, 0, "PolicyRejected", rejection)
+		if err := r.rejectPolicy(ctx, &np, policyKey, rejection); err != nil {
+			logger.Error(err, "Failed to remove the HCN ACL rules of a rejected NetworkPolicy", "policyKey", policyKey)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		// The policy cannot be translated as written; retrying won't help
+		logger.Error(err, "Failed to convert NetworkPolicy to HCN ACL rules")
+		r.notify(notify.EventFailed, &np, policyKey, 0, "ConversionFailed", err)
+		return ctrl.Result{}, nil
+	}
+	conversion, err = r.allocatePriorities(&np, policyKey, opts, conversion)
+	if err != nil {
+		// Rules programmed for an earlier version of the policy stay in place
+		logger.Error(err, "Failed to allocate ACL priorities", "policyKey", policyKey)
+		r.notify(notify.EventFailed, &np, policyKey, 0, "PriorityExhausted", err)
+		return ctrl.Result{RequeueAfter: r.Retry.withDefaults().MaxBackoff}, nil
+	}
+	rules := conversion.Rules
+	recordRejection(policyKey, nil)
+	r.reportWarnings(ctx, &np, policyKey, conversion.Warnings)
 
 	logger.Info("Generated ACL rules from NetworkPolicy",
 		"ruleCount", len(rules))
 
 	// Apply ACL rules via HCN Manager
-	policyKey := req.NamespacedName.String() // e.g., "default/allow-http"
-	if err := r.HCNManager.ApplyACLRules(policyKey, rules); err != nil {
+	if err := r.applyScoped(ctx, &np, policyKey, rules); err != nil {
+		if errors.Is(err, hcnpkg.ErrApplyInterrupted) {
+			// Progress is kept; pick up the remaining endpoints right away
+			logger.Info("Apply deadline reached, resuming with the remaining endpoints",
+				"policyKey", policyKey,
+				"error", err.Error())
+			return ctrl.Result{Requeue: true}, nil
+		}
+		hnsErr := hcnpkg.ParseHNSError(err)
 		logger.Error(err, "Failed to apply HCN ACL rules",
-			"policyKey", policyKey,
-			"ruleCount", len(rules))
+			append([]any{"policyKey", policyKey, "ruleCount", len(rules)}, hnsErr.LogKeys()...)...)
+		if r.Recorder != nil {
+			r.Recorder.Event(&np, corev1.EventTypeWarning, "HNSApplyFailed", hnsErr.Summary())
+		}
+		r.notify(notify.EventFailed, &np, policyKey, len(rules), "HNSApplyFailed", err)
 
-		// Requeue with backoff - transient errors like endpoint unavailability
-		// will be retried automatically by controller-runtime
-		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
+		// Transient errors like endpoint unavailability are retried with the
+		// backoff of Retry
+		return ctrl.Result{}, err
 	}
 
 	logger.Info("Successfully applied HCN ACL rules",
 		"policyKey", policyKey,
 		"ruleCount", len(rules))
+	auditApply(logger, &np, policyKey, len(rules))
+	r.notify(notify.EventApplied, &np, policyKey, len(rules), "", nil)
+	if r.Verifier != nil {
+		r.Verifier.Enqueue(&np, policyKey)
+	}
 
 	return ctrl.Result{}, nil
 }
 
+// reportWarnings logs the conversion warnings of a NetworkPolicy, exports
+// their counts and records them as warning events, so partially enforced
+// policies show up in dashboards and kubectl describe
+func (r *NetworkPolicyReconciler) reportWarnings(ctx context.Context, np *networkingv1.NetworkPolicy, policyKey hcnpkg.PolicyKey, warnings []converter.Warning) {
+	recordWarnings(policyKey, warnings)
+	logger := log.FromContext(ctx)
+	for _, warning := range warnings {
+		logger.Info("NetworkPolicy not enforced exactly as written",
+			"reason", warning.Reason,
+			"warning", warning.Message)
+		if r.Recorder != nil {
+			r.Recorder.Event(np, corev1.EventTypeWarning, "ConversionWarning", warning.String())
+		}
+	}
+}
+
+// applyACLRules programs rules through the HCN manager, bounded by ApplyTimeout
+// when the manager supports interruptible applies
+func (r *NetworkPolicyReconciler) applyACLRules(ctx context.Context, policyKey hcnpkg.PolicyKey, rules []hcnpkg.ACLRule) error {
+	applier, ok := r.HCNManager.(hcnpkg.ContextApplier)
+	if !ok {
+		return r.HCNManager.ApplyACLRules(policyKey, rules)
+	}
+	if r.ApplyTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.ApplyTimeout)
+		defer cancel()
+	}
+	return applier.ApplyACLRulesContext(ctx, policyKey, rules)
+}
+
 // reconcileDelete handles cleanup when a NetworkPolicy is deleted
-func (r *NetworkPolicyReconciler) reconcileDelete(ctx context.Context, policyKey string) (ctrl.Result, error) {
+func (r *NetworkPolicyReconciler) reconcileDelete(ctx context.Context, policyKey hcnpkg.PolicyKey) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 	logger.Info("Reconciling NetworkPolicy deletion", "policyKey", policyKey)
+	recordWarnings(policyKey, nil)
+	recordRejection(policyKey, nil)
+	forgetVerification(policyKey)
 
 	// Remove HCN ACL rules
 	if err := r.HCNManager.RemoveACLRules(policyKey); err != nil {
 		logger.Error(err, "Failed to remove HCN ACL rules", "policyKey", policyKey)
+		r.notify(notify.EventFailed, nil, policyKey, 0, "RemoveFailed", err)
 		// Still return success - the policy is gone, so we don't want to keep retrying
-		// The HCN rules will be cleaned up on agent restart via orphan cleanup
+		// Rules left on endpoints stay tracked and are removed by the next
+		// resync, or after a restart by the OrphanCleaner
 		return ctrl.Result{}, nil
 	}
 
 	logger.Info("Successfully removed HCN ACL rules", "policyKey", policyKey)
+	r.releasePriorities(policyKey)
+	r.notify(notify.EventRemoved, nil, policyKey, 0, "", nil)
 	return ctrl.Result{}, nil
 }
 
+// policiesForPod returns the NetworkPolicies whose rules or targets depend on
+// the pod: in the pod's namespace those using the same-namespace peer or, when
+// peers are resolved from pods, selector peers, when the pod's named ports
+// changed those referencing ports by name, and with ApplyScopeSelector those
+// selecting the pod; elsewhere, when peers are resolved from pods, those
+// selecting the pod's namespace, and when its named ports changed, those whose
+// egress named ports resolve against the pod's namespace
+func (r *NetworkPolicyReconciler) policiesForPod(ctx context.Context, obj client.Object, portsChanged bool) []reconcile.Request {
+	if portsChanged && r.NamedPorts != nil {
+		r.NamedPorts.Invalidate(obj.GetNamespace())
+	}
+
+	var policies networkingv1.NetworkPolicyList
+	if err := r.List(ctx, &policies, client.InNamespace(obj.GetNamespace()), client.UnsafeDisableDeepCopy); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list NetworkPolicies for pod",
+			"namespace", obj.GetNamespace(),
+			"pod", obj.GetName())
+		return nil
+	}
+
+	_, podsResolvePeers := r.peerWatch().(*corev1.Pod)
+	var requests []reconcile.Request
+	if podsResolvePeers {
+		requests = r.policiesSelectingNamespace(ctx, obj.GetNamespace())
+	}
+	if portsChanged && r.NamedPorts != nil {
+		requests = append(requests, r.policiesUsingPortsOf(ctx, obj.GetNamespace())...)
+	}
+	for i := range policies.Items {
+		policy := &policies.Items[i]
+		_, sameNamespace := policy.Annotations[converter.SameNamespaceAnnotation]
+		selectsPods := podsResolvePeers && peers.HasSelectorPeers(policy)
+		// Pods of other nodes have no endpoint here for the policy to target
+		targetsPod := r.selectsPods() && r.hostsPod(obj) && policySelects(policy, obj)
+		if !sameNamespace && !selectsPods && !targetsPod && !(portsChanged && usesNamedPorts(policy)) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: policy.Namespace, Name: policy.Name},
+		})
+	}
+	return requests
+}
+
+// podEventHandler requeues the NetworkPolicies affected by a pod event
+func (r *NetworkPolicyReconciler) podEventHandler() handler.EventHandler {
+	enqueue := func(ctx context.Context, obj client.Object, portsChanged bool, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+		for _, request := range r.policiesForPod(ctx, obj, portsChanged) {
+			if r.PodEventDelay > 0 {
+				// Events for a policy already waiting are merged into its pending reconcile
+				q.AddAfter(request, r.PodEventDelay)
+				continue
+			}
+			q.Add(request)
+		}
+	}
+	hasNamedPorts := func(obj client.Object) bool {
+		pod, ok := obj.(*corev1.Pod)
+		return ok && len(podNamedPorts(pod)) > 0
+	}
+
+	return handler.Funcs{
+		CreateFunc: func(ctx context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, e.Object, hasNamedPorts(e.Object), q)
+		},
+		UpdateFunc: func(ctx context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			oldPod, oldOK := e.ObjectOld.(*corev1.Pod)
+			newPod, newOK := e.ObjectNew.(*corev1.Pod)
+			enqueue(ctx, e.ObjectNew, oldOK && newOK && namedPortsChanged(oldPod, newPod), q)
+			if r.selectsPods() && !maps.Equal(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels()) {
+				// Policies that selected the pod before the change must drop its endpoint
+				enqueue(ctx, e.ObjectOld, false, q)
+			}
+		},
+		DeleteFunc: func(ctx context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, e.Object, hasNamedPorts(e.Object), q)
+		},
+		GenericFunc: func(ctx context.Context, e event.GenericEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, e.Object, hasNamedPorts(e.Object), q)
+		},
+	}
+}
+
+// policyKey returns the HCN manager key for a NetworkPolicy, prefixed with the
+// source name for policies read from an additional cluster
+func (r *NetworkPolicyReconciler) policyKey(name types.NamespacedName) hcnpkg.PolicyKey {
+	if r.SourceName == "" {
+		return hcnpkg.NewPolicyKey(hcnpkg.SourceNetworkPolicy, name.String())
+	}
+	return hcnpkg.NewPolicyKey(hcnpkg.SourceNetworkPolicy, r.SourceName+"/"+name.String())
+}
+
 // SetupWithManager sets up the controller with the Manager
 func (r *NetworkPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&networkingv1.NetworkPolicy{}).
+		Watches(&corev1.Pod{}, r.podEventHandler())
+	if obj := r.peerObjectWatch(); obj != nil {
+		builder = builder.Watches(obj, r.peerEventHandler())
+	}
+	// Namespaces carry the labels selector peers match and the pod CIDRs of the aggregation
+	builder = builder.Watches(&corev1.Namespace{}, r.namespaceEventHandler())
+	if r.Requeues != nil {
+		builder = builder.WatchesRawSource(r.Requeues.Source(r))
+	}
+	return builder.
+		WithOptions(r.controllerOptions()).
+		Complete(r)
+}
+
+// SetupWithCluster sets up the controller to watch NetworkPolicies and Pods of
+// an additional cluster. The cluster must be added to mgr so its cache is started.
+func (r *NetworkPolicyReconciler) SetupWithCluster(mgr ctrl.Manager, cl cluster.Cluster) error {
+	builder := ctrl.NewControllerManagedBy(mgr).
+		Named("networkpolicy-" + r.SourceName).
+		WatchesRawSource(source.Kind[client.Object](cl.GetCache(), &networkingv1.NetworkPolicy{}, &handler.EnqueueRequestForObject{})).
+		WatchesRawSource(source.Kind[client.Object](cl.GetCache(), &corev1.Pod{}, r.podEventHandler()))
+	if obj := r.peerObjectWatch(); obj != nil {
+		builder = builder.WatchesRawSource(source.Kind[client.Object](cl.GetCache(), obj, r.peerEventHandler()))
+	}
+	builder = builder.WatchesRawSource(source.Kind[client.Object](cl.GetCache(), &corev1.Namespace{}, r.namespaceEventHandler()))
+	if r.Requeues != nil {
+		builder = builder.WatchesRawSource(r.Requeues.Source(r))
+	}
+	return builder.
+		WithOptions(r.controllerOptions()).
 		Complete(r)
 }
 
+// controllerOptions returns the work queue tuning of the controller
+func (r *NetworkPolicyReconciler) controllerOptions() crcontroller.Options {
+	return crcontroller.Options{
+		MaxConcurrentReconciles: r.MaxConcurrentReconciles,
+		RateLimiter:             r.Retry.RateLimiter(),
+	}
+}
+
 // NewNetworkPolicyReconciler creates a new NetworkPolicyReconciler
 func NewNetworkPolicyReconciler(
 	client client.Client,
 	scheme *runtime.Scheme,
-	hcnManager *hcnpkg.Manager,
+	hcnManager hcnpkg.HCNManager,
 	nodeName string,
 	logger logr.Logger,
 ) *NetworkPolicyReconciler {
 	return &NetworkPolicyReconciler{
-		Client:     client,
-		Scheme:     scheme,
-		HCNManager: hcnManager,
-		NodeName:   nodeName,
+		Client:            client,
+		Scheme:            scheme,
+		HCNManager:        hcnManager,
+		NodeName:          nodeName,
+		ConversionOptions: converter.DefaultConversionOptions(),
+		NamedPorts:        NewNamedPortCache(),
 	}
 }
@@ -4,38 +4,54 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/go-logr/logr"
 	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	"github.com/knabben/firewall-controller/internal/converter"
 	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	"github.com/knabben/firewall-controller/internal/notify"
+	"github.com/knabben/firewall-controller/internal/peers"
+	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-// mockHCNManager is a mock implementation of the HCN Manager for testing
+// mockHCNManager is a mock implementation of hcnpkg.HCNManager for testing
 type mockHCNManager struct {
-	appliedPolicies map[string][]hcnpkg.ACLRule
-	removedPolicies []string
+	appliedPolicies map[hcnpkg.PolicyKey][]hcnpkg.ACLRule
+	removedPolicies []hcnpkg.PolicyKey
 	applyError      error
 	removeError     error
+	reconcileError  error
+	reconcileCount  int
 }
 
+var _ hcnpkg.HCNManager = &mockHCNManager{}
+
 func newMockHCNManager() *mockHCNManager {
 	return &mockHCNManager{
-		appliedPolicies: make(map[string][]hcnpkg.ACLRule),
-		removedPolicies: []string{},
+		appliedPolicies: make(map[hcnpkg.PolicyKey][]hcnpkg.ACLRule),
+		removedPolicies: []hcnpkg.PolicyKey{},
 	}
 }
 
-func (m *mockHCNManager) ApplyACLRules(policyKey string, rules []hcnpkg.ACLRule) error {
+func (m *mockHCNManager) ApplyACLRules(policyKey hcnpkg.PolicyKey, rules []hcnpkg.ACLRule) error {
 	if m.applyError != nil {
 		return m.applyError
 	}
@@ -43,7 +59,7 @@ func (m *mockHCNManager) ApplyACLRules(policyKey string, rules []hcnpkg.ACLRule)
 	return nil
 }
 
-func (m *mockHCNManager) RemoveACLRules(policyKey string) error {
+func (m *mockHCNManager) RemoveACLRules(policyKey hcnpkg.PolicyKey) error {
 	if m.removeError != nil {
 		return m.removeError
 	}
@@ -52,7 +68,7 @@ func (m *mockHCNManager) RemoveACLRules(policyKey string) error {
 	return nil
 }
 
-func (m *mockHCNManager) GetAppliedPolicies(policyKey string) ([]hcnpkg.RuleSet, bool) {
+func (m *mockHCNManager) GetAppliedPolicies(policyKey hcnpkg.PolicyKey) ([]hcnpkg.RuleSet, bool) {
 	rules, exists := m.appliedPolicies[policyKey]
 	if !exists {
 		return nil, false
@@ -61,14 +77,19 @@ func (m *mockHCNManager) GetAppliedPolicies(policyKey string) ([]hcnpkg.RuleSet,
 	return []hcnpkg.RuleSet{{EndpointID: "mock-endpoint"}}, len(rules) > 0
 }
 
-func (m *mockHCNManager) ListTrackedPolicies() []string {
-	keys := make([]string, 0, len(m.appliedPolicies))
+func (m *mockHCNManager) ListTrackedPolicies() []hcnpkg.PolicyKey {
+	keys := make([]hcnpkg.PolicyKey, 0, len(m.appliedPolicies))
 	for key := range m.appliedPolicies {
 		keys = append(keys, key)
 	}
 	return keys
 }
 
+func (m *mockHCNManager) Reconcile() error {
+	m.reconcileCount++
+	return m.reconcileError
+}
+
 func TestReconcile_CreateNetworkPolicy(t *testing.T) {
 	// Setup scheme
 	scheme := runtime.NewScheme()
@@ -111,6 +132,7 @@ func TestReconcile_CreateNetworkPolicy(t *testing.T) {
 		Client:     fakeClient,
 		Scheme:     scheme,
 		HCNManager: mockHCN,
+		ApplyScope: ApplyScopeAllEndpoints,
 		NodeName:   "test-node",
 	}
 
@@ -132,7 +154,7 @@ func TestReconcile_CreateNetworkPolicy(t *testing.T) {
 	}
 
 	// Verify HCN rules were applied
-	policyKey := "default/test-policy"
+	policyKey := hcnpkg.PolicyKey("netpol/default/test-policy")
 	rules, exists := mockHCN.appliedPolicies[policyKey]
 	if !exists {
 		t.Fatal("Expected ACL rules to be applied")
@@ -164,7 +186,7 @@ func TestReconcile_DeleteNetworkPolicy(t *testing.T) {
 
 	// Create mock HCN manager with pre-existing policy
 	mockHCN := newMockHCNManager()
-	mockHCN.appliedPolicies["default/test-policy"] = []hcnpkg.ACLRule{
+	mockHCN.appliedPolicies["netpol/default/test-policy"] = []hcnpkg.ACLRule{
 		{
 			Name:      "test-rule",
 			Direction: hcnlib.DirectionTypeIn,
@@ -177,6 +199,7 @@ func TestReconcile_DeleteNetworkPolicy(t *testing.T) {
 		Client:     fakeClient,
 		Scheme:     scheme,
 		HCNManager: mockHCN,
+		ApplyScope: ApplyScopeAllEndpoints,
 		NodeName:   "test-node",
 	}
 
@@ -202,12 +225,12 @@ func TestReconcile_DeleteNetworkPolicy(t *testing.T) {
 		t.Fatalf("Expected 1 policy to be removed, got %d", len(mockHCN.removedPolicies))
 	}
 
-	if mockHCN.removedPolicies[0] != "default/test-policy" {
+	if mockHCN.removedPolicies[0] != "netpol/default/test-policy" {
 		t.Errorf("Expected policy default/test-policy to be removed, got %s", mockHCN.removedPolicies[0])
 	}
 
 	// Verify policy no longer exists in applied policies
-	if _, exists := mockHCN.appliedPolicies["default/test-policy"]; exists {
+	if _, exists := mockHCN.appliedPolicies["netpol/default/test-policy"]; exists {
 		t.Error("Policy should have been removed from applied policies")
 	}
 }
@@ -252,7 +275,7 @@ func TestReconcile_UpdateNetworkPolicy(t *testing.T) {
 
 	// Create mock HCN manager with existing rule
 	mockHCN := newMockHCNManager()
-	mockHCN.appliedPolicies["default/test-policy"] = []hcnpkg.ACLRule{
+	mockHCN.appliedPolicies["netpol/default/test-policy"] = []hcnpkg.ACLRule{
 		{
 			Name:      "old-rule",
 			Direction: hcnlib.DirectionTypeIn,
@@ -265,6 +288,7 @@ func TestReconcile_UpdateNetworkPolicy(t *testing.T) {
 		Client:     fakeClient,
 		Scheme:     scheme,
 		HCNManager: mockHCN,
+		ApplyScope: ApplyScopeAllEndpoints,
 		NodeName:   "test-node",
 	}
 
@@ -286,7 +310,7 @@ func TestReconcile_UpdateNetworkPolicy(t *testing.T) {
 	}
 
 	// Verify HCN rules were updated (should have 2 rules now)
-	rules, exists := mockHCN.appliedPolicies["default/test-policy"]
+	rules, exists := mockHCN.appliedPolicies["netpol/default/test-policy"]
 	if !exists {
 		t.Fatal("Expected ACL rules to be applied")
 	}
@@ -329,6 +353,7 @@ func TestReconcile_ApplyError(t *testing.T) {
 		Client:     fakeClient,
 		Scheme:     scheme,
 		HCNManager: mockHCN,
+		ApplyScope: ApplyScopeAllEndpoints,
 		NodeName:   "test-node",
 	}
 
@@ -345,9 +370,161 @@ func TestReconcile_ApplyError(t *testing.T) {
 		t.Fatal("Expected error from Reconcile")
 	}
 
-	// Should requeue with delay
-	if result.RequeueAfter != 30*time.Second {
-		t.Errorf("Expected RequeueAfter 30s, got %v", result.RequeueAfter)
+	// The retry is left to the backoff of the controller's rate limiter
+	if result.RequeueAfter != 0 {
+		t.Errorf("Expected no RequeueAfter with an error, got %v", result.RequeueAfter)
+	}
+}
+
+func TestReconcile_ApplyErrorEmitsEvent(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = networkingv1.AddToScheme(scheme)
+
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-policy", Namespace: "default"},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(np).Build()
+
+	mockHCN := newMockHCNManager()
+	mockHCN.applyError = fmt.Errorf("endpoint ep-1: %w", syscall.Errno(0x803B000D))
+	recorder := record.NewFakeRecorder(1)
+
+	reconciler := &NetworkPolicyReconciler{
+		Client:     fakeClient,
+		Scheme:     scheme,
+		HCNManager: mockHCN,
+		ApplyScope: ApplyScopeAllEndpoints,
+		Recorder:   recorder,
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-policy", Namespace: "default"}}
+	if _, err := reconciler.Reconcile(context.Background(), req); err == nil {
+		t.Fatal("Expected error from Reconcile")
+	}
+
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, "HNSApplyFailed") || !strings.Contains(event, "HCN_E_INVALID_POLICY") {
+			t.Errorf("Unexpected event %q", event)
+		}
+	default:
+		t.Fatal("Expected a warning event")
+	}
+}
+
+func TestReconcile_StrictRejectsPolicy(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = networkingv1.AddToScheme(scheme)
+
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-policy", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From: []networkingv1.NetworkPolicyPeer{{
+					IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8", Except: []string{"10.1.0.0/16"}},
+				}},
+			}},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(np).Build()
+
+	mockHCN := newMockHCNManager()
+	mockHCN.appliedPolicies["netpol/default/test-policy"] = []hcnpkg.ACLRule{{Name: "stale"}}
+	recorder := record.NewFakeRecorder(1)
+	opts := converter.DefaultConversionOptions()
+	opts.Strict = true
+
+	reconciler := &NetworkPolicyReconciler{
+		Client:            fakeClient,
+		Scheme:            scheme,
+		HCNManager:        mockHCN,
+		ApplyScope:        ApplyScopeAllEndpoints,
+		Recorder:          recorder,
+		ConversionOptions: opts,
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-policy", Namespace: "default"}}
+	if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	if _, ok := mockHCN.appliedPolicies["netpol/default/test-policy"]; ok {
+		t.Error("Expected the rules of the rejected policy to be removed")
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, "PolicyRejected") || !strings.Contains(event, "except-ignored") {
+			t.Errorf("Unexpected event %q", event)
+		}
+	default:
+		t.Fatal("Expected a PolicyRejected event")
+	}
+}
+
+// recordingNotifier keeps the events it receives
+type recordingNotifier struct {
+	events []notify.Event
+}
+
+func (n *recordingNotifier) Notify(event notify.Event) {
+	n.events = append(n.events, event)
+}
+
+func TestReconcile_Notifies(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = networkingv1.AddToScheme(scheme)
+
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-policy", Namespace: "default", Generation: 2},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{}},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(np).Build()
+
+	mockHCN := newMockHCNManager()
+	notifier := &recordingNotifier{}
+	reconciler := &NetworkPolicyReconciler{
+		Client:            fakeClient,
+		Scheme:            scheme,
+		HCNManager:        mockHCN,
+		ApplyScope:        ApplyScopeAllEndpoints,
+		NodeName:          "node-1",
+		ConversionOptions: converter.DefaultConversionOptions(),
+		Notifier:          notifier,
+	}
+
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-policy", Namespace: "default"}}
+	if _, err := reconciler.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	mockHCN.applyError = fmt.Errorf("endpoint unavailable")
+	if _, err := reconciler.Reconcile(ctx, req); err == nil {
+		t.Fatal("Expected error from Reconcile")
+	}
+	if err := fakeClient.Delete(ctx, np); err != nil {
+		t.Fatalf("Failed to delete policy: %v", err)
+	}
+	if _, err := reconciler.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	want := []notify.EventType{notify.EventApplied, notify.EventFailed, notify.EventRemoved}
+	if len(notifier.events) != len(want) {
+		t.Fatalf("Expected events %v, got %+v", want, notifier.events)
+	}
+	for i, event := range notifier.events {
+		if event.Type != want[i] || event.PolicyKey != "netpol/default/test-policy" || event.Node != "node-1" ||
+			event.Namespace != "default" || event.Name != "test-policy" {
+			t.Errorf("Unexpected event %d: %+v", i, event)
+		}
+	}
+	if applied := notifier.events[0]; applied.RuleCount == 0 || applied.Generation != 2 {
+		t.Errorf("Expected the applied event to describe the programmed rules, got %+v", applied)
+	}
+	if failed := notifier.events[1]; failed.Reason != "HNSApplyFailed" || failed.Error == "" {
+		t.Errorf("Expected the failure reason and error, got %+v", failed)
 	}
 }
 
@@ -362,6 +539,7 @@ func TestSetupWithManager(t *testing.T) {
 		Client:     nil,
 		Scheme:     scheme,
 		HCNManager: newMockHCNManager(),
+		ApplyScope: ApplyScopeAllEndpoints,
 		NodeName:   "test-node",
 	}
 
@@ -412,7 +590,587 @@ func TestNewNetworkPolicyReconciler(t *testing.T) {
 	}
 }
 
+func TestReconcile_SameNamespacePeer(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "same-ns",
+			Namespace:   "default",
+			Annotations: map[string]string{converter.SameNamespaceAnnotation: "ingress"},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Status: corev1.PodStatus{
+			Phase:  corev1.PodRunning,
+			PodIPs: []corev1.PodIP{{IP: "10.0.0.5"}},
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(np, pod).
+		Build()
+
+	mockHCN := newMockHCNManager()
+	reconciler := &NetworkPolicyReconciler{
+		Client:     fakeClient,
+		Scheme:     scheme,
+		HCNManager: mockHCN,
+		ApplyScope: ApplyScopeAllEndpoints,
+		NodeName:   "test-node",
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "same-ns", Namespace: "default"}}
+	if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	rules := mockHCN.appliedPolicies["netpol/default/same-ns"]
+	if len(rules) != 1 || rules[0].RemoteAddresses != "10.0.0.5" {
+		t.Fatalf("Expected one rule allowing the namespace pod, got %+v", rules)
+	}
+
+	// Pod events requeue only annotated policies in the pod's namespace
+	requests := reconciler.policiesForPod(context.Background(), pod, false)
+	if len(requests) != 1 || requests[0].Name != "same-ns" {
+		t.Errorf("Expected pod to requeue same-ns, got %v", requests)
+	}
+}
+
 // Helper function to create a protocol pointer
-func protoPtr(p string) *string {
+func protoPtr(p corev1.Protocol) *corev1.Protocol {
 	return &p
 }
+
+// interruptibleHCNManager reports every apply as interrupted by its deadline
+type interruptibleHCNManager struct {
+	*mockHCNManager
+	deadline bool
+}
+
+func (m *interruptibleHCNManager) ApplyACLRulesContext(ctx context.Context, policyKey string, rules []hcnpkg.ACLRule) error {
+	_, m.deadline = ctx.Deadline()
+	return fmt.Errorf("%w: 1/2 endpoints not reached", hcnpkg.ErrApplyInterrupted)
+}
+
+func TestReconcile_NamespacePeerAggregation(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "aggregated",
+			Namespace: "default",
+			Annotations: map[string]string{
+				converter.SameNamespaceAnnotation:   "ingress",
+				converter.PeerAggregationAnnotation: "namespace",
+			},
+		},
+	}
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "default",
+			Annotations: map[string]string{converter.NamespacePodCIDRsAnnotation: "10.0.0.0/24"},
+		},
+	}
+	objects := []client.Object{np, namespace}
+	for i, ip := range []string{"10.0.0.5", "10.0.0.6"} {
+		objects = append(objects, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("web-%d", i), Namespace: "default"},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIPs: []corev1.PodIP{{IP: ip}}},
+		})
+	}
+
+	mockHCN := newMockHCNManager()
+	reconciler := &NetworkPolicyReconciler{
+		Client:     fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		Scheme:     scheme,
+		HCNManager: mockHCN,
+		ApplyScope: ApplyScopeAllEndpoints,
+		NodeName:   "test-node",
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "aggregated", Namespace: "default"}}
+	if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	rules := mockHCN.appliedPolicies["netpol/default/aggregated"]
+	if len(rules) != 1 || rules[0].RemoteAddresses != "10.0.0.0/24" {
+		t.Fatalf("Expected one rule allowing the namespace pod CIDR, got %+v", rules)
+	}
+}
+
+func TestReconcile_InterruptedApplyRequeues(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = networkingv1.AddToScheme(scheme)
+
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "large", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(np).Build()
+
+	hcnManager := &interruptibleHCNManager{mockHCNManager: newMockHCNManager()}
+	reconciler := &NetworkPolicyReconciler{
+		Client:       fakeClient,
+		Scheme:       scheme,
+		HCNManager:   hcnManager,
+		ApplyScope:   ApplyScopeAllEndpoints,
+		NodeName:     "test-node",
+		ApplyTimeout: time.Minute,
+	}
+
+	result, err := reconciler.Reconcile(context.Background(), ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: "large", Namespace: "default"},
+	})
+	if err != nil {
+		t.Fatalf("Expected an interrupted apply not to be an error, got %v", err)
+	}
+	if !result.Requeue || result.RequeueAfter != 0 {
+		t.Errorf("Expected an immediate requeue, got %+v", result)
+	}
+	if !hcnManager.deadline {
+		t.Error("Expected the apply to be bounded by ApplyTimeout")
+	}
+}
+
+func TestReconcile_LargeNamespacePeer(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+
+	web := map[string]string{"app": "web"}
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "from-web", Namespace: "large"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: web}}},
+			}},
+		},
+	}
+	objects := []client.Object{np}
+	for i := 0; i < 5000; i++ {
+		objects = append(objects, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("web-%d", i), Namespace: "large", Labels: web},
+			Status: corev1.PodStatus{
+				Phase:  corev1.PodRunning,
+				PodIPs: []corev1.PodIP{{IP: fmt.Sprintf("10.244.%d.%d", i/250, i%250+1)}},
+			},
+		})
+	}
+
+	mockHCN := newMockHCNManager()
+	opts := converter.DefaultConversionOptions()
+	opts.MaxRemoteAddresses = 1000
+	reconciler := &NetworkPolicyReconciler{
+		Client:            fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		Scheme:            scheme,
+		HCNManager:        mockHCN,
+		ApplyScope:        ApplyScopeAllEndpoints,
+		NodeName:          "test-node",
+		ConversionOptions: opts,
+		PeerResolver:      peers.InformerResolver{},
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "from-web", Namespace: "large"}}
+	if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	rules := mockHCN.appliedPolicies["netpol/large/from-web"]
+	if len(rules) != 5 {
+		t.Fatalf("Expected the 5000 pods to be split into 5 rules, got %d", len(rules))
+	}
+	for _, rule := range rules {
+		if got := strings.Count(rule.RemoteAddresses, ",") + 1; got != 1000 {
+			t.Errorf("Expected 1000 addresses in rule %d, got %d", rule.Priority, got)
+		}
+	}
+}
+
+func TestPodEventHandler_Delay(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "same-ns",
+			Namespace:   "default",
+			Annotations: map[string]string{converter.SameNamespaceAnnotation: "ingress"},
+		},
+	}
+	reconciler := &NetworkPolicyReconciler{
+		Client:        fake.NewClientBuilder().WithScheme(scheme).WithObjects(np).Build(),
+		Scheme:        scheme,
+		HCNManager:    newMockHCNManager(),
+		ApplyScope:    ApplyScopeAllEndpoints,
+		NodeName:      "test-node",
+		PodEventDelay: 50 * time.Millisecond,
+	}
+
+	q := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer q.ShutDown()
+	handler := reconciler.podEventHandler()
+	for i := 0; i < 100; i++ {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("web-%d", i), Namespace: "default"}}
+		handler.Create(context.Background(), event.CreateEvent{Object: pod}, q)
+	}
+	if q.Len() != 0 {
+		t.Fatalf("Expected pod events to be held back, got %d queued", q.Len())
+	}
+
+	// The 100 events collapse into a single reconcile once the delay passes
+	deadline := time.Now().Add(5 * time.Second)
+	for q.Len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if q.Len() != 1 {
+		t.Errorf("Expected one queued reconcile, got %d", q.Len())
+	}
+}
+
+func TestReconcile_ApplyScope(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Ingress:     []networkingv1.NetworkPolicyIngressRule{{}},
+		},
+	}
+	// The fake HCN endpoints own 10.244.0.2, 10.244.0.3 and 10.244.0.4
+	web := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: "default", Labels: map[string]string{"app": "web"}},
+		Spec:       corev1.PodSpec{NodeName: "test-node"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIPs: []corev1.PodIP{{IP: "10.244.0.2"}}},
+	}
+	db := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "default", Labels: map[string]string{"app": "db"}},
+		Spec:       corev1.PodSpec{NodeName: "test-node"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIPs: []corev1.PodIP{{IP: "10.244.0.3"}}},
+	}
+	// A selected pod of another node is not programmed here, even if its IP
+	// shows up on a local endpoint
+	remoteWeb := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default", Labels: map[string]string{"app": "web"}},
+		Spec:       corev1.PodSpec{NodeName: "other-node"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIPs: []corev1.PodIP{{IP: "10.244.0.4"}}},
+	}
+
+	tests := []struct {
+		scope     ApplyScope
+		endpoints []string
+	}{
+		{scope: "", endpoints: []string{"fake-endpoint-0"}},
+		{scope: ApplyScopeAllEndpoints, endpoints: []string{"fake-endpoint-0", "fake-endpoint-1", "fake-endpoint-2"}},
+		{scope: ApplyScopeSelector, endpoints: []string{"fake-endpoint-0"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.scope), func(t *testing.T) {
+			manager := hcnpkg.NewManager(hcnpkg.NewFakeClient(3), logr.Discard())
+			reconciler := &NetworkPolicyReconciler{
+				Client:            fake.NewClientBuilder().WithScheme(scheme).WithObjects(np, web, db, remoteWeb).Build(),
+				Scheme:            scheme,
+				HCNManager:        manager,
+				NodeName:          "test-node",
+				ConversionOptions: converter.DefaultConversionOptions(),
+				ApplyScope:        tt.scope,
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "web", Namespace: "default"}}
+			if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+				t.Fatalf("Reconcile failed: %v", err)
+			}
+			ruleSets, _ := manager.GetAppliedPolicies("netpol/default/web")
+			var programmed []string
+			for _, ruleSet := range ruleSets {
+				programmed = append(programmed, ruleSet.EndpointID)
+			}
+			if fmt.Sprint(programmed) != fmt.Sprint(tt.endpoints) {
+				t.Errorf("Expected the rules on %v, got %v", tt.endpoints, programmed)
+			}
+		})
+	}
+}
+
+func TestReconcile_PriorityAllocation(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = networkingv1.AddToScheme(scheme)
+
+	policy := func(name string) *networkingv1.NetworkPolicy {
+		return &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: networkingv1.NetworkPolicySpec{
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{
+					Ports: []networkingv1.NetworkPolicyPort{{
+						Protocol: protoPtr("TCP"),
+						Port:     &intstr.IntOrString{Type: intstr.Int, IntVal: 80},
+					}},
+				}},
+			},
+		}
+	}
+	allocator, err := hcnpkg.NewPriorityAllocator(hcnpkg.PriorityRange{Start: 100, End: hcnpkg.MaxPriority}, nil, 16)
+	if err != nil {
+		t.Fatalf("NewPriorityAllocator failed: %v", err)
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(policy("web"), policy("db")).Build()
+	mockHCN := newMockHCNManager()
+	reconciler := &NetworkPolicyReconciler{
+		Client:            fakeClient,
+		Scheme:            scheme,
+		HCNManager:        mockHCN,
+		ApplyScope:        ApplyScopeAllEndpoints,
+		NodeName:          "test-node",
+		ConversionOptions: converter.DefaultConversionOptions(),
+		Priorities:        allocator,
+	}
+
+	for _, name := range []string{"web", "db"} {
+		req := ctrl.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: "default"}}
+		if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("Reconcile failed: %v", err)
+		}
+	}
+	// Each policy draws from a block of its own instead of both starting at 100
+	if got := mockHCN.appliedPolicies["netpol/default/web"][0].Priority; got != 100 {
+		t.Errorf("Expected default/web at priority 100, got %d", got)
+	}
+	if got := mockHCN.appliedPolicies["netpol/default/db"][0].Priority; got != 116 {
+		t.Errorf("Expected default/db at priority 116, got %d", got)
+	}
+
+	// Deleting a policy reclaims its range
+	if err := fakeClient.Delete(context.Background(), policy("web")); err != nil {
+		t.Fatal(err)
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "web", Namespace: "default"}}
+	if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if _, found := allocator.Ranges()["netpol/default/web"]; found {
+		t.Error("Expected the range of netpol/default/web released")
+	}
+}
+
+func TestPodEventHandler_ApplyScopeSelector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       networkingv1.NetworkPolicySpec{PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}},
+	}
+	reconciler := &NetworkPolicyReconciler{
+		Client:     fake.NewClientBuilder().WithScheme(scheme).WithObjects(np).Build(),
+		Scheme:     scheme,
+		HCNManager: newMockHCNManager(),
+		ApplyScope: ApplyScopeAllEndpoints,
+		NodeName:   "test-node",
+	}
+	web := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: "default", Labels: map[string]string{"app": "web"}},
+		Spec:       corev1.PodSpec{NodeName: "test-node"},
+	}
+	relabelled := web.DeepCopy()
+	relabelled.Labels["app"] = "api"
+
+	// Selected pods only requeue the policy when rules follow the selector
+	if requests := reconciler.policiesForPod(context.Background(), web, false); len(requests) != 0 {
+		t.Fatalf("Expected no requeue with the all-endpoints scope, got %v", requests)
+	}
+	reconciler.ApplyScope = ApplyScopeSelector
+	if requests := reconciler.policiesForPod(context.Background(), web, false); len(requests) != 1 {
+		t.Fatalf("Expected the selecting policy to be requeued, got %v", requests)
+	}
+	remote := web.DeepCopy()
+	remote.Spec.NodeName = "other-node"
+	if requests := reconciler.policiesForPod(context.Background(), remote, false); len(requests) != 0 {
+		t.Fatalf("Expected no requeue for a pod of another node, got %v", requests)
+	}
+
+	// A pod relabelled out of the selector must leave the policy's targets
+	q := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer q.ShutDown()
+	reconciler.podEventHandler().Update(context.Background(), event.UpdateEvent{ObjectOld: web, ObjectNew: relabelled}, q)
+	if q.Len() != 1 {
+		t.Errorf("Expected the policy that selected the old labels to be requeued, got %d", q.Len())
+	}
+}
+
+func TestPoliciesForPod_NamespaceSelectorPeer(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "from-monitoring", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From: []networkingv1.NetworkPolicyPeer{{
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "observability"}},
+				}},
+			}},
+		},
+	}
+	monitoring := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "monitoring", Labels: map[string]string{"team": "observability"}}}
+	batch := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "batch"}}
+	prometheus := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "prometheus-0", Namespace: "monitoring"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIPs: []corev1.PodIP{{IP: "10.1.0.1"}}},
+	}
+
+	mockHCN := newMockHCNManager()
+	reconciler := &NetworkPolicyReconciler{
+		Client:            fake.NewClientBuilder().WithScheme(scheme).WithObjects(np, monitoring, batch, prometheus).Build(),
+		Scheme:            scheme,
+		HCNManager:        mockHCN,
+		ApplyScope:        ApplyScopeAllEndpoints,
+		NodeName:          "test-node",
+		ConversionOptions: converter.DefaultConversionOptions(),
+		PeerResolver:      peers.InformerResolver{},
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "from-monitoring", Namespace: "default"}}
+	if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if rules := mockHCN.appliedPolicies["netpol/default/from-monitoring"]; len(rules) != 1 || rules[0].RemoteAddresses != "10.1.0.1" {
+		t.Fatalf("Expected one rule allowing the monitoring pod, got %+v", rules)
+	}
+
+	// Pods of selected namespaces requeue the policy; others do not
+	if requests := reconciler.policiesForPod(context.Background(), prometheus, false); len(requests) != 1 {
+		t.Errorf("Expected the pod to requeue from-monitoring, got %v", requests)
+	}
+	job := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "job-0", Namespace: "batch"}}
+	if requests := reconciler.policiesForPod(context.Background(), job, false); len(requests) != 0 {
+		t.Errorf("Expected no requeue for a pod outside the selected namespaces, got %v", requests)
+	}
+}
+
+func TestNamespaceEventHandler_LabelChanges(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+
+	fromTeam := func(name, team string) *networkingv1.NetworkPolicy {
+		return &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: networkingv1.NetworkPolicySpec{
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{
+					From: []networkingv1.NetworkPolicyPeer{{
+						NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": team}},
+					}},
+				}},
+			},
+		}
+	}
+	reconciler := &NetworkPolicyReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(fromTeam("from-a", "a"), fromTeam("from-b", "b"), fromTeam("from-c", "c")).Build(),
+		Scheme:       scheme,
+		HCNManager:   newMockHCNManager(),
+		ApplyScope:   ApplyScopeAllEndpoints,
+		NodeName:     "test-node",
+		PeerResolver: peers.InformerResolver{},
+	}
+
+	q := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer q.ShutDown()
+	queued := func() []string {
+		var names []string
+		for q.Len() > 0 {
+			request, _ := q.Get()
+			names = append(names, request.Name)
+			q.Done(request)
+		}
+		slices.Sort(names)
+		return names
+	}
+	handler := reconciler.namespaceEventHandler()
+	ctx := context.Background()
+
+	oldNamespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "apps", Labels: map[string]string{"team": "a"}}}
+	newNamespace := oldNamespace.DeepCopy()
+	newNamespace.Labels["team"] = "b"
+	handler.Update(ctx, event.UpdateEvent{ObjectOld: oldNamespace, ObjectNew: newNamespace}, q)
+	if got := queued(); !slices.Equal(got, []string{"from-a", "from-b"}) {
+		t.Errorf("Expected the policies selecting the old and new labels requeued, got %v", got)
+	}
+
+	// Updates leaving the labels alone do not recompute policies
+	annotated := newNamespace.DeepCopy()
+	annotated.Annotations = map[string]string{"owner": "platform"}
+	handler.Update(ctx, event.UpdateEvent{ObjectOld: newNamespace, ObjectNew: annotated}, q)
+	if got := queued(); len(got) != 0 {
+		t.Errorf("Expected no policy requeued, got %v", got)
+	}
+
+	handler.Delete(ctx, event.DeleteEvent{Object: annotated}, q)
+	if got := queued(); !slices.Equal(got, []string{"from-b"}) {
+		t.Errorf("Expected the policy selecting the deleted namespace requeued, got %v", got)
+	}
+}
+
+func TestNamespaceEventHandler_PodCIDRsChange(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+
+	fromWeb := func(name string, annotations map[string]string) *networkingv1.NetworkPolicy {
+		return &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "apps", Annotations: annotations},
+			Spec: networkingv1.NetworkPolicySpec{
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{
+					From: []networkingv1.NetworkPolicyPeer{{
+						PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+					}},
+				}},
+			},
+		}
+	}
+	reconciler := &NetworkPolicyReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			fromWeb("aggregated", map[string]string{converter.PeerAggregationAnnotation: "namespace"}),
+			fromWeb("exact", nil),
+		).Build(),
+		Scheme:     scheme,
+		HCNManager: newMockHCNManager(),
+		ApplyScope: ApplyScopeAllEndpoints,
+		NodeName:   "test-node",
+	}
+
+	q := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer q.ShutDown()
+
+	// Policies with only podSelector peers aggregate their own namespace's CIDRs
+	oldNamespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "apps",
+		Annotations: map[string]string{converter.NamespacePodCIDRsAnnotation: "10.0.0.0/24"},
+	}}
+	newNamespace := oldNamespace.DeepCopy()
+	newNamespace.Annotations[converter.NamespacePodCIDRsAnnotation] = "10.0.1.0/24"
+	reconciler.namespaceEventHandler().Update(context.Background(), event.UpdateEvent{ObjectOld: oldNamespace, ObjectNew: newNamespace}, q)
+
+	if q.Len() != 1 {
+		t.Fatalf("Expected only the aggregated policy requeued, got %d requests", q.Len())
+	}
+	if request, _ := q.Get(); request.Name != "aggregated" {
+		t.Errorf("Expected the aggregated policy requeued, got %v", request)
+	}
+}
@@ -0,0 +1,45 @@
+//go:build windows
+
+package controller
+
+import (
+	"time"
+
+	networkingv1 "k8s.io/api/networking/v1"
+
+	"github.com/knabben/firewall-controller/internal/converter"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	"github.com/knabben/firewall-controller/internal/notify"
+)
+
+// notify sends an enforcement event for the policy of policyKey to the
+// Notifier, if any. np is nil for removals, when only the key is known.
+func (r *NetworkPolicyReconciler) notify(eventType notify.EventType, np *networkingv1.NetworkPolicy, policyKey hcnpkg.PolicyKey, ruleCount int, reason string, err error) {
+	if r.Notifier == nil {
+		return
+	}
+
+	name, _ := r.policyName(policyKey)
+	event := notify.Event{
+		Type:      eventType,
+		Time:      time.Now().UTC(),
+		Node:      r.NodeName,
+		Source:    r.SourceName,
+		PolicyKey: string(policyKey),
+		Namespace: name.Namespace,
+		Name:      name.Name,
+		RuleCount: ruleCount,
+		Reason:    reason,
+	}
+	if np != nil {
+		event.Generation = np.Generation
+		event.ResourceVersion = np.ResourceVersion
+		if modification, found := converter.LastModification(np); found {
+			event.ModifiedBy = modification.Manager
+		}
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	r.Notifier.Notify(event)
+}
@@ -0,0 +1,100 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// OrphanCleaner removes, once on startup, the rules of NetworkPolicies deleted
+// while the agent was not running. The HCN Manager tracks the endpoint ACLs a
+// previous run programmed, restored from the priority store, and those whose
+// removal failed; no delete event will ever arrive for their policies, and
+// without the cleaner they would stay enforced until the first periodic resync.
+type OrphanCleaner struct {
+	reconciler    *NetworkPolicyReconciler
+	reader        client.Reader
+	retryInterval time.Duration
+	logger        logr.Logger
+}
+
+// NewOrphanCleaner creates a cleaner for the policies of r. reader must read
+// from the API server rather than an informer cache; retryInterval is how long
+// the cleaner waits before trying again when the API server is unreachable.
+func NewOrphanCleaner(r *NetworkPolicyReconciler, reader client.Reader, retryInterval time.Duration,
+	logger logr.Logger) *OrphanCleaner {
+	return &OrphanCleaner{
+		reconciler:    r,
+		reader:        reader,
+		retryInterval: retryInterval,
+		logger:        logger,
+	}
+}
+
+// Start cleans up orphaned rules, retrying until a cleanup completes or the
+// context is cancelled. It implements the controller-runtime Runnable interface.
+func (c *OrphanCleaner) Start(ctx context.Context) error {
+	for {
+		_, err := c.Cleanup(ctx)
+		if err == nil {
+			return nil
+		}
+		c.logger.Error(err, "Orphaned rule cleanup failed")
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(c.retryInterval):
+		}
+	}
+}
+
+// NeedLeaderElection implements LeaderElectionRunnable; every node cleans up its own endpoints
+func (c *OrphanCleaner) NeedLeaderElection() bool {
+	return false
+}
+
+// Cleanup lists the NetworkPolicies from the API server and removes the rules
+// tracked for every policy of this source that no longer exists. It returns
+// the removed keys.
+func (c *OrphanCleaner) Cleanup(ctx context.Context) ([]hcnpkg.PolicyKey, error) {
+	var policies networkingv1.NetworkPolicyList
+	if err := c.reader.List(ctx, &policies); err != nil {
+		return nil, fmt.Errorf("failed to list NetworkPolicies: %w", err)
+	}
+	existing := make(map[hcnpkg.PolicyKey]bool, len(policies.Items))
+	for _, np := range policies.Items {
+		existing[c.reconciler.policyKey(types.NamespacedName{Namespace: np.Namespace, Name: np.Name})] = true
+	}
+
+	var removed []hcnpkg.PolicyKey
+	var removeErrors []error
+	for _, key := range c.reconciler.HCNManager.ListTrackedPolicies() {
+		if existing[key] || !c.reconciler.ownsKey(key) {
+			continue
+		}
+		c.logger.Info("Removing orphaned rules of NetworkPolicy deleted while the agent was down", "policyKey", key)
+		if err := c.reconciler.HCNManager.RemoveACLRules(key); err != nil {
+			removeErrors = append(removeErrors, fmt.Errorf("policy %s: %w", key, err))
+			continue
+		}
+		recordWarnings(key, nil)
+		c.reconciler.releasePriorities(key)
+		removed = append(removed, key)
+	}
+
+	c.logger.Info("Orphaned rule cleanup complete",
+		"policyCount", len(policies.Items),
+		"removedCount", len(removed))
+	return removed, errors.Join(removeErrors...)
+}
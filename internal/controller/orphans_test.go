@@ -0,0 +1,88 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/go-logr/logr"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+func TestOrphanCleaner_Cleanup(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = networkingv1.AddToScheme(scheme)
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "kept", Namespace: "default"}},
+	).Build()
+
+	tests := []struct {
+		name    string
+		source  string
+		tracked []hcnpkg.PolicyKey
+		removed []hcnpkg.PolicyKey
+	}{
+		{
+			name:    "local policies deleted while the agent was down",
+			tracked: []hcnpkg.PolicyKey{"netpol/default/kept", "netpol/default/deleted", "netpol/remote/default/kept"},
+			removed: []hcnpkg.PolicyKey{"netpol/default/deleted"},
+		},
+		{
+			name:    "only the reconciler's own source",
+			source:  "remote",
+			tracked: []hcnpkg.PolicyKey{"netpol/default/deleted", "netpol/remote/default/kept", "netpol/remote/default/deleted"},
+			removed: []hcnpkg.PolicyKey{"netpol/remote/default/deleted"},
+		},
+		{
+			name:    "rules of other sources and unclaimed ACLs",
+			tracked: []hcnpkg.PolicyKey{"static/deleted", "anp/deleted", hcnpkg.UnclaimedPolicyKey},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hcnManager := newMockHCNManager()
+			for _, key := range tt.tracked {
+				hcnManager.appliedPolicies[key] = nil
+			}
+			reconciler := &NetworkPolicyReconciler{HCNManager: hcnManager, SourceName: tt.source}
+			cleaner := NewOrphanCleaner(reconciler, reader, 0, logr.Discard())
+
+			removed, err := cleaner.Cleanup(context.Background())
+			if err != nil {
+				t.Fatalf("Cleanup failed: %v", err)
+			}
+			slices.Sort(removed)
+			if !slices.Equal(removed, tt.removed) || !slices.Equal(hcnManager.removedPolicies, tt.removed) {
+				t.Errorf("Expected %v removed, got %v (manager %v)", tt.removed, removed, hcnManager.removedPolicies)
+			}
+		})
+	}
+}
+
+func TestOrphanCleaner_RemoveFailure(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = networkingv1.AddToScheme(scheme)
+	reader := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	hcnManager := newMockHCNManager()
+	hcnManager.appliedPolicies["netpol/default/deleted"] = nil
+	hcnManager.removeError = errors.New("HNS unavailable")
+	cleaner := NewOrphanCleaner(&NetworkPolicyReconciler{HCNManager: hcnManager}, reader, 0, logr.Discard())
+
+	removed, err := cleaner.Cleanup(context.Background())
+	if err == nil {
+		t.Fatal("Expected the failed removal reported")
+	}
+	if len(removed) != 0 {
+		t.Errorf("Expected nothing removed, got %v", removed)
+	}
+}
@@ -0,0 +1,129 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// EnforcementAnnotation set to EnforcementPaused on a pod lifts the agent's
+// rules from the pod's endpoint, for debugging a workload without stopping
+// the agent
+const EnforcementAnnotation = "networking.knabben.github.io/enforcement"
+
+// EnforcementPaused is the EnforcementAnnotation value pausing enforcement
+const EnforcementPaused = "paused"
+
+// EnforcementPausedFor reports whether pod asks for its enforcement to be paused
+func EnforcementPausedFor(pod *corev1.Pod) bool {
+	return pod.Annotations[EnforcementAnnotation] == EnforcementPaused
+}
+
+// PodEnforcementReconciler pauses enforcement on the endpoints of the pods on
+// this node carrying the EnforcementAnnotation, and resumes it once the
+// annotation is removed or the pod is deleted
+type PodEnforcementReconciler struct {
+	client.Client
+	HCNManager hcnpkg.EnforcementPauser
+	NodeName   string
+
+	// Recorder emits EnforcementPaused and EnforcementResumed events on the pod; nil disables events
+	Recorder record.EventRecorder
+
+	mu sync.Mutex
+
+	// paused maps pod -> the IP enforcement was paused for
+	paused map[types.NamespacedName]string
+}
+
+// Reconcile pauses or resumes enforcement on a pod's endpoint to match its annotation
+func (r *PodEnforcementReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var pod *corev1.Pod
+	var current corev1.Pod
+	if err := r.Get(ctx, req.NamespacedName, &current); err == nil {
+		pod = &current
+	} else if !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	wantIP := ""
+	if pod != nil && pod.DeletionTimestamp == nil && pod.Spec.NodeName == r.NodeName &&
+		!pod.Spec.HostNetwork && EnforcementPausedFor(pod) {
+		wantIP = pod.Status.PodIP
+	}
+
+	r.mu.Lock()
+	pausedIP := r.paused[req.NamespacedName]
+	r.mu.Unlock()
+	if pausedIP == wantIP {
+		return ctrl.Result{}, nil
+	}
+
+	if pausedIP != "" {
+		if err := r.HCNManager.SetEnforcementPaused(ctx, pausedIP, req.String(), false); err != nil {
+			logger.Error(err, "Failed to resume enforcement on pod endpoint", "podIP", pausedIP)
+			return ctrl.Result{}, err
+		}
+		r.setPaused(req.NamespacedName, "")
+		logger.Info("Resumed enforcement on pod endpoint", "podIP", pausedIP)
+		r.event(pod, "EnforcementResumed", "NetworkPolicy rules are programmed on the pod's endpoint again")
+	}
+	if wantIP != "" {
+		if err := r.HCNManager.SetEnforcementPaused(ctx, wantIP, req.String(), true); err != nil {
+			logger.Error(err, "Failed to pause enforcement on pod endpoint", "podIP", wantIP)
+			return ctrl.Result{}, err
+		}
+		r.setPaused(req.NamespacedName, wantIP)
+		logger.Info("Paused enforcement on pod endpoint", "podIP", wantIP)
+		r.event(pod, "EnforcementPaused", "No NetworkPolicy rules are programmed on the pod's endpoint while "+
+			EnforcementAnnotation+" is "+EnforcementPaused)
+	}
+	return ctrl.Result{}, nil
+}
+
+// setPaused records the IP enforcement is paused for on a pod; empty forgets the pod
+func (r *PodEnforcementReconciler) setPaused(name types.NamespacedName, ip string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.paused == nil {
+		r.paused = make(map[types.NamespacedName]string)
+	}
+	if ip == "" {
+		delete(r.paused, name)
+		return
+	}
+	r.paused[name] = ip
+}
+
+// event records a normal event on pod, if it still exists
+func (r *PodEnforcementReconciler) event(pod *corev1.Pod, reason, message string) {
+	if r.Recorder != nil && pod != nil {
+		r.Recorder.Event(pod, corev1.EventTypeNormal, reason, message)
+	}
+}
+
+// SetupWithManager sets up the controller to watch the pods of this node
+func (r *PodEnforcementReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	onNode := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		pod, ok := obj.(*corev1.Pod)
+		return ok && pod.Spec.NodeName == r.NodeName
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("pod-enforcement").
+		For(&corev1.Pod{}, builder.WithPredicates(onNode)).
+		Complete(r)
+}
@@ -0,0 +1,79 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// recordingPauser records the pause and resume calls it receives
+type recordingPauser struct {
+	calls []string
+}
+
+func (p *recordingPauser) SetEnforcementPaused(ctx context.Context, ip, pod string, paused bool) error {
+	p.calls = append(p.calls, fmt.Sprintf("%s %s %t", pod, ip, paused))
+	return nil
+}
+
+func TestPodEnforcementReconciler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "web-0",
+			Namespace:   "default",
+			Annotations: map[string]string{EnforcementAnnotation: EnforcementPaused},
+		},
+		Spec:   corev1.PodSpec{NodeName: "node-1"},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.244.0.2"},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod).Build()
+	pauser := &recordingPauser{}
+	reconciler := &PodEnforcementReconciler{Client: fakeClient, HCNManager: pauser, NodeName: "node-1"}
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "web-0"}}
+	reconcile := func() {
+		t.Helper()
+		if _, err := reconciler.Reconcile(ctx, req); err != nil {
+			t.Fatalf("Reconcile failed: %v", err)
+		}
+	}
+
+	// Reconciling an unchanged pod again makes no call
+	reconcile()
+	reconcile()
+
+	// A new IP moves the pause to it
+	pod.Status.PodIP = "10.244.0.9"
+	if err := fakeClient.Update(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+
+	// Deleting the pod resumes its last address
+	if err := fakeClient.Delete(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+
+	want := []string{
+		"default/web-0 10.244.0.2 true",
+		"default/web-0 10.244.0.2 false",
+		"default/web-0 10.244.0.9 true",
+		"default/web-0 10.244.0.9 false",
+	}
+	if fmt.Sprint(pauser.calls) != fmt.Sprint(want) {
+		t.Errorf("Expected calls %v, got %v", want, pauser.calls)
+	}
+}
@@ -0,0 +1,222 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"maps"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/knabben/firewall-controller/internal/converter"
+	"github.com/knabben/firewall-controller/internal/peers"
+)
+
+// peerWatch returns the kind of object the peer resolver follows, or nil
+func (r *NetworkPolicyReconciler) peerWatch() client.Object {
+	watcher, ok := r.PeerResolver.(peers.ObjectWatcher)
+	if !ok {
+		return nil
+	}
+	return watcher.WatchObject()
+}
+
+// peerObjectWatch returns the kind of object, other than pods which are always
+// watched, whose changes alter resolved peers, or nil
+func (r *NetworkPolicyReconciler) peerObjectWatch() client.Object {
+	obj := r.peerWatch()
+	if _, isPod := obj.(*corev1.Pod); isPod {
+		return nil
+	}
+	return obj
+}
+
+// peerEventHandler requeues the NetworkPolicies with selector peers in the
+// namespace of a changed object the peer resolver follows, and those in other
+// namespaces selecting its namespace
+func (r *NetworkPolicyReconciler) peerEventHandler() handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		var policies networkingv1.NetworkPolicyList
+		if err := r.List(ctx, &policies, client.InNamespace(obj.GetNamespace())); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to list NetworkPolicies for peer change",
+				"namespace", obj.GetNamespace(),
+				"name", obj.GetName())
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for i := range policies.Items {
+			if peers.HasSelectorPeers(&policies.Items[i]) {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Namespace: policies.Items[i].Namespace, Name: policies.Items[i].Name},
+				})
+			}
+		}
+		return append(requests, r.policiesSelectingNamespace(ctx, obj.GetNamespace())...)
+	})
+}
+
+// policiesSelectingNamespace returns the NetworkPolicies outside namespace
+// whose namespaceSelector peers select it
+func (r *NetworkPolicyReconciler) policiesSelectingNamespace(ctx context.Context, namespace string) []reconcile.Request {
+	logger := log.FromContext(ctx)
+	var ns corev1.Namespace
+	if err := r.Get(ctx, client.ObjectKey{Name: namespace}, &ns); err != nil {
+		if !apierrors.IsNotFound(err) {
+			logger.Error(err, "Failed to get Namespace for peer change", "namespace", namespace)
+		}
+		return nil
+	}
+
+	var policies networkingv1.NetworkPolicyList
+	if err := r.List(ctx, &policies, client.UnsafeDisableDeepCopy); err != nil {
+		logger.Error(err, "Failed to list NetworkPolicies selecting namespace", "namespace", namespace)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range policies.Items {
+		policy := &policies.Items[i]
+		if policy.Namespace != namespace && peers.SelectsNamespace(policy, &ns) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: policy.Namespace, Name: policy.Name},
+			})
+		}
+	}
+	return requests
+}
+
+// namespaceEventHandler requeues the NetworkPolicies whose namespaceSelector
+// peers select a Namespace before or after it changes, and when its pod CIDRs
+// annotation changes, the policies inside it using the namespace peer
+// aggregation. Updates leaving the labels and the pod CIDRs annotation alone
+// are ignored, so status and unrelated metadata changes do not recompute every
+// policy.
+func (r *NetworkPolicyReconciler) namespaceEventHandler() handler.EventHandler {
+	enqueue := func(ctx context.Context, q workqueue.TypedRateLimitingInterface[reconcile.Request], namespaces ...*corev1.Namespace) {
+		for _, request := range r.policiesForNamespaces(ctx, namespaces...) {
+			q.Add(request)
+		}
+	}
+	asNamespace := func(obj client.Object) *corev1.Namespace {
+		namespace, _ := obj.(*corev1.Namespace)
+		return namespace
+	}
+
+	return handler.Funcs{
+		CreateFunc: func(ctx context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, q, asNamespace(e.Object))
+		},
+		UpdateFunc: func(ctx context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			oldNamespace, newNamespace := asNamespace(e.ObjectOld), asNamespace(e.ObjectNew)
+			if oldNamespace == nil || newNamespace == nil {
+				return
+			}
+			cidrsChanged := oldNamespace.Annotations[converter.NamespacePodCIDRsAnnotation] != newNamespace.Annotations[converter.NamespacePodCIDRsAnnotation]
+			if maps.Equal(oldNamespace.Labels, newNamespace.Labels) && !cidrsChanged {
+				return
+			}
+			// Policies that selected the namespace before the change must drop its pods
+			enqueue(ctx, q, oldNamespace, newNamespace)
+			if cidrsChanged {
+				// The aggregation also covers the policy's own namespace
+				for _, request := range r.policiesAggregatingNamespace(ctx, newNamespace.Name) {
+					q.Add(request)
+				}
+			}
+		},
+		DeleteFunc: func(ctx context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, q, asNamespace(e.Object))
+		},
+		GenericFunc: func(ctx context.Context, e event.GenericEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, q, asNamespace(e.Object))
+		},
+	}
+}
+
+// policiesForNamespaces returns the NetworkPolicies whose namespaceSelector
+// peers select any of namespaces, each once
+func (r *NetworkPolicyReconciler) policiesForNamespaces(ctx context.Context, namespaces ...*corev1.Namespace) []reconcile.Request {
+	var policies networkingv1.NetworkPolicyList
+	if err := r.List(ctx, &policies, client.UnsafeDisableDeepCopy); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list NetworkPolicies for namespace change")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range policies.Items {
+		policy := &policies.Items[i]
+		if !peers.HasNamespaceSelectorPeers(policy) {
+			continue
+		}
+		for _, namespace := range namespaces {
+			if namespace != nil && peers.SelectsNamespace(policy, namespace) {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Namespace: policy.Namespace, Name: policy.Name},
+				})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// policiesAggregatingNamespace returns the NetworkPolicies in namespace using
+// the namespace peer aggregation, whose rules list its pod CIDRs
+func (r *NetworkPolicyReconciler) policiesAggregatingNamespace(ctx context.Context, namespace string) []reconcile.Request {
+	var policies networkingv1.NetworkPolicyList
+	if err := r.List(ctx, &policies, client.InNamespace(namespace), client.UnsafeDisableDeepCopy); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list NetworkPolicies for pod CIDRs change", "namespace", namespace)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range policies.Items {
+		policy := &policies.Items[i]
+		if aggregation, err := converter.PeerAggregationOf(policy); err == nil && aggregation == converter.PeerAggregationNamespace {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: policy.Namespace, Name: policy.Name},
+			})
+		}
+	}
+	return requests
+}
+
+// namespacePodCIDRs returns the pod CIDRs of np's namespace and of the
+// namespaces its namespaceSelector peers select, for the namespace peer
+// aggregation. Namespaces with an invalid annotation are logged and skipped.
+func (r *NetworkPolicyReconciler) namespacePodCIDRs(ctx context.Context, np *networkingv1.NetworkPolicy) ([]string, error) {
+	namespaces := []string{np.Namespace}
+	if r.PeerResolver != nil {
+		selected, err := peers.SelectedNamespaces(ctx, r.Client, np)
+		if err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, selected...)
+	}
+
+	var cidrs []string
+	for _, name := range namespaces {
+		var namespace corev1.Namespace
+		if err := r.Get(ctx, client.ObjectKey{Name: name}, &namespace); err != nil {
+			return nil, err
+		}
+		namespaceCIDRs, err := converter.ParseNamespacePodCIDRs(namespace.Annotations)
+		if err != nil {
+			// Rules fall back to exact addresses until the annotation is fixed
+			log.FromContext(ctx).Error(err, "Invalid Namespace pod CIDRs annotation", "namespace", name)
+			continue
+		}
+		cidrs = append(cidrs, namespaceCIDRs...)
+	}
+	return cidrs, nil
+}
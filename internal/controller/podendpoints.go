@@ -0,0 +1,140 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// PodEndpointReconciler programs the endpoint of every pod starting on this
+// node with the rules of the NetworkPolicies covering it. A policy is applied
+// to the endpoints that exist when it is reconciled; without this reconciler
+// an endpoint created afterwards only receives its rules from the next
+// periodic resync.
+type PodEndpointReconciler struct {
+	client.Client
+	HCNManager hcnpkg.EndpointConverger
+	NodeName   string
+
+	// Policies tells which NetworkPolicies cover a pod under its ApplyScope
+	Policies *NetworkPolicyReconciler
+
+	// CacheSync holds reconciles back until the informers have synced; nil does not wait
+	CacheSync *CacheSyncGate
+
+	// Exemptions selects the pods whose endpoints carry no rules; nil exempts none
+	Exemptions *PodExemptions
+
+	mu sync.Mutex
+
+	// programmed maps pod -> the IP whose endpoint carries the pod's rules
+	programmed map[types.NamespacedName]string
+}
+
+// Reconcile programs the endpoint of a new local pod once it has an IP,
+// retrying with the controller's backoff until the endpoint exists and
+// carries every rule desired on it
+func (r *PodEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	// Policies missing from a partially synced cache would leave the endpoint unprotected
+	if err := r.CacheSync.Wait(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var pod corev1.Pod
+	if err := r.Get(ctx, req.NamespacedName, &pod); err != nil {
+		r.setProgrammed(req.NamespacedName, "")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !r.needsEndpoint(&pod) {
+		r.setProgrammed(req.NamespacedName, "")
+		return ctrl.Result{}, nil
+	}
+	if pod.Status.PodIP == "" || EnforcementPausedFor(&pod) || r.Exemptions.ExemptBy(&pod) != "" {
+		// The update assigning the IP or lifting the pause or exemption triggers the next reconcile
+		return ctrl.Result{}, nil
+	}
+	if r.programmedIP(req.NamespacedName) == pod.Status.PodIP {
+		return ctrl.Result{}, nil
+	}
+
+	policyKeys, err := r.Policies.policyKeysForPod(ctx, &pod)
+	if err != nil {
+		logger.Error(err, "Failed to list the NetworkPolicies covering pod")
+		return ctrl.Result{}, err
+	}
+	convergence, err := r.HCNManager.ConvergeEndpoint(ctx, pod.Status.PodIP, policyKeys)
+	if err != nil {
+		logger.Error(err, "Failed to program pod endpoint", "podIP", pod.Status.PodIP)
+		return ctrl.Result{}, err
+	}
+
+	switch {
+	case convergence.Programmed():
+		logger.Info("Programmed endpoint of new pod",
+			"endpointID", convergence.EndpointID,
+			"podIP", pod.Status.PodIP,
+			"policyCount", len(policyKeys))
+		r.setProgrammed(req.NamespacedName, pod.Status.PodIP)
+		return ctrl.Result{}, nil
+	case convergence.EndpointID == "":
+		logger.V(1).Info("No HNS endpoint owns the pod IP yet", "podIP", pod.Status.PodIP)
+	default:
+		// Selector-scoped policies wait for the reconcile adding the pod's IP
+		logger.V(1).Info("Pod endpoint rules still pending",
+			"endpointID", convergence.EndpointID,
+			"pending", convergence.Pending)
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// needsEndpoint reports whether pod runs on this node with an HNS endpoint of its own
+func (r *PodEndpointReconciler) needsEndpoint(pod *corev1.Pod) bool {
+	return pod.Spec.NodeName == r.NodeName && !pod.Spec.HostNetwork && pod.DeletionTimestamp == nil &&
+		pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed
+}
+
+// programmedIP returns the IP the endpoint of a pod was programmed for, if any
+func (r *PodEndpointReconciler) programmedIP(name types.NamespacedName) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.programmed[name]
+}
+
+// setProgrammed records the IP the endpoint of a pod was programmed for; empty forgets the pod
+func (r *PodEndpointReconciler) setProgrammed(name types.NamespacedName, ip string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.programmed == nil {
+		r.programmed = make(map[types.NamespacedName]string)
+	}
+	if ip == "" {
+		delete(r.programmed, name)
+		return
+	}
+	r.programmed[name] = ip
+}
+
+// SetupWithManager sets up the controller to watch the pods of this node
+func (r *PodEndpointReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	onNode := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		pod, ok := obj.(*corev1.Pod)
+		return ok && pod.Spec.NodeName == r.NodeName
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("pod-endpoint").
+		For(&corev1.Pod{}, builder.WithPredicates(onNode)).
+		Complete(r)
+}
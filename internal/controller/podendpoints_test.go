@@ -0,0 +1,93 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+func TestPodEndpointReconciler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+
+	web := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: "default", Labels: map[string]string{"app": "web"}},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+		Status:     corev1.PodStatus{Phase: corev1.PodPending},
+	}
+	remote := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default", Labels: map[string]string{"app": "web"}},
+		Spec:       corev1.PodSpec{NodeName: "node-2"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.244.1.2"},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(web, pod, remote).Build()
+
+	converger := &fakeConverger{}
+	reconciler := &PodEndpointReconciler{
+		Client:     fakeClient,
+		HCNManager: converger,
+		NodeName:   "node-1",
+		Policies:   &NetworkPolicyReconciler{Client: fakeClient, ApplyScope: ApplyScopeSelector},
+	}
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "web-0"}}
+	reconcile := func() ctrl.Result {
+		t.Helper()
+		converger.ip = ""
+		result, err := reconciler.Reconcile(ctx, req)
+		if err != nil {
+			t.Fatalf("Reconcile failed: %v", err)
+		}
+		return result
+	}
+
+	// Nothing to program before the pod has an IP
+	if result := reconcile(); result.Requeue || converger.ip != "" {
+		t.Errorf("Expected a pod without IP left alone, got %+v converging %q", result, converger.ip)
+	}
+
+	// The CNI has assigned the IP but HNS has no endpoint for it yet
+	pod.Status = corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.244.0.2"}
+	if err := fakeClient.Update(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+	if result := reconcile(); !result.Requeue {
+		t.Error("Expected a retry while the endpoint does not exist")
+	}
+	if converger.ip != "10.244.0.2" || len(converger.policyKeys) != 1 || converger.policyKeys[0] != "netpol/default/web" {
+		t.Errorf("Expected the endpoint of 10.244.0.2 programmed for netpol/default/web, got %s %v", converger.ip, converger.policyKeys)
+	}
+
+	// Once the endpoint carries its rules the pod is done
+	converger.convergence = hcnpkg.EndpointConvergence{EndpointID: "ep-1"}
+	if result := reconcile(); result.Requeue {
+		t.Error("Expected no retry once the endpoint is programmed")
+	}
+	if result := reconcile(); result.Requeue || converger.ip != "" {
+		t.Errorf("Expected a programmed pod not converged again, got %+v converging %q", result, converger.ip)
+	}
+
+	// Pods of other nodes are left to their agent
+	req.Name = "web-1"
+	if result := reconcile(); result.Requeue || converger.ip != "" {
+		t.Errorf("Expected a pod of another node left alone, got %+v converging %q", result, converger.ip)
+	}
+}
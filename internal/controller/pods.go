@@ -0,0 +1,47 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// listNamespacePodIPs returns the IPs of the running pods in a namespace.
+// Host-network pods are skipped since their IP is the node's.
+func listNamespacePodIPs(ctx context.Context, reader client.Reader, namespace string) ([]string, error) {
+	return listPodIPs(ctx, reader, client.InNamespace(namespace))
+}
+
+// listPodIPs returns the IPs of the running, non-host-network pods matching opts
+func listPodIPs(ctx context.Context, reader client.Reader, opts ...client.ListOption) ([]string, error) {
+	return listNodePodIPs(ctx, reader, "", opts...)
+}
+
+// listNodePodIPs returns the IPs of the running, non-host-network pods of
+// nodeName matching opts; empty nodeName takes the pods of every node
+func listNodePodIPs(ctx context.Context, reader client.Reader, nodeName string, opts ...client.ListOption) ([]string, error) {
+	// The pods are only read, so share them with the cache instead of copying
+	// every pod of a large namespace on each reconcile
+	var pods corev1.PodList
+	if err := reader.List(ctx, &pods, append(opts, client.UnsafeDisableDeepCopy)...); err != nil {
+		return nil, err
+	}
+
+	var ips []string
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if nodeName != "" && pod.Spec.NodeName != nodeName {
+			continue
+		}
+		if pod.Spec.HostNetwork || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, podIP := range pod.Status.PodIPs {
+			ips = append(ips, podIP.IP)
+		}
+	}
+	return ips, nil
+}
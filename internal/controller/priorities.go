@@ -0,0 +1,32 @@
+//go:build windows
+
+package controller
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+
+	"github.com/knabben/firewall-controller/internal/converter"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// allocatePriorities converts np again within the priority range allocated
+// to policyKey, sized after the priorities conversion used from the start of
+// the band. Without an allocator conversion is returned as is.
+func (r *NetworkPolicyReconciler) allocatePriorities(np *networkingv1.NetworkPolicy, policyKey hcnpkg.PolicyKey, opts converter.ConversionOptions, conversion converter.Conversion) (converter.Conversion, error) {
+	if r.Priorities == nil {
+		return conversion, nil
+	}
+	allocation, err := r.Priorities.Allocate(string(policyKey), converter.PrioritySpan(conversion.Rules, opts))
+	if err != nil || allocation == (hcnpkg.PriorityRange{}) {
+		return conversion, err
+	}
+	opts.BasePriority, opts.MaxPriority = allocation.Start, allocation.End
+	return converter.ConvertNetworkPolicy(np, opts)
+}
+
+// releasePriorities reclaims the priority range of a policy whose rules are removed
+func (r *NetworkPolicyReconciler) releasePriorities(policyKey hcnpkg.PolicyKey) {
+	if r.Priorities != nil {
+		r.Priorities.Release(string(policyKey))
+	}
+}
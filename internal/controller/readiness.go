@@ -0,0 +1,225 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// PolicyReadinessGate is the pod readiness gate the agent sets once the pod's
+// endpoint carries the rules of every policy covering it
+const PolicyReadinessGate corev1.PodConditionType = "networking.knabben.github.io/policies-programmed"
+
+// Reasons of the PolicyReadinessGate condition
+const (
+	ReadinessReasonProgrammed  = "PoliciesProgrammed"
+	ReadinessReasonPending     = "PoliciesPending"
+	ReadinessReasonNoEndpoint  = "EndpointNotFound"
+	ReadinessReasonHostNetwork = "HostNetwork"
+	ReadinessReasonPaused      = "EnforcementPaused"
+	ReadinessReasonExempt      = "EndpointExempt"
+)
+
+// defaultReadinessRetryInterval is how often gated pods are checked again while pending
+const defaultReadinessRetryInterval = 2 * time.Second
+
+// PodReadinessReconciler sets the PolicyReadinessGate condition of the pods on
+// this node listing the gate, so they only turn Ready, and receive Service
+// traffic, once their endpoint is protected
+type PodReadinessReconciler struct {
+	client.Client
+	HCNManager hcnpkg.EndpointConverger
+	NodeName   string
+
+	// Policies tells which NetworkPolicies cover a pod under its ApplyScope
+	Policies *NetworkPolicyReconciler
+
+	// CacheSync holds reconciles back until the informers have synced; nil does not wait
+	CacheSync *CacheSyncGate
+
+	// RetryInterval is how often a pending pod is checked again; 0 means 2s
+	RetryInterval time.Duration
+
+	// Exemptions selects the pods whose endpoints carry no rules; nil exempts none
+	Exemptions *PodExemptions
+}
+
+// Reconcile programs the endpoint of a gated pod and marks the gate True once
+// every rule desired on it is in effect
+func (r *PodReadinessReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	// Policies missing from a partially synced cache would pass pods unprotected
+	if err := r.CacheSync.Wait(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var pod corev1.Pod
+	if err := r.Get(ctx, req.NamespacedName, &pod); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !r.gated(&pod) || pod.DeletionTimestamp != nil || gateStatus(&pod) == corev1.ConditionTrue {
+		return ctrl.Result{}, nil
+	}
+	if pod.Spec.HostNetwork {
+		return ctrl.Result{}, r.setGate(ctx, &pod, corev1.ConditionTrue, ReadinessReasonHostNetwork,
+			"Host-network pods have no HNS endpoint")
+	}
+	if EnforcementPausedFor(&pod) {
+		// No rule is programmed on a paused endpoint; waiting for them would never end
+		return ctrl.Result{}, r.setGate(ctx, &pod, corev1.ConditionTrue, ReadinessReasonPaused,
+			"Enforcement is paused on the pod's endpoint")
+	}
+	if exemptBy := r.Exemptions.ExemptBy(&pod); exemptBy != "" {
+		// Likewise an exempt endpoint never carries rules
+		return ctrl.Result{}, r.setGate(ctx, &pod, corev1.ConditionTrue, ReadinessReasonExempt,
+			"The pod is exempt from NetworkPolicy rules by "+exemptBy)
+	}
+	if pod.Status.PodIP == "" {
+		// The update assigning the IP triggers the next reconcile
+		return ctrl.Result{}, nil
+	}
+
+	policyKeys, err := r.Policies.policyKeysForPod(ctx, &pod)
+	if err != nil {
+		logger.Error(err, "Failed to list the NetworkPolicies covering pod")
+		return ctrl.Result{}, err
+	}
+	convergence, err := r.HCNManager.ConvergeEndpoint(ctx, pod.Status.PodIP, policyKeys)
+	if err != nil {
+		logger.Error(err, "Failed to converge pod endpoint", "podIP", pod.Status.PodIP)
+		return ctrl.Result{}, err
+	}
+
+	switch {
+	case convergence.Programmed():
+		logger.Info("Pod endpoint programmed, opening readiness gate",
+			"endpointID", convergence.EndpointID,
+			"policyCount", len(policyKeys))
+		return ctrl.Result{}, r.setGate(ctx, &pod, corev1.ConditionTrue, ReadinessReasonProgrammed,
+			fmt.Sprintf("Endpoint %s carries the rules of %d NetworkPolicies", convergence.EndpointID, len(policyKeys)))
+	case convergence.EndpointID == "":
+		err = r.setGate(ctx, &pod, corev1.ConditionFalse, ReadinessReasonNoEndpoint,
+			fmt.Sprintf("No HNS endpoint owns %s yet", pod.Status.PodIP))
+	default:
+		err = r.setGate(ctx, &pod, corev1.ConditionFalse, ReadinessReasonPending,
+			"Rules not programmed yet: "+strings.Join(convergence.Pending, ", "))
+	}
+	return ctrl.Result{RequeueAfter: r.retryInterval()}, err
+}
+
+// gated reports whether pod runs on this node and lists PolicyReadinessGate
+func (r *PodReadinessReconciler) gated(pod *corev1.Pod) bool {
+	return pod.Spec.NodeName == r.NodeName && HasPolicyReadinessGate(pod)
+}
+
+// retryInterval returns RetryInterval or its default
+func (r *PodReadinessReconciler) retryInterval() time.Duration {
+	if r.RetryInterval > 0 {
+		return r.RetryInterval
+	}
+	return defaultReadinessRetryInterval
+}
+
+// setGate patches the PolicyReadinessGate condition of pod unless it already
+// has status, reason and message
+func (r *PodReadinessReconciler) setGate(ctx context.Context, pod *corev1.Pod, status corev1.ConditionStatus, reason, message string) error {
+	condition := corev1.PodCondition{
+		Type:               PolicyReadinessGate,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
+	}
+	i := slices.IndexFunc(pod.Status.Conditions, func(c corev1.PodCondition) bool { return c.Type == PolicyReadinessGate })
+	if i >= 0 {
+		current := pod.Status.Conditions[i]
+		if current.Status == status && current.Reason == reason && current.Message == message {
+			return nil
+		}
+		if current.Status == status {
+			condition.LastTransitionTime = current.LastTransitionTime
+		}
+	}
+
+	// A strategic merge leaves the conditions owned by the kubelet alone
+	patch := client.StrategicMergeFrom(pod.DeepCopy())
+	if i >= 0 {
+		pod.Status.Conditions[i] = condition
+	} else {
+		pod.Status.Conditions = append(pod.Status.Conditions, condition)
+	}
+	return r.Status().Patch(ctx, pod, patch)
+}
+
+// gateStatus returns the status of the PolicyReadinessGate condition of pod
+func gateStatus(pod *corev1.Pod) corev1.ConditionStatus {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == PolicyReadinessGate {
+			return condition.Status
+		}
+	}
+	return corev1.ConditionUnknown
+}
+
+// HasPolicyReadinessGate reports whether the spec of pod lists PolicyReadinessGate
+func HasPolicyReadinessGate(pod *corev1.Pod) bool {
+	for _, gate := range pod.Spec.ReadinessGates {
+		if gate.ConditionType == PolicyReadinessGate {
+			return true
+		}
+	}
+	return false
+}
+
+// policyKeysForPod returns the keys of the NetworkPolicies whose rules are
+// programmed on the endpoint of pod or its network: every policy with
+// ApplyScopeAllEndpoints and ApplyScopeNetwork, those selecting it in its
+// namespace with ApplyScopeSelector
+func (r *NetworkPolicyReconciler) policyKeysForPod(ctx context.Context, pod *corev1.Pod) ([]hcnpkg.PolicyKey, error) {
+	opts := []client.ListOption{client.UnsafeDisableDeepCopy}
+	if r.selectsPods() {
+		opts = append(opts, client.InNamespace(pod.Namespace))
+	}
+	var policies networkingv1.NetworkPolicyList
+	if err := r.List(ctx, &policies, opts...); err != nil {
+		return nil, err
+	}
+
+	var keys []hcnpkg.PolicyKey
+	for i := range policies.Items {
+		policy := &policies.Items[i]
+		if r.selectsPods() && !policySelects(policy, pod) {
+			continue
+		}
+		keys = append(keys, r.policyKey(client.ObjectKeyFromObject(policy)))
+	}
+	return keys, nil
+}
+
+// SetupWithManager sets up the controller to watch the gated pods of this node
+func (r *PodReadinessReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	onNode := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		pod, ok := obj.(*corev1.Pod)
+		return ok && r.gated(pod)
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("pod-readiness").
+		For(&corev1.Pod{}, builder.WithPredicates(onNode)).
+		Complete(r)
+}
@@ -0,0 +1,207 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// fakeConverger reports a fixed convergence and records the keys it was asked about
+type fakeConverger struct {
+	convergence hcnpkg.EndpointConvergence
+	ip          string
+	policyKeys  []hcnpkg.PolicyKey
+}
+
+func (f *fakeConverger) ConvergeEndpoint(ctx context.Context, ip string, policyKeys []hcnpkg.PolicyKey) (hcnpkg.EndpointConvergence, error) {
+	f.ip, f.policyKeys = ip, policyKeys
+	return f.convergence, nil
+}
+
+// gatedPod returns a running pod on node listing PolicyReadinessGate
+func gatedPod(name, node string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"app": "web"}},
+		Spec: corev1.PodSpec{
+			NodeName:       node,
+			ReadinessGates: []corev1.PodReadinessGate{{ConditionType: PolicyReadinessGate}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.244.0.2"},
+	}
+}
+
+func TestPodReadinessReconciler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+
+	web := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		},
+	}
+	db := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(web, db, gatedPod("web-0", "node-1"), gatedPod("web-1", "node-2")).
+		WithStatusSubresource(&corev1.Pod{}).
+		Build()
+
+	converger := &fakeConverger{convergence: hcnpkg.EndpointConvergence{
+		EndpointID: "ep-1",
+		Pending:    []string{"netpol/default/web"},
+	}}
+	reconciler := &PodReadinessReconciler{
+		Client:     fakeClient,
+		HCNManager: converger,
+		NodeName:   "node-1",
+		Policies:   &NetworkPolicyReconciler{Client: fakeClient, ApplyScope: ApplyScopeSelector},
+	}
+	ctx := context.Background()
+	condition := func(name string) *corev1.PodCondition {
+		var pod corev1.Pod
+		if err := fakeClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, &pod); err != nil {
+			t.Fatalf("Failed to get pod: %v", err)
+		}
+		for i := range pod.Status.Conditions {
+			if pod.Status.Conditions[i].Type == PolicyReadinessGate {
+				return &pod.Status.Conditions[i]
+			}
+		}
+		return nil
+	}
+
+	// Pending rules keep the gate closed and retry
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "web-0"}}
+	result, err := reconciler.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if result.RequeueAfter == 0 {
+		t.Error("Expected a pending pod to be checked again")
+	}
+	if len(converger.policyKeys) != 1 || converger.policyKeys[0] != "netpol/default/web" || converger.ip != "10.244.0.2" {
+		t.Errorf("Expected the endpoint of 10.244.0.2 checked for netpol/default/web, got %s %v", converger.ip, converger.policyKeys)
+	}
+	if c := condition("web-0"); c == nil || c.Status != corev1.ConditionFalse || c.Reason != ReadinessReasonPending {
+		t.Fatalf("Expected a pending condition, got %+v", c)
+	}
+
+	// Once programmed the gate opens
+	converger.convergence.Pending = nil
+	if result, err = reconciler.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if result.RequeueAfter != 0 {
+		t.Error("Expected no retry once the gate is open")
+	}
+	if c := condition("web-0"); c == nil || c.Status != corev1.ConditionTrue || c.Reason != ReadinessReasonProgrammed {
+		t.Fatalf("Expected the gate open, got %+v", c)
+	}
+
+	// Pods of other nodes are left to their agent
+	req.Name = "web-1"
+	if _, err := reconciler.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if c := condition("web-1"); c != nil {
+		t.Errorf("Expected no condition on a pod of another node, got %+v", c)
+	}
+
+	// Exempt pods carry no rules to wait for
+	exempt := gatedPod("dns-0", "node-1")
+	exempt.Labels = map[string]string{ExemptLabel: "true"}
+	if err := fakeClient.Create(ctx, exempt); err != nil {
+		t.Fatal(err)
+	}
+	reconciler.Exemptions = &PodExemptions{Label: true}
+	converger.convergence.Pending = []string{"netpol/default/web"}
+	req.Name = "dns-0"
+	if _, err := reconciler.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if c := condition("dns-0"); c == nil || c.Status != corev1.ConditionTrue || c.Reason != ReadinessReasonExempt {
+		t.Errorf("Expected the gate of an exempt pod open, got %+v", c)
+	}
+}
+
+func TestPodReadinessGateInjector(t *testing.T) {
+	windows := map[string]string{corev1.LabelOSStable: "windows"}
+	tests := []struct {
+		name     string
+		pod      corev1.Pod
+		wantGate bool
+		wantErr  bool
+	}{
+		{
+			name:     "windows pod",
+			pod:      corev1.Pod{Spec: corev1.PodSpec{NodeSelector: windows}},
+			wantGate: true,
+		},
+		{
+			name: "linux pod",
+			pod:  corev1.Pod{Spec: corev1.PodSpec{NodeSelector: map[string]string{corev1.LabelOSStable: "linux"}}},
+		},
+		{
+			name: "host network",
+			pod:  corev1.Pod{Spec: corev1.PodSpec{NodeSelector: windows, HostNetwork: true}},
+		},
+		{
+			name: "opted out",
+			pod: corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{ReadinessGateAnnotation: "false"}},
+				Spec:       corev1.PodSpec{NodeSelector: windows},
+			},
+		},
+		{
+			name: "opted in",
+			pod: corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{ReadinessGateAnnotation: "true"}},
+			},
+			wantGate: true,
+		},
+		{
+			name: "invalid annotation",
+			pod: corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{ReadinessGateAnnotation: "yes please"}},
+			},
+			wantErr: true,
+		},
+	}
+
+	injector := &PodReadinessGateInjector{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := tt.pod.DeepCopy()
+			err := injector.Default(context.Background(), pod)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Default() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if HasPolicyReadinessGate(pod) != tt.wantGate {
+				t.Fatalf("Expected gate %v, got %+v", tt.wantGate, pod.Spec.ReadinessGates)
+			}
+
+			// Admitting the pod again does not add a second gate
+			_ = injector.Default(context.Background(), pod)
+			if tt.wantGate && len(pod.Spec.ReadinessGates) != 1 {
+				t.Errorf("Expected one readiness gate, got %+v", pod.Spec.ReadinessGates)
+			}
+		})
+	}
+}
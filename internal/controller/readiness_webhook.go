@@ -0,0 +1,73 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// ReadinessGateAnnotation overrides whether PolicyReadinessGate is added to a
+// pod: "true" adds it to any pod, "false" never adds it. Without it the gate is
+// added to pods scheduled on Windows nodes through their nodeSelector.
+const ReadinessGateAnnotation = "networking.knabben.github.io/readiness-gate"
+
+// PodReadinessGateInjector is a mutating webhook adding PolicyReadinessGate to
+// new pods, which PodReadinessReconciler then sets on their node
+// +kubebuilder:webhook:path=/mutate--v1-pod,mutating=true,failurePolicy=ignore,sideEffects=None,groups="",resources=pods,verbs=create,versions=v1,name=mpod-readiness.knabben.github.io,admissionReviewVersions=v1
+type PodReadinessGateInjector struct{}
+
+var _ admission.CustomDefaulter = &PodReadinessGateInjector{}
+
+// Default adds PolicyReadinessGate to pod when it should be gated
+func (i *PodReadinessGateInjector) Default(ctx context.Context, obj runtime.Object) error {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return fmt.Errorf("expected a Pod, got %T", obj)
+	}
+
+	gate, err := wantsReadinessGate(pod)
+	if err != nil {
+		return err
+	}
+	if !gate || HasPolicyReadinessGate(pod) {
+		return nil
+	}
+	log.FromContext(ctx).V(1).Info("Adding policy readiness gate to pod",
+		"namespace", pod.Namespace,
+		"name", pod.Name,
+		"generateName", pod.GenerateName)
+	pod.Spec.ReadinessGates = append(pod.Spec.ReadinessGates, corev1.PodReadinessGate{ConditionType: PolicyReadinessGate})
+	return nil
+}
+
+// wantsReadinessGate reports whether pod should wait for its rules before turning Ready
+func wantsReadinessGate(pod *corev1.Pod) (bool, error) {
+	if value, ok := pod.Annotations[ReadinessGateAnnotation]; ok {
+		gate, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("invalid %s annotation %q: %w", ReadinessGateAnnotation, value, err)
+		}
+		return gate, nil
+	}
+	// Host-network pods have no endpoint to program
+	if pod.Spec.HostNetwork {
+		return false, nil
+	}
+	return pod.Spec.NodeSelector[corev1.LabelOSStable] == "windows", nil
+}
+
+// SetupWebhookWithManager registers the injector with the webhook server of mgr
+func (i *PodReadinessGateInjector) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&corev1.Pod{}).
+		WithDefaulter(i).
+		Complete()
+}
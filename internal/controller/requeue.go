@@ -0,0 +1,100 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-logr/logr"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// RequeueDispatcher closes the loop between the HCN Manager and the
+// NetworkPolicy controllers: policies the Manager could not keep converged on
+// its own (failed resyncs, drift, recreated endpoints) are pushed into the
+// source.Channel of the controller owning their key and reconciled again.
+type RequeueDispatcher struct {
+	requests <-chan hcnpkg.RequeueRequest
+	routes   []requeueRoute
+	logger   logr.Logger
+}
+
+// requeueRoute is the channel source of one NetworkPolicy reconciler
+type requeueRoute struct {
+	reconciler *NetworkPolicyReconciler
+	events     chan event.GenericEvent
+}
+
+// NewRequeueDispatcher creates a dispatcher for the requests of an HCN Manager,
+// as returned by Manager.EnableRequeues
+func NewRequeueDispatcher(requests <-chan hcnpkg.RequeueRequest, logger logr.Logger) *RequeueDispatcher {
+	return &RequeueDispatcher{requests: requests, logger: logger}
+}
+
+// Source returns the channel source feeding r the requeues of its policy keys.
+// It is called once per reconciler while the controllers are set up.
+func (d *RequeueDispatcher) Source(r *NetworkPolicyReconciler) source.Source {
+	events := make(chan event.GenericEvent)
+	d.routes = append(d.routes, requeueRoute{reconciler: r, events: events})
+	return source.Channel(events, &handler.EnqueueRequestForObject{})
+}
+
+// Start routes requeue requests until the context is cancelled. It implements
+// the controller-runtime Runnable interface.
+func (d *RequeueDispatcher) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case request := <-d.requests:
+			d.dispatch(ctx, request)
+		}
+	}
+}
+
+// NeedLeaderElection implements LeaderElectionRunnable; every node programs its own endpoints
+func (d *RequeueDispatcher) NeedLeaderElection() bool {
+	return false
+}
+
+// dispatch sends request to the reconciler owning its policy key
+func (d *RequeueDispatcher) dispatch(ctx context.Context, request hcnpkg.RequeueRequest) {
+	for _, route := range d.routes {
+		name, owned := route.reconciler.policyName(request.PolicyKey)
+		if !owned {
+			continue
+		}
+		d.logger.Info("Requeueing NetworkPolicy on HCN Manager request",
+			"policyKey", request.PolicyKey,
+			"reason", request.Reason)
+		np := &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Namespace: name.Namespace, Name: name.Name}}
+		select {
+		case route.events <- event.GenericEvent{Object: np}:
+		case <-ctx.Done():
+		}
+		return
+	}
+	d.logger.V(1).Info("No controller owns requeued policy key", "policyKey", request.PolicyKey)
+}
+
+// policyName returns the NetworkPolicy a policy key of this reconciler's
+// source names; it is the inverse of policyKey
+func (r *NetworkPolicyReconciler) policyName(policyKey hcnpkg.PolicyKey) (types.NamespacedName, bool) {
+	if !r.ownsKey(policyKey) {
+		return types.NamespacedName{}, false
+	}
+	rest := policyKey.Name()
+	if r.SourceName != "" {
+		rest = strings.TrimPrefix(rest, r.SourceName+"/")
+	}
+	namespace, name, _ := strings.Cut(rest, "/")
+	return types.NamespacedName{Namespace: namespace, Name: name}, true
+}
@@ -0,0 +1,50 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+func TestNetworkPolicyReconciler_PolicyName(t *testing.T) {
+	local := &NetworkPolicyReconciler{}
+	remote := &NetworkPolicyReconciler{SourceName: "hub"}
+	name := types.NamespacedName{Namespace: "default", Name: "web"}
+
+	for _, r := range []*NetworkPolicyReconciler{local, remote} {
+		got, owned := r.policyName(r.policyKey(name))
+		if !owned || got != name {
+			t.Errorf("policyName(%q) = %v, %v; want %v", r.policyKey(name), got, owned, name)
+		}
+	}
+	if _, owned := local.policyName("netpol/hub/default/web"); owned {
+		t.Error("Expected the local reconciler not to own a source key")
+	}
+	if _, owned := remote.policyName("netpol/default/web"); owned {
+		t.Error("Expected the source reconciler not to own a local key")
+	}
+}
+
+func TestRequeueDispatcher_RoutesToOwner(t *testing.T) {
+	requests := make(chan hcnpkg.RequeueRequest, 1)
+	dispatcher := NewRequeueDispatcher(requests, logr.Discard())
+	dispatcher.Source(&NetworkPolicyReconciler{})
+	dispatcher.Source(&NetworkPolicyReconciler{SourceName: "hub"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = dispatcher.Start(ctx) }()
+
+	requests <- hcnpkg.RequeueRequest{PolicyKey: "netpol/hub/team-a/db", Reason: hcnpkg.RequeueDrift}
+	event := <-dispatcher.routes[1].events
+	if event.Object.GetNamespace() != "team-a" || event.Object.GetName() != "db" {
+		t.Errorf("Expected team-a/db on the hub source, got %s/%s",
+			event.Object.GetNamespace(), event.Object.GetName())
+	}
+}
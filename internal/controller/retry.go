@@ -0,0 +1,73 @@
+//go:build windows
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// RetryOptions tunes how the policy controllers retry failed reconciles. A
+// failing object is retried after InitialBackoff, doubled on each consecutive
+// failure up to MaxBackoff; QPS and Burst bound the retries of every object
+// together, so a node whose HNS keeps failing does not spin.
+type RetryOptions struct {
+	// InitialBackoff is how long a failed object waits for its first retry
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the backoff of an object that keeps failing. A policy
+	// waiting for ACL priorities to be freed is also retried after it.
+	MaxBackoff time.Duration
+
+	// QPS is the rate of retries across every object
+	QPS float64
+
+	// Burst is how many retries may exceed QPS at once
+	Burst int
+}
+
+// DefaultRetryOptions returns the controller-runtime rate limits, with the
+// backoff capped at the 30s the controllers used to requeue after
+func DefaultRetryOptions() RetryOptions {
+	return RetryOptions{
+		InitialBackoff: 5 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		QPS:            10,
+		Burst:          100,
+	}
+}
+
+// Validate checks the options
+func (o RetryOptions) Validate() error {
+	if o.InitialBackoff <= 0 || o.MaxBackoff < o.InitialBackoff {
+		return fmt.Errorf("invalid requeue backoff %s-%s: the initial backoff must be positive and at most the maximum",
+			o.InitialBackoff, o.MaxBackoff)
+	}
+	if o.QPS <= 0 || o.Burst <= 0 {
+		return fmt.Errorf("invalid requeue rate %g/s with burst %d: both must be positive", o.QPS, o.Burst)
+	}
+	return nil
+}
+
+// withDefaults returns o, or DefaultRetryOptions when o is unset
+func (o RetryOptions) withDefaults() RetryOptions {
+	if o == (RetryOptions{}) {
+		return DefaultRetryOptions()
+	}
+	return o
+}
+
+// RateLimiter returns a work queue rate limiter retrying with o. Each
+// controller needs a limiter of its own, since it tracks the failures of the
+// objects of one queue.
+func (o RetryOptions) RateLimiter() workqueue.TypedRateLimiter[reconcile.Request] {
+	o = o.withDefaults()
+	return workqueue.NewTypedMaxOfRateLimiter(
+		workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](o.InitialBackoff, o.MaxBackoff),
+		&workqueue.TypedBucketRateLimiter[reconcile.Request]{Limiter: rate.NewLimiter(rate.Limit(o.QPS), o.Burst)},
+	)
+}
@@ -0,0 +1,47 @@
+//go:build windows
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestRetryOptions_Validate(t *testing.T) {
+	if err := DefaultRetryOptions().Validate(); err != nil {
+		t.Errorf("Expected the defaults to be valid, got %v", err)
+	}
+	for _, opts := range []RetryOptions{
+		{InitialBackoff: 0, MaxBackoff: time.Minute, QPS: 10, Burst: 100},
+		{InitialBackoff: time.Minute, MaxBackoff: time.Second, QPS: 10, Burst: 100},
+		{InitialBackoff: time.Second, MaxBackoff: time.Minute, QPS: 0, Burst: 100},
+		{InitialBackoff: time.Second, MaxBackoff: time.Minute, QPS: 10, Burst: 0},
+	} {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", opts)
+		}
+	}
+}
+
+func TestRetryOptions_RateLimiter(t *testing.T) {
+	limiter := RetryOptions{InitialBackoff: time.Second, MaxBackoff: 3 * time.Second, QPS: 1000, Burst: 1000}.RateLimiter()
+	item := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "web"}}
+
+	// Each failure doubles the backoff up to the maximum
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		if got := limiter.When(item); got != want {
+			t.Errorf("Expected a %s backoff, got %s", want, got)
+		}
+	}
+	limiter.Forget(item)
+	if got := limiter.When(item); got != time.Second {
+		t.Errorf("Expected the backoff to start over once forgotten, got %s", got)
+	}
+
+	if got := (RetryOptions{}).withDefaults(); got != DefaultRetryOptions() {
+		t.Errorf("Expected unset options to use the defaults, got %+v", got)
+	}
+}
@@ -0,0 +1,132 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// ApplyScope selects which endpoints receive a NetworkPolicy's rules
+type ApplyScope string
+
+const (
+	// ApplyScopeAllEndpoints programs every policy on every endpoint of the
+	// node, ignoring spec.podSelector. It is the behavior of earlier releases
+	// and is kept for clusters that rely on it.
+	ApplyScopeAllEndpoints ApplyScope = "all-endpoints"
+
+	// ApplyScopeSelector programs a policy only on the endpoints of the pods
+	// its spec.podSelector selects. It is the agent's default and what an
+	// empty ApplyScope means.
+	ApplyScopeSelector ApplyScope = "selector"
+
+	// ApplyScopeNetwork programs every policy once on each HNS network hosting
+	// the node's endpoints, ignoring spec.podSelector like
+	// ApplyScopeAllEndpoints but without a copy of the rules per endpoint
+	ApplyScopeNetwork ApplyScope = "network"
+)
+
+// ParseApplyScope parses an --apply-scope value
+func ParseApplyScope(value string) (ApplyScope, error) {
+	switch scope := ApplyScope(value); scope {
+	case ApplyScopeAllEndpoints, ApplyScopeSelector, ApplyScopeNetwork:
+		return scope, nil
+	default:
+		return "", fmt.Errorf("invalid apply scope %q: must be all-endpoints, selector or network", value)
+	}
+}
+
+// scope returns the reconciler's ApplyScope, ApplyScopeSelector when empty
+func (r *NetworkPolicyReconciler) scope() ApplyScope {
+	if r.ApplyScope == "" {
+		return ApplyScopeSelector
+	}
+	return r.ApplyScope
+}
+
+// selectsPods reports whether the reconciler limits policies to their selected pods
+func (r *NetworkPolicyReconciler) selectsPods() bool {
+	return r.scope() == ApplyScopeSelector
+}
+
+// applyScoped programs rules on the endpoints np applies to under the
+// reconciler's scope, bounded by ApplyTimeout
+func (r *NetworkPolicyReconciler) applyScoped(ctx context.Context, np *networkingv1.NetworkPolicy, policyKey hcnpkg.PolicyKey, rules []hcnpkg.ACLRule) error {
+	if r.scope() == ApplyScopeNetwork {
+		return r.applyNetworkScoped(ctx, policyKey, rules)
+	}
+	if !r.selectsPods() {
+		return r.applyACLRules(ctx, policyKey, rules)
+	}
+
+	applier, ok := r.HCNManager.(hcnpkg.AddressApplier)
+	if !ok {
+		return fmt.Errorf("apply scope %s: the HCN manager cannot target endpoints", r.scope())
+	}
+	addresses, err := selectedPodIPs(ctx, r.Client, np, r.localNode())
+	if err != nil {
+		return fmt.Errorf("failed to list the pods selected by the policy: %w", err)
+	}
+	if r.ApplyTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.ApplyTimeout)
+		defer cancel()
+	}
+	return applier.ApplyACLRulesToAddresses(ctx, policyKey, rules, addresses)
+}
+
+// applyNetworkScoped programs rules on the HNS networks of the node, bounded by ApplyTimeout
+func (r *NetworkPolicyReconciler) applyNetworkScoped(ctx context.Context, policyKey hcnpkg.PolicyKey, rules []hcnpkg.ACLRule) error {
+	applier, ok := r.HCNManager.(hcnpkg.NetworkApplier)
+	if !ok {
+		return fmt.Errorf("apply scope %s: the HCN manager cannot program networks", r.scope())
+	}
+	if r.ApplyTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.ApplyTimeout)
+		defer cancel()
+	}
+	return applier.ApplyNetworkACLRules(ctx, policyKey, rules)
+}
+
+// selectedPodIPs returns the IPs of the running pods of nodeName np's
+// spec.podSelector selects in its namespace; empty nodeName takes every node
+func selectedPodIPs(ctx context.Context, reader client.Reader, np *networkingv1.NetworkPolicy, nodeName string) ([]string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&np.Spec.PodSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	return listNodePodIPs(ctx, reader, nodeName, client.InNamespace(np.Namespace), client.MatchingLabelsSelector{Selector: selector})
+}
+
+// localNode returns the node whose pods the reconciler's policies target. The
+// pods of an additional policy source are not filtered, since they may reach
+// this node's endpoints under a node name of their own cluster.
+func (r *NetworkPolicyReconciler) localNode() string {
+	if r.SourceName != "" {
+		return ""
+	}
+	return r.NodeName
+}
+
+// hostsPod reports whether obj is a pod the reconciler's policies may target
+func (r *NetworkPolicyReconciler) hostsPod(obj client.Object) bool {
+	pod, ok := obj.(*corev1.Pod)
+	return ok && (r.localNode() == "" || pod.Spec.NodeName == r.localNode())
+}
+
+// policySelects reports whether np's spec.podSelector selects obj
+func policySelects(np *networkingv1.NetworkPolicy, obj client.Object) bool {
+	selector, err := metav1.LabelSelectorAsSelector(&np.Spec.PodSelector)
+	return err == nil && selector.Matches(labels.Set(obj.GetLabels()))
+}
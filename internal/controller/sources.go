@@ -0,0 +1,46 @@
+//go:build windows
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// PolicySource is an additional cluster whose NetworkPolicies are enforced on
+// this node, for edge nodes serving workloads of more than one control plane.
+// Policy keys from the source are prefixed with its name.
+type PolicySource struct {
+	// Name identifies the source in policy keys, logs and controller names
+	Name string
+
+	// Kubeconfig is the path of the kubeconfig used to reach the cluster
+	Kubeconfig string
+}
+
+// ParsePolicySources parses a comma-separated list such as "edge=C:\k\edge.conf"
+func ParsePolicySources(value string) ([]PolicySource, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var sources []PolicySource
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ",") {
+		name, path, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("invalid policy source %q: expected name=kubeconfig", part)
+		}
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid policy source name %q: %s", name, strings.Join(errs, "; "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate policy source %q", name)
+		}
+		seen[name] = true
+		sources = append(sources, PolicySource{Name: name, Kubeconfig: path})
+	}
+	return sources, nil
+}
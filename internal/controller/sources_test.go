@@ -0,0 +1,36 @@
+//go:build windows
+
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestParsePolicySources(t *testing.T) {
+	sources, err := ParsePolicySources(`edge=C:\k\edge.conf, site-b=C:\k\site-b.conf`)
+	if err != nil {
+		t.Fatalf("ParsePolicySources failed: %v", err)
+	}
+	if len(sources) != 2 || sources[0].Name != "edge" || sources[1].Kubeconfig != `C:\k\site-b.conf` {
+		t.Errorf("Unexpected sources %+v", sources)
+	}
+
+	for _, invalid := range []string{"edge", "Edge=x", "edge=", "edge=a,edge=b"} {
+		if _, err := ParsePolicySources(invalid); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestPolicyKey_SourcePrefix(t *testing.T) {
+	name := types.NamespacedName{Namespace: "default", Name: "allow-web"}
+
+	if got := (&NetworkPolicyReconciler{}).policyKey(name); got != "netpol/default/allow-web" {
+		t.Errorf("Expected unprefixed key for the local cluster, got %s", got)
+	}
+	if got := (&NetworkPolicyReconciler{SourceName: "edge"}).policyKey(name); got != "netpol/edge/default/allow-web" {
+		t.Errorf("Expected source-prefixed key, got %s", got)
+	}
+}
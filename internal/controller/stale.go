@@ -0,0 +1,236 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// PolicyStore is the part of the HCN Manager the stale policy sweeper compares
+// against the API server
+type PolicyStore interface {
+	// DesiredPolicyKeys returns the keys of every NetworkPolicy with desired rules
+	DesiredPolicyKeys() []hcnpkg.PolicyKey
+
+	// RemoveACLRules removes all rules programmed for policyKey
+	RemoveACLRules(policyKey hcnpkg.PolicyKey) error
+
+	// RepairDrift reprograms tracked rules that are missing from their endpoints
+	RepairDrift() error
+}
+
+// WatchMonitor records dropped API server watches for a StalePolicySweeper.
+// It is created before the cache so its handler can be passed in the cache options.
+type WatchMonitor struct {
+	// dropped is set by WatchErrorHandler and cleared by a successful sweep
+	dropped atomic.Bool
+
+	// expired is set when the watch's resourceVersion was too old to resume
+	// from, and cleared by a successful rebuild
+	expired atomic.Bool
+}
+
+// WatchErrorHandler records a dropped watch and defers to the client-go default.
+// It is meant for the cache's DefaultWatchErrorHandler option.
+func (w *WatchMonitor) WatchErrorHandler(r *toolscache.Reflector, err error) {
+	// A watch closed normally by the server is resumed without missing events
+	if err != io.EOF {
+		w.dropped.Store(true)
+	}
+	if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+		w.expired.Store(true)
+	}
+	toolscache.DefaultWatchErrorHandler(r, err)
+}
+
+// StalePolicySweeper removes the rules of NetworkPolicies deleted while the
+// agent could not watch the API server. Delete events missed during a long
+// disconnect never arrive, so once the watch has failed the sweeper lists the
+// policies straight from the API server and drops every key it no longer finds.
+// When the watch could not resume because its resourceVersion was too old, any
+// event may have been missed, so every policy is rebuilt and drift repaired.
+type StalePolicySweeper struct {
+	reconciler *NetworkPolicyReconciler
+	reader     client.Reader
+	store      PolicyStore
+	interval   time.Duration
+	monitor    *WatchMonitor
+	logger     logr.Logger
+}
+
+// NewStalePolicySweeper creates a sweeper for the policies of r, triggered by
+// the watches monitor saw drop. reader must read from the API server rather
+// than an informer cache; interval is how often the sweeper checks monitor.
+func NewStalePolicySweeper(r *NetworkPolicyReconciler, reader client.Reader, store PolicyStore, monitor *WatchMonitor,
+	interval time.Duration, logger logr.Logger) *StalePolicySweeper {
+	return &StalePolicySweeper{
+		reconciler: r,
+		reader:     reader,
+		store:      store,
+		interval:   interval,
+		monitor:    monitor,
+		logger:     logger,
+	}
+}
+
+// Start sweeps after every dropped watch until the context is cancelled.
+// It implements the controller-runtime Runnable interface.
+func (s *StalePolicySweeper) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			expired := s.monitor.expired.Swap(false)
+			if !s.monitor.dropped.Swap(false) && !expired {
+				continue
+			}
+			if expired {
+				if err := s.Rebuild(ctx); err != nil {
+					// Try again on the next tick
+					s.monitor.dropped.Store(true)
+					s.monitor.expired.Store(true)
+					s.logger.Error(err, "Desired state rebuild failed")
+				}
+				continue
+			}
+			if _, err := s.Sweep(ctx); err != nil {
+				// Still unreachable; try again on the next tick
+				s.monitor.dropped.Store(true)
+				s.logger.Error(err, "Stale policy sweep failed")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection implements LeaderElectionRunnable; every node cleans up its own endpoints
+func (s *StalePolicySweeper) NeedLeaderElection() bool {
+	return false
+}
+
+// Sweep lists the NetworkPolicies from the API server and removes the rules of
+// every policy of this source that no longer exists. It returns the removed keys.
+func (s *StalePolicySweeper) Sweep(ctx context.Context) ([]hcnpkg.PolicyKey, error) {
+	policies, err := s.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.sweep(policies)
+}
+
+// Rebuild re-reconciles every NetworkPolicy of this source, removes the rules
+// of deleted ones and repairs drift on the endpoints, rebuilding the desired
+// state from scratch instead of trusting that incremental events suffice
+func (s *StalePolicySweeper) Rebuild(ctx context.Context) error {
+	s.logger.Info("Watch resourceVersion expired, rebuilding desired state")
+	policies, err := s.list(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Read through to the API server: the cache may still be relisting and
+	// would report policies that exist as deleted
+	reconciler := *s.reconciler
+	reconciler.Client = apiReadClient{Client: s.reconciler.Client, reader: s.reader}
+
+	var rebuildErrors []error
+	for _, np := range policies.Items {
+		request := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: np.Namespace, Name: np.Name}}
+		if _, err := reconciler.Reconcile(ctx, request); err != nil {
+			rebuildErrors = append(rebuildErrors, fmt.Errorf("policy %s: %w", request.NamespacedName, err))
+		}
+	}
+	if _, err := s.sweep(policies); err != nil {
+		rebuildErrors = append(rebuildErrors, err)
+	}
+	if err := s.store.RepairDrift(); err != nil {
+		rebuildErrors = append(rebuildErrors, fmt.Errorf("failed to repair drift: %w", err))
+	}
+	return errors.Join(rebuildErrors...)
+}
+
+// list reads every NetworkPolicy from the API server
+func (s *StalePolicySweeper) list(ctx context.Context) (*networkingv1.NetworkPolicyList, error) {
+	var policies networkingv1.NetworkPolicyList
+	if err := s.reader.List(ctx, &policies); err != nil {
+		return nil, fmt.Errorf("failed to list NetworkPolicies: %w", err)
+	}
+	return &policies, nil
+}
+
+// sweep removes the rules of every policy of this source missing from policies
+func (s *StalePolicySweeper) sweep(policies *networkingv1.NetworkPolicyList) ([]hcnpkg.PolicyKey, error) {
+	existing := make(map[hcnpkg.PolicyKey]bool, len(policies.Items))
+	for _, np := range policies.Items {
+		existing[s.reconciler.policyKey(types.NamespacedName{Namespace: np.Namespace, Name: np.Name})] = true
+	}
+
+	var removed []hcnpkg.PolicyKey
+	var removeErrors []error
+	for _, key := range s.store.DesiredPolicyKeys() {
+		if existing[key] || !s.reconciler.ownsKey(key) {
+			continue
+		}
+		s.logger.Info("Removing rules of NetworkPolicy deleted while disconnected", "policyKey", key)
+		if err := s.store.RemoveACLRules(key); err != nil {
+			removeErrors = append(removeErrors, fmt.Errorf("policy %s: %w", key, err))
+			continue
+		}
+		recordWarnings(key, nil)
+		s.reconciler.releasePriorities(key)
+		removed = append(removed, key)
+	}
+
+	s.logger.Info("Stale policy sweep complete",
+		"policyCount", len(policies.Items),
+		"removedCount", len(removed))
+	return removed, errors.Join(removeErrors...)
+}
+
+// apiReadClient serves reads from the API server instead of the informer cache
+type apiReadClient struct {
+	client.Client
+	reader client.Reader
+}
+
+// Get implements client.Reader
+func (c apiReadClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	return c.reader.Get(ctx, key, obj, opts...)
+}
+
+// List implements client.Reader
+func (c apiReadClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return c.reader.List(ctx, list, opts...)
+}
+
+// ownsKey reports whether policyKey names a NetworkPolicy of this reconciler's
+// source: "netpol/namespace/name" locally, "netpol/source/namespace/name" otherwise
+func (r *NetworkPolicyReconciler) ownsKey(policyKey hcnpkg.PolicyKey) bool {
+	if policyKey.Kind() != hcnpkg.SourceNetworkPolicy {
+		return false
+	}
+	if r.SourceName == "" {
+		return strings.Count(policyKey.Name(), "/") == 1
+	}
+	rest, found := strings.CutPrefix(policyKey.Name(), r.SourceName+"/")
+	return found && strings.Count(rest, "/") == 1
+}
@@ -0,0 +1,149 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/go-logr/logr"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// mockPolicyStore records the keys removed by a sweep
+type mockPolicyStore struct {
+	desired  []hcnpkg.PolicyKey
+	removed  []hcnpkg.PolicyKey
+	repaired int
+}
+
+func (m *mockPolicyStore) DesiredPolicyKeys() []hcnpkg.PolicyKey {
+	return m.desired
+}
+
+func (m *mockPolicyStore) RemoveACLRules(policyKey hcnpkg.PolicyKey) error {
+	m.removed = append(m.removed, policyKey)
+	return nil
+}
+
+func (m *mockPolicyStore) RepairDrift() error {
+	m.repaired++
+	return nil
+}
+
+func TestStalePolicySweeper_Sweep(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = networkingv1.AddToScheme(scheme)
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "kept", Namespace: "default"}},
+	).Build()
+
+	tests := []struct {
+		name    string
+		source  string
+		desired []hcnpkg.PolicyKey
+		removed []hcnpkg.PolicyKey
+	}{
+		{
+			name:    "local policies deleted during the outage",
+			desired: []hcnpkg.PolicyKey{"netpol/default/kept", "netpol/default/deleted", "netpol/prod/deleted", "netpol/remote/default/kept"},
+			removed: []hcnpkg.PolicyKey{"netpol/default/deleted", "netpol/prod/deleted"},
+		},
+		{
+			name:    "only the reconciler's own source",
+			source:  "remote",
+			desired: []hcnpkg.PolicyKey{"netpol/default/deleted", "netpol/remote/default/kept", "netpol/remote/default/deleted", "netpol/other/default/deleted"},
+			removed: []hcnpkg.PolicyKey{"netpol/remote/default/deleted"},
+		},
+		{
+			name:    "keys of other source kinds named like NetworkPolicies",
+			desired: []hcnpkg.PolicyKey{"static/deleted", "anp/deleted", "namespacedefault/default/deleted"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockPolicyStore{desired: tt.desired}
+			reconciler := &NetworkPolicyReconciler{SourceName: tt.source}
+			sweeper := NewStalePolicySweeper(reconciler, reader, store, &WatchMonitor{}, 0, logr.Discard())
+
+			removed, err := sweeper.Sweep(context.Background())
+			if err != nil {
+				t.Fatalf("Sweep failed: %v", err)
+			}
+			slices.Sort(removed)
+			slices.Sort(store.removed)
+			if len(removed) != len(tt.removed) || len(store.removed) != len(tt.removed) {
+				t.Fatalf("Expected %v removed, got %v (store %v)", tt.removed, removed, store.removed)
+			}
+			for i := range tt.removed {
+				if removed[i] != tt.removed[i] || store.removed[i] != tt.removed[i] {
+					t.Errorf("Expected %v removed, got %v", tt.removed, removed)
+				}
+			}
+		})
+	}
+}
+
+func TestStalePolicySweeper_Rebuild(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = networkingv1.AddToScheme(scheme)
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "kept", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(np).Build()
+	// The cache has not caught up with the relist yet
+	cache := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	hcnManager := newMockHCNManager()
+	reconciler := &NetworkPolicyReconciler{Client: cache, Scheme: scheme, HCNManager: hcnManager, NodeName: "test-node",
+		ApplyScope: ApplyScopeAllEndpoints}
+	store := &mockPolicyStore{desired: []hcnpkg.PolicyKey{"netpol/default/kept", "netpol/default/deleted"}}
+	sweeper := NewStalePolicySweeper(reconciler, reader, store, &WatchMonitor{}, 0, logr.Discard())
+
+	if err := sweeper.Rebuild(context.Background()); err != nil {
+		t.Fatalf("Rebuild failed: %v", err)
+	}
+	if _, applied := hcnManager.appliedPolicies["netpol/default/kept"]; !applied {
+		t.Error("Expected the existing policy reconciled from the API server")
+	}
+	if len(hcnManager.removedPolicies) != 0 {
+		t.Errorf("Expected no policy removed through the stale cache, got %v", hcnManager.removedPolicies)
+	}
+	if len(store.removed) != 1 || store.removed[0] != "netpol/default/deleted" {
+		t.Errorf("Expected the deleted policy swept, got %v", store.removed)
+	}
+	if store.repaired != 1 {
+		t.Errorf("Expected one drift repair, got %d", store.repaired)
+	}
+}
+
+func TestWatchMonitor_ExpiredResourceVersion(t *testing.T) {
+	monitor := &WatchMonitor{}
+	monitor.WatchErrorHandler(&toolscache.Reflector{}, io.EOF)
+	if monitor.dropped.Load() {
+		t.Error("Expected a normally closed watch to be ignored")
+	}
+
+	monitor.WatchErrorHandler(&toolscache.Reflector{}, io.ErrUnexpectedEOF)
+	if !monitor.dropped.Load() || monitor.expired.Load() {
+		t.Error("Expected a plain watch failure to only mark the watch dropped")
+	}
+
+	monitor.WatchErrorHandler(&toolscache.Reflector{}, apierrors.NewResourceExpired("too old resource version: 1 (20)"))
+	if !monitor.expired.Load() {
+		t.Error("Expected a too old resourceVersion to request a rebuild")
+	}
+}
@@ -0,0 +1,35 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/knabben/firewall-controller/internal/converter"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// rejectPolicy handles a policy strict mode refused to convert: it records a
+// PolicyRejected event and the rejection metric, and removes the rules
+// programmed for an earlier version of the policy so none of it stays
+// enforced. Retrying does not help until the policy changes.
+func (r *NetworkPolicyReconciler) rejectPolicy(ctx context.Context, np *networkingv1.NetworkPolicy, policyKey hcnpkg.PolicyKey, rejection *converter.UnsupportedFieldsError) error {
+	logger := log.FromContext(ctx)
+	logger.Error(rejection, "NetworkPolicy rejected in strict mode",
+		"policyKey", policyKey,
+		"reasons", rejection.Reasons())
+	recordWarnings(policyKey, nil)
+	recordRejection(policyKey, rejection.Reasons())
+	if r.Recorder != nil {
+		r.Recorder.Event(np, corev1.EventTypeWarning, "PolicyRejected", rejection.Error())
+	}
+	if err := r.HCNManager.RemoveACLRules(policyKey); err != nil {
+		return err
+	}
+	r.releasePriorities(policyKey)
+	return nil
+}
@@ -0,0 +1,174 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/client-go/tools/record"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	"github.com/knabben/firewall-controller/internal/verify"
+)
+
+// VerificationOptions bounds the probes sent for each applied policy
+type VerificationOptions struct {
+	// MaxEndpoints is how many of a policy's endpoints are probed
+	MaxEndpoints int
+
+	// MaxProbes is how many ports are probed on each endpoint
+	MaxProbes int
+}
+
+// PolicyVerifier checks, after a NetworkPolicy is applied, that HNS enforces
+// its ingress rules: it sends TCP connection attempts from the node to the
+// ports the rules name on the policy's endpoints and compares the outcome
+// with the verdict of each endpoint's ACL table. HNS can accept a rule it
+// does not enforce; mismatches are recorded as VerificationFailed events on
+// the policy and exported as a metric.
+type PolicyVerifier struct {
+	manager  hcnpkg.TableReader
+	prober   verify.Prober
+	recorder record.EventRecorder
+	opts     VerificationOptions
+	logger   logr.Logger
+
+	// queue holds the policies waiting for verification
+	queue chan verificationRequest
+}
+
+// verificationRequest asks for the rules of policyKey to be verified
+type verificationRequest struct {
+	policy    *networkingv1.NetworkPolicy
+	policyKey hcnpkg.PolicyKey
+}
+
+// NewPolicyVerifier creates a verifier; recorder may be nil
+func NewPolicyVerifier(manager hcnpkg.TableReader, prober verify.Prober, recorder record.EventRecorder,
+	opts VerificationOptions, logger logr.Logger) *PolicyVerifier {
+	return &PolicyVerifier{
+		manager:  manager,
+		prober:   prober,
+		recorder: recorder,
+		opts:     opts,
+		logger:   logger,
+		queue:    make(chan verificationRequest, 256),
+	}
+}
+
+// Enqueue schedules the verification of an applied policy. Requests are
+// dropped while the queue is full; the policy's next apply asks again.
+func (v *PolicyVerifier) Enqueue(np *networkingv1.NetworkPolicy, policyKey hcnpkg.PolicyKey) {
+	select {
+	case v.queue <- verificationRequest{policy: np.DeepCopy(), policyKey: policyKey}:
+	default:
+		v.logger.V(1).Info("Verification queue full, skipping policy", "policyKey", policyKey)
+	}
+}
+
+// Start verifies queued policies one at a time until the context is
+// cancelled. It implements the controller-runtime Runnable interface.
+func (v *PolicyVerifier) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case request := <-v.queue:
+			v.report(request, v.Verify(ctx, request.policyKey))
+		}
+	}
+}
+
+// NeedLeaderElection implements LeaderElectionRunnable; every agent probes its own node
+func (v *PolicyVerifier) NeedLeaderElection() bool {
+	return false
+}
+
+// Verify probes the endpoints carrying the rules of policyKey
+func (v *PolicyVerifier) Verify(ctx context.Context, policyKey hcnpkg.PolicyKey) []verify.Result {
+	ruleSets, _ := v.manager.GetAppliedPolicies(policyKey)
+	var results []verify.Result
+	probed := 0
+	for _, ruleSet := range ruleSets {
+		if probed == v.opts.MaxEndpoints {
+			break
+		}
+		endpoint, found := v.manager.EndpointByID(ruleSet.EndpointID)
+		if !found {
+			continue
+		}
+		address, ok := endpointAddress(endpoint.IpConfigurations)
+		if !ok {
+			continue
+		}
+		source, err := v.prober.Source(address)
+		if err != nil {
+			v.logger.V(1).Info("No route to endpoint, skipping verification",
+				"endpointID", endpoint.Id, "address", address, "error", err.Error())
+			continue
+		}
+		var table []hcnpkg.ACLRule
+		for _, rules := range v.manager.DesiredTable(endpoint) {
+			table = append(table, rules...)
+		}
+		probed++
+		for _, probe := range verify.PlanProbes(endpoint.Id, address, source, ruleSet.Rules, table, v.opts.MaxProbes) {
+			observed, err := v.prober.Probe(ctx, probe)
+			if ctx.Err() != nil {
+				return results
+			}
+			results = append(results, verify.Result{Probe: probe, Observed: observed, Err: err})
+		}
+	}
+	return results
+}
+
+// report logs the results of a verification, records failures as events and exports their count
+func (v *PolicyVerifier) report(request verificationRequest, results []verify.Result) {
+	var failures []string
+	for _, result := range results {
+		if result.Err != nil {
+			v.logger.V(1).Info("Verification probe failed", "policyKey", request.policyKey, "probe", result.String())
+			continue
+		}
+		if result.Failed() {
+			failures = append(failures, result.String())
+		}
+	}
+	recordVerification(request.policyKey, len(results), len(failures))
+	if len(failures) == 0 {
+		v.logger.V(1).Info("Verified policy enforcement", "policyKey", request.policyKey, "probes", len(results))
+		return
+	}
+	v.logger.Info("HNS does not enforce the programmed rules",
+		"policyKey", request.policyKey,
+		"failures", failures)
+	if v.recorder != nil {
+		v.recorder.Event(request.policy, corev1.EventTypeWarning, "VerificationFailed",
+			"Rules accepted by HNS but not enforced: "+strings.Join(failures, "; "))
+	}
+}
+
+// endpointAddress returns the IPv4 address of an endpoint, or its first one
+func endpointAddress(configs []hcnlib.IpConfig) (netip.Addr, bool) {
+	var first netip.Addr
+	for _, config := range configs {
+		addr, err := netip.ParseAddr(config.IpAddress)
+		if err != nil {
+			continue
+		}
+		if addr.Is4() {
+			return addr, true
+		}
+		if !first.IsValid() {
+			first = addr
+		}
+	}
+	return first, first.IsValid()
+}
@@ -0,0 +1,78 @@
+//go:build windows
+
+package controller
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	"github.com/knabben/firewall-controller/internal/verify"
+)
+
+// fakeTables serves the tracked rule sets and tables of a single endpoint
+type fakeTables struct {
+	endpoint hcnlib.HostComputeEndpoint
+	rules    []hcnpkg.ACLRule
+}
+
+func (f *fakeTables) GetAppliedPolicies(policyKey hcnpkg.PolicyKey) ([]hcnpkg.RuleSet, bool) {
+	return []hcnpkg.RuleSet{{EndpointID: f.endpoint.Id, Rules: f.rules}}, true
+}
+
+func (f *fakeTables) EndpointByID(id string) (hcnlib.HostComputeEndpoint, bool) {
+	return f.endpoint, id == f.endpoint.Id
+}
+
+func (f *fakeTables) DesiredTable(hcnlib.HostComputeEndpoint) map[string][]hcnpkg.ACLRule {
+	return map[string][]hcnpkg.ACLRule{"netpol/default/web": f.rules}
+}
+
+// fakeProber observes the verdicts of a map keyed by port
+type fakeProber map[uint16]verify.Verdict
+
+func (f fakeProber) Probe(_ context.Context, probe verify.Probe) (verify.Verdict, error) {
+	return f[probe.Port], nil
+}
+
+func (f fakeProber) Source(netip.Addr) (netip.Addr, error) {
+	return netip.MustParseAddr("10.0.0.4"), nil
+}
+
+func TestPolicyVerifier(t *testing.T) {
+	tables := &fakeTables{
+		endpoint: hcnlib.HostComputeEndpoint{Id: "ep-1", IpConfigurations: []hcnlib.IpConfig{{IpAddress: "10.244.0.5"}}},
+		rules: []hcnpkg.ACLRule{
+			{Name: "allow-http", Action: hcnlib.ActionTypeAllow, Direction: hcnlib.DirectionTypeIn, Protocol: "6",
+				LocalPorts: "80,443", Priority: 100},
+		},
+	}
+	// HNS accepted the rule but drops port 443
+	prober := fakeProber{80: verify.Allowed, 443: verify.Blocked}
+	recorder := record.NewFakeRecorder(10)
+	verifier := NewPolicyVerifier(tables, prober, recorder, VerificationOptions{MaxEndpoints: 4, MaxProbes: 4}, logr.Discard())
+
+	results := verifier.Verify(context.Background(), "netpol/default/web")
+	if len(results) != 2 || results[0].Failed() || !results[1].Failed() {
+		t.Fatalf("Expected port 443 to fail verification, got %v", results)
+	}
+
+	np := &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	verifier.report(verificationRequest{policy: np, policyKey: "netpol/default/web"}, results)
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, "VerificationFailed") || !strings.Contains(event, ":443") {
+			t.Errorf("Unexpected event: %s", event)
+		}
+	default:
+		t.Error("Expected a VerificationFailed event")
+	}
+}
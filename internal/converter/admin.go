@@ -0,0 +1,310 @@
+//go:build windows
+
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// ErrPassOverlap is returned when a rule after a Pass rule matches part, but not
+// all, of the Pass rule's traffic. HCN ACLs are a flat priority list without
+// tiers, so such a rule cannot be skipped for the passed traffic only.
+var ErrPassOverlap = errors.New("rule partially overlaps an earlier Pass rule")
+
+// AdminAction is the action of an admin-tier rule
+type AdminAction string
+
+const (
+	// AdminActionAllow accepts the traffic without consulting lower tiers
+	AdminActionAllow AdminAction = "Allow"
+	// AdminActionDeny drops the traffic without consulting lower tiers
+	AdminActionDeny AdminAction = "Deny"
+	// AdminActionPass stops admin-tier evaluation and delegates the traffic to
+	// the namespace tier (NetworkPolicies)
+	AdminActionPass AdminAction = "Pass"
+)
+
+// AdminRule is a single admin-tier rule, already resolved to addresses
+type AdminRule struct {
+	Name            string
+	Action          AdminAction
+	Direction       hcnlib.DirectionType
+	Protocol        string
+	LocalPorts      string
+	RemotePorts     string
+	RemoteAddresses string
+}
+
+// AdminPolicy is an ordered list of admin-tier rules. Policies with a lower
+// Priority are evaluated first, as with AdminNetworkPolicy.
+type AdminPolicy struct {
+	Name     string
+	Priority int32
+	Rules    []AdminRule
+}
+
+// AdminTierOptions places the admin tier in the ACL priority space. The band must
+// sit entirely above (lower numbers than) the namespace tier so admin decisions
+// are evaluated before any NetworkPolicy rule.
+type AdminTierOptions struct {
+	// BasePriority is the priority of the first admin-tier rule
+	BasePriority uint16
+
+	// MaxPriority is the highest priority an admin-tier rule may use
+	MaxPriority uint16
+}
+
+// Validate checks that the admin band is well formed and precedes the namespace
+// tier starting at namespaceBase
+func (o AdminTierOptions) Validate(namespaceBase uint16) error {
+	if o.BasePriority < hcnpkg.MinPriority || o.BasePriority > o.MaxPriority {
+		return fmt.Errorf("invalid admin priority band %d-%d", o.BasePriority, o.MaxPriority)
+	}
+	if o.MaxPriority >= namespaceBase {
+		return fmt.Errorf("admin priority band %d-%d must end below the namespace tier base %d",
+			o.BasePriority, o.MaxPriority, namespaceBase)
+	}
+	return nil
+}
+
+// AdminPoliciesToACLRules flattens admin-tier policies into ACL rules.
+//
+// Allow and Deny rules are emitted in evaluation order. A Pass rule emits no ACL;
+// instead every later admin-tier rule whose traffic it fully covers is skipped,
+// so the passed traffic falls through to the namespace tier below. Later rules
+// that only partially overlap a Pass rule fail with ErrPassOverlap.
+func AdminPoliciesToACLRules(policies []AdminPolicy, opts AdminTierOptions) ([]hcnpkg.ACLRule, error) {
+	ordered := make([]AdminPolicy, len(policies))
+	copy(ordered, policies)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Priority != ordered[j].Priority {
+			return ordered[i].Priority < ordered[j].Priority
+		}
+		return ordered[i].Name < ordered[j].Name
+	})
+
+	priorities, err := hcnpkg.NewPriorityPool(opts.BasePriority, opts.MaxPriority, 1, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []hcnpkg.ACLRule
+	var passed []AdminRule
+
+	for _, policy := range ordered {
+		for _, rule := range policy.Rules {
+			// Passes only ever widen the delegated traffic, so they need no shadow check
+			if rule.Action == AdminActionPass {
+				passed = append(passed, rule)
+				continue
+			}
+
+			skip, err := shadowedByPass(rule, passed)
+			if err != nil {
+				return nil, fmt.Errorf("admin policy %s rule %s: %w", policy.Name, rule.Name, err)
+			}
+			if skip {
+				continue
+			}
+
+			var action hcnlib.ActionType
+			switch rule.Action {
+			case AdminActionAllow:
+				action = hcnlib.ActionTypeAllow
+			case AdminActionDeny:
+				action = hcnlib.ActionTypeBlock
+			default:
+				return nil, fmt.Errorf("admin policy %s rule %s: invalid action %q", policy.Name, rule.Name, rule.Action)
+			}
+
+			rules = append(rules, hcnpkg.ACLRule{
+				Name:            fmt.Sprintf("admin/%s/%s", policy.Name, rule.Name),
+				Action:          action,
+				Direction:       rule.Direction,
+				Protocol:        rule.Protocol,
+				LocalPorts:      rule.LocalPorts,
+				RemotePorts:     rule.RemotePorts,
+				RemoteAddresses: rule.RemoteAddresses,
+				Priority:        priorities.Next(),
+			})
+		}
+	}
+
+	if err := priorities.Err(); err != nil {
+		return nil, fmt.Errorf("admin policies generate too many rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// matchRelation describes how the traffic matched by one rule relates to another's
+type matchRelation int
+
+const (
+	// matchDisjoint means no packet matches both rules
+	matchDisjoint matchRelation = iota
+	// matchPartial means some, but not all, packets of the inner rule match the outer
+	matchPartial
+	// matchCovers means every packet of the inner rule matches the outer
+	matchCovers
+)
+
+// shadowedByPass reports whether rule is fully covered by an earlier Pass rule
+func shadowedByPass(rule AdminRule, passed []AdminRule) (bool, error) {
+	for _, pass := range passed {
+		switch ruleRelation(pass, rule) {
+		case matchCovers:
+			return true, nil
+		case matchPartial:
+			return false, fmt.Errorf("%w %s", ErrPassOverlap, pass.Name)
+		}
+	}
+	return false, nil
+}
+
+// ruleRelation compares the traffic matched by outer and inner
+func ruleRelation(outer, inner AdminRule) matchRelation {
+	if outer.Direction != inner.Direction {
+		return matchDisjoint
+	}
+
+	relations := []matchRelation{
+		fieldRelation(outer.Protocol, inner.Protocol, protocolsOverlap, protocolsOverlap),
+		fieldRelation(outer.LocalPorts, inner.LocalPorts, portsContain, portsOverlap),
+		fieldRelation(outer.RemotePorts, inner.RemotePorts, portsContain, portsOverlap),
+		fieldRelation(outer.RemoteAddresses, inner.RemoteAddresses, addressesContain, addressesOverlap),
+	}
+
+	result := matchCovers
+	for _, relation := range relations {
+		if relation < result {
+			result = relation
+		}
+	}
+	return result
+}
+
+// fieldRelation compares two comma-separated match fields, where empty means any
+func fieldRelation(outer, inner string, contains, overlaps func(a, b string) bool) matchRelation {
+	if outer == "" {
+		return matchCovers
+	}
+	if inner == "" {
+		return matchPartial
+	}
+
+	outerTokens := strings.Split(outer, ",")
+	innerTokens := strings.Split(inner, ",")
+
+	covered := true
+	anyOverlap := false
+	for _, i := range innerTokens {
+		tokenCovered := false
+		for _, o := range outerTokens {
+			if contains(o, i) {
+				tokenCovered = true
+			}
+			if overlaps(o, i) {
+				anyOverlap = true
+			}
+		}
+		covered = covered && tokenCovered
+	}
+
+	switch {
+	case covered:
+		return matchCovers
+	case anyOverlap:
+		return matchPartial
+	default:
+		return matchDisjoint
+	}
+}
+
+// protocolsOverlap compares single protocol numbers
+func protocolsOverlap(a, b string) bool {
+	return strings.TrimSpace(a) == strings.TrimSpace(b)
+}
+
+// portRange parses "80" or "8000-8080"
+func portRange(s string) (uint64, uint64, bool) {
+	s = strings.TrimSpace(s)
+	low, high, isRange := strings.Cut(s, "-")
+	start, err := strconv.ParseUint(low, 10, 16)
+	if err != nil {
+		return 0, 0, false
+	}
+	if !isRange {
+		return start, start, true
+	}
+	end, err := strconv.ParseUint(high, 10, 16)
+	if err != nil || end < start {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// portsContain reports whether port range a contains port range b
+func portsContain(a, b string) bool {
+	aStart, aEnd, okA := portRange(a)
+	bStart, bEnd, okB := portRange(b)
+	if !okA || !okB {
+		return strings.TrimSpace(a) == strings.TrimSpace(b)
+	}
+	return aStart <= bStart && bEnd <= aEnd
+}
+
+// portsOverlap reports whether port ranges a and b share a port
+func portsOverlap(a, b string) bool {
+	aStart, aEnd, okA := portRange(a)
+	bStart, bEnd, okB := portRange(b)
+	if !okA || !okB {
+		// Unparseable tokens are compared literally; assume the worst otherwise
+		return true
+	}
+	return aStart <= bEnd && bStart <= aEnd
+}
+
+// addressNet parses an IP or CIDR into a network
+func addressNet(s string) (*net.IPNet, bool) {
+	s = strings.TrimSpace(s)
+	if ip := net.ParseIP(s); ip != nil {
+		bits := 32
+		if ip.To4() == nil {
+			bits = 128
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, true
+	}
+	_, ipNet, err := net.ParseCIDR(s)
+	return ipNet, err == nil
+}
+
+// addressesContain reports whether address block a contains address block b
+func addressesContain(a, b string) bool {
+	aNet, okA := addressNet(a)
+	bNet, okB := addressNet(b)
+	if !okA || !okB {
+		return strings.TrimSpace(a) == strings.TrimSpace(b)
+	}
+	aOnes, aBits := aNet.Mask.Size()
+	bOnes, bBits := bNet.Mask.Size()
+	return aBits == bBits && aOnes <= bOnes && aNet.Contains(bNet.IP)
+}
+
+// addressesOverlap reports whether address blocks a and b share an address
+func addressesOverlap(a, b string) bool {
+	aNet, okA := addressNet(a)
+	bNet, okB := addressNet(b)
+	if !okA || !okB {
+		return true
+	}
+	return aNet.Contains(bNet.IP) || bNet.Contains(aNet.IP)
+}
@@ -0,0 +1,163 @@
+//go:build windows
+
+package converter
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// packet is a single flow evaluated against flattened ACL rules
+type packet struct {
+	direction  hcnlib.DirectionType
+	protocol   string
+	remotePort string
+	remoteAddr string
+}
+
+// evaluateTiers returns the action of the first matching admin-tier ACL by
+// priority, or "namespace" when the packet falls through to the namespace tier
+func evaluateTiers(rules []hcnpkg.ACLRule, p packet) string {
+	ordered := make([]hcnpkg.ACLRule, len(rules))
+	copy(ordered, rules)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Priority < ordered[j].Priority })
+
+	flow := AdminRule{Direction: p.direction, Protocol: p.protocol, RemotePorts: p.remotePort, RemoteAddresses: p.remoteAddr}
+	for _, rule := range ordered {
+		match := AdminRule{
+			Direction:       rule.Direction,
+			Protocol:        rule.Protocol,
+			RemotePorts:     rule.RemotePorts,
+			RemoteAddresses: rule.RemoteAddresses,
+		}
+		if ruleRelation(match, flow) == matchCovers {
+			return string(rule.Action)
+		}
+	}
+	return "namespace"
+}
+
+func TestAdminPoliciesToACLRules_Conformance(t *testing.T) {
+	opts := AdminTierOptions{BasePriority: 10, MaxPriority: 99}
+	web := packet{direction: hcnlib.DirectionTypeOut, protocol: "6", remotePort: "443", remoteAddr: "10.1.2.3"}
+	dns := packet{direction: hcnlib.DirectionTypeOut, protocol: "17", remotePort: "53", remoteAddr: "10.96.0.10"}
+
+	tests := []struct {
+		name     string
+		policies []AdminPolicy
+		expected map[packet]string
+	}{
+		{
+			name: "pass delegates matching traffic to the namespace tier",
+			policies: []AdminPolicy{{Name: "a", Priority: 1, Rules: []AdminRule{
+				{Name: "pass-web", Action: AdminActionPass, Direction: hcnlib.DirectionTypeOut, Protocol: "6", RemotePorts: "443"},
+				{Name: "deny-web-10", Action: AdminActionDeny, Direction: hcnlib.DirectionTypeOut, Protocol: "6", RemotePorts: "443", RemoteAddresses: "10.0.0.0/8"},
+				{Name: "deny-dns", Action: AdminActionDeny, Direction: hcnlib.DirectionTypeOut, Protocol: "17", RemotePorts: "53"},
+			}}},
+			expected: map[packet]string{web: "namespace", dns: string(hcnlib.ActionTypeBlock)},
+		},
+		{
+			name: "earlier allow wins over a later pass",
+			policies: []AdminPolicy{{Name: "a", Priority: 1, Rules: []AdminRule{
+				{Name: "allow-web", Action: AdminActionAllow, Direction: hcnlib.DirectionTypeOut, Protocol: "6", RemotePorts: "443"},
+				{Name: "pass-all", Action: AdminActionPass, Direction: hcnlib.DirectionTypeOut},
+				{Name: "deny-all", Action: AdminActionDeny, Direction: hcnlib.DirectionTypeOut},
+			}}},
+			expected: map[packet]string{web: string(hcnlib.ActionTypeAllow), dns: "namespace"},
+		},
+		{
+			name: "pass in a higher priority policy skips lower priority policies",
+			policies: []AdminPolicy{
+				{Name: "low", Priority: 20, Rules: []AdminRule{
+					{Name: "deny-web", Action: AdminActionDeny, Direction: hcnlib.DirectionTypeOut, Protocol: "6", RemotePorts: "443"},
+				}},
+				{Name: "high", Priority: 5, Rules: []AdminRule{
+					{Name: "pass-web", Action: AdminActionPass, Direction: hcnlib.DirectionTypeOut, Protocol: "6", RemotePorts: "443"},
+				}},
+			},
+			expected: map[packet]string{web: "namespace"},
+		},
+		{
+			name: "pass in the other direction does not apply",
+			policies: []AdminPolicy{{Name: "a", Priority: 1, Rules: []AdminRule{
+				{Name: "pass-in", Action: AdminActionPass, Direction: hcnlib.DirectionTypeIn},
+				{Name: "deny-dns", Action: AdminActionDeny, Direction: hcnlib.DirectionTypeOut, Protocol: "17", RemotePorts: "53"},
+			}}},
+			expected: map[packet]string{dns: string(hcnlib.ActionTypeBlock), web: "namespace"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := AdminPoliciesToACLRules(tt.policies, opts)
+			if err != nil {
+				t.Fatalf("AdminPoliciesToACLRules failed: %v", err)
+			}
+			for _, rule := range rules {
+				if rule.Priority < opts.BasePriority || rule.Priority > opts.MaxPriority {
+					t.Errorf("Rule %s priority %d outside admin band", rule.Name, rule.Priority)
+				}
+			}
+			for p, want := range tt.expected {
+				if got := evaluateTiers(rules, p); got != want {
+					t.Errorf("packet %+v: got %s, want %s", p, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminPoliciesToACLRules_PartialOverlap(t *testing.T) {
+	policies := []AdminPolicy{{Name: "a", Priority: 1, Rules: []AdminRule{
+		{Name: "pass-web", Action: AdminActionPass, Direction: hcnlib.DirectionTypeOut, Protocol: "6", RemotePorts: "443"},
+		{Name: "deny-all", Action: AdminActionDeny, Direction: hcnlib.DirectionTypeOut},
+	}}}
+
+	_, err := AdminPoliciesToACLRules(policies, AdminTierOptions{BasePriority: 10, MaxPriority: 99})
+	if !errors.Is(err, ErrPassOverlap) {
+		t.Fatalf("Expected ErrPassOverlap, got %v", err)
+	}
+}
+
+func TestAdminTierOptions_Validate(t *testing.T) {
+	if err := (AdminTierOptions{BasePriority: 10, MaxPriority: 99}).Validate(100); err != nil {
+		t.Errorf("Expected valid band, got %v", err)
+	}
+	if err := (AdminTierOptions{BasePriority: 10, MaxPriority: 100}).Validate(100); err == nil {
+		t.Error("Expected error for admin band overlapping the namespace tier")
+	}
+}
+
+func TestFieldRelation(t *testing.T) {
+	tests := []struct {
+		name     string
+		outer    string
+		inner    string
+		expected matchRelation
+	}{
+		{name: "any covers specific", outer: "", inner: "80", expected: matchCovers},
+		{name: "specific within any is partial", outer: "80", inner: "", expected: matchPartial},
+		{name: "range covers port", outer: "80-90", inner: "85", expected: matchCovers},
+		{name: "range overlaps range", outer: "80-90", inner: "85-95", expected: matchPartial},
+		{name: "disjoint ports", outer: "80", inner: "443", expected: matchDisjoint},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fieldRelation(tt.outer, tt.inner, portsContain, portsOverlap); got != tt.expected {
+				t.Errorf("fieldRelation(%q, %q) = %v, want %v", tt.outer, tt.inner, got, tt.expected)
+			}
+		})
+	}
+
+	if got := fieldRelation("10.0.0.0/8", "10.1.0.0/16", addressesContain, addressesOverlap); got != matchCovers {
+		t.Errorf("Expected /8 to cover /16, got %v", got)
+	}
+	if got := fieldRelation("10.0.0.0/8", "192.168.0.1", addressesContain, addressesOverlap); got != matchDisjoint {
+		t.Errorf("Expected disjoint address blocks, got %v", got)
+	}
+}
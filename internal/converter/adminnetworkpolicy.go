@@ -0,0 +1,242 @@
+//go:build windows
+
+package converter
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// AdminNetworkPolicyGVK identifies the sig-network AdminNetworkPolicy API. Its
+// types are not vendored, so objects are read as unstructured and decoded into
+// the mirror types below.
+var AdminNetworkPolicyGVK = schema.GroupVersionKind{
+	Group:   "policy.networking.k8s.io",
+	Version: "v1alpha1",
+	Kind:    "AdminNetworkPolicy",
+}
+
+// AdminNetworkPolicy mirrors the fields of an AdminNetworkPolicy the agent enforces
+type AdminNetworkPolicy struct {
+	Name string                 `json:"-"`
+	Spec AdminNetworkPolicySpec `json:"spec"`
+}
+
+// AdminNetworkPolicySpec mirrors spec of an AdminNetworkPolicy
+type AdminNetworkPolicySpec struct {
+	Priority int32                           `json:"priority"`
+	Subject  AdminNetworkPolicySubject       `json:"subject"`
+	Ingress  []AdminNetworkPolicyIngressRule `json:"ingress,omitempty"`
+	Egress   []AdminNetworkPolicyEgressRule  `json:"egress,omitempty"`
+}
+
+// AdminNetworkPolicySubject selects the pods a policy applies to; exactly one field is set
+type AdminNetworkPolicySubject struct {
+	Namespaces *metav1.LabelSelector `json:"namespaces,omitempty"`
+	Pods       *NamespacedPod        `json:"pods,omitempty"`
+}
+
+// NamespacedPod selects pods by label within the namespaces selected by label
+type NamespacedPod struct {
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector"`
+	PodSelector       metav1.LabelSelector `json:"podSelector"`
+}
+
+// AdminNetworkPolicyIngressRule mirrors an entry of spec.ingress
+type AdminNetworkPolicyIngressRule struct {
+	Name   string                   `json:"name,omitempty"`
+	Action AdminAction              `json:"action"`
+	From   []AdminNetworkPolicyPeer `json:"from"`
+	Ports  []AdminNetworkPolicyPort `json:"ports,omitempty"`
+}
+
+// AdminNetworkPolicyEgressRule mirrors an entry of spec.egress
+type AdminNetworkPolicyEgressRule struct {
+	Name   string                   `json:"name,omitempty"`
+	Action AdminAction              `json:"action"`
+	To     []AdminNetworkPolicyPeer `json:"to"`
+	Ports  []AdminNetworkPolicyPort `json:"ports,omitempty"`
+}
+
+// AdminNetworkPolicyPeer is a source or destination of a rule; exactly one field is set
+type AdminNetworkPolicyPeer struct {
+	Namespaces *metav1.LabelSelector `json:"namespaces,omitempty"`
+	Pods       *NamespacedPod        `json:"pods,omitempty"`
+	Nodes      *metav1.LabelSelector `json:"nodes,omitempty"`
+	Networks   []string              `json:"networks,omitempty"`
+}
+
+// AdminNetworkPolicyPort is a destination port of a rule; exactly one field is set
+type AdminNetworkPolicyPort struct {
+	PortNumber *AdminPortNumber `json:"portNumber,omitempty"`
+	NamedPort  *string          `json:"namedPort,omitempty"`
+	PortRange  *AdminPortRange  `json:"portRange,omitempty"`
+}
+
+// AdminPortNumber is a single destination port
+type AdminPortNumber struct {
+	Protocol corev1.Protocol `json:"protocol"`
+	Port     int32           `json:"port"`
+}
+
+// AdminPortRange is an inclusive range of destination ports
+type AdminPortRange struct {
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+	Start    int32           `json:"start"`
+	End      int32           `json:"end"`
+}
+
+// AdminNetworkPolicyFromUnstructured decodes an AdminNetworkPolicy read as unstructured
+func AdminNetworkPolicyFromUnstructured(obj *unstructured.Unstructured) (*AdminNetworkPolicy, error) {
+	var anp AdminNetworkPolicy
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &anp); err != nil {
+		return nil, fmt.Errorf("failed to decode AdminNetworkPolicy %s: %w", obj.GetName(), err)
+	}
+	anp.Name = obj.GetName()
+	return &anp, nil
+}
+
+// AdminPeerResolver returns the addresses of the pods an AdminNetworkPolicy
+// peer selects, and whether the peer kind is supported at all
+type AdminPeerResolver func(peer AdminNetworkPolicyPeer) ([]string, bool, error)
+
+// AdminNetworkPolicyToAdminPolicy translates anp into an admin-tier policy for
+// AdminPoliciesToACLRules, resolving pod and namespace peers with resolve.
+// Every protocol of a rule's ports becomes a rule of its own. A rule whose
+// peers or ports all resolve to nothing matches no traffic and is left out,
+// since an empty address or port list would match everything instead.
+func AdminNetworkPolicyToAdminPolicy(anp *AdminNetworkPolicy, resolve AdminPeerResolver) (AdminPolicy, []Warning, error) {
+	policy := AdminPolicy{Name: anp.Name, Priority: anp.Spec.Priority}
+	var warnings []Warning
+
+	for i, rule := range anp.Spec.Ingress {
+		rules, ruleWarnings, err := adminRules(anp.Name, adminRuleName(rule.Name, "ingress", i),
+			rule.Action, hcnlib.DirectionTypeIn, rule.From, rule.Ports, resolve)
+		if err != nil {
+			return AdminPolicy{}, nil, err
+		}
+		policy.Rules = append(policy.Rules, rules...)
+		warnings = append(warnings, ruleWarnings...)
+	}
+	for i, rule := range anp.Spec.Egress {
+		rules, ruleWarnings, err := adminRules(anp.Name, adminRuleName(rule.Name, "egress", i),
+			rule.Action, hcnlib.DirectionTypeOut, rule.To, rule.Ports, resolve)
+		if err != nil {
+			return AdminPolicy{}, nil, err
+		}
+		policy.Rules = append(policy.Rules, rules...)
+		warnings = append(warnings, ruleWarnings...)
+	}
+	return policy, warnings, nil
+}
+
+// adminRuleName names a rule by its name or, when unnamed, its position
+func adminRuleName(name, direction string, index int) string {
+	if name != "" {
+		return name
+	}
+	return fmt.Sprintf("%s-%d", direction, index)
+}
+
+// adminRules translates one ingress or egress rule of policyName
+func adminRules(policyName, name string, action AdminAction, direction hcnlib.DirectionType,
+	peers []AdminNetworkPolicyPeer, ports []AdminNetworkPolicyPort, resolve AdminPeerResolver) ([]AdminRule, []Warning, error) {
+	switch action {
+	case AdminActionAllow, AdminActionDeny, AdminActionPass:
+	default:
+		return nil, nil, fmt.Errorf("admin policy %s rule %s: invalid action %q", policyName, name, action)
+	}
+
+	var warnings []Warning
+	var addresses []string
+	for _, peer := range peers {
+		if len(peer.Networks) > 0 {
+			addresses = append(addresses, peer.Networks...)
+			continue
+		}
+		resolved, supported, err := resolve(peer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("admin policy %s rule %s: %w", policyName, name, err)
+		}
+		if !supported {
+			warnings = append(warnings, Warning{
+				Reason:  WarningSelectorUnsupported,
+				Message: fmt.Sprintf("admin policy %s rule %s: nodes peers are not enforced", policyName, name),
+			})
+			continue
+		}
+		addresses = append(addresses, resolved...)
+	}
+	if len(addresses) == 0 {
+		return nil, warnings, nil
+	}
+	sort.Strings(addresses)
+	remote := strings.Join(slices.Compact(addresses), ",")
+
+	byProtocol, order, portWarnings := adminPortsByProtocol(policyName, name, ports)
+	warnings = append(warnings, portWarnings...)
+	if len(ports) > 0 && len(order) == 0 {
+		return nil, warnings, nil
+	}
+
+	rule := AdminRule{Name: name, Action: action, Direction: direction, RemoteAddresses: remote}
+	if len(order) == 0 {
+		return []AdminRule{rule}, warnings, nil
+	}
+	rules := make([]AdminRule, 0, len(order))
+	for _, protocol := range order {
+		protoRule := rule
+		if len(order) > 1 {
+			protoRule.Name = name + "-" + protocol
+		}
+		protoRule.Protocol = protocol
+		// Ports are destination ports: the subject's own for ingress, the peer's for egress
+		if direction == hcnlib.DirectionTypeIn {
+			protoRule.LocalPorts = strings.Join(byProtocol[protocol], ",")
+		} else {
+			protoRule.RemotePorts = strings.Join(byProtocol[protocol], ",")
+		}
+		rules = append(rules, protoRule)
+	}
+	return rules, warnings, nil
+}
+
+// adminPortsByProtocol groups a rule's ports by protocol number, in order of
+// first appearance. Named ports are dropped with a warning.
+func adminPortsByProtocol(policyName, name string, ports []AdminNetworkPolicyPort) (map[string][]string, []string, []Warning) {
+	byProtocol := make(map[string][]string)
+	var order []string
+	var warnings []Warning
+	add := func(protocol corev1.Protocol, port string) {
+		number := protocolToNumber(&protocol)
+		if _, seen := byProtocol[number]; !seen {
+			order = append(order, number)
+		}
+		byProtocol[number] = append(byProtocol[number], port)
+	}
+
+	for _, port := range ports {
+		switch {
+		case port.PortNumber != nil:
+			add(port.PortNumber.Protocol, strconv.Itoa(int(port.PortNumber.Port)))
+		case port.PortRange != nil:
+			add(port.PortRange.Protocol, fmt.Sprintf("%d-%d", port.PortRange.Start, port.PortRange.End))
+		case port.NamedPort != nil:
+			warnings = append(warnings, Warning{
+				Reason:  WarningNamedPortDropped,
+				Message: fmt.Sprintf("admin policy %s rule %s: named port %q is not enforced", policyName, name, *port.NamedPort),
+			})
+		}
+	}
+	return byProtocol, order, warnings
+}
@@ -0,0 +1,116 @@
+//go:build windows
+
+package converter
+
+import (
+	"testing"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestAdminNetworkPolicyToAdminPolicy(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "policy.networking.k8s.io/v1alpha1",
+		"kind":       "AdminNetworkPolicy",
+		"metadata":   map[string]interface{}{"name": "cluster-baseline"},
+		"spec": map[string]interface{}{
+			"priority": int64(10),
+			"subject":  map[string]interface{}{"namespaces": map[string]interface{}{}},
+			"ingress": []interface{}{
+				map[string]interface{}{
+					"name":   "allow-monitoring",
+					"action": "Allow",
+					"from": []interface{}{
+						map[string]interface{}{"namespaces": map[string]interface{}{
+							"matchLabels": map[string]interface{}{"team": "monitoring"},
+						}},
+					},
+					"ports": []interface{}{
+						map[string]interface{}{"portNumber": map[string]interface{}{"protocol": "TCP", "port": int64(9090)}},
+						map[string]interface{}{"portRange": map[string]interface{}{"protocol": "UDP", "start": int64(8125), "end": int64(8126)}},
+						map[string]interface{}{"namedPort": "metrics"},
+					},
+				},
+				map[string]interface{}{
+					"action": "Deny",
+					"from": []interface{}{
+						map[string]interface{}{"pods": map[string]interface{}{
+							"namespaceSelector": map[string]interface{}{},
+							"podSelector":       map[string]interface{}{"matchLabels": map[string]interface{}{"app": "none"}},
+						}},
+					},
+				},
+			},
+			"egress": []interface{}{
+				map[string]interface{}{
+					"name":   "deny-metadata",
+					"action": "Deny",
+					"to": []interface{}{
+						map[string]interface{}{"networks": []interface{}{"169.254.169.254/32"}},
+						map[string]interface{}{"nodes": map[string]interface{}{}},
+					},
+				},
+			},
+		},
+	}}
+
+	anp, err := AdminNetworkPolicyFromUnstructured(obj)
+	if err != nil {
+		t.Fatalf("AdminNetworkPolicyFromUnstructured failed: %v", err)
+	}
+	if anp.Name != "cluster-baseline" || anp.Spec.Priority != 10 {
+		t.Fatalf("Unexpected decoded policy: %+v", anp)
+	}
+
+	resolve := func(peer AdminNetworkPolicyPeer) ([]string, bool, error) {
+		switch {
+		case peer.Namespaces != nil:
+			return []string{"10.0.2.1", "10.0.1.1", "10.0.2.1"}, true, nil
+		case peer.Pods != nil:
+			return nil, true, nil
+		default:
+			return nil, false, nil
+		}
+	}
+	policy, warnings, err := AdminNetworkPolicyToAdminPolicy(anp, resolve)
+	if err != nil {
+		t.Fatalf("AdminNetworkPolicyToAdminPolicy failed: %v", err)
+	}
+
+	expected := []AdminRule{
+		{Name: "allow-monitoring-6", Action: AdminActionAllow, Direction: hcnlib.DirectionTypeIn,
+			Protocol: "6", LocalPorts: "9090", RemoteAddresses: "10.0.1.1,10.0.2.1"},
+		{Name: "allow-monitoring-17", Action: AdminActionAllow, Direction: hcnlib.DirectionTypeIn,
+			Protocol: "17", LocalPorts: "8125-8126", RemoteAddresses: "10.0.1.1,10.0.2.1"},
+		// ingress-1 selects no pods and is left out rather than denying everything
+		{Name: "deny-metadata", Action: AdminActionDeny, Direction: hcnlib.DirectionTypeOut,
+			RemoteAddresses: "169.254.169.254/32"},
+	}
+	if len(policy.Rules) != len(expected) {
+		t.Fatalf("Expected %d rules, got %d: %+v", len(expected), len(policy.Rules), policy.Rules)
+	}
+	for i, rule := range expected {
+		if policy.Rules[i] != rule {
+			t.Errorf("Rule %d: expected %+v, got %+v", i, rule, policy.Rules[i])
+		}
+	}
+
+	reasons := map[WarningReason]int{}
+	for _, warning := range warnings {
+		reasons[warning.Reason]++
+	}
+	if reasons[WarningNamedPortDropped] != 1 || reasons[WarningSelectorUnsupported] != 1 {
+		t.Errorf("Expected a named port and a nodes peer warning, got %v", warnings)
+	}
+}
+
+func TestAdminNetworkPolicyToAdminPolicy_InvalidAction(t *testing.T) {
+	anp := &AdminNetworkPolicy{Name: "broken", Spec: AdminNetworkPolicySpec{
+		Egress: []AdminNetworkPolicyEgressRule{{Action: "Log", To: []AdminNetworkPolicyPeer{{Networks: []string{"10.0.0.0/8"}}}}},
+	}}
+	resolve := func(AdminNetworkPolicyPeer) ([]string, bool, error) { return nil, true, nil }
+	if _, _, err := AdminNetworkPolicyToAdminPolicy(anp, resolve); err == nil {
+		t.Error("Expected an error for an unknown action")
+	}
+}
@@ -0,0 +1,247 @@
+//go:build windows
+
+package converter
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"sort"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// PeerAggregationAnnotation selects how the resolved addresses of selector and
+// same-namespace peers are written into rules, trading precision for shorter
+// RemoteAddresses lists. Its value is a PeerAggregation.
+const PeerAggregationAnnotation = "networking.knabben.github.io/peer-aggregation"
+
+// NamespacePodCIDRsAnnotation lists the pod CIDRs of a Namespace, e.g.
+// "10.244.16.0/20,fd00:10:16::/48", for the namespace peer aggregation. It is
+// set on the Namespace, not the NetworkPolicy.
+const NamespacePodCIDRsAnnotation = "networking.knabben.github.io/pod-cidrs"
+
+// PeerAggregation is how resolved peer addresses are aggregated
+type PeerAggregation string
+
+const (
+	// PeerAggregationExact lists every resolved address (default)
+	PeerAggregationExact PeerAggregation = "exact"
+
+	// PeerAggregationSummarize widens addresses to their /24 (IPv4) or /64
+	// (IPv6) supernet and merges adjacent supernets
+	PeerAggregationSummarize PeerAggregation = "summarize"
+
+	// PeerAggregationNamespace replaces addresses inside the pod CIDRs (see
+	// NamespacePodCIDRsAnnotation) of the policy's namespace and of the
+	// namespaces its peers select with those CIDRs
+	PeerAggregationNamespace PeerAggregation = "namespace"
+)
+
+const (
+	// summarizeIPv4Bits is the prefix length IPv4 addresses are summarized to
+	summarizeIPv4Bits = 24
+
+	// summarizeIPv6Bits is the prefix length IPv6 addresses are summarized to
+	summarizeIPv6Bits = 64
+)
+
+// PeerAggregationOf returns the peer aggregation selected on np
+func PeerAggregationOf(np *networkingv1.NetworkPolicy) (PeerAggregation, error) {
+	value, exists := np.Annotations[PeerAggregationAnnotation]
+	if !exists {
+		return PeerAggregationExact, nil
+	}
+	switch aggregation := PeerAggregation(strings.ToLower(strings.TrimSpace(value))); aggregation {
+	case PeerAggregationExact, PeerAggregationSummarize, PeerAggregationNamespace:
+		return aggregation, nil
+	default:
+		return "", fmt.Errorf("NetworkPolicy %s/%s: invalid %s %q: must be exact, summarize or namespace",
+			np.Namespace, np.Name, PeerAggregationAnnotation, value)
+	}
+}
+
+// ParseNamespacePodCIDRs returns the pod CIDRs listed in a Namespace's annotations
+func ParseNamespacePodCIDRs(annotations map[string]string) ([]string, error) {
+	var cidrs []string
+	for _, value := range strings.Split(annotations[NamespacePodCIDRsAnnotation], ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: %w", NamespacePodCIDRsAnnotation, value, err)
+		}
+		cidrs = append(cidrs, prefix.Masked().String())
+	}
+	return cidrs, nil
+}
+
+// aggregatePeerAddresses applies the policy's peer aggregation to the resolved
+// addresses of peer, warning when the rule matches more than the peer does
+func aggregatePeerAddresses(peer string, addresses []string, opts ConversionOptions) []string {
+	if len(addresses) == 0 {
+		return addresses
+	}
+	var aggregated []string
+	switch opts.aggregation {
+	case PeerAggregationSummarize:
+		aggregated = summarizeAddresses(addresses)
+	case PeerAggregationNamespace:
+		if len(opts.NamespacePodCIDRs) == 0 {
+			opts.warn(WarningPeerAggregated, "%s not aggregated: no selected namespace has a %s annotation",
+				peer, NamespacePodCIDRsAnnotation)
+			return addresses
+		}
+		aggregated = namespaceAddresses(addresses, opts.NamespacePodCIDRs)
+	default:
+		return addresses
+	}
+	if !slices.Equal(aggregated, addresses) {
+		opts.warn(WarningPeerAggregated, "%d addresses of %s aggregated into %s",
+			len(addresses), peer, strings.Join(aggregated, ","))
+	}
+	return aggregated
+}
+
+// summarizeAddresses widens every address to its summary supernet and merges
+// the result into the fewest prefixes. Unparsable entries are kept as written.
+func summarizeAddresses(addresses []string) []string {
+	var prefixes []netip.Prefix
+	var unparsed []string
+	for _, address := range addresses {
+		prefix, ok := parseAddress(address)
+		if !ok {
+			unparsed = append(unparsed, address)
+			continue
+		}
+		bits := summarizeIPv4Bits
+		if prefix.Addr().Is6() {
+			bits = summarizeIPv6Bits
+		}
+		if prefix.Bits() > bits {
+			prefix = netip.PrefixFrom(prefix.Addr(), bits).Masked()
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return append(formatPrefixes(collapsePrefixes(prefixes)), unparsed...)
+}
+
+// namespaceAddresses replaces the addresses inside one of cidrs with that CIDR
+func namespaceAddresses(addresses, cidrs []string) []string {
+	var pools []netip.Prefix
+	for _, cidr := range cidrs {
+		if prefix, err := netip.ParsePrefix(cidr); err == nil {
+			pools = append(pools, prefix)
+		}
+	}
+
+	var out []string
+	seen := make(map[string]bool)
+	for _, address := range addresses {
+		replacement := address
+		if prefix, ok := parseAddress(address); ok {
+			for _, pool := range pools {
+				if pool.Bits() <= prefix.Bits() && pool.Contains(prefix.Addr()) {
+					replacement = pool.String()
+					break
+				}
+			}
+		}
+		if !seen[replacement] {
+			seen[replacement] = true
+			out = append(out, replacement)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// parseAddress parses an IP or CIDR into a masked prefix
+func parseAddress(address string) (netip.Prefix, bool) {
+	if prefix, err := netip.ParsePrefix(address); err == nil {
+		return prefix.Masked(), true
+	}
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), true
+}
+
+// collapsePrefixes drops prefixes covered by others and merges sibling pairs
+// into their parent until no more merges apply
+func collapsePrefixes(prefixes []netip.Prefix) []netip.Prefix {
+	set := make(map[netip.Prefix]bool, len(prefixes))
+	for _, prefix := range prefixes {
+		set[prefix] = true
+	}
+	for merged := true; merged; {
+		merged = false
+		for prefix := range set {
+			if prefix.Bits() == 0 {
+				continue
+			}
+			parent := netip.PrefixFrom(prefix.Addr(), prefix.Bits()-1).Masked()
+			if set[parent] {
+				delete(set, prefix)
+				merged = true
+				continue
+			}
+			sibling := siblingPrefix(prefix)
+			if set[sibling] {
+				delete(set, prefix)
+				delete(set, sibling)
+				set[parent] = true
+				merged = true
+			}
+		}
+	}
+
+	out := make([]netip.Prefix, 0, len(set))
+	for prefix := range set {
+		out = append(out, prefix)
+	}
+	// Drop prefixes covered by shorter, non-adjacent ones
+	sort.Slice(out, func(i, j int) bool { return out[i].Bits() < out[j].Bits() })
+	var kept []netip.Prefix
+	for _, prefix := range out {
+		covered := false
+		for _, wider := range kept {
+			if wider.Overlaps(prefix) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			kept = append(kept, prefix)
+		}
+	}
+	return kept
+}
+
+// siblingPrefix returns the other half of prefix's parent
+func siblingPrefix(prefix netip.Prefix) netip.Prefix {
+	bytes := prefix.Addr().AsSlice()
+	bit := prefix.Bits() - 1
+	bytes[bit/8] ^= 0x80 >> (bit % 8)
+	addr, _ := netip.AddrFromSlice(bytes)
+	return netip.PrefixFrom(addr, prefix.Bits())
+}
+
+// formatPrefixes writes prefixes as sorted CIDR strings
+func formatPrefixes(prefixes []netip.Prefix) []string {
+	sort.Slice(prefixes, func(i, j int) bool {
+		if c := prefixes[i].Addr().Compare(prefixes[j].Addr()); c != 0 {
+			return c < 0
+		}
+		return prefixes[i].Bits() < prefixes[j].Bits()
+	})
+	out := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		out[i] = prefix.String()
+	}
+	return out
+}
@@ -0,0 +1,127 @@
+//go:build windows
+
+package converter
+
+import (
+	"reflect"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPeerAggregationOf(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    PeerAggregation
+		expectErr   bool
+	}{
+		{name: "no annotation", expected: PeerAggregationExact},
+		{name: "summarize", annotations: map[string]string{PeerAggregationAnnotation: " Summarize"}, expected: PeerAggregationSummarize},
+		{name: "namespace", annotations: map[string]string{PeerAggregationAnnotation: "namespace"}, expected: PeerAggregationNamespace},
+		{name: "invalid", annotations: map[string]string{PeerAggregationAnnotation: "everything"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			np := &networkingv1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "p", Namespace: "default", Annotations: tt.annotations},
+			}
+			aggregation, err := PeerAggregationOf(np)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("PeerAggregationOf() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if aggregation != tt.expected {
+				t.Errorf("PeerAggregationOf() = %q, want %q", aggregation, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseNamespacePodCIDRs(t *testing.T) {
+	cidrs, err := ParseNamespacePodCIDRs(map[string]string{NamespacePodCIDRsAnnotation: "10.244.16.1/20, fd00:10:16::/48"})
+	if err != nil {
+		t.Fatalf("ParseNamespacePodCIDRs failed: %v", err)
+	}
+	if expected := []string{"10.244.16.0/20", "fd00:10:16::/48"}; !reflect.DeepEqual(cidrs, expected) {
+		t.Errorf("ParseNamespacePodCIDRs() = %v, want %v", cidrs, expected)
+	}
+	if _, err := ParseNamespacePodCIDRs(map[string]string{NamespacePodCIDRsAnnotation: "10.244.0.0"}); err == nil {
+		t.Error("Expected an error for a bare address")
+	}
+}
+
+func TestSummarizeAddresses(t *testing.T) {
+	tests := []struct {
+		name      string
+		addresses []string
+		expected  []string
+	}{
+		{name: "same /24", addresses: []string{"10.0.0.1", "10.0.0.200"}, expected: []string{"10.0.0.0/24"}},
+		{name: "adjacent /24s merge", addresses: []string{"10.0.0.1", "10.0.1.1", "10.0.3.7"},
+			expected: []string{"10.0.0.0/23", "10.0.3.0/24"}},
+		{name: "wider CIDR kept", addresses: []string{"10.0.0.0/16", "10.0.5.5"}, expected: []string{"10.0.0.0/16"}},
+		{name: "IPv6 to /64", addresses: []string{"fd00::1", "fd00::2"}, expected: []string{"fd00::/64"}},
+		{name: "unparsable kept", addresses: []string{"bogus", "10.0.0.1"}, expected: []string{"10.0.0.0/24", "bogus"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summarizeAddresses(tt.addresses); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("summarizeAddresses() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConvertNetworkPolicy_PeerAggregation(t *testing.T) {
+	web := networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}}
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-a"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{From: []networkingv1.NetworkPolicyPeer{web}}},
+		},
+	}
+	addresses := []string{"10.244.16.5", "10.244.17.9", "10.99.0.1"}
+
+	tests := []struct {
+		name        string
+		aggregation string
+		podCIDRs    []string
+		expected    string
+		warnings    int
+	}{
+		{name: "exact", aggregation: "exact", expected: "10.244.16.5,10.244.17.9,10.99.0.1"},
+		{name: "summarize", aggregation: "summarize", expected: "10.99.0.0/24,10.244.16.0/23", warnings: 1},
+		{name: "namespace", aggregation: "namespace", podCIDRs: []string{"10.244.16.0/20"},
+			expected: "10.244.16.0/20,10.99.0.1", warnings: 1},
+		{name: "namespace without CIDRs", aggregation: "namespace", expected: "10.244.16.5,10.244.17.9,10.99.0.1", warnings: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := np.DeepCopy()
+			policy.Annotations = map[string]string{PeerAggregationAnnotation: tt.aggregation}
+			opts := DefaultConversionOptions()
+			opts.PeerAddresses = map[string][]string{PeerKey(web): addresses}
+			opts.NamespacePodCIDRs = tt.podCIDRs
+
+			conversion, err := ConvertNetworkPolicy(policy, opts)
+			if err != nil {
+				t.Fatalf("ConvertNetworkPolicy failed: %v", err)
+			}
+			if len(conversion.Rules) != 1 || conversion.Rules[0].RemoteAddresses != tt.expected {
+				t.Errorf("Expected RemoteAddresses %q, got %+v", tt.expected, conversion.Rules)
+			}
+			if len(conversion.Warnings) != tt.warnings {
+				t.Errorf("Expected %d warnings, got %v", tt.warnings, conversion.Warnings)
+			}
+			for _, warning := range conversion.Warnings {
+				if warning.Reason != WarningPeerAggregated {
+					t.Errorf("Unexpected warning %v", warning)
+				}
+			}
+		})
+	}
+}
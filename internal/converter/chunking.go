@@ -0,0 +1,55 @@
+//go:build windows
+
+package converter
+
+import (
+	"slices"
+	"strings"
+)
+
+// peerChunks memoizes the RemoteAddresses chunks of the resolved peers of one
+// conversion; it is shared by the copies of ConversionOptions passed down the
+// converter
+type peerChunks struct {
+	byPeer map[string][]string
+}
+
+// addressChunks aggregates the resolved addresses of peer and joins them into
+// the RemoteAddresses of one rule per opts.MaxRemoteAddresses addresses. The
+// chunks are computed once per peer and conversion, so a large peer repeated
+// across ports shares one copy of its address strings.
+func (o ConversionOptions) addressChunks(key, peer string, addresses []string) []string {
+	if o.chunks != nil {
+		if chunks, done := o.chunks.byPeer[key]; done {
+			return chunks
+		}
+	}
+
+	chunks := chunkAddresses(aggregatePeerAddresses(peer, addresses, o), o.MaxRemoteAddresses)
+	if o.chunks != nil {
+		if o.chunks.byPeer == nil {
+			o.chunks.byPeer = make(map[string][]string)
+		}
+		o.chunks.byPeer[key] = chunks
+	}
+	return chunks
+}
+
+// chunkAddresses joins addresses into comma-separated lists of at most limit
+// entries; a limit of 0 joins them into one. Addresses are sorted before they
+// are split so the same set always yields the same chunks.
+func chunkAddresses(addresses []string, limit int) []string {
+	if len(addresses) == 0 {
+		return nil
+	}
+	if limit <= 0 || len(addresses) <= limit {
+		return []string{strings.Join(addresses, ",")}
+	}
+
+	sorted := slices.Compact(slices.Sorted(slices.Values(addresses)))
+	chunks := make([]string, 0, (len(sorted)+limit-1)/limit)
+	for start := 0; start < len(sorted); start += limit {
+		chunks = append(chunks, strings.Join(sorted[start:min(start+limit, len(sorted))], ","))
+	}
+	return chunks
+}
@@ -0,0 +1,190 @@
+//go:build windows
+
+package converter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// podAddresses returns n distinct pod IPs in 10.244.0.0/16, in reverse order
+func podAddresses(n int) []string {
+	addresses := make([]string, n)
+	for i := range addresses {
+		j := n - 1 - i
+		addresses[i] = fmt.Sprintf("10.244.%d.%d", j/250, j%250+1)
+	}
+	return addresses
+}
+
+func TestChunkAddresses(t *testing.T) {
+	tests := []struct {
+		name      string
+		addresses []string
+		limit     int
+		want      []string
+	}{
+		{name: "none", addresses: nil, limit: 2, want: nil},
+		{name: "unlimited", addresses: []string{"10.0.0.2", "10.0.0.1"}, limit: 0, want: []string{"10.0.0.2,10.0.0.1"}},
+		{name: "within limit", addresses: []string{"10.0.0.2", "10.0.0.1"}, limit: 2, want: []string{"10.0.0.2,10.0.0.1"}},
+		{
+			name:      "split sorted",
+			addresses: []string{"10.0.0.3", "10.0.0.1", "10.0.0.2"},
+			limit:     2,
+			want:      []string{"10.0.0.1,10.0.0.2", "10.0.0.3"},
+		},
+		{
+			name:      "duplicates dropped",
+			addresses: []string{"10.0.0.1", "10.0.0.1", "10.0.0.2"},
+			limit:     2,
+			want:      []string{"10.0.0.1,10.0.0.2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := chunkAddresses(tt.addresses, tt.limit)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("chunkAddresses() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetworkPolicyToACLRules_LargeSelectorPeer(t *testing.T) {
+	web := networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}}
+	tcp := corev1.ProtocolTCP
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "large", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From: []networkingv1.NetworkPolicyPeer{web},
+				Ports: []networkingv1.NetworkPolicyPort{
+					{Protocol: &tcp, Port: &intstr.IntOrString{IntVal: 80}},
+					{Protocol: &tcp, Port: &intstr.IntOrString{IntVal: 443}},
+				},
+			}},
+		},
+	}
+
+	addresses := podAddresses(10000)
+	opts := DefaultConversionOptions()
+	opts.PeerAddresses = map[string][]string{PeerKey(web): addresses}
+
+	// Without a limit every port gets one rule holding all addresses
+	rules, err := NetworkPolicyToACLRules(np, opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	if len(rules) != 2 || strings.Count(rules[0].RemoteAddresses, ",") != len(addresses)-1 {
+		t.Fatalf("Expected 2 rules of %d addresses, got %d rules", len(addresses), len(rules))
+	}
+
+	opts.MaxRemoteAddresses = 1000
+	rules, err = NetworkPolicyToACLRules(np, opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	if len(rules) != 20 {
+		t.Fatalf("Expected 10 rules per port, got %d", len(rules))
+	}
+
+	seen := make(map[string]int)
+	for i, rule := range rules {
+		if rule.Priority != uint16(100+i) {
+			t.Errorf("Expected rule %d at priority %d, got %d", i, 100+i, rule.Priority)
+		}
+		chunk := strings.Split(rule.RemoteAddresses, ",")
+		if len(chunk) != 1000 {
+			t.Errorf("Expected rule %d to hold 1000 addresses, got %d", i, len(chunk))
+		}
+		for _, address := range chunk {
+			seen[address]++
+		}
+	}
+	for _, address := range addresses {
+		if seen[address] != 2 {
+			t.Fatalf("Expected %s in one rule per port, found it in %d", address, seen[address])
+		}
+	}
+
+	// Both ports share the chunks, and a reordered resolution yields the same rules
+	for i := 0; i < 10; i++ {
+		if rules[i].RemoteAddresses != rules[i+10].RemoteAddresses {
+			t.Fatalf("Expected port 80 and 443 rules to share chunk %d", i)
+		}
+	}
+	reversed := make([]string, len(addresses))
+	for i, address := range addresses {
+		reversed[len(addresses)-1-i] = address
+	}
+	opts.PeerAddresses = map[string][]string{PeerKey(web): reversed}
+	again, err := NetworkPolicyToACLRules(np, opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	for i := range rules {
+		if again[i].RemoteAddresses != rules[i].RemoteAddresses || again[i].Priority != rules[i].Priority {
+			t.Fatalf("Expected rule %d to be independent of the resolution order", i)
+		}
+	}
+}
+
+func TestNetworkPolicyToACLRules_LargeSameNamespacePeer(t *testing.T) {
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "same-ns",
+			Namespace:   "default",
+			Annotations: map[string]string{SameNamespaceAnnotation: "ingress,egress"},
+		},
+	}
+
+	opts := DefaultConversionOptions()
+	opts.NamespacePodIPs = podAddresses(5000)
+	opts.MaxRemoteAddresses = 2000
+
+	rules, err := NetworkPolicyToACLRules(np, opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	if len(rules) != 6 {
+		t.Fatalf("Expected 3 rules per direction, got %d", len(rules))
+	}
+	if got := strings.Count(rules[2].RemoteAddresses, ",") + 1; got != 1000 {
+		t.Errorf("Expected the last ingress rule to hold the remaining 1000 addresses, got %d", got)
+	}
+}
+
+func TestConversionOptions_NegativeMaxRemoteAddresses(t *testing.T) {
+	opts := DefaultConversionOptions()
+	opts.MaxRemoteAddresses = -1
+	if err := opts.Validate(); err == nil {
+		t.Error("Expected a negative address limit to be rejected")
+	}
+}
+
+func BenchmarkNetworkPolicyToACLRules_LargeSelectorPeer(b *testing.B) {
+	web := networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}}
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "large", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{From: []networkingv1.NetworkPolicyPeer{web}}},
+		},
+	}
+	opts := DefaultConversionOptions()
+	opts.PeerAddresses = map[string][]string{PeerKey(web): podAddresses(10000)}
+	opts.MaxRemoteAddresses = 1000
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := NetworkPolicyToACLRules(np, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
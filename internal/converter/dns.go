@@ -0,0 +1,57 @@
+//go:build windows
+
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// dnsProtocols are the protocols DNS is served over: UDP and TCP
+var dnsProtocols = []string{"17", "6"}
+
+// dnsAllowRules returns egress allow rules for UDP and TCP port 53 to servers.
+// Empty servers allows DNS to any address.
+func dnsAllowRules(namePrefix string, servers []string, priority uint16) []hcnpkg.ACLRule {
+	rules := make([]hcnpkg.ACLRule, 0, len(dnsProtocols))
+	for _, protocol := range dnsProtocols {
+		rules = append(rules, hcnpkg.ACLRule{
+			Name:            fmt.Sprintf("%s-dns-%s", namePrefix, protocol),
+			Action:          hcnlib.ActionTypeAllow,
+			Direction:       hcnlib.DirectionTypeOut,
+			Protocol:        protocol,
+			RemotePorts:     "53",
+			RemoteAddresses: strings.Join(servers, ","),
+			Priority:        priority,
+		})
+	}
+	return rules
+}
+
+// restrictsEgress reports whether a NetworkPolicy limits the egress of its
+// pods: policies without policyTypes only do when they have egress rules, as
+// the API server then defaults them to Egress too
+func restrictsEgress(np *networkingv1.NetworkPolicy) bool {
+	if len(np.Spec.Egress) > 0 {
+		return true
+	}
+	for _, policyType := range np.Spec.PolicyTypes {
+		if policyType == networkingv1.PolicyTypeEgress {
+			return true
+		}
+	}
+	return false
+}
+
+// convertAutoDNS injects the DNS allow rules for opts.AutoAllowDNS into
+// policies that restrict egress
+func convertAutoDNS(np *networkingv1.NetworkPolicy, priorities *hcnpkg.PriorityPool, opts ConversionOptions) []hcnpkg.ACLRule {
+	if len(opts.AutoAllowDNS) == 0 || !restrictsEgress(np) {
+		return nil
+	}
+	return dnsAllowRules(fmt.Sprintf("%s/%s-egress-auto", np.Namespace, np.Name), opts.AutoAllowDNS, priorities.Next())
+}
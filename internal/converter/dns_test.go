@@ -0,0 +1,48 @@
+//go:build windows
+
+package converter
+
+import (
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNetworkPolicyToACLRules_AutoAllowDNS(t *testing.T) {
+	opts := DefaultConversionOptions()
+	opts.AutoAllowDNS = []string{"10.96.0.10"}
+
+	denyEgress := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "deny-egress", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+		},
+	}
+	rules, err := NetworkPolicyToACLRules(denyEgress, opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("Expected UDP and TCP DNS rules, got %d: %+v", len(rules), rules)
+	}
+	for _, rule := range rules {
+		if rule.RemotePorts != "53" || rule.RemoteAddresses != "10.96.0.10" {
+			t.Errorf("Unexpected DNS rule: %+v", rule)
+		}
+	}
+
+	ingressOnly := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "ingress-only", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+	rules, err = NetworkPolicyToACLRules(ingressOnly, opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	if len(rules) != 0 {
+		t.Errorf("Expected no DNS rules for ingress-only policy, got %+v", rules)
+	}
+}
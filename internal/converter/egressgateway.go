@@ -0,0 +1,48 @@
+//go:build windows
+
+package converter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// ParseEgressGateways validates an --egress-gateways list of IPs and CIDRs
+func ParseEgressGateways(values []string) ([]string, error) {
+	var gateways []string
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if _, err := parsePrefix(value); err != nil {
+			return nil, fmt.Errorf("invalid egress gateway %q: %w", value, err)
+		}
+		gateways = append(gateways, value)
+	}
+	return gateways, nil
+}
+
+// egressPeerAddresses returns the remote addresses of an egress peer. Traffic
+// to ipBlock peers may leave through an egress gateway, where HNS sees it
+// addressed to the gateway instead of the destination, so the gateways in
+// opts.EgressGateways are matched along with the block.
+func egressPeerAddresses(np *networkingv1.NetworkPolicy, peer networkingv1.NetworkPolicyPeer, opts ConversionOptions) ([]string, error) {
+	remoteAddrs, err := peerAddresses(np, peer, opts)
+	if err != nil || peer.IPBlock == nil || len(opts.EgressGateways) == 0 {
+		return remoteAddrs, err
+	}
+	for i, remoteAddr := range remoteAddrs {
+		addresses := strings.Split(remoteAddr, ",")
+		for _, gateway := range opts.EgressGateways {
+			if !slices.Contains(addresses, gateway) {
+				addresses = append(addresses, gateway)
+			}
+		}
+		remoteAddrs[i] = strings.Join(addresses, ",")
+	}
+	return remoteAddrs, nil
+}
@@ -0,0 +1,81 @@
+//go:build windows
+
+package converter
+
+import (
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNetworkPolicyToACLRules_EgressGateways(t *testing.T) {
+	opts := DefaultConversionOptions()
+	opts.EgressGateways = []string{"192.168.0.1"}
+
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "to-external", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Egress: []networkingv1.NetworkPolicyEgressRule{{
+				To: []networkingv1.NetworkPolicyPeer{
+					{IPBlock: &networkingv1.IPBlock{CIDR: "203.0.113.0/24"}},
+					{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}},
+				},
+			}},
+		},
+	}
+	opts.PeerAddresses = map[string][]string{PeerKey(np.Spec.Egress[0].To[1]): {"10.244.0.7"}}
+
+	rules, err := NetworkPolicyToACLRules(np, opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("Expected 2 rules, got %d: %+v", len(rules), rules)
+	}
+	if rules[0].RemoteAddresses != "203.0.113.0/24,192.168.0.1" {
+		t.Errorf("Expected the ipBlock rule to match the gateway, got %q", rules[0].RemoteAddresses)
+	}
+	if rules[1].RemoteAddresses != "10.244.0.7" {
+		t.Errorf("Expected the selector rule left alone, got %q", rules[1].RemoteAddresses)
+	}
+}
+
+func TestNetworkPolicyToACLRules_EgressGatewaysSingleRule(t *testing.T) {
+	opts := DefaultConversionOptions()
+	opts.EgressGateways = []string{"192.168.0.1"}
+
+	// One rule with one ipBlock peer takes the single-rule fast path
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "to-external", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Egress: []networkingv1.NetworkPolicyEgressRule{{
+				To: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "203.0.113.0/24"}}},
+			}},
+		},
+	}
+
+	rules, err := NetworkPolicyToACLRules(np, opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	if len(rules) != 1 {
+		t.Fatalf("Expected 1 rule, got %d: %+v", len(rules), rules)
+	}
+	if rules[0].RemoteAddresses != "203.0.113.0/24,192.168.0.1" {
+		t.Errorf("Expected the ipBlock rule to match the gateway, got %q", rules[0].RemoteAddresses)
+	}
+}
+
+func TestParseEgressGateways(t *testing.T) {
+	gateways, err := ParseEgressGateways([]string{"192.168.0.1", " 10.0.0.0/8", ""})
+	if err != nil {
+		t.Fatalf("ParseEgressGateways failed: %v", err)
+	}
+	if len(gateways) != 2 || gateways[1] != "10.0.0.0/8" {
+		t.Errorf("Unexpected gateways: %v", gateways)
+	}
+	if _, err := ParseEgressGateways([]string{"gateway"}); err == nil {
+		t.Error("Expected an invalid gateway to be rejected")
+	}
+}
@@ -0,0 +1,277 @@
+//go:build windows
+
+package converter
+
+import (
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// EnforcementLevel says how faithfully a NetworkPolicy field is enforced
+type EnforcementLevel string
+
+const (
+	// EnforcementEnforced means the field changes the rules as written
+	EnforcementEnforced EnforcementLevel = "enforced"
+
+	// EnforcementPartial means the field changes the rules but raises
+	// conversion warnings about what is not enforced
+	EnforcementPartial EnforcementLevel = "partial"
+
+	// EnforcementIgnored means the field leaves the rules unchanged
+	EnforcementIgnored EnforcementLevel = "ignored"
+
+	// EnforcementRejected means policies using the field fail conversion
+	EnforcementRejected EnforcementLevel = "rejected"
+)
+
+// FieldEnforcement is the enforcement of one NetworkPolicy field
+type FieldEnforcement struct {
+	// Field is the path of the field, e.g. "spec.ingress[].from[].ipBlock.except"
+	Field       string           `json:"field"`
+	Level       EnforcementLevel `json:"level"`
+	Description string           `json:"description"`
+
+	// Warnings are the conversion warnings raised by a policy using the field
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Error is the conversion error of a rejected field
+	Error string `json:"error,omitempty"`
+}
+
+// EnforcementMatrix lists how every NetworkPolicy field is enforced by an agent
+type EnforcementMatrix struct {
+	// Node and WindowsBuild identify where the matrix was computed, when known
+	Node         string `json:"node,omitempty"`
+	WindowsBuild uint32 `json:"windowsBuild,omitempty"`
+
+	Fields []FieldEnforcement `json:"fields"`
+}
+
+// ProbeEnvironment describes what the agent resolves before conversion
+type ProbeEnvironment struct {
+	// ResolvesPeer reports whether a selector peer is resolved to addresses;
+	// nil resolves none
+	ResolvesPeer func(peer networkingv1.NetworkPolicyPeer) bool
+
+	// ResolvesNamedPorts reports whether named ports are resolved from pod specs
+	ResolvesNamedPorts bool
+}
+
+// fieldProbe annotates a NetworkPolicy field with two policy specs that
+// differ only in that field. The matrix converts both, so it reports what the
+// converter does with the field rather than what it was once documented to do.
+type fieldProbe struct {
+	field       string
+	description string
+	base, with  networkingv1.NetworkPolicySpec
+}
+
+// Addresses and ports used by the probe policies
+const (
+	probeCIDR       = "192.0.2.0/24"
+	probeExceptCIDR = "192.0.2.128/25"
+	probeOtherCIDR  = "198.51.100.0/24"
+	probePeerIP     = "203.0.113.10"
+	probePortName   = "http"
+	probePortNumber = 8080
+)
+
+// fieldProbes returns the probes of every NetworkPolicy field
+func fieldProbes() []fieldProbe {
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "probe"}}
+	tcp, udp, sctp := corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP
+	port := intstr.FromInt32(80)
+	named := intstr.FromString(probePortName)
+	endPort := int32(8100)
+	ipBlock := networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: probeOtherCIDR}}
+
+	probes := []fieldProbe{
+		{
+			field:       "spec.podSelector",
+			description: "Selects the pods the policy applies to",
+			base:        networkingv1.NetworkPolicySpec{Ingress: []networkingv1.NetworkPolicyIngressRule{{}}},
+			with:        networkingv1.NetworkPolicySpec{PodSelector: *selector, Ingress: []networkingv1.NetworkPolicyIngressRule{{}}},
+		},
+		{
+			field:       "spec.policyTypes",
+			description: "Isolates the selected pods, denying traffic no rule allows",
+			with: networkingv1.NetworkPolicySpec{PolicyTypes: []networkingv1.PolicyType{
+				networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress,
+			}},
+		},
+	}
+
+	// Every peer and port field exists in both directions
+	type direction struct {
+		name, peers string
+		spec        func(peers []networkingv1.NetworkPolicyPeer, ports []networkingv1.NetworkPolicyPort) networkingv1.NetworkPolicySpec
+	}
+	directions := []direction{
+		{"ingress", "from", func(peers []networkingv1.NetworkPolicyPeer, ports []networkingv1.NetworkPolicyPort) networkingv1.NetworkPolicySpec {
+			return networkingv1.NetworkPolicySpec{Ingress: []networkingv1.NetworkPolicyIngressRule{{From: peers, Ports: ports}}}
+		}},
+		{"egress", "to", func(peers []networkingv1.NetworkPolicyPeer, ports []networkingv1.NetworkPolicyPort) networkingv1.NetworkPolicySpec {
+			return networkingv1.NetworkPolicySpec{Egress: []networkingv1.NetworkPolicyEgressRule{{To: peers, Ports: ports}}}
+		}},
+	}
+	for _, d := range directions {
+		peer := "spec." + d.name + "[]." + d.peers + "[]."
+		ports := "spec." + d.name + "[].ports[]."
+		probes = append(probes,
+			fieldProbe{
+				field:       "spec." + d.name + "[]",
+				description: "Allows the traffic matched by the rule",
+				with:        d.spec(nil, nil),
+			},
+			fieldProbe{
+				field:       peer + "ipBlock.cidr",
+				description: "Allows a CIDR",
+				base:        d.spec(nil, nil),
+				with:        d.spec([]networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: probeCIDR}}}, nil),
+			},
+			fieldProbe{
+				field:       peer + "ipBlock.except",
+				description: "Excludes ranges from an allowed CIDR",
+				base:        d.spec([]networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: probeCIDR}}}, nil),
+				with: d.spec([]networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{
+					CIDR: probeCIDR, Except: []string{probeExceptCIDR},
+				}}}, nil),
+			},
+			fieldProbe{
+				field:       peer + "podSelector",
+				description: "Allows the pods selected in the policy's namespace",
+				base:        d.spec([]networkingv1.NetworkPolicyPeer{ipBlock}, nil),
+				with:        d.spec([]networkingv1.NetworkPolicyPeer{ipBlock, {PodSelector: selector}}, nil),
+			},
+			fieldProbe{
+				field:       peer + "namespaceSelector",
+				description: "Allows the pods of the selected namespaces",
+				base:        d.spec([]networkingv1.NetworkPolicyPeer{ipBlock}, nil),
+				with:        d.spec([]networkingv1.NetworkPolicyPeer{ipBlock, {NamespaceSelector: selector}}, nil),
+			},
+			fieldProbe{
+				field:       ports + "port",
+				description: "Restricts the rule to a port number",
+				base:        d.spec(nil, nil),
+				with:        d.spec(nil, []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port}}),
+			},
+			fieldProbe{
+				field:       ports + "port (named)",
+				description: "Restricts the rule to a named container port",
+				base:        d.spec(nil, []networkingv1.NetworkPolicyPort{{Protocol: &tcp}}),
+				with:        d.spec(nil, []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &named}}),
+			},
+			fieldProbe{
+				field:       ports + "protocol",
+				description: "Selects TCP or UDP",
+				base:        d.spec(nil, []networkingv1.NetworkPolicyPort{{Port: &port}}),
+				with:        d.spec(nil, []networkingv1.NetworkPolicyPort{{Protocol: &udp, Port: &port}}),
+			},
+			fieldProbe{
+				field:       ports + "protocol (SCTP)",
+				description: "Selects SCTP",
+				base:        d.spec(nil, []networkingv1.NetworkPolicyPort{{Port: &port}}),
+				with:        d.spec(nil, []networkingv1.NetworkPolicyPort{{Protocol: &sctp, Port: &port}}),
+			},
+			fieldProbe{
+				field:       ports + "endPort",
+				description: "Extends the port to a range",
+				base:        d.spec(nil, []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port}}),
+				with:        d.spec(nil, []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port, EndPort: &endPort}}),
+			},
+		)
+	}
+	return probes
+}
+
+// BuildEnforcementMatrix reports how every NetworkPolicy field is enforced
+// with opts in env, by converting a probe policy per field
+func BuildEnforcementMatrix(opts ConversionOptions, env ProbeEnvironment) EnforcementMatrix {
+	matrix := EnforcementMatrix{Fields: []FieldEnforcement{}}
+	for _, probe := range fieldProbes() {
+		matrix.Fields = append(matrix.Fields, probe.run(opts, env))
+	}
+	return matrix
+}
+
+// run converts the two specs of the probe and classifies the difference
+func (p fieldProbe) run(opts ConversionOptions, env ProbeEnvironment) FieldEnforcement {
+	result := FieldEnforcement{Field: p.field, Description: p.description}
+
+	base, err := convertProbe(p.base, opts, env)
+	if err == nil {
+		var with Conversion
+		if with, err = convertProbe(p.with, opts, env); err == nil {
+			for _, warning := range with.Warnings {
+				if !slices.Contains(base.Warnings, warning) {
+					result.Warnings = append(result.Warnings, warning.String())
+				}
+			}
+			switch {
+			case slices.EqualFunc(base.Rules, with.Rules, sameMatch):
+				result.Level = EnforcementIgnored
+			case len(result.Warnings) > 0:
+				result.Level = EnforcementPartial
+			default:
+				result.Level = EnforcementEnforced
+			}
+			return result
+		}
+	}
+	result.Level = EnforcementRejected
+	result.Error = err.Error()
+	return result
+}
+
+// convertProbe converts spec as the agent would in env
+func convertProbe(spec networkingv1.NetworkPolicySpec, opts ConversionOptions, env ProbeEnvironment) (Conversion, error) {
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "enforcement-probe", Namespace: "enforcement-probe"},
+		Spec:       spec,
+	}
+
+	opts.PeerAddresses = map[string][]string{}
+	resolve := func(peers []networkingv1.NetworkPolicyPeer) {
+		for _, peer := range peers {
+			if peer.IPBlock == nil && env.ResolvesPeer != nil && env.ResolvesPeer(peer) {
+				opts.PeerAddresses[PeerKey(peer)] = []string{probePeerIP}
+			}
+		}
+	}
+	for _, rule := range spec.Ingress {
+		resolve(rule.From)
+	}
+	for _, rule := range spec.Egress {
+		resolve(rule.To)
+	}
+	opts.NamedPorts, opts.PeerNamedPorts = nil, nil
+	if env.ResolvesNamedPorts {
+		opts.NamedPorts = map[string][]int32{NamedPortKey(probePortName, corev1.ProtocolTCP): {probePortNumber}}
+		opts.PeerNamedPorts = map[string]map[string][]int32{AnyPeerKey: opts.NamedPorts}
+		for _, rule := range spec.Egress {
+			for _, peer := range rule.To {
+				opts.PeerNamedPorts[PeerKey(peer)] = opts.NamedPorts
+			}
+		}
+	}
+	return ConvertNetworkPolicy(np, opts)
+}
+
+// sameMatch reports whether two rules match the same traffic, ignoring names,
+// labels and packing provenance
+func sameMatch(a, b hcnpkg.ACLRule) bool {
+	return a.Action == b.Action &&
+		a.Direction == b.Direction &&
+		a.Protocol == b.Protocol &&
+		a.LocalPorts == b.LocalPorts &&
+		a.RemotePorts == b.RemotePorts &&
+		a.RemoteAddresses == b.RemoteAddresses &&
+		a.Priority == b.Priority
+}
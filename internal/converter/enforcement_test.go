@@ -0,0 +1,76 @@
+//go:build windows
+
+package converter
+
+import (
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// levels indexes the matrix by field
+func levels(matrix EnforcementMatrix) map[string]FieldEnforcement {
+	fields := make(map[string]FieldEnforcement, len(matrix.Fields))
+	for _, field := range matrix.Fields {
+		fields[field.Field] = field
+	}
+	return fields
+}
+
+func TestBuildEnforcementMatrix(t *testing.T) {
+	fields := levels(BuildEnforcementMatrix(DefaultConversionOptions(), ProbeEnvironment{}))
+
+	expected := map[string]EnforcementLevel{
+		"spec.podSelector":                        EnforcementIgnored,
+		"spec.policyTypes":                        EnforcementIgnored,
+		"spec.ingress[]":                          EnforcementEnforced,
+		"spec.ingress[].from[].ipBlock.cidr":      EnforcementEnforced,
+		"spec.ingress[].from[].ipBlock.except":    EnforcementIgnored,
+		"spec.ingress[].from[].podSelector":       EnforcementIgnored,
+		"spec.ingress[].from[].namespaceSelector": EnforcementIgnored,
+		"spec.ingress[].ports[].port":             EnforcementEnforced,
+		"spec.ingress[].ports[].port (named)":     EnforcementIgnored,
+		"spec.ingress[].ports[].protocol":         EnforcementEnforced,
+		"spec.egress[].to[].ipBlock.cidr":         EnforcementEnforced,
+		"spec.egress[].to[].podSelector":          EnforcementIgnored,
+		"spec.egress[].ports[].endPort":           EnforcementEnforced,
+	}
+	for field, level := range expected {
+		if got, found := fields[field]; !found || got.Level != level {
+			t.Errorf("Expected %s to be %s, got %+v", field, level, got)
+		}
+	}
+	if len(fields["spec.ingress[].from[].ipBlock.except"].Warnings) != 1 {
+		t.Errorf("Expected the except warning to be reported, got %+v", fields["spec.ingress[].from[].ipBlock.except"])
+	}
+}
+
+func TestBuildEnforcementMatrix_Environment(t *testing.T) {
+	env := ProbeEnvironment{
+		ResolvesPeer:       func(peer networkingv1.NetworkPolicyPeer) bool { return peer.NamespaceSelector == nil },
+		ResolvesNamedPorts: true,
+	}
+	fields := levels(BuildEnforcementMatrix(DefaultConversionOptions(), env))
+
+	expected := map[string]EnforcementLevel{
+		"spec.ingress[].from[].podSelector":       EnforcementEnforced,
+		"spec.ingress[].from[].namespaceSelector": EnforcementIgnored,
+		"spec.egress[].ports[].port (named)":      EnforcementEnforced,
+	}
+	for field, level := range expected {
+		if got := fields[field]; got.Level != level {
+			t.Errorf("Expected %s to be %s, got %+v", field, level, got)
+		}
+	}
+
+	// Strict options reject what the default ones skip
+	opts := DefaultConversionOptions()
+	opts.RejectUnsupportedPeers = true
+	opts.RejectNamedPorts = true
+	fields = levels(BuildEnforcementMatrix(opts, ProbeEnvironment{}))
+	for _, field := range []string{"spec.ingress[].from[].podSelector", "spec.egress[].ports[].port (named)"} {
+		if fields[field].Level != EnforcementRejected || fields[field].Error == "" {
+			t.Errorf("Expected %s to be rejected, got %+v", field, fields[field])
+		}
+	}
+}
@@ -0,0 +1,109 @@
+//go:build windows
+
+package converter
+
+import (
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// singleRuleCapacity sizes the rules of a single-rule policy: its rule and
+// the isolation denies of both directions
+const singleRuleCapacity = 3
+
+// simpleRule is the only rule of a policy with at most one port and one peer,
+// the shape of most NetworkPolicies
+type simpleRule struct {
+	direction hcnlib.DirectionType
+	ports     []networkingv1.NetworkPolicyPort
+	peers     []networkingv1.NetworkPolicyPeer
+}
+
+// singleRule returns the rule of np if np has exactly one rule, with at most
+// one port and one peer
+func singleRule(np *networkingv1.NetworkPolicy) (simpleRule, bool) {
+	switch {
+	case len(np.Spec.Ingress) == 1 && len(np.Spec.Egress) == 0:
+		rule := np.Spec.Ingress[0]
+		return simpleRule{direction: hcnlib.DirectionTypeIn, ports: rule.Ports, peers: rule.From},
+			len(rule.Ports) <= 1 && len(rule.From) <= 1
+	case len(np.Spec.Egress) == 1 && len(np.Spec.Ingress) == 0:
+		rule := np.Spec.Egress[0]
+		return simpleRule{direction: hcnlib.DirectionTypeOut, ports: rule.Ports, peers: rule.To},
+			len(rule.Ports) <= 1 && len(rule.To) <= 1
+	}
+	return simpleRule{}, false
+}
+
+// appendSingleRule appends the ACL rules of a single-rule policy to rules. It
+// emits what convertIngressRule and convertEgressRule would, without their
+// intermediate slices, and formats the rule name once.
+func appendSingleRule(rules []hcnpkg.ACLRule, np *networkingv1.NetworkPolicy, rule simpleRule, priorities *hcnpkg.PriorityPool, opts ConversionOptions) ([]hcnpkg.ACLRule, error) {
+	name := np.Namespace + "/" + np.Name + "-ingress"
+	if rule.direction == hcnlib.DirectionTypeOut {
+		name = np.Namespace + "/" + np.Name + "-egress"
+	}
+	template := hcnpkg.ACLRule{
+		Name:      name,
+		Action:    opts.DefaultAction,
+		Direction: rule.direction,
+	}
+	if len(rule.ports) == 1 {
+		port := rule.ports[0]
+		var ports string
+		var err error
+		if rule.direction == hcnlib.DirectionTypeIn {
+			ports, err = convertPort(np, port, opts)
+		} else {
+			// Named ports are those of the peer's pods
+			var peer networkingv1.NetworkPolicyPeer
+			if len(rule.peers) == 1 {
+				peer = rule.peers[0]
+			}
+			ports, err = egressPort(np, port, peer, opts)
+		}
+		if err != nil {
+			return nil, err
+		}
+		template.Protocol = protocolToNumber(port.Protocol)
+		if rule.direction == hcnlib.DirectionTypeIn {
+			template.LocalPorts = ports
+		} else {
+			template.RemotePorts = ports
+		}
+	}
+
+	if len(rule.peers) == 0 {
+		template.RemoteAddresses = opts.defaultRemoteAddresses()
+		template.Priority = priorities.Next()
+		return append(rules, template), nil
+	}
+
+	// A plain ipBlock needs no address list of its own, unless egress adds gateways to it
+	peer := rule.peers[0]
+	egress := rule.direction == hcnlib.DirectionTypeOut
+	if peer.IPBlock != nil && peer.IPBlock.CIDR != "" && len(peer.IPBlock.Except) == 0 &&
+		(!egress || len(opts.EgressGateways) == 0) {
+		if err := validateIPBlock(np, peer.IPBlock.CIDR); err != nil {
+			return nil, err
+		}
+		template.RemoteAddresses = peer.IPBlock.CIDR
+		template.Priority = priorities.Next()
+		return append(rules, template), nil
+	}
+	addresses := peerAddresses
+	if egress {
+		addresses = egressPeerAddresses
+	}
+	remoteAddrs, err := addresses(np, peer, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, remoteAddr := range remoteAddrs {
+		template.RemoteAddresses = remoteAddr
+		template.Priority = priorities.Next()
+		rules = append(rules, template)
+	}
+	return rules, nil
+}
@@ -0,0 +1,188 @@
+//go:build windows
+
+package converter
+
+import (
+	"testing"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// singleRulePolicy returns an ingress policy allowing TCP 443 from 10.0.0.0/8
+func singleRulePolicy() *networkingv1.NetworkPolicy {
+	port := intstr.FromInt32(443)
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				Ports: []networkingv1.NetworkPolicyPort{{Port: &port}},
+				From:  []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8"}}},
+			}},
+		},
+	}
+}
+
+// genericRules converts the rules of np without the single-rule fast path
+func genericRules(t testing.TB, np *networkingv1.NetworkPolicy, opts ConversionOptions) []hcnpkg.ACLRule {
+	priorities, err := hcnpkg.NewPriorityPool(opts.BasePriority, opts.MaxPriority, opts.PriorityStride, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rules []hcnpkg.ACLRule
+	for _, rule := range np.Spec.Ingress {
+		converted, err := convertIngressRule(np, rule, priorities, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules = append(rules, converted...)
+	}
+	for _, rule := range np.Spec.Egress {
+		converted, err := convertEgressRule(np, rule, priorities, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules = append(rules, converted...)
+	}
+	return rules
+}
+
+func TestAppendSingleRule_MatchesGenericPath(t *testing.T) {
+	web := networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}}
+	named := intstr.FromString("metrics")
+
+	tests := []struct {
+		name   string
+		mutate func(np *networkingv1.NetworkPolicy)
+	}{
+		{name: "port and ipBlock", mutate: func(*networkingv1.NetworkPolicy) {}},
+		{name: "no port", mutate: func(np *networkingv1.NetworkPolicy) { np.Spec.Ingress[0].Ports = nil }},
+		{name: "no peer", mutate: func(np *networkingv1.NetworkPolicy) { np.Spec.Ingress[0].From = nil }},
+		{name: "named port", mutate: func(np *networkingv1.NetworkPolicy) {
+			np.Spec.Ingress[0].Ports[0].Port = &named
+		}},
+		{name: "ipBlock with except", mutate: func(np *networkingv1.NetworkPolicy) {
+			np.Spec.Ingress[0].From[0].IPBlock.Except = []string{"10.1.0.0/16"}
+		}},
+		{name: "resolved selector peer", mutate: func(np *networkingv1.NetworkPolicy) {
+			np.Spec.Ingress[0].From[0] = web
+		}},
+		{name: "egress", mutate: func(np *networkingv1.NetworkPolicy) {
+			np.Spec.Egress = []networkingv1.NetworkPolicyEgressRule{{
+				Ports: np.Spec.Ingress[0].Ports,
+				To:    np.Spec.Ingress[0].From,
+			}}
+			np.Spec.Ingress = nil
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			np := singleRulePolicy()
+			tt.mutate(np)
+			rule, ok := singleRule(np)
+			if !ok {
+				t.Fatal("Expected a single-rule policy")
+			}
+
+			opts := DefaultConversionOptions()
+			opts.PeerAddresses = map[string][]string{PeerKey(web): podAddresses(3)}
+			opts.MaxRemoteAddresses = 2
+			opts.chunks = &peerChunks{}
+			expected := genericRules(t, np, opts)
+
+			priorities, err := hcnpkg.NewPriorityPool(opts.BasePriority, opts.MaxPriority, opts.PriorityStride, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts.chunks = &peerChunks{}
+			got, err := appendSingleRule(nil, np, rule, priorities, opts)
+			if err != nil {
+				t.Fatalf("appendSingleRule failed: %v", err)
+			}
+			if len(got) != len(expected) {
+				t.Fatalf("Expected %d rules, got %d: %+v", len(expected), len(got), got)
+			}
+			for i := range expected {
+				if !got[i].Equal(expected[i]) {
+					t.Errorf("Rule %d: expected %+v, got %+v", i, expected[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSingleRule(t *testing.T) {
+	np := singleRulePolicy()
+	if _, ok := singleRule(np); !ok {
+		t.Error("Expected one port and one peer to take the fast path")
+	}
+
+	np.Spec.Ingress[0].From = append(np.Spec.Ingress[0].From, np.Spec.Ingress[0].From[0])
+	if _, ok := singleRule(np); ok {
+		t.Error("Expected two peers to take the generic path")
+	}
+
+	np = singleRulePolicy()
+	np.Spec.Egress = []networkingv1.NetworkPolicyEgressRule{{}}
+	if _, ok := singleRule(np); ok {
+		t.Error("Expected an ingress and an egress rule to take the generic path")
+	}
+}
+
+func TestNetworkPolicyToACLRules_SingleRuleInvalidCIDR(t *testing.T) {
+	np := singleRulePolicy()
+	np.Spec.Ingress[0].From[0].IPBlock.CIDR = "10.0.0.0/33"
+	if _, err := NetworkPolicyToACLRules(np, DefaultConversionOptions()); err == nil {
+		t.Error("Expected an invalid ipBlock to be rejected on the fast path")
+	}
+}
+
+// BenchmarkNetworkPolicyToACLRules_SingleRule converts the most common policy
+// shape, which takes the single-rule fast path
+func BenchmarkNetworkPolicyToACLRules_SingleRule(b *testing.B) {
+	np := singleRulePolicy()
+	opts := DefaultConversionOptions()
+	opts.IsolateIngress = true
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := NetworkPolicyToACLRules(np, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkAppendSingleRule and BenchmarkConvertIngressRule compare the fast
+// path with the generic expansion of the same rule
+func BenchmarkAppendSingleRule(b *testing.B) {
+	np := singleRulePolicy()
+	rule, _ := singleRule(np)
+	opts := DefaultConversionOptions()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		priorities, _ := hcnpkg.NewPriorityPool(opts.BasePriority, opts.MaxPriority, opts.PriorityStride, nil)
+		if _, err := appendSingleRule(make([]hcnpkg.ACLRule, 0, singleRuleCapacity), np, rule, priorities, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConvertIngressRule(b *testing.B) {
+	np := singleRulePolicy()
+	opts := DefaultConversionOptions()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		priorities, _ := hcnpkg.NewPriorityPool(opts.BasePriority, opts.MaxPriority, opts.PriorityStride, nil)
+		rules, err := convertIngressRule(np, np.Spec.Ingress[0], priorities, opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		// The caller copies the rules into the policy's slice
+		_ = append([]hcnpkg.ACLRule(nil), rules...)
+	}
+}
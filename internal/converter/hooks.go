@@ -0,0 +1,225 @@
+//go:build windows
+
+package converter
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// PreConversionHook runs before a NetworkPolicy is converted. It may mutate the
+// policy (it receives a private copy) or reject it by returning an error.
+type PreConversionHook interface {
+	// Name identifies the hook in errors and logs
+	Name() string
+
+	// PreConvert mutates or validates np
+	PreConvert(np *networkingv1.NetworkPolicy) error
+}
+
+// PostConversionHook runs on the ACL rules generated for a NetworkPolicy. It may
+// rewrite, drop or add rules; added rules take their priorities from priorities
+// so they stay within the policy's band.
+type PostConversionHook interface {
+	// Name identifies the hook in errors and logs
+	Name() string
+
+	// PostConvert returns the rules to program for np
+	PostConvert(np *networkingv1.NetworkPolicy, rules []hcnpkg.ACLRule, priorities *hcnpkg.PriorityPool) ([]hcnpkg.ACLRule, error)
+}
+
+var (
+	hooksMu        sync.Mutex
+	registeredPre  []PreConversionHook
+	registeredPost []PostConversionHook
+)
+
+// RegisterPreConversionHook registers a hook compiled into the agent, typically
+// from the init function of a package blank-imported by cmd/main.go
+func RegisterPreConversionHook(hook PreConversionHook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	registeredPre = append(registeredPre, hook)
+}
+
+// RegisterPostConversionHook registers a hook compiled into the agent, typically
+// from the init function of a package blank-imported by cmd/main.go
+func RegisterPostConversionHook(hook PostConversionHook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	registeredPost = append(registeredPost, hook)
+}
+
+// RegisteredHooks returns the hooks registered so far, in registration order
+func RegisteredHooks() ([]PreConversionHook, []PostConversionHook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	return append([]PreConversionHook(nil), registeredPre...), append([]PostConversionHook(nil), registeredPost...)
+}
+
+// runPreHooks applies the pre-conversion hooks to a copy of np
+func runPreHooks(np *networkingv1.NetworkPolicy, hooks []PreConversionHook) (*networkingv1.NetworkPolicy, error) {
+	if len(hooks) == 0 {
+		return np, nil
+	}
+	np = np.DeepCopy()
+	for _, hook := range hooks {
+		if err := hook.PreConvert(np); err != nil {
+			return nil, fmt.Errorf("conversion hook %s rejected NetworkPolicy %s/%s: %w", hook.Name(), np.Namespace, np.Name, err)
+		}
+	}
+	return np, nil
+}
+
+// runPostHooks applies the post-conversion hooks to the generated rules
+func runPostHooks(np *networkingv1.NetworkPolicy, rules []hcnpkg.ACLRule, priorities *hcnpkg.PriorityPool, hooks []PostConversionHook) ([]hcnpkg.ACLRule, error) {
+	for _, hook := range hooks {
+		var err error
+		rules, err = hook.PostConvert(np, rules, priorities)
+		if err != nil {
+			return nil, fmt.Errorf("conversion hook %s failed on NetworkPolicy %s/%s: %w", hook.Name(), np.Namespace, np.Name, err)
+		}
+	}
+	return rules, nil
+}
+
+// stripCIDRsHook removes disallowed address blocks from allow rules
+type stripCIDRsHook struct {
+	disallowed []netip.Prefix
+}
+
+// NewStripCIDRsHook returns a hook that removes the given CIDRs from the remote
+// addresses of every allow rule. Wider blocks are split around them, so
+// "0.0.0.0/0" minus "10.0.0.0/8" still allows everything outside 10.0.0.0/8.
+// Allow rules left without addresses are dropped.
+func NewStripCIDRsHook(cidrs []string) (PostConversionHook, error) {
+	hook := &stripCIDRsHook{}
+	for _, cidr := range cidrs {
+		prefix, err := parsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid disallowed CIDR %q: %w", cidr, err)
+		}
+		hook.disallowed = append(hook.disallowed, prefix)
+	}
+	return hook, nil
+}
+
+// Name implements PostConversionHook
+func (h *stripCIDRsHook) Name() string {
+	return "strip-cidrs"
+}
+
+// PostConvert implements PostConversionHook
+func (h *stripCIDRsHook) PostConvert(_ *networkingv1.NetworkPolicy, rules []hcnpkg.ACLRule, _ *hcnpkg.PriorityPool) ([]hcnpkg.ACLRule, error) {
+	result := make([]hcnpkg.ACLRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Action != hcnlib.ActionTypeAllow || rule.RemoteAddresses == "" {
+			result = append(result, rule)
+			continue
+		}
+
+		var kept []string
+		for _, entry := range strings.Split(rule.RemoteAddresses, ",") {
+			entry = strings.TrimSpace(entry)
+			prefix, err := parsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: invalid remote address %q: %w", rule.Name, entry, err)
+			}
+			remaining := []netip.Prefix{prefix}
+			for _, disallowed := range h.disallowed {
+				var next []netip.Prefix
+				for _, p := range remaining {
+					next = append(next, subtractPrefix(p, disallowed)...)
+				}
+				remaining = next
+			}
+			if len(remaining) == 1 && remaining[0] == prefix {
+				kept = append(kept, entry)
+				continue
+			}
+			for _, p := range remaining {
+				kept = append(kept, p.String())
+			}
+		}
+
+		if len(kept) == 0 {
+			continue
+		}
+		rule.RemoteAddresses = strings.Join(kept, ",")
+		result = append(result, rule)
+	}
+	return result, nil
+}
+
+// parsePrefix parses a CIDR or a bare IP as a host prefix
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// subtractPrefix returns the blocks covering p but not blocked, splitting p in
+// halves until each half is either disjoint from or inside blocked
+func subtractPrefix(p, blocked netip.Prefix) []netip.Prefix {
+	if p.Addr().Is4() != blocked.Addr().Is4() || !p.Overlaps(blocked) {
+		return []netip.Prefix{p}
+	}
+	if blocked.Bits() <= p.Bits() {
+		return nil
+	}
+
+	low := netip.PrefixFrom(p.Addr(), p.Bits()+1)
+	high := netip.PrefixFrom(setBit(p.Addr(), p.Bits()), p.Bits()+1)
+	return append(subtractPrefix(low, blocked), subtractPrefix(high, blocked)...)
+}
+
+// setBit returns addr with bit n (counted from the most significant bit) set
+func setBit(addr netip.Addr, n int) netip.Addr {
+	if addr.Is4() {
+		b := addr.As4()
+		b[n/8] |= 0x80 >> (n % 8)
+		return netip.AddrFrom4(b)
+	}
+	b := addr.As16()
+	b[n/8] |= 0x80 >> (n % 8)
+	return netip.AddrFrom16(b)
+}
+
+// mandatoryRulesHook appends fixed rules to every policy
+type mandatoryRulesHook struct {
+	rules []hcnpkg.ACLRule
+}
+
+// NewMandatoryRulesHook returns a hook that appends rules to the rules of every
+// NetworkPolicy, after the generated ones. Their priorities are reassigned from
+// the policy's band; names are prefixed with the policy.
+func NewMandatoryRulesHook(rules []hcnpkg.ACLRule) PostConversionHook {
+	return &mandatoryRulesHook{rules: rules}
+}
+
+// Name implements PostConversionHook
+func (h *mandatoryRulesHook) Name() string {
+	return "mandatory-rules"
+}
+
+// PostConvert implements PostConversionHook
+func (h *mandatoryRulesHook) PostConvert(np *networkingv1.NetworkPolicy, rules []hcnpkg.ACLRule, priorities *hcnpkg.PriorityPool) ([]hcnpkg.ACLRule, error) {
+	for _, mandatory := range h.rules {
+		mandatory.Name = fmt.Sprintf("%s-%s-mandatory-%s", np.Namespace, np.Name, mandatory.Name)
+		mandatory.Priority = priorities.Next()
+		rules = append(rules, mandatory)
+	}
+	return rules, nil
+}
@@ -0,0 +1,129 @@
+//go:build windows
+
+package converter
+
+import (
+	"errors"
+	"testing"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// labelHook is a pre-conversion hook that drops egress rules and can reject policies
+type labelHook struct {
+	reject bool
+}
+
+func (h *labelHook) Name() string { return "test" }
+
+func (h *labelHook) PreConvert(np *networkingv1.NetworkPolicy) error {
+	if h.reject {
+		return errors.New("rejected")
+	}
+	np.Spec.Egress = nil
+	return nil
+}
+
+func hookTestPolicy() *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{}},
+			Egress:  []networkingv1.NetworkPolicyEgressRule{{}},
+		},
+	}
+}
+
+func TestPreConversionHook(t *testing.T) {
+	np := hookTestPolicy()
+	opts := DefaultConversionOptions()
+	opts.PreHooks = []PreConversionHook{&labelHook{}}
+
+	rules, err := NetworkPolicyToACLRules(np, opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	for _, rule := range rules {
+		if rule.Direction == hcnlib.DirectionTypeOut {
+			t.Errorf("Expected the hook to drop egress rules, got %s", rule.Name)
+		}
+	}
+	if len(np.Spec.Egress) != 1 {
+		t.Error("Expected the hook to mutate a copy, not the caller's policy")
+	}
+
+	opts.PreHooks = []PreConversionHook{&labelHook{reject: true}}
+	if _, err := NetworkPolicyToACLRules(np, opts); err == nil {
+		t.Error("Expected a rejecting hook to fail conversion")
+	}
+}
+
+func TestMandatoryRulesHook(t *testing.T) {
+	opts := DefaultConversionOptions()
+	opts.PostHooks = []PostConversionHook{NewMandatoryRulesHook([]hcnpkg.ACLRule{
+		{Name: "proxy", Action: hcnlib.ActionTypeAllow, Direction: hcnlib.DirectionTypeOut, Protocol: "6", RemotePorts: "3128", RemoteAddresses: "10.10.0.5"},
+	})}
+
+	rules, err := NetworkPolicyToACLRules(hookTestPolicy(), opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	last := rules[len(rules)-1]
+	if last.Name != "default-web-mandatory-proxy" {
+		t.Fatalf("Expected mandatory rule last, got %s", last.Name)
+	}
+	if last.Priority <= rules[len(rules)-2].Priority {
+		t.Errorf("Expected mandatory rule priority after the generated rules, got %d", last.Priority)
+	}
+}
+
+func TestStripCIDRsHook(t *testing.T) {
+	hook, err := NewStripCIDRsHook([]string{"10.0.0.0/8", "169.254.169.254"})
+	if err != nil {
+		t.Fatalf("NewStripCIDRsHook failed: %v", err)
+	}
+
+	rules := []hcnpkg.ACLRule{
+		{Name: "inside", Action: hcnlib.ActionTypeAllow, RemoteAddresses: "10.1.0.0/16"},
+		{Name: "mixed", Action: hcnlib.ActionTypeAllow, RemoteAddresses: "10.1.2.3,192.168.0.0/16"},
+		{Name: "wide", Action: hcnlib.ActionTypeAllow, RemoteAddresses: "0.0.0.0/1"},
+		{Name: "deny", Action: hcnlib.ActionTypeBlock, RemoteAddresses: "10.0.0.0/8"},
+	}
+
+	result, err := hook.PostConvert(hookTestPolicy(), rules, nil)
+	if err != nil {
+		t.Fatalf("PostConvert failed: %v", err)
+	}
+
+	if len(result) != 3 {
+		t.Fatalf("Expected the fully disallowed rule to be dropped, got %d rules", len(result))
+	}
+	if result[0].RemoteAddresses != "192.168.0.0/16" {
+		t.Errorf("Expected only the allowed entry kept, got %s", result[0].RemoteAddresses)
+	}
+	// 0.0.0.0/1 minus 10.0.0.0/8 is split into 0/5, 8/7, 11/8, 12/6, 16/4, 32/3 and 64/2
+	expected := "0.0.0.0/5,8.0.0.0/7,11.0.0.0/8,12.0.0.0/6,16.0.0.0/4,32.0.0.0/3,64.0.0.0/2"
+	if result[1].RemoteAddresses != expected {
+		t.Errorf("Expected split blocks %s, got %s", expected, result[1].RemoteAddresses)
+	}
+	if result[2].RemoteAddresses != "10.0.0.0/8" {
+		t.Error("Expected block rules to be left untouched")
+	}
+}
+
+func TestSubtractPrefix_IPv6(t *testing.T) {
+	p, _ := parsePrefix("fd00::/64")
+	blocked, _ := parsePrefix("fd00::/65")
+	remaining := subtractPrefix(p, blocked)
+	if len(remaining) != 1 || remaining[0].String() != "fd00::8000:0:0:0/65" {
+		t.Errorf("Unexpected remainder %v", remaining)
+	}
+
+	other, _ := parsePrefix("10.0.0.0/8")
+	if remaining := subtractPrefix(p, other); len(remaining) != 1 || remaining[0] != p {
+		t.Error("Expected IPv4 blocks to leave IPv6 prefixes untouched")
+	}
+}
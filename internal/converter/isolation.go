@@ -0,0 +1,57 @@
+//go:build windows
+
+package converter
+
+import (
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// IsolationDenyPriority is the priority of the rules isolating the pods a
+// NetworkPolicy selects. It follows every NetworkPolicy rule, since the
+// allows of all policies selecting a pod add up, and precedes the namespace
+// default rules, which only apply to pods no policy isolates.
+const IsolationDenyPriority = NamespaceDefaultAllowPriority - 1
+
+// isolatesIngress reports whether a NetworkPolicy isolates its pods for
+// ingress. Policies without policyTypes always do, as the API server defaults
+// them to Ingress.
+func isolatesIngress(np *networkingv1.NetworkPolicy) bool {
+	if len(np.Spec.PolicyTypes) == 0 {
+		return true
+	}
+	for _, policyType := range np.Spec.PolicyTypes {
+		if policyType == networkingv1.PolicyTypeIngress {
+			return true
+		}
+	}
+	return false
+}
+
+// appendIsolation appends the Block-all rules denying the traffic no rule
+// allows, for opts.IsolateIngress and opts.IsolateEgress. Every policy emits
+// the same payloads, so an endpoint carries one deny per direction however
+// many policies select it.
+func appendIsolation(rules []hcnpkg.ACLRule, np *networkingv1.NetworkPolicy, opts ConversionOptions) []hcnpkg.ACLRule {
+	if opts.DefaultAction != hcnlib.ActionTypeAllow {
+		return rules
+	}
+	if opts.IsolateIngress && isolatesIngress(np) {
+		rules = append(rules, hcnpkg.ACLRule{
+			Name:      np.Namespace + "/" + np.Name + "-ingress-isolation",
+			Action:    hcnlib.ActionTypeBlock,
+			Direction: hcnlib.DirectionTypeIn,
+			Priority:  IsolationDenyPriority,
+		})
+	}
+	if opts.IsolateEgress && restrictsEgress(np) {
+		rules = append(rules, hcnpkg.ACLRule{
+			Name:      np.Namespace + "/" + np.Name + "-egress-isolation",
+			Action:    hcnlib.ActionTypeBlock,
+			Direction: hcnlib.DirectionTypeOut,
+			Priority:  IsolationDenyPriority,
+		})
+	}
+	return rules
+}
@@ -0,0 +1,124 @@
+//go:build windows
+
+package converter
+
+import (
+	"testing"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNetworkPolicyToACLRules_IsolateIngress(t *testing.T) {
+	opts := DefaultConversionOptions()
+	opts.IsolateIngress = true
+
+	tests := []struct {
+		name        string
+		policyTypes []networkingv1.PolicyType
+		isolated    bool
+	}{
+		{name: "no policy types", isolated: true},
+		{name: "ingress", policyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}, isolated: true},
+		{name: "egress only", policyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			np := &networkingv1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec: networkingv1.NetworkPolicySpec{
+					PolicyTypes: tt.policyTypes,
+					Ingress:     []networkingv1.NetworkPolicyIngressRule{{}},
+				},
+			}
+			rules, err := NetworkPolicyToACLRules(np, opts)
+			if err != nil {
+				t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+			}
+
+			var denies int
+			for _, rule := range rules {
+				if rule.Action != hcnlib.ActionTypeBlock {
+					if rule.Priority >= IsolationDenyPriority {
+						t.Errorf("Expected allow rules before the isolation deny, got priority %d", rule.Priority)
+					}
+					continue
+				}
+				denies++
+				if rule.Direction != hcnlib.DirectionTypeIn || rule.RemoteAddresses != "" || rule.Priority != IsolationDenyPriority {
+					t.Errorf("Unexpected isolation rule: %+v", rule)
+				}
+			}
+			if want := map[bool]int{true: 1}[tt.isolated]; denies != want {
+				t.Errorf("Expected %d isolation rules, got %d: %+v", want, denies, rules)
+			}
+		})
+	}
+}
+
+func TestNetworkPolicyToACLRules_IsolateEgress(t *testing.T) {
+	opts := DefaultConversionOptions()
+	opts.IsolateEgress = true
+	opts.AutoAllowDNS = []string{"10.96.0.10"}
+
+	egress := []networkingv1.NetworkPolicyEgressRule{{}}
+	tests := []struct {
+		name        string
+		policyTypes []networkingv1.PolicyType
+		egress      []networkingv1.NetworkPolicyEgressRule
+		isolated    bool
+	}{
+		{name: "no policy types"},
+		{name: "no policy types with egress rules", egress: egress, isolated: true},
+		{name: "egress without rules", policyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress}, isolated: true},
+		{name: "ingress only", policyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}, egress: egress},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			np := &networkingv1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec:       networkingv1.NetworkPolicySpec{PolicyTypes: tt.policyTypes, Egress: tt.egress},
+			}
+			rules, err := NetworkPolicyToACLRules(np, opts)
+			if err != nil {
+				t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+			}
+
+			var denies int
+			for _, rule := range rules {
+				if rule.Action != hcnlib.ActionTypeBlock {
+					continue
+				}
+				denies++
+				if rule.Direction != hcnlib.DirectionTypeOut || rule.Priority != IsolationDenyPriority {
+					t.Errorf("Unexpected isolation rule: %+v", rule)
+				}
+			}
+			if want := map[bool]int{true: 1}[tt.isolated]; denies != want {
+				t.Errorf("Expected %d isolation rules, got %d: %+v", want, denies, rules)
+			}
+		})
+	}
+}
+
+func TestNetworkPolicyToACLRules_IsolationOff(t *testing.T) {
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       networkingv1.NetworkPolicySpec{PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}},
+	}
+	rules, err := NetworkPolicyToACLRules(np, DefaultConversionOptions())
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	if len(rules) != 0 {
+		t.Errorf("Expected no rules without IsolateIngress, got %+v", rules)
+	}
+}
+
+func TestConversionOptions_IsolationClampsMaxPriority(t *testing.T) {
+	opts := ConversionOptions{IsolateIngress: true}.withDefaults()
+	if opts.MaxPriority != IsolationDenyPriority-1 {
+		t.Errorf("Expected the max priority below the isolation deny, got %d", opts.MaxPriority)
+	}
+}
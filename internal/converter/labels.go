@@ -0,0 +1,114 @@
+//go:build windows
+
+package converter
+
+import (
+	"strings"
+	"time"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RuleLabelsAnnotation attaches audit labels to every rule generated from a
+// NetworkPolicy, e.g. "team=payments,ticket=NET-1234". Labels are tracked by
+// the agent and shown by fwctl; they are never sent to HNS.
+const RuleLabelsAnnotation = "networking.knabben.github.io/rule-labels"
+
+const (
+	// PolicyLabel is the rule label holding the "namespace/name" of the source policy
+	PolicyLabel = hcnpkg.PolicyLabel
+
+	// SelectorLabel is the rule label holding the pod selector of the source policy
+	SelectorLabel = hcnpkg.SelectorLabel
+
+	// RuleLabel is the rule label holding the spec entry the rule was generated
+	// from: "ingress[i]", "egress[i]", "same-namespace", "auto-dns" or "isolation"
+	RuleLabel = hcnpkg.RuleLabel
+
+	// UIDLabel is the rule label holding the UID of the source policy
+	UIDLabel = hcnpkg.UIDLabel
+
+	// ModifiedByLabel is the rule label holding the field manager that last
+	// modified the source policy, e.g. "kubectl-client-side-apply"
+	ModifiedByLabel = "modified-by"
+
+	// ModifiedAtLabel is the rule label holding when the source policy was last modified
+	ModifiedAtLabel = "modified-at"
+)
+
+// Modification is the most recent change recorded in an object's managedFields
+type Modification struct {
+	// Manager is the field manager, which identifies the client (kubectl,
+	// a GitOps controller, an operator) rather than the authenticated user
+	Manager   string
+	Operation metav1.ManagedFieldsOperationType
+	Time      time.Time
+}
+
+// LastModification returns the most recent change to obj's spec or metadata
+// recorded in its managedFields. Status updates are ignored.
+func LastModification(obj metav1.Object) (Modification, bool) {
+	var last Modification
+	found := false
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Subresource != "" || entry.Time == nil {
+			continue
+		}
+		if !found || !entry.Time.Time.Before(last.Time) {
+			last = Modification{Manager: entry.Manager, Operation: entry.Operation, Time: entry.Time.Time}
+			found = true
+		}
+	}
+	return last, found
+}
+
+// ruleLabels returns the labels every rule of np is annotated with
+func ruleLabels(np *networkingv1.NetworkPolicy) map[string]string {
+	labels := map[string]string{}
+	for _, pair := range strings.Split(np.Annotations[RuleLabelsAnnotation], ",") {
+		key, value, found := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			continue
+		}
+		labels[key] = strings.TrimSpace(value)
+	}
+	labels[PolicyLabel] = np.Namespace + "/" + np.Name
+	labels[SelectorLabel] = metav1.FormatLabelSelector(&np.Spec.PodSelector)
+	if np.UID != "" {
+		labels[UIDLabel] = string(np.UID)
+	}
+	if modification, found := LastModification(np); found {
+		labels[ModifiedByLabel] = modification.Manager
+		labels[ModifiedAtLabel] = modification.Time.UTC().Format(time.RFC3339)
+	}
+	return labels
+}
+
+// applyRuleLabels annotates rules with the labels of np. Labels already set on
+// a rule (e.g. by a conversion hook) take precedence.
+func applyRuleLabels(np *networkingv1.NetworkPolicy, rules []hcnpkg.ACLRule) {
+	labels := ruleLabels(np)
+	for i := range rules {
+		merged := make(map[string]string, len(labels)+len(rules[i].Labels))
+		for key, value := range labels {
+			merged[key] = value
+		}
+		for key, value := range rules[i].Labels {
+			merged[key] = value
+		}
+		rules[i].Labels = merged
+	}
+}
+
+// labelSpecEntry sets the RuleLabel of rules to the spec entry they were generated from
+func labelSpecEntry(rules []hcnpkg.ACLRule, entry string) {
+	for i := range rules {
+		if rules[i].Labels == nil {
+			rules[i].Labels = make(map[string]string, 1)
+		}
+		rules[i].Labels[RuleLabel] = entry
+	}
+}
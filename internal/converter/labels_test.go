@@ -0,0 +1,100 @@
+//go:build windows
+
+package converter
+
+import (
+	"testing"
+	"time"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNetworkPolicyToACLRules_Labels(t *testing.T) {
+	np := hookTestPolicy()
+	np.Annotations = map[string]string{RuleLabelsAnnotation: "team=payments, ticket=NET-1,malformed,=empty"}
+	np.Spec.PodSelector = metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+
+	opts := DefaultConversionOptions()
+	opts.PostHooks = []PostConversionHook{NewMandatoryRulesHook([]hcnpkg.ACLRule{
+		{Name: "audit", Labels: map[string]string{"team": "security"}},
+	})}
+
+	rules, err := NetworkPolicyToACLRules(np, opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("Expected 3 rules, got %d", len(rules))
+	}
+
+	labels := rules[0].Labels
+	if labels["team"] != "payments" || labels["ticket"] != "NET-1" {
+		t.Errorf("Expected annotation labels, got %v", labels)
+	}
+	if labels[PolicyLabel] != "default/web" || labels[SelectorLabel] != "app=web" {
+		t.Errorf("Expected policy and selector labels, got %v", labels)
+	}
+	if labels[RuleLabel] != "ingress[0]" || rules[1].Labels[RuleLabel] != "egress[0]" {
+		t.Errorf("Expected the rules labelled with their spec entry, got %v and %v", labels, rules[1].Labels)
+	}
+	if len(labels) != 5 {
+		t.Errorf("Expected malformed pairs to be skipped, got %v", labels)
+	}
+
+	// Each rule owns its labels, and labels set by hooks win
+	rules[0].Labels["team"] = "changed"
+	if rules[1].Labels["team"] != "payments" {
+		t.Error("Expected rules not to share a labels map")
+	}
+	if rules[2].Labels["team"] != "security" || rules[2].Labels[PolicyLabel] != "default/web" {
+		t.Errorf("Expected hook labels to be merged, got %v", rules[2].Labels)
+	}
+}
+
+func TestNetworkPolicyToACLRules_UIDLabel(t *testing.T) {
+	np := hookTestPolicy()
+	np.UID = "3f2a6c1e-8d4b-4f7a-9c2e-1b5d7e9f0a3c"
+	rules, err := NetworkPolicyToACLRules(np, DefaultConversionOptions())
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	for _, rule := range rules {
+		if rule.Labels[UIDLabel] != string(np.UID) {
+			t.Errorf("Expected every rule labelled with the policy UID, got %v", rule.Labels)
+		}
+	}
+}
+
+func TestLastModification(t *testing.T) {
+	older := metav1.NewTime(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	newer := metav1.NewTime(time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC))
+	newest := metav1.NewTime(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC))
+
+	np := hookTestPolicy()
+	np.ManagedFields = []metav1.ManagedFieldsEntry{
+		{Manager: "argocd-controller", Operation: metav1.ManagedFieldsOperationApply, Time: &newer},
+		{Manager: "kubectl-client-side-apply", Operation: metav1.ManagedFieldsOperationUpdate, Time: &older},
+		{Manager: "status-writer", Operation: metav1.ManagedFieldsOperationUpdate, Time: &newest, Subresource: "status"},
+	}
+
+	modification, found := LastModification(np)
+	if !found {
+		t.Fatal("Expected a modification")
+	}
+	if modification.Manager != "argocd-controller" || modification.Operation != metav1.ManagedFieldsOperationApply {
+		t.Errorf("Expected the latest non-status change by argocd-controller, got %+v", modification)
+	}
+
+	rules, err := NetworkPolicyToACLRules(np, DefaultConversionOptions())
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	if labels := rules[0].Labels; labels[ModifiedByLabel] != "argocd-controller" || labels[ModifiedAtLabel] != "2025-02-01T00:00:00Z" {
+		t.Errorf("Expected modification labels, got %v", labels)
+	}
+
+	if _, found := LastModification(hookTestPolicy()); found {
+		t.Error("Expected no modification without managedFields")
+	}
+}
@@ -0,0 +1,80 @@
+//go:build windows
+
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+
+	v1alpha1 "github.com/knabben/firewall-controller/api/v1alpha1"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+const (
+	// NamespaceDefaultAllowPriority is the priority of namespace default allow rules,
+	// below the NetworkPolicy band so explicit policies are evaluated first
+	NamespaceDefaultAllowPriority = hcnpkg.MaxPriority - 1
+
+	// NamespaceDefaultDenyPriority is the priority of the namespace default deny rules,
+	// the lowest precedence a rule can have
+	NamespaceDefaultDenyPriority = hcnpkg.MaxPriority
+)
+
+// NamespaceDefaultPolicyToACLRules converts a NamespaceDefaultPolicy to the ACL rules
+// programmed on every endpoint of the namespace.
+// namespaceIPs are the IPs of the pods currently running in the namespace;
+// autoDNSServers, when set, are allowed on port 53 whenever egress is denied.
+func NamespaceDefaultPolicyToACLRules(ndp *v1alpha1.NamespaceDefaultPolicy, namespaceIPs, autoDNSServers []string) []hcnpkg.ACLRule {
+	var rules []hcnpkg.ACLRule
+	name := fmt.Sprintf("%s-%s", ndp.Namespace, ndp.Name)
+	namespaceAddresses := strings.Join(namespaceIPs, ",")
+
+	if ndp.Spec.Ingress.AllowSameNamespace && namespaceAddresses != "" {
+		rules = append(rules, hcnpkg.ACLRule{
+			Name:            name + "-ingress-same-namespace",
+			Action:          hcnlib.ActionTypeAllow,
+			Direction:       hcnlib.DirectionTypeIn,
+			RemoteAddresses: namespaceAddresses,
+			Priority:        NamespaceDefaultAllowPriority,
+		})
+	}
+
+	if ndp.Spec.Egress.AllowSameNamespace && namespaceAddresses != "" {
+		rules = append(rules, hcnpkg.ACLRule{
+			Name:            name + "-egress-same-namespace",
+			Action:          hcnlib.ActionTypeAllow,
+			Direction:       hcnlib.DirectionTypeOut,
+			RemoteAddresses: namespaceAddresses,
+			Priority:        NamespaceDefaultAllowPriority,
+		})
+	}
+
+	if ndp.Spec.Egress.AllowDNS {
+		rules = append(rules, dnsAllowRules(name+"-egress", ndp.Spec.DNSServers, NamespaceDefaultAllowPriority)...)
+	} else if ndp.Spec.Egress.DenyOther && len(autoDNSServers) > 0 {
+		// Keep DNS working under a default-deny egress posture
+		rules = append(rules, dnsAllowRules(name+"-egress", autoDNSServers, NamespaceDefaultAllowPriority)...)
+	}
+
+	if ndp.Spec.Ingress.DenyOther {
+		rules = append(rules, hcnpkg.ACLRule{
+			Name:      name + "-ingress-deny",
+			Action:    hcnlib.ActionTypeBlock,
+			Direction: hcnlib.DirectionTypeIn,
+			Priority:  NamespaceDefaultDenyPriority,
+		})
+	}
+
+	if ndp.Spec.Egress.DenyOther {
+		rules = append(rules, hcnpkg.ACLRule{
+			Name:      name + "-egress-deny",
+			Action:    hcnlib.ActionTypeBlock,
+			Direction: hcnlib.DirectionTypeOut,
+			Priority:  NamespaceDefaultDenyPriority,
+		})
+	}
+
+	return rules
+}
@@ -0,0 +1,82 @@
+//go:build windows
+
+package converter
+
+import (
+	"testing"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1alpha1 "github.com/knabben/firewall-controller/api/v1alpha1"
+)
+
+func TestNamespaceDefaultPolicyToACLRules(t *testing.T) {
+	ndp := &v1alpha1.NamespaceDefaultPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "baseline", Namespace: "team-a"},
+		Spec: v1alpha1.NamespaceDefaultPolicySpec{
+			Ingress:    v1alpha1.IngressPosture{AllowSameNamespace: true, DenyOther: true},
+			Egress:     v1alpha1.EgressPosture{AllowSameNamespace: true, AllowDNS: true, DenyOther: true},
+			DNSServers: []string{"10.96.0.10"},
+		},
+	}
+
+	rules := NamespaceDefaultPolicyToACLRules(ndp, []string{"10.0.0.1", "10.0.0.2"}, nil)
+
+	// 2 same-namespace allows, 2 DNS allows (UDP+TCP), 2 denies
+	if len(rules) != 6 {
+		t.Fatalf("Expected 6 rules, got %d: %+v", len(rules), rules)
+	}
+
+	for _, rule := range rules {
+		switch rule.Action {
+		case hcnlib.ActionTypeAllow:
+			if rule.Priority != NamespaceDefaultAllowPriority {
+				t.Errorf("Allow rule %s has priority %d, want %d", rule.Name, rule.Priority, NamespaceDefaultAllowPriority)
+			}
+		case hcnlib.ActionTypeBlock:
+			if rule.Priority != NamespaceDefaultDenyPriority {
+				t.Errorf("Block rule %s has priority %d, want %d", rule.Name, rule.Priority, NamespaceDefaultDenyPriority)
+			}
+		}
+	}
+
+	if rules[0].RemoteAddresses != "10.0.0.1,10.0.0.2" {
+		t.Errorf("Expected same-namespace rule to target pod IPs, got %q", rules[0].RemoteAddresses)
+	}
+	if rules[2].RemotePorts != "53" || rules[2].RemoteAddresses != "10.96.0.10" {
+		t.Errorf("Unexpected DNS rule: %+v", rules[2])
+	}
+}
+
+func TestNamespaceDefaultPolicyToACLRules_NoPods(t *testing.T) {
+	ndp := &v1alpha1.NamespaceDefaultPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "baseline", Namespace: "team-a"},
+		Spec: v1alpha1.NamespaceDefaultPolicySpec{
+			Ingress: v1alpha1.IngressPosture{AllowSameNamespace: true},
+		},
+	}
+
+	if rules := NamespaceDefaultPolicyToACLRules(ndp, nil, nil); len(rules) != 0 {
+		t.Errorf("Expected no rules without namespace pods, got %+v", rules)
+	}
+}
+
+func TestNamespaceDefaultPolicyToACLRules_AutoAllowDNS(t *testing.T) {
+	ndp := &v1alpha1.NamespaceDefaultPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "baseline", Namespace: "team-a"},
+		Spec: v1alpha1.NamespaceDefaultPolicySpec{
+			Egress: v1alpha1.EgressPosture{DenyOther: true},
+		},
+	}
+
+	rules := NamespaceDefaultPolicyToACLRules(ndp, nil, []string{"10.96.0.10", "169.254.20.10"})
+
+	// UDP and TCP DNS allows plus the egress deny
+	if len(rules) != 3 {
+		t.Fatalf("Expected 3 rules, got %d: %+v", len(rules), rules)
+	}
+	if rules[0].RemoteAddresses != "10.96.0.10,169.254.20.10" || rules[0].RemotePorts != "53" {
+		t.Errorf("Unexpected auto DNS rule: %+v", rules[0])
+	}
+}
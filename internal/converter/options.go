@@ -0,0 +1,236 @@
+//go:build windows
+
+package converter
+
+import (
+	"errors"
+	"fmt"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// ErrUnsupportedField is returned in strict mode when a policy uses a construct
+// the converter cannot translate faithfully
+var ErrUnsupportedField = errors.New("unsupported NetworkPolicy field")
+
+// AddressFamily selects which IP family the converter emits default addresses for
+type AddressFamily string
+
+const (
+	// AddressFamilyIPv4 emits IPv4 defaults only
+	AddressFamilyIPv4 AddressFamily = "IPv4"
+	// AddressFamilyIPv6 emits IPv6 defaults only
+	AddressFamilyIPv6 AddressFamily = "IPv6"
+	// AddressFamilyDualStack emits both IPv4 and IPv6 defaults
+	AddressFamilyDualStack AddressFamily = "DualStack"
+)
+
+// ParseAddressFamily parses an --address-family value
+func ParseAddressFamily(value string) (AddressFamily, error) {
+	switch family := AddressFamily(value); family {
+	case AddressFamilyIPv4, AddressFamilyIPv6, AddressFamilyDualStack:
+		return family, nil
+	default:
+		return "", fmt.Errorf("invalid address family %q: must be IPv4, IPv6 or DualStack", value)
+	}
+}
+
+// ConversionOptions tunes how NetworkPolicies are translated into ACL rules.
+// Unset fields fall back to DefaultConversionOptions.
+type ConversionOptions struct {
+	// BasePriority is the priority assigned to the first generated rule
+	BasePriority uint16
+
+	// MaxPriority is the highest priority a generated rule may use
+	MaxPriority uint16
+
+	// PriorityStride is the gap between the priorities of consecutive rules,
+	// leaving room for rules inserted by operators or other agents
+	PriorityStride uint16
+
+	// ReservedPriorities are ranges owned by other agents; no rule is emitted in them
+	ReservedPriorities []hcnpkg.PriorityRange
+
+	// DefaultAction is the action of rules generated from allow lists
+	DefaultAction hcnlib.ActionType
+
+	// DefaultIPv4CIDR is used as RemoteAddresses when a rule has no peers
+	DefaultIPv4CIDR string
+
+	// DefaultIPv6CIDR is used as RemoteAddresses when a rule has no peers
+	DefaultIPv6CIDR string
+
+	// AddressFamily selects which default CIDRs are emitted
+	AddressFamily AddressFamily
+
+	// RejectUnsupportedPeers fails conversion instead of skipping peers that
+	// cannot be resolved to addresses (pod/namespace selectors)
+	RejectUnsupportedPeers bool
+
+	// RejectNamedPorts fails conversion instead of matching all ports for named ports
+	RejectNamedPorts bool
+
+	// Strict fails the conversion of a policy with any construct that would
+	// not be enforced (see WarningReason.Unsupported) with an
+	// UnsupportedFieldsError listing all of them, instead of converting the
+	// rest of the policy
+	Strict bool
+
+	// NamedPorts resolves the named ports of ingress rules to the container
+	// port numbers of the pods the policy selects, keyed by NamedPortKey
+	NamedPorts map[string][]int32
+
+	// PeerNamedPorts resolves the named ports of egress rules to the container
+	// port numbers of the pods each To peer selects, keyed by PeerKey and then
+	// NamedPortKey. Rules to anywhere and to ipBlock peers use AnyPeerKey.
+	PeerNamedPorts map[string]map[string][]int32
+
+	// NamespacePodIPs are the live pod IPs of the policy's namespace, used to
+	// expand the same-namespace peer (see SameNamespaceAnnotation)
+	NamespacePodIPs []string
+
+	// PeerAddresses are the resolved IPs of pod and namespace selector peers,
+	// keyed by PeerKey; selector peers without an entry are unsupported
+	PeerAddresses map[string][]string
+
+	// NamespacePodCIDRs are the pod CIDRs of the policy's namespace and of the
+	// namespaces its peers select, used by the namespace peer aggregation (see
+	// PeerAggregationAnnotation)
+	NamespacePodCIDRs []string
+
+	// AutoAllowDNS are DNS server IPs (kube-dns, node-local DNS) allowed on
+	// UDP/TCP 53 in every policy that restricts egress
+	AutoAllowDNS []string
+
+	// EgressGateways are the SNAT or egress gateway addresses traffic to
+	// ipBlock peers leaves the cluster through; egress rules to ipBlock peers
+	// match them too, as HNS sees that traffic addressed to the gateway
+	EgressGateways []string
+
+	// PreHooks run on a copy of each NetworkPolicy before conversion
+	PreHooks []PreConversionHook
+
+	// PostHooks run on the rules generated for each NetworkPolicy, in order
+	PostHooks []PostConversionHook
+
+	// MaxRemoteAddresses splits rules whose resolved peer addresses outnumber
+	// it into several rules of at most this many addresses each; 0 never splits
+	MaxRemoteAddresses int
+
+	// aggregation is the peer aggregation of the policy being converted
+	aggregation PeerAggregation
+
+	// PackRules merges rules that differ only in their remote addresses or
+	// ports into one ACL each (see hcn.PackRules). Packing moves merged rules
+	// up to the priority of the first one, which only matters for rules of
+	// another action in the same priority band.
+	PackRules bool
+
+	// IsolateIngress adds a Block-all ingress rule at IsolationDenyPriority to
+	// every policy isolating its pods for ingress, so traffic no policy allows
+	// is denied. Generated rules are kept below that priority. It only applies
+	// with the Allow default action.
+	IsolateIngress bool
+
+	// IsolateEgress adds a Block-all egress rule at IsolationDenyPriority to
+	// every policy isolating its pods for egress, like IsolateIngress
+	IsolateEgress bool
+
+	// chunks memoizes the split addresses of resolved peers for one conversion
+	chunks *peerChunks
+
+	// warnings collects the warnings of a ConvertNetworkPolicy call; nil drops them
+	warnings *warnings
+}
+
+// DefaultConversionOptions returns the options matching the converter's historical behavior
+func DefaultConversionOptions() ConversionOptions {
+	return ConversionOptions{
+		BasePriority:    100,
+		MaxPriority:     hcnpkg.MaxPriority,
+		PriorityStride:  1,
+		DefaultAction:   hcnlib.ActionTypeAllow,
+		DefaultIPv4CIDR: "0.0.0.0/0",
+		DefaultIPv6CIDR: "::/0",
+		AddressFamily:   AddressFamilyIPv4,
+	}
+}
+
+// withDefaults fills unset fields from DefaultConversionOptions so a zero value is usable
+func (o ConversionOptions) withDefaults() ConversionOptions {
+	defaults := DefaultConversionOptions()
+	if o.BasePriority == 0 {
+		o.BasePriority = defaults.BasePriority
+	}
+	if o.MaxPriority == 0 {
+		o.MaxPriority = defaults.MaxPriority
+	}
+	if o.PriorityStride == 0 {
+		o.PriorityStride = defaults.PriorityStride
+	}
+	if o.DefaultAction == "" {
+		o.DefaultAction = defaults.DefaultAction
+	}
+	if o.DefaultIPv4CIDR == "" {
+		o.DefaultIPv4CIDR = defaults.DefaultIPv4CIDR
+	}
+	if o.DefaultIPv6CIDR == "" {
+		o.DefaultIPv6CIDR = defaults.DefaultIPv6CIDR
+	}
+	if o.AddressFamily == "" {
+		o.AddressFamily = defaults.AddressFamily
+	}
+	if (o.IsolateIngress || o.IsolateEgress) && o.MaxPriority >= IsolationDenyPriority {
+		// Isolation denies follow every generated rule
+		o.MaxPriority = IsolationDenyPriority - 1
+	}
+	return o
+}
+
+// Validate checks the options for values the converter cannot work with
+func (o ConversionOptions) Validate() error {
+	switch o.DefaultAction {
+	case hcnlib.ActionTypeAllow, hcnlib.ActionTypeBlock:
+	default:
+		return fmt.Errorf("invalid default action %q", o.DefaultAction)
+	}
+
+	switch o.AddressFamily {
+	case AddressFamilyIPv4, AddressFamilyIPv6, AddressFamilyDualStack:
+	default:
+		return fmt.Errorf("invalid address family %q", o.AddressFamily)
+	}
+
+	if o.BasePriority < hcnpkg.MinPriority || o.BasePriority > o.MaxPriority || o.MaxPriority > hcnpkg.MaxPriority {
+		return fmt.Errorf("priority band %d-%d outside the valid range %d-%d",
+			o.BasePriority, o.MaxPriority, hcnpkg.MinPriority, hcnpkg.MaxPriority)
+	}
+
+	if o.MaxRemoteAddresses < 0 {
+		return fmt.Errorf("max remote addresses must not be negative, got %d", o.MaxRemoteAddresses)
+	}
+
+	for _, reserved := range o.ReservedPriorities {
+		if err := reserved.Validate(); err != nil {
+			return fmt.Errorf("invalid reserved priority range: %w", err)
+		}
+		if reserved.Contains(o.BasePriority) {
+			return fmt.Errorf("base priority %d lies in reserved range %s", o.BasePriority, reserved)
+		}
+	}
+
+	return nil
+}
+
+// defaultRemoteAddresses returns the RemoteAddresses used for rules without peers
+func (o ConversionOptions) defaultRemoteAddresses() string {
+	switch o.AddressFamily {
+	case AddressFamilyIPv6:
+		return o.DefaultIPv6CIDR
+	case AddressFamilyDualStack:
+		return o.DefaultIPv4CIDR + "," + o.DefaultIPv6CIDR
+	default:
+		return o.DefaultIPv4CIDR
+	}
+}
@@ -0,0 +1,243 @@
+//go:build windows
+
+package converter
+
+import (
+	"errors"
+	"testing"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestNetworkPolicyToACLRules_CustomOptions(t *testing.T) {
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					Ports: []networkingv1.NetworkPolicyPort{
+						{Protocol: protoPtr(corev1.ProtocolTCP), Port: &intstr.IntOrString{Type: intstr.Int, IntVal: 80}},
+						{Protocol: protoPtr(corev1.ProtocolTCP), Port: &intstr.IntOrString{Type: intstr.Int, IntVal: 443}},
+					},
+				},
+			},
+		},
+	}
+
+	opts := DefaultConversionOptions()
+	opts.BasePriority = 1000
+	opts.DefaultAction = hcnlib.ActionTypeBlock
+	opts.AddressFamily = AddressFamilyDualStack
+
+	rules, err := NetworkPolicyToACLRules(np, opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("Expected 2 rules, got %d", len(rules))
+	}
+
+	for i, rule := range rules {
+		if rule.Priority != uint16(1000+i) {
+			t.Errorf("rules[%d].Priority = %d, want %d", i, rule.Priority, 1000+i)
+		}
+		if rule.Action != hcnlib.ActionTypeBlock {
+			t.Errorf("rules[%d].Action = %v, want Block", i, rule.Action)
+		}
+		if rule.RemoteAddresses != "0.0.0.0/0,::/0" {
+			t.Errorf("rules[%d].RemoteAddresses = %s, want dual-stack defaults", i, rule.RemoteAddresses)
+		}
+	}
+}
+
+func TestNetworkPolicyToACLRules_ZeroOptionsUseDefaults(t *testing.T) {
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{}},
+		},
+	}
+
+	rules, err := NetworkPolicyToACLRules(np, ConversionOptions{})
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	if len(rules) != 1 {
+		t.Fatalf("Expected 1 rule, got %d", len(rules))
+	}
+	if rules[0].Priority != 100 || rules[0].Action != hcnlib.ActionTypeAllow || rules[0].RemoteAddresses != "0.0.0.0/0" {
+		t.Errorf("Expected default priority/action/CIDR, got %+v", rules[0])
+	}
+}
+
+func TestNetworkPolicyToACLRules_StrictOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		rule networkingv1.NetworkPolicyIngressRule
+		opts ConversionOptions
+	}{
+		{
+			name: "selector peer rejected",
+			rule: networkingv1.NetworkPolicyIngressRule{
+				From: []networkingv1.NetworkPolicyPeer{
+					{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}},
+				},
+			},
+			opts: ConversionOptions{RejectUnsupportedPeers: true},
+		},
+		{
+			name: "named port rejected",
+			rule: networkingv1.NetworkPolicyIngressRule{
+				Ports: []networkingv1.NetworkPolicyPort{
+					{Port: &intstr.IntOrString{Type: intstr.String, StrVal: "http"}},
+				},
+			},
+			opts: ConversionOptions{RejectNamedPorts: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			np := &networkingv1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "strict", Namespace: "default"},
+				Spec: networkingv1.NetworkPolicySpec{
+					Ingress: []networkingv1.NetworkPolicyIngressRule{tt.rule},
+				},
+			}
+
+			_, err := NetworkPolicyToACLRules(np, tt.opts)
+			if !errors.Is(err, ErrUnsupportedField) {
+				t.Errorf("Expected ErrUnsupportedField, got %v", err)
+			}
+		})
+	}
+}
+
+func TestConversionOptions_Validate(t *testing.T) {
+	opts := DefaultConversionOptions()
+	if err := opts.Validate(); err != nil {
+		t.Errorf("Default options should be valid: %v", err)
+	}
+
+	opts.AddressFamily = "IPv5"
+	if err := opts.Validate(); err == nil {
+		t.Error("Expected error for invalid address family")
+	}
+}
+
+func TestNetworkPolicyToACLRules_StrideAndReservedRanges(t *testing.T) {
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					Ports: []networkingv1.NetworkPolicyPort{
+						{Port: &intstr.IntOrString{Type: intstr.Int, IntVal: 80}},
+						{Port: &intstr.IntOrString{Type: intstr.Int, IntVal: 443}},
+						{Port: &intstr.IntOrString{Type: intstr.Int, IntVal: 8080}},
+					},
+				},
+			},
+		},
+	}
+
+	opts := DefaultConversionOptions()
+	opts.BasePriority = 1000
+	opts.PriorityStride = 10
+	opts.ReservedPriorities = []hcnpkg.PriorityRange{{Start: 1005, End: 1015}}
+
+	rules, err := NetworkPolicyToACLRules(np, opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+
+	expected := []uint16{1000, 1016, 1026}
+	for i, rule := range rules {
+		if rule.Priority != expected[i] {
+			t.Errorf("rules[%d].Priority = %d, want %d", i, rule.Priority, expected[i])
+		}
+	}
+}
+
+func TestConversionOptions_BaseInReservedRange(t *testing.T) {
+	opts := DefaultConversionOptions()
+	opts.ReservedPriorities = []hcnpkg.PriorityRange{{Start: 1, End: 200}}
+
+	if err := opts.Validate(); err == nil {
+		t.Error("Expected error when base priority is reserved")
+	}
+}
+
+func TestNetworkPolicyToACLRules_PriorityOverflow(t *testing.T) {
+	var ports []networkingv1.NetworkPolicyPort
+	for i := 0; i < 5; i++ {
+		ports = append(ports, networkingv1.NetworkPolicyPort{Port: &intstr.IntOrString{Type: intstr.Int, IntVal: int32(8000 + i)}})
+	}
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "big", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{Ports: ports}},
+		},
+	}
+
+	opts := DefaultConversionOptions()
+	opts.BasePriority = 100
+	opts.MaxPriority = 103
+
+	_, err := NetworkPolicyToACLRules(np, opts)
+	if !errors.Is(err, hcnpkg.ErrPriorityExhausted) {
+		t.Errorf("Expected ErrPriorityExhausted, got %v", err)
+	}
+}
+
+func TestNetworkPolicyToACLRules_PackRules(t *testing.T) {
+	tcp := corev1.ProtocolTCP
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From: []networkingv1.NetworkPolicyPeer{
+					{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.1.0/24"}},
+					{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.2.0/24"}},
+				},
+				Ports: []networkingv1.NetworkPolicyPort{
+					{Protocol: &tcp, Port: &intstr.IntOrString{IntVal: 80}},
+					{Protocol: &tcp, Port: &intstr.IntOrString{IntVal: 443}},
+				},
+			}},
+		},
+	}
+
+	opts := DefaultConversionOptions()
+	opts.PackRules = true
+	rules, err := NetworkPolicyToACLRules(np, opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	// The 2 ports x 2 peers cross-product fits in one ACL
+	if len(rules) != 1 {
+		t.Fatalf("Expected one ACL, got %+v", rules)
+	}
+	if rules[0].LocalPorts != "80,443" || rules[0].RemoteAddresses != "10.0.1.0/24,10.0.2.0/24" || len(rules[0].Packed) != 4 {
+		t.Errorf("Expected the ACL to pack both peers on both ports, got %+v", rules[0])
+	}
+	if rules[0].Labels[PolicyLabel] != "default/web" || rules[0].Packed[3].Labels[PolicyLabel] != "default/web" {
+		t.Error("Expected the ACL and its packed rules to keep the policy label")
+	}
+}
+
+func TestParseAddressFamily(t *testing.T) {
+	for _, value := range []string{"IPv4", "IPv6", "DualStack"} {
+		if family, err := ParseAddressFamily(value); err != nil || string(family) != value {
+			t.Errorf("ParseAddressFamily(%q) = %q, %v", value, family, err)
+		}
+	}
+	if _, err := ParseAddressFamily("ipv6"); err == nil {
+		t.Error("Expected an unknown address family to be rejected")
+	}
+}
@@ -4,37 +4,131 @@ package converter
 
 import (
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 
 	hcnlib "github.com/Microsoft/hcsshim/hcn"
 	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // NetworkPolicyToACLRules converts a Kubernetes NetworkPolicy to HCN ACL rules
-// It expands the ingress and egress rules into individual ACL rules with incremental priorities
-func NetworkPolicyToACLRules(np *networkingv1.NetworkPolicy) []hcnpkg.ACLRule {
+// It expands the ingress and egress rules into individual ACL rules with priorities
+// allocated from a pool starting at opts.BasePriority, advancing by opts.PriorityStride
+// and skipping reserved ranges; running past opts.MaxPriority is an error
+func NetworkPolicyToACLRules(np *networkingv1.NetworkPolicy, opts ConversionOptions) ([]hcnpkg.ACLRule, error) {
+	opts = opts.withDefaults()
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	np, err := runPreHooks(np, opts.PreHooks)
+	if err != nil {
+		return nil, err
+	}
+	if opts.aggregation, err = PeerAggregationOf(np); err != nil {
+		return nil, err
+	}
+	opts.chunks = &peerChunks{}
+	if opts.Strict && opts.warnings == nil {
+		// Strict mode decides on the warnings, even when the caller drops them
+		opts.warnings = &warnings{}
+	}
+
 	var rules []hcnpkg.ACLRule
-	priority := uint16(100) // Starting priority
+	priorities, err := hcnpkg.NewPriorityPool(opts.BasePriority, opts.MaxPriority, opts.PriorityStride, opts.ReservedPriorities)
+	if err != nil {
+		return nil, err
+	}
+
+	if rule, ok := singleRule(np); ok {
+		// Most policies have one rule with one port and peer; convert them
+		// into a slice sized for the rule and its isolation denies
+		rules, err = appendSingleRule(make([]hcnpkg.ACLRule, 0, singleRuleCapacity), np, rule, priorities, opts)
+		if err != nil {
+			return nil, err
+		}
+		if rule.direction == hcnlib.DirectionTypeIn {
+			labelSpecEntry(rules, "ingress[0]")
+		} else {
+			labelSpecEntry(rules, "egress[0]")
+		}
+	} else {
+		// Process ingress rules
+		for i, ingressRule := range np.Spec.Ingress {
+			ingressRules, err := convertIngressRule(np, ingressRule, priorities, opts)
+			if err != nil {
+				return nil, err
+			}
+			labelSpecEntry(ingressRules, fmt.Sprintf("ingress[%d]", i))
+			rules = append(rules, ingressRules...)
+		}
+
+		// Process egress rules
+		for i, egressRule := range np.Spec.Egress {
+			egressRules, err := convertEgressRule(np, egressRule, priorities, opts)
+			if err != nil {
+				return nil, err
+			}
+			labelSpecEntry(egressRules, fmt.Sprintf("egress[%d]", i))
+			rules = append(rules, egressRules...)
+		}
+	}
+
+	// Expand the built-in same-namespace peer, if requested
+	sameNamespaceRules, err := convertSameNamespacePeer(np, priorities, opts)
+	if err != nil {
+		return nil, err
+	}
+	labelSpecEntry(sameNamespaceRules, "same-namespace")
+	rules = append(rules, sameNamespaceRules...)
+
+	// Keep DNS reachable from pods whose egress is restricted
+	dnsRules := convertAutoDNS(np, priorities, opts)
+	labelSpecEntry(dnsRules, "auto-dns")
+	rules = append(rules, dnsRules...)
+
+	// Let compiled-in hooks rewrite the generated rules
+	rules, err = runPostHooks(np, rules, priorities, opts.PostHooks)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep hook-provided priorities out of values HNS rejects or reserves
+	guardPriorities(rules, priorities, opts)
+
+	// Deny the ingress no rule allows to isolated pods
+	generated := len(rules)
+	rules = appendIsolation(rules, np, opts)
+	labelSpecEntry(rules[generated:], "isolation")
 
-	// Process ingress rules
-	for _, ingressRule := range np.Spec.Ingress {
-		ingressRules := convertIngressRule(np, ingressRule, &priority)
-		rules = append(rules, ingressRules...)
+	// Annotate the rules for auditing
+	applyRuleLabels(np, rules)
+
+	// Merge rules HNS can enforce as one ACL, once their labels are final
+	if opts.PackRules {
+		rules = hcnpkg.PackRules(rules, opts.MaxRemoteAddresses)
+	}
+
+	// Fail instead of emitting wrapped-around or HNS-reserved priorities
+	if err := priorities.Err(); err != nil {
+		return nil, fmt.Errorf("NetworkPolicy %s/%s generates too many rules: %w", np.Namespace, np.Name, err)
 	}
 
-	// Process egress rules
-	for _, egressRule := range np.Spec.Egress {
-		egressRules := convertEgressRule(np, egressRule, &priority)
-		rules = append(rules, egressRules...)
+	// In strict mode no rule is emitted for a partially enforced policy
+	if err := opts.strictError(np); err != nil {
+		return nil, err
 	}
 
-	return rules
+	return rules, nil
 }
 
 // convertIngressRule converts a single ingress rule to one or more ACL rules
-func convertIngressRule(np *networkingv1.NetworkPolicy, ingressRule networkingv1.NetworkPolicyIngressRule, priority *uint16) []hcnpkg.ACLRule {
+func convertIngressRule(np *networkingv1.NetworkPolicy, ingressRule networkingv1.NetworkPolicyIngressRule, priorities *hcnpkg.PriorityPool, opts ConversionOptions) ([]hcnpkg.ACLRule, error) {
 	var rules []hcnpkg.ACLRule
 
 	// If no ports specified, create a rule for all ports
@@ -43,79 +137,86 @@ func convertIngressRule(np *networkingv1.NetworkPolicy, ingressRule networkingv1
 		if len(ingressRule.From) == 0 {
 			rule := hcnpkg.ACLRule{
 				Name:            fmt.Sprintf("%s/%s-ingress", np.Namespace, np.Name),
-				Action:          hcnlib.ActionTypeAllow,
+				Action:          opts.DefaultAction,
 				Direction:       hcnlib.DirectionTypeIn,
 				Protocol:        "", // Empty means all protocols
-				RemoteAddresses: "0.0.0.0/0",
-				Priority:        *priority,
+				RemoteAddresses: opts.defaultRemoteAddresses(),
+				Priority:        priorities.Next(),
 			}
-			*priority++
 			rules = append(rules, rule)
 		} else {
 			// Create rule for each From peer
 			for _, from := range ingressRule.From {
-				remoteAddr := getPeerAddress(from)
-				if remoteAddr == "" {
-					continue // Skip if we can't determine address
+				remoteAddrs, err := peerAddresses(np, from, opts)
+				if err != nil {
+					return nil, err
 				}
 
-				rule := hcnpkg.ACLRule{
-					Name:            fmt.Sprintf("%s/%s-ingress", np.Namespace, np.Name),
-					Action:          hcnlib.ActionTypeAllow,
-					Direction:       hcnlib.DirectionTypeIn,
-					Protocol:        "",
-					RemoteAddresses: remoteAddr,
-					Priority:        *priority,
+				// Unresolvable peers yield no rule, large ones one rule per chunk
+				for _, remoteAddr := range remoteAddrs {
+					rule := hcnpkg.ACLRule{
+						Name:            fmt.Sprintf("%s/%s-ingress", np.Namespace, np.Name),
+						Action:          opts.DefaultAction,
+						Direction:       hcnlib.DirectionTypeIn,
+						Protocol:        "",
+						RemoteAddresses: remoteAddr,
+						Priority:        priorities.Next(),
+					}
+					rules = append(rules, rule)
 				}
-				*priority++
-				rules = append(rules, rule)
 			}
 		}
 	} else {
 		// Create rules for each port
 		for _, port := range ingressRule.Ports {
+			ports, err := convertPort(np, port, opts)
+			if err != nil {
+				return nil, err
+			}
+
 			// If no From specified, allow from anywhere
 			if len(ingressRule.From) == 0 {
 				rule := hcnpkg.ACLRule{
 					Name:            fmt.Sprintf("%s/%s-ingress", np.Namespace, np.Name),
-					Action:          hcnlib.ActionTypeAllow,
+					Action:          opts.DefaultAction,
 					Direction:       hcnlib.DirectionTypeIn,
 					Protocol:        protocolToNumber(port.Protocol),
-					LocalPorts:      portToString(port.Port),
-					RemoteAddresses: "0.0.0.0/0",
-					Priority:        *priority,
+					LocalPorts:      ports,
+					RemoteAddresses: opts.defaultRemoteAddresses(),
+					Priority:        priorities.Next(),
 				}
-				*priority++
 				rules = append(rules, rule)
 			} else {
 				// Create rule for each From peer × port combination
 				for _, from := range ingressRule.From {
-					remoteAddr := getPeerAddress(from)
-					if remoteAddr == "" {
-						continue // Skip if we can't determine address
+					remoteAddrs, err := peerAddresses(np, from, opts)
+					if err != nil {
+						return nil, err
 					}
 
-					rule := hcnpkg.ACLRule{
-						Name:            fmt.Sprintf("%s/%s-ingress", np.Namespace, np.Name),
-						Action:          hcnlib.ActionTypeAllow,
-						Direction:       hcnlib.DirectionTypeIn,
-						Protocol:        protocolToNumber(port.Protocol),
-						LocalPorts:      portToString(port.Port),
-						RemoteAddresses: remoteAddr,
-						Priority:        *priority,
+					// Unresolvable peers yield no rule, large ones one rule per chunk
+					for _, remoteAddr := range remoteAddrs {
+						rule := hcnpkg.ACLRule{
+							Name:            fmt.Sprintf("%s/%s-ingress", np.Namespace, np.Name),
+							Action:          opts.DefaultAction,
+							Direction:       hcnlib.DirectionTypeIn,
+							Protocol:        protocolToNumber(port.Protocol),
+							LocalPorts:      ports,
+							RemoteAddresses: remoteAddr,
+							Priority:        priorities.Next(),
+						}
+						rules = append(rules, rule)
 					}
-					*priority++
-					rules = append(rules, rule)
 				}
 			}
 		}
 	}
 
-	return rules
+	return rules, nil
 }
 
 // convertEgressRule converts a single egress rule to one or more ACL rules
-func convertEgressRule(np *networkingv1.NetworkPolicy, egressRule networkingv1.NetworkPolicyEgressRule, priority *uint16) []hcnpkg.ACLRule {
+func convertEgressRule(np *networkingv1.NetworkPolicy, egressRule networkingv1.NetworkPolicyEgressRule, priorities *hcnpkg.PriorityPool, opts ConversionOptions) ([]hcnpkg.ACLRule, error) {
 	var rules []hcnpkg.ACLRule
 
 	// If no ports specified, create a rule for all ports
@@ -124,32 +225,33 @@ func convertEgressRule(np *networkingv1.NetworkPolicy, egressRule networkingv1.N
 		if len(egressRule.To) == 0 {
 			rule := hcnpkg.ACLRule{
 				Name:            fmt.Sprintf("%s/%s-egress", np.Namespace, np.Name),
-				Action:          hcnlib.ActionTypeAllow,
+				Action:          opts.DefaultAction,
 				Direction:       hcnlib.DirectionTypeOut,
 				Protocol:        "", // Empty means all protocols
-				RemoteAddresses: "0.0.0.0/0",
-				Priority:        *priority,
+				RemoteAddresses: opts.defaultRemoteAddresses(),
+				Priority:        priorities.Next(),
 			}
-			*priority++
 			rules = append(rules, rule)
 		} else {
 			// Create rule for each To peer
 			for _, to := range egressRule.To {
-				remoteAddr := getPeerAddress(to)
-				if remoteAddr == "" {
-					continue // Skip if we can't determine address
+				remoteAddrs, err := egressPeerAddresses(np, to, opts)
+				if err != nil {
+					return nil, err
 				}
 
-				rule := hcnpkg.ACLRule{
-					Name:            fmt.Sprintf("%s/%s-egress", np.Namespace, np.Name),
-					Action:          hcnlib.ActionTypeAllow,
-					Direction:       hcnlib.DirectionTypeOut,
-					Protocol:        "",
-					RemoteAddresses: remoteAddr,
-					Priority:        *priority,
+				// Unresolvable peers yield no rule, large ones one rule per chunk
+				for _, remoteAddr := range remoteAddrs {
+					rule := hcnpkg.ACLRule{
+						Name:            fmt.Sprintf("%s/%s-egress", np.Namespace, np.Name),
+						Action:          opts.DefaultAction,
+						Direction:       hcnlib.DirectionTypeOut,
+						Protocol:        "",
+						RemoteAddresses: remoteAddr,
+						Priority:        priorities.Next(),
+					}
+					rules = append(rules, rule)
 				}
-				*priority++
-				rules = append(rules, rule)
 			}
 		}
 	} else {
@@ -157,57 +259,186 @@ func convertEgressRule(np *networkingv1.NetworkPolicy, egressRule networkingv1.N
 		for _, port := range egressRule.Ports {
 			// If no To specified, allow to anywhere
 			if len(egressRule.To) == 0 {
+				ports, err := egressPort(np, port, networkingv1.NetworkPolicyPeer{}, opts)
+				if err != nil {
+					return nil, err
+				}
 				rule := hcnpkg.ACLRule{
 					Name:            fmt.Sprintf("%s/%s-egress", np.Namespace, np.Name),
-					Action:          hcnlib.ActionTypeAllow,
+					Action:          opts.DefaultAction,
 					Direction:       hcnlib.DirectionTypeOut,
 					Protocol:        protocolToNumber(port.Protocol),
-					RemotePorts:     portToString(port.Port),
-					RemoteAddresses: "0.0.0.0/0",
-					Priority:        *priority,
+					RemotePorts:     ports,
+					RemoteAddresses: opts.defaultRemoteAddresses(),
+					Priority:        priorities.Next(),
 				}
-				*priority++
 				rules = append(rules, rule)
 			} else {
 				// Create rule for each To peer × port combination
 				for _, to := range egressRule.To {
-					remoteAddr := getPeerAddress(to)
-					if remoteAddr == "" {
-						continue // Skip if we can't determine address
+					// Named ports are those of the peer's pods
+					ports, err := egressPort(np, port, to, opts)
+					if err != nil {
+						return nil, err
+					}
+					remoteAddrs, err := egressPeerAddresses(np, to, opts)
+					if err != nil {
+						return nil, err
 					}
 
-					rule := hcnpkg.ACLRule{
-						Name:            fmt.Sprintf("%s/%s-egress", np.Namespace, np.Name),
-						Action:          hcnlib.ActionTypeAllow,
-						Direction:       hcnlib.DirectionTypeOut,
-						Protocol:        protocolToNumber(port.Protocol),
-						RemotePorts:     portToString(port.Port),
-						RemoteAddresses: remoteAddr,
-						Priority:        *priority,
+					// Unresolvable peers yield no rule, large ones one rule per chunk
+					for _, remoteAddr := range remoteAddrs {
+						rule := hcnpkg.ACLRule{
+							Name:            fmt.Sprintf("%s/%s-egress", np.Namespace, np.Name),
+							Action:          opts.DefaultAction,
+							Direction:       hcnlib.DirectionTypeOut,
+							Protocol:        protocolToNumber(port.Protocol),
+							RemotePorts:     ports,
+							RemoteAddresses: remoteAddr,
+							Priority:        priorities.Next(),
+						}
+						rules = append(rules, rule)
 					}
-					*priority++
-					rules = append(rules, rule)
 				}
 			}
 		}
 	}
 
-	return rules
+	return rules, nil
 }
 
-// getPeerAddress extracts the IP address/CIDR from a NetworkPolicyPeer
+// getPeerAddress extracts the IP address/CIDR from a NetworkPolicyPeer.
+// Selector peers are resolved beforehand into opts.PeerAddresses (see
+// peerAddresses); those left unresolved, without a --peer-resolver or with one
+// that does not handle them, have no address here.
 func getPeerAddress(peer networkingv1.NetworkPolicyPeer) string {
-	// For now, only support IPBlock
-	// TODO: Add support for PodSelector and NamespaceSelector (requires pod IP mapping)
 	if peer.IPBlock != nil {
 		return peer.IPBlock.CIDR
 	}
-
-	// If PodSelector or NamespaceSelector is specified, we need pod IP resolution
-	// This is a future enhancement (Phase 9 in the implementation plan)
 	return ""
 }
 
+// peerAddresses returns the remote addresses of peer, one entry per rule to
+// emit, warning about the parts of it that are not enforced. Resolved peers
+// with more than opts.MaxRemoteAddresses addresses span several entries; peers
+// that must be skipped have none.
+func peerAddresses(np *networkingv1.NetworkPolicy, peer networkingv1.NetworkPolicyPeer, opts ConversionOptions) ([]string, error) {
+	if peer.IPBlock == nil {
+		key := PeerKey(peer)
+		if addresses, resolved := opts.PeerAddresses[key]; resolved {
+			// A selector matching no pods allows nothing
+			return opts.addressChunks(key, peerKind(peer), addresses), nil
+		}
+	}
+	remoteAddr := getPeerAddress(peer)
+	if remoteAddr == "" {
+		if opts.RejectUnsupportedPeers {
+			return nil, unsupportedPeerError(np, peer)
+		}
+		opts.warn(WarningSelectorUnsupported, "%s skipped: only ipBlock peers are enforced", peerKind(peer))
+		return nil, nil
+	}
+	if err := validateIPBlock(np, remoteAddr); err != nil {
+		return nil, err
+	}
+	if len(peer.IPBlock.Except) > 0 {
+		opts.warn(WarningExceptIgnored, "ipBlock %s except %s is not enforced: the excepted ranges are matched too",
+			peer.IPBlock.CIDR, strings.Join(peer.IPBlock.Except, ","))
+	}
+	return []string{remoteAddr}, nil
+}
+
+// validateIPBlock checks the CIDR of an ipBlock peer of np
+func validateIPBlock(np *networkingv1.NetworkPolicy, cidr string) error {
+	if _, _, err := net.ParseCIDR(cidr); err != nil {
+		return fmt.Errorf("invalid ipBlock in NetworkPolicy %s/%s: %w", np.Namespace, np.Name, err)
+	}
+	return nil
+}
+
+// convertPort converts a NetworkPolicyPort's port to the HCN port string: a
+// number, or a "port-endPort" range when endPort is set. Named ports are
+// resolved through opts.NamedPorts; unresolved ones match all ports unless the
+// options require strict conversion.
+func convertPort(np *networkingv1.NetworkPolicy, port networkingv1.NetworkPolicyPort, opts ConversionOptions) (string, error) {
+	if port.Port != nil && port.Port.Type == intstr.String {
+		protocol := corev1.ProtocolTCP
+		if port.Protocol != nil {
+			protocol = *port.Protocol
+		}
+		if numbers := opts.NamedPorts[NamedPortKey(port.Port.StrVal, protocol)]; len(numbers) > 0 {
+			resolved := make([]string, len(numbers))
+			for i, number := range numbers {
+				resolved[i] = strconv.Itoa(int(number))
+			}
+			return strings.Join(resolved, ","), nil
+		}
+		if opts.RejectNamedPorts {
+			return "", fmt.Errorf("%w: named port %q in NetworkPolicy %s/%s",
+				ErrUnsupportedField, port.Port.StrVal, np.Namespace, np.Name)
+		}
+		opts.warn(WarningNamedPortDropped, "named port %q (%s) not resolved: all ports are matched",
+			port.Port.StrVal, protocol)
+	}
+	if port.EndPort != nil {
+		// The API server rejects endPort with a named or missing port; be as strict
+		if port.Port == nil || port.Port.Type != intstr.Int || *port.EndPort < port.Port.IntVal {
+			return "", fmt.Errorf("invalid endPort %d for port %s in NetworkPolicy %s/%s",
+				*port.EndPort, portToString(port.Port), np.Namespace, np.Name)
+		}
+		if *port.EndPort > port.Port.IntVal {
+			return fmt.Sprintf("%d-%d", port.Port.IntVal, *port.EndPort), nil
+		}
+	}
+	return portToString(port.Port), nil
+}
+
+// PeerKey returns the key the addresses of a selector peer are resolved under
+func PeerKey(peer networkingv1.NetworkPolicyPeer) string {
+	selector := func(s *metav1.LabelSelector) string {
+		if s == nil {
+			return "-"
+		}
+		return "{" + metav1.FormatLabelSelector(s) + "}"
+	}
+	return "pods=" + selector(peer.PodSelector) + ",namespaces=" + selector(peer.NamespaceSelector)
+}
+
+// AnyPeerKey is the PeerKey of ipBlock peers. Egress named ports to them, or
+// to anywhere, resolve against every pod.
+const AnyPeerKey = "pods=-,namespaces=-"
+
+// egressPort converts a port of an egress rule to peer, resolving a named port
+// against the pods of peer; an empty peer stands for a rule to anywhere
+func egressPort(np *networkingv1.NetworkPolicy, port networkingv1.NetworkPolicyPort, peer networkingv1.NetworkPolicyPeer, opts ConversionOptions) (string, error) {
+	opts.NamedPorts = opts.PeerNamedPorts[PeerKey(peer)]
+	return convertPort(np, port, opts)
+}
+
+// NamedPortKey returns the key a named container port is resolved under
+func NamedPortKey(name string, protocol corev1.Protocol) string {
+	if protocol == "" {
+		protocol = corev1.ProtocolTCP
+	}
+	return name + "/" + string(protocol)
+}
+
+// unsupportedPeerError describes a peer that cannot be translated to remote addresses
+func unsupportedPeerError(np *networkingv1.NetworkPolicy, peer networkingv1.NetworkPolicyPeer) error {
+	return fmt.Errorf("%w: %s in NetworkPolicy %s/%s", ErrUnsupportedField, peerKind(peer), np.Namespace, np.Name)
+}
+
+// peerKind names the kind of a peer for errors and warnings
+func peerKind(peer networkingv1.NetworkPolicyPeer) string {
+	switch {
+	case peer.PodSelector != nil:
+		return "podSelector peer"
+	case peer.NamespaceSelector != nil:
+		return "namespaceSelector peer"
+	}
+	return "peer"
+}
+
 // protocolToNumber converts a Kubernetes protocol to its IP protocol number
 func protocolToNumber(proto *corev1.Protocol) string {
 	if proto == nil {
@@ -159,7 +159,10 @@ func TestNetworkPolicyToACLRules_IngressOnly(t *testing.T) {
 		},
 	}
 
-	rules := NetworkPolicyToACLRules(np)
+	rules, err := NetworkPolicyToACLRules(np, DefaultConversionOptions())
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
 
 	if len(rules) != 1 {
 		t.Fatalf("Expected 1 rule, got %d", len(rules))
@@ -217,7 +220,10 @@ func TestNetworkPolicyToACLRules_EgressOnly(t *testing.T) {
 		},
 	}
 
-	rules := NetworkPolicyToACLRules(np)
+	rules, err := NetworkPolicyToACLRules(np, DefaultConversionOptions())
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
 
 	if len(rules) != 1 {
 		t.Fatalf("Expected 1 rule, got %d", len(rules))
@@ -281,7 +287,10 @@ func TestNetworkPolicyToACLRules_MultiplePortsAndPeers(t *testing.T) {
 		},
 	}
 
-	rules := NetworkPolicyToACLRules(np)
+	rules, err := NetworkPolicyToACLRules(np, DefaultConversionOptions())
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
 
 	// Should create 2 ports × 2 peers = 4 rules
 	if len(rules) != 4 {
@@ -355,7 +364,10 @@ func TestNetworkPolicyToACLRules_IngressAndEgress(t *testing.T) {
 		},
 	}
 
-	rules := NetworkPolicyToACLRules(np)
+	rules, err := NetworkPolicyToACLRules(np, DefaultConversionOptions())
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
 
 	if len(rules) != 2 {
 		t.Fatalf("Expected 2 rules (1 ingress + 1 egress), got %d", len(rules))
@@ -395,7 +407,10 @@ func TestNetworkPolicyToACLRules_EmptyRules(t *testing.T) {
 		},
 	}
 
-	rules := NetworkPolicyToACLRules(np)
+	rules, err := NetworkPolicyToACLRules(np, DefaultConversionOptions())
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
 
 	if len(rules) != 0 {
 		t.Fatalf("Expected 0 rules for empty policy, got %d", len(rules))
@@ -428,7 +443,10 @@ func TestNetworkPolicyToACLRules_NoPortsSpecified(t *testing.T) {
 		},
 	}
 
-	rules := NetworkPolicyToACLRules(np)
+	rules, err := NetworkPolicyToACLRules(np, DefaultConversionOptions())
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
 
 	if len(rules) != 1 {
 		t.Fatalf("Expected 1 rule, got %d", len(rules))
@@ -447,3 +465,174 @@ func TestNetworkPolicyToACLRules_NoPortsSpecified(t *testing.T) {
 func protoPtr(p corev1.Protocol) *corev1.Protocol {
 	return &p
 }
+
+func TestNetworkPolicyToACLRules_ResolvedNamedPort(t *testing.T) {
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "named", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				Ports: []networkingv1.NetworkPolicyPort{{Port: &intstr.IntOrString{Type: intstr.String, StrVal: "http"}}},
+			}},
+		},
+	}
+
+	opts := DefaultConversionOptions()
+	opts.RejectNamedPorts = true
+	opts.NamedPorts = map[string][]int32{NamedPortKey("http", corev1.ProtocolTCP): {8080, 9090}}
+
+	rules, err := NetworkPolicyToACLRules(np, opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	if len(rules) != 1 || rules[0].LocalPorts != "8080,9090" {
+		t.Errorf("Expected named port resolved to 8080,9090, got %+v", rules)
+	}
+}
+
+func TestNetworkPolicyToACLRules_EgressNamedPortPerPeer(t *testing.T) {
+	named := intstr.FromString("sql")
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "to-db", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Egress: []networkingv1.NetworkPolicyEgressRule{{
+				To: []networkingv1.NetworkPolicyPeer{
+					{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "postgres"}}},
+					{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "mysql"}}},
+				},
+				Ports: []networkingv1.NetworkPolicyPort{{Port: &named}},
+			}},
+		},
+	}
+	postgres, mysql := np.Spec.Egress[0].To[0], np.Spec.Egress[0].To[1]
+
+	opts := DefaultConversionOptions()
+	opts.RejectNamedPorts = true
+	// The policy's own pods declare the name too; egress must not use them
+	opts.NamedPorts = map[string][]int32{NamedPortKey("sql", corev1.ProtocolTCP): {1}}
+	opts.PeerNamedPorts = map[string]map[string][]int32{
+		PeerKey(postgres): {NamedPortKey("sql", corev1.ProtocolTCP): {5432}},
+		PeerKey(mysql):    {NamedPortKey("sql", corev1.ProtocolTCP): {3306}},
+	}
+	opts.PeerAddresses = map[string][]string{PeerKey(postgres): {"10.244.0.5"}, PeerKey(mysql): {"10.244.0.6"}}
+
+	rules, err := NetworkPolicyToACLRules(np, opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	if len(rules) != 2 || rules[0].RemotePorts != "5432" || rules[1].RemotePorts != "3306" {
+		t.Errorf("Expected each peer's own port number, got %+v", rules)
+	}
+}
+
+func TestNetworkPolicyToACLRules_EndPort(t *testing.T) {
+	endPort := func(port int32) *int32 { return &port }
+	tests := []struct {
+		name    string
+		port    networkingv1.NetworkPolicyPort
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "range",
+			port: networkingv1.NetworkPolicyPort{Port: &intstr.IntOrString{Type: intstr.Int, IntVal: 8000}, EndPort: endPort(9000)},
+			want: "8000-9000",
+		},
+		{
+			name: "single port range",
+			port: networkingv1.NetworkPolicyPort{Port: &intstr.IntOrString{Type: intstr.Int, IntVal: 8000}, EndPort: endPort(8000)},
+			want: "8000",
+		},
+		{
+			name:    "end before start",
+			port:    networkingv1.NetworkPolicyPort{Port: &intstr.IntOrString{Type: intstr.Int, IntVal: 8000}, EndPort: endPort(7000)},
+			wantErr: true,
+		},
+		{
+			name:    "named port",
+			port:    networkingv1.NetworkPolicyPort{Port: &intstr.IntOrString{Type: intstr.String, StrVal: "http"}, EndPort: endPort(9000)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			np := &networkingv1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "range", Namespace: "default"},
+				Spec: networkingv1.NetworkPolicySpec{
+					Ingress: []networkingv1.NetworkPolicyIngressRule{{Ports: []networkingv1.NetworkPolicyPort{tt.port}}},
+					Egress:  []networkingv1.NetworkPolicyEgressRule{{Ports: []networkingv1.NetworkPolicyPort{tt.port}}},
+				},
+			}
+			conversion, err := ConvertNetworkPolicy(np, DefaultConversionOptions())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConvertNetworkPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(conversion.Rules) != 2 || conversion.Rules[0].LocalPorts != tt.want || conversion.Rules[1].RemotePorts != tt.want {
+				t.Errorf("Expected ports %s in both directions, got %+v", tt.want, conversion.Rules)
+			}
+			if len(conversion.Warnings) != 0 {
+				t.Errorf("Expected no warnings, got %v", conversion.Warnings)
+			}
+		})
+	}
+}
+
+func TestNetworkPolicyToACLRules_ResolvedSelectorPeers(t *testing.T) {
+	web := networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}}
+	idle := networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "idle"}}}
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "selectors", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{From: []networkingv1.NetworkPolicyPeer{web, idle}}},
+		},
+	}
+
+	opts := DefaultConversionOptions()
+	opts.RejectUnsupportedPeers = true
+	opts.PeerAddresses = map[string][]string{
+		PeerKey(web):  {"10.0.0.1", "10.0.0.2"},
+		PeerKey(idle): {},
+	}
+
+	// A peer selecting no pods allows nothing
+	rules, err := NetworkPolicyToACLRules(np, opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	if len(rules) != 1 || rules[0].RemoteAddresses != "10.0.0.1,10.0.0.2" {
+		t.Errorf("Expected one rule for the resolved web pods, got %+v", rules)
+	}
+	if PeerKey(web) == PeerKey(networkingv1.NetworkPolicyPeer{NamespaceSelector: web.PodSelector}) {
+		t.Error("Expected pod and namespace selectors to have distinct keys")
+	}
+}
+
+func TestNetworkPolicyToACLRules_IPv6IPBlock(t *testing.T) {
+	policy := func(cidr string) *networkingv1.NetworkPolicy {
+		return &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec: networkingv1.NetworkPolicySpec{
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{
+					From: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: cidr}}},
+				}},
+			},
+		}
+	}
+
+	opts := DefaultConversionOptions()
+	opts.AddressFamily = AddressFamilyDualStack
+	rules, err := NetworkPolicyToACLRules(policy("fd00:10:244::/64"), opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	if len(rules) != 1 || rules[0].RemoteAddresses != "fd00:10:244::/64" {
+		t.Errorf("Expected one rule for the IPv6 block, got %+v", rules)
+	}
+
+	if _, err := NetworkPolicyToACLRules(policy("fd00:10:244::/129"), opts); err == nil {
+		t.Error("Expected an invalid ipBlock CIDR to fail the conversion")
+	}
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package converter
+
+import (
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// PriorityBand returns the band generated rules draw their priorities from
+func (o ConversionOptions) PriorityBand() hcnpkg.PriorityRange {
+	o = o.withDefaults()
+	return hcnpkg.PriorityRange{Start: o.BasePriority, End: o.MaxPriority}
+}
+
+// PrioritySpan returns how many consecutive priorities, from the start of the
+// band of opts, rules use. Rules at fixed priorities outside the band, such
+// as isolation denies, do not count.
+func PrioritySpan(rules []hcnpkg.ACLRule, opts ConversionOptions) int {
+	band := opts.PriorityBand()
+	span := 0
+	for _, rule := range rules {
+		if band.Contains(rule.Priority) {
+			span = max(span, int(rule.Priority)-int(band.Start)+1)
+		}
+	}
+	return span
+}
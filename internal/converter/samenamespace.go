@@ -0,0 +1,79 @@
+//go:build windows
+
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// SameNamespaceAnnotation enables the built-in "same-namespace" peer on a
+// NetworkPolicy. Its value is a comma-separated list of directions ("ingress",
+// "egress") in which all traffic from/to the pods of the policy's namespace
+// is allowed, e.g. "ingress,egress".
+const SameNamespaceAnnotation = "networking.knabben.github.io/same-namespace"
+
+// SameNamespaceDirections returns the directions the same-namespace peer is enabled for
+func SameNamespaceDirections(np *networkingv1.NetworkPolicy) (ingress, egress bool, err error) {
+	value, exists := np.Annotations[SameNamespaceAnnotation]
+	if !exists {
+		return false, false, nil
+	}
+
+	for _, direction := range strings.Split(value, ",") {
+		switch strings.ToLower(strings.TrimSpace(direction)) {
+		case "ingress":
+			ingress = true
+		case "egress":
+			egress = true
+		case "":
+		default:
+			return false, false, fmt.Errorf("NetworkPolicy %s/%s: invalid %s direction %q",
+				np.Namespace, np.Name, SameNamespaceAnnotation, direction)
+		}
+	}
+	return ingress, egress, nil
+}
+
+// convertSameNamespacePeer emits the allow rules of the same-namespace peer,
+// expanded to opts.NamespacePodIPs and split like resolved selector peers
+func convertSameNamespacePeer(np *networkingv1.NetworkPolicy, priorities *hcnpkg.PriorityPool, opts ConversionOptions) ([]hcnpkg.ACLRule, error) {
+	ingress, egress, err := SameNamespaceDirections(np)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.NamespacePodIPs) == 0 {
+		return nil, nil
+	}
+
+	var rules []hcnpkg.ACLRule
+	chunks := opts.addressChunks("same-namespace", "same-namespace peer", opts.NamespacePodIPs)
+
+	if ingress {
+		for _, remoteAddresses := range chunks {
+			rules = append(rules, hcnpkg.ACLRule{
+				Name:            fmt.Sprintf("%s/%s-ingress-same-namespace", np.Namespace, np.Name),
+				Action:          opts.DefaultAction,
+				Direction:       hcnlib.DirectionTypeIn,
+				RemoteAddresses: remoteAddresses,
+				Priority:        priorities.Next(),
+			})
+		}
+	}
+	if egress {
+		for _, remoteAddresses := range chunks {
+			rules = append(rules, hcnpkg.ACLRule{
+				Name:            fmt.Sprintf("%s/%s-egress-same-namespace", np.Namespace, np.Name),
+				Action:          opts.DefaultAction,
+				Direction:       hcnlib.DirectionTypeOut,
+				RemoteAddresses: remoteAddresses,
+				Priority:        priorities.Next(),
+			})
+		}
+	}
+	return rules, nil
+}
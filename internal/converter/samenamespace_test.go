@@ -0,0 +1,74 @@
+//go:build windows
+
+package converter
+
+import (
+	"testing"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSameNamespaceDirections(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		ingress     bool
+		egress      bool
+		expectErr   bool
+	}{
+		{name: "no annotation"},
+		{name: "ingress only", annotations: map[string]string{SameNamespaceAnnotation: "ingress"}, ingress: true},
+		{name: "both directions", annotations: map[string]string{SameNamespaceAnnotation: "Ingress, egress"}, ingress: true, egress: true},
+		{name: "invalid direction", annotations: map[string]string{SameNamespaceAnnotation: "sideways"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			np := &networkingv1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "p", Namespace: "default", Annotations: tt.annotations},
+			}
+			ingress, egress, err := SameNamespaceDirections(np)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("SameNamespaceDirections() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if ingress != tt.ingress || egress != tt.egress {
+				t.Errorf("SameNamespaceDirections() = (%v, %v), want (%v, %v)", ingress, egress, tt.ingress, tt.egress)
+			}
+		})
+	}
+}
+
+func TestNetworkPolicyToACLRules_SameNamespacePeer(t *testing.T) {
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "same-ns",
+			Namespace:   "team-a",
+			Annotations: map[string]string{SameNamespaceAnnotation: "ingress"},
+		},
+	}
+
+	opts := DefaultConversionOptions()
+	opts.NamespacePodIPs = []string{"10.0.0.1", "10.0.0.2"}
+
+	rules, err := NetworkPolicyToACLRules(np, opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	if len(rules) != 1 {
+		t.Fatalf("Expected 1 same-namespace rule, got %d: %+v", len(rules), rules)
+	}
+	if rules[0].Direction != hcnlib.DirectionTypeIn || rules[0].RemoteAddresses != "10.0.0.1,10.0.0.2" {
+		t.Errorf("Unexpected same-namespace rule: %+v", rules[0])
+	}
+
+	// Without live pods there is nothing to allow
+	rules, err = NetworkPolicyToACLRules(np, DefaultConversionOptions())
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	if len(rules) != 0 {
+		t.Errorf("Expected no rules without namespace pods, got %+v", rules)
+	}
+}
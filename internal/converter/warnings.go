@@ -0,0 +1,189 @@
+//go:build windows
+
+package converter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// WarningReason classifies a conversion warning
+type WarningReason string
+
+const (
+	// WarningPriorityRemapped means a rule's priority was reserved or rejected
+	// by HNS and the rule was moved into the managed band
+	WarningPriorityRemapped WarningReason = "priority-remapped"
+
+	// WarningNamedPortDropped means a named port could not be resolved and the
+	// rule matches all ports instead
+	WarningNamedPortDropped WarningReason = "named-port-dropped"
+
+	// WarningSelectorUnsupported means a podSelector or namespaceSelector peer
+	// was skipped
+	WarningSelectorUnsupported WarningReason = "selector-unsupported"
+
+	// WarningExceptIgnored means an ipBlock's except ranges are matched like the
+	// rest of the block
+	WarningExceptIgnored WarningReason = "except-ignored"
+
+	// WarningPeerAggregated means a peer's resolved addresses were widened by
+	// the policy's peer aggregation, or could not be aggregated as requested
+	WarningPeerAggregated WarningReason = "peer-aggregated"
+)
+
+// Unsupported reports whether warnings of reason mean part of the policy is
+// not enforced, rather than enforced differently than written. Remapped
+// priorities keep every rule and aggregation is requested by the policy.
+func (r WarningReason) Unsupported() bool {
+	switch r {
+	case WarningNamedPortDropped, WarningSelectorUnsupported, WarningExceptIgnored:
+		return true
+	default:
+		return false
+	}
+}
+
+// Warning reports a part of a NetworkPolicy that is not enforced exactly as written
+type Warning struct {
+	Reason  WarningReason
+	Message string
+}
+
+// String formats the warning as "reason: message"
+func (w Warning) String() string {
+	return string(w.Reason) + ": " + w.Message
+}
+
+// UnsupportedFieldsError is returned in strict mode for a policy using
+// constructs that are not enforced; it lists all of them. It matches
+// ErrUnsupportedField with errors.Is.
+type UnsupportedFieldsError struct {
+	// Policy is the namespace/name of the rejected NetworkPolicy
+	Policy string
+
+	// Warnings are the unsupported constructs, as raised in best-effort mode
+	Warnings []Warning
+}
+
+// Error implements error
+func (e *UnsupportedFieldsError) Error() string {
+	reasons := make([]string, len(e.Warnings))
+	for i, warning := range e.Warnings {
+		reasons[i] = warning.String()
+	}
+	return fmt.Sprintf("%s in NetworkPolicy %s: %s", ErrUnsupportedField, e.Policy, strings.Join(reasons, "; "))
+}
+
+// Unwrap returns ErrUnsupportedField
+func (e *UnsupportedFieldsError) Unwrap() error {
+	return ErrUnsupportedField
+}
+
+// Reasons returns the distinct reasons of the unsupported constructs, in order
+func (e *UnsupportedFieldsError) Reasons() []WarningReason {
+	var reasons []WarningReason
+	for _, warning := range e.Warnings {
+		if !slices.Contains(reasons, warning.Reason) {
+			reasons = append(reasons, warning.Reason)
+		}
+	}
+	return reasons
+}
+
+// strictError returns an UnsupportedFieldsError for the unsupported warnings
+// collected while converting np in strict mode, or nil
+func (o ConversionOptions) strictError(np *networkingv1.NetworkPolicy) error {
+	if !o.Strict || o.warnings == nil {
+		return nil
+	}
+	var unsupported []Warning
+	for _, warning := range o.warnings.list {
+		if warning.Reason.Unsupported() {
+			unsupported = append(unsupported, warning)
+		}
+	}
+	if len(unsupported) == 0 {
+		return nil
+	}
+	return &UnsupportedFieldsError{Policy: np.Namespace + "/" + np.Name, Warnings: unsupported}
+}
+
+// Conversion is the outcome of converting a NetworkPolicy
+type Conversion struct {
+	// Rules are the generated ACL rules
+	Rules []hcnpkg.ACLRule
+
+	// Warnings list what the rules do not enforce exactly as written
+	Warnings []Warning
+}
+
+// warnings collects the warnings of one conversion; it is shared by the
+// copies of ConversionOptions passed down the converter
+type warnings struct {
+	list []Warning
+}
+
+// warn records a conversion warning; conversions without a collector drop it.
+// A peer or port repeated across rules is only reported once.
+func (o ConversionOptions) warn(reason WarningReason, format string, args ...any) {
+	if o.warnings == nil {
+		return
+	}
+	warning := Warning{Reason: reason, Message: fmt.Sprintf(format, args...)}
+	for _, existing := range o.warnings.list {
+		if existing == warning {
+			return
+		}
+	}
+	o.warnings.list = append(o.warnings.list, warning)
+}
+
+// ConvertNetworkPolicy converts a NetworkPolicy like NetworkPolicyToACLRules
+// and additionally reports the warnings raised along the way
+func ConvertNetworkPolicy(np *networkingv1.NetworkPolicy, opts ConversionOptions) (Conversion, error) {
+	opts.warnings = &warnings{}
+	rules, err := NetworkPolicyToACLRules(np, opts)
+	if err != nil {
+		return Conversion{}, err
+	}
+	return Conversion{Rules: rules, Warnings: opts.warnings.list}, nil
+}
+
+// guardPriorities moves rules whose priority HNS rejects (0), uses for its own
+// default rules (above hcn.MaxPriority) or that lies in a reserved range into
+// the managed band, so they do not fail opaquely at apply time. Such rules can
+// only come from conversion hooks; the pool never hands out these values.
+func guardPriorities(rules []hcnpkg.ACLRule, priorities *hcnpkg.PriorityPool, opts ConversionOptions) {
+	for i := range rules {
+		priority := rules[i].Priority
+		var reason string
+		switch {
+		case priority < hcnpkg.MinPriority:
+			reason = "rejected by HNS"
+		case priority > hcnpkg.MaxPriority:
+			reason = "reserved for HNS default rules"
+		case inReservedRange(priority, opts.ReservedPriorities):
+			reason = "in a reserved range"
+		default:
+			continue
+		}
+		rules[i].Priority = priorities.Next()
+		opts.warn(WarningPriorityRemapped, "rule %q priority %d is %s, remapped to %d",
+			rules[i].Name, priority, reason, rules[i].Priority)
+	}
+}
+
+// inReservedRange reports whether priority lies in one of the reserved ranges
+func inReservedRange(priority uint16, reserved []hcnpkg.PriorityRange) bool {
+	for _, r := range reserved {
+		if r.Contains(priority) {
+			return true
+		}
+	}
+	return false
+}
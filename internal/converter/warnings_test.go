@@ -0,0 +1,172 @@
+//go:build windows
+
+package converter
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// fixedPriorityHook is a post-conversion hook adding rules with hard-coded priorities
+type fixedPriorityHook struct {
+	priorities []uint16
+}
+
+func (h *fixedPriorityHook) Name() string { return "fixed" }
+
+func (h *fixedPriorityHook) PostConvert(np *networkingv1.NetworkPolicy, rules []hcnpkg.ACLRule, priorities *hcnpkg.PriorityPool) ([]hcnpkg.ACLRule, error) {
+	for _, priority := range h.priorities {
+		rules = append(rules, hcnpkg.ACLRule{
+			Name:      "fixed",
+			Action:    hcnlib.ActionTypeAllow,
+			Direction: hcnlib.DirectionTypeIn,
+			Priority:  priority,
+		})
+	}
+	return rules, nil
+}
+
+func TestConvertNetworkPolicy_RemapsInvalidPriorities(t *testing.T) {
+	opts := DefaultConversionOptions()
+	opts.ReservedPriorities = []hcnpkg.PriorityRange{{Start: 5000, End: 5100}}
+	opts.PostHooks = []PostConversionHook{&fixedPriorityHook{priorities: []uint16{0, 65500, 5050, 2000}}}
+
+	conversion, err := ConvertNetworkPolicy(hookTestPolicy(), opts)
+	if err != nil {
+		t.Fatalf("ConvertNetworkPolicy failed: %v", err)
+	}
+	if len(conversion.Warnings) != 3 {
+		t.Fatalf("Expected 3 warnings, got %v", conversion.Warnings)
+	}
+	for _, warning := range conversion.Warnings {
+		if warning.Reason != WarningPriorityRemapped {
+			t.Errorf("Expected reason %s, got %s", WarningPriorityRemapped, warning.Reason)
+		}
+	}
+
+	seen := make(map[uint16]bool)
+	for _, rule := range conversion.Rules {
+		if rule.Priority < opts.BasePriority || rule.Priority > opts.MaxPriority || inReservedRange(rule.Priority, opts.ReservedPriorities) {
+			t.Errorf("Rule %s has priority %d outside the managed band", rule.Name, rule.Priority)
+		}
+		if seen[rule.Priority] && rule.Priority != 2000 {
+			t.Errorf("Rule %s reuses priority %d", rule.Name, rule.Priority)
+		}
+		seen[rule.Priority] = true
+	}
+	if !seen[2000] {
+		t.Error("Expected a valid hook priority to be kept")
+	}
+}
+
+func TestNetworkPolicyToACLRules_DropsWarningsWithoutCollector(t *testing.T) {
+	opts := DefaultConversionOptions()
+	opts.PostHooks = []PostConversionHook{&fixedPriorityHook{priorities: []uint16{0}}}
+
+	rules, err := NetworkPolicyToACLRules(hookTestPolicy(), opts)
+	if err != nil {
+		t.Fatalf("NetworkPolicyToACLRules failed: %v", err)
+	}
+	for _, rule := range rules {
+		if rule.Priority == 0 {
+			t.Errorf("Expected priority 0 remapped, got rule %s", rule.Name)
+		}
+	}
+}
+
+func TestConvertNetworkPolicy_PartialEnforcementWarnings(t *testing.T) {
+	namedPort := intstr.FromString("metrics")
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "partial", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Egress: []networkingv1.NetworkPolicyEgressRule{{
+				Ports: []networkingv1.NetworkPolicyPort{{Port: &namedPort}, {Port: &namedPort}},
+				To: []networkingv1.NetworkPolicyPeer{
+					{PodSelector: &metav1.LabelSelector{}},
+					{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8", Except: []string{"10.1.0.0/16"}}},
+				},
+			}},
+		},
+	}
+
+	conversion, err := ConvertNetworkPolicy(np, DefaultConversionOptions())
+	if err != nil {
+		t.Fatalf("ConvertNetworkPolicy failed: %v", err)
+	}
+
+	// Peers and ports repeated across rules are reported once
+	reasons := make(map[WarningReason]int)
+	for _, warning := range conversion.Warnings {
+		reasons[warning.Reason]++
+	}
+	expected := map[WarningReason]int{
+		WarningNamedPortDropped:    1,
+		WarningSelectorUnsupported: 1,
+		WarningExceptIgnored:       1,
+	}
+	if len(reasons) != len(expected) {
+		t.Fatalf("Expected warnings %v, got %v", expected, conversion.Warnings)
+	}
+	for reason, count := range expected {
+		if reasons[reason] != count {
+			t.Errorf("Expected %d %s warnings, got %d", count, reason, reasons[reason])
+		}
+	}
+}
+
+func TestConvertNetworkPolicy_StrictRejectsUnsupportedFields(t *testing.T) {
+	namedPort := intstr.FromString("metrics")
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "partial", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				Ports: []networkingv1.NetworkPolicyPort{{Port: &namedPort}},
+				From: []networkingv1.NetworkPolicyPeer{
+					{PodSelector: &metav1.LabelSelector{}},
+					{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8", Except: []string{"10.1.0.0/16"}}},
+				},
+			}},
+		},
+	}
+	opts := DefaultConversionOptions()
+	opts.Strict = true
+
+	rules, err := NetworkPolicyToACLRules(np, opts)
+	if !errors.Is(err, ErrUnsupportedField) {
+		t.Fatalf("Expected ErrUnsupportedField, got %v", err)
+	}
+	if rules != nil {
+		t.Errorf("Expected no rules for a rejected policy, got %d", len(rules))
+	}
+	var rejection *UnsupportedFieldsError
+	if !errors.As(err, &rejection) {
+		t.Fatalf("Expected an UnsupportedFieldsError, got %T", err)
+	}
+	expected := []WarningReason{WarningNamedPortDropped, WarningExceptIgnored, WarningSelectorUnsupported}
+	if reasons := rejection.Reasons(); len(reasons) != len(expected) {
+		t.Fatalf("Expected reasons %v, got %v", expected, reasons)
+	}
+	for _, reason := range expected {
+		if !slices.Contains(rejection.Reasons(), reason) {
+			t.Errorf("Expected reason %s in %v", reason, rejection.Reasons())
+		}
+	}
+
+	// Warnings about enforced rules do not reject the policy
+	opts.ReservedPriorities = []hcnpkg.PriorityRange{{Start: 5000, End: 5100}}
+	opts.PostHooks = []PostConversionHook{&fixedPriorityHook{priorities: []uint16{5050}}}
+	conversion, err := ConvertNetworkPolicy(hookTestPolicy(), opts)
+	if err != nil {
+		t.Fatalf("Expected a remapped priority to be accepted, got %v", err)
+	}
+	if len(conversion.Warnings) != 1 || conversion.Warnings[0].Reason != WarningPriorityRemapped {
+		t.Errorf("Expected one %s warning, got %v", WarningPriorityRemapped, conversion.Warnings)
+	}
+}
@@ -0,0 +1,206 @@
+//go:build windows
+
+// Package dryrun validates a directory of policy manifests offline: every
+// policy is converted and programmed onto a fake HCN, without an API server
+// or HNS, so GitOps pipelines can reject policies the agent would fail on
+package dryrun
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	v1alpha1 "github.com/knabben/firewall-controller/api/v1alpha1"
+	"github.com/knabben/firewall-controller/internal/converter"
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+	"github.com/knabben/firewall-controller/internal/peers"
+)
+
+// Options configures a dry run
+type Options struct {
+	// Conversion are the options the agent converts NetworkPolicies with
+	Conversion converter.ConversionOptions
+
+	// Endpoints is the number of fake HCN endpoints rules are programmed on
+	Endpoints int
+
+	// PeerResolver resolves selector peers offline; nil leaves them unresolved
+	PeerResolver *peers.FileResolver
+}
+
+// Result is the outcome for a single manifest document
+type Result struct {
+	File      string
+	Kind      string
+	Namespace string
+	Name      string
+	RuleCount int
+	Err       error
+
+	// Warnings list what the policy's rules do not enforce exactly as written
+	Warnings []converter.Warning
+}
+
+// Report summarizes a dry run
+type Report struct {
+	Results []Result
+
+	// Skipped counts documents of kinds the agent does not consume
+	Skipped int
+}
+
+// Failed returns the results that failed
+func (r Report) Failed() []Result {
+	var failed []Result
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Run validates every .yaml, .yml and .json manifest under dir.
+// Policy failures are reported in the Report; the error is only set when the
+// directory cannot be read.
+func Run(dir string, opts Options, scheme *runtime.Scheme, logger logr.Logger) (Report, error) {
+	files, err := manifestFiles(dir)
+	if err != nil {
+		return Report{}, err
+	}
+
+	if opts.Endpoints < 1 {
+		opts.Endpoints = 1
+	}
+	manager := hcnpkg.NewManager(hcnpkg.NewFakeClient(opts.Endpoints), logger)
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+
+	var report Report
+	for _, file := range files {
+		docs, err := readDocuments(file)
+		if err != nil {
+			report.Results = append(report.Results, Result{File: file, Err: err})
+			continue
+		}
+		for _, doc := range docs {
+			obj, gvk, err := decoder.Decode(doc, nil, nil)
+			if err != nil {
+				// Comment-only documents and kinds unknown to the agent are not policies
+				if runtime.IsNotRegisteredError(err) || runtime.IsMissingKind(err) {
+					report.Skipped++
+					continue
+				}
+				report.Results = append(report.Results, Result{File: file, Err: fmt.Errorf("invalid manifest: %w", err)})
+				continue
+			}
+
+			result := Result{File: file, Kind: gvk.Kind}
+			switch policy := obj.(type) {
+			case *networkingv1.NetworkPolicy:
+				defaultNamespace(&policy.ObjectMeta.Namespace)
+				result.Namespace, result.Name = policy.Namespace, policy.Name
+				result.RuleCount, result.Warnings, result.Err = applyNetworkPolicy(manager, policy, opts)
+			case *v1alpha1.NamespaceDefaultPolicy:
+				defaultNamespace(&policy.ObjectMeta.Namespace)
+				result.Namespace, result.Name = policy.Namespace, policy.Name
+				rules := converter.NamespaceDefaultPolicyToACLRules(policy, nil, opts.Conversion.AutoAllowDNS)
+				result.RuleCount = len(rules)
+				result.Err = manager.ApplyACLRules(hcnpkg.NewPolicyKey(hcnpkg.SourceNamespaceDefault, policy.Namespace+"/"+policy.Name), rules)
+			default:
+				report.Skipped++
+				continue
+			}
+			report.Results = append(report.Results, result)
+		}
+	}
+
+	return report, nil
+}
+
+// applyNetworkPolicy converts a NetworkPolicy as the controller would and programs it
+func applyNetworkPolicy(manager *hcnpkg.Manager, np *networkingv1.NetworkPolicy, opts Options) (int, []converter.Warning, error) {
+	if _, _, err := converter.SameNamespaceDirections(np); err != nil {
+		return 0, nil, err
+	}
+	conversionOpts := opts.Conversion
+	if opts.PeerResolver != nil && peers.HasSelectorPeers(np) {
+		var err error
+		conversionOpts.PeerAddresses, err = peers.Resolve(context.Background(), opts.PeerResolver, nil, np)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+	conversion, err := converter.ConvertNetworkPolicy(np, conversionOpts)
+	if err != nil {
+		return 0, nil, err
+	}
+	err = manager.ApplyACLRules(hcnpkg.NewPolicyKey(hcnpkg.SourceNetworkPolicy, np.Namespace+"/"+np.Name), conversion.Rules)
+	return len(conversion.Rules), conversion.Warnings, err
+}
+
+// defaultNamespace fills in the namespace kubectl would apply a manifest to
+func defaultNamespace(namespace *string) {
+	if *namespace == "" {
+		*namespace = "default"
+	}
+}
+
+// manifestFiles lists the manifest files under dir in a stable order
+func manifestFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest directory %s: %w", dir, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// readDocuments splits a (multi-document) YAML or JSON file into documents
+func readDocuments(path string) ([][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs [][]byte
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to split YAML documents: %w", err)
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		docs = append(docs, doc)
+	}
+}
@@ -0,0 +1,116 @@
+//go:build windows
+
+package dryrun
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+
+	v1alpha1 "github.com/knabben/firewall-controller/api/v1alpha1"
+	"github.com/knabben/firewall-controller/internal/converter"
+)
+
+const validManifests = `# Allowed web traffic
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: allow-web
+spec:
+  podSelector: {}
+  ingress:
+  - ports:
+    - port: 80
+      protocol: TCP
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: unrelated
+---
+apiVersion: networking.knabben.github.io/v1alpha1
+kind: NamespaceDefaultPolicy
+metadata:
+  name: default
+  namespace: team-a
+spec:
+  ingress:
+    denyOther: true
+`
+
+const invalidManifest = `apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: named-port
+  namespace: team-a
+spec:
+  podSelector: {}
+  ingress:
+  - ports:
+    - port: http
+`
+
+func testScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to build scheme: %v", err)
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to build scheme: %v", err)
+	}
+	return scheme
+}
+
+func writeManifest(t *testing.T, dir, name, content string) {
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeManifest(t, dir, "valid.yaml", validManifests)
+	writeManifest(t, dir, "README.md", "not a manifest")
+
+	report, err := Run(dir, Options{Conversion: converter.DefaultConversionOptions(), Endpoints: 3}, testScheme(t), logr.Discard())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(report.Results) != 2 || report.Skipped != 1 {
+		t.Fatalf("Expected 2 policies and 1 skipped document, got %d and %d", len(report.Results), report.Skipped)
+	}
+	if failed := report.Failed(); len(failed) != 0 {
+		t.Errorf("Expected no failures, got %v", failed[0].Err)
+	}
+	if np := report.Results[0]; np.Namespace != "default" || np.Name != "allow-web" || np.RuleCount == 0 {
+		t.Errorf("Unexpected NetworkPolicy result %+v", np)
+	}
+}
+
+func TestRun_ReportsConversionFailures(t *testing.T) {
+	dir := t.TempDir()
+	writeManifest(t, dir, "invalid.yaml", invalidManifest)
+	writeManifest(t, dir, "broken.yaml", "apiVersion: networking.k8s.io/v1\nkind: NetworkPolicy\nspec: [")
+
+	opts := converter.DefaultConversionOptions()
+	opts.RejectNamedPorts = true
+
+	report, err := Run(dir, Options{Conversion: opts}, testScheme(t), logr.Discard())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if failed := report.Failed(); len(failed) != 2 {
+		t.Errorf("Expected the named port and the malformed manifest to fail, got %d failures", len(failed))
+	}
+}
+
+func TestRun_MissingDirectory(t *testing.T) {
+	if _, err := Run(filepath.Join(t.TempDir(), "missing"), Options{}, testScheme(t), logr.Discard()); err == nil {
+		t.Error("Expected error for a missing directory")
+	}
+}
@@ -0,0 +1,88 @@
+//go:build windows
+
+// Package events is the in-process bus the agent's subsystems exchange typed
+// events over, so metrics, status writers, audit and webhooks subscribe to
+// what the HCN Manager and the controllers do instead of being called by them
+package events
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// EndpointAdded is published when an endpoint listing finds an endpoint the
+// previous listing did not have; the first listing reports every endpoint
+type EndpointAdded struct {
+	EndpointID string
+	Name       string
+
+	// IPs are the addresses of the endpoint's IP configurations
+	IPs []string
+}
+
+// PolicyApplied is published when the rules of a policy have been programmed
+// on every endpoint they target
+type PolicyApplied struct {
+	PolicyKey string
+
+	// RuleCount is the number of ACL rules desired for the policy
+	RuleCount int
+
+	// EndpointCount is the number of endpoints carrying the policy's rules
+	EndpointCount int
+}
+
+// DriftDetected is published when a drift check finds tracked rules of a
+// policy missing from an endpoint, before they are programmed again
+type DriftDetected struct {
+	PolicyKey  string
+	EndpointID string
+
+	// MissingCount is the number of tracked HCN policies gone from the endpoint
+	MissingCount int
+}
+
+// HNSRestarted is published when HNS answers again after failing as
+// stopped or unavailable, which it does while the hns service restarts
+type HNSRestarted struct {
+	// Downtime is how long HNS was seen unavailable
+	Downtime time.Duration
+}
+
+// Bus delivers each published event to the handlers subscribed to its type.
+// A nil Bus drops every event, so publishers need not check for one.
+type Bus struct {
+	mu sync.RWMutex
+
+	// handlers maps an event type to its func(E) handlers, in subscription order
+	handlers map[reflect.Type][]any
+}
+
+// NewBus creates a bus without subscribers
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[reflect.Type][]any)}
+}
+
+// Subscribe registers handler for the events of type E. Handlers run on the
+// publisher's goroutine, one after the other, and must not block it: work
+// that may take long belongs in a goroutine of its own.
+func Subscribe[E any](bus *Bus, handler func(E)) {
+	eventType := reflect.TypeFor[E]()
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	bus.handlers[eventType] = append(bus.handlers[eventType], handler)
+}
+
+// Publish delivers event to every handler subscribed to its type
+func Publish[E any](bus *Bus, event E) {
+	if bus == nil {
+		return
+	}
+	bus.mu.RLock()
+	handlers := bus.handlers[reflect.TypeFor[E]()]
+	bus.mu.RUnlock()
+	for _, handler := range handlers {
+		handler.(func(E))(event)
+	}
+}
@@ -0,0 +1,40 @@
+//go:build windows
+
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBus_DeliversByType(t *testing.T) {
+	bus := NewBus()
+	var applied []string
+	var restarts int
+	Subscribe(bus, func(event PolicyApplied) {
+		applied = append(applied, "first:"+event.PolicyKey)
+	})
+	Subscribe(bus, func(event PolicyApplied) {
+		applied = append(applied, "second:"+event.PolicyKey)
+	})
+	Subscribe(bus, func(HNSRestarted) {
+		restarts++
+	})
+
+	Publish(bus, PolicyApplied{PolicyKey: "netpol/default/web"})
+	Publish(bus, HNSRestarted{Downtime: time.Second})
+	Publish(bus, DriftDetected{PolicyKey: "netpol/default/web"})
+
+	if len(applied) != 2 || applied[0] != "first:netpol/default/web" || applied[1] != "second:netpol/default/web" {
+		t.Errorf("Expected both PolicyApplied handlers called in order, got %v", applied)
+	}
+	if restarts != 1 {
+		t.Errorf("Expected 1 HNSRestarted delivered, got %d", restarts)
+	}
+}
+
+func TestBus_NilDropsEvents(t *testing.T) {
+	var bus *Bus
+	// Must not panic
+	Publish(bus, EndpointAdded{EndpointID: "ep-1"})
+}
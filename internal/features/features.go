@@ -0,0 +1,149 @@
+//go:build windows
+
+// Package features implements feature gates, so experimental subsystems can
+// ship disabled and be enabled per cluster with --feature-gates instead of
+// separate builds
+package features
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Feature names a gated subsystem
+type Feature string
+
+// Stage is the maturity of a feature
+type Stage string
+
+const (
+	// Alpha features are off by default and may change or be removed
+	Alpha Stage = "ALPHA"
+
+	// Beta features are on by default and can still be turned off
+	Beta Stage = "BETA"
+
+	// GA features are always on; their gates are kept for compatibility only
+	GA Stage = "GA"
+)
+
+// FeatureSpec describes a feature's default and maturity
+type FeatureSpec struct {
+	Default bool
+	Stage   Stage
+}
+
+const (
+	// PolicySources enables enforcing NetworkPolicies of additional clusters
+	// (--policy-sources)
+	PolicySources Feature = "PolicySources"
+)
+
+// defaultFeatures are the features known to the agent
+var defaultFeatures = map[Feature]FeatureSpec{
+	PolicySources: {Default: true, Stage: Beta},
+}
+
+// Gate holds the enabled state of a set of features. It implements flag.Value.
+type Gate struct {
+	mu      sync.RWMutex
+	known   map[Feature]FeatureSpec
+	enabled map[Feature]bool
+}
+
+// NewGate creates a gate for the given features, all at their defaults
+func NewGate(known map[Feature]FeatureSpec) *Gate {
+	return &Gate{known: known, enabled: make(map[Feature]bool)}
+}
+
+// DefaultGate is the gate of the agent's features, set by --feature-gates
+var DefaultGate = NewGate(defaultFeatures)
+
+// Enabled reports whether feature is enabled on the default gate
+func Enabled(feature Feature) bool {
+	return DefaultGate.Enabled(feature)
+}
+
+// Enabled reports whether feature is enabled. Unknown features are disabled.
+func (g *Gate) Enabled(feature Feature) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if enabled, set := g.enabled[feature]; set {
+		return enabled
+	}
+	return g.known[feature].Default
+}
+
+// Set parses a comma-separated list of Feature=bool pairs, e.g.
+// "PolicySources=false". Unknown features, and disabling GA features, are errors.
+func (g *Gate) Set(value string) error {
+	parsed := make(map[Feature]bool)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, rawValue, found := strings.Cut(pair, "=")
+		if !found {
+			return fmt.Errorf("invalid feature gate %q: expected Feature=true|false", pair)
+		}
+		feature := Feature(strings.TrimSpace(name))
+		spec, known := g.known[feature]
+		if !known {
+			return fmt.Errorf("unknown feature gate %q", feature)
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(rawValue))
+		if err != nil {
+			return fmt.Errorf("invalid value %q for feature gate %s: %w", rawValue, feature, err)
+		}
+		if spec.Stage == GA && !enabled {
+			return fmt.Errorf("feature gate %s is GA and cannot be disabled", feature)
+		}
+		parsed[feature] = enabled
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for feature, enabled := range parsed {
+		g.enabled[feature] = enabled
+	}
+	return nil
+}
+
+// String returns the explicitly set features in Set's format
+func (g *Gate) String() string {
+	if g == nil {
+		return ""
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	pairs := make([]string, 0, len(g.enabled))
+	for feature, enabled := range g.enabled {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", feature, enabled))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// State returns the enabled state of every known feature, for logging
+func (g *Gate) State() map[Feature]bool {
+	state := make(map[Feature]bool, len(g.known))
+	for feature := range g.known {
+		state[feature] = g.Enabled(feature)
+	}
+	return state
+}
+
+// KnownFeatures describes every known feature for flag help, sorted by name
+func (g *Gate) KnownFeatures() []string {
+	descriptions := make([]string, 0, len(g.known))
+	for feature, spec := range g.known {
+		descriptions = append(descriptions, fmt.Sprintf("%s=true|false (%s - default=%t)", feature, spec.Stage, spec.Default))
+	}
+	sort.Strings(descriptions)
+	return descriptions
+}
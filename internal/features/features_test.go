@@ -0,0 +1,62 @@
+//go:build windows
+
+package features
+
+import (
+	"testing"
+)
+
+const (
+	alphaFeature Feature = "AlphaFeature"
+	betaFeature  Feature = "BetaFeature"
+	gaFeature    Feature = "GAFeature"
+)
+
+func testGate() *Gate {
+	return NewGate(map[Feature]FeatureSpec{
+		alphaFeature: {Default: false, Stage: Alpha},
+		betaFeature:  {Default: true, Stage: Beta},
+		gaFeature:    {Default: true, Stage: GA},
+	})
+}
+
+func TestGate_Defaults(t *testing.T) {
+	gate := testGate()
+	if gate.Enabled(alphaFeature) || !gate.Enabled(betaFeature) || !gate.Enabled(gaFeature) {
+		t.Errorf("Expected defaults, got %v", gate.State())
+	}
+	if gate.Enabled("Unknown") {
+		t.Error("Expected unknown features to be disabled")
+	}
+}
+
+func TestGate_Set(t *testing.T) {
+	gate := testGate()
+	if err := gate.Set("AlphaFeature=true, BetaFeature=false"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if !gate.Enabled(alphaFeature) || gate.Enabled(betaFeature) {
+		t.Errorf("Expected AlphaFeature on and BetaFeature off, got %v", gate.State())
+	}
+	if got := gate.String(); got != "AlphaFeature=true,BetaFeature=false" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestGate_SetInvalid(t *testing.T) {
+	for _, value := range []string{
+		"Unknown=true",
+		"AlphaFeature",
+		"AlphaFeature=maybe",
+		"GAFeature=false",
+		"AlphaFeature=true,Unknown=true",
+	} {
+		gate := testGate()
+		if err := gate.Set(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+		if gate.Enabled(alphaFeature) {
+			t.Errorf("Expected a rejected %q not to change the gate", value)
+		}
+	}
+}
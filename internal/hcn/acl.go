@@ -3,97 +3,413 @@
 package hcn
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/Microsoft/hcsshim/hcn"
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/knabben/firewall-controller/internal/events"
 )
 
-// Manager handles ACL rule application and tracking for HCN endpoints
+// Manager handles ACL rule application and tracking for HCN endpoints.
+// Callers declare the rules they want via the desired state cache; the Manager
+// then reconciles the live endpoint state toward it, adding and removing only
+// the HCN policies that differ from what it has already programmed.
 type Manager struct {
 	client HCNClient
 	logger logr.Logger
 
-	// mu protects the appliedPolicies map
+	// desired is the NetworkPolicy rule store fed by ApplyACLRules/RemoveACLRules
+	desired *DesiredState
+
+	// providers are all rule sources merged into each endpoint's desired ACL table
+	providers []RuleProvider
+
+	// payloads caches marshalled ACL settings per rule
+	payloads *payloadCache
+
+	// index is refreshed from every endpoint listing for O(1) IP/MAC lookups
+	index *EndpointIndex
+
+	// mu protects the appliedPolicies, networkDesired, networkApplied,
+	// syncErrors, pinned and remaining maps, the rule history, the requeue
+	// channel and stateless
 	mu sync.RWMutex
 
 	// appliedPolicies tracks which policies have been applied to which endpoints
 	// Map: policyKey (namespace/name) -> list of RuleSets
 	appliedPolicies map[string][]RuleSet
+
+	// networkDesired holds the rules declared through ApplyNetworkACLRules,
+	// programmed on networks rather than endpoints
+	networkDesired map[string][]ACLRule
+
+	// networkApplied tracks which policies have been applied to which networks
+	networkApplied map[string][]NetworkRuleSet
+
+	// syncErrors holds the error of the last failed sync per policy key
+	syncErrors map[string]string
+
+	// pinned are endpoints restored from a backup, skipped by policy syncs
+	pinned map[string]bool
+
+	// backups, when set, receives each endpoint's ACLs before destructive changes
+	backups *BackupStore
+
+	// priorities, when set, persists the tracked rules across restarts
+	priorities *PriorityStore
+
+	// hnsErrors counts failed HNS calls by operation and error code
+	hnsErrors *prometheus.CounterVec
+
+	// applies counts ApplyACLRules calls and their outcome for ApplyStats
+	applies applyCounters
+
+	// applyDuration observes the latency of ApplyACLRules
+	applyDuration prometheus.Histogram
+
+	// concurrency bounds the parallel HCN work of each sync
+	concurrency ConcurrencyOptions
+
+	// remaining holds, per policy key, the endpoints an interrupted sync did not reach
+	remaining map[string]map[string]bool
+
+	// history, when set, keeps recent snapshots of each endpoint's rule table
+	history *ruleHistory
+
+	// requeues, when set, receives the policies the controller should reconcile again
+	requeues chan RequeueRequest
+
+	// backoff suspends endpoints that keep rejecting rules from policy syncs
+	backoff *endpointBackoff
+
+	// churn counts rule changes per policy and endpoint
+	churn *churnMetrics
+
+	// remoteSubnets caches the overlay routes Block rules are checked against
+	remoteSubnets remoteSubnets
+
+	// foreign holds the priorities taken by out-of-band ACLs on each endpoint
+	foreign foreignACLs
+
+	// paused holds the pod addresses whose endpoints carry no rules
+	paused podAddresses
+
+	// exempt holds the pod addresses whose endpoints are exempt from every rule
+	exempt podAddresses
+
+	// stateless is set by WarmStartFromEndpoints; policies then claim the
+	// ACLs tracked under UnclaimedPolicyKey
+	stateless bool
+
+	// bus, when set, receives the events of the Manager's subsystems
+	bus *events.Bus
+
+	// migrated serves the ACLs adopted from other agents by Migrate
+	migrated *TargetedProvider
+
+	// outage tracks HNS unavailability for HNSRestarted events
+	outage hnsOutage
+
+	// isolation tells Hyper-V isolated endpoints from process-isolated ones
+	isolation isolationModes
+
+	// dryRun, when set, simulates the HNS writes of m.client
+	dryRun *dryRunClient
+
+	// dryRunChanges counts the policies dry run kept from being added or removed
+	dryRunChanges *prometheus.CounterVec
+
+	// policyLocks serializes the syncs and removals of each policy key
+	policyLocks policyLocks
 }
 
 // NewManager creates a new ACL manager
 func NewManager(client HCNClient, logger logr.Logger) *Manager {
+	desired := NewDesiredState()
+	migrated := NewTargetedProvider("migrated")
+	namespaces, _ := client.(NamespaceClient)
 	return &Manager{
 		client:          client,
 		logger:          logger,
+		desired:         desired,
+		providers:       []RuleProvider{desired, migrated},
+		migrated:        migrated,
+		index:           NewEndpointIndex(),
+		payloads:        newPayloadCache(),
 		appliedPolicies: make(map[string][]RuleSet),
+		networkDesired:  make(map[string][]ACLRule),
+		networkApplied:  make(map[string][]NetworkRuleSet),
+		syncErrors:      make(map[string]string),
+		pinned:          make(map[string]bool),
+		concurrency:     DefaultConcurrencyOptions(),
+		remaining:       make(map[string]map[string]bool),
+		backoff:         newEndpointBackoff(DefaultEndpointBackoffOptions()),
+		churn:           newChurnMetrics(),
+		remoteSubnets:   remoteSubnets{mode: RemoteSubnetIgnore},
+		foreign:         foreignACLs{mode: PriorityCollisionRemap},
+		paused:          podAddresses{pods: make(map[string]string)},
+		exempt:          podAddresses{pods: make(map[string]string)},
+		isolation:       isolationModes{mode: HyperVIgnore, namespaces: namespaces},
+		hnsErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "networkpolicy_agent",
+			Subsystem: "hcn",
+			Name:      "errors_total",
+			Help:      "Number of failed HNS calls by operation and HNS error code.",
+		}, []string{"operation", "code"}),
+		dryRunChanges: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "networkpolicy_agent",
+			Subsystem: "hcn",
+			Name:      "dry_run_changes_total",
+			Help:      "Number of HNS policies dry run kept from being added or removed, by operation.",
+		}, []string{"operation"}),
+		applyDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "networkpolicy_agent",
+			Subsystem: "hcn",
+			Name:      "apply_duration_seconds",
+			Help:      "Latency of programming a policy's ACL rules on every endpoint.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+		}),
 	}
 }
 
-// ApplyACLRules applies the given ACL rules to all HCN endpoints
-// policyKey is typically "namespace/name" for tracking purposes
-func (m *Manager) ApplyACLRules(policyKey string, rules []ACLRule) error {
-	m.logger.Info("Applying ACL rules", "policyKey", policyKey, "ruleCount", len(rules))
+// recordHNSError classifies a failed HNS call and counts it
+func (m *Manager) recordHNSError(operation string, err error) *HNSError {
+	parsed := ParseHNSError(err)
+	m.hnsErrors.WithLabelValues(operation, parsed.Name).Inc()
+	return parsed
+}
+
+// RegisterProvider adds a rule source whose rules are merged into every
+// endpoint's desired ACL table on the next reconciliation.
+// Providers must be registered before the Manager starts reconciling.
+func (m *Manager) RegisterProvider(provider RuleProvider) {
+	m.logger.Info("Registering rule provider", "provider", provider.Name())
+	m.providers = append(m.providers, provider)
+}
 
-	// List all HCN endpoints
+// listEndpoints lists all HCN endpoints and refreshes the endpoint index
+func (m *Manager) listEndpoints() ([]hcn.HostComputeEndpoint, error) {
 	endpoints, err := m.client.ListEndpoints()
+	m.observeListing(endpoints, err)
 	if err != nil {
-		return fmt.Errorf("failed to list HCN endpoints: %w", err)
+		return nil, err
 	}
-
-	if len(endpoints) == 0 {
-		m.logger.Info("No HCN endpoints found, skipping rule application")
-		return nil
+	m.index.Update(endpoints)
+	m.refreshRemoteSubnets()
+	m.refreshIsolation(endpoints)
+	live := make(map[string]bool, len(endpoints))
+	for _, endpoint := range endpoints {
+		live[endpoint.Id] = true
 	}
+	m.backoff.prune(live)
+	m.churn.prune(live)
+	m.pruneStaleEndpoints(live)
+	return endpoints, nil
+}
+
+// EndpointByID returns the endpoint with the given ID as of the last endpoint listing
+func (m *Manager) EndpointByID(id string) (hcn.HostComputeEndpoint, bool) {
+	return m.index.ByID(id)
+}
+
+// EndpointByIP returns the endpoint owning ip as of the last endpoint listing
+func (m *Manager) EndpointByIP(ip string) (hcn.HostComputeEndpoint, bool) {
+	return m.index.ByIP(ip)
+}
+
+// EndpointByMAC returns the endpoint with the given MAC as of the last endpoint listing
+func (m *Manager) EndpointByMAC(mac string) (hcn.HostComputeEndpoint, bool) {
+	return m.index.ByMAC(mac)
+}
 
-	// Convert ACL rules to HCN endpoint policies
-	policies, err := m.buildPolicies(rules)
+// ApplyACLRules records the given ACL rules as the desired state for policyKey
+// and reconciles all HCN endpoints toward it
+func (m *Manager) ApplyACLRules(policyKey PolicyKey, rules []ACLRule) error {
+	return m.ApplyACLRulesContext(context.Background(), policyKey, rules)
+}
+
+// RemoveACLRules removes the desired state for the given policy key and
+// removes previously applied ACL rules from the endpoints and networks
+func (m *Manager) RemoveACLRules(policyKey PolicyKey) error {
+	m.logger.Info("Removing ACL rules", "policyKey", policyKey)
+
+	key := string(policyKey)
+	m.desired.Delete(key)
+	return errors.Join(m.removeTracked(key), m.removeNetworkTracked(key))
+}
+
+// Reconcile performs a full reconciliation pass: it lists all endpoints once and
+// converges every endpoint's controller-owned ACL table toward the rules desired
+// by all registered providers. Policies that are tracked but no longer desired
+// anywhere are removed.
+func (m *Manager) Reconcile() error {
+	endpoints, err := m.listEndpoints()
 	if err != nil {
-		return fmt.Errorf("failed to build HCN policies: %w", err)
+		return fmt.Errorf("failed to list HCN endpoints: %w", err)
 	}
 
-	// Track successful applications
-	var ruleSets []RuleSet
-	var applyErrors []error
-
-	// Apply policies to each endpoint
+	// Collect every policy key any provider wants on any endpoint
+	desiredSet := make(map[string]bool)
 	for _, endpoint := range endpoints {
-		m.logger.V(1).Info("Applying policies to endpoint",
-			"endpointID", endpoint.Id,
-			"endpointName", endpoint.Name)
-
-		request := hcn.PolicyEndpointRequest{
-			Policies: policies,
+		for key := range m.desiredRulesFor(endpoint) {
+			desiredSet[key] = true
 		}
+	}
+	// NetworkPolicy keys stay tracked even when there are no endpoints yet
+	for _, key := range m.desired.Keys() {
+		desiredSet[key] = true
+	}
+	desiredKeys := make([]string, 0, len(desiredSet))
+	for key := range desiredSet {
+		desiredKeys = append(desiredKeys, key)
+	}
+	sort.Strings(desiredKeys)
 
-		err := m.client.ApplyEndpointPolicy(&endpoint, hcn.RequestTypeAdd, request)
-		if err != nil {
-			m.logger.Error(err, "Failed to apply policy to endpoint",
-				"endpointID", endpoint.Id,
-				"endpointName", endpoint.Name)
-			applyErrors = append(applyErrors, fmt.Errorf("endpoint %s: %w", endpoint.Id, err))
+	var syncErrors []error
+
+	// Remove policies that were applied but are no longer desired
+	for _, key := range m.trackedKeys() {
+		if desiredSet[key] {
 			continue
 		}
+		if err := m.removeUndesired(key); err != nil {
+			syncErrors = append(syncErrors, fmt.Errorf("policy %s: %w", key, err))
+		}
+	}
+
+	// Converge every desired policy on every endpoint
+	for _, key := range desiredKeys {
+		if err := m.syncPolicy(context.Background(), key, endpoints); err != nil {
+			syncErrors = append(syncErrors, fmt.Errorf("policy %s: %w", key, err))
+			m.requestRequeue(key, RequeueSyncFailed)
+		}
+	}
+	syncErrors = append(syncErrors, m.reconcileNetworks(context.Background())...)
 
+	if len(syncErrors) > 0 {
+		return fmt.Errorf("failed to reconcile %d/%d policies: %w",
+			len(syncErrors), len(desiredKeys)+len(m.networkKeys()), errors.Join(syncErrors...))
+	}
+
+	m.logger.V(1).Info("Reconciled desired state",
+		"policyCount", len(desiredKeys),
+		"endpointCount", len(endpoints))
+	return nil
+}
+
+// Run periodically reconciles the desired state until the context is cancelled.
+// It implements the controller-runtime Runnable contract when wrapped in a RunnableFunc.
+func (m *Manager) Run(ctx context.Context, period time.Duration) error {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := m.Reconcile(); err != nil {
+				m.logger.Error(err, "Periodic reconciliation failed")
+			}
+		}
+	}
+}
+
+// syncPolicy converges the given endpoints toward the rules desired for policyKey.
+// Endpoints not started before ctx is done keep their tracked state and are
+// visited first by the next sync. Tracked endpoints missing from endpoints,
+// programmed by a sync listing them later, stay tracked. Syncs and removals
+// of the same key run one at a time.
+func (m *Manager) syncPolicy(ctx context.Context, policyKey string, endpoints []hcn.HostComputeEndpoint) error {
+	defer m.policyLocks.lock(policyKey)()
+
+	// Index what we have already programmed per endpoint
+	previous, _ := m.trackedRuleSets(policyKey)
+	current := make(map[string]RuleSet, len(previous))
+	for _, ruleSet := range previous {
+		current[ruleSet.EndpointID] = ruleSet
+	}
+
+	// Endpoints with identical desired rules share one immutable policy slice
+	var built sharedPolicies
+
+	// Reconcile each endpoint toward the desired policies; results are kept in
+	// endpoint order so tracking does not depend on which worker finished first
+	results := make([]endpointSync, len(endpoints))
+	for i := range results {
+		results[i].skipped = true
+	}
+	order := m.resumeOrder(policyKey, endpoints)
+	m.forEachEndpoint(ctx, len(order), func(k int) {
+		i := order[k]
+		results[i] = m.syncEndpoint(policyKey, &endpoints[i], current[endpoints[i].Id], &built)
+	})
+
+	// Track successful applications
+	ruleSets := []RuleSet{}
+	var applyErrors []error
+	remaining := make(map[string]bool)
+	for i, result := range results {
+		if result.skipped {
+			// Interrupted before this endpoint; what was programmed is still there
+			remaining[endpoints[i].Id] = true
+			result.ruleSet = current[endpoints[i].Id]
+		}
+		if result.buildErr != nil {
+			return fmt.Errorf("failed to build HCN policies: %w", result.buildErr)
+		}
+		if result.applyErr != nil {
+			applyErrors = append(applyErrors, result.applyErr)
+		}
 		// Track the applied policies (we need to store them for removal)
-		ruleSets = append(ruleSets, RuleSet{
-			EndpointID: endpoint.Id,
-			Policies:   policies,
-		})
+		if len(result.ruleSet.Policies) > 0 {
+			ruleSets = append(ruleSets, result.ruleSet)
+		}
+	}
+
+	// If we had partial failures, return an error
+	var syncErr error
+	if len(applyErrors) > 0 {
+		syncErr = fmt.Errorf("failed to apply policies to %d/%d endpoints: %w",
+			len(applyErrors), len(endpoints), errors.Join(applyErrors...))
+	}
+	if len(remaining) > 0 {
+		syncErr = errors.Join(fmt.Errorf("%w: %d/%d endpoints not reached: %w",
+			ErrApplyInterrupted, len(remaining), len(endpoints), ctx.Err()), syncErr)
 	}
 
-	// Store the tracking information
+	// Store the tracking information, keeping the endpoints this sync did not list
 	m.mu.Lock()
+	listed := make(map[string]bool, len(endpoints))
+	for _, endpoint := range endpoints {
+		listed[endpoint.Id] = true
+	}
+	for _, ruleSet := range m.appliedPolicies[policyKey] {
+		if !listed[ruleSet.EndpointID] {
+			ruleSets = append(ruleSets, ruleSet)
+		}
+	}
+	m.recordHistoryLocked(policyKey, m.appliedPolicies[policyKey], ruleSets)
 	m.appliedPolicies[policyKey] = ruleSets
+	m.setRemainingLocked(policyKey, remaining)
+	if syncErr != nil {
+		m.syncErrors[policyKey] = syncErr.Error()
+	} else {
+		delete(m.syncErrors, policyKey)
+	}
 	m.mu.Unlock()
+	m.savePriorities()
 
-	// If we had partial failures, return an error
-	if len(applyErrors) > 0 {
-		return fmt.Errorf("failed to apply policies to %d/%d endpoints: %v",
-			len(applyErrors), len(endpoints), applyErrors)
+	if syncErr != nil {
+		return syncErr
 	}
 
 	m.logger.Info("Successfully applied ACL rules",
@@ -103,10 +419,168 @@ func (m *Manager) ApplyACLRules(policyKey string, rules []ACLRule) error {
 	return nil
 }
 
-// RemoveACLRules removes previously applied ACL rules for the given policy key
-func (m *Manager) RemoveACLRules(policyKey string) error {
-	m.logger.Info("Removing ACL rules", "policyKey", policyKey)
+// endpointSync is the outcome of converging one endpoint for a policy
+type endpointSync struct {
+	// ruleSet is what is programmed on the endpoint afterwards; empty when nothing is
+	ruleSet RuleSet
+
+	// applyErr is set when HCN rejected part of the change
+	applyErr error
+
+	// buildErr is set when the desired rules could not be converted to HCN policies
+	buildErr error
+
+	// skipped is set when the sync was interrupted before reaching the endpoint
+	skipped bool
+}
 
+// syncEndpoint converges a single endpoint toward the rules desired for policyKey,
+// starting from the rule set tracked for it. It is safe to call concurrently
+// for different endpoints.
+func (m *Manager) syncEndpoint(policyKey string, endpoint *hcn.HostComputeEndpoint, tracked RuleSet, built *sharedPolicies) endpointSync {
+	// Restored endpoints keep their backup until they are resynced
+	if m.isPinned(endpoint.Id) {
+		m.logger.V(1).Info("Skipping endpoint pinned to a restored backup", "endpointID", endpoint.Id)
+		return endpointSync{ruleSet: tracked}
+	}
+
+	// Endpoints that keep rejecting rules are only probed once their backoff expires
+	if m.backoff.suspended(endpoint.Id) {
+		m.logger.V(1).Info("Skipping endpoint suspended after repeated failures", "endpointID", endpoint.Id)
+		return endpointSync{ruleSet: tracked}
+	}
+
+	m.logger.V(1).Info("Applying policies to endpoint",
+		"endpointID", endpoint.Id,
+		"endpointName", endpoint.Name)
+
+	// Convert the endpoint's desired ACL rules to HCN endpoint policies
+	rules := m.desiredRulesFor(*endpoint)[policyKey]
+	if err := m.collisionError(endpoint.Id, rules); err != nil {
+		return endpointSync{ruleSet: tracked, applyErr: fmt.Errorf("endpoint %s: %w", endpoint.Id, err)}
+	}
+	policies, err := built.get(rules, m.buildPolicies)
+	if err != nil {
+		return endpointSync{buildErr: err}
+	}
+
+	prior := m.claimUnclaimed(policyKey, endpoint.Id, tracked.Policies, policies)
+
+	// Re-read the endpoint before touching it so rules are never programmed
+	// through a handle that was deleted or recreated since the listing
+	toRemove, toAdd := diffPolicies(prior, policies)
+	changes := len(toRemove) > 0 || len(toAdd) > 0
+	if changes {
+		fresh, changed, err := m.checkEndpoint(endpoint)
+		if errors.Is(err, ErrEndpointGone) {
+			m.logger.V(1).Info("Endpoint removed before apply, skipping", "endpointID", endpoint.Id)
+			return endpointSync{}
+		}
+		if err != nil {
+			m.logger.Error(err, "Failed to refresh endpoint before apply",
+				append([]any{"endpointID", endpoint.Id}, m.recordHNSError("get", err).LogKeys()...)...)
+			return endpointSync{applyErr: fmt.Errorf("endpoint %s: %w", endpoint.Id, err)}
+		}
+		if changed {
+			m.logger.Info("Endpoint recreated since listing, reprogramming from its current state",
+				"endpointID", endpoint.Id,
+				"endpointName", fresh.Name)
+			m.requestRequeue(policyKey, RequeueEndpointRecreated)
+			endpoint = fresh
+			prior = nil
+			rules = m.desiredRulesFor(*endpoint)[policyKey]
+			policies, err = built.get(rules, m.buildPolicies)
+			if err != nil {
+				return endpointSync{buildErr: err}
+			}
+		}
+		prior = adoptProgrammed(prior, policies, fresh.Policies)
+	}
+
+	var result endpointSync
+	programmed, err := m.reconcileEndpointPolicy(policyKey, endpoint, prior, policies)
+	removed, added := diffPolicies(prior, programmed)
+	m.churn.record(policyKey, endpoint.Id, len(added), len(removed))
+	programmedRules := rules
+	if err != nil {
+		// Trace the failure back to the policy entries of the rules that did not go through
+		failed := m.unprogrammedRules(rules, programmed)
+		m.logger.Error(err, "Failed to apply policy to endpoint",
+			slices.Concat([]any{"endpointID", endpoint.Id, "endpointName", endpoint.Name},
+				provenanceLogKeys(failed), m.recordRuleError("apply", err, failed).LogKeys())...)
+		result.applyErr = fmt.Errorf("endpoint %s: %w", endpoint.Id, err)
+		// Only part of the change went through; recover which rules are on the endpoint
+		programmedRules = m.rulesFor(programmed, rules, tracked.Rules)
+		if m.backoff.failure(endpoint.Id, err) {
+			m.logger.Info("Endpoint keeps rejecting rules, suspending it from policy syncs",
+				"endpointID", endpoint.Id,
+				"endpointName", endpoint.Name)
+		}
+	} else if changes && m.backoff.success(endpoint.Id) {
+		m.logger.Info("Suspended endpoint accepted rules again", "endpointID", endpoint.Id)
+	}
+	if len(programmed) > 0 {
+		result.ruleSet = RuleSet{
+			EndpointID: endpoint.Id,
+			Policies:   programmed,
+			Rules:      programmedRules,
+		}
+	}
+	return result
+}
+
+// reconcileEndpointPolicy moves a single endpoint from the currently programmed
+// policies to the desired ones, issuing only the removals and additions needed.
+// It returns the policies that are programmed on the endpoint afterwards.
+func (m *Manager) reconcileEndpointPolicy(policyKey string, endpoint *hcn.HostComputeEndpoint, current, desired []hcn.EndpointPolicy) ([]hcn.EndpointPolicy, error) {
+	toRemove, toAdd := diffPolicies(current, desired)
+	programmed := current
+
+	if len(toRemove) > 0 {
+		m.backupEndpoint(endpoint.Id, "replace")
+		// Policies another key still holds stay on the endpoint
+		request := hcn.PolicyEndpointRequest{Policies: m.withoutShared(policyKey, endpoint.Id, toRemove)}
+		if len(request.Policies) > 0 {
+			if err := m.client.RemoveEndpointPolicy(endpoint, hcn.RequestTypeRemove, request); err != nil {
+				return programmed, fmt.Errorf("remove stale policies: %w", err)
+			}
+		}
+		_, programmed = diffPolicies(toRemove, current)
+	}
+
+	if len(toAdd) > 0 {
+		// Policies already programmed for another key are not added twice
+		request := hcn.PolicyEndpointRequest{Policies: m.withoutShared(policyKey, endpoint.Id, toAdd)}
+		if len(request.Policies) > 0 {
+			if err := m.client.ApplyEndpointPolicy(endpoint, hcn.RequestTypeAdd, request); err != nil {
+				return programmed, err
+			}
+			m.applies.rulesProgrammed.Add(uint64(len(request.Policies)))
+		}
+	}
+
+	return desired, nil
+}
+
+// removeTracked removes every policy tracked for policyKey from its endpoints
+func (m *Manager) removeTracked(policyKey string) error {
+	defer m.policyLocks.lock(policyKey)()
+	return m.removeTrackedHeld(policyKey)
+}
+
+// removeUndesired removes the policies tracked for policyKey, which a
+// reconcile found desired nowhere, unless an apply declared it since
+func (m *Manager) removeUndesired(policyKey string) error {
+	defer m.policyLocks.lock(policyKey)()
+	if _, desired := m.desired.Get(policyKey); desired {
+		m.logger.V(1).Info("Policy applied during reconcile, keeping it", "policyKey", policyKey)
+		return nil
+	}
+	return m.removeTrackedHeld(policyKey)
+}
+
+// removeTrackedHeld is removeTracked for callers holding the lock of policyKey
+func (m *Manager) removeTrackedHeld(policyKey string) error {
 	// Get the tracked rule sets
 	m.mu.Lock()
 	ruleSets, exists := m.appliedPolicies[policyKey]
@@ -115,68 +589,357 @@ func (m *Manager) RemoveACLRules(policyKey string) error {
 		m.logger.Info("No tracked policies found for key, nothing to remove", "policyKey", policyKey)
 		return nil
 	}
+	// Back up every endpoint while its state is still tracked, and keep the
+	// policy on endpoints pinned to a restored backup
+	backups := make(map[string]map[string]BackupRuleSet)
+	var pinned, toRemove []RuleSet
+	for _, ruleSet := range ruleSets {
+		if m.pinned[ruleSet.EndpointID] {
+			pinned = append(pinned, ruleSet)
+			continue
+		}
+		toRemove = append(toRemove, ruleSet)
+		if m.backups != nil {
+			backups[ruleSet.EndpointID] = m.endpointStateLocked(ruleSet.EndpointID)
+		}
+	}
+	ruleSets = toRemove
+
 	// Remove from tracking immediately
-	delete(m.appliedPolicies, policyKey)
+	m.recordHistoryLocked(policyKey, m.appliedPolicies[policyKey], pinned)
+	if len(pinned) > 0 {
+		m.appliedPolicies[policyKey] = pinned
+	} else {
+		delete(m.appliedPolicies, policyKey)
+	}
+	delete(m.syncErrors, policyKey)
+	delete(m.remaining, policyKey)
 	m.mu.Unlock()
+	m.savePriorities()
+
+	for endpointID, state := range backups {
+		m.saveBackup(endpointID, "remove", state)
+	}
 
 	var removeErrors []error
+	var failed []RuleSet
 
-	// Remove policies from each endpoint
+	// Collect the removal of each endpoint, so clients that can batch take
+	// several endpoints per call
+	var changes []EndpointPolicyChange
+	var removed []RuleSet
 	for _, ruleSet := range ruleSets {
 		endpoint, err := m.client.GetEndpointByID(ruleSet.EndpointID)
+		if err != nil && hcn.IsNotFoundError(err) {
+			// Its policies went with the endpoint
+			m.logger.V(1).Info("Endpoint already deleted, nothing to remove", "endpointID", ruleSet.EndpointID)
+			continue
+		}
 		if err != nil {
 			m.logger.Error(err, "Failed to get endpoint for policy removal",
-				"endpointID", ruleSet.EndpointID)
+				append([]any{"endpointID", ruleSet.EndpointID}, m.recordHNSError("get", err).LogKeys()...)...)
 			removeErrors = append(removeErrors, fmt.Errorf("get endpoint %s: %w", ruleSet.EndpointID, err))
+			failed = append(failed, ruleSet)
 			continue
 		}
 
-		// Build removal request with the same policies that were applied
+		// Build removal request with the same policies that were applied,
+		// except those another key still holds on the endpoint
 		request := hcn.PolicyEndpointRequest{
-			Policies: ruleSet.Policies,
+			Policies: m.withoutShared(policyKey, ruleSet.EndpointID, ruleSet.Policies),
+		}
+		if len(request.Policies) == 0 {
+			continue
 		}
+		changes = append(changes, EndpointPolicyChange{Endpoint: endpoint, RequestType: hcn.RequestTypeRemove, Request: request})
+		removed = append(removed, ruleSet)
+	}
 
-		err = m.client.RemoveEndpointPolicy(endpoint, hcn.RequestTypeRemove, request)
+	// Remove policies from each endpoint
+	for i, err := range modifyEndpoints(m.client, changes) {
+		ruleSet := removed[i]
 		if err != nil {
 			m.logger.Error(err, "Failed to remove policy from endpoint",
-				"endpointID", ruleSet.EndpointID)
+				append([]any{"endpointID", ruleSet.EndpointID}, m.recordHNSError("remove", err).LogKeys()...)...)
 			removeErrors = append(removeErrors, fmt.Errorf("endpoint %s: %w", ruleSet.EndpointID, err))
+			failed = append(failed, ruleSet)
 			continue
 		}
 
+		m.churn.record(policyKey, ruleSet.EndpointID, 0, len(ruleSet.Policies))
 		m.logger.V(1).Info("Successfully removed policies from endpoint",
 			"endpointID", ruleSet.EndpointID,
 			"policyCount", len(ruleSet.Policies))
 	}
+	if len(pinned) == 0 {
+		m.churn.forgetPolicy(policyKey)
+	}
 
 	if len(removeErrors) > 0 {
-		return fmt.Errorf("failed to remove policies from %d/%d endpoints: %v",
-			len(removeErrors), len(ruleSets), removeErrors)
+		// Keep what is still on the endpoints tracked, so the next resync or,
+		// through the priority store, the orphan cleanup of the next run
+		// removes it instead of leaving it behind untracked
+		m.retrackFailed(policyKey, failed)
+		return fmt.Errorf("failed to remove policies from %d/%d endpoints: %w",
+			len(removeErrors), len(ruleSets), errors.Join(removeErrors...))
 	}
 
 	m.logger.Info("Successfully removed ACL rules", "policyKey", policyKey)
 	return nil
 }
 
-// buildPolicies converts ACLRules to HCN EndpointPolicy objects
+// retrackFailed tracks the rule sets a removal failed on under policyKey
+// again, except on endpoints a sync has programmed for the key since
+func (m *Manager) retrackFailed(policyKey string, failed []RuleSet) {
+	if len(failed) == 0 {
+		return
+	}
+	defer m.savePriorities()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous := m.appliedPolicies[policyKey]
+	ruleSets := append(make([]RuleSet, 0, len(previous)+len(failed)), previous...)
+	for _, ruleSet := range failed {
+		synced := slices.ContainsFunc(previous, func(tracked RuleSet) bool {
+			return tracked.EndpointID == ruleSet.EndpointID
+		})
+		if !synced {
+			ruleSets = append(ruleSets, ruleSet)
+		}
+	}
+	m.recordHistoryLocked(policyKey, previous, ruleSets)
+	m.appliedPolicies[policyKey] = ruleSets
+}
+
+// sharedPolicies memoizes built HCN policies per distinct rule list so that
+// endpoints with identical rules reuse the same slice (copy-on-write: the
+// slices are never mutated once built, only replaced). It is safe for
+// concurrent use by the endpoint workers of one sync.
+type sharedPolicies struct {
+	mu       sync.Mutex
+	variants []sharedPolicyVariant
+}
+
+type sharedPolicyVariant struct {
+	rules    []ACLRule
+	policies []hcn.EndpointPolicy
+}
+
+// get returns the policies for rules, building them at most once per distinct rule list
+func (s *sharedPolicies) get(rules []ACLRule, build func([]ACLRule) ([]hcn.EndpointPolicy, error)) ([]hcn.EndpointPolicy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, variant := range s.variants {
+		if rulesEqual(variant.rules, rules) {
+			return variant.policies, nil
+		}
+	}
+
+	policies, err := build(rules)
+	if err != nil {
+		return nil, err
+	}
+	s.variants = append(s.variants, sharedPolicyVariant{rules: rules, policies: policies})
+	return policies, nil
+}
+
+// rulesEqual reports whether two rule lists are identical, element by element
+func rulesEqual(a, b []ACLRule) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// rulesFor maps programmed policies back to the rules they were built from,
+// searching the candidate rule lists in order. Policies built from none of the
+// candidates map to a rule carrying only the policy's settings.
+func (m *Manager) rulesFor(programmed []hcn.EndpointPolicy, candidates ...[]ACLRule) []ACLRule {
+	byPayload := make(map[string]ACLRule)
+	for _, rules := range candidates {
+		for _, rule := range rules {
+			payload, err := m.payloads.get(rule)
+			if err != nil {
+				continue
+			}
+			if _, exists := byPayload[string(payload)]; !exists {
+				byPayload[string(payload)] = rule
+			}
+		}
+	}
+
+	rules := make([]ACLRule, 0, len(programmed))
+	for _, policy := range programmed {
+		rule, found := byPayload[string(policy.Settings)]
+		if !found {
+			rule = ruleFromPolicy(policy)
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// policyID identifies an endpoint policy by its type and payload
+func policyID(p hcn.EndpointPolicy) string {
+	return string(p.Type) + ":" + string(p.Settings)
+}
+
+// withoutShared drops the policies another key also tracks on the endpoint
+// from an add or remove request. Keys emitting identical rules, such as the
+// isolation denies of policies selecting the same pod, share one copy of the
+// payload, which HNS would otherwise duplicate or remove for all of them.
+func (m *Manager) withoutShared(policyKey, endpointID string, policies []hcn.EndpointPolicy) []hcn.EndpointPolicy {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	held := make(map[string]bool)
+	for key, ruleSets := range m.appliedPolicies {
+		if key == policyKey {
+			continue
+		}
+		for _, ruleSet := range ruleSets {
+			if ruleSet.EndpointID != endpointID {
+				continue
+			}
+			for _, policy := range ruleSet.Policies {
+				held[policyID(policy)] = true
+			}
+		}
+	}
+	if len(held) == 0 {
+		return policies
+	}
+
+	kept := make([]hcn.EndpointPolicy, 0, len(policies))
+	for _, policy := range policies {
+		if !held[policyID(policy)] {
+			kept = append(kept, policy)
+		}
+	}
+	return kept
+}
+
+// adoptProgrammed returns prior with the desired policies that are already
+// programmed on the endpoint but not tracked, e.g. by a run whose tracking was
+// lost. HNS accepts the same ACL twice, so adding them again would stack a
+// duplicate on the endpoint; they are taken over instead.
+func adoptProgrammed(prior, desired, live []hcn.EndpointPolicy) []hcn.EndpointPolicy {
+	_, missing := diffPolicies(prior, desired)
+	if len(missing) == 0 {
+		return prior
+	}
+	onEndpoint := make(map[string]bool, len(live))
+	for _, policy := range live {
+		onEndpoint[policyID(policy)] = true
+	}
+	var present []hcn.EndpointPolicy
+	for _, policy := range missing {
+		if onEndpoint[policyID(policy)] {
+			present = append(present, policy)
+		}
+	}
+	if len(present) == 0 {
+		return prior
+	}
+	// Copy on write: prior may be shared with the tracked rule sets
+	return append(append([]hcn.EndpointPolicy(nil), prior...), present...)
+}
+
+// diffPolicies compares two policy lists by type and settings payload and returns
+// the policies only present in current (to remove) and only in desired (to add)
+func diffPolicies(current, desired []hcn.EndpointPolicy) (toRemove, toAdd []hcn.EndpointPolicy) {
+	desiredSet := make(map[string]bool, len(desired))
+	for _, p := range desired {
+		desiredSet[policyID(p)] = true
+	}
+	currentSet := make(map[string]bool, len(current))
+	for _, p := range current {
+		currentSet[policyID(p)] = true
+		if !desiredSet[policyID(p)] {
+			toRemove = append(toRemove, p)
+		}
+	}
+	for _, p := range desired {
+		if !currentSet[policyID(p)] {
+			toAdd = append(toAdd, p)
+		}
+	}
+	return toRemove, toAdd
+}
+
+// GetDesiredRules returns the desired rules recorded for a policy key
+func (m *Manager) GetDesiredRules(policyKey PolicyKey) ([]ACLRule, bool) {
+	return m.desired.Get(string(policyKey))
+}
+
+// DesiredPolicyKeys returns the keys of every policy declared through
+// ApplyACLRules or ApplyNetworkACLRules, sorted
+func (m *Manager) DesiredPolicyKeys() []PolicyKey {
+	return typedKeys(slices.Sorted(slices.Values(append(m.desired.Keys(), m.networkKeys()...))))
+}
+
+// DesiredTable returns the complete desired ACL table for an endpoint from all
+// providers, grouped by policy key
+func (m *Manager) DesiredTable(endpoint hcn.HostComputeEndpoint) map[string][]ACLRule {
+	return m.desiredRulesFor(endpoint)
+}
+
+// desiredRulesFor returns the desired rules of all providers for an endpoint,
+// with the rules colliding with its out-of-band ACLs remapped in
+// PriorityCollisionRemap mode and, on Hyper-V isolated endpoints in
+// HyperVEnforce mode, Host rules moved to the switch. An endpoint with
+// enforcement paused, exempt or excluded in HyperVExclude mode has none.
+func (m *Manager) desiredRulesFor(endpoint hcn.HostComputeEndpoint) map[string][]ACLRule {
+	hyperV := m.hyperVMode(endpoint)
+	if m.enforcementPaused(endpoint) || m.endpointExempt(endpoint) || hyperV == HyperVExclude {
+		return map[string][]ACLRule{}
+	}
+	table := m.providerRulesFor(endpoint)
+	m.resolveCollisions(endpoint.Id, table)
+	if hyperV == HyperVEnforce {
+		// Host rules never see the traffic of a utility VM
+		for key, rules := range table {
+			table[key] = switchRules(rules)
+		}
+	}
+	return table
+}
+
+// providerRulesFor merges the desired rules of all providers for an endpoint,
+// keeping only the rules and remote addresses of the endpoint's IP families
+// and, in RemoteSubnetExclude mode, leaving the provider addresses of its
+// network's remote subnet routes out of Block rules
+func (m *Manager) providerRulesFor(endpoint hcn.HostComputeEndpoint) map[string][]ACLRule {
+	families := endpointFamilies(endpoint)
+	routes := m.excludedRoutes(endpoint.HostComputeNetwork)
+	table := make(map[string][]ACLRule)
+	for _, provider := range m.providers {
+		for key, rules := range provider.DesiredRulesFor(endpoint) {
+			if _, exists := table[key]; exists {
+				m.logger.Info("Policy key provided by multiple providers, keeping first",
+					"policyKey", key,
+					"provider", provider.Name())
+				continue
+			}
+			table[key] = excludeProviderAddresses(rulesForFamilies(rules, families), routes)
+		}
+	}
+	return table
+}
+
+// buildPolicies converts ACLRules to HCN EndpointPolicy objects,
+// reusing cached settings payloads for rules seen before
 func (m *Manager) buildPolicies(rules []ACLRule) ([]hcn.EndpointPolicy, error) {
 	policies := make([]hcn.EndpointPolicy, 0, len(rules))
 
 	for i, rule := range rules {
-		// Create ACL policy setting
-		aclSetting := hcn.AclPolicySetting{
-			Protocols:       rule.Protocol,
-			Action:          rule.Action,
-			Direction:       rule.Direction,
-			LocalAddresses:  "",                    // Not used for basic rules
-			RemoteAddresses: rule.RemoteAddresses,
-			LocalPorts:      rule.LocalPorts,
-			RemotePorts:     rule.RemotePorts,
-			Priority:        rule.Priority,
-		}
-
-		// Marshal the settings to JSON
-		settingsJSON, err := json.Marshal(aclSetting)
+		settingsJSON, err := m.payloads.get(rule)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal ACL setting for rule %d: %w", i, err)
 		}
@@ -193,8 +956,15 @@ func (m *Manager) buildPolicies(rules []ACLRule) ([]hcn.EndpointPolicy, error) {
 	return policies, nil
 }
 
-// GetAppliedPolicies returns the currently tracked policies (for testing/debugging)
-func (m *Manager) GetAppliedPolicies(policyKey string) ([]RuleSet, bool) {
+// GetAppliedPolicies returns a deep copy of the currently tracked policies
+func (m *Manager) GetAppliedPolicies(policyKey PolicyKey) ([]RuleSet, bool) {
+	ruleSets, exists := m.trackedRuleSets(string(policyKey))
+	return copyRuleSets(ruleSets), exists
+}
+
+// trackedRuleSets returns the tracked rule sets for policyKey without copying;
+// callers must not modify them
+func (m *Manager) trackedRuleSets(policyKey string) ([]RuleSet, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	ruleSets, exists := m.appliedPolicies[policyKey]
@@ -202,7 +972,13 @@ func (m *Manager) GetAppliedPolicies(policyKey string) ([]RuleSet, bool) {
 }
 
 // ListTrackedPolicies returns all tracked policy keys
-func (m *Manager) ListTrackedPolicies() []string {
+func (m *Manager) ListTrackedPolicies() []PolicyKey {
+	return typedKeys(m.trackedKeys())
+}
+
+// trackedKeys returns all tracked policy keys untyped, for indexing the
+// Manager's maps
+func (m *Manager) trackedKeys() []string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -0,0 +1,128 @@
+//go:build windows
+
+package hcn
+
+import (
+	"fmt"
+	"runtime/debug"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+// BenchmarkApplyACLRules_ManyEndpoints measures a mass apply of one policy to 200 endpoints
+func BenchmarkApplyACLRules_ManyEndpoints(b *testing.B) {
+	rules := benchmarkRules(10)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		mockClient := newMockHCNClient()
+		for e := 0; e < 200; e++ {
+			mockClient.endpoints = append(mockClient.endpoints, hcn.HostComputeEndpoint{Id: fmt.Sprintf("ep-%d", e)})
+		}
+		manager := NewManager(mockClient, logr.Discard())
+		if err := manager.ApplyACLRules("default/bench", rules); err != nil {
+			b.Fatalf("ApplyACLRules failed: %v", err)
+		}
+	}
+}
+
+// BenchmarkApplyACLRules_HNSLatency measures a mass apply against simulated HNS
+// latency, comparing endpoint worker counts as tuned by --endpoint-workers
+func BenchmarkApplyACLRules_HNSLatency(b *testing.B) {
+	rules := benchmarkRules(10)
+
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				client := NewFakeClient(50)
+				client.SetLatency(TypicalHNSLatency())
+				manager := NewManager(client, logr.Discard())
+				if err := manager.SetConcurrency(ConcurrencyOptions{EndpointWorkers: workers}); err != nil {
+					b.Fatalf("SetConcurrency failed: %v", err)
+				}
+				if err := manager.ApplyACLRules("default/bench", rules); err != nil {
+					b.Fatalf("ApplyACLRules failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// benchmarkRules returns n distinct rules
+func benchmarkRules(n int) []ACLRule {
+	rules := make([]ACLRule, 0, n)
+	for i := 0; i < n; i++ {
+		rules = append(rules, ACLRule{
+			Name:            "allow",
+			Action:          hcn.ActionTypeAllow,
+			Direction:       hcn.DirectionTypeIn,
+			Protocol:        "6",
+			LocalPorts:      fmt.Sprintf("%d", 8000+i),
+			RemoteAddresses: "10.0.0.0/8",
+			Priority:        uint16(100 + i),
+		})
+	}
+	return rules
+}
+
+// BenchmarkBuildPolicies_Cold marshals every payload, as on the first apply
+func BenchmarkBuildPolicies_Cold(b *testing.B) {
+	rules := benchmarkRules(50)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		manager := NewManager(newMockHCNClient(), logr.Discard())
+		if _, err := manager.buildPolicies(rules); err != nil {
+			b.Fatalf("buildPolicies failed: %v", err)
+		}
+	}
+}
+
+// BenchmarkBuildPolicies_Cached reuses cached payloads, as on every resync
+func BenchmarkBuildPolicies_Cached(b *testing.B) {
+	rules := benchmarkRules(50)
+	manager := NewManager(newMockHCNClient(), logr.Discard())
+	if _, err := manager.buildPolicies(rules); err != nil {
+		b.Fatalf("buildPolicies failed: %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := manager.buildPolicies(rules); err != nil {
+			b.Fatalf("buildPolicies failed: %v", err)
+		}
+	}
+}
+
+// BenchmarkMassResync applies 50 policies to 500 endpoints from a cold manager,
+// as after an agent restart, under the default and perf-mode GC targets
+func BenchmarkMassResync(b *testing.B) {
+	policies := make(map[PolicyKey][]ACLRule, 50)
+	for p := 0; p < 50; p++ {
+		policies[PolicyKey(fmt.Sprintf("default/bench-%d", p))] = benchmarkRules(20)
+	}
+
+	for _, gogc := range []int{100, 400} {
+		b.Run(fmt.Sprintf("GOGC=%d", gogc), func(b *testing.B) {
+			previous := debug.SetGCPercent(gogc)
+			defer debug.SetGCPercent(previous)
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				mockClient := newMockHCNClient()
+				for e := 0; e < 500; e++ {
+					mockClient.endpoints = append(mockClient.endpoints, hcn.HostComputeEndpoint{Id: fmt.Sprintf("ep-%d", e)})
+				}
+				manager := NewManager(mockClient, logr.Discard())
+				for key, rules := range policies {
+					if err := manager.ApplyACLRules(key, rules); err != nil {
+						b.Fatalf("ApplyACLRules failed: %v", err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ package hcn
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/Microsoft/hcsshim/hcn"
@@ -19,6 +20,7 @@ type mockHCNClient struct {
 	removePolicyErr    error
 	appliedPolicies    map[string][]hcn.EndpointPolicy // endpoint ID -> policies
 	removedPolicies    map[string][]hcn.EndpointPolicy // endpoint ID -> policies
+	refreshed          map[string]*hcn.HostComputeEndpoint // endpoint ID -> GetEndpointByID result, nil when deleted
 }
 
 func newMockHCNClient() *mockHCNClient {
@@ -40,12 +42,18 @@ func (m *mockHCNClient) GetEndpointByID(id string) (*hcn.HostComputeEndpoint, er
 	if m.getEndpointErr != nil {
 		return nil, m.getEndpointErr
 	}
+	if ep, ok := m.refreshed[id]; ok {
+		if ep == nil {
+			return nil, hcn.EndpointNotFoundError{EndpointID: id}
+		}
+		return ep, nil
+	}
 	for _, ep := range m.endpoints {
 		if ep.Id == id {
 			return &ep, nil
 		}
 	}
-	return nil, errors.New("endpoint not found")
+	return nil, hcn.EndpointNotFoundError{EndpointID: id}
 }
 
 func (m *mockHCNClient) ApplyEndpointPolicy(endpoint *hcn.HostComputeEndpoint, requestType hcn.RequestType, request hcn.PolicyEndpointRequest) error {
@@ -289,6 +297,42 @@ func TestRemoveACLRules_GetEndpointError(t *testing.T) {
 	}
 }
 
+func TestRemoveACLRules_KeepsFailedRemovalsTracked(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{
+		{Id: "ep-1", Name: "endpoint-1"},
+	}
+	manager := NewManager(mockClient, logr.Discard())
+
+	rules := []ACLRule{
+		{Name: "allow-http", Action: hcn.ActionTypeAllow, Direction: hcn.DirectionTypeIn, Protocol: "6", Priority: 100},
+	}
+	if err := manager.ApplyACLRules("default/test-policy", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	mockClient.removePolicyErr = errors.New("HNS unavailable")
+	if err := manager.RemoveACLRules("default/test-policy"); err == nil {
+		t.Fatal("Expected error when RemoveEndpointPolicy fails")
+	}
+	ruleSets, exists := manager.GetAppliedPolicies("default/test-policy")
+	if !exists || len(ruleSets) != 1 || ruleSets[0].EndpointID != "ep-1" {
+		t.Fatalf("Expected the rules left on ep-1 to stay tracked, got %v", ruleSets)
+	}
+
+	// The next resync finds the key no longer desired and retries the removal
+	mockClient.removePolicyErr = nil
+	if err := manager.Reconcile(); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if _, exists := manager.GetAppliedPolicies("default/test-policy"); exists {
+		t.Error("Expected the policy untracked once its rules are removed")
+	}
+	if len(mockClient.removedPolicies["ep-1"]) != 1 {
+		t.Errorf("Expected 1 policy removed from ep-1, got %d", len(mockClient.removedPolicies["ep-1"]))
+	}
+}
+
 func TestBuildPolicies(t *testing.T) {
 	mockClient := newMockHCNClient()
 	manager := NewManager(mockClient, logr.Discard())
@@ -362,7 +406,7 @@ func TestListTrackedPolicies(t *testing.T) {
 	}
 
 	// Verify all expected keys are present
-	expectedKeys := map[string]bool{
+	expectedKeys := map[PolicyKey]bool{
 		"default/policy-1":     true,
 		"default/policy-2":     true,
 		"kube-system/policy-3": true,
@@ -374,3 +418,251 @@ func TestListTrackedPolicies(t *testing.T) {
 		}
 	}
 }
+
+func TestApplyACLRules_Idempotent(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{
+		{Id: "ep-1", Name: "endpoint-1"},
+	}
+
+	manager := NewManager(mockClient, logr.Discard())
+
+	rules := []ACLRule{
+		{
+			Name:      "allow-http",
+			Action:    hcn.ActionTypeAllow,
+			Direction: hcn.DirectionTypeIn,
+			Protocol:  "6",
+			Priority:  100,
+		},
+	}
+
+	// Reconciling the same desired state twice must not stack duplicate ACLs
+	if err := manager.ApplyACLRules("default/test-policy", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	if err := manager.ApplyACLRules("default/test-policy", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	if len(mockClient.appliedPolicies["ep-1"]) != 1 {
+		t.Errorf("Expected 1 policy applied to ep-1, got %d", len(mockClient.appliedPolicies["ep-1"]))
+	}
+	if len(mockClient.removedPolicies) != 0 {
+		t.Errorf("Expected no removals, got %d", len(mockClient.removedPolicies))
+	}
+}
+
+func TestApplyACLRules_AdoptsUntrackedRules(t *testing.T) {
+	client := &countingClient{FakeClient: NewFakeClient(2)}
+	rules := benchmarkRules(3)
+	first := NewManager(client, logr.Discard())
+	if err := first.ApplyACLRules("netpol/default/web", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	// A restarted agent without a priority store tracks nothing, and one more rule is desired
+	client.applies = 0
+	second := NewManager(client, logr.Discard())
+	if err := second.ApplyACLRules("netpol/default/web", benchmarkRules(4)); err != nil {
+		t.Fatalf("ApplyACLRules after restart failed: %v", err)
+	}
+	if client.applies != 2 || client.removes != 0 {
+		t.Errorf("Expected one add per endpoint and no removes, got %d adds and %d removes", client.applies, client.removes)
+	}
+	endpoints, _ := client.ListEndpoints()
+	for _, endpoint := range endpoints {
+		if len(endpoint.Policies) != 4 {
+			t.Errorf("Expected 4 ACLs on %s without duplicates, got %d", endpoint.Id, len(endpoint.Policies))
+		}
+	}
+	ruleSets, _ := second.GetAppliedPolicies("netpol/default/web")
+	if len(ruleSets) != 2 || len(ruleSets[0].Policies) != 4 || len(ruleSets[0].Rules) != 4 {
+		t.Errorf("Expected the rules found on the endpoints tracked, got %+v", ruleSets)
+	}
+}
+
+func TestApplyACLRules_UpdateReplacesChangedRules(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{
+		{Id: "ep-1", Name: "endpoint-1"},
+	}
+
+	manager := NewManager(mockClient, logr.Discard())
+
+	httpRule := ACLRule{
+		Name:       "allow-http",
+		Action:     hcn.ActionTypeAllow,
+		Direction:  hcn.DirectionTypeIn,
+		Protocol:   "6",
+		LocalPorts: "80",
+		Priority:   100,
+	}
+	httpsRule := httpRule
+	httpsRule.LocalPorts = "443"
+	httpsRule.Priority = 101
+
+	if err := manager.ApplyACLRules("default/test-policy", []ACLRule{httpRule, httpsRule}); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	// Drop the HTTP rule; only it should be removed, HTTPS stays untouched
+	if err := manager.ApplyACLRules("default/test-policy", []ACLRule{httpsRule}); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	if len(mockClient.removedPolicies["ep-1"]) != 1 {
+		t.Errorf("Expected 1 policy removed from ep-1, got %d", len(mockClient.removedPolicies["ep-1"]))
+	}
+	if len(mockClient.appliedPolicies["ep-1"]) != 2 {
+		t.Errorf("Expected 2 policy additions in total on ep-1, got %d", len(mockClient.appliedPolicies["ep-1"]))
+	}
+
+	ruleSets, _ := manager.GetAppliedPolicies("default/test-policy")
+	if len(ruleSets) != 1 || len(ruleSets[0].Policies) != 1 {
+		t.Errorf("Expected 1 tracked policy on ep-1, got %+v", ruleSets)
+	}
+}
+
+func TestReconcile_ConvergesToDesiredState(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{
+		{Id: "ep-1", Name: "endpoint-1"},
+	}
+
+	manager := NewManager(mockClient, logr.Discard())
+
+	rules := []ACLRule{
+		{
+			Name:      "allow-http",
+			Action:    hcn.ActionTypeAllow,
+			Direction: hcn.DirectionTypeIn,
+			Protocol:  "6",
+			Priority:  100,
+		},
+	}
+
+	if err := manager.ApplyACLRules("default/stale", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	// The stale policy disappears from the desired state, a new one is declared
+	manager.desired.Delete("default/stale")
+	manager.desired.Set("default/fresh", rules)
+
+	// A new endpoint shows up between reconciles
+	mockClient.endpoints = append(mockClient.endpoints, hcn.HostComputeEndpoint{Id: "ep-2", Name: "endpoint-2"})
+
+	if err := manager.Reconcile(); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	if _, exists := manager.GetAppliedPolicies("default/stale"); exists {
+		t.Error("Expected stale policy to be removed")
+	}
+	if len(mockClient.removedPolicies["ep-1"]) != 1 {
+		t.Errorf("Expected stale policy removed from ep-1, got %d", len(mockClient.removedPolicies["ep-1"]))
+	}
+
+	ruleSets, exists := manager.GetAppliedPolicies("default/fresh")
+	if !exists || len(ruleSets) != 2 {
+		t.Errorf("Expected fresh policy on 2 endpoints, got %d", len(ruleSets))
+	}
+}
+
+func TestApplyACLRules_SharesPoliciesAcrossEndpoints(t *testing.T) {
+	mockClient := newMockHCNClient()
+	for i := 0; i < 3; i++ {
+		mockClient.endpoints = append(mockClient.endpoints, hcn.HostComputeEndpoint{Id: fmt.Sprintf("ep-%d", i)})
+	}
+
+	manager := NewManager(mockClient, logr.Discard())
+
+	rules := []ACLRule{
+		{
+			Name:      "allow-http",
+			Action:    hcn.ActionTypeAllow,
+			Direction: hcn.DirectionTypeIn,
+			Protocol:  "6",
+			Priority:  100,
+		},
+	}
+
+	if err := manager.ApplyACLRules("default/test-policy", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	ruleSets, _ := manager.GetAppliedPolicies("default/test-policy")
+	if len(ruleSets) != 3 {
+		t.Fatalf("Expected 3 rule sets, got %d", len(ruleSets))
+	}
+
+	// Identical desired rules are marshalled once and the slice is shared
+	for _, ruleSet := range ruleSets[1:] {
+		if &ruleSet.Policies[0] != &ruleSets[0].Policies[0] {
+			t.Errorf("Expected endpoint %s to share the policy slice", ruleSet.EndpointID)
+		}
+	}
+}
+
+func TestApplyACLRules_TracksLabels(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{{Id: "ep-1"}}
+
+	manager := NewManager(mockClient, logr.Discard())
+
+	rules := benchmarkRules(2)
+	rules[0].Labels = map[string]string{"team": "payments"}
+	if err := manager.ApplyACLRules("default/test-policy", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	// A label-only change is tracked without touching HCN
+	relabeled := benchmarkRules(2)
+	relabeled[0].Labels = map[string]string{"team": "security"}
+	if err := manager.ApplyACLRules("default/test-policy", relabeled); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	if len(mockClient.appliedPolicies["ep-1"]) != 2 || len(mockClient.removedPolicies) != 0 {
+		t.Errorf("Expected no HCN calls for a label change, got %d applied and %d removed",
+			len(mockClient.appliedPolicies["ep-1"]), len(mockClient.removedPolicies))
+	}
+
+	ruleSets, _ := manager.GetAppliedPolicies("default/test-policy")
+	if len(ruleSets) != 1 || len(ruleSets[0].Rules) != 2 {
+		t.Fatalf("Expected 2 tracked rules, got %+v", ruleSets)
+	}
+	if team := ruleSets[0].Rules[0].Labels["team"]; team != "security" {
+		t.Errorf("Expected tracked label team=security, got %q", team)
+	}
+}
+
+func TestRemoveACLRules_KeepsPoliciesSharedWithOtherKeys(t *testing.T) {
+	client := NewFakeClient(1)
+	manager := NewManager(client, logr.Discard())
+
+	deny := ACLRule{Name: "isolation", Action: hcn.ActionTypeBlock, Direction: hcn.DirectionTypeIn, Priority: 65497}
+	if err := manager.ApplyACLRules("default/a", append(benchmarkRules(1), deny)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	if err := manager.ApplyACLRules("default/b", []ACLRule{deny}); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	// Removing one policy keeps the deny the other one still holds
+	if err := manager.RemoveACLRules("default/a"); err != nil {
+		t.Fatalf("RemoveACLRules failed: %v", err)
+	}
+	endpoint, _ := client.GetEndpointByID("fake-endpoint-0")
+	if len(endpoint.Policies) != 1 {
+		t.Fatalf("Expected the shared deny kept, got %d policies", len(endpoint.Policies))
+	}
+
+	if err := manager.RemoveACLRules("default/b"); err != nil {
+		t.Fatalf("RemoveACLRules failed: %v", err)
+	}
+	endpoint, _ = client.GetEndpointByID("fake-endpoint-0")
+	if len(endpoint.Policies) != 0 {
+		t.Errorf("Expected the deny removed with its last policy, got %d policies", len(endpoint.Policies))
+	}
+}
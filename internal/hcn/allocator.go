@@ -0,0 +1,192 @@
+//go:build windows
+
+package hcn
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// PriorityAllocator hands each policy a range of priorities of its own within
+// a band, so rules of different policies on an endpoint never share a
+// priority. Ranges are sized in multiples of a block, which lets a policy grow
+// by a few rules without moving, skip reserved ranges and are reclaimed by
+// Release when the policy is deleted.
+type PriorityAllocator struct {
+	mu       sync.Mutex
+	band     PriorityRange
+	reserved []PriorityRange
+	block    uint16
+
+	// ranges maps policy key -> allocated range
+	ranges map[string]PriorityRange
+}
+
+// NewPriorityAllocator creates an allocator handing out ranges of band in
+// multiples of block priorities, around the reserved ranges
+func NewPriorityAllocator(band PriorityRange, reserved []PriorityRange, block uint16) (*PriorityAllocator, error) {
+	if err := band.Validate(); err != nil {
+		return nil, err
+	}
+	if band.Start < MinPriority || band.End > MaxPriority {
+		return nil, fmt.Errorf("priority band %s outside the valid range %d-%d", band, MinPriority, MaxPriority)
+	}
+	if block == 0 {
+		return nil, fmt.Errorf("priority block size must be positive")
+	}
+	return &PriorityAllocator{
+		band:     band,
+		reserved: reserved,
+		block:    block,
+		ranges:   make(map[string]PriorityRange),
+	}, nil
+}
+
+// Allocate returns the range of policyKey, large enough for span consecutive
+// priorities. A range that still fits is kept; one that is too small grows in
+// place if it can and moves otherwise. A span of 0 releases the range.
+func (a *PriorityAllocator) Allocate(policyKey string, span int) (PriorityRange, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if span <= 0 {
+		delete(a.ranges, policyKey)
+		return PriorityRange{}, nil
+	}
+
+	current, allocated := a.ranges[policyKey]
+	if allocated && rangeSize(current) >= span {
+		return current, nil
+	}
+	size := (span + int(a.block) - 1) / int(a.block) * int(a.block)
+	if allocated {
+		grown := PriorityRange{Start: current.Start, End: current.Start}
+		if end := int(current.Start) + size - 1; end <= int(a.band.End) {
+			grown.End = uint16(end)
+			if a.isFree(policyKey, grown) {
+				a.ranges[policyKey] = grown
+				return grown, nil
+			}
+		}
+	}
+
+	allocation, ok := a.firstFit(policyKey, size)
+	if !ok {
+		return PriorityRange{}, fmt.Errorf("%w: no %d free priorities in band %s for %s",
+			ErrPriorityExhausted, size, a.band, policyKey)
+	}
+	a.ranges[policyKey] = allocation
+	return allocation, nil
+}
+
+// Release reclaims the range of policyKey
+func (a *PriorityAllocator) Release(policyKey string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.ranges, policyKey)
+}
+
+// Restore hands out the ranges a previous run used, e.g. those of
+// Manager.TrackedPriorityRanges, so restarting does not move rules. Ranges
+// overlapping one restored before them, in key order, are left to Allocate.
+func (a *PriorityAllocator) Restore(ranges map[string]PriorityRange) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	keys := make([]string, 0, len(ranges))
+	for key := range ranges {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		r := ranges[key]
+		if r.Start < a.band.Start || r.End > a.band.End || !a.isFree(key, r) {
+			continue
+		}
+		a.ranges[key] = r
+	}
+}
+
+// Ranges returns the allocated ranges by policy key
+func (a *PriorityAllocator) Ranges() map[string]PriorityRange {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ranges := make(map[string]PriorityRange, len(a.ranges))
+	for key, r := range a.ranges {
+		ranges[key] = r
+	}
+	return ranges
+}
+
+// taken returns the ranges held by reserved ranges and policies other than
+// policyKey, ordered by start; callers hold mu
+func (a *PriorityAllocator) taken(policyKey string) []PriorityRange {
+	taken := append([]PriorityRange(nil), a.reserved...)
+	for key, r := range a.ranges {
+		if key != policyKey {
+			taken = append(taken, r)
+		}
+	}
+	sort.Slice(taken, func(i, j int) bool { return taken[i].Start < taken[j].Start })
+	return taken
+}
+
+// isFree reports whether r overlaps no range taken by others; callers hold mu
+func (a *PriorityAllocator) isFree(policyKey string, r PriorityRange) bool {
+	for _, other := range a.taken(policyKey) {
+		if other.Start <= r.End && r.Start <= other.End {
+			return false
+		}
+	}
+	return true
+}
+
+// firstFit finds the lowest free range of size priorities; callers hold mu
+func (a *PriorityAllocator) firstFit(policyKey string, size int) (PriorityRange, bool) {
+	start := int(a.band.Start)
+	for _, other := range a.taken(policyKey) {
+		if int(other.End) < start {
+			continue
+		}
+		if int(other.Start)-start >= size {
+			break
+		}
+		start = int(other.End) + 1
+	}
+	if start+size-1 > int(a.band.End) {
+		return PriorityRange{}, false
+	}
+	return PriorityRange{Start: uint16(start), End: uint16(start + size - 1)}, true
+}
+
+// rangeSize returns the number of priorities in r
+func rangeSize(r PriorityRange) int {
+	return int(r.End) - int(r.Start) + 1
+}
+
+// TrackedPriorityRanges returns, for each tracked policy, the smallest range
+// holding the priorities of its rules that lie in band, to seed a
+// PriorityAllocator after a restart
+func (m *Manager) TrackedPriorityRanges(band PriorityRange) map[string]PriorityRange {
+	ranges := make(map[string]PriorityRange)
+	for _, key := range m.trackedKeys() {
+		if key == UnclaimedPolicyKey {
+			continue
+		}
+		ruleSets, _ := m.trackedRuleSets(key)
+		for _, ruleSet := range ruleSets {
+			for _, rule := range ruleSet.Rules {
+				if !band.Contains(rule.Priority) {
+					continue
+				}
+				r, found := ranges[key]
+				if !found {
+					r = PriorityRange{Start: rule.Priority, End: rule.Priority}
+				}
+				r.Start = min(r.Start, rule.Priority)
+				r.End = max(r.End, rule.Priority)
+				ranges[key] = r
+			}
+		}
+	}
+	return ranges
+}
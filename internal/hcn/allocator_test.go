@@ -0,0 +1,86 @@
+//go:build windows
+
+package hcn
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestPriorityAllocator(t *testing.T) {
+	allocator, err := NewPriorityAllocator(PriorityRange{Start: 100, End: 199}, []PriorityRange{{Start: 116, End: 119}}, 16)
+	if err != nil {
+		t.Fatalf("NewPriorityAllocator failed: %v", err)
+	}
+
+	web, _ := allocator.Allocate("default/web", 3)
+	if web != (PriorityRange{Start: 100, End: 115}) {
+		t.Errorf("Expected the first block, got %s", web)
+	}
+	// The next block would overlap the reserved range
+	db, _ := allocator.Allocate("default/db", 10)
+	if db != (PriorityRange{Start: 120, End: 135}) {
+		t.Errorf("Expected the block after the reserved range, got %s", db)
+	}
+
+	// A range that still fits is kept
+	if again, _ := allocator.Allocate("default/web", 16); again != web {
+		t.Errorf("Expected %s kept, got %s", web, again)
+	}
+	// One that grows moves past the ranges taken around it
+	if grown, _ := allocator.Allocate("default/web", 20); grown != (PriorityRange{Start: 136, End: 167}) {
+		t.Errorf("Expected web moved to 136-167, got %s", grown)
+	}
+	// Growing in place when the priorities after the range are free
+	if grown, _ := allocator.Allocate("default/web", 40); grown != (PriorityRange{Start: 136, End: 183}) {
+		t.Errorf("Expected web grown in place to 136-183, got %s", grown)
+	}
+
+	if _, err := allocator.Allocate("default/big", 40); !errors.Is(err, ErrPriorityExhausted) {
+		t.Errorf("Expected ErrPriorityExhausted, got %v", err)
+	}
+
+	// Released ranges are handed out again
+	allocator.Release("default/db")
+	if api, _ := allocator.Allocate("default/api", 1); api != (PriorityRange{Start: 100, End: 115}) {
+		t.Errorf("Expected the first free block, got %s", api)
+	}
+	if api, _ := allocator.Allocate("default/api", 0); api != (PriorityRange{}) {
+		t.Errorf("Expected a span of 0 to release the range, got %s", api)
+	}
+	if _, found := allocator.Ranges()["default/api"]; found {
+		t.Error("Expected default/api released")
+	}
+}
+
+func TestPriorityAllocator_RestoreTrackedRanges(t *testing.T) {
+	manager := NewManager(NewFakeClient(1), logr.Discard())
+	db := benchmarkRules(3)[1:]
+	for i := range db {
+		db[i].LocalPorts = "9000"
+	}
+	if err := manager.ApplyACLRules("default/api", benchmarkRules(3)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	if err := manager.ApplyACLRules("default/db", db); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	ranges := manager.TrackedPriorityRanges(PriorityRange{Start: 100, End: 65496})
+	if ranges["default/api"] != (PriorityRange{Start: 100, End: 102}) || ranges["default/db"] != (PriorityRange{Start: 101, End: 102}) {
+		t.Errorf("Expected default/api at 100-102 and default/db at 101-102, got %v", ranges)
+	}
+
+	allocator, _ := NewPriorityAllocator(PriorityRange{Start: 100, End: 65496}, nil, 16)
+	allocator.Restore(ranges)
+	// default/db overlaps default/api and is left to Allocate
+	restored := allocator.Ranges()
+	if len(restored) != 1 || restored["default/api"] != ranges["default/api"] {
+		t.Errorf("Expected only default/api restored, got %v", restored)
+	}
+	if allocation, _ := allocator.Allocate("default/db", 2); allocation != (PriorityRange{Start: 103, End: 118}) {
+		t.Errorf("Expected default/db after default/api, got %s", allocation)
+	}
+}
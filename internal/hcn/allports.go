@@ -0,0 +1,70 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// AllPortsForm is how a rule matching every port is written in ACL settings.
+// Some HNS builds treat an omitted port field and an explicit full range
+// differently, so the form is selected per node like AnyAddressForm. No build
+// has been validated to need the range, so it is not detected from the
+// Windows build and AllPortsOmit stays the default.
+type AllPortsForm string
+
+const (
+	// AllPortsOmit leaves the port field empty
+	AllPortsOmit AllPortsForm = "omit"
+
+	// AllPortsRange writes the explicit range AllPortsRangeValue
+	AllPortsRange AllPortsForm = "range"
+)
+
+// AllPortsRangeValue is the explicit port range written by AllPortsRange
+const AllPortsRangeValue = "0-65535"
+
+// WindowsBuild returns the build number of the running Windows
+func WindowsBuild() uint32 {
+	return windows.RtlGetVersion().BuildNumber
+}
+
+// ParseAllPortsForm parses an --all-ports-form value
+func ParseAllPortsForm(value string) (AllPortsForm, error) {
+	switch form := AllPortsForm(value); form {
+	case AllPortsOmit, AllPortsRange:
+		return form, nil
+	default:
+		return "", fmt.Errorf("invalid all-ports form %q: must be omit or range", value)
+	}
+}
+
+// SetAllPortsForm selects how all-ports rules are sent to HNS.
+// It must be called before the Manager starts reconciling.
+func (m *Manager) SetAllPortsForm(form AllPortsForm) AllPortsForm {
+	m.payloads.mu.Lock()
+	defer m.payloads.mu.Unlock()
+	m.payloads.allPorts = form
+	m.payloads.payloads = make(map[payloadKey]json.RawMessage)
+	return form
+}
+
+// normalizeAllPorts rewrites a port field matching every port into form.
+// Ports only apply to TCP and UDP; fields of other protocols are left alone
+// since HNS rejects port ranges on them.
+func normalizeAllPorts(ports, protocol string, form AllPortsForm) string {
+	if protocol != "6" && protocol != "17" {
+		return ports
+	}
+	switch {
+	case form == AllPortsRange && ports == "":
+		return AllPortsRangeValue
+	case form == AllPortsOmit && ports == AllPortsRangeValue:
+		return ""
+	default:
+		return ports
+	}
+}
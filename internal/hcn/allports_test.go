@@ -0,0 +1,79 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+func TestNormalizeAllPorts(t *testing.T) {
+	tests := []struct {
+		name     string
+		ports    string
+		protocol string
+		form     AllPortsForm
+		want     string
+	}{
+		{name: "range form expands TCP", ports: "", protocol: "6", form: AllPortsRange, want: "0-65535"},
+		{name: "range form expands UDP", ports: "", protocol: "17", form: AllPortsRange, want: "0-65535"},
+		{name: "range form keeps explicit ports", ports: "80,443", protocol: "6", form: AllPortsRange, want: "80,443"},
+		{name: "omit form drops full range", ports: "0-65535", protocol: "6", form: AllPortsOmit, want: ""},
+		{name: "any protocol has no ports", ports: "", protocol: "", form: AllPortsRange, want: ""},
+		{name: "ICMP has no ports", ports: "", protocol: "1", form: AllPortsRange, want: ""},
+		{name: "unset form is unchanged", ports: "0-65535", protocol: "6", want: "0-65535"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeAllPorts(tt.ports, tt.protocol, tt.form); got != tt.want {
+				t.Errorf("normalizeAllPorts(%q, %q) = %q, want %q", tt.ports, tt.protocol, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAllPortsForm(t *testing.T) {
+	for _, value := range []string{"omit", "range"} {
+		if _, err := ParseAllPortsForm(value); err != nil {
+			t.Errorf("Expected %q to parse, got %v", value, err)
+		}
+	}
+	for _, value := range []string{"all", "auto"} {
+		if _, err := ParseAllPortsForm(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestSetAllPortsForm_SettingsPayload(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{{Id: "ep-1"}}
+	manager := NewManager(mockClient, logr.Discard())
+	manager.SetAnyAddressForm(AnyAddressEmpty, true)
+	if form := manager.SetAllPortsForm(AllPortsRange); form != AllPortsRange {
+		t.Fatalf("Expected the range form, got %q", form)
+	}
+
+	rules := benchmarkRules(1)
+	rules[0].LocalPorts = ""
+	rules[0].RemoteAddresses = "0.0.0.0/0"
+	if err := manager.ApplyACLRules("default/test", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	var setting hcn.AclPolicySetting
+	if err := json.Unmarshal(mockClient.appliedPolicies["ep-1"][0].Settings, &setting); err != nil {
+		t.Fatalf("Failed to decode settings: %v", err)
+	}
+	if setting.LocalPorts != AllPortsRangeValue || setting.RemotePorts != AllPortsRangeValue {
+		t.Errorf("Expected explicit port ranges, got local %q remote %q", setting.LocalPorts, setting.RemotePorts)
+	}
+	// Both forms are kept when configured one after the other
+	if setting.RemoteAddresses != "" {
+		t.Errorf("Expected the any-address form kept, got %q", setting.RemoteAddresses)
+	}
+}
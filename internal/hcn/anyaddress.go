@@ -0,0 +1,73 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// AnyAddressForm is how "any remote address" is written in ACL settings.
+// HNS builds differ in whether they honor the wildcard CIDRs or an empty
+// RemoteAddresses, so the form is selected per node. No build has been
+// validated to need the empty form, so it is not detected from the Windows
+// build and AnyAddressCIDR stays the default.
+type AnyAddressForm string
+
+const (
+	// AnyAddressCIDR sends 0.0.0.0/0 and ::/0 as generated
+	AnyAddressCIDR AnyAddressForm = "cidr"
+
+	// AnyAddressEmpty sends an empty RemoteAddresses for wildcard-only rules
+	AnyAddressEmpty AnyAddressForm = "empty"
+)
+
+// wildcardCIDRs are the remote addresses meaning "anywhere" in one IP family
+var wildcardCIDRs = map[string]bool{"0.0.0.0/0": true, "::/0": true}
+
+// ParseAnyAddressForm parses an --any-address-form value
+func ParseAnyAddressForm(value string) (AnyAddressForm, error) {
+	switch form := AnyAddressForm(value); form {
+	case AnyAddressCIDR, AnyAddressEmpty:
+		return form, nil
+	default:
+		return "", fmt.Errorf("invalid any-address form %q: must be cidr or empty", value)
+	}
+}
+
+// SetAnyAddressForm selects how wildcard remote addresses are sent to HNS.
+// singleStack reports that the node only has one IP family, so a single
+// wildcard CIDR already means any address; on dual-stack nodes only rules
+// listing both wildcards are collapsed.
+// It must be called before the Manager starts reconciling.
+func (m *Manager) SetAnyAddressForm(form AnyAddressForm, singleStack bool) AnyAddressForm {
+	m.payloads.mu.Lock()
+	defer m.payloads.mu.Unlock()
+	m.payloads.anyAddress = form
+	m.payloads.singleStack = singleStack
+	m.payloads.payloads = make(map[payloadKey]json.RawMessage)
+	return form
+}
+
+// normalizeAnyAddress rewrites remote addresses meaning "anywhere" into form.
+// Lists mixing wildcards and narrower CIDRs are left alone, as are lists
+// covering only one family of a dual-stack node.
+func normalizeAnyAddress(addresses string, form AnyAddressForm, singleStack bool) string {
+	if form != AnyAddressEmpty || addresses == "" {
+		return addresses
+	}
+
+	families := make(map[string]bool)
+	for _, address := range strings.Split(addresses, ",") {
+		address = strings.TrimSpace(address)
+		if !wildcardCIDRs[address] {
+			return addresses
+		}
+		families[address] = true
+	}
+	if len(families) < len(wildcardCIDRs) && !singleStack {
+		return addresses
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+func TestNormalizeAnyAddress(t *testing.T) {
+	tests := []struct {
+		name        string
+		addresses   string
+		form        AnyAddressForm
+		singleStack bool
+		want        string
+	}{
+		{name: "cidr form keeps wildcards", addresses: "0.0.0.0/0", form: AnyAddressCIDR, singleStack: true, want: "0.0.0.0/0"},
+		{name: "single-stack wildcard", addresses: "0.0.0.0/0", form: AnyAddressEmpty, singleStack: true, want: ""},
+		{name: "dual-stack both wildcards", addresses: "0.0.0.0/0, ::/0", form: AnyAddressEmpty, want: ""},
+		{name: "dual-stack one family stays", addresses: "0.0.0.0/0", form: AnyAddressEmpty, want: "0.0.0.0/0"},
+		{name: "mixed list stays", addresses: "0.0.0.0/0,10.0.0.0/8", form: AnyAddressEmpty, singleStack: true, want: "0.0.0.0/0,10.0.0.0/8"},
+		{name: "narrow CIDR stays", addresses: "10.0.0.0/8", form: AnyAddressEmpty, singleStack: true, want: "10.0.0.0/8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeAnyAddress(tt.addresses, tt.form, tt.singleStack); got != tt.want {
+				t.Errorf("normalizeAnyAddress(%q) = %q, want %q", tt.addresses, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAnyAddressForm(t *testing.T) {
+	for _, value := range []string{"cidr", "empty"} {
+		if _, err := ParseAnyAddressForm(value); err != nil {
+			t.Errorf("Expected %q to parse, got %v", value, err)
+		}
+	}
+	for _, value := range []string{"any", "auto"} {
+		if _, err := ParseAnyAddressForm(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestSetAnyAddressForm_SettingsPayload(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{{Id: "ep-1"}}
+	manager := NewManager(mockClient, logr.Discard())
+	if form := manager.SetAnyAddressForm(AnyAddressEmpty, true); form != AnyAddressEmpty {
+		t.Fatalf("Expected the empty form, got %q", form)
+	}
+
+	rules := benchmarkRules(1)
+	rules[0].RemoteAddresses = "0.0.0.0/0"
+	if err := manager.ApplyACLRules("default/test", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	var setting hcn.AclPolicySetting
+	if err := json.Unmarshal(mockClient.appliedPolicies["ep-1"][0].Settings, &setting); err != nil {
+		t.Fatalf("Failed to decode settings: %v", err)
+	}
+	if setting.RemoteAddresses != "" {
+		t.Errorf("Expected an empty RemoteAddresses, got %q", setting.RemoteAddresses)
+	}
+}
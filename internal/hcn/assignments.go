@@ -0,0 +1,214 @@
+//go:build windows
+
+package hcn
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// priorityStoreVersion is the format version of the priority assignments file
+const priorityStoreVersion = 1
+
+// PriorityAssignments are the rules, priorities included, programmed by the
+// Manager: policy key -> endpoint ID -> rules
+type PriorityAssignments map[string]map[string][]ACLRule
+
+// priorityFile is the on-disk form of PriorityAssignments
+type priorityFile struct {
+	Version  int                 `json:"version"`
+	Policies PriorityAssignments `json:"policies"`
+}
+
+// PriorityStore persists the priority assignments of the Manager in a JSON
+// file. Without it a restarted agent knows nothing about the rules it
+// programmed and leaves those it no longer desires behind; with it
+// the agent takes over the rules still on the endpoints and an unchanged
+// desired state needs no HCN calls.
+type PriorityStore struct {
+	path string
+
+	// mu orders saves so an older snapshot never overwrites a newer one
+	mu sync.Mutex
+
+	// encryption protects the file at rest; empty writes plain JSON
+	encryption StateEncryption
+
+	// last is the plaintext of the last write, to skip writes that change nothing
+	last []byte
+}
+
+// NewPriorityStore creates a store writing to path, creating its directory
+func NewPriorityStore(path string) (*PriorityStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create priority assignments directory: %w", err)
+	}
+	return &PriorityStore{path: path}, nil
+}
+
+// SetEncryption selects how the file is protected from the next write on.
+// Files written with another setting are still read, and rewritten with this
+// one by the next save. It must be called before Load.
+func (s *PriorityStore) SetEncryption(mode StateEncryption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.encryption = mode
+}
+
+// Load reads the stored assignments; a missing file holds none
+func (s *PriorityStore) Load() (PriorityAssignments, error) {
+	raw, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return PriorityAssignments{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read priority assignments: %w", err)
+	}
+	data, err := unprotectState(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read priority assignments %s: %w", s.path, err)
+	}
+
+	var file priorityFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse priority assignments %s: %w", s.path, err)
+	}
+	if file.Version != priorityStoreVersion {
+		return nil, fmt.Errorf("unsupported priority assignments version %d in %s", file.Version, s.path)
+	}
+	if file.Policies == nil {
+		file.Policies = PriorityAssignments{}
+	}
+	// A file not yet in the selected form is rewritten by the next save
+	if isEncryptedState(raw) == s.encrypted() {
+		s.last = data
+	}
+	return file.Policies, nil
+}
+
+// save writes assignments unless they are what the file already holds;
+// callers hold mu
+func (s *PriorityStore) save(assignments PriorityAssignments) error {
+	data, err := json.Marshal(priorityFile{Version: priorityStoreVersion, Policies: assignments})
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(data, s.last) {
+		return nil
+	}
+	content, err := protectState(data, s.encryption)
+	if err != nil {
+		return fmt.Errorf("failed to write priority assignments: %w", err)
+	}
+
+	// Write then rename so a crash never leaves a truncated file behind
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		return fmt.Errorf("failed to write priority assignments: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write priority assignments: %w", err)
+	}
+	s.last = data
+	return nil
+}
+
+// encrypted reports whether the store writes encrypted files
+func (s *PriorityStore) encrypted() bool {
+	return s.encryption != "" && s.encryption != StateEncryptionNone
+}
+
+// SetPriorityStore loads the assignments persisted by a previous run and
+// tracks the rules still programmed on live endpoints as if this run had
+// programmed them. Assigned rules missing from their endpoint are forgotten
+// and programmed again by the next sync. From then on every change to the
+// tracked rules is saved. It must be called before the Manager starts
+// reconciling.
+func (m *Manager) SetPriorityStore(store *PriorityStore) error {
+	assignments, err := store.Load()
+	if err != nil {
+		return err
+	}
+	endpoints, err := m.listEndpoints()
+	if err != nil {
+		return fmt.Errorf("failed to list HCN endpoints: %w", err)
+	}
+	live := make(map[string][]hcn.EndpointPolicy, len(endpoints))
+	for _, endpoint := range endpoints {
+		live[endpoint.Id] = endpoint.Policies
+	}
+
+	adopted := make(map[string][]RuleSet, len(assignments))
+	var adoptedRules, missingRules int
+	for key, endpointRules := range assignments {
+		// Files of earlier versions keyed NetworkPolicies without their kind
+		key = migratePolicyKey(key)
+		for endpointID, rules := range endpointRules {
+			policies, err := m.buildPolicies(rules)
+			if err != nil {
+				return fmt.Errorf("invalid priority assignment of %s on endpoint %s: %w", key, endpointID, err)
+			}
+			missing, _ := diffPolicies(policies, live[endpointID])
+			missingRules += len(missing)
+			_, present := diffPolicies(missing, policies)
+			if len(present) == 0 {
+				continue
+			}
+			adopted[key] = append(adopted[key], RuleSet{
+				EndpointID: endpointID,
+				Policies:   present,
+				Rules:      m.rulesFor(present, rules),
+			})
+			adoptedRules += len(present)
+		}
+	}
+
+	m.mu.Lock()
+	for key, ruleSets := range adopted {
+		m.appliedPolicies[key] = ruleSets
+	}
+	m.mu.Unlock()
+	m.priorities = store
+
+	m.logger.Info("Restored priority assignments",
+		"policyCount", len(adopted),
+		"ruleCount", adoptedRules,
+		"missingRuleCount", missingRules)
+	m.savePriorities()
+	return nil
+}
+
+// savePriorities persists the tracked rules if a priority store is set.
+// Failures are logged: the rules are programmed either way and the next save
+// retries.
+func (m *Manager) savePriorities() {
+	store := m.priorities
+	if store == nil {
+		return
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	m.mu.RLock()
+	assignments := make(PriorityAssignments, len(m.appliedPolicies))
+	for key, ruleSets := range m.appliedPolicies {
+		endpointRules := make(map[string][]ACLRule, len(ruleSets))
+		for _, ruleSet := range ruleSets {
+			endpointRules[ruleSet.EndpointID] = ruleSet.Rules
+		}
+		assignments[key] = endpointRules
+	}
+	m.mu.RUnlock()
+
+	if err := store.save(assignments); err != nil {
+		m.logger.Error(err, "Failed to persist priority assignments")
+	}
+}
@@ -0,0 +1,202 @@
+//go:build windows
+
+package hcn
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+// countingClient counts the policy changes sent to a FakeClient
+type countingClient struct {
+	*FakeClient
+	applies int
+	removes int
+}
+
+func (c *countingClient) ApplyEndpointPolicy(endpoint *hcn.HostComputeEndpoint, requestType hcn.RequestType, request hcn.PolicyEndpointRequest) error {
+	c.applies++
+	return c.FakeClient.ApplyEndpointPolicy(endpoint, requestType, request)
+}
+
+func (c *countingClient) RemoveEndpointPolicy(endpoint *hcn.HostComputeEndpoint, requestType hcn.RequestType, request hcn.PolicyEndpointRequest) error {
+	c.removes++
+	return c.FakeClient.RemoveEndpointPolicy(endpoint, requestType, request)
+}
+
+// restartedManager creates a Manager on client restoring the assignments in path
+func restartedManager(t *testing.T, client HCNClient, path string) *Manager {
+	t.Helper()
+	store, err := NewPriorityStore(path)
+	if err != nil {
+		t.Fatalf("NewPriorityStore failed: %v", err)
+	}
+	manager := NewManager(client, logr.Discard())
+	if err := manager.SetPriorityStore(store); err != nil {
+		t.Fatalf("SetPriorityStore failed: %v", err)
+	}
+	return manager
+}
+
+func TestPriorityStore_UnchangedAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "priorities.json")
+	client := &countingClient{FakeClient: NewFakeClient(3)}
+	web, db := benchmarkRules(6)[:4], benchmarkRules(6)[4:]
+
+	first := restartedManager(t, client, path)
+	if err := first.ApplyACLRules("netpol/default/web", web); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	if err := first.ApplyACLRules("netpol/default/db", db); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	if client.applies != 6 {
+		t.Fatalf("Expected one add per policy and endpoint, got %d", client.applies)
+	}
+
+	// The restarted agent re-applies the same desired state and a full reconcile
+	client.applies, client.removes = 0, 0
+	second := restartedManager(t, client, path)
+	if err := second.ApplyACLRules("netpol/default/web", web); err != nil {
+		t.Fatalf("ApplyACLRules after restart failed: %v", err)
+	}
+	if err := second.ApplyACLRules("netpol/default/db", db); err != nil {
+		t.Fatalf("ApplyACLRules after restart failed: %v", err)
+	}
+	if err := second.Reconcile(); err != nil {
+		t.Fatalf("Reconcile after restart failed: %v", err)
+	}
+	if client.applies != 0 || client.removes != 0 {
+		t.Errorf("Expected no HCN changes after restart, got %d adds and %d removes", client.applies, client.removes)
+	}
+	endpoints, _ := client.ListEndpoints()
+	for _, endpoint := range endpoints {
+		if len(endpoint.Policies) != 6 {
+			t.Errorf("Expected 6 ACLs on %s without duplicates, got %d", endpoint.Id, len(endpoint.Policies))
+		}
+	}
+
+	// Tracking restored from the file still removes the policy
+	if err := second.RemoveACLRules("netpol/default/db"); err != nil {
+		t.Fatalf("RemoveACLRules after restart failed: %v", err)
+	}
+	if client.removes != 3 {
+		t.Errorf("Expected the restored policy to be removed from 3 endpoints, got %d removes", client.removes)
+	}
+}
+
+func TestPriorityStore_ReprogramsMissingRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "priorities.json")
+	client := &countingClient{FakeClient: NewFakeClient(2)}
+
+	first := restartedManager(t, client, path)
+	if err := first.ApplyACLRules("netpol/default/web", benchmarkRules(2)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	// The rules vanish from one endpoint while the agent is down
+	endpoint, _ := client.GetEndpointByID("fake-endpoint-1")
+	if err := client.FakeClient.RemoveEndpointPolicy(endpoint, hcn.RequestTypeRemove, hcn.PolicyEndpointRequest{Policies: endpoint.Policies}); err != nil {
+		t.Fatalf("RemoveEndpointPolicy failed: %v", err)
+	}
+
+	client.applies = 0
+	second := restartedManager(t, client, path)
+	ruleSets, tracked := second.GetAppliedPolicies("netpol/default/web")
+	if !tracked || len(ruleSets) != 1 || ruleSets[0].EndpointID != "fake-endpoint-0" {
+		t.Fatalf("Expected only the rules still on fake-endpoint-0 to be restored, got %+v", ruleSets)
+	}
+	if err := second.ApplyACLRules("netpol/default/web", benchmarkRules(2)); err != nil {
+		t.Fatalf("ApplyACLRules after restart failed: %v", err)
+	}
+	if client.applies != 1 {
+		t.Errorf("Expected the rules to be added to fake-endpoint-1 only, got %d adds", client.applies)
+	}
+}
+
+func TestPriorityStore_MigratesUnprefixedKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "priorities.json")
+	client := &countingClient{FakeClient: NewFakeClient(1)}
+
+	// An earlier version tracked the NetworkPolicy under its bare name
+	first := restartedManager(t, client, path)
+	if err := first.ApplyACLRules("default/web", benchmarkRules(2)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	client.applies = 0
+	second := restartedManager(t, client, path)
+	if _, tracked := second.GetAppliedPolicies("netpol/default/web"); !tracked {
+		t.Fatalf("Expected the bare key to be tracked as netpol/default/web, got %v", second.ListTrackedPolicies())
+	}
+	if err := second.ApplyACLRules("netpol/default/web", benchmarkRules(2)); err != nil {
+		t.Fatalf("ApplyACLRules after restart failed: %v", err)
+	}
+	if client.applies != 0 || client.removes != 0 {
+		t.Errorf("Expected no HCN changes for a migrated key, got %d adds and %d removes", client.applies, client.removes)
+	}
+}
+
+func TestPriorityStore_MissingAndInvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewPriorityStore(filepath.Join(dir, "state", "priorities.json"))
+	if err != nil {
+		t.Fatalf("NewPriorityStore failed: %v", err)
+	}
+	assignments, err := store.Load()
+	if err != nil || len(assignments) != 0 {
+		t.Fatalf("Expected no assignments without a file, got %v, %v", assignments, err)
+	}
+
+	path := filepath.Join(dir, "priorities.json")
+	if err := os.WriteFile(path, []byte(`{"version": 2, "policies": {}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store, _ = NewPriorityStore(path)
+	if _, err := store.Load(); err == nil {
+		t.Error("Expected an unsupported version to be rejected")
+	}
+}
+
+func TestPriorityStore_Encryption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "priorities.json")
+	assignments := PriorityAssignments{"default/web": {"fake-endpoint-0": benchmarkRules(2)}}
+
+	plain, _ := NewPriorityStore(path)
+	if err := plain.save(assignments); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	// Enabling encryption rewrites a plain file on the next save
+	encrypted, _ := NewPriorityStore(path)
+	encrypted.SetEncryption(StateEncryptionUser)
+	if _, err := encrypted.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if err := encrypted.save(assignments); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isEncryptedState(data) || strings.Contains(string(data), "default/web") {
+		t.Fatal("Expected the file to be encrypted")
+	}
+
+	// Encrypted files are read whatever the store's setting
+	reader, _ := NewPriorityStore(path)
+	loaded, err := reader.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, assignments) {
+		t.Errorf("Expected %v, got %v", assignments, loaded)
+	}
+}
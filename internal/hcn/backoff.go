@@ -0,0 +1,174 @@
+//go:build windows
+
+package hcn
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EndpointBackoffOptions tunes how endpoints that keep rejecting rules (for
+// example while being torn down) are taken out of the apply loop
+type EndpointBackoffOptions struct {
+	// FailureThreshold is how many consecutive failed applies suspend an
+	// endpoint; 0 never suspends
+	FailureThreshold int
+
+	// InitialDelay is how long an endpoint is first suspended; every failed
+	// probe afterwards doubles it
+	InitialDelay time.Duration
+
+	// MaxDelay caps the suspension
+	MaxDelay time.Duration
+}
+
+// DefaultEndpointBackoffOptions returns the backoff used unless configured otherwise
+func DefaultEndpointBackoffOptions() EndpointBackoffOptions {
+	return EndpointBackoffOptions{
+		FailureThreshold: 3,
+		InitialDelay:     30 * time.Second,
+		MaxDelay:         10 * time.Minute,
+	}
+}
+
+// Validate checks the options for values the backoff cannot work with
+func (o EndpointBackoffOptions) Validate() error {
+	if o.FailureThreshold < 0 {
+		return fmt.Errorf("endpoint failure threshold must not be negative, got %d", o.FailureThreshold)
+	}
+	if o.FailureThreshold > 0 && (o.InitialDelay <= 0 || o.MaxDelay < o.InitialDelay) {
+		return fmt.Errorf("endpoint backoff delays %s-%s must be positive and increasing", o.InitialDelay, o.MaxDelay)
+	}
+	return nil
+}
+
+// SuspendedEndpoint is an endpoint skipped by policy syncs until its next probe
+type SuspendedEndpoint struct {
+	EndpointID string
+
+	// Failures is the number of consecutive failed applies
+	Failures int
+
+	// Until is when the endpoint is probed with the next sync
+	Until time.Time
+
+	// LastError is the error of the most recent failed apply
+	LastError string
+}
+
+// endpointBackoff tracks consecutive apply failures per endpoint. It has its
+// own lock since endpoints are synced concurrently.
+type endpointBackoff struct {
+	mu      sync.Mutex
+	opts    EndpointBackoffOptions
+	entries map[string]*backoffEntry
+	now     func() time.Time
+}
+
+// backoffEntry is the failure record of one endpoint
+type backoffEntry struct {
+	failures  int
+	delay     time.Duration
+	until     time.Time
+	lastError string
+}
+
+func newEndpointBackoff(opts EndpointBackoffOptions) *endpointBackoff {
+	return &endpointBackoff{opts: opts, entries: make(map[string]*backoffEntry), now: time.Now}
+}
+
+// SetEndpointBackoff replaces the endpoint backoff options and forgets every
+// recorded failure. It must be called before the Manager starts reconciling.
+func (m *Manager) SetEndpointBackoff(opts EndpointBackoffOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	m.backoff = newEndpointBackoff(opts)
+	return nil
+}
+
+// SuspendedEndpoints returns the endpoints currently skipped by policy syncs,
+// sorted by ID
+func (m *Manager) SuspendedEndpoints() []SuspendedEndpoint {
+	b := m.backoff
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	var suspended []SuspendedEndpoint
+	for endpointID, entry := range b.entries {
+		if entry.until.After(now) {
+			suspended = append(suspended, SuspendedEndpoint{
+				EndpointID: endpointID,
+				Failures:   entry.failures,
+				Until:      entry.until,
+				LastError:  entry.lastError,
+			})
+		}
+	}
+	sort.Slice(suspended, func(i, j int) bool { return suspended[i].EndpointID < suspended[j].EndpointID })
+	return suspended
+}
+
+// suspended reports whether endpointID must be skipped for now. Once the
+// suspension expires the next sync probes the endpoint.
+func (b *endpointBackoff) suspended(endpointID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entry, exists := b.entries[endpointID]
+	return exists && entry.until.After(b.now())
+}
+
+// failure records a failed apply on endpointID and reports whether it
+// suspended the endpoint
+func (b *endpointBackoff) failure(endpointID string, err error) bool {
+	if b.opts.FailureThreshold == 0 {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry, exists := b.entries[endpointID]
+	if !exists {
+		entry = &backoffEntry{}
+		b.entries[endpointID] = entry
+	}
+	entry.failures++
+	entry.lastError = err.Error()
+	if entry.failures < b.opts.FailureThreshold {
+		return false
+	}
+	// A failed probe doubles the suspension
+	if entry.delay == 0 {
+		entry.delay = b.opts.InitialDelay
+	} else {
+		entry.delay = min(2*entry.delay, b.opts.MaxDelay)
+	}
+	entry.until = b.now().Add(entry.delay)
+	return true
+}
+
+// success forgets the failures of endpointID and reports whether it was suspended
+func (b *endpointBackoff) success(endpointID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entry, exists := b.entries[endpointID]
+	if !exists {
+		return false
+	}
+	delete(b.entries, endpointID)
+	return entry.delay > 0
+}
+
+// prune forgets endpoints that no longer exist
+func (b *endpointBackoff) prune(live map[string]bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for endpointID := range b.entries {
+		if !live[endpointID] {
+			delete(b.entries, endpointID)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+//go:build windows
+
+package hcn
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+// rejectingClient fails every add on one endpoint and counts the attempts
+type rejectingClient struct {
+	*FakeClient
+	reject   string
+	attempts int
+}
+
+func (c *rejectingClient) ApplyEndpointPolicy(endpoint *hcn.HostComputeEndpoint, requestType hcn.RequestType, request hcn.PolicyEndpointRequest) error {
+	if endpoint.Id == c.reject {
+		c.attempts++
+		return errors.New("endpoint is being torn down")
+	}
+	return c.FakeClient.ApplyEndpointPolicy(endpoint, requestType, request)
+}
+
+func TestEndpointBackoff_SuspendsAndProbes(t *testing.T) {
+	client := &rejectingClient{FakeClient: NewFakeClient(2), reject: "fake-endpoint-1"}
+	manager := NewManager(client, logr.Discard())
+	if err := manager.SetEndpointBackoff(EndpointBackoffOptions{
+		FailureThreshold: 2,
+		InitialDelay:     time.Minute,
+		MaxDelay:         3 * time.Minute,
+	}); err != nil {
+		t.Fatalf("SetEndpointBackoff failed: %v", err)
+	}
+	now := time.Now()
+	manager.backoff.now = func() time.Time { return now }
+
+	// Two failures suspend the endpoint; later syncs skip it and succeed
+	for i := 0; i < 2; i++ {
+		if err := manager.ApplyACLRules("default/test", benchmarkRules(1)); err == nil {
+			t.Fatal("Expected the rejecting endpoint to fail the apply")
+		}
+	}
+	if err := manager.ApplyACLRules("default/test", benchmarkRules(1)); err != nil {
+		t.Fatalf("Expected the suspended endpoint to be skipped, got %v", err)
+	}
+	if client.attempts != 2 {
+		t.Errorf("Expected 2 attempts on the rejecting endpoint, got %d", client.attempts)
+	}
+	suspended := manager.SuspendedEndpoints()
+	if len(suspended) != 1 || suspended[0].EndpointID != "fake-endpoint-1" || !suspended[0].Until.Equal(now.Add(time.Minute)) {
+		t.Fatalf("Expected fake-endpoint-1 suspended for a minute, got %+v", suspended)
+	}
+
+	// A failed probe doubles the suspension, capped at MaxDelay
+	now = now.Add(time.Minute)
+	if err := manager.Reconcile(); err == nil {
+		t.Fatal("Expected the probe to fail")
+	}
+	if suspended = manager.SuspendedEndpoints(); len(suspended) != 1 || !suspended[0].Until.Equal(now.Add(2*time.Minute)) {
+		t.Fatalf("Expected a 2 minute suspension after the failed probe, got %+v", suspended)
+	}
+	now = now.Add(2 * time.Minute)
+	_ = manager.Reconcile()
+	if suspended = manager.SuspendedEndpoints(); len(suspended) != 1 || !suspended[0].Until.Equal(now.Add(3*time.Minute)) {
+		t.Fatalf("Expected the suspension capped at 3 minutes, got %+v", suspended)
+	}
+
+	// A successful probe releases the endpoint
+	client.reject = ""
+	now = now.Add(3 * time.Minute)
+	if err := manager.Reconcile(); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if suspended = manager.SuspendedEndpoints(); len(suspended) != 0 {
+		t.Errorf("Expected no suspended endpoints, got %+v", suspended)
+	}
+	if ruleSets, _ := manager.GetAppliedPolicies("default/test"); len(ruleSets) != 2 {
+		t.Errorf("Expected both endpoints programmed, got %+v", ruleSets)
+	}
+}
+
+func TestEndpointBackoffOptions_Validate(t *testing.T) {
+	if err := DefaultEndpointBackoffOptions().Validate(); err != nil {
+		t.Errorf("Expected the defaults to be valid, got %v", err)
+	}
+	if err := (EndpointBackoffOptions{}).Validate(); err != nil {
+		t.Errorf("Expected a disabled backoff to be valid, got %v", err)
+	}
+	if err := (EndpointBackoffOptions{FailureThreshold: 1, InitialDelay: time.Minute, MaxDelay: time.Second}).Validate(); err == nil {
+		t.Error("Expected an error for a max delay below the initial delay")
+	}
+}
@@ -0,0 +1,345 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+var (
+	// ErrBackupsDisabled is returned by restores when no backup store is configured
+	ErrBackupsDisabled = errors.New("endpoint backups are disabled")
+
+	// ErrBackupNotFound is returned when an endpoint has no backup of the requested name
+	ErrBackupNotFound = errors.New("backup not found")
+)
+
+// backupTimeFormat sorts lexically in time order and is safe in file names
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+// safeName matches endpoint IDs and backup names that are safe as path elements
+var safeName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// EndpointBackup is the controller-owned ACL state of one endpoint before a
+// destructive change
+type EndpointBackup struct {
+	EndpointID string    `json:"endpointID"`
+	Time       time.Time `json:"time"`
+	Reason     string    `json:"reason"`
+
+	// Policies are the tracked rule sets on the endpoint, by policy key
+	Policies map[string]BackupRuleSet `json:"policies"`
+}
+
+// BackupRuleSet is the state of one policy on the backed-up endpoint
+type BackupRuleSet struct {
+	Policies []hcn.EndpointPolicy `json:"policies"`
+	Rules    []ACLRule            `json:"rules,omitempty"`
+}
+
+// BackupInfo describes a stored backup
+type BackupInfo struct {
+	Name   string
+	Time   time.Time
+	Reason string
+}
+
+// BackupStore keeps the most recent backups of each endpoint as JSON files
+// under <dir>/<endpoint-id>/
+type BackupStore struct {
+	dir  string
+	keep int
+
+	// encryption protects the files at rest; empty writes plain JSON
+	encryption StateEncryption
+}
+
+// NewBackupStore creates a store under dir keeping keep backups per endpoint
+func NewBackupStore(dir string, keep int) (*BackupStore, error) {
+	if keep < 1 {
+		return nil, fmt.Errorf("backups to keep must be at least 1, got %d", keep)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	return &BackupStore{dir: dir, keep: keep}, nil
+}
+
+// SetEncryption selects how backups are protected from the next save on.
+// Backups written with another setting are still read. It must be called
+// before the store is handed to the Manager.
+func (s *BackupStore) SetEncryption(mode StateEncryption) {
+	s.encryption = mode
+}
+
+// Save writes a backup and prunes the oldest ones beyond the retention count.
+// It returns the backup's name.
+func (s *BackupStore) Save(backup EndpointBackup) (string, error) {
+	endpointDir, err := s.endpointDir(backup.EndpointID)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(endpointDir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	data, err = protectState(data, s.encryption)
+	if err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	// Write then rename so a crash never leaves a truncated backup behind
+	name := backup.Time.UTC().Format(backupTimeFormat) + "-" + backup.Reason
+	tmp := filepath.Join(endpointDir, name+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(endpointDir, name+".json")); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	backups, err := s.List(backup.EndpointID)
+	if err != nil {
+		return name, err
+	}
+	for _, old := range backups[min(len(backups), s.keep):] {
+		if err := os.Remove(filepath.Join(endpointDir, old.Name+".json")); err != nil {
+			return name, fmt.Errorf("failed to prune backup %s: %w", old.Name, err)
+		}
+	}
+	return name, nil
+}
+
+// List returns the backups of an endpoint, newest first
+func (s *BackupStore) List(endpointID string) ([]BackupInfo, error) {
+	endpointDir, err := s.endpointDir(endpointID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(endpointDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var backups []BackupInfo
+	for _, entry := range entries {
+		name, isBackup := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !isBackup {
+			continue
+		}
+		stamp, reason, _ := strings.Cut(name, "-")
+		backupTime, err := time.Parse(backupTimeFormat, stamp)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, BackupInfo{Name: name, Time: backupTime, Reason: reason})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Name > backups[j].Name
+	})
+	return backups, nil
+}
+
+// Load reads a backup of an endpoint; an empty name loads the newest one
+func (s *BackupStore) Load(endpointID, name string) (EndpointBackup, error) {
+	if name == "" {
+		backups, err := s.List(endpointID)
+		if err != nil {
+			return EndpointBackup{}, err
+		}
+		if len(backups) == 0 {
+			return EndpointBackup{}, fmt.Errorf("%w: endpoint %s has no backups", ErrBackupNotFound, endpointID)
+		}
+		name = backups[0].Name
+	}
+
+	endpointDir, err := s.endpointDir(endpointID)
+	if err != nil {
+		return EndpointBackup{}, err
+	}
+	if !safeName.MatchString(name) {
+		return EndpointBackup{}, fmt.Errorf("invalid backup name %q", name)
+	}
+
+	data, err := os.ReadFile(filepath.Join(endpointDir, name+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return EndpointBackup{}, fmt.Errorf("%w: %s/%s", ErrBackupNotFound, endpointID, name)
+	}
+	if err != nil {
+		return EndpointBackup{}, fmt.Errorf("failed to read backup: %w", err)
+	}
+	data, err = unprotectState(data)
+	if err != nil {
+		return EndpointBackup{}, fmt.Errorf("failed to read backup %s: %w", name, err)
+	}
+
+	var backup EndpointBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return EndpointBackup{}, fmt.Errorf("failed to parse backup %s: %w", name, err)
+	}
+	return backup, nil
+}
+
+// endpointDir returns the backup directory of an endpoint
+func (s *BackupStore) endpointDir(endpointID string) (string, error) {
+	if !safeName.MatchString(endpointID) {
+		return "", fmt.Errorf("invalid endpoint ID %q", endpointID)
+	}
+	return filepath.Join(s.dir, endpointID), nil
+}
+
+// SetBackupStore enables backing up each endpoint's controller-owned ACLs
+// before rules are removed or replaced on it
+func (m *Manager) SetBackupStore(store *BackupStore) {
+	m.backups = store
+}
+
+// endpointStateLocked returns the rule sets tracked on an endpoint by policy
+// key; callers hold mu
+func (m *Manager) endpointStateLocked(endpointID string) map[string]BackupRuleSet {
+	state := make(map[string]BackupRuleSet)
+	for key, ruleSets := range m.appliedPolicies {
+		for _, ruleSet := range ruleSets {
+			if ruleSet.EndpointID == endpointID && len(ruleSet.Policies) > 0 {
+				state[key] = BackupRuleSet{Policies: ruleSet.Policies, Rules: ruleSet.Rules}
+			}
+		}
+	}
+	return state
+}
+
+// backupEndpoint saves the current controller-owned ACLs of an endpoint
+func (m *Manager) backupEndpoint(endpointID, reason string) {
+	if m.backups == nil {
+		return
+	}
+	m.mu.RLock()
+	state := m.endpointStateLocked(endpointID)
+	m.mu.RUnlock()
+	m.saveBackup(endpointID, reason, state)
+}
+
+// saveBackup writes a backup of state. Failures are logged: a missing backup
+// must not block policy changes.
+func (m *Manager) saveBackup(endpointID, reason string, state map[string]BackupRuleSet) {
+	if m.backups == nil || len(state) == 0 {
+		return
+	}
+	name, err := m.backups.Save(EndpointBackup{
+		EndpointID: endpointID,
+		Time:       time.Now(),
+		Reason:     reason,
+		Policies:   state,
+	})
+	if err != nil {
+		m.logger.Error(err, "Failed to back up endpoint ACLs", "endpointID", endpointID, "reason", reason)
+		return
+	}
+	m.logger.V(1).Info("Backed up endpoint ACLs", "endpointID", endpointID, "backup", name, "policyCount", len(state))
+}
+
+// ListBackups returns the stored backups of an endpoint, newest first
+func (m *Manager) ListBackups(endpointID string) ([]BackupInfo, error) {
+	if m.backups == nil {
+		return nil, ErrBackupsDisabled
+	}
+	return m.backups.List(endpointID)
+}
+
+// RestoreEndpoint replaces the controller-owned ACLs of an endpoint with a
+// backup (the newest when name is empty) and pins the endpoint: policy syncs
+// leave it alone until ForceResyncEndpoint is called for it, so the restored
+// state survives while the broken policy is fixed.
+func (m *Manager) RestoreEndpoint(endpointID, name string) error {
+	if m.backups == nil {
+		return ErrBackupsDisabled
+	}
+	backup, err := m.backups.Load(endpointID, name)
+	if err != nil {
+		return err
+	}
+	endpoint, err := m.client.GetEndpointByID(endpointID)
+	if err != nil {
+		return fmt.Errorf("failed to get endpoint %s: %w", endpointID, err)
+	}
+
+	m.logger.Info("Restoring endpoint ACLs from backup",
+		"endpointID", endpointID,
+		"backup", name,
+		"backupTime", backup.Time)
+
+	m.mu.Lock()
+	m.pinned[endpointID] = true
+	current := m.endpointStateLocked(endpointID)
+	m.mu.Unlock()
+
+	// The state being replaced is itself backed up, so a restore can be undone
+	m.saveBackup(endpointID, "restore", current)
+
+	var restoreErrors []error
+	for key, ruleSet := range current {
+		unlock := m.policyLocks.lock(key)
+		request := hcn.PolicyEndpointRequest{Policies: ruleSet.Policies}
+		if err := m.client.RemoveEndpointPolicy(endpoint, hcn.RequestTypeRemove, request); err != nil {
+			unlock()
+			m.recordHNSError("remove", err)
+			restoreErrors = append(restoreErrors, fmt.Errorf("remove %s: %w", key, err))
+			continue
+		}
+		m.setEndpointTracking(key, endpointID, nil, nil)
+		unlock()
+	}
+
+	for key, ruleSet := range backup.Policies {
+		unlock := m.policyLocks.lock(key)
+		request := hcn.PolicyEndpointRequest{Policies: ruleSet.Policies}
+		if err := m.client.ApplyEndpointPolicy(endpoint, hcn.RequestTypeAdd, request); err != nil {
+			unlock()
+			m.recordHNSError("apply", err)
+			restoreErrors = append(restoreErrors, fmt.Errorf("apply %s: %w", key, err))
+			continue
+		}
+		m.setEndpointTracking(key, endpointID, ruleSet.Policies, ruleSet.Rules)
+		unlock()
+	}
+
+	if len(restoreErrors) > 0 {
+		return fmt.Errorf("failed to restore endpoint %s: %w", endpointID, errors.Join(restoreErrors...))
+	}
+	m.logger.Info("Restored endpoint ACLs; the endpoint is pinned until it is resynced",
+		"endpointID", endpointID,
+		"policyCount", len(backup.Policies))
+	return nil
+}
+
+// isPinned reports whether an endpoint holds a restored backup
+func (m *Manager) isPinned(endpointID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.pinned[endpointID]
+}
+
+// unpin returns an endpoint to normal policy syncs
+func (m *Manager) unpin(endpointID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pinned, endpointID)
+}
@@ -0,0 +1,184 @@
+//go:build windows
+
+package hcn
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+func TestBackupStore(t *testing.T) {
+	store, err := NewBackupStore(t.TempDir(), 2)
+	if err != nil {
+		t.Fatalf("NewBackupStore failed: %v", err)
+	}
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, reason := range []string{"replace", "remove", "resync"} {
+		backup := EndpointBackup{
+			EndpointID: "ep-1",
+			Time:       start.Add(time.Duration(i) * time.Minute),
+			Reason:     reason,
+			Policies:   map[string]BackupRuleSet{"default/web": {Rules: benchmarkRules(i + 1)}},
+		}
+		if _, err := store.Save(backup); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	backups, err := store.List("ep-1")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(backups) != 2 || backups[0].Reason != "resync" || backups[1].Reason != "remove" {
+		t.Fatalf("Expected the 2 newest backups, newest first, got %+v", backups)
+	}
+
+	latest, err := store.Load("ep-1", "")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(latest.Policies["default/web"].Rules) != 3 {
+		t.Errorf("Expected the newest backup, got %+v", latest)
+	}
+	older, err := store.Load("ep-1", backups[1].Name)
+	if err != nil || older.Reason != "remove" {
+		t.Errorf("Expected to load backup %s, got %+v (%v)", backups[1].Name, older, err)
+	}
+
+	if _, err := store.Load("ep-2", ""); !errors.Is(err, ErrBackupNotFound) {
+		t.Errorf("Expected ErrBackupNotFound, got %v", err)
+	}
+	if _, err := store.Load("..", ""); err == nil {
+		t.Error("Expected unsafe endpoint IDs to be rejected")
+	}
+	if _, err := store.Load("ep-1", `..\ep-2\x`); err == nil {
+		t.Error("Expected unsafe backup names to be rejected")
+	}
+}
+
+func TestRestoreEndpoint(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{{Id: "ep-1"}, {Id: "ep-2"}}
+	store, err := NewBackupStore(t.TempDir(), 5)
+	if err != nil {
+		t.Fatalf("NewBackupStore failed: %v", err)
+	}
+
+	manager := NewManager(mockClient, logr.Discard())
+	manager.SetBackupStore(store)
+
+	good := benchmarkRules(2)
+	if err := manager.ApplyACLRules("default/web", good); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	// Replacing the rules backs up the previous generation
+	broken := benchmarkRules(3)[1:]
+	if err := manager.ApplyACLRules("default/web", broken); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	backups, err := manager.ListBackups("ep-1")
+	if err != nil || len(backups) != 1 || backups[0].Reason != "replace" {
+		t.Fatalf("Expected one replace backup, got %+v (%v)", backups, err)
+	}
+
+	if err := manager.RestoreEndpoint("ep-1", ""); err != nil {
+		t.Fatalf("RestoreEndpoint failed: %v", err)
+	}
+	assertTrackedRules := func(endpointID string, want []ACLRule) {
+		t.Helper()
+		ruleSets, _ := manager.GetAppliedPolicies("default/web")
+		for _, ruleSet := range ruleSets {
+			if ruleSet.EndpointID != endpointID {
+				continue
+			}
+			if !rulesEqual(ruleSet.Rules, want) {
+				t.Errorf("Endpoint %s: expected rules %v, got %v", endpointID, want, ruleSet.Rules)
+			}
+			return
+		}
+		t.Errorf("Endpoint %s: no tracked rules", endpointID)
+	}
+	assertTrackedRules("ep-1", good)
+
+	// The pinned endpoint keeps the backup while the other follows the policy
+	if err := manager.ApplyACLRules("default/web", broken); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	assertTrackedRules("ep-1", good)
+	assertTrackedRules("ep-2", broken)
+
+	// Deleting the policy leaves the pinned endpoint for its resync
+	if err := manager.RemoveACLRules("default/web"); err != nil {
+		t.Fatalf("RemoveACLRules failed: %v", err)
+	}
+	assertTrackedRules("ep-1", good)
+
+	if err := manager.ForceResyncEndpoint("ep-1"); err != nil {
+		t.Fatalf("ForceResyncEndpoint failed: %v", err)
+	}
+	if ruleSets, _ := manager.GetAppliedPolicies("default/web"); len(ruleSets) != 0 {
+		t.Errorf("Expected the resync to drop the restored rules, got %v", ruleSets)
+	}
+}
+
+func TestRestoreEndpoint_Disabled(t *testing.T) {
+	manager := NewManager(newMockHCNClient(), logr.Discard())
+	if err := manager.RestoreEndpoint("ep-1", ""); !errors.Is(err, ErrBackupsDisabled) {
+		t.Errorf("Expected ErrBackupsDisabled, got %v", err)
+	}
+}
+
+func TestBackupStore_Encryption(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBackupStore(dir, 2)
+	if err != nil {
+		t.Fatalf("NewBackupStore failed: %v", err)
+	}
+	backup := EndpointBackup{
+		EndpointID: "ep-1",
+		Time:       time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Reason:     "remove",
+		Policies:   map[string]BackupRuleSet{"default/web": {Rules: benchmarkRules(1)}},
+	}
+	if _, err := store.Save(backup); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	store.SetEncryption(StateEncryptionUser)
+	backup.Time = backup.Time.Add(time.Minute)
+	name, err := store.Save(backup)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "ep-1", name+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isEncryptedState(data) || strings.Contains(string(data), "default/web") {
+		t.Fatal("Expected the backup to be encrypted")
+	}
+
+	// Both forms are read
+	backups, err := store.List("ep-1")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	for _, info := range backups {
+		loaded, err := store.Load("ep-1", info.Name)
+		if err != nil {
+			t.Fatalf("Load %s failed: %v", info.Name, err)
+		}
+		if len(loaded.Policies["default/web"].Rules) != 1 {
+			t.Errorf("Expected the backed-up rules of %s, got %+v", info.Name, loaded)
+		}
+	}
+}
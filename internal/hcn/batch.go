@@ -0,0 +1,60 @@
+//go:build windows
+
+package hcn
+
+import (
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// EndpointPolicyChange is the add or remove request of one endpoint in a batch
+type EndpointPolicyChange struct {
+	Endpoint    *hcn.HostComputeEndpoint
+	RequestType hcn.RequestType
+	Request     hcn.PolicyEndpointRequest
+}
+
+// BatchClient is implemented by HCNClients that can change the policies of
+// several endpoints in one call. HCN takes the policies of a single endpoint
+// per call today, so the production client does not implement it; once HNS
+// offers such a call only the client needs to change.
+type BatchClient interface {
+	// MaxBatchEndpoints is the most changes one ModifyEndpointPolicies call
+	// takes; 1 or less means the client cannot batch right now
+	MaxBatchEndpoints() int
+
+	// ModifyEndpointPolicies applies changes and returns an error per change,
+	// nil for those that went through
+	ModifyEndpointPolicies(changes []EndpointPolicyChange) []error
+}
+
+// modifyEndpoints applies changes through client's batch call, split into
+// batches of the size it accepts, or with a call per change when it cannot
+// batch. The returned errors line up with changes.
+func modifyEndpoints(client HCNClient, changes []EndpointPolicyChange) []error {
+	batcher, ok := client.(BatchClient)
+	size := 0
+	if ok {
+		size = batcher.MaxBatchEndpoints()
+	}
+	if size <= 1 {
+		errs := make([]error, len(changes))
+		for i, change := range changes {
+			errs[i] = modifyEndpoint(client, change)
+		}
+		return errs
+	}
+
+	errs := make([]error, 0, len(changes))
+	for start := 0; start < len(changes); start += size {
+		errs = append(errs, batcher.ModifyEndpointPolicies(changes[start:min(start+size, len(changes))])...)
+	}
+	return errs
+}
+
+// modifyEndpoint applies a single change with the per-endpoint calls of client
+func modifyEndpoint(client HCNClient, change EndpointPolicyChange) error {
+	if change.RequestType == hcn.RequestTypeRemove {
+		return client.RemoveEndpointPolicy(change.Endpoint, change.RequestType, change.Request)
+	}
+	return client.ApplyEndpointPolicy(change.Endpoint, change.RequestType, change.Request)
+}
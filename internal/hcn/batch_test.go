@@ -0,0 +1,50 @@
+//go:build windows
+
+package hcn
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestRemoveACLRules_Batched(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxBatch      int
+		maxInFlight   int
+		expectedCalls int
+	}{
+		{name: "no batching", maxBatch: 0, expectedCalls: 0},
+		{name: "batches of two", maxBatch: 2, expectedCalls: 3},
+		{name: "one batch", maxBatch: 10, expectedCalls: 1},
+		{name: "through the call limiter", maxBatch: 2, maxInFlight: 1, expectedCalls: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewFakeClient(5)
+			manager := NewManager(client, logr.Discard())
+			if err := manager.SetConcurrency(ConcurrencyOptions{EndpointWorkers: 1, MaxInFlightCalls: tt.maxInFlight}); err != nil {
+				t.Fatalf("SetConcurrency failed: %v", err)
+			}
+			if err := manager.ApplyACLRules("default/test", benchmarkRules(3)); err != nil {
+				t.Fatalf("ApplyACLRules failed: %v", err)
+			}
+
+			client.SetMaxBatchEndpoints(tt.maxBatch)
+			if err := manager.RemoveACLRules("default/test"); err != nil {
+				t.Fatalf("RemoveACLRules failed: %v", err)
+			}
+			if calls := client.BatchCalls(); calls != tt.expectedCalls {
+				t.Errorf("Expected %d batch calls, got %d", tt.expectedCalls, calls)
+			}
+			endpoints, _ := client.ListEndpoints()
+			for _, endpoint := range endpoints {
+				if len(endpoint.Policies) != 0 {
+					t.Errorf("Expected policies removed from %s, got %d", endpoint.Id, len(endpoint.Policies))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// ACLCapabilities are the optional AclPolicySetting fields the node's HNS
+// honors. Rules only use them when the matching capability is set, so newer
+// builds get richer matching while older ones keep the basic fields.
+type ACLCapabilities struct {
+	// LocalAddresses matches rules on the endpoint's own addresses, which
+	// tells apart the addresses of multi-IP and dual-stack endpoints
+	LocalAddresses bool
+
+	// RuleType enforces rules in the host (WFP) instead of the virtual switch (VFP)
+	RuleType bool
+}
+
+// Optional ACL fields named in --acl-features
+const (
+	// ACLFeatureLocalAddresses enables ACLCapabilities.LocalAddresses
+	ACLFeatureLocalAddresses = "local-addresses"
+
+	// ACLFeatureRuleType enables ACLCapabilities.RuleType
+	ACLFeatureRuleType = "rule-type"
+)
+
+// ParseACLFeatures parses an --acl-features value: a comma-separated list of
+// the optional fields the node's HNS honors. Empty or "basic" sends only the
+// fields every supported build accepts. The fields are not detected: HNS
+// reports its ACL features per version, and every version the agent supports
+// reports the flags that could stand for them, whether it honors the fields
+// or not.
+func ParseACLFeatures(value string) (ACLCapabilities, error) {
+	var capabilities ACLCapabilities
+	if value == "basic" {
+		return capabilities, nil
+	}
+	for _, feature := range strings.Split(value, ",") {
+		switch strings.TrimSpace(feature) {
+		case "":
+		case ACLFeatureLocalAddresses:
+			capabilities.LocalAddresses = true
+		case ACLFeatureRuleType:
+			capabilities.RuleType = true
+		default:
+			return ACLCapabilities{}, fmt.Errorf("invalid ACL feature %q: must be %s or %s",
+				feature, ACLFeatureLocalAddresses, ACLFeatureRuleType)
+		}
+	}
+	return capabilities, nil
+}
+
+// SetACLCapabilities sets the optional ACL fields HNS accepts.
+// It must be called before the Manager starts reconciling.
+func (m *Manager) SetACLCapabilities(capabilities ACLCapabilities) {
+	m.payloads.mu.Lock()
+	defer m.payloads.mu.Unlock()
+	m.payloads.capabilities = capabilities
+	m.payloads.payloads = make(map[payloadKey]json.RawMessage)
+}
+
+// validateCapabilities rejects rules using fields HNS does not accept. A
+// match criterion dropped from an allow or block rule would widen it, so such
+// rules fail instead of being sent without it. The rule type only selects
+// where the rule is enforced and is omitted when unsupported.
+func validateCapabilities(rule ACLRule, capabilities ACLCapabilities) error {
+	if rule.LocalAddresses != "" && !capabilities.LocalAddresses {
+		return fmt.Errorf("rule %q matches local addresses, which this HNS version does not support", rule.Name)
+	}
+	return nil
+}
+
+// ruleTypeFor returns the rule type sent for rule, empty for HNS's default
+func ruleTypeFor(rule ACLRule, capabilities ACLCapabilities) hcn.RuleType {
+	if !capabilities.RuleType {
+		return ""
+	}
+	return rule.RuleType
+}
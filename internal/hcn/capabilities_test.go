@@ -0,0 +1,78 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+func TestParseACLFeatures(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    ACLCapabilities
+		wantErr bool
+	}{
+		{value: "", want: ACLCapabilities{}},
+		{value: "basic", want: ACLCapabilities{}},
+		{value: "local-addresses", want: ACLCapabilities{LocalAddresses: true}},
+		{value: "local-addresses, rule-type", want: ACLCapabilities{LocalAddresses: true, RuleType: true}},
+		{value: "auto", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseACLFeatures(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseACLFeatures(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseACLFeatures(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSetACLCapabilities_SettingsPayload(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{{Id: "ep-1"}}
+	manager := NewManager(mockClient, logr.Discard())
+
+	rules := benchmarkRules(1)
+	rules[0].LocalAddresses = "10.0.0.5"
+	rules[0].RuleType = hcn.RuleTypeHost
+
+	// Older builds must not receive a rule wider than written
+	if err := manager.ApplyACLRules("default/test", rules); err == nil {
+		t.Fatal("Expected a rule matching local addresses to fail without the capability")
+	}
+
+	manager.SetACLCapabilities(ACLCapabilities{LocalAddresses: true})
+	if err := manager.ApplyACLRules("default/test", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	var setting hcn.AclPolicySetting
+	if err := json.Unmarshal(mockClient.appliedPolicies["ep-1"][0].Settings, &setting); err != nil {
+		t.Fatalf("Failed to decode settings: %v", err)
+	}
+	if setting.LocalAddresses != "10.0.0.5" || setting.RuleType != "" {
+		t.Errorf("Expected local addresses without rule type, got %q %q", setting.LocalAddresses, setting.RuleType)
+	}
+
+	manager.SetACLCapabilities(ACLCapabilities{LocalAddresses: true, RuleType: true})
+	payload, err := manager.payloads.get(rules[0])
+	if err != nil {
+		t.Fatalf("Failed to build payload: %v", err)
+	}
+	if err := json.Unmarshal(payload, &setting); err != nil {
+		t.Fatalf("Failed to decode settings: %v", err)
+	}
+	if setting.RuleType != hcn.RuleTypeHost {
+		t.Errorf("Expected the Host rule type, got %q", setting.RuleType)
+	}
+	if rule := ruleFromPolicy(hcn.EndpointPolicy{Type: hcn.ACL, Settings: payload}); rule.LocalAddresses != "10.0.0.5" || rule.RuleType != hcn.RuleTypeHost {
+		t.Errorf("Expected the new fields read back, got %+v", rule)
+	}
+}
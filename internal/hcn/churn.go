@@ -0,0 +1,86 @@
+//go:build windows
+
+package hcn
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// churnMetrics counts the ACL policies added to and removed from endpoints,
+// by policy key and by endpoint. rate() over them shows which policies and
+// workloads (CI namespaces, crash-looping pods) thrash HNS.
+type churnMetrics struct {
+	byPolicy   *prometheus.CounterVec
+	byEndpoint *prometheus.CounterVec
+
+	// mu protects endpoints
+	mu sync.Mutex
+
+	// endpoints are the endpoint IDs with exported series
+	endpoints map[string]bool
+}
+
+func newChurnMetrics() *churnMetrics {
+	return &churnMetrics{
+		byPolicy: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "networkpolicy_agent",
+			Subsystem: "hcn",
+			Name:      "policy_rule_changes_total",
+			Help:      "Number of ACL policies added to or removed from endpoints, by policy key.",
+		}, []string{"policy", "operation"}),
+		byEndpoint: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "networkpolicy_agent",
+			Subsystem: "hcn",
+			Name:      "endpoint_rule_changes_total",
+			Help:      "Number of ACL policies added to or removed from endpoints, by endpoint.",
+		}, []string{"endpoint", "operation"}),
+		endpoints: make(map[string]bool),
+	}
+}
+
+// record counts the policies added to and removed from endpointID for policyKey
+func (c *churnMetrics) record(policyKey, endpointID string, added, removed int) {
+	if added == 0 && removed == 0 {
+		return
+	}
+	c.mu.Lock()
+	c.endpoints[endpointID] = true
+	c.mu.Unlock()
+	for operation, count := range map[string]int{"add": added, "remove": removed} {
+		if count > 0 {
+			c.byPolicy.WithLabelValues(policyKey, operation).Add(float64(count))
+			c.byEndpoint.WithLabelValues(endpointID, operation).Add(float64(count))
+		}
+	}
+}
+
+// forgetPolicy drops the series of a policy that is no longer programmed anywhere
+func (c *churnMetrics) forgetPolicy(policyKey string) {
+	c.byPolicy.DeletePartialMatch(prometheus.Labels{"policy": policyKey})
+}
+
+// prune drops the series of endpoints that no longer exist
+func (c *churnMetrics) prune(live map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for endpointID := range c.endpoints {
+		if !live[endpointID] {
+			c.byEndpoint.DeletePartialMatch(prometheus.Labels{"endpoint": endpointID})
+			delete(c.endpoints, endpointID)
+		}
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *churnMetrics) Describe(ch chan<- *prometheus.Desc) {
+	c.byPolicy.Describe(ch)
+	c.byEndpoint.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *churnMetrics) Collect(ch chan<- prometheus.Metric) {
+	c.byPolicy.Collect(ch)
+	c.byEndpoint.Collect(ch)
+}
@@ -0,0 +1,243 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// PriorityCollisionMode selects what happens to a controller rule whose
+// direction and priority are taken on an endpoint by an ACL added out of band,
+// e.g. by an operator. HNS evaluates ACLs of equal priority in no fixed order,
+// so programming the rule as generated would layer it unpredictably.
+type PriorityCollisionMode string
+
+const (
+	// PriorityCollisionRemap moves the rule to the next priority free on the endpoint
+	PriorityCollisionRemap PriorityCollisionMode = "remap"
+
+	// PriorityCollisionFail stops programming the policy on the endpoint and fails its sync
+	PriorityCollisionFail PriorityCollisionMode = "fail"
+)
+
+// ParsePriorityCollisionMode parses a --priority-collisions value
+func ParsePriorityCollisionMode(value string) (PriorityCollisionMode, error) {
+	switch mode := PriorityCollisionMode(value); mode {
+	case PriorityCollisionRemap, PriorityCollisionFail:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid priority collision mode %q: must be remap or fail", value)
+	}
+}
+
+// ErrPriorityCollision is returned for endpoints a policy is not programmed
+// on because an out-of-band ACL holds the priority of one of its rules
+var ErrPriorityCollision = errors.New("ACL priority taken by an out-of-band rule")
+
+// PriorityCollision is a desired rule sharing its direction and priority
+// with an out-of-band ACL on an endpoint
+type PriorityCollision struct {
+	EndpointID string            `json:"endpointID"`
+	PolicyKey  string            `json:"policyKey"`
+	Rule       string            `json:"rule"`
+	Direction  hcn.DirectionType `json:"direction"`
+	Priority   uint16            `json:"priority"`
+
+	// RemappedTo is the priority the rule is programmed at instead; 0 when it is not remapped
+	RemappedTo uint16 `json:"remappedTo,omitempty"`
+}
+
+// prioritySlot is a direction and priority an ACL is evaluated at
+type prioritySlot struct {
+	direction hcn.DirectionType
+	priority  uint16
+}
+
+// foreignACLs holds the slots taken by out-of-band ACLs as of the last drift check
+type foreignACLs struct {
+	mu   sync.RWMutex
+	mode PriorityCollisionMode
+
+	// slots maps endpoint ID -> slots of the ACLs the Manager did not program
+	slots map[string]map[prioritySlot]bool
+
+	// collisions are the collisions found by the last drift check
+	collisions []PriorityCollision
+}
+
+// SetPriorityCollisionMode selects how rules colliding with out-of-band ACLs
+// are handled. It must be called before the Manager starts reconciling.
+func (m *Manager) SetPriorityCollisionMode(mode PriorityCollisionMode) {
+	m.foreign.mu.Lock()
+	defer m.foreign.mu.Unlock()
+	m.foreign.mode = mode
+}
+
+// PriorityCollisions returns the collisions found by the last drift check
+func (m *Manager) PriorityCollisions() []PriorityCollision {
+	m.foreign.mu.RLock()
+	defer m.foreign.mu.RUnlock()
+	return append([]PriorityCollision(nil), m.foreign.collisions...)
+}
+
+// scanForeignACLs records the slots of the ACLs on endpoints that the Manager
+// did not program, logs the collisions not seen by the previous scan and
+// requeues the policies they affect. Endpoints pinned to a backup are skipped.
+func (m *Manager) scanForeignACLs(endpoints []hcn.HostComputeEndpoint) {
+	owned := make(map[string]map[string]bool)
+	for _, key := range m.trackedKeys() {
+		ruleSets, _ := m.trackedRuleSets(key)
+		for _, ruleSet := range ruleSets {
+			if owned[ruleSet.EndpointID] == nil {
+				owned[ruleSet.EndpointID] = make(map[string]bool)
+			}
+			for _, policy := range ruleSet.Policies {
+				owned[ruleSet.EndpointID][string(policy.Settings)] = true
+			}
+		}
+	}
+
+	slots := make(map[string]map[prioritySlot]bool)
+	for _, endpoint := range endpoints {
+		if m.isPinned(endpoint.Id) {
+			continue
+		}
+		for _, policy := range endpoint.Policies {
+			if policy.Type != hcn.ACL || owned[endpoint.Id][string(policy.Settings)] {
+				continue
+			}
+			var setting hcn.AclPolicySetting
+			if err := json.Unmarshal(policy.Settings, &setting); err != nil {
+				m.logger.V(1).Info("Skipping unparsable ACL on endpoint", "endpointID", endpoint.Id, "error", err.Error())
+				continue
+			}
+			if slots[endpoint.Id] == nil {
+				slots[endpoint.Id] = make(map[prioritySlot]bool)
+			}
+			slots[endpoint.Id][prioritySlot{direction: setting.Direction, priority: setting.Priority}] = true
+		}
+	}
+
+	m.foreign.mu.Lock()
+	m.foreign.slots = slots
+	previous := m.foreign.collisions
+	m.foreign.mu.Unlock()
+
+	var collisions []PriorityCollision
+	for _, endpoint := range endpoints {
+		found := m.resolveCollisions(endpoint.Id, m.providerRulesFor(endpoint))
+		collisions = append(collisions, found...)
+	}
+
+	m.foreign.mu.Lock()
+	m.foreign.collisions = collisions
+	m.foreign.mu.Unlock()
+
+	seen := make(map[PriorityCollision]bool, len(previous))
+	for _, collision := range previous {
+		seen[collision] = true
+	}
+	for _, collision := range collisions {
+		if seen[collision] {
+			continue
+		}
+		m.logger.Info("Out-of-band ACL holds the priority of a controller rule",
+			"policyKey", collision.PolicyKey,
+			"rule", collision.Rule,
+			"endpointID", collision.EndpointID,
+			"direction", collision.Direction,
+			"priority", collision.Priority,
+			"remappedTo", collision.RemappedTo)
+		m.requestRequeue(collision.PolicyKey, RequeuePriorityCollision)
+	}
+}
+
+// resolveCollisions finds the rules of an endpoint's desired table colliding
+// with its out-of-band ACLs. In PriorityCollisionRemap mode they are moved, in
+// table itself, to the next priority taken by neither an out-of-band ACL nor
+// another rule of the table.
+func (m *Manager) resolveCollisions(endpointID string, table map[string][]ACLRule) []PriorityCollision {
+	m.foreign.mu.RLock()
+	foreign := m.foreign.slots[endpointID]
+	remap := m.foreign.mode == PriorityCollisionRemap
+	m.foreign.mu.RUnlock()
+	if len(foreign) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(table))
+	used := make(map[prioritySlot]bool, len(foreign))
+	for slot := range foreign {
+		used[slot] = true
+	}
+	for key, rules := range table {
+		keys = append(keys, key)
+		for _, rule := range rules {
+			used[prioritySlot{direction: rule.Direction, priority: rule.Priority}] = true
+		}
+	}
+	sort.Strings(keys)
+
+	var collisions []PriorityCollision
+	for _, key := range keys {
+		rules := table[key]
+		remapped := false
+		for i, rule := range rules {
+			if rule.Priority == 0 || !foreign[prioritySlot{direction: rule.Direction, priority: rule.Priority}] {
+				continue
+			}
+			collision := PriorityCollision{
+				EndpointID: endpointID,
+				PolicyKey:  key,
+				Rule:       rule.Name,
+				Direction:  rule.Direction,
+				Priority:   rule.Priority,
+			}
+			if remap {
+				for priority := uint32(rule.Priority) + 1; priority <= uint32(MaxPriority); priority++ {
+					slot := prioritySlot{direction: rule.Direction, priority: uint16(priority)}
+					if used[slot] {
+						continue
+					}
+					used[slot] = true
+					if !remapped {
+						// Provider slices are shared; remap a copy
+						rules = append([]ACLRule(nil), rules...)
+						remapped = true
+					}
+					rules[i].Priority = slot.priority
+					collision.RemappedTo = slot.priority
+					break
+				}
+			}
+			collisions = append(collisions, collision)
+		}
+		if remapped {
+			table[key] = rules
+		}
+	}
+	return collisions
+}
+
+// collisionError returns ErrPriorityCollision when, in PriorityCollisionFail
+// mode, one of rules collides with an out-of-band ACL on the endpoint
+func (m *Manager) collisionError(endpointID string, rules []ACLRule) error {
+	m.foreign.mu.RLock()
+	defer m.foreign.mu.RUnlock()
+	if m.foreign.mode != PriorityCollisionFail {
+		return nil
+	}
+	foreign := m.foreign.slots[endpointID]
+	for _, rule := range rules {
+		if rule.Priority != 0 && foreign[prioritySlot{direction: rule.Direction, priority: rule.Priority}] {
+			return fmt.Errorf("%w: rule %s, %s priority %d", ErrPriorityCollision, rule.Name, rule.Direction, rule.Priority)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+// addOutOfBandACL programs an ACL on the fake endpoint behind the Manager's back
+func addOutOfBandACL(t *testing.T, client *FakeClient, endpointID string, priority uint16) hcn.EndpointPolicy {
+	t.Helper()
+	settings, err := json.Marshal(hcn.AclPolicySetting{
+		Action:    hcn.ActionTypeBlock,
+		Direction: hcn.DirectionTypeIn,
+		Priority:  priority,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := hcn.EndpointPolicy{Type: hcn.ACL, Settings: settings}
+	endpoint, err := client.GetEndpointByID(endpointID)
+	if err != nil {
+		t.Fatalf("GetEndpointByID failed: %v", err)
+	}
+	request := hcn.PolicyEndpointRequest{Policies: []hcn.EndpointPolicy{policy}}
+	if err := client.ApplyEndpointPolicy(endpoint, hcn.RequestTypeAdd, request); err != nil {
+		t.Fatalf("ApplyEndpointPolicy failed: %v", err)
+	}
+	return policy
+}
+
+// programmedPriorities returns the sorted ACL priorities on an endpoint
+func programmedPriorities(t *testing.T, client *FakeClient, endpointID string) []uint16 {
+	t.Helper()
+	endpoint, err := client.GetEndpointByID(endpointID)
+	if err != nil {
+		t.Fatalf("GetEndpointByID failed: %v", err)
+	}
+	var priorities []uint16
+	for _, policy := range endpoint.Policies {
+		var setting hcn.AclPolicySetting
+		if err := json.Unmarshal(policy.Settings, &setting); err != nil {
+			t.Fatal(err)
+		}
+		priorities = append(priorities, setting.Priority)
+	}
+	sort.Slice(priorities, func(i, j int) bool { return priorities[i] < priorities[j] })
+	return priorities
+}
+
+func equalPriorities(a, b []uint16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParsePriorityCollisionMode(t *testing.T) {
+	for _, value := range []string{"remap", "fail"} {
+		if mode, err := ParsePriorityCollisionMode(value); err != nil || string(mode) != value {
+			t.Errorf("ParsePriorityCollisionMode(%q) = %q, %v", value, mode, err)
+		}
+	}
+	if _, err := ParsePriorityCollisionMode("overwrite"); err == nil {
+		t.Error("Expected an invalid mode to be rejected")
+	}
+}
+
+func TestRepairDrift_RemapsAroundOutOfBandACL(t *testing.T) {
+	client := NewFakeClient(1)
+	manager := NewManager(client, logr.Discard())
+	if err := manager.ApplyACLRules("default/web", benchmarkRules(2)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	// An operator adds a rule at the priority of the first generated one
+	foreign := addOutOfBandACL(t, client, "fake-endpoint-0", 100)
+	if err := manager.RepairDrift(); err != nil {
+		t.Fatalf("RepairDrift failed: %v", err)
+	}
+	if got := programmedPriorities(t, client, "fake-endpoint-0"); !equalPriorities(got, []uint16{100, 101, 102}) {
+		t.Errorf("Expected the colliding rule moved to 102, got priorities %v", got)
+	}
+	collisions := manager.PriorityCollisions()
+	if len(collisions) != 1 || collisions[0].PolicyKey != "default/web" || collisions[0].RemappedTo != 102 {
+		t.Fatalf("Expected one collision remapped to 102, got %+v", collisions)
+	}
+
+	// Once the operator's rule is gone the generated priority is restored
+	endpoint, _ := client.GetEndpointByID("fake-endpoint-0")
+	request := hcn.PolicyEndpointRequest{Policies: []hcn.EndpointPolicy{foreign}}
+	if err := client.RemoveEndpointPolicy(endpoint, hcn.RequestTypeRemove, request); err != nil {
+		t.Fatalf("RemoveEndpointPolicy failed: %v", err)
+	}
+	if err := manager.RepairDrift(); err != nil {
+		t.Fatalf("RepairDrift failed: %v", err)
+	}
+	if got := programmedPriorities(t, client, "fake-endpoint-0"); !equalPriorities(got, []uint16{100, 101}) {
+		t.Errorf("Expected the generated priorities restored, got %v", got)
+	}
+	if collisions := manager.PriorityCollisions(); len(collisions) != 0 {
+		t.Errorf("Expected no collisions left, got %+v", collisions)
+	}
+}
+
+func TestRepairDrift_FailsOnOutOfBandACL(t *testing.T) {
+	client := NewFakeClient(1)
+	manager := NewManager(client, logr.Discard())
+	manager.SetPriorityCollisionMode(PriorityCollisionFail)
+	addOutOfBandACL(t, client, "fake-endpoint-0", 101)
+	if err := manager.RepairDrift(); err != nil {
+		t.Fatalf("RepairDrift failed: %v", err)
+	}
+
+	err := manager.ApplyACLRules("default/web", benchmarkRules(2))
+	if !errors.Is(err, ErrPriorityCollision) {
+		t.Fatalf("Expected ErrPriorityCollision, got %v", err)
+	}
+	if got := programmedPriorities(t, client, "fake-endpoint-0"); !equalPriorities(got, []uint16{101}) {
+		t.Errorf("Expected only the out-of-band ACL programmed, got %v", got)
+	}
+}
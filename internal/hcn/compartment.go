@@ -0,0 +1,95 @@
+//go:build windows
+
+package hcn
+
+import (
+	"fmt"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// listCompartmentEndpoints merges the default endpoint listing with the endpoints
+// attached to each HNS namespace, optionally restricted to ClientOptions.Namespaces.
+// Endpoints are deduplicated by ID.
+func (c *realHCNClient) listCompartmentEndpoints() ([]hcn.HostComputeEndpoint, error) {
+	endpoints, err := c.listEndpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]hcn.HostComputeEndpoint, len(endpoints))
+	order := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if _, exists := byID[endpoint.Id]; !exists {
+			order = append(order, endpoint.Id)
+		}
+		byID[endpoint.Id] = endpoint
+	}
+
+	namespaceIDs, err := c.targetNamespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, namespaceID := range namespaceIDs {
+		endpointIDs, err := c.namespaceEndpointIDs(namespaceID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list endpoints of HNS namespace %s: %w", namespaceID, err)
+		}
+		for _, endpointID := range endpointIDs {
+			if _, exists := byID[endpointID]; exists {
+				continue
+			}
+			endpoint, err := c.getEndpointByIDFromHCN(endpointID)
+			if err != nil {
+				if hcn.IsNotFoundError(err) {
+					// Endpoint was torn down between listing the namespace and fetching it
+					continue
+				}
+				return nil, fmt.Errorf("failed to get endpoint %s of HNS namespace %s: %w", endpointID, namespaceID, err)
+			}
+			byID[endpoint.Id] = *endpoint
+			order = append(order, endpoint.Id)
+		}
+	}
+
+	result := make([]hcn.HostComputeEndpoint, 0, len(order))
+	for _, id := range order {
+		endpoint := byID[id]
+		if !c.inTargetNamespace(endpoint) {
+			continue
+		}
+		result = append(result, endpoint)
+	}
+	return result, nil
+}
+
+// targetNamespaces returns the HNS namespace IDs whose endpoints should be enumerated
+func (c *realHCNClient) targetNamespaces() ([]string, error) {
+	if len(c.opts.Namespaces) > 0 {
+		return c.opts.Namespaces, nil
+	}
+
+	namespaces, err := c.listNamespaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list HNS namespaces: %w", err)
+	}
+	ids := make([]string, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		ids = append(ids, namespace.Id)
+	}
+	return ids, nil
+}
+
+// inTargetNamespace reports whether the endpoint belongs to a configured namespace
+func (c *realHCNClient) inTargetNamespace(endpoint hcn.HostComputeEndpoint) bool {
+	if len(c.opts.Namespaces) == 0 {
+		return true
+	}
+	for _, namespaceID := range c.opts.Namespaces {
+		if endpoint.HostComputeNamespace == namespaceID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+//go:build windows
+
+package hcn
+
+import (
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// newFakeCompartmentClient builds a realHCNClient backed by in-memory HNS data
+func newFakeCompartmentClient(opts ClientOptions, defaultList []hcn.HostComputeEndpoint, namespaces map[string][]hcn.HostComputeEndpoint) *realHCNClient {
+	all := make(map[string]hcn.HostComputeEndpoint)
+	for _, endpoint := range defaultList {
+		all[endpoint.Id] = endpoint
+	}
+	for namespaceID, endpoints := range namespaces {
+		for _, endpoint := range endpoints {
+			endpoint.HostComputeNamespace = namespaceID
+			all[endpoint.Id] = endpoint
+		}
+	}
+
+	return &realHCNClient{
+		opts: opts,
+		listEndpoints: func() ([]hcn.HostComputeEndpoint, error) {
+			return defaultList, nil
+		},
+		listNamespaces: func() ([]hcn.HostComputeNamespace, error) {
+			var result []hcn.HostComputeNamespace
+			for id := range namespaces {
+				result = append(result, hcn.HostComputeNamespace{Id: id})
+			}
+			return result, nil
+		},
+		namespaceEndpointIDs: func(namespaceID string) ([]string, error) {
+			var ids []string
+			for _, endpoint := range namespaces[namespaceID] {
+				ids = append(ids, endpoint.Id)
+			}
+			return ids, nil
+		},
+		getEndpointByIDFromHCN: func(id string) (*hcn.HostComputeEndpoint, error) {
+			endpoint, exists := all[id]
+			if !exists {
+				return nil, hcn.EndpointNotFoundError{EndpointID: id}
+			}
+			return &endpoint, nil
+		},
+	}
+}
+
+func TestListEndpoints_IncludesNamespaceEndpoints(t *testing.T) {
+	client := newFakeCompartmentClient(
+		ClientOptions{IncludeNamespaceEndpoints: true},
+		[]hcn.HostComputeEndpoint{{Id: "ep-1", HostComputeNamespace: "ns-a"}},
+		map[string][]hcn.HostComputeEndpoint{
+			"ns-a": {{Id: "ep-1"}},
+			"ns-b": {{Id: "ep-2"}},
+		},
+	)
+
+	endpoints, err := client.ListEndpoints()
+	if err != nil {
+		t.Fatalf("ListEndpoints failed: %v", err)
+	}
+	if len(endpoints) != 2 {
+		t.Fatalf("Expected 2 deduplicated endpoints, got %d", len(endpoints))
+	}
+}
+
+func TestListEndpoints_RestrictedToNamespaces(t *testing.T) {
+	client := newFakeCompartmentClient(
+		ClientOptions{Namespaces: []string{"ns-b"}},
+		[]hcn.HostComputeEndpoint{{Id: "ep-1", HostComputeNamespace: "ns-a"}},
+		map[string][]hcn.HostComputeEndpoint{
+			"ns-a": {{Id: "ep-1"}},
+			"ns-b": {{Id: "ep-2"}},
+		},
+	)
+
+	endpoints, err := client.ListEndpoints()
+	if err != nil {
+		t.Fatalf("ListEndpoints failed: %v", err)
+	}
+	if len(endpoints) != 1 || endpoints[0].Id != "ep-2" {
+		t.Errorf("Expected only ep-2 from ns-b, got %+v", endpoints)
+	}
+}
+
+func TestListEndpoints_DefaultSkipsNamespaces(t *testing.T) {
+	client := newFakeCompartmentClient(
+		ClientOptions{},
+		[]hcn.HostComputeEndpoint{{Id: "ep-1"}},
+		map[string][]hcn.HostComputeEndpoint{"ns-b": {{Id: "ep-2"}}},
+	)
+
+	endpoints, err := client.ListEndpoints()
+	if err != nil {
+		t.Fatalf("ListEndpoints failed: %v", err)
+	}
+	if len(endpoints) != 1 {
+		t.Errorf("Expected default listing only, got %d endpoints", len(endpoints))
+	}
+}
@@ -0,0 +1,146 @@
+//go:build windows
+
+package hcn
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// ConcurrencyOptions tunes how much HCN work the Manager issues in parallel.
+// Small nodes are fastest sequentially; nodes with hundreds of endpoints spend
+// most of a sync waiting on HNS and benefit from programming endpoints in parallel.
+type ConcurrencyOptions struct {
+	// EndpointWorkers is how many endpoints a single policy sync programs in
+	// parallel; 1 programs them one after another
+	EndpointWorkers int
+
+	// MaxInFlightCalls caps the HCN calls in flight across all syncs, bounding
+	// the load on HNS when several policies sync at once; 0 is unlimited
+	MaxInFlightCalls int
+}
+
+// DefaultConcurrencyOptions returns the sequential behaviour of the Manager
+func DefaultConcurrencyOptions() ConcurrencyOptions {
+	return ConcurrencyOptions{EndpointWorkers: 1}
+}
+
+// Validate checks the options for out of range values
+func (o ConcurrencyOptions) Validate() error {
+	if o.EndpointWorkers < 1 {
+		return fmt.Errorf("invalid endpoint workers %d: must be at least 1", o.EndpointWorkers)
+	}
+	if o.MaxInFlightCalls < 0 {
+		return fmt.Errorf("invalid max in-flight HCN calls %d: must be 0 (unlimited) or positive", o.MaxInFlightCalls)
+	}
+	return nil
+}
+
+// SetConcurrency configures parallel endpoint programming.
+// It must be called before the Manager starts reconciling.
+func (m *Manager) SetConcurrency(opts ConcurrencyOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	client := m.client
+	if limited, ok := client.(*limitedClient); ok {
+		client = limited.HCNClient
+	}
+	if opts.MaxInFlightCalls > 0 {
+		client = &limitedClient{HCNClient: client, slots: make(chan struct{}, opts.MaxInFlightCalls)}
+	}
+
+	m.client = client
+	m.concurrency = opts
+	m.logger.Info("Configured HCN concurrency",
+		"endpointWorkers", opts.EndpointWorkers,
+		"maxInFlightCalls", opts.MaxInFlightCalls)
+	return nil
+}
+
+// forEachEndpoint calls fn for every index in [0, n), running up to
+// EndpointWorkers calls at once. No further calls start once ctx is done.
+// It returns once all started calls have finished.
+func (m *Manager) forEachEndpoint(ctx context.Context, n int, fn func(i int)) {
+	workers := min(m.concurrency.EndpointWorkers, n)
+	if workers <= 1 {
+		for i := 0; i < n && ctx.Err() == nil; i++ {
+			fn(i)
+		}
+		return
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// limitedClient bounds the number of concurrent calls to the wrapped HCNClient
+type limitedClient struct {
+	HCNClient
+	slots chan struct{}
+}
+
+// acquire blocks until a call slot is free and returns its release function
+func (c *limitedClient) acquire() func() {
+	c.slots <- struct{}{}
+	return func() { <-c.slots }
+}
+
+// ListEndpoints implements HCNClient
+func (c *limitedClient) ListEndpoints() ([]hcn.HostComputeEndpoint, error) {
+	defer c.acquire()()
+	return c.HCNClient.ListEndpoints()
+}
+
+// GetEndpointByID implements HCNClient
+func (c *limitedClient) GetEndpointByID(id string) (*hcn.HostComputeEndpoint, error) {
+	defer c.acquire()()
+	return c.HCNClient.GetEndpointByID(id)
+}
+
+// ApplyEndpointPolicy implements HCNClient
+func (c *limitedClient) ApplyEndpointPolicy(endpoint *hcn.HostComputeEndpoint, requestType hcn.RequestType, request hcn.PolicyEndpointRequest) error {
+	defer c.acquire()()
+	return c.HCNClient.ApplyEndpointPolicy(endpoint, requestType, request)
+}
+
+// RemoveEndpointPolicy implements HCNClient
+func (c *limitedClient) RemoveEndpointPolicy(endpoint *hcn.HostComputeEndpoint, requestType hcn.RequestType, request hcn.PolicyEndpointRequest) error {
+	defer c.acquire()()
+	return c.HCNClient.RemoveEndpointPolicy(endpoint, requestType, request)
+}
+
+// MaxBatchEndpoints implements BatchClient for the wrapped client
+func (c *limitedClient) MaxBatchEndpoints() int {
+	if batcher, ok := c.HCNClient.(BatchClient); ok {
+		return batcher.MaxBatchEndpoints()
+	}
+	return 0
+}
+
+// ModifyEndpointPolicies implements BatchClient; a batch takes one call slot
+func (c *limitedClient) ModifyEndpointPolicies(changes []EndpointPolicyChange) []error {
+	defer c.acquire()()
+	return c.HCNClient.(BatchClient).ModifyEndpointPolicies(changes)
+}
@@ -0,0 +1,97 @@
+//go:build windows
+
+package hcn
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+// slowClient delays policy calls and records the peak number in flight
+type slowClient struct {
+	*FakeClient
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (c *slowClient) ApplyEndpointPolicy(endpoint *hcn.HostComputeEndpoint, requestType hcn.RequestType, request hcn.PolicyEndpointRequest) error {
+	c.mu.Lock()
+	c.inFlight++
+	c.peak = max(c.peak, c.inFlight)
+	c.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	c.mu.Lock()
+	c.inFlight--
+	c.mu.Unlock()
+	return c.FakeClient.ApplyEndpointPolicy(endpoint, requestType, request)
+}
+
+func TestConcurrencyOptions_Validate(t *testing.T) {
+	if err := DefaultConcurrencyOptions().Validate(); err != nil {
+		t.Errorf("Expected default options to be valid, got %v", err)
+	}
+	for _, opts := range []ConcurrencyOptions{
+		{EndpointWorkers: 0},
+		{EndpointWorkers: 1, MaxInFlightCalls: -1},
+	} {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", opts)
+		}
+	}
+}
+
+func TestSetConcurrency_ParallelEndpoints(t *testing.T) {
+	client := &slowClient{FakeClient: NewFakeClient(8)}
+	manager := NewManager(client, logr.Discard())
+	if err := manager.SetConcurrency(ConcurrencyOptions{EndpointWorkers: 4}); err != nil {
+		t.Fatalf("SetConcurrency failed: %v", err)
+	}
+
+	if err := manager.ApplyACLRules("default/test", benchmarkRules(2)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	if client.peak < 2 || client.peak > 4 {
+		t.Errorf("Expected 2-4 concurrent applies, got %d", client.peak)
+	}
+
+	// Tracking keeps the listing order regardless of which worker finished first
+	ruleSets, _ := manager.GetAppliedPolicies("default/test")
+	endpoints, _ := client.ListEndpoints()
+	if len(ruleSets) != len(endpoints) {
+		t.Fatalf("Expected %d tracked endpoints, got %d", len(endpoints), len(ruleSets))
+	}
+	for i, ruleSet := range ruleSets {
+		if ruleSet.EndpointID != endpoints[i].Id || len(ruleSet.Policies) != 2 {
+			t.Errorf("Rule set %d: expected 2 policies on %s, got %+v", i, endpoints[i].Id, ruleSet)
+		}
+	}
+}
+
+func TestSetConcurrency_MaxInFlightCalls(t *testing.T) {
+	client := &slowClient{FakeClient: NewFakeClient(8)}
+	manager := NewManager(client, logr.Discard())
+	if err := manager.SetConcurrency(ConcurrencyOptions{EndpointWorkers: 8, MaxInFlightCalls: 2}); err != nil {
+		t.Fatalf("SetConcurrency failed: %v", err)
+	}
+	// Reconfiguring replaces the limit rather than stacking another one
+	if err := manager.SetConcurrency(ConcurrencyOptions{EndpointWorkers: 8, MaxInFlightCalls: 3}); err != nil {
+		t.Fatalf("SetConcurrency failed: %v", err)
+	}
+	if limited, ok := manager.client.(*limitedClient); !ok || limited.HCNClient != client {
+		t.Fatalf("Expected the client wrapped once, got %T", manager.client)
+	}
+
+	if err := manager.ApplyACLRules("default/test", benchmarkRules(1)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	if client.peak > 3 {
+		t.Errorf("Expected at most 3 HCN calls in flight, got %d", client.peak)
+	}
+}
@@ -0,0 +1,159 @@
+//go:build windows
+
+package hcn
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// DesiredState holds the complete set of ACL rules the controller wants programmed
+// on the node, keyed by the source that produced them (e.g. "namespace/name" for a
+// NetworkPolicy). It is the single source of truth the Manager reconciles live HCN
+// state toward.
+type DesiredState struct {
+	mu sync.RWMutex
+
+	// policies maps policyKey -> desired ACL rules for that source
+	policies map[string][]ACLRule
+
+	// targets maps policyKey -> the endpoint IDs its rules are limited to;
+	// policies without an entry target every endpoint
+	targets map[string]map[string]bool
+
+	// addressTargets maps policyKey -> the IPs of the endpoints its rules are
+	// limited to; policies without an entry target every endpoint
+	addressTargets map[string]map[string]bool
+}
+
+// NewDesiredState creates an empty desired state cache
+func NewDesiredState() *DesiredState {
+	return &DesiredState{
+		policies:       make(map[string][]ACLRule),
+		targets:        make(map[string]map[string]bool),
+		addressTargets: make(map[string]map[string]bool),
+	}
+}
+
+// Set records the desired rules for a policy key, replacing any previous entry
+func (d *DesiredState) Set(policyKey string, rules []ACLRule) {
+	stored := make([]ACLRule, len(rules))
+	copy(stored, rules)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.policies[policyKey] = stored
+	delete(d.targets, policyKey)
+	delete(d.addressTargets, policyKey)
+}
+
+// SetForEndpoints records the desired rules for a policy key like Set, limited
+// to the given endpoint IDs
+func (d *DesiredState) SetForEndpoints(policyKey string, rules []ACLRule, endpointIDs []string) {
+	stored := make([]ACLRule, len(rules))
+	copy(stored, rules)
+	targets := make(map[string]bool, len(endpointIDs))
+	for _, endpointID := range endpointIDs {
+		targets[endpointID] = true
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.policies[policyKey] = stored
+	d.targets[policyKey] = targets
+	delete(d.addressTargets, policyKey)
+}
+
+// SetForAddresses records the desired rules for a policy key like Set, limited
+// to the endpoints owning one of the given IPs. Unlike SetForEndpoints the
+// targets need not be resolved to endpoints first, so an endpoint created
+// later for one of the IPs is programmed once it is listed.
+func (d *DesiredState) SetForAddresses(policyKey string, rules []ACLRule, addresses []string) {
+	stored := make([]ACLRule, len(rules))
+	copy(stored, rules)
+	targets := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		targets[address] = true
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.policies[policyKey] = stored
+	d.addressTargets[policyKey] = targets
+	delete(d.targets, policyKey)
+}
+
+// Delete removes the desired rules for a policy key
+func (d *DesiredState) Delete(policyKey string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.policies, policyKey)
+	delete(d.targets, policyKey)
+	delete(d.addressTargets, policyKey)
+}
+
+// Get returns a copy of the desired rules for a policy key
+func (d *DesiredState) Get(policyKey string) ([]ACLRule, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rules, exists := d.policies[policyKey]
+	if !exists {
+		return nil, false
+	}
+	out := make([]ACLRule, len(rules))
+	copy(out, rules)
+	return out, true
+}
+
+// Keys returns all policy keys with desired rules, sorted for deterministic iteration
+func (d *DesiredState) Keys() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	keys := make([]string, 0, len(d.policies))
+	for key := range d.policies {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// TableFor returns the complete desired ACL table for an endpoint, grouped by policy key.
+// Policies set with SetForEndpoints only appear in the tables of their endpoints.
+func (d *DesiredState) TableFor(endpointID string) map[string][]ACLRule {
+	return d.tableFor(hcn.HostComputeEndpoint{Id: endpointID})
+}
+
+// tableFor is TableFor matching the policies set with SetForAddresses against
+// the IPs of endpoint as well
+func (d *DesiredState) tableFor(endpoint hcn.HostComputeEndpoint) map[string][]ACLRule {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	table := make(map[string][]ACLRule, len(d.policies))
+	for key, rules := range d.policies {
+		if targets, targeted := d.targets[key]; targeted && !targets[endpoint.Id] {
+			continue
+		}
+		if addresses, targeted := d.addressTargets[key]; targeted && !ownsAddress(endpoint, addresses) {
+			continue
+		}
+		out := make([]ACLRule, len(rules))
+		copy(out, rules)
+		table[key] = out
+	}
+	return table
+}
+
+// ownsAddress reports whether endpoint has one of addresses
+func ownsAddress(endpoint hcn.HostComputeEndpoint, addresses map[string]bool) bool {
+	for _, ipConfig := range endpoint.IpConfigurations {
+		if addresses[ipConfig.IpAddress] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+//go:build windows
+
+package hcn
+
+import (
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+func TestDesiredState_SetGetDelete(t *testing.T) {
+	desired := NewDesiredState()
+
+	rules := []ACLRule{
+		{Name: "allow-http", Direction: hcn.DirectionTypeIn, LocalPorts: "80", Priority: 100},
+	}
+	desired.Set("default/policy", rules)
+
+	// Mutating the caller's slice must not leak into the cache
+	rules[0].LocalPorts = "8080"
+
+	got, exists := desired.Get("default/policy")
+	if !exists {
+		t.Fatal("Expected desired rules to exist")
+	}
+	if got[0].LocalPorts != "80" {
+		t.Errorf("Expected stored LocalPorts 80, got %s", got[0].LocalPorts)
+	}
+
+	desired.Delete("default/policy")
+	if _, exists := desired.Get("default/policy"); exists {
+		t.Error("Expected desired rules to be deleted")
+	}
+}
+
+func TestDesiredState_KeysSorted(t *testing.T) {
+	desired := NewDesiredState()
+	desired.Set("kube-system/b", nil)
+	desired.Set("default/a", nil)
+	desired.Set("default/c", nil)
+
+	keys := desired.Keys()
+	expected := []string{"default/a", "default/c", "kube-system/b"}
+	if len(keys) != len(expected) {
+		t.Fatalf("Expected %d keys, got %d", len(expected), len(keys))
+	}
+	for i := range expected {
+		if keys[i] != expected[i] {
+			t.Errorf("keys[%d] = %s, want %s", i, keys[i], expected[i])
+		}
+	}
+}
+
+func TestDesiredState_TableFor(t *testing.T) {
+	desired := NewDesiredState()
+	desired.Set("default/web", []ACLRule{{Name: "allow-http", Priority: 100}})
+	desired.Set("default/dns", []ACLRule{{Name: "allow-dns", Priority: 101}})
+
+	table := desired.TableFor("ep-1")
+	if len(table) != 2 {
+		t.Fatalf("Expected 2 policies in endpoint table, got %d", len(table))
+	}
+	if table["default/web"][0].Name != "allow-http" {
+		t.Errorf("Unexpected rule in table: %+v", table["default/web"])
+	}
+}
+
+func TestDesiredState_SetForEndpoints(t *testing.T) {
+	desired := NewDesiredState()
+	desired.Set("all/policy", nil)
+	desired.SetForEndpoints("default/targeted", []ACLRule{{Name: "allow", Priority: 100}}, []string{"ep-1"})
+
+	if table := desired.TableFor("ep-1"); len(table) != 2 {
+		t.Errorf("Expected both policies on ep-1, got %v", table)
+	}
+	table := desired.TableFor("ep-2")
+	if _, found := table["default/targeted"]; found || len(table) != 1 {
+		t.Errorf("Expected only the untargeted policy on ep-2, got %v", table)
+	}
+
+	// Set drops the targeting
+	desired.Set("default/targeted", nil)
+	if _, found := desired.TableFor("ep-2")["default/targeted"]; !found {
+		t.Error("Expected Set to target every endpoint again")
+	}
+}
+
+func TestDesiredState_SetForAddresses(t *testing.T) {
+	desired := NewDesiredState()
+	desired.SetForAddresses("default/targeted", []ACLRule{{Name: "allow", Priority: 100}}, []string{"10.0.0.5"})
+
+	selected := hcn.HostComputeEndpoint{Id: "ep-1", IpConfigurations: []hcn.IpConfig{{IpAddress: "10.0.0.5"}}}
+	other := hcn.HostComputeEndpoint{Id: "ep-2", IpConfigurations: []hcn.IpConfig{{IpAddress: "10.0.0.6"}}}
+	if _, found := desired.DesiredRulesFor(selected)["default/targeted"]; !found {
+		t.Error("Expected the policy on the endpoint owning its address")
+	}
+	if table := desired.DesiredRulesFor(other); len(table) != 0 {
+		t.Errorf("Expected no policy on other endpoints, got %v", table)
+	}
+
+	// Targeting by ID replaces the address targets
+	desired.SetForEndpoints("default/targeted", nil, []string{"ep-2"})
+	if _, found := desired.DesiredRulesFor(other)["default/targeted"]; !found {
+		t.Error("Expected SetForEndpoints to drop the address targets")
+	}
+}
@@ -0,0 +1,63 @@
+//go:build windows
+
+package hcn
+
+import (
+	"fmt"
+
+	"github.com/Microsoft/hcsshim/hcn"
+
+	"github.com/knabben/firewall-controller/internal/events"
+)
+
+// RepairDrift compares the tracked policies with what every endpoint actually
+// carries, forgets tracked policies HNS no longer has, records the priorities
+// taken by out-of-band ACLs, and reconciles so they are programmed again. It is the full repair pass run when incremental events
+// may have been lost; the periodic Reconcile trusts the tracked state instead.
+func (m *Manager) RepairDrift() error {
+	endpoints, err := m.listEndpoints()
+	if err != nil {
+		return fmt.Errorf("failed to list HCN endpoints: %w", err)
+	}
+	live := make(map[string][]hcn.EndpointPolicy, len(endpoints))
+	for _, endpoint := range endpoints {
+		live[endpoint.Id] = endpoint.Policies
+	}
+	m.scanForeignACLs(endpoints)
+
+	drifted := 0
+	for _, key := range m.trackedKeys() {
+		unlock := m.policyLocks.lock(key)
+		ruleSets, _ := m.trackedRuleSets(key)
+		for _, ruleSet := range ruleSets {
+			policies, exists := live[ruleSet.EndpointID]
+			if !exists || m.isPinned(ruleSet.EndpointID) {
+				continue
+			}
+			missing, _ := diffPolicies(ruleSet.Policies, policies)
+			if len(missing) == 0 {
+				continue
+			}
+			drifted++
+			m.logger.Info("Tracked policies missing from endpoint, reprogramming",
+				"policyKey", key,
+				"endpointID", ruleSet.EndpointID,
+				"missingCount", len(missing))
+			events.Publish(m.bus, events.DriftDetected{
+				PolicyKey:    key,
+				EndpointID:   ruleSet.EndpointID,
+				MissingCount: len(missing),
+			})
+			_, present := diffPolicies(missing, ruleSet.Policies)
+			m.setEndpointTracking(key, ruleSet.EndpointID, present, m.rulesFor(present, ruleSet.Rules))
+			m.requestRequeue(key, RequeueDrift)
+		}
+		unlock()
+	}
+
+	m.logger.Info("Drift check complete",
+		"driftedCount", drifted,
+		"collisionCount", len(m.PriorityCollisions()),
+		"endpointCount", len(endpoints))
+	return m.Reconcile()
+}
@@ -0,0 +1,43 @@
+//go:build windows
+
+package hcn
+
+import (
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+func TestRepairDrift_ReprogramsMissingPolicies(t *testing.T) {
+	client := NewFakeClient(2)
+	manager := NewManager(client, logr.Discard())
+	if err := manager.ApplyACLRules("default/test", benchmarkRules(3)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	// HNS loses one rule on the first endpoint behind the agent's back
+	endpoints, _ := client.ListEndpoints()
+	request := hcn.PolicyEndpointRequest{Policies: endpoints[0].Policies[:1]}
+	if err := client.RemoveEndpointPolicy(&endpoints[0], hcn.RequestTypeRemove, request); err != nil {
+		t.Fatalf("RemoveEndpointPolicy failed: %v", err)
+	}
+
+	// The periodic reconcile trusts tracking and does not notice
+	if err := manager.Reconcile(); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if endpoint, _ := client.GetEndpointByID(endpoints[0].Id); len(endpoint.Policies) != 2 {
+		t.Fatalf("Expected the drift to persist after Reconcile, got %d policies", len(endpoint.Policies))
+	}
+
+	if err := manager.RepairDrift(); err != nil {
+		t.Fatalf("RepairDrift failed: %v", err)
+	}
+	for _, endpoint := range endpoints {
+		repaired, _ := client.GetEndpointByID(endpoint.Id)
+		if len(repaired.Policies) != 3 {
+			t.Errorf("Expected 3 policies on %s, got %d", endpoint.Id, len(repaired.Policies))
+		}
+	}
+}
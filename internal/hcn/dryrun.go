@@ -0,0 +1,225 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DryRunChange is an HNS change the Manager would have made in dry-run mode
+type DryRunChange struct {
+	Time time.Time `json:"time"`
+
+	// Operation is "add" or "remove"
+	Operation string `json:"operation"`
+
+	// EndpointID or NetworkID is the object the policies would have been changed on
+	EndpointID string `json:"endpointID,omitempty"`
+	NetworkID  string `json:"networkID,omitempty"`
+
+	// Settings are the settings of the policies, ACLs for the Manager's own
+	Settings []json.RawMessage `json:"settings"`
+}
+
+// SetDryRun stops the Manager from changing HNS. Reads still go to HNS, while
+// every policy the Manager would add or remove is logged, counted and kept
+// among the keep most recent changes for DryRunChanges instead. The Manager
+// converges against a simulated copy of the endpoint and network policies,
+// so a change is reported once rather than on every reconcile; the copy is
+// lost on restart. It must be called before the Manager starts reconciling,
+// and before SetConcurrency and SetFaultInjector.
+func (m *Manager) SetDryRun(keep int) {
+	client := &dryRunClient{
+		HCNClient: m.client,
+		logger:    m.logger.WithName("dry-run"),
+		counter:   m.dryRunChanges,
+		keep:      max(keep, 0),
+		endpoints: make(map[string][]hcn.EndpointPolicy),
+		networks:  make(map[string][]hcn.NetworkPolicy),
+	}
+	m.dryRun = client
+	m.logger.Info("Dry run enabled: no policy is changed in HNS")
+	if _, ok := m.client.(NetworkClient); ok {
+		m.client = &dryRunNetworkClient{dryRunClient: client}
+		return
+	}
+	m.client = client
+}
+
+// DryRunChanges returns the most recent changes suppressed in dry-run mode,
+// oldest first; nil unless dry run is enabled
+func (m *Manager) DryRunChanges() []DryRunChange {
+	if m.dryRun == nil {
+		return nil
+	}
+	m.dryRun.mu.Lock()
+	defer m.dryRun.mu.Unlock()
+	return append(make([]DryRunChange, 0, len(m.dryRun.changes)), m.dryRun.changes...)
+}
+
+// dryRunClient reads through the wrapped HCNClient and simulates its writes
+type dryRunClient struct {
+	HCNClient
+	logger  logr.Logger
+	counter *prometheus.CounterVec
+	keep    int
+
+	mu sync.Mutex
+
+	// endpoints and networks hold the simulated policies of the objects a
+	// change was simulated on, replacing those HNS reports
+	endpoints map[string][]hcn.EndpointPolicy
+	networks  map[string][]hcn.NetworkPolicy
+
+	// changes are the most recent suppressed changes, oldest first
+	changes []DryRunChange
+}
+
+// ListEndpoints implements HCNClient, with the simulated policies
+func (c *dryRunClient) ListEndpoints() ([]hcn.HostComputeEndpoint, error) {
+	endpoints, err := c.HCNClient.ListEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	live := make(map[string]bool, len(endpoints))
+	for i := range endpoints {
+		live[endpoints[i].Id] = true
+		if policies, simulated := c.endpoints[endpoints[i].Id]; simulated {
+			endpoints[i].Policies = append([]hcn.EndpointPolicy(nil), policies...)
+		}
+	}
+	for id := range c.endpoints {
+		if !live[id] {
+			delete(c.endpoints, id)
+		}
+	}
+	return endpoints, nil
+}
+
+// GetEndpointByID implements HCNClient, with the simulated policies
+func (c *dryRunClient) GetEndpointByID(id string) (*hcn.HostComputeEndpoint, error) {
+	endpoint, err := c.HCNClient.GetEndpointByID(id)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if policies, simulated := c.endpoints[id]; simulated {
+		endpoint.Policies = append([]hcn.EndpointPolicy(nil), policies...)
+	}
+	return endpoint, nil
+}
+
+// ApplyEndpointPolicy implements HCNClient, recording the policies it would add
+func (c *dryRunClient) ApplyEndpointPolicy(endpoint *hcn.HostComputeEndpoint, requestType hcn.RequestType, request hcn.PolicyEndpointRequest) error {
+	return c.modifyEndpoint(endpoint, "add", request.Policies)
+}
+
+// RemoveEndpointPolicy implements HCNClient, recording the policies it would remove
+func (c *dryRunClient) RemoveEndpointPolicy(endpoint *hcn.HostComputeEndpoint, requestType hcn.RequestType, request hcn.PolicyEndpointRequest) error {
+	return c.modifyEndpoint(endpoint, "remove", request.Policies)
+}
+
+// modifyEndpoint simulates adding or removing policies on an endpoint
+func (c *dryRunClient) modifyEndpoint(endpoint *hcn.HostComputeEndpoint, operation string, policies []hcn.EndpointPolicy) error {
+	// The endpoint must still exist, as HNS would check
+	current, err := c.GetEndpointByID(endpoint.Id)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if operation == "add" {
+		c.endpoints[endpoint.Id] = append(current.Policies, policies...)
+	} else {
+		_, c.endpoints[endpoint.Id] = diffPolicies(policies, current.Policies)
+	}
+	settings := make([]json.RawMessage, len(policies))
+	for i, policy := range policies {
+		settings[i] = policy.Settings
+	}
+	c.recordLocked(DryRunChange{Time: time.Now(), Operation: operation, EndpointID: endpoint.Id, Settings: settings})
+	return nil
+}
+
+// recordLocked logs, counts and keeps a suppressed change; callers hold mu
+func (c *dryRunClient) recordLocked(change DryRunChange) {
+	settings := make([]string, len(change.Settings))
+	for i, setting := range change.Settings {
+		settings[i] = string(setting)
+	}
+	c.logger.Info("Would change HNS policies",
+		"operation", change.Operation,
+		"endpointID", change.EndpointID,
+		"networkID", change.NetworkID,
+		"settings", settings)
+	c.counter.WithLabelValues(change.Operation).Add(float64(len(change.Settings)))
+	if c.keep == 0 {
+		return
+	}
+	if len(c.changes) == c.keep {
+		c.changes = append(c.changes[:0], c.changes[1:]...)
+	}
+	c.changes = append(c.changes, change)
+}
+
+// dryRunNetworkClient is a dryRunClient for clients that also program networks
+type dryRunNetworkClient struct {
+	*dryRunClient
+}
+
+// ListNetworks implements NetworkClient, with the simulated policies
+func (c *dryRunNetworkClient) ListNetworks() ([]hcn.HostComputeNetwork, error) {
+	networks, err := c.HCNClient.(NetworkClient).ListNetworks()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range networks {
+		if policies, simulated := c.networks[networks[i].Id]; simulated {
+			networks[i].Policies = append([]hcn.NetworkPolicy(nil), policies...)
+		}
+	}
+	return networks, nil
+}
+
+// AddNetworkPolicy implements NetworkClient, recording the policies it would add
+func (c *dryRunNetworkClient) AddNetworkPolicy(network *hcn.HostComputeNetwork, request hcn.PolicyNetworkRequest) error {
+	return c.modifyNetwork(network, "add", request.Policies)
+}
+
+// RemoveNetworkPolicy implements NetworkClient, recording the policies it would remove
+func (c *dryRunNetworkClient) RemoveNetworkPolicy(network *hcn.HostComputeNetwork, request hcn.PolicyNetworkRequest) error {
+	return c.modifyNetwork(network, "remove", request.Policies)
+}
+
+// modifyNetwork simulates adding or removing policies on a network
+func (c *dryRunNetworkClient) modifyNetwork(network *hcn.HostComputeNetwork, operation string, policies []hcn.NetworkPolicy) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current, simulated := c.networks[network.Id]
+	if !simulated {
+		current = network.Policies
+	}
+	if operation == "add" {
+		c.networks[network.Id] = append(append([]hcn.NetworkPolicy(nil), current...), policies...)
+	} else {
+		_, c.networks[network.Id] = diffNetworkPolicies(policies, current)
+	}
+	settings := make([]json.RawMessage, len(policies))
+	for i, policy := range policies {
+		settings[i] = policy.Settings
+	}
+	c.recordLocked(DryRunChange{Time: time.Now(), Operation: operation, NetworkID: network.Id, Settings: settings})
+	return nil
+}
@@ -0,0 +1,60 @@
+//go:build windows
+
+package hcn
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestManager_DryRun(t *testing.T) {
+	client := NewFakeClient(2)
+	manager := NewManager(client, logr.Discard())
+	manager.SetDryRun(3)
+	policiesOn := func(endpointID string) int {
+		t.Helper()
+		endpoint, err := client.GetEndpointByID(endpointID)
+		if err != nil {
+			t.Fatalf("GetEndpointByID failed: %v", err)
+		}
+		return len(endpoint.Policies)
+	}
+
+	if err := manager.ApplyACLRules("default/web", benchmarkRules(2)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	if got := policiesOn("fake-endpoint-0") + policiesOn("fake-endpoint-1"); got != 0 {
+		t.Fatalf("Expected HNS untouched in dry run, got %d policies", got)
+	}
+	changes := manager.DryRunChanges()
+	if len(changes) != 2 || changes[0].Operation != "add" || len(changes[0].Settings) != 2 {
+		t.Fatalf("Expected an add of both ACLs per endpoint recorded, got %+v", changes)
+	}
+	if ruleSets, _ := manager.GetAppliedPolicies("default/web"); len(ruleSets) != 2 {
+		t.Errorf("Expected the simulated rules tracked on both endpoints, got %d", len(ruleSets))
+	}
+
+	// Converged against the simulated policies, reconciles change nothing
+	if err := manager.Reconcile(); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if err := manager.RepairDrift(); err != nil {
+		t.Fatalf("RepairDrift failed: %v", err)
+	}
+	if changes := manager.DryRunChanges(); len(changes) != 2 {
+		t.Errorf("Expected no further change once converged, got %+v", changes)
+	}
+
+	// Removals are recorded too, keeping the 3 most recent changes
+	if err := manager.RemoveACLRules("default/web"); err != nil {
+		t.Fatalf("RemoveACLRules failed: %v", err)
+	}
+	changes = manager.DryRunChanges()
+	if len(changes) != 3 || changes[1].Operation != "remove" || changes[2].Operation != "remove" {
+		t.Errorf("Expected the two removals to be the latest changes, got %+v", changes)
+	}
+	if got := policiesOn("fake-endpoint-0"); got != 0 {
+		t.Errorf("Expected HNS untouched in dry run, got %d policies", got)
+	}
+}
@@ -0,0 +1,154 @@
+//go:build windows
+
+package hcn
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"syscall"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// HNSError is structured information extracted from an hcsshim/HNS error, for
+// logs, events and metrics
+type HNSError struct {
+	// Code is the Win32 error code or HRESULT reported by HNS, 0 when unknown
+	Code uint32
+
+	// Name is the symbolic name of Code, e.g. HCN_E_POLICY_ALREADY_EXISTS
+	Name string
+
+	// Retryable reports whether the same request may succeed later
+	Retryable bool
+
+	// Hint is a short operator-facing explanation, empty for unknown codes
+	Hint string
+
+	// Err is the original error
+	Err error
+}
+
+func (e *HNSError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *HNSError) Unwrap() error {
+	return e.Err
+}
+
+// Summary returns a one-line description of the error suitable for events
+func (e *HNSError) Summary() string {
+	s := fmt.Sprintf("HNS error %s (0x%08x)", e.Name, e.Code)
+	if e.Hint != "" {
+		s += ": " + e.Hint
+	}
+	return s
+}
+
+// LogKeys returns the structured fields of the error as logr key/value pairs
+func (e *HNSError) LogKeys() []any {
+	return []any{
+		"hnsCode", fmt.Sprintf("0x%08x", e.Code),
+		"hnsError", e.Name,
+		"retryable", e.Retryable,
+		"hint", e.Hint,
+	}
+}
+
+// hnsCodeInfo describes a known HNS error code
+type hnsCodeInfo struct {
+	name      string
+	retryable bool
+	hint      string
+}
+
+const (
+	hintNotFound     = "the endpoint or policy was deleted concurrently; the next resync drops it"
+	hintExists       = "an identical policy is already programmed, typically by another agent or before a restart; a resync refreshes tracking"
+	hintInvalid      = "HNS rejected the ACL settings; check the ports, protocol and address formats of the rule"
+	hintUnsupported  = "the HNS version on this node does not support the request"
+	hintUnavailable  = "HNS is restarting or unavailable; check the hns service on the node"
+	hintAccessDenied = "the agent must run as a HostProcess container with administrator privileges"
+)
+
+// hnsCodes are the error codes HNS commonly returns when programming ACLs
+var hnsCodes = map[uint32]hnsCodeInfo{
+	0x00000005: {"ERROR_ACCESS_DENIED", false, hintAccessDenied},
+	0x80070005: {"E_ACCESSDENIED", false, hintAccessDenied},
+	0x80070057: {"E_INVALIDARG", false, hintInvalid},
+	0x000000B7: {"ERROR_ALREADY_EXISTS", true, hintExists},
+	0x00000490: {"ERROR_NOT_FOUND", false, hintNotFound},
+	0x00000426: {"ERROR_SERVICE_NOT_ACTIVE", true, hintUnavailable},
+	0x000006BA: {"RPC_S_SERVER_UNAVAILABLE", true, hintUnavailable},
+	0x00001392: {"ERROR_OBJECT_ALREADY_EXISTS", true, hintExists},
+	0x803B0002: {"HCN_E_ENDPOINT_NOT_FOUND", false, hintNotFound},
+	0x803B0007: {"HCN_E_PORT_NOT_FOUND", false, hintNotFound},
+	0x803B0008: {"HCN_E_POLICY_NOT_FOUND", false, hintNotFound},
+	0x803B000D: {"HCN_E_INVALID_POLICY", false, hintInvalid},
+	0x803B000E: {"HCN_E_INVALID_POLICY_TYPE", false, hintInvalid},
+	0x803B0012: {"HCN_E_POLICY_ALREADY_EXISTS", true, hintExists},
+	0x803B0015: {"HCN_E_REQUEST_UNSUPPORTED", false, hintUnsupported},
+	0x803B0017: {"HCN_E_DEGRADED_OPERATION", true, hintUnavailable},
+	0x803B001B: {"HCN_E_INVALID_JSON", false, hintInvalid},
+	0x803B001E: {"HCN_E_INVALID_IP", false, hintInvalid},
+	0x803B0020: {"HCN_E_MANAGER_STOPPED", true, hintUnavailable},
+}
+
+// hresultPattern matches the "(0x803b0012)" suffix hcsshim appends to HNS errors
+var hresultPattern = regexp.MustCompile(`\(0x([0-9a-fA-F]{1,8})\)`)
+
+// ParseHNSError extracts the HNS error code from err and classifies it.
+// Errors without a recognizable code are reported as retryable, matching how
+// they were handled before classification existed. It returns nil for a nil error.
+func ParseHNSError(err error) *HNSError {
+	if err == nil {
+		return nil
+	}
+
+	code, ok := hnsErrorCode(err)
+	if !ok {
+		return &HNSError{Name: "Unknown", Retryable: true, Err: err}
+	}
+
+	parsed := &HNSError{Code: code, Name: fmt.Sprintf("0x%08x", code), Retryable: true, Err: err}
+	if info, known := hnsCodes[code]; known {
+		parsed.Name = info.name
+		parsed.Retryable = info.retryable
+		parsed.Hint = info.hint
+	}
+	return parsed
+}
+
+// hnsErrorCode finds the Win32 error or HRESULT carried by err
+func hnsErrorCode(err error) (uint32, bool) {
+	// Lookups by ID fail with typed errors that carry no code
+	if hcn.IsNotFoundError(err) {
+		return 0x00000490, true
+	}
+
+	// HcnError wraps the errno without exposing it through Unwrap
+	var hcnErr *hcn.HcnError
+	if errors.As(err, &hcnErr) && hcnErr.HcsError != nil {
+		var errno syscall.Errno
+		if errors.As(hcnErr.Err, &errno) {
+			return uint32(errno), true
+		}
+	}
+
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		return uint32(errno), true
+	}
+
+	// Errors flattened to strings (e.g. through %v) still carry the code in the message
+	if match := hresultPattern.FindStringSubmatch(err.Error()); match != nil {
+		code, parseErr := strconv.ParseUint(match[1], 16, 32)
+		if parseErr == nil {
+			return uint32(code), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,104 @@
+//go:build windows
+
+package hcn
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseHNSError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		code      uint32
+		errName   string
+		retryable bool
+	}{
+		{
+			name:      "errno",
+			err:       fmt.Errorf("apply: %w", syscall.Errno(0x803B0012)),
+			code:      0x803B0012,
+			errName:   "HCN_E_POLICY_ALREADY_EXISTS",
+			retryable: true,
+		},
+		{
+			name:    "hcsshim message",
+			err:     errors.New("hnsCall failed in Win32: The parameter is incorrect. (0x80070057)"),
+			code:    0x80070057,
+			errName: "E_INVALIDARG",
+		},
+		{
+			name:    "typed not found",
+			err:     hcn.EndpointNotFoundError{EndpointID: "ep-1"},
+			code:    0x490,
+			errName: "ERROR_NOT_FOUND",
+		},
+		{
+			name:      "unlisted code",
+			err:       errors.New("failed in Win32: Something (0x803b00ff)"),
+			code:      0x803B00FF,
+			errName:   "0x803b00ff",
+			retryable: true,
+		},
+		{
+			name:      "no code",
+			err:       errors.New("connection reset"),
+			errName:   "Unknown",
+			retryable: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed := ParseHNSError(tt.err)
+			if parsed.Code != tt.code || parsed.Name != tt.errName || parsed.Retryable != tt.retryable {
+				t.Errorf("ParseHNSError() = {Code: 0x%x, Name: %s, Retryable: %v}, want {Code: 0x%x, Name: %s, Retryable: %v}",
+					parsed.Code, parsed.Name, parsed.Retryable, tt.code, tt.errName, tt.retryable)
+			}
+			if !errors.Is(parsed, tt.err) {
+				t.Error("Expected the parsed error to wrap the original")
+			}
+		})
+	}
+
+	if ParseHNSError(nil) != nil {
+		t.Error("Expected nil for a nil error")
+	}
+}
+
+func TestParseHNSError_Joined(t *testing.T) {
+	err := fmt.Errorf("failed to apply policies to 2/2 endpoints: %w", errors.Join(
+		fmt.Errorf("endpoint ep-1: %w", syscall.Errno(0x803B0020)),
+		fmt.Errorf("endpoint ep-2: %w", syscall.Errno(0x803B0020)),
+	))
+
+	if parsed := ParseHNSError(err); parsed.Name != "HCN_E_MANAGER_STOPPED" || parsed.Hint == "" {
+		t.Errorf("Expected HCN_E_MANAGER_STOPPED with a hint, got %s %q", parsed.Name, parsed.Hint)
+	}
+}
+
+func TestApplyACLRules_CountsHNSErrors(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{{Id: "ep-1"}, {Id: "ep-2"}}
+	mockClient.applyPolicyErr = syscall.Errno(0x803B000D)
+
+	manager := NewManager(mockClient, logr.Discard())
+	err := manager.ApplyACLRules("default/test", benchmarkRules(1))
+	if err == nil {
+		t.Fatal("Expected apply error")
+	}
+	if parsed := ParseHNSError(err); parsed.Name != "HCN_E_INVALID_POLICY" || parsed.Retryable {
+		t.Errorf("Expected non-retryable HCN_E_INVALID_POLICY through the aggregate error, got %s", parsed.Name)
+	}
+
+	if got := testutil.ToFloat64(manager.hnsErrors.WithLabelValues("apply", "HCN_E_INVALID_POLICY")); got != 2 {
+		t.Errorf("Expected 2 counted apply errors, got %v", got)
+	}
+}
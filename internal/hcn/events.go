@@ -0,0 +1,80 @@
+//go:build windows
+
+package hcn
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Microsoft/hcsshim/hcn"
+
+	"github.com/knabben/firewall-controller/internal/events"
+)
+
+// hnsOutage tracks since when HNS fails as unavailable, to publish
+// HNSRestarted once it answers again
+type hnsOutage struct {
+	mu sync.Mutex
+
+	// since is when the first failing listing was seen; zero while HNS answers
+	since time.Time
+}
+
+// SetEventBus makes the Manager publish EndpointAdded, PolicyApplied,
+// DriftDetected and HNSRestarted events on bus. It must be called before the
+// Manager starts reconciling.
+func (m *Manager) SetEventBus(bus *events.Bus) {
+	m.bus = bus
+}
+
+// observeListing records the outcome of an endpoint listing: it publishes the
+// end of an HNS outage and the endpoints the index does not know yet. It must
+// be called before the index is updated with endpoints.
+func (m *Manager) observeListing(endpoints []hcn.HostComputeEndpoint, err error) {
+	if m.bus == nil {
+		return
+	}
+	m.outage.mu.Lock()
+	if err != nil {
+		if ParseHNSError(err).Hint == hintUnavailable && m.outage.since.IsZero() {
+			m.outage.since = time.Now()
+		}
+		m.outage.mu.Unlock()
+		return
+	}
+	since := m.outage.since
+	m.outage.since = time.Time{}
+	m.outage.mu.Unlock()
+
+	if !since.IsZero() {
+		downtime := time.Since(since)
+		m.logger.Info("HNS available again", "downtime", downtime)
+		events.Publish(m.bus, events.HNSRestarted{Downtime: downtime})
+	}
+	for _, endpoint := range endpoints {
+		if _, known := m.index.ByID(endpoint.Id); known {
+			continue
+		}
+		added := events.EndpointAdded{EndpointID: endpoint.Id, Name: endpoint.Name}
+		for _, ipConfig := range endpoint.IpConfigurations {
+			if ipConfig.IpAddress != "" {
+				added.IPs = append(added.IPs, ipConfig.IpAddress)
+			}
+		}
+		events.Publish(m.bus, added)
+	}
+}
+
+// publishApplied publishes PolicyApplied for a policy whose sync succeeded
+func (m *Manager) publishApplied(policyKey string) {
+	if m.bus == nil {
+		return
+	}
+	rules, _ := m.desired.Get(policyKey)
+	ruleSets, _ := m.trackedRuleSets(policyKey)
+	events.Publish(m.bus, events.PolicyApplied{
+		PolicyKey:     policyKey,
+		RuleCount:     len(rules),
+		EndpointCount: len(ruleSets),
+	})
+}
@@ -0,0 +1,69 @@
+//go:build windows
+
+package hcn
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+
+	"github.com/knabben/firewall-controller/internal/events"
+)
+
+func TestManager_PublishesEvents(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{
+		{Id: "ep-1", Name: "endpoint-1", IpConfigurations: []hcn.IpConfig{{IpAddress: "10.0.0.5"}}},
+	}
+	manager := NewManager(mockClient, logr.Discard())
+	bus := events.NewBus()
+	manager.SetEventBus(bus)
+
+	var added []events.EndpointAdded
+	var applied []events.PolicyApplied
+	var drifted []events.DriftDetected
+	var restarts []events.HNSRestarted
+	events.Subscribe(bus, func(event events.EndpointAdded) { added = append(added, event) })
+	events.Subscribe(bus, func(event events.PolicyApplied) { applied = append(applied, event) })
+	events.Subscribe(bus, func(event events.DriftDetected) { drifted = append(drifted, event) })
+	events.Subscribe(bus, func(event events.HNSRestarted) { restarts = append(restarts, event) })
+
+	rules := []ACLRule{
+		{Name: "allow-http", Action: hcn.ActionTypeAllow, Direction: hcn.DirectionTypeIn, Protocol: "6", Priority: 100},
+	}
+	if err := manager.ApplyACLRules("netpol/default/web", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	if len(added) != 1 || added[0].EndpointID != "ep-1" || len(added[0].IPs) != 1 || added[0].IPs[0] != "10.0.0.5" {
+		t.Errorf("Expected ep-1 reported as added, got %+v", added)
+	}
+	if len(applied) != 1 || applied[0].PolicyKey != "netpol/default/web" || applied[0].RuleCount != 1 || applied[0].EndpointCount != 1 {
+		t.Errorf("Expected the policy reported as applied, got %+v", applied)
+	}
+
+	// HNS stops answering while its service restarts
+	mockClient.listEndpointsErr = errors.New("hcnOpenEndpoint failed in Win32: The HNS manager is stopped. (0x803b0020)")
+	if err := manager.Reconcile(); err == nil {
+		t.Fatal("Expected Reconcile to fail while HNS is unavailable")
+	}
+	mockClient.listEndpointsErr = nil
+	if err := manager.Reconcile(); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if len(restarts) != 1 {
+		t.Errorf("Expected 1 HNS restart reported, got %d", len(restarts))
+	}
+	if len(added) != 1 {
+		t.Errorf("Expected known endpoints not reported again, got %+v", added)
+	}
+
+	// The mock endpoint never carries the programmed policies
+	if err := manager.RepairDrift(); err != nil {
+		t.Fatalf("RepairDrift failed: %v", err)
+	}
+	if len(drifted) != 1 || drifted[0].PolicyKey != "netpol/default/web" || drifted[0].EndpointID != "ep-1" || drifted[0].MissingCount != 1 {
+		t.Errorf("Expected drift reported on ep-1, got %+v", drifted)
+	}
+}
@@ -0,0 +1,39 @@
+//go:build windows
+
+package hcn
+
+import (
+	"context"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// ExemptEndpoint is a pod address whose endpoint is exempt from every rule
+type ExemptEndpoint = PausedEndpoint
+
+// SetEndpointExempt exempts the endpoint owning ip from every rule, or lifts
+// the exemption. Like a paused endpoint, an exempt one is desired to carry no
+// rules: the policies programmed on it are removed and none are added while
+// it stays exempt. Exemptions are meant for system pods the node depends on,
+// configured by the operator rather than requested by the pod's owner. pod
+// names the pod for logs and ExemptEndpoints.
+func (m *Manager) SetEndpointExempt(ctx context.Context, ip, pod string, exempt bool) error {
+	if !m.exempt.set(ip, pod, exempt) {
+		return nil
+	}
+	message := "Lifted exemption of endpoint"
+	if exempt {
+		message = "Exempted endpoint from every rule"
+	}
+	return m.resyncAddress(ctx, ip, pod, message)
+}
+
+// ExemptEndpoints returns the pod addresses exempt from every rule, sorted
+func (m *Manager) ExemptEndpoints() []ExemptEndpoint {
+	return m.exempt.list()
+}
+
+// endpointExempt reports whether an address of endpoint is exempt from every rule
+func (m *Manager) endpointExempt(endpoint hcn.HostComputeEndpoint) bool {
+	return m.exempt.holds(endpoint)
+}
@@ -0,0 +1,61 @@
+//go:build windows
+
+package hcn
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestSetEndpointExempt(t *testing.T) {
+	client := NewFakeClient(2)
+	manager := NewManager(client, logr.Discard())
+	if err := manager.ApplyACLRules("default/web", benchmarkRules(2)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	policiesOn := func(endpointID string) int {
+		t.Helper()
+		endpoint, err := client.GetEndpointByID(endpointID)
+		if err != nil {
+			t.Fatalf("GetEndpointByID failed: %v", err)
+		}
+		return len(endpoint.Policies)
+	}
+
+	ctx := context.Background()
+	if err := manager.SetEndpointExempt(ctx, "10.244.0.2", "kube-system/coredns-0", true); err != nil {
+		t.Fatalf("SetEndpointExempt failed: %v", err)
+	}
+	if got := policiesOn("fake-endpoint-0"); got != 0 {
+		t.Errorf("Expected no rules on the exempt endpoint, got %d", got)
+	}
+	if got := policiesOn("fake-endpoint-1"); got != 2 {
+		t.Errorf("Expected the other endpoint untouched, got %d rules", got)
+	}
+	if stats := manager.Stats(); stats.ExemptEndpoints != 1 || stats.PausedEndpoints != 0 {
+		t.Errorf("Expected 1 exempt and no paused endpoint, got %d and %d", stats.ExemptEndpoints, stats.PausedEndpoints)
+	}
+
+	// Pausing and resuming the exempt endpoint keeps it exempt
+	if err := manager.SetEnforcementPaused(ctx, "10.244.0.2", "kube-system/coredns-0", true); err != nil {
+		t.Fatalf("SetEnforcementPaused failed: %v", err)
+	}
+	if err := manager.SetEnforcementPaused(ctx, "10.244.0.2", "kube-system/coredns-0", false); err != nil {
+		t.Fatalf("SetEnforcementPaused failed: %v", err)
+	}
+	if got := policiesOn("fake-endpoint-0"); got != 0 {
+		t.Errorf("Expected the exempt endpoint to stay empty, got %d rules", got)
+	}
+
+	if err := manager.SetEndpointExempt(ctx, "10.244.0.2", "kube-system/coredns-0", false); err != nil {
+		t.Fatalf("SetEndpointExempt failed: %v", err)
+	}
+	if got := policiesOn("fake-endpoint-0"); got != 2 {
+		t.Errorf("Expected the rules back once the exemption is lifted, got %d", got)
+	}
+	if exempt := manager.ExemptEndpoints(); len(exempt) != 0 {
+		t.Errorf("Expected no exempt endpoints, got %v", exempt)
+	}
+}
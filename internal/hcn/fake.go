@@ -0,0 +1,346 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// FakeClient is an in-memory HCNClient for dry runs and CI, where no HNS is
+// available. It only checks that programmed ACL settings are well formed;
+// rule validation itself happens in the Manager before any HCN call.
+type FakeClient struct {
+	mu         sync.Mutex
+	endpoints  []hcn.HostComputeEndpoint
+	networks   []hcn.HostComputeNetwork
+	namespaces []hcn.HostComputeNamespace
+
+	// latency is the simulated HNS call cost; slots bounds concurrent calls
+	latency FakeLatency
+	slots   chan struct{}
+
+	// maxBatch is the batch size ModifyEndpointPolicies reports; batchCalls counts its calls
+	maxBatch   int
+	batchCalls int
+}
+
+// FakeLatency simulates the cost of HNS calls so performance tests against the
+// fake approximate a real node. The zero value makes every call instant.
+type FakeLatency struct {
+	// List is the duration of a ListEndpoints call
+	List time.Duration
+
+	// Get is the duration of a GetEndpointByID call
+	Get time.Duration
+
+	// Modify is the base duration of an add or remove policy request
+	Modify time.Duration
+
+	// PerPolicy is added to Modify for every policy in the request
+	PerPolicy time.Duration
+
+	// MaxConcurrent is how many calls HNS serves at once; further calls wait
+	// for a free slot. 0 is unlimited.
+	MaxConcurrent int
+}
+
+// TypicalHNSLatency returns rough per-call costs of HNS on a busy node, for
+// benchmarks that should reflect production rather than in-memory speed.
+// Replace them with figures measured on your own nodes where they matter.
+func TypicalHNSLatency() FakeLatency {
+	return FakeLatency{
+		List:          20 * time.Millisecond,
+		Get:           2 * time.Millisecond,
+		Modify:        5 * time.Millisecond,
+		PerPolicy:     500 * time.Microsecond,
+		MaxConcurrent: 4,
+	}
+}
+
+// FakeNetworkID is the ID of the network hosting every fake endpoint
+const FakeNetworkID = "fake-network"
+
+// NewFakeClient creates a fake client with count synthetic endpoints on one network
+func NewFakeClient(count int) *FakeClient {
+	endpoints := make([]hcn.HostComputeEndpoint, 0, count)
+	for i := 0; i < count; i++ {
+		endpoints = append(endpoints, hcn.HostComputeEndpoint{
+			Id:   fmt.Sprintf("fake-endpoint-%d", i),
+			Name: fmt.Sprintf("fake-%d", i),
+
+			HostComputeNetwork: FakeNetworkID,
+			IpConfigurations: []hcn.IpConfig{
+				{IpAddress: fmt.Sprintf("10.244.%d.%d", i/250, i%250+2)},
+			},
+		})
+	}
+	return &FakeClient{
+		endpoints: endpoints,
+		networks:  []hcn.HostComputeNetwork{{Id: FakeNetworkID, Name: "fake"}},
+	}
+}
+
+// SetLatency makes subsequent calls take the given simulated time.
+// It must not be called while calls are in flight.
+func (c *FakeClient) SetLatency(latency FakeLatency) {
+	c.latency = latency
+	c.slots = nil
+	if latency.MaxConcurrent > 0 {
+		c.slots = make(chan struct{}, latency.MaxConcurrent)
+	}
+}
+
+// simulate waits for a call slot and sleeps for the call's duration.
+// It runs before mu is taken so that concurrent calls overlap as they would in HNS.
+func (c *FakeClient) simulate(duration time.Duration) {
+	if c.slots != nil {
+		c.slots <- struct{}{}
+		defer func() { <-c.slots }()
+	}
+	if duration > 0 {
+		time.Sleep(duration)
+	}
+}
+
+// modifyDuration is the simulated duration of a request carrying n policies
+func (c *FakeClient) modifyDuration(n int) time.Duration {
+	return c.latency.Modify + time.Duration(n)*c.latency.PerPolicy
+}
+
+// ListEndpoints returns copies of all fake endpoints
+func (c *FakeClient) ListEndpoints() ([]hcn.HostComputeEndpoint, error) {
+	c.simulate(c.latency.List)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	endpoints := make([]hcn.HostComputeEndpoint, len(c.endpoints))
+	for i, endpoint := range c.endpoints {
+		endpoint.Policies = append([]hcn.EndpointPolicy(nil), endpoint.Policies...)
+		endpoints[i] = endpoint
+	}
+	return endpoints, nil
+}
+
+// GetEndpointByID returns a copy of the fake endpoint with the given ID
+func (c *FakeClient) GetEndpointByID(id string) (*hcn.HostComputeEndpoint, error) {
+	c.simulate(c.latency.Get)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, endpoint := range c.endpoints {
+		if endpoint.Id == id {
+			endpoint.Policies = append([]hcn.EndpointPolicy(nil), endpoint.Policies...)
+			return &endpoint, nil
+		}
+	}
+	return nil, hcn.EndpointNotFoundError{EndpointID: id}
+}
+
+// ApplyEndpointPolicy validates and adds policies to the endpoint
+func (c *FakeClient) ApplyEndpointPolicy(endpoint *hcn.HostComputeEndpoint, requestType hcn.RequestType, request hcn.PolicyEndpointRequest) error {
+	c.simulate(c.modifyDuration(len(request.Policies)))
+	return c.addPolicies(endpoint, request)
+}
+
+// addPolicies validates and adds the policies of request to the endpoint
+func (c *FakeClient) addPolicies(endpoint *hcn.HostComputeEndpoint, request hcn.PolicyEndpointRequest) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stored := c.find(endpoint.Id)
+	if stored == nil {
+		return hcn.EndpointNotFoundError{EndpointID: endpoint.Id}
+	}
+
+	for _, policy := range request.Policies {
+		if policy.Type != hcn.ACL {
+			continue
+		}
+		var setting hcn.AclPolicySetting
+		if err := json.Unmarshal(policy.Settings, &setting); err != nil {
+			return fmt.Errorf("invalid ACL settings: %w", err)
+		}
+		if setting.Priority < MinPriority {
+			return fmt.Errorf("ACL priority %d is not accepted by HNS", setting.Priority)
+		}
+	}
+
+	stored.Policies = append(stored.Policies, request.Policies...)
+	return nil
+}
+
+// RemoveEndpointPolicy removes policies from the endpoint
+func (c *FakeClient) RemoveEndpointPolicy(endpoint *hcn.HostComputeEndpoint, requestType hcn.RequestType, request hcn.PolicyEndpointRequest) error {
+	c.simulate(c.modifyDuration(len(request.Policies)))
+	return c.removePolicies(endpoint, request)
+}
+
+// removePolicies removes the policies of request from the endpoint
+func (c *FakeClient) removePolicies(endpoint *hcn.HostComputeEndpoint, request hcn.PolicyEndpointRequest) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stored := c.find(endpoint.Id)
+	if stored == nil {
+		return hcn.EndpointNotFoundError{EndpointID: endpoint.Id}
+	}
+
+	_, remaining := diffPolicies(request.Policies, stored.Policies)
+	stored.Policies = remaining
+	return nil
+}
+
+// find returns the stored endpoint with the given ID; callers hold mu
+func (c *FakeClient) find(id string) *hcn.HostComputeEndpoint {
+	for i := range c.endpoints {
+		if c.endpoints[i].Id == id {
+			return &c.endpoints[i]
+		}
+	}
+	return nil
+}
+
+// ListNetworks returns copies of all fake networks
+func (c *FakeClient) ListNetworks() ([]hcn.HostComputeNetwork, error) {
+	c.simulate(c.latency.List)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	networks := make([]hcn.HostComputeNetwork, len(c.networks))
+	for i, network := range c.networks {
+		network.Policies = append([]hcn.NetworkPolicy(nil), network.Policies...)
+		networks[i] = network
+	}
+	return networks, nil
+}
+
+// AddNetworkPolicy validates and adds policies to the network
+func (c *FakeClient) AddNetworkPolicy(network *hcn.HostComputeNetwork, request hcn.PolicyNetworkRequest) error {
+	c.simulate(c.modifyDuration(len(request.Policies)))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stored := c.findNetwork(network.Id)
+	if stored == nil {
+		return hcn.NetworkNotFoundError{NetworkID: network.Id}
+	}
+
+	for _, policy := range request.Policies {
+		if policy.Type != hcn.NetworkACL {
+			continue
+		}
+		var setting hcn.NetworkACLPolicySetting
+		if err := json.Unmarshal(policy.Settings, &setting); err != nil {
+			return fmt.Errorf("invalid network ACL settings: %w", err)
+		}
+		if setting.Priority < MinPriority {
+			return fmt.Errorf("network ACL priority %d is not accepted by HNS", setting.Priority)
+		}
+	}
+
+	stored.Policies = append(stored.Policies, request.Policies...)
+	return nil
+}
+
+// RemoveNetworkPolicy removes policies from the network
+func (c *FakeClient) RemoveNetworkPolicy(network *hcn.HostComputeNetwork, request hcn.PolicyNetworkRequest) error {
+	c.simulate(c.modifyDuration(len(request.Policies)))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stored := c.findNetwork(network.Id)
+	if stored == nil {
+		return hcn.NetworkNotFoundError{NetworkID: network.Id}
+	}
+
+	_, remaining := diffNetworkPolicies(request.Policies, stored.Policies)
+	stored.Policies = remaining
+	return nil
+}
+
+// findNetwork returns the stored network with the given ID; callers hold mu
+func (c *FakeClient) findNetwork(id string) *hcn.HostComputeNetwork {
+	for i := range c.networks {
+		if c.networks[i].Id == id {
+			return &c.networks[i]
+		}
+	}
+	return nil
+}
+
+// SetEndpointNamespace attaches the fake endpoint with the given ID to a new
+// HNS namespace of the given type, e.g. hcn.NamespaceTypeGuest for the
+// endpoint of a Hyper-V isolated container
+func (c *FakeClient) SetEndpointNamespace(endpointID string, namespaceType hcn.NamespaceType) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	namespace := hcn.HostComputeNamespace{Id: fmt.Sprintf("fake-namespace-%d", len(c.namespaces)), Type: namespaceType}
+	c.namespaces = append(c.namespaces, namespace)
+	if endpoint := c.find(endpointID); endpoint != nil {
+		endpoint.HostComputeNamespace = namespace.Id
+	}
+}
+
+// GetNamespaceByID implements NamespaceClient
+func (c *FakeClient) GetNamespaceByID(id string) (*hcn.HostComputeNamespace, error) {
+	c.simulate(c.latency.Get)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, namespace := range c.namespaces {
+		if namespace.Id == id {
+			return &namespace, nil
+		}
+	}
+	return nil, hcn.NamespaceNotFoundError{NamespaceID: id}
+}
+
+// SetMaxBatchEndpoints makes the fake accept batches of up to n changes, as a
+// client of an HNS with a batch call would; 0 disables batching
+func (c *FakeClient) SetMaxBatchEndpoints(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxBatch = n
+}
+
+// BatchCalls returns the number of ModifyEndpointPolicies calls served
+func (c *FakeClient) BatchCalls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.batchCalls
+}
+
+// MaxBatchEndpoints implements BatchClient
+func (c *FakeClient) MaxBatchEndpoints() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.maxBatch
+}
+
+// ModifyEndpointPolicies implements BatchClient, costing a single modify call
+func (c *FakeClient) ModifyEndpointPolicies(changes []EndpointPolicyChange) []error {
+	policies := 0
+	for _, change := range changes {
+		policies += len(change.Request.Policies)
+	}
+	c.simulate(c.modifyDuration(policies))
+	c.mu.Lock()
+	c.batchCalls++
+	c.mu.Unlock()
+
+	errs := make([]error, len(changes))
+	for i, change := range changes {
+		if change.RequestType == hcn.RequestTypeRemove {
+			errs[i] = c.removePolicies(change.Endpoint, change.Request)
+		} else {
+			errs[i] = c.addPolicies(change.Endpoint, change.Request)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,88 @@
+//go:build windows
+
+package hcn
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+func TestFakeClient_ApplyAndRemove(t *testing.T) {
+	client := NewFakeClient(2)
+	manager := NewManager(client, logr.Discard())
+
+	if err := manager.ApplyACLRules("default/test", benchmarkRules(3)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	endpoints, _ := client.ListEndpoints()
+	if len(endpoints) != 2 || len(endpoints[0].Policies) != 3 {
+		t.Fatalf("Expected 3 policies on each of 2 endpoints, got %+v", endpoints)
+	}
+
+	if err := manager.RemoveACLRules("default/test"); err != nil {
+		t.Fatalf("RemoveACLRules failed: %v", err)
+	}
+	endpoint, err := client.GetEndpointByID(endpoints[1].Id)
+	if err != nil {
+		t.Fatalf("GetEndpointByID failed: %v", err)
+	}
+	if len(endpoint.Policies) != 0 {
+		t.Errorf("Expected policies removed, got %d", len(endpoint.Policies))
+	}
+}
+
+func TestFakeClient_RejectsInvalidSettings(t *testing.T) {
+	client := NewFakeClient(1)
+	endpoints, _ := client.ListEndpoints()
+
+	request := hcn.PolicyEndpointRequest{Policies: []hcn.EndpointPolicy{
+		{Type: hcn.ACL, Settings: []byte(`{"Priority":0}`)},
+	}}
+	if err := client.ApplyEndpointPolicy(&endpoints[0], hcn.RequestTypeAdd, request); err == nil {
+		t.Error("Expected priority 0 to be rejected")
+	}
+
+	if _, err := client.GetEndpointByID("missing"); !hcn.IsNotFoundError(err) {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestFakeClient_Latency(t *testing.T) {
+	client := NewFakeClient(4)
+	client.SetLatency(FakeLatency{Modify: 10 * time.Millisecond, MaxConcurrent: 1})
+	endpoints, _ := client.ListEndpoints()
+
+	// HNS serving one call at a time serializes concurrent requests
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			request := hcn.PolicyEndpointRequest{Policies: []hcn.EndpointPolicy{
+				{Type: hcn.ACL, Settings: []byte(`{"Priority":100}`)},
+			}}
+			if err := client.ApplyEndpointPolicy(&endpoints[i], hcn.RequestTypeAdd, request); err != nil {
+				t.Errorf("ApplyEndpointPolicy failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected 4 serialized calls to take at least 40ms, took %v", elapsed)
+	}
+
+	// The zero value turns the simulation off again
+	client.SetLatency(FakeLatency{})
+	start = time.Now()
+	if _, err := client.ListEndpoints(); err != nil {
+		t.Fatalf("ListEndpoints failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Errorf("Expected an instant call, took %v", elapsed)
+	}
+}
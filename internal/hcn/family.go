@@ -0,0 +1,104 @@
+//go:build windows
+
+package hcn
+
+import (
+	"strings"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// ipFamilies are the IP families an endpoint has addresses in
+type ipFamilies struct {
+	v4, v6 bool
+}
+
+// endpointFamilies returns the IP families of endpoint's IP configurations.
+// Endpoints without any are treated as having both, so nothing is withheld
+// from them.
+func endpointFamilies(endpoint hcn.HostComputeEndpoint) ipFamilies {
+	var families ipFamilies
+	for _, ipConfig := range endpoint.IpConfigurations {
+		if isIPv6(ipConfig.IpAddress) {
+			families.v6 = true
+		} else if ipConfig.IpAddress != "" {
+			families.v4 = true
+		}
+	}
+	if !families.v4 && !families.v6 {
+		return ipFamilies{v4: true, v6: true}
+	}
+	return families
+}
+
+// isIPv6 reports whether an IP or CIDR is IPv6; only IPv6 forms contain a colon
+func isIPv6(address string) bool {
+	return strings.Contains(address, ":")
+}
+
+// dualStack reports whether the endpoint has addresses in both families
+func (f ipFamilies) dualStack() bool {
+	return f.v4 && f.v6
+}
+
+// has reports whether the family of address is one of f
+func (f ipFamilies) has(address string) bool {
+	if isIPv6(address) {
+		return f.v6
+	}
+	return f.v4
+}
+
+// rulesForFamilies returns the rules that can match traffic of an endpoint in
+// families: remote addresses of the other family are dropped, and rules left
+// with none are dropped entirely, since HNS would otherwise match their
+// remaining (empty) address list against any address. Rules without remote
+// addresses are kept. rules is returned unchanged when nothing is dropped.
+func rulesForFamilies(rules []ACLRule, families ipFamilies) []ACLRule {
+	if families.dualStack() {
+		return rules
+	}
+
+	var filtered []ACLRule
+	for i, rule := range rules {
+		addresses, changed := addressesForFamilies(rule.RemoteAddresses, families)
+		if !changed {
+			if filtered != nil {
+				filtered = append(filtered, rule)
+			}
+			continue
+		}
+		if filtered == nil {
+			filtered = make([]ACLRule, i, len(rules))
+			copy(filtered, rules[:i])
+		}
+		if addresses == "" {
+			continue
+		}
+		rule.RemoteAddresses = addresses
+		filtered = append(filtered, rule)
+	}
+	if filtered == nil {
+		return rules
+	}
+	return filtered
+}
+
+// addressesForFamilies drops the entries of a comma-separated address list
+// outside families and reports whether any was dropped
+func addressesForFamilies(addresses string, families ipFamilies) (string, bool) {
+	if addresses == "" {
+		return "", false
+	}
+	entries := strings.Split(addresses, ",")
+	kept := entries[:0]
+	for _, entry := range entries {
+		if families.has(strings.TrimSpace(entry)) {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) == len(entries) {
+		return addresses, false
+	}
+	return strings.Join(kept, ","), true
+}
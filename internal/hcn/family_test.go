@@ -0,0 +1,70 @@
+//go:build windows
+
+package hcn
+
+import (
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+func TestRulesForFamilies(t *testing.T) {
+	rules := []ACLRule{
+		{Name: "any", RemoteAddresses: "0.0.0.0/0,::/0"},
+		{Name: "v6-only", RemoteAddresses: "fd00::/64"},
+		{Name: "no-peers"},
+		{Name: "v4-only", RemoteAddresses: "10.0.0.0/8"},
+	}
+	tests := []struct {
+		name      string
+		families  ipFamilies
+		wantNames []string
+		wantAny   string
+	}{
+		{name: "dual-stack", families: ipFamilies{v4: true, v6: true},
+			wantNames: []string{"any", "v6-only", "no-peers", "v4-only"}, wantAny: "0.0.0.0/0,::/0"},
+		{name: "IPv4", families: ipFamilies{v4: true},
+			wantNames: []string{"any", "no-peers", "v4-only"}, wantAny: "0.0.0.0/0"},
+		{name: "IPv6", families: ipFamilies{v6: true},
+			wantNames: []string{"any", "v6-only", "no-peers"}, wantAny: "::/0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rulesForFamilies(rules, tt.families)
+			if len(got) != len(tt.wantNames) {
+				t.Fatalf("Expected rules %v, got %+v", tt.wantNames, got)
+			}
+			for i, name := range tt.wantNames {
+				if got[i].Name != name {
+					t.Errorf("rules[%d] = %s, want %s", i, got[i].Name, name)
+				}
+			}
+			if got[0].RemoteAddresses != tt.wantAny {
+				t.Errorf("Expected remote addresses %q, got %q", tt.wantAny, got[0].RemoteAddresses)
+			}
+		})
+	}
+	if rules[0].RemoteAddresses != "0.0.0.0/0,::/0" {
+		t.Error("Expected the input rules left unchanged")
+	}
+}
+
+func TestDesiredRulesFor_EndpointFamilies(t *testing.T) {
+	client := newMockHCNClient()
+	client.endpoints = []hcn.HostComputeEndpoint{
+		{Id: "ep-v4", IpConfigurations: []hcn.IpConfig{{IpAddress: "10.244.0.2"}}},
+		{Id: "ep-dual", IpConfigurations: []hcn.IpConfig{{IpAddress: "10.244.0.3"}, {IpAddress: "fd00::3"}}},
+	}
+	manager := NewManager(client, logr.Discard())
+	manager.desired.Set("default/web", []ACLRule{
+		{Name: "v6", Action: hcn.ActionTypeAllow, Direction: hcn.DirectionTypeIn, RemoteAddresses: "fd00::/64", Priority: 100},
+	})
+
+	if rules := manager.DesiredTable(client.endpoints[0])["default/web"]; len(rules) != 0 {
+		t.Errorf("Expected no IPv6 rules on an IPv4 endpoint, got %+v", rules)
+	}
+	if rules := manager.DesiredTable(client.endpoints[1])["default/web"]; len(rules) != 1 {
+		t.Errorf("Expected the IPv6 rule on a dual-stack endpoint, got %+v", rules)
+	}
+}
@@ -0,0 +1,295 @@
+//go:build windows
+
+package hcn
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// FaultKind names a failure the agent can be made to simulate
+type FaultKind string
+
+const (
+	// FaultPauseApplies holds every HCN add and remove call until the fault ends
+	FaultPauseApplies FaultKind = "pause-applies"
+
+	// FaultHNSOutage fails every HCN call as HNS does while the hns service is stopped
+	FaultHNSOutage FaultKind = "hns-outage"
+
+	// FaultReconcileDelay delays every NetworkPolicy reconcile by the fault's delay
+	FaultReconcileDelay FaultKind = "reconcile-delay"
+)
+
+// MaxFaultDuration bounds how long a fault lasts, so a game day cannot leave a
+// node degraded
+const MaxFaultDuration = time.Hour
+
+// errInjectedOutage carries HCN_E_MANAGER_STOPPED so it is classified like a real outage
+var errInjectedOutage = errors.New("injected HNS outage (0x803b0020)")
+
+// ParseFaultKind parses a fault kind of the admin API
+func ParseFaultKind(value string) (FaultKind, error) {
+	switch kind := FaultKind(value); kind {
+	case FaultPauseApplies, FaultHNSOutage, FaultReconcileDelay:
+		return kind, nil
+	default:
+		return "", fmt.Errorf("invalid fault %q: must be pause-applies, hns-outage or reconcile-delay", value)
+	}
+}
+
+// Fault is an active simulated failure
+type Fault struct {
+	Kind FaultKind `json:"kind"`
+
+	// Until is when the fault ends on its own
+	Until time.Time `json:"until"`
+
+	// Delay is how long reconciles wait under FaultReconcileDelay
+	Delay time.Duration `json:"delay,omitempty"`
+}
+
+// FaultInjector simulates failures on a live node for game days: HCN calls
+// are held or failed before they reach HNS and reconciles are slowed down, so
+// alerting can be exercised without changing the ACLs actually programmed.
+// Faults end after their duration or when cleared. A nil FaultInjector
+// injects nothing.
+type FaultInjector struct {
+	mu     sync.Mutex
+	faults map[FaultKind]Fault
+
+	// changed is closed and replaced whenever faults change, waking held calls
+	changed chan struct{}
+}
+
+// NewFaultInjector creates an injector with no active faults
+func NewFaultInjector() *FaultInjector {
+	return &FaultInjector{
+		faults:  make(map[FaultKind]Fault),
+		changed: make(chan struct{}),
+	}
+}
+
+// Inject activates a fault for duration, replacing an active fault of the same
+// kind. delay is required by FaultReconcileDelay and ignored otherwise.
+func (f *FaultInjector) Inject(kind FaultKind, duration, delay time.Duration) (Fault, error) {
+	if _, err := ParseFaultKind(string(kind)); err != nil {
+		return Fault{}, err
+	}
+	if duration <= 0 || duration > MaxFaultDuration {
+		return Fault{}, fmt.Errorf("invalid fault duration %s: must be positive and at most %s", duration, MaxFaultDuration)
+	}
+	fault := Fault{Kind: kind, Until: time.Now().Add(duration)}
+	if kind == FaultReconcileDelay {
+		if delay <= 0 {
+			return Fault{}, fmt.Errorf("invalid reconcile delay %s: must be positive", delay)
+		}
+		fault.Delay = delay
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults[kind] = fault
+	f.notifyLocked()
+	return fault, nil
+}
+
+// Clear ends the fault of kind, or every fault when kind is empty
+func (f *FaultInjector) Clear(kind FaultKind) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if kind == "" {
+		clear(f.faults)
+	} else {
+		delete(f.faults, kind)
+	}
+	f.notifyLocked()
+}
+
+// Active returns the faults in effect, sorted by kind
+func (f *FaultInjector) Active() []Fault {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	faults := make([]Fault, 0, len(f.faults))
+	for kind := range f.faults {
+		if fault, ok := f.activeLocked(kind); ok {
+			faults = append(faults, fault)
+		}
+	}
+	sort.Slice(faults, func(i, j int) bool { return faults[i].Kind < faults[j].Kind })
+	return faults
+}
+
+// ReconcileDelay returns how long a reconcile should wait, 0 without FaultReconcileDelay
+func (f *FaultInjector) ReconcileDelay() time.Duration {
+	fault, ok := f.active(FaultReconcileDelay)
+	if !ok {
+		return 0
+	}
+	return min(fault.Delay, time.Until(fault.Until))
+}
+
+// active returns the fault of kind if it is in effect
+func (f *FaultInjector) active(kind FaultKind) (Fault, bool) {
+	if f == nil {
+		return Fault{}, false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.activeLocked(kind)
+}
+
+// activeLocked returns the fault of kind if it is in effect, forgetting it once expired
+func (f *FaultInjector) activeLocked(kind FaultKind) (Fault, bool) {
+	fault, ok := f.faults[kind]
+	if ok && !time.Now().Before(fault.Until) {
+		delete(f.faults, kind)
+		return Fault{}, false
+	}
+	return fault, ok
+}
+
+// notifyLocked wakes the calls held by FaultPauseApplies
+func (f *FaultInjector) notifyLocked() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// waitWhilePaused blocks while FaultPauseApplies is in effect
+func (f *FaultInjector) waitWhilePaused() {
+	for {
+		f.mu.Lock()
+		fault, paused := f.activeLocked(FaultPauseApplies)
+		changed := f.changed
+		f.mu.Unlock()
+		if !paused {
+			return
+		}
+		timer := time.NewTimer(time.Until(fault.Until))
+		select {
+		case <-changed:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// SetFaultInjector routes the Manager's HCN calls through faults.
+// It must be called before the Manager starts reconciling.
+func (m *Manager) SetFaultInjector(faults *FaultInjector) {
+	client := &faultClient{HCNClient: m.client, faults: faults}
+	if _, ok := m.client.(NetworkClient); ok {
+		m.client = &faultNetworkClient{faultClient: client}
+		return
+	}
+	m.client = client
+}
+
+// faultClient applies the injected faults to the calls of the wrapped HCNClient
+type faultClient struct {
+	HCNClient
+	faults *FaultInjector
+}
+
+// read fails reads during an outage
+func (c *faultClient) read() error {
+	if _, outage := c.faults.active(FaultHNSOutage); outage {
+		return errInjectedOutage
+	}
+	return nil
+}
+
+// write holds writes while applies are paused, then fails them during an outage
+func (c *faultClient) write() error {
+	c.faults.waitWhilePaused()
+	return c.read()
+}
+
+// ListEndpoints implements HCNClient
+func (c *faultClient) ListEndpoints() ([]hcn.HostComputeEndpoint, error) {
+	if err := c.read(); err != nil {
+		return nil, err
+	}
+	return c.HCNClient.ListEndpoints()
+}
+
+// GetEndpointByID implements HCNClient
+func (c *faultClient) GetEndpointByID(id string) (*hcn.HostComputeEndpoint, error) {
+	if err := c.read(); err != nil {
+		return nil, err
+	}
+	return c.HCNClient.GetEndpointByID(id)
+}
+
+// ApplyEndpointPolicy implements HCNClient
+func (c *faultClient) ApplyEndpointPolicy(endpoint *hcn.HostComputeEndpoint, requestType hcn.RequestType, request hcn.PolicyEndpointRequest) error {
+	if err := c.write(); err != nil {
+		return err
+	}
+	return c.HCNClient.ApplyEndpointPolicy(endpoint, requestType, request)
+}
+
+// RemoveEndpointPolicy implements HCNClient
+func (c *faultClient) RemoveEndpointPolicy(endpoint *hcn.HostComputeEndpoint, requestType hcn.RequestType, request hcn.PolicyEndpointRequest) error {
+	if err := c.write(); err != nil {
+		return err
+	}
+	return c.HCNClient.RemoveEndpointPolicy(endpoint, requestType, request)
+}
+
+// MaxBatchEndpoints implements BatchClient for the wrapped client
+func (c *faultClient) MaxBatchEndpoints() int {
+	if batcher, ok := c.HCNClient.(BatchClient); ok {
+		return batcher.MaxBatchEndpoints()
+	}
+	return 0
+}
+
+// ModifyEndpointPolicies implements BatchClient; a failed batch fails every change
+func (c *faultClient) ModifyEndpointPolicies(changes []EndpointPolicyChange) []error {
+	if err := c.write(); err != nil {
+		errs := make([]error, len(changes))
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	return c.HCNClient.(BatchClient).ModifyEndpointPolicies(changes)
+}
+
+// faultNetworkClient is a faultClient for clients that also program networks
+type faultNetworkClient struct {
+	*faultClient
+}
+
+// ListNetworks implements NetworkClient
+func (c *faultNetworkClient) ListNetworks() ([]hcn.HostComputeNetwork, error) {
+	if err := c.read(); err != nil {
+		return nil, err
+	}
+	return c.HCNClient.(NetworkClient).ListNetworks()
+}
+
+// AddNetworkPolicy implements NetworkClient
+func (c *faultNetworkClient) AddNetworkPolicy(network *hcn.HostComputeNetwork, request hcn.PolicyNetworkRequest) error {
+	if err := c.write(); err != nil {
+		return err
+	}
+	return c.HCNClient.(NetworkClient).AddNetworkPolicy(network, request)
+}
+
+// RemoveNetworkPolicy implements NetworkClient
+func (c *faultNetworkClient) RemoveNetworkPolicy(network *hcn.HostComputeNetwork, request hcn.PolicyNetworkRequest) error {
+	if err := c.write(); err != nil {
+		return err
+	}
+	return c.HCNClient.(NetworkClient).RemoveNetworkPolicy(network, request)
+}
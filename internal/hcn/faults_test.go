@@ -0,0 +1,109 @@
+//go:build windows
+
+package hcn
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+func TestFaultInjector_Inject(t *testing.T) {
+	faults := NewFaultInjector()
+	for _, tt := range []struct {
+		kind     FaultKind
+		duration time.Duration
+		delay    time.Duration
+	}{
+		{FaultHNSOutage, 0, 0},
+		{FaultHNSOutage, 2 * MaxFaultDuration, 0},
+		{FaultReconcileDelay, time.Minute, 0},
+		{"drop-packets", time.Minute, 0},
+	} {
+		if _, err := faults.Inject(tt.kind, tt.duration, tt.delay); err == nil {
+			t.Errorf("Expected %s for %s to be rejected", tt.kind, tt.duration)
+		}
+	}
+
+	if _, err := faults.Inject(FaultReconcileDelay, time.Minute, 5*time.Second); err != nil {
+		t.Fatalf("Inject failed: %v", err)
+	}
+	if delay := faults.ReconcileDelay(); delay != 5*time.Second {
+		t.Errorf("Expected a 5s reconcile delay, got %s", delay)
+	}
+	faults.Clear("")
+	if delay := faults.ReconcileDelay(); delay != 0 || len(faults.Active()) != 0 {
+		t.Errorf("Expected no faults after clearing, got %+v", faults.Active())
+	}
+
+	var none *FaultInjector
+	if none.ReconcileDelay() != 0 || none.Active() != nil {
+		t.Error("Expected a nil injector to inject nothing")
+	}
+}
+
+func TestFaultInjector_HNSOutage(t *testing.T) {
+	faults := NewFaultInjector()
+	manager := NewManager(NewFakeClient(1), logr.Discard())
+	manager.SetFaultInjector(faults)
+
+	if _, err := faults.Inject(FaultHNSOutage, time.Minute, 0); err != nil {
+		t.Fatalf("Inject failed: %v", err)
+	}
+	err := manager.ApplyACLRules("default/web", benchmarkRules(1))
+	if err == nil {
+		t.Fatal("Expected applies to fail during the outage")
+	}
+	if hint := ParseHNSError(err).Hint; hint != hintUnavailable {
+		t.Errorf("Expected the outage classified as HNS unavailable, got hint %q for %v", hint, err)
+	}
+
+	faults.Clear(FaultHNSOutage)
+	if err := manager.ApplyACLRules("default/web", benchmarkRules(1)); err != nil {
+		t.Errorf("Expected applies to succeed once the outage is cleared, got %v", err)
+	}
+}
+
+func TestFaultInjector_PauseApplies(t *testing.T) {
+	faults := NewFaultInjector()
+	manager := NewManager(NewFakeClient(1), logr.Discard())
+	manager.SetFaultInjector(faults)
+
+	if _, err := faults.Inject(FaultPauseApplies, time.Minute, 0); err != nil {
+		t.Fatalf("Inject failed: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- manager.ApplyACLRules("default/web", benchmarkRules(1)) }()
+
+	select {
+	case err := <-done:
+		t.Fatalf("Expected the apply to be held, it returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	faults.Clear(FaultPauseApplies)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("ApplyACLRules failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the apply to resume once the pause is cleared")
+	}
+}
+
+func TestFaultInjector_Expiry(t *testing.T) {
+	faults := NewFaultInjector()
+	if _, err := faults.Inject(FaultPauseApplies, 20*time.Millisecond, 0); err != nil {
+		t.Fatalf("Inject failed: %v", err)
+	}
+	start := time.Now()
+	faults.waitWhilePaused()
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("Expected the wait to last until the fault expired, took %s", elapsed)
+	}
+	if active := faults.Active(); len(active) != 0 {
+		t.Errorf("Expected the fault to be gone once expired, got %+v", active)
+	}
+}
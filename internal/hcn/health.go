@@ -0,0 +1,138 @@
+//go:build windows
+
+package hcn
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// HealthOptions tunes the HCN health probes
+type HealthOptions struct {
+	// Timeout is how long HCN may take to answer a probe
+	Timeout time.Duration
+
+	// LivenessGrace is how long HCN may fail before the liveness probe does;
+	// 0 leaves HCN out of the liveness probe
+	LivenessGrace time.Duration
+}
+
+// HealthChecker probes the HCN API for the agent's health endpoints: the
+// node must support the V2 API and HNS must list its networks in time. One
+// probe runs at a time; a call HNS never answers fails the probes after
+// Timeout instead of piling up behind it.
+type HealthChecker struct {
+	opts  HealthOptions
+	check func() error
+
+	mu sync.Mutex
+
+	// running is closed when the probe in flight returns; nil when none is
+	running chan struct{}
+	started time.Time
+
+	// lastErr is the outcome of the last probe that returned
+	lastErr error
+
+	// failingSince is when probes started failing; zero while they pass
+	failingSince time.Time
+}
+
+// NewHealthChecker creates a checker probing the HCN API of m
+func NewHealthChecker(m *Manager, opts HealthOptions) *HealthChecker {
+	return &HealthChecker{opts: opts, check: m.CheckHCN}
+}
+
+// CheckHCN returns an error when the node's HNS lacks the V2 API or does not
+// answer a listing
+func (m *Manager) CheckHCN() error {
+	if err := hcn.V2ApiSupported(); err != nil {
+		return fmt.Errorf("HCN V2 API unavailable: %w", err)
+	}
+	var err error
+	if client, ok := m.client.(NetworkClient); ok {
+		_, err = client.ListNetworks()
+	} else {
+		_, err = m.client.ListEndpoints()
+	}
+	if err != nil {
+		return fmt.Errorf("HCN unreachable: %w", ParseHNSError(err))
+	}
+	return nil
+}
+
+// ReadyzCheck fails while HCN does not answer. It is a healthz.Checker.
+func (c *HealthChecker) ReadyzCheck(_ *http.Request) error {
+	return c.probe()
+}
+
+// HealthzCheck fails once HCN has not answered for LivenessGrace, so the
+// kubelet restarts the agent. It is a healthz.Checker.
+func (c *HealthChecker) HealthzCheck(_ *http.Request) error {
+	if c.opts.LivenessGrace <= 0 {
+		return nil
+	}
+	err := c.probe()
+	if err == nil {
+		return nil
+	}
+	c.mu.Lock()
+	failing := time.Since(c.failingSince)
+	c.mu.Unlock()
+	if failing < c.opts.LivenessGrace {
+		return nil
+	}
+	return fmt.Errorf("failing for %s: %w", failing.Round(time.Second), err)
+}
+
+// probe returns the outcome of a probe, starting one unless one is in flight
+func (c *HealthChecker) probe() error {
+	c.mu.Lock()
+	if c.running == nil {
+		c.running = make(chan struct{})
+		c.started = time.Now()
+		go c.run(c.running)
+	}
+	running, deadline := c.running, c.started.Add(c.opts.Timeout)
+	c.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-running:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.lastErr
+	case <-timer.C:
+		err := fmt.Errorf("HCN did not answer within %s", c.opts.Timeout)
+		c.mu.Lock()
+		c.observeLocked(err)
+		c.mu.Unlock()
+		return err
+	}
+}
+
+// run probes HCN and records the outcome
+func (c *HealthChecker) run(running chan struct{}) {
+	err := c.check()
+	c.mu.Lock()
+	c.lastErr = err
+	c.observeLocked(err)
+	c.running = nil
+	c.mu.Unlock()
+	close(running)
+}
+
+// observeLocked tracks since when probes fail
+func (c *HealthChecker) observeLocked(err error) {
+	switch {
+	case err == nil:
+		c.failingSince = time.Time{}
+	case c.failingSince.IsZero():
+		c.failingSince = time.Now()
+	}
+}
@@ -0,0 +1,69 @@
+//go:build windows
+
+package hcn
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthChecker_Readiness(t *testing.T) {
+	var checkErr error
+	checker := &HealthChecker{opts: HealthOptions{Timeout: time.Second}, check: func() error { return checkErr }}
+
+	if err := checker.ReadyzCheck(nil); err != nil {
+		t.Errorf("Expected readiness while HCN answers, got %v", err)
+	}
+	checkErr = errors.New("HCN unreachable")
+	if err := checker.ReadyzCheck(nil); err == nil {
+		t.Error("Expected readiness to fail while HCN does not answer")
+	}
+	checkErr = nil
+	if err := checker.ReadyzCheck(nil); err != nil {
+		t.Errorf("Expected readiness once HCN answers again, got %v", err)
+	}
+}
+
+func TestHealthChecker_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	checker := &HealthChecker{opts: HealthOptions{Timeout: 20 * time.Millisecond}, check: func() error {
+		calls.Add(1)
+		<-release
+		return nil
+	}}
+
+	for i := 0; i < 3; i++ {
+		if err := checker.ReadyzCheck(nil); err == nil {
+			t.Fatal("Expected readiness to fail while HCN hangs")
+		}
+	}
+	close(release)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected probes to wait on the call in flight, got %d calls", n)
+	}
+}
+
+func TestHealthChecker_LivenessGrace(t *testing.T) {
+	checker := &HealthChecker{
+		opts:  HealthOptions{Timeout: time.Second, LivenessGrace: time.Minute},
+		check: func() error { return errors.New("HCN unreachable") },
+	}
+	if err := checker.HealthzCheck(nil); err != nil {
+		t.Errorf("Expected liveness within the grace period, got %v", err)
+	}
+
+	checker.mu.Lock()
+	checker.failingSince = time.Now().Add(-2 * time.Minute)
+	checker.mu.Unlock()
+	if err := checker.HealthzCheck(nil); err == nil {
+		t.Error("Expected liveness to fail after the grace period")
+	}
+
+	checker.opts.LivenessGrace = 0
+	if err := checker.HealthzCheck(nil); err != nil {
+		t.Errorf("Expected HCN left out of liveness without a grace period, got %v", err)
+	}
+}
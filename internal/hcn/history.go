@@ -0,0 +1,116 @@
+//go:build windows
+
+package hcn
+
+import (
+	"errors"
+	"maps"
+	"reflect"
+	"time"
+)
+
+// ErrHistoryDisabled is returned by history reads when no rule history is kept
+var ErrHistoryDisabled = errors.New("rule history is disabled")
+
+// RuleTableSnapshot is the controller-owned rule table of an endpoint right
+// after one policy changed on it
+type RuleTableSnapshot struct {
+	Time time.Time
+
+	// Cause is the key of the policy whose change produced the snapshot
+	Cause string
+
+	// Policies are the rules tracked on the endpoint, by policy key
+	Policies map[string][]ACLRule
+}
+
+// ruleHistory keeps the most recent rule table snapshots of each endpoint,
+// newest first. It is protected by the Manager's mu.
+type ruleHistory struct {
+	keep      int
+	endpoints map[string][]RuleTableSnapshot
+}
+
+// SetRuleHistory keeps the keep most recent snapshots of each endpoint's
+// controller-owned rule table, for debugging how rules evolved; 0 disables it.
+// It must be called before the Manager starts reconciling.
+func (m *Manager) SetRuleHistory(keep int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if keep < 1 {
+		m.history = nil
+		return
+	}
+	m.history = &ruleHistory{keep: keep, endpoints: make(map[string][]RuleTableSnapshot)}
+}
+
+// RuleHistory returns the rule table snapshots of an endpoint, newest first.
+// The snapshots share rule slices with the Manager and must not be modified.
+func (m *Manager) RuleHistory(endpointID string) ([]RuleTableSnapshot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.history == nil {
+		return nil, ErrHistoryDisabled
+	}
+	return append([]RuleTableSnapshot(nil), m.history.endpoints[endpointID]...), nil
+}
+
+// recordHistoryLocked snapshots every endpoint whose rules for policyKey
+// differ between the previous and new rule sets; callers hold mu
+func (m *Manager) recordHistoryLocked(policyKey string, previous, ruleSets []RuleSet) {
+	if m.history == nil {
+		return
+	}
+	rules := make(map[string][]ACLRule, len(ruleSets))
+	for _, ruleSet := range ruleSets {
+		rules[ruleSet.EndpointID] = ruleSet.Rules
+	}
+	now := time.Now()
+	for _, ruleSet := range previous {
+		if _, exists := rules[ruleSet.EndpointID]; !exists {
+			m.history.record(ruleSet.EndpointID, policyKey, nil, false, now)
+		}
+	}
+	for endpointID, endpointRules := range rules {
+		m.history.record(endpointID, policyKey, endpointRules, true, now)
+	}
+}
+
+// record adds a snapshot of endpointID with policyKey's rules replaced, or
+// removed when programmed is false, unless the table is unchanged
+func (h *ruleHistory) record(endpointID, policyKey string, rules []ACLRule, programmed bool, now time.Time) {
+	snapshots := h.endpoints[endpointID]
+	var policies map[string][]ACLRule
+	if len(snapshots) > 0 {
+		current, tracked := snapshots[0].Policies[policyKey]
+		if tracked == programmed && reflect.DeepEqual(current, rules) {
+			return
+		}
+		policies = maps.Clone(snapshots[0].Policies)
+	} else if !programmed {
+		return
+	} else {
+		policies = make(map[string][]ACLRule, 1)
+	}
+
+	if programmed {
+		policies[policyKey] = rules
+	} else {
+		delete(policies, policyKey)
+	}
+	snapshot := RuleTableSnapshot{Time: now, Cause: policyKey, Policies: policies}
+	h.endpoints[endpointID] = append([]RuleTableSnapshot{snapshot}, snapshots[:min(len(snapshots), h.keep-1)]...)
+}
+
+// pruneHistoryLocked forgets the history of endpoints that no longer exist and hold
+// no rules; callers hold mu
+func (m *Manager) pruneHistoryLocked(live map[string]bool) {
+	if m.history == nil {
+		return
+	}
+	for endpointID, snapshots := range m.history.endpoints {
+		if !live[endpointID] && len(snapshots[0].Policies) == 0 {
+			delete(m.history.endpoints, endpointID)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+//go:build windows
+
+package hcn
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestRuleHistory(t *testing.T) {
+	manager := NewManager(NewFakeClient(2), logr.Discard())
+	if _, err := manager.RuleHistory("fake-endpoint-0"); !errors.Is(err, ErrHistoryDisabled) {
+		t.Fatalf("Expected history disabled by default, got %v", err)
+	}
+	manager.SetRuleHistory(2)
+
+	for _, rules := range [][]ACLRule{benchmarkRules(1), benchmarkRules(2), benchmarkRules(2)} {
+		if err := manager.ApplyACLRules("default/web", rules); err != nil {
+			t.Fatalf("ApplyACLRules failed: %v", err)
+		}
+	}
+	if err := manager.ApplyACLRules("default/db", benchmarkRules(1)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	// An unchanged apply adds nothing; the oldest snapshot rotates out
+	history, err := manager.RuleHistory("fake-endpoint-0")
+	if err != nil {
+		t.Fatalf("RuleHistory failed: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 snapshots, got %d", len(history))
+	}
+	if history[0].Cause != "default/db" || len(history[0].Policies) != 2 {
+		t.Errorf("Expected the newest snapshot to hold both policies, got %+v", history[0])
+	}
+	if history[1].Cause != "default/web" || len(history[1].Policies["default/web"]) != 2 {
+		t.Errorf("Expected the older snapshot to hold the updated web rules, got %+v", history[1])
+	}
+
+	if err := manager.RemoveACLRules("default/web"); err != nil {
+		t.Fatalf("RemoveACLRules failed: %v", err)
+	}
+	history, _ = manager.RuleHistory("fake-endpoint-1")
+	if _, tracked := history[0].Policies["default/web"]; tracked || history[0].Cause != "default/web" {
+		t.Errorf("Expected a snapshot recording the removal, got %+v", history[0])
+	}
+}
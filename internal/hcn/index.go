@@ -0,0 +1,105 @@
+//go:build windows
+
+package hcn
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// EndpointIndex maps endpoint IDs, IP addresses and MAC addresses to HCN endpoints
+// so pod-driven lookups are O(1) instead of scanning ListEndpoints output
+type EndpointIndex struct {
+	mu sync.RWMutex
+
+	byID  map[string]hcn.HostComputeEndpoint
+	byIP  map[string]string // IP -> endpoint ID
+	byMAC map[string]string // normalized MAC -> endpoint ID
+}
+
+// NewEndpointIndex creates an empty endpoint index
+func NewEndpointIndex() *EndpointIndex {
+	return &EndpointIndex{
+		byID:  make(map[string]hcn.HostComputeEndpoint),
+		byIP:  make(map[string]string),
+		byMAC: make(map[string]string),
+	}
+}
+
+// Update replaces the index contents with the given endpoint listing
+func (idx *EndpointIndex) Update(endpoints []hcn.HostComputeEndpoint) {
+	byID := make(map[string]hcn.HostComputeEndpoint, len(endpoints))
+	byIP := make(map[string]string, len(endpoints))
+	byMAC := make(map[string]string, len(endpoints))
+
+	for _, endpoint := range endpoints {
+		byID[endpoint.Id] = endpoint
+		for _, ipConfig := range endpoint.IpConfigurations {
+			if ipConfig.IpAddress != "" {
+				byIP[ipConfig.IpAddress] = endpoint.Id
+			}
+		}
+		if endpoint.MacAddress != "" {
+			byMAC[normalizeMAC(endpoint.MacAddress)] = endpoint.Id
+		}
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.byID = byID
+	idx.byIP = byIP
+	idx.byMAC = byMAC
+}
+
+// ByID returns the indexed endpoint with the given ID
+func (idx *EndpointIndex) ByID(id string) (hcn.HostComputeEndpoint, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	endpoint, exists := idx.byID[id]
+	return endpoint, exists
+}
+
+// ByIP returns the endpoint owning the given IP address
+func (idx *EndpointIndex) ByIP(ip string) (hcn.HostComputeEndpoint, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	id, exists := idx.byIP[ip]
+	if !exists {
+		return hcn.HostComputeEndpoint{}, false
+	}
+	endpoint, exists := idx.byID[id]
+	return endpoint, exists
+}
+
+// ByMAC returns the endpoint with the given MAC address, in any common notation
+func (idx *EndpointIndex) ByMAC(mac string) (hcn.HostComputeEndpoint, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	id, exists := idx.byMAC[normalizeMAC(mac)]
+	if !exists {
+		return hcn.HostComputeEndpoint{}, false
+	}
+	endpoint, exists := idx.byID[id]
+	return endpoint, exists
+}
+
+// Len returns the number of indexed endpoints
+func (idx *EndpointIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.byID)
+}
+
+// IPLen returns the number of indexed IP addresses
+func (idx *EndpointIndex) IPLen() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.byIP)
+}
+
+// normalizeMAC converts "00:15:5D:AA:BB:CC" and "00-15-5d-aa-bb-cc" to one form
+func normalizeMAC(mac string) string {
+	return strings.ToLower(strings.ReplaceAll(mac, ":", "-"))
+}
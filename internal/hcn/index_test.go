@@ -0,0 +1,76 @@
+//go:build windows
+
+package hcn
+
+import (
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+func TestEndpointIndex_Lookups(t *testing.T) {
+	idx := NewEndpointIndex()
+	idx.Update([]hcn.HostComputeEndpoint{
+		{
+			Id:               "ep-1",
+			MacAddress:       "00-15-5D-AA-BB-01",
+			IpConfigurations: []hcn.IpConfig{{IpAddress: "10.0.0.5"}, {IpAddress: "fd00::5"}},
+		},
+		{
+			Id:               "ep-2",
+			MacAddress:       "00-15-5D-AA-BB-02",
+			IpConfigurations: []hcn.IpConfig{{IpAddress: "10.0.0.6"}},
+		},
+	})
+
+	if idx.Len() != 2 {
+		t.Errorf("Expected 2 indexed endpoints, got %d", idx.Len())
+	}
+
+	tests := []struct {
+		name     string
+		lookup   func() (hcn.HostComputeEndpoint, bool)
+		expected string
+	}{
+		{"by IPv4", func() (hcn.HostComputeEndpoint, bool) { return idx.ByIP("10.0.0.6") }, "ep-2"},
+		{"by IPv6", func() (hcn.HostComputeEndpoint, bool) { return idx.ByIP("fd00::5") }, "ep-1"},
+		{"by MAC colon notation", func() (hcn.HostComputeEndpoint, bool) { return idx.ByMAC("00:15:5d:aa:bb:01") }, "ep-1"},
+		{"by ID", func() (hcn.HostComputeEndpoint, bool) { return idx.ByID("ep-2") }, "ep-2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint, exists := tt.lookup()
+			if !exists {
+				t.Fatal("Expected endpoint to be found")
+			}
+			if endpoint.Id != tt.expected {
+				t.Errorf("Got endpoint %s, want %s", endpoint.Id, tt.expected)
+			}
+		})
+	}
+
+	// A refresh drops endpoints that disappeared
+	idx.Update([]hcn.HostComputeEndpoint{{Id: "ep-2", IpConfigurations: []hcn.IpConfig{{IpAddress: "10.0.0.6"}}}})
+	if _, exists := idx.ByIP("10.0.0.5"); exists {
+		t.Error("Expected stale IP to be dropped from index")
+	}
+}
+
+func TestManager_IndexRefreshedOnApply(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{
+		{Id: "ep-1", IpConfigurations: []hcn.IpConfig{{IpAddress: "10.0.0.5"}}},
+	}
+
+	manager := NewManager(mockClient, logr.Discard())
+	if err := manager.ApplyACLRules("default/test-policy", nil); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	endpoint, exists := manager.EndpointByIP("10.0.0.5")
+	if !exists || endpoint.Id != "ep-1" {
+		t.Errorf("Expected ep-1 for 10.0.0.5, got %+v (exists=%v)", endpoint, exists)
+	}
+}
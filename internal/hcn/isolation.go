@@ -0,0 +1,194 @@
+//go:build windows
+
+package hcn
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// HyperVMode selects how endpoints of Hyper-V isolated containers are
+// programmed. Such a container runs in a utility VM: its endpoint lives in a
+// guest HNS namespace and its traffic leaves the VM through the virtual
+// switch, never reaching the host's WFP. Rules HNS enforces in the switch
+// (VFP) apply as they do to process-isolated containers, while rules enforced
+// in the host (RuleType Host) never match.
+type HyperVMode string
+
+const (
+	// HyperVIgnore programs every endpoint as process-isolated, without
+	// looking up their namespaces
+	HyperVIgnore HyperVMode = "ignore"
+
+	// HyperVEnforce programs Hyper-V isolated endpoints with their Host rules
+	// moved to the switch, where they are enforced
+	HyperVEnforce HyperVMode = "enforce"
+
+	// HyperVExclude programs no rules on Hyper-V isolated endpoints and logs a
+	// warning for each, leaving their enforcement to the operator
+	HyperVExclude HyperVMode = "exclude"
+)
+
+// ParseHyperVMode parses a --hyperv-endpoints value
+func ParseHyperVMode(value string) (HyperVMode, error) {
+	switch mode := HyperVMode(value); mode {
+	case HyperVIgnore, HyperVEnforce, HyperVExclude:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid Hyper-V endpoint mode %q: must be ignore, enforce or exclude", value)
+	}
+}
+
+// NamespaceClient is implemented by HCNClients that can look up HNS
+// namespaces, which tell process-isolated endpoints from Hyper-V isolated ones
+type NamespaceClient interface {
+	// GetNamespaceByID returns the HNS namespace with the given ID
+	GetNamespaceByID(id string) (*hcn.HostComputeNamespace, error)
+}
+
+// GetNamespaceByID implements NamespaceClient
+func (c *realHCNClient) GetNamespaceByID(id string) (*hcn.HostComputeNamespace, error) {
+	return c.getNamespaceByID(id)
+}
+
+// isolationModes tracks which endpoints are Hyper-V isolated
+type isolationModes struct {
+	mu   sync.RWMutex
+	mode HyperVMode
+
+	// namespaces looks up namespace types; nil when the client cannot
+	namespaces NamespaceClient
+
+	// guest maps namespace ID -> whether it is a guest namespace, for the
+	// namespaces of listed endpoints; a namespace's type never changes
+	guest map[string]bool
+
+	// isolated are the Hyper-V isolated endpoints of the last listing, by ID
+	isolated map[string]bool
+}
+
+// SetHyperVMode selects how endpoints of Hyper-V isolated containers are
+// programmed. Endpoints are classified from the type of their HNS namespace,
+// which needs a client implementing NamespaceClient; with other clients every
+// endpoint is taken as process-isolated.
+// It must be called before the Manager starts reconciling.
+func (m *Manager) SetHyperVMode(mode HyperVMode) {
+	m.isolation.mu.Lock()
+	defer m.isolation.mu.Unlock()
+	m.isolation.mode = mode
+	m.isolation.isolated = make(map[string]bool)
+}
+
+// HyperVEndpoints returns the IDs of the Hyper-V isolated endpoints of the
+// last listing, sorted
+func (m *Manager) HyperVEndpoints() []string {
+	m.isolation.mu.RLock()
+	defer m.isolation.mu.RUnlock()
+	ids := make([]string, 0, len(m.isolation.isolated))
+	for id := range m.isolation.isolated {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// refreshIsolation classifies the listed endpoints, looking up the namespaces
+// not seen before, and logs the Hyper-V isolated endpoints once each
+func (m *Manager) refreshIsolation(endpoints []hcn.HostComputeEndpoint) {
+	iso := &m.isolation
+	iso.mu.RLock()
+	mode, known := iso.mode, iso.guest
+	iso.mu.RUnlock()
+	if mode == HyperVIgnore || iso.namespaces == nil {
+		return
+	}
+
+	// Look namespaces up without holding mu; a failed lookup is retried by
+	// the next listing, the endpoint taken as process-isolated until then
+	guest := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		id := endpoint.HostComputeNamespace
+		if id == "" {
+			continue
+		}
+		if _, done := guest[id]; done {
+			continue
+		}
+		if isGuest, cached := known[id]; cached {
+			guest[id] = isGuest
+			continue
+		}
+		namespace, err := iso.namespaces.GetNamespaceByID(id)
+		if err != nil {
+			if !hcn.IsNotFoundError(err) {
+				m.logger.Error(err, "Failed to look up the HNS namespace of an endpoint",
+					append([]any{"endpointID", endpoint.Id, "namespaceID", id}, m.recordHNSError("namespace", err).LogKeys()...)...)
+			}
+			continue
+		}
+		guest[id] = namespace.Type == hcn.NamespaceTypeGuest || namespace.Type == hcn.NamespaceTypeGuestDefault
+	}
+
+	isolated := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		if guest[endpoint.HostComputeNamespace] {
+			isolated[endpoint.Id] = true
+		}
+	}
+
+	iso.mu.Lock()
+	previous := iso.isolated
+	iso.guest = guest
+	iso.isolated = isolated
+	iso.mu.Unlock()
+
+	for _, endpoint := range endpoints {
+		if !isolated[endpoint.Id] || previous[endpoint.Id] {
+			continue
+		}
+		if mode == HyperVExclude {
+			m.logger.Info("Endpoint belongs to a Hyper-V isolated container, excluding it: no rules are programmed on it",
+				"endpointID", endpoint.Id,
+				"endpointName", endpoint.Name,
+				"namespaceID", endpoint.HostComputeNamespace)
+			continue
+		}
+		m.logger.Info("Endpoint belongs to a Hyper-V isolated container, enforcing its rules in the switch",
+			"endpointID", endpoint.Id,
+			"endpointName", endpoint.Name,
+			"namespaceID", endpoint.HostComputeNamespace)
+	}
+}
+
+// hyperVMode returns the mode endpoint is programmed in when it is Hyper-V
+// isolated, and HyperVIgnore when it is taken as process-isolated
+func (m *Manager) hyperVMode(endpoint hcn.HostComputeEndpoint) HyperVMode {
+	m.isolation.mu.RLock()
+	defer m.isolation.mu.RUnlock()
+	if !m.isolation.isolated[endpoint.Id] {
+		return HyperVIgnore
+	}
+	return m.isolation.mode
+}
+
+// switchRules returns rules with every Host rule enforced in the switch
+// instead. rules is returned unchanged when none is a Host rule.
+func switchRules(rules []ACLRule) []ACLRule {
+	var moved []ACLRule
+	for i, rule := range rules {
+		if rule.RuleType != hcn.RuleTypeHost {
+			continue
+		}
+		if moved == nil {
+			moved = append([]ACLRule(nil), rules...)
+		}
+		moved[i].RuleType = hcn.RuleTypeSwitch
+	}
+	if moved == nil {
+		return rules
+	}
+	return moved
+}
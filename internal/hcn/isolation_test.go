@@ -0,0 +1,95 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+func TestParseHyperVMode(t *testing.T) {
+	for _, value := range []string{"ignore", "enforce", "exclude"} {
+		if mode, err := ParseHyperVMode(value); err != nil || string(mode) != value {
+			t.Errorf("ParseHyperVMode(%q) = %q, %v", value, mode, err)
+		}
+	}
+	if _, err := ParseHyperVMode("isolate"); err == nil {
+		t.Error("Expected an unknown mode to be rejected")
+	}
+}
+
+// ruleTypesOn returns the rule types of the ACLs programmed on an endpoint
+func ruleTypesOn(t *testing.T, client *FakeClient, endpointID string) []hcn.RuleType {
+	t.Helper()
+	endpoint, err := client.GetEndpointByID(endpointID)
+	if err != nil {
+		t.Fatalf("GetEndpointByID failed: %v", err)
+	}
+	var types []hcn.RuleType
+	for _, policy := range endpoint.Policies {
+		var setting hcn.AclPolicySetting
+		if err := json.Unmarshal(policy.Settings, &setting); err != nil {
+			t.Fatalf("Invalid ACL settings: %v", err)
+		}
+		types = append(types, setting.RuleType)
+	}
+	return types
+}
+
+func TestManager_HyperVEnforce(t *testing.T) {
+	client := NewFakeClient(3)
+	client.SetEndpointNamespace("fake-endpoint-0", hcn.NamespaceTypeGuest)
+	client.SetEndpointNamespace("fake-endpoint-1", hcn.NamespaceTypeHostDefault)
+	manager := NewManager(client, logr.Discard())
+	manager.SetACLCapabilities(ACLCapabilities{RuleType: true})
+	manager.SetHyperVMode(HyperVEnforce)
+
+	rules := benchmarkRules(2)
+	rules[0].RuleType = hcn.RuleTypeHost
+	if err := manager.ApplyACLRules("default/web", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	if got := manager.HyperVEndpoints(); len(got) != 1 || got[0] != "fake-endpoint-0" {
+		t.Errorf("Expected only the guest namespace endpoint detected, got %v", got)
+	}
+	if types := ruleTypesOn(t, client, "fake-endpoint-0"); len(types) != 2 || slices.Contains(types, hcn.RuleTypeHost) {
+		t.Errorf("Expected the Host rule moved to the switch on the Hyper-V endpoint, got %v", types)
+	}
+	for _, id := range []string{"fake-endpoint-1", "fake-endpoint-2"} {
+		if types := ruleTypesOn(t, client, id); len(types) != 2 || !slices.Contains(types, hcn.RuleTypeHost) {
+			t.Errorf("Expected the Host rule kept on process-isolated endpoint %s, got %v", id, types)
+		}
+	}
+	if stats := manager.Stats(); stats.HyperVEndpoints != 1 {
+		t.Errorf("Expected 1 Hyper-V endpoint, got %d", stats.HyperVEndpoints)
+	}
+}
+
+func TestManager_HyperVExclude(t *testing.T) {
+	client := NewFakeClient(2)
+	client.SetEndpointNamespace("fake-endpoint-0", hcn.NamespaceTypeGuestDefault)
+	manager := NewManager(client, logr.Discard())
+	if err := manager.ApplyACLRules("default/web", benchmarkRules(2)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	if types := ruleTypesOn(t, client, "fake-endpoint-0"); len(types) != 2 {
+		t.Fatalf("Expected the Hyper-V endpoint programmed while ignored, got %d rules", len(types))
+	}
+
+	// Excluding the endpoint removes the rules programmed on it
+	manager.SetHyperVMode(HyperVExclude)
+	if err := manager.Reconcile(); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if types := ruleTypesOn(t, client, "fake-endpoint-0"); len(types) != 0 {
+		t.Errorf("Expected no rules on the excluded Hyper-V endpoint, got %d", len(types))
+	}
+	if types := ruleTypesOn(t, client, "fake-endpoint-1"); len(types) != 2 {
+		t.Errorf("Expected the process-isolated endpoint untouched, got %d rules", len(types))
+	}
+}
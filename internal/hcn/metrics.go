@@ -0,0 +1,250 @@
+//go:build windows
+
+package hcn
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ManagerStats is a point-in-time view of the Manager's caches, used to spot
+// capacity issues on dense nodes
+type ManagerStats struct {
+	// DesiredPolicies is the number of NetworkPolicy keys in the desired state
+	DesiredPolicies int
+
+	// TrackedPolicies is the number of policy keys with tracked HCN policies
+	TrackedPolicies int
+
+	// TrackedRuleSets is the number of (policy, endpoint) pairs tracked
+	TrackedRuleSets int
+
+	// Endpoints is the number of endpoints in the index
+	Endpoints int
+
+	// EndpointIPs is the number of IP addresses in the index
+	EndpointIPs int
+
+	// PayloadCacheEntries is the number of cached ACL settings payloads
+	PayloadCacheEntries int
+
+	// AddressSetEntries is the total number of remote addresses across desired rules
+	AddressSetEntries int
+
+	// AddressSetMaxEntries is the largest remote address list of any desired rule
+	AddressSetMaxEntries int
+
+	// SuspendedEndpoints is the number of endpoints skipped after repeated failures
+	SuspendedEndpoints int
+
+	// RemoteSubnetConflicts is the number of Block rules covering the provider
+	// address of a remote subnet route, as of the last route refresh
+	RemoteSubnetConflicts int
+
+	// PriorityCollisions is the number of desired rules sharing their priority
+	// with an out-of-band ACL, as of the last drift check
+	PriorityCollisions int
+
+	// PausedEndpoints is the number of pod addresses enforcement is paused for
+	PausedEndpoints int
+
+	// ExemptEndpoints is the number of pod addresses exempt from every rule
+	ExemptEndpoints int
+
+	// HyperVEndpoints is the number of Hyper-V isolated endpoints, as of the
+	// last endpoint listing
+	HyperVEndpoints int
+}
+
+// Stats returns the current cache sizes
+func (m *Manager) Stats() ManagerStats {
+	stats := ManagerStats{
+		Endpoints:           m.index.Len(),
+		EndpointIPs:         m.index.IPLen(),
+		PayloadCacheEntries: m.payloads.len(),
+		SuspendedEndpoints:  len(m.SuspendedEndpoints()),
+
+		RemoteSubnetConflicts: m.remoteSubnetConflictCount(),
+		PriorityCollisions:    len(m.PriorityCollisions()),
+		PausedEndpoints:       len(m.PausedEndpoints()),
+		ExemptEndpoints:       len(m.ExemptEndpoints()),
+		HyperVEndpoints:       len(m.HyperVEndpoints()),
+	}
+
+	keys := m.desired.Keys()
+	stats.DesiredPolicies = len(keys)
+	for _, key := range keys {
+		rules, _ := m.desired.Get(key)
+		for _, rule := range rules {
+			if rule.RemoteAddresses == "" {
+				continue
+			}
+			entries := strings.Count(rule.RemoteAddresses, ",") + 1
+			stats.AddressSetEntries += entries
+			if entries > stats.AddressSetMaxEntries {
+				stats.AddressSetMaxEntries = entries
+			}
+		}
+	}
+
+	m.mu.RLock()
+	stats.TrackedPolicies = len(m.appliedPolicies)
+	for _, ruleSets := range m.appliedPolicies {
+		stats.TrackedRuleSets += len(ruleSets)
+	}
+	m.mu.RUnlock()
+
+	return stats
+}
+
+// ApplyStats are cumulative counts of rule programming since the Manager started
+type ApplyStats struct {
+	// Applies is the number of ApplyACLRules calls
+	Applies uint64
+
+	// Failures is the number of ApplyACLRules calls that failed on any endpoint
+	Failures uint64
+
+	// RulesProgrammed is the number of ACL policies added to endpoints
+	RulesProgrammed uint64
+
+	// LastDuration is the latency of the most recent ApplyACLRules call
+	LastDuration time.Duration
+}
+
+// applyCounters backs ApplyStats; they are updated without holding mu
+type applyCounters struct {
+	applies         atomic.Uint64
+	failures        atomic.Uint64
+	rulesProgrammed atomic.Uint64
+	lastDuration    atomic.Int64
+}
+
+// recordApply records the outcome of an ApplyACLRules call started at start
+func (m *Manager) recordApply(start time.Time, err *error) {
+	duration := time.Since(start)
+	m.applies.applies.Add(1)
+	if *err != nil {
+		m.applies.failures.Add(1)
+	}
+	m.applies.lastDuration.Store(int64(duration))
+	m.applyDuration.Observe(duration.Seconds())
+}
+
+// ApplyStats returns the cumulative rule programming counts
+func (m *Manager) ApplyStats() ApplyStats {
+	return ApplyStats{
+		Applies:         m.applies.applies.Load(),
+		Failures:        m.applies.failures.Load(),
+		RulesProgrammed: m.applies.rulesProgrammed.Load(),
+		LastDuration:    time.Duration(m.applies.lastDuration.Load()),
+	}
+}
+
+// managerCollector exports ManagerStats as Prometheus gauges, computed on scrape
+type managerCollector struct {
+	manager  *Manager
+	gauges   []managerGauge
+	counters []managerCounter
+}
+
+type managerCounter struct {
+	desc  *prometheus.Desc
+	value func(ApplyStats) uint64
+}
+
+type managerGauge struct {
+	desc  *prometheus.Desc
+	value func(ManagerStats) int
+}
+
+// Collector returns a Prometheus collector exporting the Manager's cache sizes,
+// apply counts and latency, rule churn, HNS error counts and dry-run changes
+func (m *Manager) Collector() prometheus.Collector {
+	gauge := func(name, help string, value func(ManagerStats) int) managerGauge {
+		return managerGauge{
+			desc:  prometheus.NewDesc(prometheus.BuildFQName("networkpolicy_agent", "hcn", name), help, nil, nil),
+			value: value,
+		}
+	}
+
+	counter := func(name, help string, value func(ApplyStats) uint64) managerCounter {
+		return managerCounter{
+			desc:  prometheus.NewDesc(prometheus.BuildFQName("networkpolicy_agent", "hcn", name), help, nil, nil),
+			value: value,
+		}
+	}
+
+	return &managerCollector{
+		manager: m,
+		counters: []managerCounter{
+			counter("rules_programmed_total", "Number of ACL policies added to HCN endpoints.",
+				func(s ApplyStats) uint64 { return s.RulesProgrammed }),
+			counter("apply_failures_total", "Number of policy applications that failed on any endpoint.",
+				func(s ApplyStats) uint64 { return s.Failures }),
+		},
+		gauges: []managerGauge{
+			gauge("desired_policies", "Number of NetworkPolicy keys in the desired state.",
+				func(s ManagerStats) int { return s.DesiredPolicies }),
+			gauge("tracked_policies", "Number of policy keys with tracked HCN policies.",
+				func(s ManagerStats) int { return s.TrackedPolicies }),
+			gauge("tracked_rule_sets", "Number of tracked (policy, endpoint) rule sets.",
+				func(s ManagerStats) int { return s.TrackedRuleSets }),
+			gauge("endpoints_cached", "Number of HCN endpoints in the endpoint index.",
+				func(s ManagerStats) int { return s.Endpoints }),
+			gauge("endpoint_ips_indexed", "Number of pod IP addresses in the endpoint index.",
+				func(s ManagerStats) int { return s.EndpointIPs }),
+			gauge("payload_cache_entries", "Number of cached ACL settings payloads.",
+				func(s ManagerStats) int { return s.PayloadCacheEntries }),
+			gauge("address_set_entries", "Total remote addresses across desired NetworkPolicy rules.",
+				func(s ManagerStats) int { return s.AddressSetEntries }),
+			gauge("address_set_max_entries", "Largest remote address list of any desired NetworkPolicy rule.",
+				func(s ManagerStats) int { return s.AddressSetMaxEntries }),
+			gauge("endpoints_suspended", "Number of endpoints suspended from policy syncs after repeated failures.",
+				func(s ManagerStats) int { return s.SuspendedEndpoints }),
+			gauge("remote_subnet_conflicts", "Number of Block rules covering the provider address of an overlay remote subnet route.",
+				func(s ManagerStats) int { return s.RemoteSubnetConflicts }),
+			gauge("priority_collisions", "Number of desired rules sharing their direction and priority with an out-of-band ACL.",
+				func(s ManagerStats) int { return s.PriorityCollisions }),
+			gauge("endpoints_paused", "Number of pod addresses whose endpoint carries no rules because enforcement is paused.",
+				func(s ManagerStats) int { return s.PausedEndpoints }),
+			gauge("endpoints_exempt", "Number of pod addresses whose endpoint carries no rules because the pod is exempt.",
+				func(s ManagerStats) int { return s.ExemptEndpoints }),
+			gauge("endpoints_hyperv", "Number of endpoints of Hyper-V isolated containers, detected unless Hyper-V endpoints are ignored.",
+				func(s ManagerStats) int { return s.HyperVEndpoints }),
+		},
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *managerCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, gauge := range c.gauges {
+		ch <- gauge.desc
+	}
+	for _, counter := range c.counters {
+		ch <- counter.desc
+	}
+	c.manager.hnsErrors.Describe(ch)
+	c.manager.applyDuration.Describe(ch)
+	c.manager.churn.Describe(ch)
+	c.manager.dryRunChanges.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *managerCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.manager.Stats()
+	for _, gauge := range c.gauges {
+		ch <- prometheus.MustNewConstMetric(gauge.desc, prometheus.GaugeValue, float64(gauge.value(stats)))
+	}
+	applyStats := c.manager.ApplyStats()
+	for _, counter := range c.counters {
+		ch <- prometheus.MustNewConstMetric(counter.desc, prometheus.CounterValue, float64(counter.value(applyStats)))
+	}
+	c.manager.hnsErrors.Collect(ch)
+	c.manager.applyDuration.Collect(ch)
+	c.manager.churn.Collect(ch)
+	c.manager.dryRunChanges.Collect(ch)
+}
@@ -0,0 +1,96 @@
+//go:build windows
+
+package hcn
+
+import (
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestManagerStats(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{
+		{Id: "ep-1", IpConfigurations: []hcn.IpConfig{{IpAddress: "10.0.0.1"}}},
+		{Id: "ep-2", IpConfigurations: []hcn.IpConfig{{IpAddress: "10.0.0.2"}}},
+	}
+	manager := NewManager(mockClient, logr.Discard())
+
+	rules := []ACLRule{
+		{Name: "a", Action: hcn.ActionTypeAllow, Direction: hcn.DirectionTypeIn, RemoteAddresses: "10.1.0.0/16,10.2.0.0/16,10.3.0.1", Priority: 100},
+		{Name: "b", Action: hcn.ActionTypeAllow, Direction: hcn.DirectionTypeOut, Priority: 101},
+	}
+	if err := manager.ApplyACLRules("default/p", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	stats := manager.Stats()
+	expected := ManagerStats{
+		DesiredPolicies:      1,
+		TrackedPolicies:      1,
+		TrackedRuleSets:      2,
+		Endpoints:            2,
+		EndpointIPs:          2,
+		PayloadCacheEntries:  2,
+		AddressSetEntries:    3,
+		AddressSetMaxEntries: 3,
+	}
+	if stats != expected {
+		t.Errorf("Stats() = %+v, want %+v", stats, expected)
+	}
+
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(manager.Collector()); err != nil {
+		t.Fatalf("Failed to register collector: %v", err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	if len(families) != 17 {
+		t.Errorf("Expected 17 metric families, got %d", len(families))
+	}
+
+	applyStats := manager.ApplyStats()
+	if applyStats.Applies != 1 || applyStats.Failures != 0 || applyStats.RulesProgrammed != 4 {
+		t.Errorf("ApplyStats() = %+v, want 1 apply, 0 failures and 4 rules programmed", applyStats)
+	}
+}
+
+func TestChurnMetrics(t *testing.T) {
+	client := NewFakeClient(2)
+	manager := NewManager(client, logr.Discard())
+	if err := manager.ApplyACLRules("default/p", benchmarkRules(3)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	// One rule changes: one removal and one addition per endpoint
+	rules := benchmarkRules(3)
+	rules[2].LocalPorts = "9999"
+	if err := manager.ApplyACLRules("default/p", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	if got := testutil.ToFloat64(manager.churn.byPolicy.WithLabelValues("default/p", "add")); got != 8 {
+		t.Errorf("Expected 8 policy additions, got %v", got)
+	}
+	if got := testutil.ToFloat64(manager.churn.byPolicy.WithLabelValues("default/p", "remove")); got != 2 {
+		t.Errorf("Expected 2 policy removals, got %v", got)
+	}
+	if got := testutil.ToFloat64(manager.churn.byEndpoint.WithLabelValues("fake-endpoint-0", "add")); got != 4 {
+		t.Errorf("Expected 4 additions on fake-endpoint-0, got %v", got)
+	}
+
+	// Removing the policy everywhere drops its series
+	if err := manager.RemoveACLRules("default/p"); err != nil {
+		t.Fatalf("RemoveACLRules failed: %v", err)
+	}
+	if deleted := manager.churn.byPolicy.DeletePartialMatch(prometheus.Labels{"policy": "default/p"}); deleted != 0 {
+		t.Errorf("Expected the policy series to be dropped, %d remained", deleted)
+	}
+	if got := testutil.CollectAndCount(manager.churn.byEndpoint); got != 4 {
+		t.Errorf("Expected add and remove series for both endpoints, got %d", got)
+	}
+}
@@ -0,0 +1,242 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// ForeignAgent names another Windows network policy agent whose endpoint ACLs
+// can be migrated
+type ForeignAgent string
+
+const (
+	// ForeignAzureNPM is Azure Network Policy Manager
+	ForeignAzureNPM ForeignAgent = "azure-npm"
+
+	// ForeignCalico is Calico for Windows (Felix)
+	ForeignCalico ForeignAgent = "calico"
+)
+
+// foreignIDPrefixes are the prefixes of the Id each agent stamps on the ACLs
+// it programs; HNS keeps the Id, which is how their ACLs are told apart from
+// those of operators and of this agent
+var foreignIDPrefixes = map[ForeignAgent][]string{
+	ForeignAzureNPM: {"azure-acl-"},
+	ForeignCalico:   {"policy-", "profile-"},
+}
+
+// ParseForeignAgent parses a fwctl migrate --from value
+func ParseForeignAgent(value string) (ForeignAgent, error) {
+	agent := ForeignAgent(value)
+	if _, known := foreignIDPrefixes[agent]; !known {
+		return "", fmt.Errorf("invalid agent %q: must be azure-npm or calico", value)
+	}
+	return agent, nil
+}
+
+// MigrationMode selects what Migrate does with the ACLs of a foreign agent
+type MigrationMode string
+
+const (
+	// MigrationReport only lists the ACLs
+	MigrationReport MigrationMode = "report"
+
+	// MigrationRemove removes the ACLs from their endpoints
+	MigrationRemove MigrationMode = "remove"
+
+	// MigrationAdopt takes over the ACLs whose addresses are plain IPs and
+	// CIDRs and removes the others, which reference the agent's own address
+	// sets and stop meaning anything once it is gone
+	MigrationAdopt MigrationMode = "adopt"
+)
+
+// ParseMigrationMode parses a migration mode of the admin API
+func ParseMigrationMode(value string) (MigrationMode, error) {
+	switch mode := MigrationMode(value); mode {
+	case MigrationReport, MigrationRemove, MigrationAdopt:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid migration mode %q: must be report, remove or adopt", value)
+	}
+}
+
+// ForeignACL is an ACL a foreign agent programmed on an endpoint
+type ForeignACL struct {
+	EndpointID string `json:"endpointID"`
+
+	// ID is the Id the agent gave the ACL
+	ID string `json:"id"`
+
+	Rule ACLRule `json:"rule"`
+
+	// Adoptable is set for ACLs matching plain IPs and CIDRs only
+	Adoptable bool `json:"adoptable"`
+
+	// Outcome is what the migration did with the ACL: "adopted", "removed"
+	// or empty when it was only reported
+	Outcome string `json:"outcome,omitempty"`
+
+	policy hcn.EndpointPolicy
+}
+
+// MigratedPolicyKey keys the ACLs adopted from agent on an endpoint
+func MigratedPolicyKey(agent ForeignAgent, endpointID string) PolicyKey {
+	return NewPolicyKey(SourceMigrated, string(agent)+"/"+endpointID)
+}
+
+// Migrate finds the ACLs agent programmed on the node's endpoints and, unless
+// mode is MigrationReport, removes or adopts them. Adopted ACLs are tracked
+// and desired under MigratedPolicyKey, so they stay enforced while the
+// NetworkPolicies replacing them are rolled out, and are dropped by running
+// Migrate again in MigrationRemove mode. Adoption lasts until the agent
+// restarts: ACLs restored from the priority store are then no longer desired
+// and removed by the first reconcile, the others are out-of-band again.
+// Endpoints are backed up before their ACLs change.
+func (m *Manager) Migrate(agent ForeignAgent, mode MigrationMode) ([]ForeignACL, error) {
+	prefixes, known := foreignIDPrefixes[agent]
+	if !known {
+		return nil, fmt.Errorf("unknown agent %q", agent)
+	}
+	endpoints, err := m.listEndpoints()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list HCN endpoints: %w", err)
+	}
+
+	var found []ForeignACL
+	var migrateErrors []error
+	for i := range endpoints {
+		endpoint := &endpoints[i]
+		acls := foreignACLsOf(endpoint, prefixes)
+		if len(acls) == 0 || mode == MigrationReport {
+			found = append(found, acls...)
+			continue
+		}
+		if err := m.migrateEndpoint(agent, endpoint, mode, acls); err != nil {
+			migrateErrors = append(migrateErrors, fmt.Errorf("endpoint %s: %w", endpoint.Id, err))
+		}
+		found = append(found, acls...)
+	}
+	if mode != MigrationReport {
+		// Removed and adopted ACLs no longer hold priority slots
+		if endpoints, err := m.listEndpoints(); err == nil {
+			m.scanForeignACLs(endpoints)
+		}
+	}
+
+	m.logger.Info("Migrated ACLs of another agent", "agent", agent, "mode", mode, "aclCount", len(found))
+	return found, errors.Join(migrateErrors...)
+}
+
+// migrateEndpoint removes the ACLs of an endpoint, keeping the adoptable ones
+// in MigrationAdopt mode, and records the outcome in acls
+func (m *Manager) migrateEndpoint(agent ForeignAgent, endpoint *hcn.HostComputeEndpoint, mode MigrationMode, acls []ForeignACL) error {
+	m.backupEndpoint(endpoint.Id, "migrate")
+
+	var adopted, removed []hcn.EndpointPolicy
+	var adoptedRules []ACLRule
+	targets := endpointIPs(endpoint)
+	for i := range acls {
+		if mode == MigrationAdopt && acls[i].Adoptable && len(targets) > 0 {
+			acls[i].Outcome = "adopted"
+			adopted = append(adopted, acls[i].policy)
+			adoptedRules = append(adoptedRules, acls[i].Rule)
+			continue
+		}
+		removed = append(removed, acls[i].policy)
+	}
+
+	if len(removed) > 0 {
+		request := hcn.PolicyEndpointRequest{Policies: removed}
+		if err := m.client.RemoveEndpointPolicy(endpoint, hcn.RequestTypeRemove, request); err != nil {
+			m.recordHNSError("remove", err)
+			return err
+		}
+		for i := range acls {
+			if acls[i].Outcome == "" {
+				acls[i].Outcome = "removed"
+			}
+		}
+	}
+	key := MigratedPolicyKey(agent, endpoint.Id)
+	defer m.policyLocks.lock(string(key))()
+	if len(adopted) == 0 {
+		m.migrated.Delete(key)
+		m.setEndpointTracking(string(key), endpoint.Id, nil, nil)
+		return nil
+	}
+	m.setEndpointTracking(string(key), endpoint.Id, adopted, adoptedRules)
+	m.migrated.Set(key, targets, adoptedRules)
+	return nil
+}
+
+// foreignACLsOf returns the ACLs of an endpoint whose Id starts with one of prefixes
+func foreignACLsOf(endpoint *hcn.HostComputeEndpoint, prefixes []string) []ForeignACL {
+	var acls []ForeignACL
+	for _, policy := range endpoint.Policies {
+		if policy.Type != hcn.ACL {
+			continue
+		}
+		// The Id is not part of hcn.AclPolicySetting
+		var setting struct {
+			ID string `json:"Id"`
+		}
+		if err := json.Unmarshal(policy.Settings, &setting); err != nil || !hasAnyPrefix(setting.ID, prefixes) {
+			continue
+		}
+		rule := ruleFromPolicy(policy)
+		rule.Name = setting.ID
+		acls = append(acls, ForeignACL{
+			EndpointID: endpoint.Id,
+			ID:         setting.ID,
+			Rule:       rule,
+			Adoptable:  plainAddresses(rule.RemoteAddresses) && plainAddresses(rule.LocalAddresses),
+			policy:     policy,
+		})
+	}
+	return acls
+}
+
+// plainAddresses reports whether an address list only holds IPs and CIDRs
+func plainAddresses(addresses string) bool {
+	if addresses == "" {
+		return true
+	}
+	for _, entry := range strings.Split(addresses, ",") {
+		entry = strings.TrimSpace(entry)
+		if _, err := netip.ParsePrefix(entry); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(entry); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// hasAnyPrefix reports whether s starts with one of prefixes
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// endpointIPs returns the IPs of an endpoint
+func endpointIPs(endpoint *hcn.HostComputeEndpoint) []string {
+	var ips []string
+	for _, ipConfig := range endpoint.IpConfigurations {
+		if ipConfig.IpAddress != "" {
+			ips = append(ips, ipConfig.IpAddress)
+		}
+	}
+	return ips
+}
@@ -0,0 +1,127 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+// addAgentACL programs an ACL carrying the Id another agent would give it
+func addAgentACL(t *testing.T, client *FakeClient, endpointID, id, remoteAddresses string, priority uint16) {
+	t.Helper()
+	settings, err := json.Marshal(map[string]interface{}{
+		"Id":              id,
+		"Action":          hcn.ActionTypeBlock,
+		"Direction":       hcn.DirectionTypeIn,
+		"RemoteAddresses": remoteAddresses,
+		"Priority":        priority,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint, err := client.GetEndpointByID(endpointID)
+	if err != nil {
+		t.Fatalf("GetEndpointByID failed: %v", err)
+	}
+	request := hcn.PolicyEndpointRequest{Policies: []hcn.EndpointPolicy{{Type: hcn.ACL, Settings: settings}}}
+	if err := client.ApplyEndpointPolicy(endpoint, hcn.RequestTypeAdd, request); err != nil {
+		t.Fatalf("ApplyEndpointPolicy failed: %v", err)
+	}
+}
+
+func TestParseForeignAgent(t *testing.T) {
+	for _, value := range []string{"azure-npm", "calico"} {
+		if agent, err := ParseForeignAgent(value); err != nil || string(agent) != value {
+			t.Errorf("ParseForeignAgent(%q) = %q, %v", value, agent, err)
+		}
+	}
+	if _, err := ParseForeignAgent("cilium"); err == nil {
+		t.Error("Expected an unknown agent to be rejected")
+	}
+}
+
+func TestMigrate_Report(t *testing.T) {
+	client := NewFakeClient(1)
+	addAgentACL(t, client, "fake-endpoint-0", "azure-acl-web-in", "10.0.0.0/8", 200)
+	addAgentACL(t, client, "fake-endpoint-0", "policy-other", "10.0.0.1", 201)
+	addOutOfBandACL(t, client, "fake-endpoint-0", 300)
+	manager := NewManager(client, logr.Discard())
+
+	acls, err := manager.Migrate(ForeignAzureNPM, MigrationReport)
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if len(acls) != 1 || acls[0].ID != "azure-acl-web-in" || !acls[0].Adoptable || acls[0].Outcome != "" {
+		t.Fatalf("Expected the Azure NPM ACL reported, got %+v", acls)
+	}
+	if got := programmedPriorities(t, client, "fake-endpoint-0"); !equalPriorities(got, []uint16{200, 201, 300}) {
+		t.Errorf("Expected a report to leave ACLs alone, got priorities %v", got)
+	}
+}
+
+func TestMigrate_Remove(t *testing.T) {
+	client := NewFakeClient(1)
+	addAgentACL(t, client, "fake-endpoint-0", "policy-default.web", "10.0.0.0/8", 200)
+	addAgentACL(t, client, "fake-endpoint-0", "profile-kns.default", "", 201)
+	addOutOfBandACL(t, client, "fake-endpoint-0", 300)
+	manager := NewManager(client, logr.Discard())
+
+	acls, err := manager.Migrate(ForeignCalico, MigrationRemove)
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if len(acls) != 2 || acls[0].Outcome != "removed" || acls[1].Outcome != "removed" {
+		t.Fatalf("Expected both Calico ACLs removed, got %+v", acls)
+	}
+	if got := programmedPriorities(t, client, "fake-endpoint-0"); !equalPriorities(got, []uint16{300}) {
+		t.Errorf("Expected only the operator ACL left, got priorities %v", got)
+	}
+}
+
+func TestMigrate_Adopt(t *testing.T) {
+	client := NewFakeClient(1)
+	addAgentACL(t, client, "fake-endpoint-0", "azure-acl-web-in", "10.0.0.0/8", 200)
+	addAgentACL(t, client, "fake-endpoint-0", "azure-acl-web-set", "azure-npm-ipset-1", 201)
+	manager := NewManager(client, logr.Discard())
+
+	acls, err := manager.Migrate(ForeignAzureNPM, MigrationAdopt)
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	outcomes := make(map[string]string)
+	for _, acl := range acls {
+		outcomes[acl.ID] = acl.Outcome
+	}
+	if outcomes["azure-acl-web-in"] != "adopted" || outcomes["azure-acl-web-set"] != "removed" {
+		t.Fatalf("Expected the CIDR ACL adopted and the IP set one removed, got %v", outcomes)
+	}
+
+	key := MigratedPolicyKey(ForeignAzureNPM, "fake-endpoint-0")
+	ruleSets, tracked := manager.GetAppliedPolicies(key)
+	if !tracked || len(ruleSets) != 1 || len(ruleSets[0].Rules) != 1 {
+		t.Fatalf("Expected the adopted ACL tracked under %s, got %+v", key, ruleSets)
+	}
+
+	// A reconcile keeps the adopted ACL in place
+	if err := manager.Reconcile(); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if got := programmedPriorities(t, client, "fake-endpoint-0"); !equalPriorities(got, []uint16{200}) {
+		t.Errorf("Expected the adopted ACL kept, got priorities %v", got)
+	}
+
+	// Removing afterwards drops the adoption
+	if _, err := manager.Migrate(ForeignAzureNPM, MigrationRemove); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if _, tracked := manager.GetAppliedPolicies(key); tracked {
+		t.Error("Expected the adoption dropped by a removal")
+	}
+	if got := programmedPriorities(t, client, "fake-endpoint-0"); len(got) != 0 {
+		t.Errorf("Expected no ACLs left, got priorities %v", got)
+	}
+}
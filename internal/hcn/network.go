@@ -0,0 +1,396 @@
+//go:build windows
+
+package hcn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// ErrNetworkPoliciesUnsupported is returned for network-scoped applies when the
+// HCN client cannot program HNS networks
+var ErrNetworkPoliciesUnsupported = errors.New("the HCN client cannot program network policies")
+
+// NetworkClient is implemented by HCNClients that can program policies on HNS
+// networks, for rules that belong to every endpoint of a network
+type NetworkClient interface {
+	// ListNetworks returns all HNS networks
+	ListNetworks() ([]hcn.HostComputeNetwork, error)
+
+	// AddNetworkPolicy adds policies to a network
+	AddNetworkPolicy(network *hcn.HostComputeNetwork, request hcn.PolicyNetworkRequest) error
+
+	// RemoveNetworkPolicy removes policies from a network
+	RemoveNetworkPolicy(network *hcn.HostComputeNetwork, request hcn.PolicyNetworkRequest) error
+}
+
+// NetworkApplier is implemented by HCNManagers that can program a policy's
+// rules once per HNS network instead of on each endpoint, for node-wide rules
+// that would otherwise be duplicated on every endpoint
+type NetworkApplier interface {
+	// ApplyNetworkACLRules declares the rules for policyKey and programs them on the networks of the node's endpoints
+	ApplyNetworkACLRules(ctx context.Context, policyKey PolicyKey, rules []ACLRule) error
+}
+
+// NetworkRuleSet tracks HCN policies applied to a specific network
+type NetworkRuleSet struct {
+	// NetworkID is the HNS network identifier
+	NetworkID string
+
+	// Policies are the NetworkACL policies that were applied (for removal).
+	// The slice may be shared with other NetworkRuleSets and must not be modified.
+	Policies []hcn.NetworkPolicy
+
+	// Rules are the ACL rules Policies were built from, in the same order
+	Rules []ACLRule
+}
+
+// ListNetworks implements NetworkClient
+func (c *realHCNClient) ListNetworks() ([]hcn.HostComputeNetwork, error) {
+	return c.listNetworks()
+}
+
+// AddNetworkPolicy implements NetworkClient
+func (c *realHCNClient) AddNetworkPolicy(network *hcn.HostComputeNetwork, request hcn.PolicyNetworkRequest) error {
+	return network.AddPolicy(request)
+}
+
+// RemoveNetworkPolicy implements NetworkClient
+func (c *realHCNClient) RemoveNetworkPolicy(network *hcn.HostComputeNetwork, request hcn.PolicyNetworkRequest) error {
+	return network.RemovePolicy(request)
+}
+
+// ApplyNetworkACLRules records rules as the desired state for policyKey on the
+// HNS networks hosting the node's endpoints and programs them there as
+// NetworkACL policies. Rules previously programmed for policyKey on the
+// endpoints themselves are removed afterwards, so a policy moves between
+// scopes without being enforced twice.
+func (m *Manager) ApplyNetworkACLRules(ctx context.Context, policyKey PolicyKey, rules []ACLRule) error {
+	key := string(policyKey)
+	m.logger.Info("Applying network ACL rules", "policyKey", policyKey, "ruleCount", len(rules))
+	if _, ok := m.client.(NetworkClient); !ok {
+		return ErrNetworkPoliciesUnsupported
+	}
+
+	stored := make([]ACLRule, len(rules))
+	copy(stored, rules)
+	m.mu.Lock()
+	m.networkDesired[key] = stored
+	m.mu.Unlock()
+
+	syncErr := m.syncNetworkPolicy(ctx, key)
+	m.desired.Delete(key)
+	if _, tracked := m.trackedRuleSets(key); !tracked {
+		return syncErr
+	}
+	removeErr := m.removeTracked(key)
+	if removeErr != nil {
+		removeErr = fmt.Errorf("failed to remove endpoint rules: %w", removeErr)
+	}
+	if syncErr != nil {
+		// removeTracked clears the sync error the network sync recorded
+		m.mu.Lock()
+		m.syncErrors[key] = syncErr.Error()
+		m.mu.Unlock()
+	}
+	return errors.Join(syncErr, removeErr)
+}
+
+// NetworkPolicyKeys returns the keys of every policy declared through ApplyNetworkACLRules, sorted
+func (m *Manager) NetworkPolicyKeys() []PolicyKey {
+	return typedKeys(m.networkKeys())
+}
+
+// networkKeys is NetworkPolicyKeys untyped, for indexing the Manager's maps
+func (m *Manager) networkKeys() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]string, 0, len(m.networkDesired))
+	for key := range m.networkDesired {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GetNetworkPolicies returns a copy of the network rule sets tracked for policyKey
+func (m *Manager) GetNetworkPolicies(policyKey PolicyKey) ([]NetworkRuleSet, bool) {
+	key := string(policyKey)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ruleSets, exists := m.networkApplied[key]
+	if !exists {
+		return nil, false
+	}
+	out := make([]NetworkRuleSet, len(ruleSets))
+	for i, ruleSet := range ruleSets {
+		out[i] = NetworkRuleSet{
+			NetworkID: ruleSet.NetworkID,
+			Policies:  append([]hcn.NetworkPolicy(nil), ruleSet.Policies...),
+			Rules:     append([]ACLRule(nil), ruleSet.Rules...),
+		}
+	}
+	return out, true
+}
+
+// syncNetworkPolicy converges the networks hosting the node's endpoints toward
+// the network rules desired for policyKey
+func (m *Manager) syncNetworkPolicy(ctx context.Context, policyKey string) error {
+	defer m.policyLocks.lock(policyKey)()
+
+	client, ok := m.client.(NetworkClient)
+	if !ok {
+		return ErrNetworkPoliciesUnsupported
+	}
+	networks, err := m.endpointNetworks(client)
+	if err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	rules := m.networkDesired[policyKey]
+	current := make(map[string]NetworkRuleSet)
+	for _, ruleSet := range m.networkApplied[policyKey] {
+		current[ruleSet.NetworkID] = ruleSet
+	}
+	m.mu.RUnlock()
+
+	var ruleSets []NetworkRuleSet
+	var applyErrors []error
+	for i := range networks {
+		network := &networks[i]
+		tracked := current[network.Id]
+		if ctx.Err() != nil {
+			applyErrors = append(applyErrors, fmt.Errorf("%w: network %s not reached: %w", ErrApplyInterrupted, network.Id, ctx.Err()))
+			if len(tracked.Policies) > 0 {
+				ruleSets = append(ruleSets, tracked)
+			}
+			continue
+		}
+
+		networkRules := excludeProviderAddresses(rules, m.excludedRoutes(network.Id))
+		policies, err := m.buildNetworkPolicies(networkRules)
+		if err != nil {
+			return fmt.Errorf("failed to build HCN policies: %w", err)
+		}
+		programmed, err := m.reconcileNetworkPolicy(client, network, tracked.Policies, policies)
+		programmedRules := networkRules
+		if err != nil {
+			m.logger.Error(err, "Failed to apply policy to network",
+				slices.Concat([]any{"networkID", network.Id, "networkName", network.Name},
+					provenanceLogKeys(networkRules), m.recordRuleError("network", err, networkRules).LogKeys())...)
+			applyErrors = append(applyErrors, fmt.Errorf("network %s: %w", network.Id, err))
+			programmedRules = nil
+		}
+		if len(programmed) > 0 {
+			ruleSets = append(ruleSets, NetworkRuleSet{
+				NetworkID: network.Id,
+				Policies:  programmed,
+				Rules:     programmedRules,
+			})
+		}
+	}
+
+	var syncErr error
+	if len(applyErrors) > 0 {
+		syncErr = fmt.Errorf("failed to apply policies to %d/%d networks: %w",
+			len(applyErrors), len(networks), errors.Join(applyErrors...))
+	}
+
+	m.mu.Lock()
+	if len(ruleSets) > 0 {
+		m.networkApplied[policyKey] = ruleSets
+	} else {
+		delete(m.networkApplied, policyKey)
+	}
+	if syncErr != nil {
+		m.syncErrors[policyKey] = syncErr.Error()
+	} else {
+		delete(m.syncErrors, policyKey)
+	}
+	m.mu.Unlock()
+
+	if syncErr != nil {
+		return syncErr
+	}
+	m.logger.Info("Successfully applied network ACL rules",
+		"policyKey", policyKey,
+		"networkCount", len(ruleSets))
+	return nil
+}
+
+// reconcileNetworkPolicy moves a network from the currently programmed policies
+// to the desired ones and returns the policies programmed on it afterwards
+func (m *Manager) reconcileNetworkPolicy(client NetworkClient, network *hcn.HostComputeNetwork, current, desired []hcn.NetworkPolicy) ([]hcn.NetworkPolicy, error) {
+	toRemove, toAdd := diffNetworkPolicies(current, desired)
+	programmed := current
+
+	if len(toRemove) > 0 {
+		if err := client.RemoveNetworkPolicy(network, hcn.PolicyNetworkRequest{Policies: toRemove}); err != nil {
+			return programmed, fmt.Errorf("remove stale policies: %w", err)
+		}
+		_, programmed = diffNetworkPolicies(toRemove, current)
+	}
+
+	if len(toAdd) > 0 {
+		if err := client.AddNetworkPolicy(network, hcn.PolicyNetworkRequest{Policies: toAdd}); err != nil {
+			return programmed, err
+		}
+		m.applies.rulesProgrammed.Add(uint64(len(toAdd)))
+	}
+
+	return desired, nil
+}
+
+// removeNetworkTracked drops the network rules desired for policyKey and
+// removes the policies programmed for it from their networks
+func (m *Manager) removeNetworkTracked(policyKey string) error {
+	defer m.policyLocks.lock(policyKey)()
+
+	m.mu.Lock()
+	delete(m.networkDesired, policyKey)
+	ruleSets, exists := m.networkApplied[policyKey]
+	delete(m.networkApplied, policyKey)
+	m.mu.Unlock()
+	if !exists {
+		return nil
+	}
+
+	client, ok := m.client.(NetworkClient)
+	if !ok {
+		return ErrNetworkPoliciesUnsupported
+	}
+	networks, err := client.ListNetworks()
+	if err != nil {
+		return fmt.Errorf("failed to list HNS networks: %w", err)
+	}
+	byID := make(map[string]*hcn.HostComputeNetwork, len(networks))
+	for i := range networks {
+		byID[networks[i].Id] = &networks[i]
+	}
+
+	var removeErrors []error
+	for _, ruleSet := range ruleSets {
+		// Policies of a deleted network went with it
+		network, found := byID[ruleSet.NetworkID]
+		if !found {
+			continue
+		}
+		if err := client.RemoveNetworkPolicy(network, hcn.PolicyNetworkRequest{Policies: ruleSet.Policies}); err != nil {
+			m.logger.Error(err, "Failed to remove policy from network",
+				append([]any{"networkID", ruleSet.NetworkID}, m.recordHNSError("network", err).LogKeys()...)...)
+			removeErrors = append(removeErrors, fmt.Errorf("network %s: %w", ruleSet.NetworkID, err))
+		}
+	}
+	if len(removeErrors) > 0 {
+		return fmt.Errorf("failed to remove policies from %d/%d networks: %w",
+			len(removeErrors), len(ruleSets), errors.Join(removeErrors...))
+	}
+
+	m.logger.Info("Successfully removed network ACL rules", "policyKey", policyKey)
+	return nil
+}
+
+// reconcileNetworks converges every network-scoped policy, so networks that
+// gained their first endpoint since the last apply are programmed too
+func (m *Manager) reconcileNetworks(ctx context.Context) []error {
+	var errs []error
+	for _, key := range m.networkKeys() {
+		if err := m.syncNetworkPolicy(ctx, key); err != nil {
+			errs = append(errs, fmt.Errorf("network policy %s: %w", key, err))
+			m.requestRequeue(key, RequeueSyncFailed)
+		}
+	}
+	return errs
+}
+
+// networkConverged reports whether the network's rules tracked for policyKey
+// are the rules desired for it
+func (m *Manager) networkConverged(policyKey, networkID string) bool {
+	routes := m.excludedRoutes(networkID)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	rules, desired := m.networkDesired[policyKey]
+	if !desired {
+		return false
+	}
+	rules = excludeProviderAddresses(rules, routes)
+	for _, ruleSet := range m.networkApplied[policyKey] {
+		if ruleSet.NetworkID == networkID {
+			return rulesEqual(ruleSet.Rules, rules)
+		}
+	}
+	return len(rules) == 0
+}
+
+// endpointNetworks lists the HNS networks hosting at least one of the node's
+// endpoints; networks the agent has no endpoints on are never programmed
+func (m *Manager) endpointNetworks(client NetworkClient) ([]hcn.HostComputeNetwork, error) {
+	endpoints, err := m.listEndpoints()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list HCN endpoints: %w", err)
+	}
+	hosting := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		hosting[endpoint.HostComputeNetwork] = true
+	}
+
+	networks, err := client.ListNetworks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list HNS networks: %w", err)
+	}
+	scoped := networks[:0]
+	for _, network := range networks {
+		if hosting[network.Id] {
+			scoped = append(scoped, network)
+		}
+	}
+	return scoped, nil
+}
+
+// buildNetworkPolicies converts ACLRules to HCN NetworkACL policies. The
+// NetworkACL settings share the endpoint ACL payload, so cached payloads are reused.
+func (m *Manager) buildNetworkPolicies(rules []ACLRule) ([]hcn.NetworkPolicy, error) {
+	policies := make([]hcn.NetworkPolicy, 0, len(rules))
+	for i, rule := range rules {
+		settingsJSON, err := m.payloads.get(rule)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal ACL setting for rule %d: %w", i, err)
+		}
+		policies = append(policies, hcn.NetworkPolicy{
+			Type:     hcn.NetworkACL,
+			Settings: settingsJSON,
+		})
+	}
+	return policies, nil
+}
+
+// diffNetworkPolicies is diffPolicies for network policies
+func diffNetworkPolicies(current, desired []hcn.NetworkPolicy) (toRemove, toAdd []hcn.NetworkPolicy) {
+	policyID := func(p hcn.NetworkPolicy) string {
+		return string(p.Type) + ":" + string(p.Settings)
+	}
+
+	desiredSet := make(map[string]bool, len(desired))
+	for _, p := range desired {
+		desiredSet[policyID(p)] = true
+	}
+	currentSet := make(map[string]bool, len(current))
+	for _, p := range current {
+		currentSet[policyID(p)] = true
+		if !desiredSet[policyID(p)] {
+			toRemove = append(toRemove, p)
+		}
+	}
+	for _, p := range desired {
+		if !currentSet[policyID(p)] {
+			toAdd = append(toAdd, p)
+		}
+	}
+	return toRemove, toAdd
+}
@@ -0,0 +1,117 @@
+//go:build windows
+
+package hcn
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+// programmedCounts returns the policies on the fake network and on each fake endpoint
+func programmedCounts(t *testing.T, client *FakeClient) (network int, endpoints []int) {
+	t.Helper()
+	networks, err := client.ListNetworks()
+	if err != nil {
+		t.Fatalf("ListNetworks failed: %v", err)
+	}
+	for _, n := range networks {
+		network += len(n.Policies)
+	}
+	listed, err := client.ListEndpoints()
+	if err != nil {
+		t.Fatalf("ListEndpoints failed: %v", err)
+	}
+	for _, endpoint := range listed {
+		endpoints = append(endpoints, len(endpoint.Policies))
+	}
+	return network, endpoints
+}
+
+func TestApplyNetworkACLRules(t *testing.T) {
+	client := NewFakeClient(3)
+	manager := NewManager(client, logr.Discard())
+	ctx := context.Background()
+	rules := benchmarkRules(2)
+
+	// Endpoint-scoped first, then moved to the network
+	if err := manager.ApplyACLRules("default/web", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	if err := manager.ApplyNetworkACLRules(ctx, "default/web", rules); err != nil {
+		t.Fatalf("ApplyNetworkACLRules failed: %v", err)
+	}
+	network, endpoints := programmedCounts(t, client)
+	if network != 2 {
+		t.Errorf("Expected 2 network policies, got %d", network)
+	}
+	for i, count := range endpoints {
+		if count != 0 {
+			t.Errorf("Expected endpoint %d's rules removed, got %d policies", i, count)
+		}
+	}
+	ruleSets, found := manager.GetNetworkPolicies("default/web")
+	if !found || len(ruleSets) != 1 || ruleSets[0].NetworkID != FakeNetworkID {
+		t.Fatalf("Expected the policy tracked on %s, got %+v", FakeNetworkID, ruleSets)
+	}
+	if keys := manager.DesiredPolicyKeys(); len(keys) != 1 || keys[0] != "default/web" {
+		t.Errorf("Expected the network policy among the desired keys, got %v", keys)
+	}
+
+	// Reapplying changed rules only swaps the difference
+	if err := manager.ApplyNetworkACLRules(ctx, "default/web", benchmarkRules(3)); err != nil {
+		t.Fatalf("ApplyNetworkACLRules failed: %v", err)
+	}
+	if network, _ := programmedCounts(t, client); network != 3 {
+		t.Errorf("Expected 3 network policies, got %d", network)
+	}
+
+	// A full reconcile keeps network-scoped rules
+	if err := manager.Reconcile(); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if network, _ := programmedCounts(t, client); network != 3 {
+		t.Errorf("Expected reconcile to keep 3 network policies, got %d", network)
+	}
+
+	// Back to the endpoints
+	if err := manager.ApplyACLRules("default/web", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	network, endpoints = programmedCounts(t, client)
+	if network != 0 {
+		t.Errorf("Expected the network policies removed, got %d", network)
+	}
+	for i, count := range endpoints {
+		if count != 2 {
+			t.Errorf("Expected 2 policies on endpoint %d, got %d", i, count)
+		}
+	}
+}
+
+func TestRemoveACLRules_NetworkScoped(t *testing.T) {
+	client := NewFakeClient(2)
+	manager := NewManager(client, logr.Discard())
+	if err := manager.ApplyNetworkACLRules(context.Background(), "default/web", benchmarkRules(2)); err != nil {
+		t.Fatalf("ApplyNetworkACLRules failed: %v", err)
+	}
+	if err := manager.RemoveACLRules("default/web"); err != nil {
+		t.Fatalf("RemoveACLRules failed: %v", err)
+	}
+	if network, _ := programmedCounts(t, client); network != 0 {
+		t.Errorf("Expected no network policies, got %d", network)
+	}
+	if keys := manager.NetworkPolicyKeys(); len(keys) != 0 {
+		t.Errorf("Expected no network policy keys, got %v", keys)
+	}
+}
+
+func TestApplyNetworkACLRules_Unsupported(t *testing.T) {
+	manager := NewManager(newMockHCNClient(), logr.Discard())
+	err := manager.ApplyNetworkACLRules(context.Background(), "default/web", benchmarkRules(1))
+	if !errors.Is(err, ErrNetworkPoliciesUnsupported) {
+		t.Errorf("Expected ErrNetworkPoliciesUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+//go:build windows
+
+package hcn
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	computenetwork = windows.NewLazySystemDLL("computenetwork.dll")
+
+	procHcnRegisterServiceCallback   = computenetwork.NewProc("HcnRegisterServiceCallback")
+	procHcnUnregisterServiceCallback = computenetwork.NewProc("HcnUnregisterServiceCallback")
+)
+
+// NotificationType is an HCN_NOTIFICATIONS value delivered by the HNS service callback
+type NotificationType uint32
+
+const (
+	// NotificationNamespaceCreate reports a new network namespace, created with each pod sandbox
+	NotificationNamespaceCreate NotificationType = 5
+
+	// NotificationNamespaceDelete reports a deleted network namespace
+	NotificationNamespaceDelete NotificationType = 6
+
+	// NotificationEndpointAttached reports an endpoint attached to a namespace or container
+	NotificationEndpointAttached NotificationType = 9
+
+	// NotificationEndpointDetached reports an endpoint detached from its namespace or container
+	NotificationEndpointDetached NotificationType = 16
+
+	// NotificationServiceDisconnect reports that HNS dropped the subscription, e.g. on restart
+	NotificationServiceDisconnect NotificationType = 0x01000000
+)
+
+// changesEndpoints reports whether a notification may add or remove endpoints
+func (t NotificationType) changesEndpoints() bool {
+	switch t {
+	case NotificationNamespaceCreate, NotificationNamespaceDelete,
+		NotificationEndpointAttached, NotificationEndpointDetached, NotificationServiceDisconnect:
+		return true
+	default:
+		return false
+	}
+}
+
+// Notification is an HNS service notification
+type Notification struct {
+	Type NotificationType
+
+	// Data is the JSON document HNS sent with the notification, if any
+	Data string
+}
+
+// notificationSubscriptions routes callbacks to their subscription: the
+// context passed to HNS is a key into it, since Go pointers cannot be handed
+// to Windows
+var notificationSubscriptions = struct {
+	sync.Mutex
+	next uintptr
+	subs map[uintptr]chan<- Notification
+}{subs: make(map[uintptr]chan<- Notification)}
+
+// notificationCallback is the HCN_NOTIFICATION_CALLBACK shared by every
+// subscription; callbacks created with syscall.NewCallback are never freed
+var notificationCallback = sync.OnceValue(func() uintptr {
+	return syscall.NewCallback(func(notificationType uint32, key uintptr, _ int32, data *uint16) uintptr {
+		notificationSubscriptions.Lock()
+		ch := notificationSubscriptions.subs[key]
+		notificationSubscriptions.Unlock()
+		if ch == nil {
+			return 0
+		}
+		notification := Notification{Type: NotificationType(notificationType)}
+		if data != nil {
+			notification.Data = windows.UTF16PtrToString(data)
+		}
+		// HNS blocks on the callback; a full channel already has a pass pending
+		select {
+		case ch <- notification:
+		default:
+		}
+		return 0
+	})
+})
+
+// SubscribeNotifications registers for HNS service notifications until ctx
+// is done. Notifications are dropped while the returned channel is full, so a
+// receiver only learns that something changed since it last looked.
+// It fails on builds whose HNS has no notification API.
+func SubscribeNotifications(ctx context.Context, buffer int) (<-chan Notification, error) {
+	if err := procHcnRegisterServiceCallback.Find(); err != nil {
+		return nil, fmt.Errorf("HNS notifications unavailable: %w", err)
+	}
+
+	ch := make(chan Notification, buffer)
+	notificationSubscriptions.Lock()
+	notificationSubscriptions.next++
+	key := notificationSubscriptions.next
+	notificationSubscriptions.subs[key] = ch
+	notificationSubscriptions.Unlock()
+
+	var handle uintptr
+	hr, _, _ := procHcnRegisterServiceCallback.Call(notificationCallback(), key, uintptr(unsafe.Pointer(&handle)))
+	if hr != 0 {
+		notificationSubscriptions.Lock()
+		delete(notificationSubscriptions.subs, key)
+		notificationSubscriptions.Unlock()
+		return nil, fmt.Errorf("HcnRegisterServiceCallback failed: HRESULT %#x", uint32(hr))
+	}
+
+	go func() {
+		<-ctx.Done()
+		procHcnUnregisterServiceCallback.Call(handle)
+		notificationSubscriptions.Lock()
+		delete(notificationSubscriptions.subs, key)
+		notificationSubscriptions.Unlock()
+	}()
+	return ch, nil
+}
+
+// WatchNotifications reconciles as soon as HNS reports endpoints being
+// attached or detached, instead of waiting for the next periodic pass: new
+// endpoints get their rules and the tracking of deleted ones is dropped.
+// Notifications arriving during a pass are folded into the next one. Passes
+// may overlap the periodic Run and controller applies; like theirs, the syncs
+// of a policy key wait for those already in progress.
+func (m *Manager) WatchNotifications(ctx context.Context, notifications <-chan Notification) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case notification := <-notifications:
+			pending := notification.Type.changesEndpoints()
+			for drained := false; !drained; {
+				select {
+				case next := <-notifications:
+					pending = pending || next.Type.changesEndpoints()
+				default:
+					drained = true
+				}
+			}
+			if !pending {
+				continue
+			}
+			m.logger.V(1).Info("HNS reported endpoint changes", "type", notification.Type)
+			if err := m.Reconcile(); err != nil {
+				m.logger.Error(err, "Reconciliation after HNS notification failed")
+			}
+		}
+	}
+}
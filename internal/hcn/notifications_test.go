@@ -0,0 +1,71 @@
+//go:build windows
+
+package hcn
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+func TestWatchNotifications(t *testing.T) {
+	mockClient := newMockHCNClient()
+	manager := NewManager(mockClient, logr.Discard())
+	if err := manager.ApplyACLRules("default/test", benchmarkRules(1)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	notifications := make(chan Notification, 1)
+	done := make(chan error)
+	go func() { done <- manager.WatchNotifications(ctx, notifications) }()
+
+	// The endpoint of a container started between resyncs
+	mockClient.endpoints = []hcn.HostComputeEndpoint{{Id: "ep-1"}}
+	notifications <- Notification{Type: NotificationEndpointAttached}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if ruleSets, _ := manager.GetAppliedPolicies("default/test"); len(ruleSets) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the new endpoint programmed after the notification")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("WatchNotifications failed: %v", err)
+	}
+}
+
+func TestRemoveUndesired_KeepsPoliciesAppliedDuringReconcile(t *testing.T) {
+	client := NewFakeClient(1)
+	manager := NewManager(client, logr.Discard())
+	if err := manager.ApplyACLRules("default/web", benchmarkRules(2)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	// A notification-triggered reconcile found the key desired nowhere just
+	// before the controller applied it again
+	if err := manager.removeUndesired("default/web"); err != nil {
+		t.Fatalf("removeUndesired failed: %v", err)
+	}
+	if ruleSets, _ := manager.GetAppliedPolicies("default/web"); len(ruleSets) != 1 {
+		t.Fatalf("Expected the applied policy kept, got %d rule sets", len(ruleSets))
+	}
+
+	manager.desired.Delete("default/web")
+	if err := manager.removeUndesired("default/web"); err != nil {
+		t.Fatalf("removeUndesired failed: %v", err)
+	}
+	if _, tracked := manager.GetAppliedPolicies("default/web"); tracked {
+		t.Error("Expected the undesired policy removed")
+	}
+}
@@ -0,0 +1,162 @@
+//go:build windows
+
+package hcn
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// PackRules merges rules HNS can enforce as a single ACL, so policies with
+// many peers and ports stay under the number of policies an endpoint accepts.
+// Two rules are merged when they share action, direction, protocol and labels
+// (except RuleLabel) and differ in one of:
+//
+//   - remote addresses, which are joined up to maxAddresses (0 is unlimited)
+//   - local or remote ports of a TCP or UDP rule, which are joined
+//
+// The merged rule keeps the name and priority of the first rule and lists the
+// rules it replaces in Packed. Rules are considered in priority order and are
+// never merged across a rule of the same direction with another action, so the
+// first matching action for any packet is unchanged.
+//
+// Merging is repeated until no rules merge, so the ports x peers cross-product
+// of a NetworkPolicy rule ends up as one ACL: the peers of each port are
+// joined first, then the ports of the rules left with the same peers.
+func PackRules(rules []ACLRule, maxAddresses int) []ACLRule {
+	packed := packPass(rules, maxAddresses)
+	for {
+		next := packPass(packed, maxAddresses)
+		if len(next) == len(packed) {
+			return packed
+		}
+		packed = next
+	}
+}
+
+// packPass merges each rule into the first earlier rule it fits in with
+func packPass(rules []ACLRule, maxAddresses int) []ACLRule {
+	sorted := append([]ACLRule(nil), rules...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Priority < sorted[j].Priority })
+
+	packed := make([]ACLRule, 0, len(sorted))
+	// open indexes the rules of each direction later rules may still merge into
+	open := make(map[hcn.DirectionType][]int)
+	for _, rule := range sorted {
+		candidates := open[rule.Direction]
+		if len(candidates) > 0 && packed[candidates[0]].Action != rule.Action {
+			candidates = nil
+		}
+
+		merged := false
+		for _, i := range candidates {
+			if target, ok := packRule(packed[i], rule, maxAddresses); ok {
+				packed[i] = target
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			candidates = append(candidates, len(packed))
+			packed = append(packed, rule)
+		}
+		open[rule.Direction] = candidates
+	}
+	return packed
+}
+
+// packRule merges rule into target, reporting whether the two fit in one ACL
+func packRule(target, rule ACLRule, maxAddresses int) (ACLRule, bool) {
+	if target.Action != rule.Action || target.Direction != rule.Direction ||
+		target.Protocol != rule.Protocol || target.LocalAddresses != rule.LocalAddresses ||
+		target.RuleType != rule.RuleType || !sameLabels(target.Labels, rule.Labels) {
+		return target, false
+	}
+
+	merged := target
+	if entries, _ := joinLists(target.Labels[RuleLabel], rule.Labels[RuleLabel]); entries != target.Labels[RuleLabel] {
+		// The packed ACL lists the spec entries of every rule it replaces
+		merged.Labels = make(map[string]string, len(target.Labels)+1)
+		maps.Copy(merged.Labels, target.Labels)
+		merged.Labels[RuleLabel] = entries
+	}
+	samePorts := target.LocalPorts == rule.LocalPorts && target.RemotePorts == rule.RemotePorts
+	switch {
+	case samePorts && target.RemoteAddresses == rule.RemoteAddresses:
+		// A duplicate of target only adds provenance
+	case samePorts:
+		// An empty address list matches any address and absorbs nothing
+		if target.RemoteAddresses == "" || rule.RemoteAddresses == "" {
+			return target, false
+		}
+		addresses, count := joinLists(target.RemoteAddresses, rule.RemoteAddresses)
+		if maxAddresses > 0 && count > maxAddresses {
+			return target, false
+		}
+		merged.RemoteAddresses = addresses
+	case target.RemoteAddresses == rule.RemoteAddresses && hasPorts(target.Protocol):
+		switch {
+		case target.RemotePorts == rule.RemotePorts && target.LocalPorts != "" && rule.LocalPorts != "":
+			merged.LocalPorts, _ = joinLists(target.LocalPorts, rule.LocalPorts)
+		case target.LocalPorts == rule.LocalPorts && target.RemotePorts != "" && rule.RemotePorts != "":
+			merged.RemotePorts, _ = joinLists(target.RemotePorts, rule.RemotePorts)
+		default:
+			return target, false
+		}
+	default:
+		return target, false
+	}
+
+	// Appending must not write into the Packed array of the caller's rules
+	merged.Packed = slices.Clip(target.Packed)
+	if len(merged.Packed) == 0 {
+		merged.Packed = []ACLRule{target}
+	}
+	if len(rule.Packed) > 0 {
+		merged.Packed = append(merged.Packed, rule.Packed...)
+	} else {
+		merged.Packed = append(merged.Packed, rule)
+	}
+	return merged, true
+}
+
+// sameLabels reports whether two rules carry the same labels, ignoring the
+// RuleLabel of the spec entries they come from
+func sameLabels(a, b map[string]string) bool {
+	for key, value := range a {
+		if other, ok := b[key]; key != RuleLabel && (!ok || other != value) {
+			return false
+		}
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok && key != RuleLabel {
+			return false
+		}
+	}
+	return true
+}
+
+// hasPorts reports whether rules of protocol can match on ports
+func hasPorts(protocol string) bool {
+	return protocol == "6" || protocol == "17"
+}
+
+// joinLists joins two comma-separated lists, dropping entries of b already in
+// a, and returns the number of entries of the result
+func joinLists(a, b string) (string, int) {
+	seen := make(map[string]bool)
+	var entries []string
+	for _, list := range []string{a, b} {
+		for _, entry := range strings.Split(list, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" && !seen[entry] {
+				seen[entry] = true
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return strings.Join(entries, ","), len(entries)
+}
@@ -0,0 +1,168 @@
+//go:build windows
+
+package hcn
+
+import (
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+// allowIn returns an ingress allow rule at priority
+func allowIn(priority uint16, protocol, localPorts, remoteAddresses string) ACLRule {
+	return ACLRule{
+		Name:            "default/web-ingress",
+		Action:          hcn.ActionTypeAllow,
+		Direction:       hcn.DirectionTypeIn,
+		Protocol:        protocol,
+		LocalPorts:      localPorts,
+		RemoteAddresses: remoteAddresses,
+		Priority:        priority,
+	}
+}
+
+func TestPackRules(t *testing.T) {
+	tests := []struct {
+		name         string
+		rules        []ACLRule
+		maxAddresses int
+		want         []ACLRule
+	}{
+		{
+			name:  "peers on one port",
+			rules: []ACLRule{allowIn(100, "6", "80", "10.0.0.1"), allowIn(101, "6", "80", "10.0.0.2,10.0.0.1")},
+			want:  []ACLRule{allowIn(100, "6", "80", "10.0.0.1,10.0.0.2")},
+		},
+		{
+			name:  "ports of one peer",
+			rules: []ACLRule{allowIn(100, "6", "80", "10.0.0.1"), allowIn(101, "6", "443", "10.0.0.1")},
+			want:  []ACLRule{allowIn(100, "6", "80,443", "10.0.0.1")},
+		},
+		{
+			name:  "ports and peers both differ",
+			rules: []ACLRule{allowIn(100, "6", "80", "10.0.0.1"), allowIn(101, "6", "443", "10.0.0.2")},
+			want:  []ACLRule{allowIn(100, "6", "80", "10.0.0.1"), allowIn(101, "6", "443", "10.0.0.2")},
+		},
+		{
+			name: "cross-product of ports and peers",
+			rules: []ACLRule{
+				allowIn(100, "6", "80", "10.0.0.1"), allowIn(101, "6", "80", "10.0.0.2"),
+				allowIn(102, "6", "443", "10.0.0.1"), allowIn(103, "6", "443", "10.0.0.2"),
+			},
+			want: []ACLRule{allowIn(100, "6", "80,443", "10.0.0.1,10.0.0.2")},
+		},
+		{
+			name:  "all ports do not absorb a port",
+			rules: []ACLRule{allowIn(100, "6", "", "10.0.0.1"), allowIn(101, "6", "443", "10.0.0.1")},
+			want:  []ACLRule{allowIn(100, "6", "", "10.0.0.1"), allowIn(101, "6", "443", "10.0.0.1")},
+		},
+		{
+			name:  "protocols stay apart",
+			rules: []ACLRule{allowIn(100, "6", "53", "10.96.0.10"), allowIn(101, "17", "53", "10.96.0.10")},
+			want:  []ACLRule{allowIn(100, "6", "53", "10.96.0.10"), allowIn(101, "17", "53", "10.96.0.10")},
+		},
+		{
+			name:         "address limit",
+			rules:        []ACLRule{allowIn(100, "6", "80", "10.0.0.1,10.0.0.2"), allowIn(101, "6", "80", "10.0.0.3")},
+			maxAddresses: 2,
+			want:         []ACLRule{allowIn(100, "6", "80", "10.0.0.1,10.0.0.2"), allowIn(101, "6", "80", "10.0.0.3")},
+		},
+		{
+			name: "interleaved protocols",
+			rules: []ACLRule{
+				allowIn(100, "6", "80", "10.0.0.1"), allowIn(101, "17", "53", "10.0.0.1"), allowIn(102, "6", "80", "10.0.0.2"),
+			},
+			want: []ACLRule{allowIn(100, "6", "80", "10.0.0.1,10.0.0.2"), allowIn(101, "17", "53", "10.0.0.1")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PackRules(tt.rules, tt.maxAddresses)
+			if len(got) != len(tt.want) {
+				t.Fatalf("PackRules() returned %d rules, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range tt.want {
+				got[i].Packed = nil
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("rule %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPackRules_KeepsActionOrder(t *testing.T) {
+	block := allowIn(101, "6", "80", "10.0.0.0/24")
+	block.Action = hcn.ActionTypeBlock
+
+	// Merging the second allow would let it match before the block
+	rules := []ACLRule{allowIn(100, "6", "80", "10.0.0.1"), block, allowIn(102, "6", "80", "10.0.0.5")}
+	if packed := PackRules(rules, 0); len(packed) != 3 {
+		t.Fatalf("Expected no rule merged across the block, got %+v", packed)
+	}
+
+	// Egress rules do not end the runs of ingress rules
+	egress := block
+	egress.Direction = hcn.DirectionTypeOut
+	rules[1] = egress
+	if packed := PackRules(rules, 0); len(packed) != 2 {
+		t.Fatalf("Expected the ingress rules merged around the egress rule, got %+v", packed)
+	}
+}
+
+func TestPackRules_Provenance(t *testing.T) {
+	rules := []ACLRule{
+		allowIn(100, "6", "80", "10.0.0.1"),
+		allowIn(101, "6", "80", "10.0.0.2"),
+		allowIn(102, "6", "80", "10.0.0.3"),
+	}
+	packed := PackRules(rules, 0)
+	if len(packed) != 1 || len(packed[0].Packed) != 3 {
+		t.Fatalf("Expected one ACL packing 3 rules, got %+v", packed)
+	}
+	for i, source := range packed[0].Packed {
+		if !source.Equal(rules[i]) {
+			t.Errorf("Packed[%d] = %+v, want %+v", i, source, rules[i])
+		}
+	}
+
+	// Programming the packed rules sends one ACL while tracking every source rule
+	client := NewFakeClient(1)
+	manager := NewManager(client, logr.Discard())
+	if err := manager.ApplyACLRules("default/web", packed); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	endpoints, _ := client.ListEndpoints()
+	if len(endpoints[0].Policies) != 1 {
+		t.Errorf("Expected one ACL on the endpoint, got %d", len(endpoints[0].Policies))
+	}
+	ruleSets, _ := manager.GetAppliedPolicies("default/web")
+	if len(ruleSets) != 1 || len(ruleSets[0].Rules[0].Packed) != 3 {
+		t.Errorf("Expected the packed rules to be tracked, got %+v", ruleSets)
+	}
+}
+
+func TestPackRules_SpecEntries(t *testing.T) {
+	rules := []ACLRule{
+		allowIn(100, "6", "80", "10.0.0.1"),
+		allowIn(101, "6", "80", "10.0.0.2"),
+	}
+	rules[0].Labels = map[string]string{"policy": "default/web", RuleLabel: "ingress[0]"}
+	rules[1].Labels = map[string]string{"policy": "default/web", RuleLabel: "ingress[1]"}
+
+	// Rules of different spec entries still pack, and the ACL lists both
+	packed := PackRules(rules, 0)
+	if len(packed) != 1 || packed[0].Labels[RuleLabel] != "ingress[0],ingress[1]" {
+		t.Fatalf("Expected one ACL listing both entries, got %+v", packed)
+	}
+	if rules[0].Labels[RuleLabel] != "ingress[0]" {
+		t.Error("Expected the labels of the source rules left alone")
+	}
+
+	rules[1].Labels["policy"] = "default/db"
+	if packed := PackRules(rules, 0); len(packed) != 2 {
+		t.Errorf("Expected rules with other labels kept apart, got %+v", packed)
+	}
+}
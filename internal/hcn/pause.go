@@ -0,0 +1,142 @@
+//go:build windows
+
+package hcn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// PausedEndpoint is a pod address whose endpoint the Manager programs no rules on
+type PausedEndpoint struct {
+	Address string `json:"address"`
+	Pod     string `json:"pod"`
+}
+
+// podAddresses holds pod addresses whose endpoints are desired to carry no rules
+type podAddresses struct {
+	mu sync.RWMutex
+
+	// pods maps pod IP -> namespace/name of the pod
+	pods map[string]string
+}
+
+// set adds or removes ip and reports whether that changed the set
+func (a *podAddresses) set(ip, pod string, on bool) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, was := a.pods[ip]
+	if on {
+		a.pods[ip] = pod
+	} else {
+		delete(a.pods, ip)
+	}
+	return was != on
+}
+
+// list returns the addresses and their pods, sorted by address
+func (a *podAddresses) list() []PausedEndpoint {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	list := make([]PausedEndpoint, 0, len(a.pods))
+	for address, pod := range a.pods {
+		list = append(list, PausedEndpoint{Address: address, Pod: pod})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Address < list[j].Address })
+	return list
+}
+
+// holds reports whether an address of endpoint is in the set
+func (a *podAddresses) holds(endpoint hcn.HostComputeEndpoint) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if len(a.pods) == 0 {
+		return false
+	}
+	for _, ipConfig := range endpoint.IpConfigurations {
+		if _, found := a.pods[ipConfig.IpAddress]; found {
+			return true
+		}
+	}
+	return false
+}
+
+// SetEnforcementPaused pauses or resumes enforcement on the endpoint owning
+// ip. A paused endpoint is desired to carry no rules, so the policies
+// programmed on it are removed and none are added until it is resumed, when
+// they are programmed again. An address no endpoint owns yet takes effect once
+// its endpoint appears. pod names the pod for logs and PausedEndpoints.
+func (m *Manager) SetEnforcementPaused(ctx context.Context, ip, pod string, paused bool) error {
+	if !m.paused.set(ip, pod, paused) {
+		return nil
+	}
+	message := "Resumed enforcement on endpoint"
+	if paused {
+		message = "Paused enforcement on endpoint"
+	}
+	return m.resyncAddress(ctx, ip, pod, message)
+}
+
+// resyncAddress converges the policies programmed on, or desired on, the
+// endpoint owning ip and logs message once it did
+func (m *Manager) resyncAddress(ctx context.Context, ip, pod, message string) error {
+	endpoints, err := m.listEndpoints()
+	if err != nil {
+		return fmt.Errorf("failed to list HCN endpoints: %w", err)
+	}
+	endpoint, found := m.index.ByIP(ip)
+	if !found {
+		return nil
+	}
+
+	// Converge the policies programmed on the endpoint or desired there
+	keys := make(map[string]bool)
+	for key := range m.providerRulesFor(endpoint) {
+		keys[key] = true
+	}
+	for _, key := range m.trackedKeys() {
+		ruleSets, _ := m.trackedRuleSets(key)
+		for _, ruleSet := range ruleSets {
+			if ruleSet.EndpointID == endpoint.Id {
+				keys[key] = true
+			}
+		}
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var syncErrors []error
+	for _, key := range sorted {
+		if err := m.syncPolicy(ctx, key, endpoints); err != nil {
+			syncErrors = append(syncErrors, fmt.Errorf("policy %s: %w", key, err))
+		}
+	}
+	if len(syncErrors) > 0 {
+		return errors.Join(syncErrors...)
+	}
+
+	m.logger.Info(message,
+		"endpointID", endpoint.Id,
+		"pod", pod,
+		"podIP", ip,
+		"policyCount", len(sorted))
+	return nil
+}
+
+// PausedEndpoints returns the pod addresses enforcement is paused for, sorted
+func (m *Manager) PausedEndpoints() []PausedEndpoint {
+	return m.paused.list()
+}
+
+// enforcementPaused reports whether enforcement is paused for an address of endpoint
+func (m *Manager) enforcementPaused(endpoint hcn.HostComputeEndpoint) bool {
+	return m.paused.holds(endpoint)
+}
@@ -0,0 +1,61 @@
+//go:build windows
+
+package hcn
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestSetEnforcementPaused(t *testing.T) {
+	client := NewFakeClient(2)
+	manager := NewManager(client, logr.Discard())
+	if err := manager.ApplyACLRules("default/web", benchmarkRules(2)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	policiesOn := func(endpointID string) int {
+		t.Helper()
+		endpoint, err := client.GetEndpointByID(endpointID)
+		if err != nil {
+			t.Fatalf("GetEndpointByID failed: %v", err)
+		}
+		return len(endpoint.Policies)
+	}
+
+	ctx := context.Background()
+	if err := manager.SetEnforcementPaused(ctx, "10.244.0.2", "default/web-0", true); err != nil {
+		t.Fatalf("SetEnforcementPaused failed: %v", err)
+	}
+	if got := policiesOn("fake-endpoint-0"); got != 0 {
+		t.Errorf("Expected no rules on the paused endpoint, got %d", got)
+	}
+	if got := policiesOn("fake-endpoint-1"); got != 2 {
+		t.Errorf("Expected the other endpoint untouched, got %d rules", got)
+	}
+	if stats := manager.Stats(); stats.PausedEndpoints != 1 {
+		t.Errorf("Expected 1 paused endpoint, got %d", stats.PausedEndpoints)
+	}
+
+	// Later applies and full reconciles leave the paused endpoint alone
+	if err := manager.ApplyACLRules("default/web", benchmarkRules(3)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	if err := manager.Reconcile(); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if got := policiesOn("fake-endpoint-0"); got != 0 {
+		t.Errorf("Expected the paused endpoint to stay empty, got %d rules", got)
+	}
+
+	if err := manager.SetEnforcementPaused(ctx, "10.244.0.2", "default/web-0", false); err != nil {
+		t.Fatalf("SetEnforcementPaused failed: %v", err)
+	}
+	if got := policiesOn("fake-endpoint-0"); got != 3 {
+		t.Errorf("Expected the rules back once resumed, got %d", got)
+	}
+	if paused := manager.PausedEndpoints(); len(paused) != 0 {
+		t.Errorf("Expected no paused endpoints, got %v", paused)
+	}
+}
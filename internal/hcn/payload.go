@@ -0,0 +1,155 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// maxPayloadCacheEntries bounds the payload cache; it is reset when full
+const maxPayloadCacheEntries = 16384
+
+// payloadKey holds the ACLRule fields that end up in the HCN settings payload
+type payloadKey struct {
+	action          hcn.ActionType
+	direction       hcn.DirectionType
+	protocol        string
+	localPorts      string
+	remotePorts     string
+	remoteAddresses string
+	localAddresses  string
+	ruleType        hcn.RuleType
+	priority        uint16
+}
+
+// payloadCache memoizes validated, marshalled AclPolicySetting payloads so that
+// repeated applies across resyncs do no marshalling work
+type payloadCache struct {
+	mu       sync.RWMutex
+	payloads map[payloadKey]json.RawMessage
+
+	// anyAddress and singleStack select how wildcard remote addresses are sent
+	// (see normalizeAnyAddress), allPorts how all-ports rules are sent (see
+	// normalizeAllPorts); changing them clears the cached payloads
+	anyAddress  AnyAddressForm
+	singleStack bool
+	allPorts    AllPortsForm
+
+	// capabilities gate the optional fields of newer HNS builds
+	capabilities ACLCapabilities
+}
+
+func newPayloadCache() *payloadCache {
+	return &payloadCache{
+		payloads: make(map[payloadKey]json.RawMessage),
+	}
+}
+
+// get returns the settings payload for rule, validating and marshalling it on first use.
+// Returned payloads are shared and must not be modified.
+func (c *payloadCache) get(rule ACLRule) (json.RawMessage, error) {
+	key := payloadKey{
+		action:          rule.Action,
+		direction:       rule.Direction,
+		protocol:        rule.Protocol,
+		localPorts:      rule.LocalPorts,
+		remotePorts:     rule.RemotePorts,
+		remoteAddresses: rule.RemoteAddresses,
+		localAddresses:  rule.LocalAddresses,
+		ruleType:        rule.RuleType,
+		priority:        rule.Priority,
+	}
+
+	c.mu.RLock()
+	payload, cached := c.payloads[key]
+	c.mu.RUnlock()
+	if cached {
+		return payload, nil
+	}
+
+	if err := validateRule(rule); err != nil {
+		return nil, err
+	}
+	if err := validateCapabilities(rule, c.capabilities); err != nil {
+		return nil, err
+	}
+
+	// Create ACL policy setting
+	aclSetting := hcn.AclPolicySetting{
+		Protocols:       rule.Protocol,
+		Action:          rule.Action,
+		Direction:       rule.Direction,
+		LocalAddresses:  rule.LocalAddresses,
+		RemoteAddresses: normalizeAnyAddress(rule.RemoteAddresses, c.anyAddress, c.singleStack),
+		LocalPorts:      normalizeAllPorts(rule.LocalPorts, rule.Protocol, c.allPorts),
+		RemotePorts:     normalizeAllPorts(rule.RemotePorts, rule.Protocol, c.allPorts),
+		RuleType:        ruleTypeFor(rule, c.capabilities),
+		Priority:        rule.Priority,
+	}
+
+	payload, err := json.Marshal(aclSetting)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if len(c.payloads) >= maxPayloadCacheEntries {
+		c.payloads = make(map[payloadKey]json.RawMessage)
+	}
+	c.payloads[key] = payload
+	c.mu.Unlock()
+
+	return payload, nil
+}
+
+// len returns the number of cached payloads
+func (c *payloadCache) len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.payloads)
+}
+
+// validateRule rejects rules HCN would refuse, before any HCN call is made
+func validateRule(rule ACLRule) error {
+	switch rule.Action {
+	case hcn.ActionTypeAllow, hcn.ActionTypeBlock, hcn.ActionTypePass:
+	default:
+		return fmt.Errorf("invalid action %q", rule.Action)
+	}
+
+	switch rule.Direction {
+	case hcn.DirectionTypeIn, hcn.DirectionTypeOut:
+	default:
+		return fmt.Errorf("invalid direction %q", rule.Direction)
+	}
+
+	if rule.Priority < MinPriority {
+		return fmt.Errorf("rule %q has priority %d, which HNS rejects", rule.Name, rule.Priority)
+	}
+
+	return nil
+}
+
+// ruleFromPolicy rebuilds the ACL rule described by an HCN ACL policy; fields
+// that are not part of the payload, such as names and labels, are left empty
+func ruleFromPolicy(policy hcn.EndpointPolicy) ACLRule {
+	var setting hcn.AclPolicySetting
+	if err := json.Unmarshal(policy.Settings, &setting); err != nil {
+		return ACLRule{}
+	}
+	return ACLRule{
+		Action:          setting.Action,
+		Direction:       setting.Direction,
+		Protocol:        setting.Protocols,
+		LocalPorts:      setting.LocalPorts,
+		RemotePorts:     setting.RemotePorts,
+		RemoteAddresses: setting.RemoteAddresses,
+		LocalAddresses:  setting.LocalAddresses,
+		RuleType:        setting.RuleType,
+		Priority:        setting.Priority,
+	}
+}
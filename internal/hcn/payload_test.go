@@ -0,0 +1,66 @@
+//go:build windows
+
+package hcn
+
+import (
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+func TestPayloadCache_ReusesPayloads(t *testing.T) {
+	cache := newPayloadCache()
+
+	rule := ACLRule{
+		Name:       "allow-http",
+		Action:     hcn.ActionTypeAllow,
+		Direction:  hcn.DirectionTypeIn,
+		Protocol:   "6",
+		LocalPorts: "80",
+		Priority:   100,
+	}
+
+	first, err := cache.get(rule)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+
+	// The name is not part of the payload, so renamed rules share the entry
+	rule.Name = "allow-http-renamed"
+	second, err := cache.get(rule)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+
+	if &first[0] != &second[0] {
+		t.Error("Expected cached payload to be reused")
+	}
+	if cache.len() != 1 {
+		t.Errorf("Expected 1 cached payload, got %d", cache.len())
+	}
+}
+
+func TestPayloadCache_RejectsInvalidRules(t *testing.T) {
+	cache := newPayloadCache()
+
+	tests := []struct {
+		name string
+		rule ACLRule
+	}{
+		{"missing action", ACLRule{Direction: hcn.DirectionTypeIn}},
+		{"missing direction", ACLRule{Action: hcn.ActionTypeAllow}},
+		{"zero priority", ACLRule{Action: hcn.ActionTypeAllow, Direction: hcn.DirectionTypeIn}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := cache.get(tt.rule); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+
+	if cache.len() != 0 {
+		t.Errorf("Invalid rules must not be cached, got %d entries", cache.len())
+	}
+}
@@ -0,0 +1,103 @@
+//go:build windows
+
+package hcn
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SourceKind is the first segment of a PolicyKey, naming the kind of source
+// the rules of the key come from
+type SourceKind string
+
+const (
+	// SourceNetworkPolicy keys the rules of a NetworkPolicy
+	SourceNetworkPolicy SourceKind = "netpol"
+
+	// SourceAdminNetworkPolicy keys the rules of a group of AdminNetworkPolicies
+	SourceAdminNetworkPolicy SourceKind = "anp"
+
+	// SourceNamespaceDefault keys the rules of a NamespaceDefaultPolicy
+	SourceNamespaceDefault SourceKind = "namespacedefault"
+
+	// SourceStatic keys a rule set of the static rules file
+	SourceStatic SourceKind = "static"
+
+	// SourceQuarantine keys the deny-all rules of a quarantined endpoint
+	SourceQuarantine SourceKind = "quarantine"
+
+	// SourceStateless keys the ACLs taken over by a stateless warm start
+	SourceStateless SourceKind = "stateless"
+
+	// SourceMigrated keys the ACLs adopted from another agent (see Migrate)
+	SourceMigrated SourceKind = "migrated"
+)
+
+// knownSourceKinds are the kinds ParsePolicyKey accepts
+var knownSourceKinds = map[SourceKind]bool{
+	SourceNetworkPolicy:      true,
+	SourceAdminNetworkPolicy: true,
+	SourceNamespaceDefault:   true,
+	SourceStatic:             true,
+	SourceQuarantine:         true,
+	SourceStateless:          true,
+	SourceMigrated:           true,
+}
+
+// PolicyKey identifies the rules the Manager tracks for one policy of one
+// source, as "<kind>/<name>". The kind keeps sources whose policies have the
+// same name, such as a NetworkPolicy "static/web" and the static rule set
+// "web", from overwriting each other's rules.
+type PolicyKey string
+
+// NewPolicyKey returns the key of the policy name of a source of kind
+func NewPolicyKey(kind SourceKind, name string) PolicyKey {
+	return PolicyKey(string(kind) + "/" + name)
+}
+
+// ParsePolicyKey parses a key given by an operator, such as
+// "netpol/default/web"; the kind must be a known one
+func ParsePolicyKey(value string) (PolicyKey, error) {
+	kind, name, _ := strings.Cut(value, "/")
+	if !knownSourceKinds[SourceKind(kind)] || name == "" {
+		return "", fmt.Errorf("invalid policy key %q: expected <kind>/<name> with kind one of netpol, anp, namespacedefault, static, quarantine, stateless or migrated", value)
+	}
+	return PolicyKey(value), nil
+}
+
+// Kind returns the source kind of the key
+func (k PolicyKey) Kind() SourceKind {
+	kind, _, _ := strings.Cut(string(k), "/")
+	return SourceKind(kind)
+}
+
+// Name returns the key without its source kind
+func (k PolicyKey) Name() string {
+	_, name, _ := strings.Cut(string(k), "/")
+	return name
+}
+
+// String implements fmt.Stringer
+func (k PolicyKey) String() string {
+	return string(k)
+}
+
+// migratePolicyKey returns the key a state file written before keys carried
+// their source kind meant: NetworkPolicy keys were the bare "namespace/name",
+// or "source/namespace/name" for an additional cluster
+func migratePolicyKey(key string) string {
+	if knownSourceKinds[PolicyKey(key).Kind()] {
+		return key
+	}
+	return string(NewPolicyKey(SourceNetworkPolicy, key))
+}
+
+// typedKeys types the keys of the Manager's string-keyed maps
+func typedKeys(keys []string) []PolicyKey {
+	typed := make([]PolicyKey, len(keys))
+	for i, key := range keys {
+		typed[i] = PolicyKey(key)
+	}
+	return typed
+}
@@ -0,0 +1,38 @@
+//go:build windows
+
+package hcn
+
+import "testing"
+
+func TestPolicyKey(t *testing.T) {
+	key := NewPolicyKey(SourceNetworkPolicy, "default/web")
+	if key != "netpol/default/web" || key.Kind() != SourceNetworkPolicy || key.Name() != "default/web" {
+		t.Errorf("Unexpected key %q: kind %q, name %q", key, key.Kind(), key.Name())
+	}
+
+	for _, value := range []string{"static/web", "anp/baseline", "netpol/edge/default/web"} {
+		if _, err := ParsePolicyKey(value); err != nil {
+			t.Errorf("ParsePolicyKey(%q) failed: %v", value, err)
+		}
+	}
+	for _, value := range []string{"default/web", "netpol/", "netpol", ""} {
+		if _, err := ParsePolicyKey(value); err == nil {
+			t.Errorf("Expected ParsePolicyKey(%q) to fail", value)
+		}
+	}
+}
+
+func TestMigratePolicyKey(t *testing.T) {
+	tests := map[string]string{
+		"default/web":        "netpol/default/web",
+		"edge/default/web":   "netpol/edge/default/web",
+		"netpol/default/web": "netpol/default/web",
+		"static/baseline":    "static/baseline",
+		"quarantine/ep-1":    "quarantine/ep-1",
+	}
+	for key, expected := range tests {
+		if got := migratePolicyKey(key); got != expected {
+			t.Errorf("migratePolicyKey(%q) = %q, expected %q", key, got, expected)
+		}
+	}
+}
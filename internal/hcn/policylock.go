@@ -0,0 +1,47 @@
+//go:build windows
+
+package hcn
+
+import "sync"
+
+// policyLocks serializes the syncs and removals of each policy key. Applies,
+// the periodic reconcile and readiness checks may converge the same policy at
+// once; each reads the tracked rule sets, changes HNS and stores what it
+// programmed, so without the lock the last writer drops the endpoints the
+// other one programmed and concurrent adds stack duplicate ACLs. Different
+// keys still sync in parallel. The zero value is ready to use.
+type policyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*policyLock
+}
+
+// policyLock is the lock of one key, dropped once no caller holds or waits for it
+type policyLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock blocks until the caller holds policyKey and returns the function releasing it
+func (l *policyLocks) lock(policyKey string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*policyLock)
+	}
+	key, ok := l.locks[policyKey]
+	if !ok {
+		key = &policyLock{}
+		l.locks[policyKey] = key
+	}
+	key.refs++
+	l.mu.Unlock()
+
+	key.Lock()
+	return func() {
+		key.Unlock()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if key.refs--; key.refs == 0 {
+			delete(l.locks, policyKey)
+		}
+	}
+}
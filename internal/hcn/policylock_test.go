@@ -0,0 +1,82 @@
+//go:build windows
+
+package hcn
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestManager_ConcurrentSyncsOfOnePolicy(t *testing.T) {
+	client := NewFakeClient(4)
+	manager := NewManager(client, logr.Discard())
+	manager.desired.Set("default/web", benchmarkRules(3))
+	ctx := context.Background()
+
+	// Applies, reconciles and readiness checks race on the same key
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			_ = manager.ApplyACLRules("default/web", benchmarkRules(3))
+		}()
+		go func() {
+			defer wg.Done()
+			_ = manager.Reconcile()
+		}()
+		go func() {
+			defer wg.Done()
+			_, _ = manager.ConvergeEndpoint(ctx, "10.244.0.2", []PolicyKey{"default/web"})
+		}()
+	}
+	wg.Wait()
+
+	endpoints, err := client.ListEndpoints()
+	if err != nil {
+		t.Fatalf("ListEndpoints failed: %v", err)
+	}
+	for _, endpoint := range endpoints {
+		if len(endpoint.Policies) != 3 {
+			t.Errorf("Expected 3 ACLs on %s, got %d", endpoint.Id, len(endpoint.Policies))
+		}
+	}
+	if ruleSets, _ := manager.GetAppliedPolicies("default/web"); len(ruleSets) != 4 {
+		t.Errorf("Expected every endpoint tracked, got %d", len(ruleSets))
+	}
+}
+
+func TestSyncPolicy_KeepsEndpointsMissingFromListing(t *testing.T) {
+	client := NewFakeClient(2)
+	manager := NewManager(client, logr.Discard())
+	if err := manager.ApplyACLRules("default/web", benchmarkRules(2)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	// A sync working from a listing taken before fake-endpoint-1 appeared
+	endpoints, err := client.ListEndpoints()
+	if err != nil {
+		t.Fatalf("ListEndpoints failed: %v", err)
+	}
+	if err := manager.syncPolicy(context.Background(), "default/web", endpoints[:1]); err != nil {
+		t.Fatalf("syncPolicy failed: %v", err)
+	}
+	if ruleSets, _ := manager.GetAppliedPolicies("default/web"); len(ruleSets) != 2 {
+		t.Fatalf("Expected fake-endpoint-1 to stay tracked, got %d rule sets", len(ruleSets))
+	}
+
+	// Its ACLs are therefore still removed with the policy
+	if err := manager.RemoveACLRules("default/web"); err != nil {
+		t.Fatalf("RemoveACLRules failed: %v", err)
+	}
+	endpoint, err := client.GetEndpointByID("fake-endpoint-1")
+	if err != nil {
+		t.Fatalf("GetEndpointByID failed: %v", err)
+	}
+	if len(endpoint.Policies) != 0 {
+		t.Errorf("Expected no orphaned ACLs, got %d", len(endpoint.Policies))
+	}
+}
@@ -0,0 +1,47 @@
+//go:build windows
+
+package hcn
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// ErrEndpointGone is returned when a listed endpoint was deleted before its
+// policies could be programmed
+var ErrEndpointGone = errors.New("endpoint no longer exists")
+
+// checkEndpoint re-reads a listed endpoint right before it is modified, guarding
+// against the endpoint being deleted or recreated since the listing.
+// It returns the fresh endpoint and whether its identity changed; a changed
+// endpoint must be reprogrammed from scratch since the listed handle is stale.
+func (m *Manager) checkEndpoint(listed *hcn.HostComputeEndpoint) (*hcn.HostComputeEndpoint, bool, error) {
+	fresh, err := m.client.GetEndpointByID(listed.Id)
+	if err != nil {
+		if hcn.IsNotFoundError(err) {
+			return nil, false, fmt.Errorf("%w: %s", ErrEndpointGone, listed.Id)
+		}
+		return nil, false, fmt.Errorf("failed to refresh endpoint %s: %w", listed.Id, err)
+	}
+	return fresh, !sameEndpoint(*listed, *fresh), nil
+}
+
+// sameEndpoint reports whether two endpoint snapshots describe the same
+// endpoint instance. Policies are ignored since they change as rules are programmed.
+func sameEndpoint(a, b hcn.HostComputeEndpoint) bool {
+	if a.Id != b.Id ||
+		a.HostComputeNetwork != b.HostComputeNetwork ||
+		a.HostComputeNamespace != b.HostComputeNamespace ||
+		a.MacAddress != b.MacAddress ||
+		len(a.IpConfigurations) != len(b.IpConfigurations) {
+		return false
+	}
+	for i := range a.IpConfigurations {
+		if a.IpConfigurations[i].IpAddress != b.IpConfigurations[i].IpAddress {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+//go:build windows
+
+package hcn
+
+import (
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+func TestApplyACLRules_EndpointDeletedAfterListing(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{{Id: "ep-1"}, {Id: "ep-2"}}
+	mockClient.refreshed = map[string]*hcn.HostComputeEndpoint{"ep-2": nil}
+
+	manager := NewManager(mockClient, logr.Discard())
+	if err := manager.ApplyACLRules("default/test", benchmarkRules(1)); err != nil {
+		t.Fatalf("Expected deleted endpoint to be skipped, got %v", err)
+	}
+
+	if _, applied := mockClient.appliedPolicies["ep-2"]; applied {
+		t.Error("Expected no policies programmed on the deleted endpoint")
+	}
+	ruleSets, _ := manager.GetAppliedPolicies("default/test")
+	if len(ruleSets) != 1 || ruleSets[0].EndpointID != "ep-1" {
+		t.Errorf("Expected only ep-1 tracked, got %+v", ruleSets)
+	}
+}
+
+func TestApplyACLRules_EndpointRecreatedAfterListing(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{{Id: "ep-1", MacAddress: "00-15-5D-00-00-01"}}
+
+	manager := NewManager(mockClient, logr.Discard())
+	first := benchmarkRules(1)
+	if err := manager.ApplyACLRules("default/test", first); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	// The endpoint is recreated under the same ID between listing and applying
+	mockClient.refreshed = map[string]*hcn.HostComputeEndpoint{
+		"ep-1": {Id: "ep-1", MacAddress: "00-15-5D-00-00-02"},
+	}
+	if err := manager.ApplyACLRules("default/test", benchmarkRules(2)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	// The stale rule must not be removed from the new endpoint, and all
+	// desired rules must be programmed on it
+	if removed := mockClient.removedPolicies["ep-1"]; len(removed) != 0 {
+		t.Errorf("Expected no removals on the recreated endpoint, got %d", len(removed))
+	}
+	if applied := mockClient.appliedPolicies["ep-1"]; len(applied) != 3 {
+		t.Errorf("Expected 1 + 2 policy adds, got %d", len(applied))
+	}
+}
+
+func TestApplyACLRules_NoRefreshWhenUnchanged(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{{Id: "ep-1"}}
+
+	manager := NewManager(mockClient, logr.Discard())
+	rules := benchmarkRules(1)
+	if err := manager.ApplyACLRules("default/test", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	// A converged endpoint needs no HCN calls, so a failing lookup is never hit
+	mockClient.getEndpointErr = hcn.EndpointNotFoundError{EndpointID: "ep-1"}
+	if err := manager.ApplyACLRules("default/test", rules); err != nil {
+		t.Fatalf("Expected no precondition check for a converged endpoint, got %v", err)
+	}
+}
+
+func TestSameEndpoint(t *testing.T) {
+	base := hcn.HostComputeEndpoint{
+		Id:                 "ep-1",
+		HostComputeNetwork: "net-1",
+		MacAddress:         "00-15-5D-00-00-01",
+		IpConfigurations:   []hcn.IpConfig{{IpAddress: "10.0.0.5"}},
+	}
+
+	withPolicies := base
+	withPolicies.Policies = []hcn.EndpointPolicy{{Type: hcn.ACL}}
+	if !sameEndpoint(base, withPolicies) {
+		t.Error("Expected policy changes to keep the endpoint identity")
+	}
+
+	newIP := base
+	newIP.IpConfigurations = []hcn.IpConfig{{IpAddress: "10.0.0.6"}}
+	if sameEndpoint(base, newIP) {
+		t.Error("Expected an IP change to mark the endpoint as recreated")
+	}
+
+	newNetwork := base
+	newNetwork.HostComputeNetwork = "net-2"
+	if sameEndpoint(base, newNetwork) {
+		t.Error("Expected a network change to mark the endpoint as recreated")
+	}
+}
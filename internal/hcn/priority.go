@@ -0,0 +1,240 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// PriorityRange is an inclusive range of ACL priorities
+type PriorityRange struct {
+	Start uint16
+	End   uint16
+}
+
+// Contains reports whether priority lies within the range
+func (r PriorityRange) Contains(priority uint16) bool {
+	return priority >= r.Start && priority <= r.End
+}
+
+// Validate checks that the range is well-formed
+func (r PriorityRange) Validate() error {
+	if r.Start > r.End {
+		return fmt.Errorf("range %s starts after it ends", r)
+	}
+	return nil
+}
+
+// String formats the range as "start-end"
+func (r PriorityRange) String() string {
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// ParsePriorityRanges parses a comma-separated list such as "1-99,4000-4100,65000"
+func ParsePriorityRanges(value string) ([]PriorityRange, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var ranges []PriorityRange
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		startStr, endStr, isRange := strings.Cut(part, "-")
+		if !isRange {
+			endStr = startStr
+		}
+
+		start, err := strconv.ParseUint(strings.TrimSpace(startStr), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid priority range %q: %w", part, err)
+		}
+		end, err := strconv.ParseUint(strings.TrimSpace(endStr), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid priority range %q: %w", part, err)
+		}
+
+		r := PriorityRange{Start: uint16(start), End: uint16(end)}
+		if err := r.Validate(); err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	return ranges, nil
+}
+
+// PriorityConflict is an ACL not programmed by this controller whose priority
+// lies in the band the controller emits rules in
+type PriorityConflict struct {
+	EndpointID string
+	Priority   uint16
+	Direction  hcn.DirectionType
+}
+
+// ValidatePriorityBand inspects the ACLs present on all endpoints and reports
+// foreign rules with priorities at or above base that are not covered by a
+// reserved range, since controller rules would interleave with them
+func (m *Manager) ValidatePriorityBand(base uint16, reserved []PriorityRange) ([]PriorityConflict, error) {
+	endpoints, err := m.listEndpoints()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list HCN endpoints: %w", err)
+	}
+
+	// Payloads we programmed ourselves are not conflicts
+	owned := make(map[string]bool)
+	for _, key := range m.trackedKeys() {
+		ruleSets, _ := m.trackedRuleSets(key)
+		for _, ruleSet := range ruleSets {
+			for _, policy := range ruleSet.Policies {
+				owned[string(policy.Settings)] = true
+			}
+		}
+	}
+
+	var conflicts []PriorityConflict
+	for _, endpoint := range endpoints {
+		for _, policy := range endpoint.Policies {
+			if policy.Type != hcn.ACL || owned[string(policy.Settings)] {
+				continue
+			}
+
+			var setting hcn.AclPolicySetting
+			if err := json.Unmarshal(policy.Settings, &setting); err != nil {
+				m.logger.V(1).Info("Skipping unparsable ACL on endpoint", "endpointID", endpoint.Id, "error", err.Error())
+				continue
+			}
+
+			if setting.Priority < base || inRanges(setting.Priority, reserved) {
+				continue
+			}
+			conflicts = append(conflicts, PriorityConflict{
+				EndpointID: endpoint.Id,
+				Priority:   setting.Priority,
+				Direction:  setting.Direction,
+			})
+		}
+	}
+
+	return conflicts, nil
+}
+
+// inRanges reports whether priority lies in any of the ranges
+func inRanges(priority uint16, ranges []PriorityRange) bool {
+	for _, r := range ranges {
+		if r.Contains(priority) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// MinPriority is the lowest priority value HNS accepts for an ACL; 0 means "unset"
+	MinPriority uint16 = 1
+
+	// MaxPriority is the highest priority the controller emits. Priorities above it
+	// are used by HNS for its own default rules.
+	MaxPriority uint16 = 65499
+)
+
+// ErrPriorityExhausted is returned when a priority pool has no values left
+var ErrPriorityExhausted = errors.New("ACL priority range exhausted")
+
+// PriorityPool hands out increasing priorities within [base, max], advancing by
+// stride and skipping reserved ranges. Exhaustion is sticky: once Next runs out
+// of values it keeps returning 0 and Err reports ErrPriorityExhausted, so callers
+// building many rules can check for overflow once at the end.
+type PriorityPool struct {
+	next     uint32
+	max      uint16
+	stride   uint16
+	reserved []PriorityRange
+	err      error
+}
+
+// NewPriorityPool creates a pool; max is clamped to MaxPriority
+func NewPriorityPool(base, max, stride uint16, reserved []PriorityRange) (*PriorityPool, error) {
+	if max == 0 || max > MaxPriority {
+		max = MaxPriority
+	}
+	if base < MinPriority || base > max {
+		return nil, fmt.Errorf("base priority %d outside the valid range %d-%d", base, MinPriority, max)
+	}
+	if stride == 0 {
+		stride = 1
+	}
+	return &PriorityPool{
+		next:     uint32(base),
+		max:      max,
+		stride:   stride,
+		reserved: reserved,
+	}, nil
+}
+
+// Next returns the next free priority, or 0 once the pool is exhausted
+func (p *PriorityPool) Next() uint16 {
+	if p.err != nil {
+		return 0
+	}
+
+	for moved := true; moved; {
+		moved = false
+		for _, reserved := range p.reserved {
+			if p.next <= uint32(MaxPriority) && reserved.Contains(uint16(p.next)) {
+				p.next = uint32(reserved.End) + 1
+				moved = true
+			}
+		}
+	}
+
+	if p.next > uint32(p.max) {
+		p.err = fmt.Errorf("%w: next priority %d exceeds maximum %d", ErrPriorityExhausted, p.next, p.max)
+		return 0
+	}
+
+	assigned := uint16(p.next)
+	p.next += uint32(p.stride)
+	return assigned
+}
+
+// Err reports whether the pool ran out of priorities
+func (p *PriorityPool) Err() error {
+	return p.err
+}
+
+// SplitPriorityBand divides [base, max] into n consecutive, non-overlapping
+// bands of equal size (the last band takes the remainder). Rule sources sharing
+// an endpoint each allocate from their own band, so their rules never collide
+// on a priority and their relative order stays fixed.
+func SplitPriorityBand(base, max uint16, n int) ([]PriorityRange, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("cannot split priority band into %d parts", n)
+	}
+	if base < MinPriority || base > max {
+		return nil, fmt.Errorf("invalid priority band %d-%d", base, max)
+	}
+
+	size := (int(max) - int(base) + 1) / n
+	if size == 0 {
+		return nil, fmt.Errorf("priority band %d-%d too small for %d sources", base, max, n)
+	}
+
+	bands := make([]PriorityRange, 0, n)
+	for i := 0; i < n; i++ {
+		start := int(base) + i*size
+		end := start + size - 1
+		if i == n-1 {
+			end = int(max)
+		}
+		bands = append(bands, PriorityRange{Start: uint16(start), End: uint16(end)})
+	}
+	return bands, nil
+}
@@ -0,0 +1,135 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+func TestParsePriorityRanges(t *testing.T) {
+	ranges, err := ParsePriorityRanges("4000-4100, 1-99,65000")
+	if err != nil {
+		t.Fatalf("ParsePriorityRanges failed: %v", err)
+	}
+
+	expected := []PriorityRange{{1, 99}, {4000, 4100}, {65000, 65000}}
+	if len(ranges) != len(expected) {
+		t.Fatalf("Expected %d ranges, got %d", len(expected), len(ranges))
+	}
+	for i := range expected {
+		if ranges[i] != expected[i] {
+			t.Errorf("ranges[%d] = %s, want %s", i, ranges[i], expected[i])
+		}
+	}
+
+	for _, invalid := range []string{"10-5", "abc", "1-70000"} {
+		if _, err := ParsePriorityRanges(invalid); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestValidatePriorityBand(t *testing.T) {
+	foreign := func(priority uint16) hcn.EndpointPolicy {
+		settings, _ := json.Marshal(hcn.AclPolicySetting{
+			Action:    hcn.ActionTypeAllow,
+			Direction: hcn.DirectionTypeIn,
+			Priority:  priority,
+		})
+		return hcn.EndpointPolicy{Type: hcn.ACL, Settings: settings}
+	}
+
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{
+		{
+			Id: "ep-1",
+			Policies: []hcn.EndpointPolicy{
+				foreign(50),   // below the controller band
+				foreign(150),  // conflicts
+				foreign(4050), // reserved
+			},
+		},
+	}
+
+	manager := NewManager(mockClient, logr.Discard())
+
+	conflicts, err := manager.ValidatePriorityBand(100, []PriorityRange{{4000, 4100}})
+	if err != nil {
+		t.Fatalf("ValidatePriorityBand failed: %v", err)
+	}
+	if len(conflicts) != 1 || conflicts[0].Priority != 150 {
+		t.Errorf("Expected a single conflict at priority 150, got %+v", conflicts)
+	}
+}
+
+func TestPriorityPool(t *testing.T) {
+	pool, err := NewPriorityPool(100, 130, 10, []PriorityRange{{Start: 110, End: 119}})
+	if err != nil {
+		t.Fatalf("NewPriorityPool failed: %v", err)
+	}
+
+	expected := []uint16{100, 120, 130}
+	for i, want := range expected {
+		if got := pool.Next(); got != want {
+			t.Errorf("Next() #%d = %d, want %d", i, got, want)
+		}
+	}
+	if pool.Err() != nil {
+		t.Fatalf("Unexpected error before exhaustion: %v", pool.Err())
+	}
+
+	if got := pool.Next(); got != 0 {
+		t.Errorf("Expected 0 from exhausted pool, got %d", got)
+	}
+	if !errors.Is(pool.Err(), ErrPriorityExhausted) {
+		t.Errorf("Expected ErrPriorityExhausted, got %v", pool.Err())
+	}
+}
+
+func TestPriorityPool_NeverWrapsAround(t *testing.T) {
+	pool, err := NewPriorityPool(MaxPriority-1, 0, 1, nil)
+	if err != nil {
+		t.Fatalf("NewPriorityPool failed: %v", err)
+	}
+
+	pool.Next()
+	pool.Next()
+	if got := pool.Next(); got != 0 || pool.Err() == nil {
+		t.Errorf("Expected exhaustion past MaxPriority, got %d (err=%v)", got, pool.Err())
+	}
+}
+
+func TestNewPriorityPool_InvalidBase(t *testing.T) {
+	if _, err := NewPriorityPool(0, 0, 1, nil); err == nil {
+		t.Error("Expected error for base priority 0")
+	}
+}
+
+func TestSplitPriorityBand(t *testing.T) {
+	bands, err := SplitPriorityBand(100, 1099, 3)
+	if err != nil {
+		t.Fatalf("SplitPriorityBand failed: %v", err)
+	}
+
+	expected := []PriorityRange{{Start: 100, End: 432}, {Start: 433, End: 765}, {Start: 766, End: 1099}}
+	if len(bands) != len(expected) {
+		t.Fatalf("Expected %d bands, got %v", len(expected), bands)
+	}
+	for i := range expected {
+		if bands[i] != expected[i] {
+			t.Errorf("band %d = %s, want %s", i, bands[i], expected[i])
+		}
+	}
+
+	if bands, _ := SplitPriorityBand(100, MaxPriority, 1); len(bands) != 1 || bands[0].End != MaxPriority {
+		t.Errorf("Expected a single band to be unchanged, got %v", bands)
+	}
+	if _, err := SplitPriorityBand(100, 101, 3); err == nil {
+		t.Error("Expected error for a band smaller than the number of sources")
+	}
+}
@@ -0,0 +1,44 @@
+//go:build windows
+
+package hcn
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// HealthProbePriority is the priority of the rules allowing health-probe sources.
+// It sits above the NetworkPolicy band and every default-deny posture so enabling
+// policies never breaks kubelet or load-balancer probes, but below quarantine.
+const HealthProbePriority uint16 = 60
+
+// NewHealthProbeProvider creates a provider allowing ingress from the given
+// probe sources (node CIDR, load-balancer probe IPs) on every endpoint
+func NewHealthProbeProvider(sources []string) (*StaticProvider, error) {
+	for _, source := range sources {
+		if net.ParseIP(source) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(source); err != nil {
+			return nil, fmt.Errorf("invalid health probe source %q: not an IP or CIDR", source)
+		}
+	}
+
+	return NewStaticProvider([]StaticRuleSet{
+		{
+			Name: "health-probes",
+			Rules: []ACLRule{
+				{
+					Name:            "allow-health-probes",
+					Action:          hcn.ActionTypeAllow,
+					Direction:       hcn.DirectionTypeIn,
+					RemoteAddresses: strings.Join(sources, ","),
+					Priority:        HealthProbePriority,
+				},
+			},
+		},
+	}), nil
+}
@@ -0,0 +1,33 @@
+//go:build windows
+
+package hcn
+
+import (
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+func TestNewHealthProbeProvider(t *testing.T) {
+	provider, err := NewHealthProbeProvider([]string{"10.240.0.0/16", "168.63.129.16"})
+	if err != nil {
+		t.Fatalf("NewHealthProbeProvider failed: %v", err)
+	}
+
+	rules := provider.DesiredRulesFor(hcn.HostComputeEndpoint{Id: "ep-1"})["static/health-probes"]
+	if len(rules) != 1 {
+		t.Fatalf("Expected 1 health probe rule, got %d", len(rules))
+	}
+	if rules[0].Direction != hcn.DirectionTypeIn || rules[0].RemoteAddresses != "10.240.0.0/16,168.63.129.16" {
+		t.Errorf("Unexpected health probe rule: %+v", rules[0])
+	}
+	if rules[0].Priority != HealthProbePriority {
+		t.Errorf("Expected priority %d, got %d", HealthProbePriority, rules[0].Priority)
+	}
+}
+
+func TestNewHealthProbeProvider_InvalidSource(t *testing.T) {
+	if _, err := NewHealthProbeProvider([]string{"not-an-ip"}); err == nil {
+		t.Fatal("Expected error for invalid probe source")
+	}
+}
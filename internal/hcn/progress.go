@@ -0,0 +1,108 @@
+//go:build windows
+
+package hcn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// ErrApplyInterrupted is returned when an apply's context ends before every
+// endpoint was programmed. The endpoints already programmed stay tracked and
+// the next apply of the policy starts with the remaining ones.
+var ErrApplyInterrupted = errors.New("apply interrupted")
+
+// ApplyACLRulesContext is ApplyACLRules bounded by ctx: once ctx is done no
+// further endpoints are started, and ErrApplyInterrupted is returned after the
+// in-progress ones finish. Large policies can thus be applied over several
+// reconciles, each resuming where the previous one stopped.
+func (m *Manager) ApplyACLRulesContext(ctx context.Context, policyKey PolicyKey, rules []ACLRule) error {
+	m.logger.Info("Applying ACL rules", "policyKey", policyKey, "ruleCount", len(rules))
+	m.desired.Set(string(policyKey), rules)
+	return m.applyDesired(ctx, string(policyKey))
+}
+
+// ApplyACLRulesToEndpoints records the given ACL rules as the desired state for
+// policyKey on the listed endpoints only, and reconciles the node toward it.
+// Endpoints left out of endpointIDs lose the policy's rules; IDs of endpoints
+// that do not exist yet are programmed once they appear.
+func (m *Manager) ApplyACLRulesToEndpoints(ctx context.Context, policyKey PolicyKey, rules []ACLRule, endpointIDs []string) error {
+	m.logger.Info("Applying ACL rules to endpoints", "policyKey", policyKey, "ruleCount", len(rules),
+		"targetCount", len(endpointIDs))
+	m.desired.SetForEndpoints(string(policyKey), rules, endpointIDs)
+	return m.applyDesired(ctx, string(policyKey))
+}
+
+// ApplyACLRulesToAddresses records the given ACL rules as the desired state for
+// policyKey on the endpoints owning one of addresses, and reconciles the node
+// toward it. Endpoints without one of the addresses lose the policy's rules.
+func (m *Manager) ApplyACLRulesToAddresses(ctx context.Context, policyKey PolicyKey, rules []ACLRule, addresses []string) error {
+	m.logger.Info("Applying ACL rules to endpoint addresses", "policyKey", policyKey, "ruleCount", len(rules),
+		"addressCount", len(addresses))
+	m.desired.SetForAddresses(string(policyKey), rules, addresses)
+	return m.applyDesired(ctx, string(policyKey))
+}
+
+// applyDesired converges every endpoint toward the desired rules of policyKey
+func (m *Manager) applyDesired(ctx context.Context, policyKey string) (err error) {
+	defer m.recordApply(time.Now(), &err)
+
+	// List all HCN endpoints
+	endpoints, err := m.listEndpoints()
+	if err != nil {
+		return fmt.Errorf("failed to list HCN endpoints: %w", err)
+	}
+
+	if len(endpoints) == 0 {
+		m.logger.Info("No HCN endpoints found, skipping rule application")
+	}
+
+	// Rules programmed on the networks move back to the endpoints
+	err = errors.Join(m.syncPolicy(ctx, policyKey, endpoints), m.removeNetworkTracked(policyKey))
+	if err == nil {
+		m.publishApplied(policyKey)
+	}
+	return err
+}
+
+// resumeOrder returns the order in which a sync visits endpoints: those left
+// unfinished by an interrupted sync of policyKey first, then the rest, each in
+// listing order
+func (m *Manager) resumeOrder(policyKey string, endpoints []hcn.HostComputeEndpoint) []int {
+	m.mu.RLock()
+	remaining := m.remaining[policyKey]
+	m.mu.RUnlock()
+
+	order := make([]int, 0, len(endpoints))
+	for i := range endpoints {
+		if remaining[endpoints[i].Id] {
+			order = append(order, i)
+		}
+	}
+	if len(order) > 0 {
+		m.logger.Info("Resuming interrupted apply",
+			"policyKey", policyKey,
+			"remainingEndpoints", len(order),
+			"endpointCount", len(endpoints))
+	}
+	for i := range endpoints {
+		if !remaining[endpoints[i].Id] {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+// setRemainingLocked records the endpoints an interrupted sync of policyKey did not
+// reach; an empty set clears the record. Callers hold mu.
+func (m *Manager) setRemainingLocked(policyKey string, remaining map[string]bool) {
+	if len(remaining) == 0 {
+		delete(m.remaining, policyKey)
+		return
+	}
+	m.remaining[policyKey] = remaining
+}
@@ -0,0 +1,142 @@
+//go:build windows
+
+package hcn
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+// interruptingClient cancels an apply's context after a number of endpoint adds
+// and records which endpoints were added to
+type interruptingClient struct {
+	*FakeClient
+	cancel func()
+	after  int
+	added  []string
+}
+
+func (c *interruptingClient) ApplyEndpointPolicy(endpoint *hcn.HostComputeEndpoint, requestType hcn.RequestType, request hcn.PolicyEndpointRequest) error {
+	c.added = append(c.added, endpoint.Id)
+	if len(c.added) == c.after && c.cancel != nil {
+		c.cancel()
+	}
+	return c.FakeClient.ApplyEndpointPolicy(endpoint, requestType, request)
+}
+
+func TestApplyACLRulesContext_ResumesAfterInterruption(t *testing.T) {
+	client := &interruptingClient{FakeClient: NewFakeClient(5), after: 2}
+	manager := NewManager(client, logr.Discard())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client.cancel = cancel
+	err := manager.ApplyACLRulesContext(ctx, "default/test", benchmarkRules(1))
+	if !errors.Is(err, ErrApplyInterrupted) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected an interrupted apply, got %v", err)
+	}
+	ruleSets, _ := manager.GetAppliedPolicies("default/test")
+	if len(ruleSets) != 2 {
+		t.Fatalf("Expected the 2 programmed endpoints tracked, got %d", len(ruleSets))
+	}
+
+	// New rules touch every endpoint; the 3 missed ones go first
+	client.added, client.cancel = nil, nil
+	if err := manager.ApplyACLRules("default/test", benchmarkRules(2)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	want := []string{"fake-endpoint-2", "fake-endpoint-3", "fake-endpoint-4", "fake-endpoint-0", "fake-endpoint-1"}
+	if len(client.added) != len(want) {
+		t.Fatalf("Expected adds on %v, got %v", want, client.added)
+	}
+	for i := range want {
+		if client.added[i] != want[i] {
+			t.Fatalf("Expected adds on %v, got %v", want, client.added)
+		}
+	}
+
+	// A completed apply clears the progress record
+	if len(manager.remaining) != 0 {
+		t.Errorf("Expected no remaining endpoints, got %v", manager.remaining)
+	}
+	ruleSets, _ = manager.GetAppliedPolicies("default/test")
+	if len(ruleSets) != 5 || ruleSets[0].EndpointID != "fake-endpoint-0" {
+		t.Errorf("Expected all 5 endpoints tracked in listing order, got %+v", ruleSets)
+	}
+}
+
+func TestRemoveACLRules_ClearsProgress(t *testing.T) {
+	client := &interruptingClient{FakeClient: NewFakeClient(3), after: 1}
+	manager := NewManager(client, logr.Discard())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client.cancel = cancel
+	if err := manager.ApplyACLRulesContext(ctx, "default/test", benchmarkRules(1)); !errors.Is(err, ErrApplyInterrupted) {
+		t.Fatalf("Expected an interrupted apply, got %v", err)
+	}
+	if err := manager.RemoveACLRules("default/test"); err != nil {
+		t.Fatalf("RemoveACLRules failed: %v", err)
+	}
+	if _, exists := manager.remaining["default/test"]; exists {
+		t.Error("Expected progress cleared with the policy")
+	}
+}
+
+func TestApplyACLRulesToEndpoints(t *testing.T) {
+	manager := NewManager(NewFakeClient(3), logr.Discard())
+	ctx := context.Background()
+
+	if err := manager.ApplyACLRulesToEndpoints(ctx, "default/test", benchmarkRules(1),
+		[]string{"fake-endpoint-1", "not-yet-created"}); err != nil {
+		t.Fatalf("ApplyACLRulesToEndpoints failed: %v", err)
+	}
+	ruleSets, _ := manager.GetAppliedPolicies("default/test")
+	if len(ruleSets) != 1 || ruleSets[0].EndpointID != "fake-endpoint-1" {
+		t.Fatalf("Expected only fake-endpoint-1 programmed, got %+v", ruleSets)
+	}
+
+	// Retargeting moves the rules; the periodic reconcile keeps the targeting
+	if err := manager.ApplyACLRulesToEndpoints(ctx, "default/test", benchmarkRules(1), []string{"fake-endpoint-2"}); err != nil {
+		t.Fatalf("ApplyACLRulesToEndpoints failed: %v", err)
+	}
+	if err := manager.Reconcile(); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	ruleSets, _ = manager.GetAppliedPolicies("default/test")
+	if len(ruleSets) != 1 || ruleSets[0].EndpointID != "fake-endpoint-2" {
+		t.Fatalf("Expected only fake-endpoint-2 programmed, got %+v", ruleSets)
+	}
+
+	// The all-endpoints apply drops the targeting
+	if err := manager.ApplyACLRules("default/test", benchmarkRules(1)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	if ruleSets, _ = manager.GetAppliedPolicies("default/test"); len(ruleSets) != 3 {
+		t.Errorf("Expected all 3 endpoints programmed, got %+v", ruleSets)
+	}
+}
+
+func TestApplyACLRulesToAddresses(t *testing.T) {
+	manager := NewManager(NewFakeClient(3), logr.Discard())
+
+	// 10.244.0.3 belongs to fake-endpoint-1; 10.244.9.9 to no endpoint yet
+	if err := manager.ApplyACLRulesToAddresses(context.Background(), "default/test", benchmarkRules(1),
+		[]string{"10.244.0.3", "10.244.9.9"}); err != nil {
+		t.Fatalf("ApplyACLRulesToAddresses failed: %v", err)
+	}
+	ruleSets, _ := manager.GetAppliedPolicies("default/test")
+	if len(ruleSets) != 1 || ruleSets[0].EndpointID != "fake-endpoint-1" {
+		t.Fatalf("Expected only fake-endpoint-1 programmed, got %+v", ruleSets)
+	}
+
+	// The periodic reconcile keeps the targeting
+	if err := manager.Reconcile(); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if ruleSets, _ = manager.GetAppliedPolicies("default/test"); len(ruleSets) != 1 {
+		t.Errorf("Expected the targeting to survive a reconcile, got %+v", ruleSets)
+	}
+}
@@ -0,0 +1,126 @@
+//go:build windows
+
+package hcn
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Labels tracing a rule back to the object it was generated from. The
+// converter sets them on every rule of a NetworkPolicy.
+const (
+	// PolicyLabel holds the "namespace/name" of the source policy
+	PolicyLabel = "policy"
+
+	// RuleLabel holds the entry of the source policy's spec the rule was
+	// generated from, e.g. "ingress[0]". Rules differing only in it are still
+	// packed; the packed ACL lists every entry.
+	RuleLabel = "rule"
+
+	// SelectorLabel holds the pod selector of the source policy
+	SelectorLabel = "selector"
+
+	// UIDLabel holds the UID of the source object
+	UIDLabel = "uid"
+)
+
+// provenanceLabels are the labels of a rule's provenance, in the order they
+// are logged and kept in exemplars
+var provenanceLabels = []string{PolicyLabel, RuleLabel, SelectorLabel, UIDLabel}
+
+// Provenance returns the provenance labels of rules and the rules packed into
+// them. Values differing between rules are joined with commas.
+func Provenance(rules []ACLRule) map[string]string {
+	values := make(map[string][]string)
+	var collect func(rules []ACLRule)
+	collect = func(rules []ACLRule) {
+		for _, rule := range rules {
+			for _, key := range provenanceLabels {
+				for _, value := range strings.Split(rule.Labels[key], ",") {
+					if value != "" && !slices.Contains(values[key], value) {
+						values[key] = append(values[key], value)
+					}
+				}
+			}
+			collect(rule.Packed)
+		}
+	}
+	collect(rules)
+
+	provenance := make(map[string]string, len(values))
+	for key, list := range values {
+		provenance[key] = strings.Join(list, ",")
+	}
+	return provenance
+}
+
+// provenanceLogKeys returns the provenance of rules as structured log key-value pairs
+func provenanceLogKeys(rules []ACLRule) []any {
+	provenance := Provenance(rules)
+	var keys []any
+	for _, key := range provenanceLabels {
+		if value, ok := provenance[key]; ok {
+			keys = append(keys, key, value)
+		}
+	}
+	return keys
+}
+
+// provenanceExemplar returns the provenance of rules as exemplar labels. Labels
+// that would exceed prometheus.ExemplarMaxRunes are left out, the policy first
+// kept; nil when rules carry no provenance.
+func provenanceExemplar(rules []ACLRule) prometheus.Labels {
+	provenance := Provenance(rules)
+	exemplar := prometheus.Labels{}
+	runes := 0
+	for _, key := range provenanceLabels {
+		value, ok := provenance[key]
+		if !ok {
+			continue
+		}
+		size := utf8.RuneCountInString(key) + utf8.RuneCountInString(value)
+		if runes+size > prometheus.ExemplarMaxRunes {
+			continue
+		}
+		exemplar[key] = value
+		runes += size
+	}
+	if len(exemplar) == 0 {
+		return nil
+	}
+	return exemplar
+}
+
+// recordRuleError classifies a failed HNS call programming rules and counts
+// it, with the provenance of the rules as exemplar
+func (m *Manager) recordRuleError(operation string, err error, rules []ACLRule) *HNSError {
+	parsed := ParseHNSError(err)
+	counter := m.hnsErrors.WithLabelValues(operation, parsed.Name)
+	if exemplar := provenanceExemplar(rules); exemplar != nil {
+		counter.(prometheus.ExemplarAdder).AddWithExemplar(1, exemplar)
+	} else {
+		counter.Inc()
+	}
+	return parsed
+}
+
+// unprogrammedRules returns the rules whose ACL is not among programmed
+func (m *Manager) unprogrammedRules(rules []ACLRule, programmed []hcn.EndpointPolicy) []ACLRule {
+	onEndpoint := make(map[string]bool, len(programmed))
+	for _, policy := range programmed {
+		onEndpoint[string(policy.Settings)] = true
+	}
+	var failed []ACLRule
+	for _, rule := range rules {
+		payload, err := m.payloads.get(rule)
+		if err != nil || !onEndpoint[string(payload)] {
+			failed = append(failed, rule)
+		}
+	}
+	return failed
+}
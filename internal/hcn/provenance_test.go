@@ -0,0 +1,71 @@
+//go:build windows
+
+package hcn
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// labelledRules returns benchmarkRules labelled as generated from spec entry i of default/web
+func labelledRules(n int) []ACLRule {
+	rules := benchmarkRules(n)
+	for i := range rules {
+		rules[i].Labels = map[string]string{
+			PolicyLabel:   "default/web",
+			RuleLabel:     fmt.Sprintf("ingress[%d]", i),
+			SelectorLabel: "app=web",
+			UIDLabel:      "3f2a6c1e-8d4b-4f7a-9c2e-1b5d7e9f0a3c",
+		}
+	}
+	return rules
+}
+
+func TestProvenance(t *testing.T) {
+	rules := labelledRules(2)
+	packed := rules[0]
+	packed.Packed = []ACLRule{rules[0], rules[1]}
+
+	provenance := Provenance([]ACLRule{packed})
+	if provenance[PolicyLabel] != "default/web" || provenance[RuleLabel] != "ingress[0],ingress[1]" {
+		t.Errorf("Expected the entries of the packed rules joined, got %v", provenance)
+	}
+	if len(Provenance(benchmarkRules(1))) != 0 {
+		t.Error("Expected no provenance for unlabelled rules")
+	}
+
+	// Exemplars leave out the labels that do not fit
+	rules[0].Labels[SelectorLabel] = "app in (" + strings.Repeat("web,", 30) + "api)"
+	exemplar := provenanceExemplar(rules[:1])
+	if _, found := exemplar[SelectorLabel]; found || exemplar[PolicyLabel] != "default/web" || exemplar[UIDLabel] == "" {
+		t.Errorf("Expected the long selector left out of the exemplar, got %v", exemplar)
+	}
+}
+
+func TestManager_ApplyFailureExemplar(t *testing.T) {
+	client := &rejectingClient{FakeClient: NewFakeClient(1), reject: "fake-endpoint-0"}
+	manager := NewManager(client, logr.Discard())
+	if err := manager.ApplyACLRules("default/web", labelledRules(2)); err == nil {
+		t.Fatal("Expected the rejecting endpoint to fail the apply")
+	}
+
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(manager.hnsErrors); err != nil {
+		t.Fatalf("Failed to register collector: %v", err)
+	}
+	families, err := registry.Gather()
+	if err != nil || len(families) != 1 {
+		t.Fatalf("Expected the HNS errors to be gathered, got %v, %v", families, err)
+	}
+	exemplar := map[string]string{}
+	for _, pair := range families[0].GetMetric()[0].GetCounter().GetExemplar().GetLabel() {
+		exemplar[pair.GetName()] = pair.GetValue()
+	}
+	if exemplar[PolicyLabel] != "default/web" || exemplar[RuleLabel] != "ingress[0],ingress[1]" {
+		t.Errorf("Expected the failed rules' provenance as exemplar, got %v", exemplar)
+	}
+}
@@ -0,0 +1,90 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// RuleProvider is a source of desired ACL rules. The Manager merges the rules of
+// all registered providers into each endpoint's desired ACL table, so new rule
+// sources can be plugged in without touching the reconciler.
+type RuleProvider interface {
+	// Name identifies the provider in logs
+	Name() string
+
+	// DesiredRulesFor returns the rules this provider wants on the given endpoint,
+	// keyed by the policy key used to track them
+	DesiredRulesFor(endpoint hcn.HostComputeEndpoint) map[string][]ACLRule
+}
+
+// Name implements RuleProvider. The desired state cache is the provider fed by
+// the NetworkPolicy controller.
+func (d *DesiredState) Name() string {
+	return "networkpolicy"
+}
+
+// DesiredRulesFor implements RuleProvider
+func (d *DesiredState) DesiredRulesFor(endpoint hcn.HostComputeEndpoint) map[string][]ACLRule {
+	return d.tableFor(endpoint)
+}
+
+// StaticRuleSet is a named group of rules loaded from the static rules file
+type StaticRuleSet struct {
+	// Name identifies the rule set; it is tracked under the key "static/<name>"
+	Name string `json:"name"`
+
+	// Rules are applied to every endpoint on the node
+	Rules []ACLRule `json:"rules"`
+}
+
+// StaticProvider serves node-wide rules defined in a configuration file
+type StaticProvider struct {
+	ruleSets []StaticRuleSet
+}
+
+// NewStaticProvider creates a provider serving the given rule sets
+func NewStaticProvider(ruleSets []StaticRuleSet) *StaticProvider {
+	return &StaticProvider{ruleSets: ruleSets}
+}
+
+// LoadStaticProvider reads a JSON list of StaticRuleSets from path
+func LoadStaticProvider(path string) (*StaticProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read static rules file: %w", err)
+	}
+
+	var ruleSets []StaticRuleSet
+	if err := json.Unmarshal(data, &ruleSets); err != nil {
+		return nil, fmt.Errorf("failed to parse static rules file %s: %w", path, err)
+	}
+
+	for i, ruleSet := range ruleSets {
+		if ruleSet.Name == "" {
+			return nil, fmt.Errorf("static rule set %d in %s has no name", i, path)
+		}
+	}
+
+	return NewStaticProvider(ruleSets), nil
+}
+
+// Name implements RuleProvider
+func (p *StaticProvider) Name() string {
+	return "static"
+}
+
+// DesiredRulesFor implements RuleProvider
+func (p *StaticProvider) DesiredRulesFor(endpoint hcn.HostComputeEndpoint) map[string][]ACLRule {
+	table := make(map[string][]ACLRule, len(p.ruleSets))
+	for _, ruleSet := range p.ruleSets {
+		rules := make([]ACLRule, len(ruleSet.Rules))
+		copy(rules, ruleSet.Rules)
+		table[string(NewPolicyKey(SourceStatic, ruleSet.Name))] = rules
+	}
+	return table
+}
@@ -0,0 +1,108 @@
+//go:build windows
+
+package hcn
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+func TestLoadStaticProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "static.json")
+	content := `[{"name": "allow-monitoring", "rules": [{"Name": "allow-9100", "Action": "Allow", "Direction": "In", "Protocol": "6", "LocalPorts": "9100", "Priority": 90}]}]`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write static rules file: %v", err)
+	}
+
+	provider, err := LoadStaticProvider(path)
+	if err != nil {
+		t.Fatalf("LoadStaticProvider failed: %v", err)
+	}
+
+	table := provider.DesiredRulesFor(hcn.HostComputeEndpoint{Id: "ep-1"})
+	rules, exists := table["static/allow-monitoring"]
+	if !exists {
+		t.Fatalf("Expected static/allow-monitoring in table, got %v", table)
+	}
+	if len(rules) != 1 || rules[0].LocalPorts != "9100" || rules[0].Action != hcn.ActionTypeAllow {
+		t.Errorf("Unexpected static rules: %+v", rules)
+	}
+}
+
+func TestLoadStaticProvider_MissingName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "static.json")
+	if err := os.WriteFile(path, []byte(`[{"rules": []}]`), 0o600); err != nil {
+		t.Fatalf("failed to write static rules file: %v", err)
+	}
+
+	if _, err := LoadStaticProvider(path); err == nil {
+		t.Fatal("Expected error for rule set without a name")
+	}
+}
+
+func TestQuarantineProvider(t *testing.T) {
+	provider := NewQuarantineProvider()
+	provider.Quarantine("ep-1", "compromised")
+
+	table := provider.DesiredRulesFor(hcn.HostComputeEndpoint{Id: "ep-1"})
+	rules := table["quarantine/ep-1"]
+	if len(rules) != 2 {
+		t.Fatalf("Expected 2 block rules for quarantined endpoint, got %d", len(rules))
+	}
+	for _, rule := range rules {
+		if rule.Action != hcn.ActionTypeBlock {
+			t.Errorf("Expected Block action, got %v", rule.Action)
+		}
+	}
+
+	if table := provider.DesiredRulesFor(hcn.HostComputeEndpoint{Id: "ep-2"}); len(table) != 0 {
+		t.Errorf("Expected no rules for healthy endpoint, got %v", table)
+	}
+
+	provider.Release("ep-1")
+	if len(provider.List()) != 0 {
+		t.Error("Expected no quarantined endpoints after release")
+	}
+}
+
+func TestManager_MergesProviders(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{
+		{Id: "ep-1", Name: "endpoint-1"},
+		{Id: "ep-2", Name: "endpoint-2"},
+	}
+
+	manager := NewManager(mockClient, logr.Discard())
+	manager.RegisterProvider(NewStaticProvider([]StaticRuleSet{
+		{Name: "node", Rules: []ACLRule{{Name: "allow-ssh", Action: hcn.ActionTypeAllow, Direction: hcn.DirectionTypeIn, LocalPorts: "22", Priority: 90}}},
+	}))
+	quarantine := NewQuarantineProvider()
+	quarantine.Quarantine("ep-2", "")
+	manager.RegisterProvider(quarantine)
+
+	if err := manager.Reconcile(); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	staticSets, exists := manager.GetAppliedPolicies("static/node")
+	if !exists || len(staticSets) != 2 {
+		t.Errorf("Expected static rules on 2 endpoints, got %d", len(staticSets))
+	}
+	quarantineSets, exists := manager.GetAppliedPolicies("quarantine/ep-2")
+	if !exists || len(quarantineSets) != 1 || quarantineSets[0].EndpointID != "ep-2" {
+		t.Errorf("Expected quarantine rules only on ep-2, got %+v", quarantineSets)
+	}
+
+	// Releasing the endpoint removes its quarantine rules on the next pass
+	quarantine.Release("ep-2")
+	if err := manager.Reconcile(); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if _, exists := manager.GetAppliedPolicies("quarantine/ep-2"); exists {
+		t.Error("Expected quarantine rules to be removed after release")
+	}
+}
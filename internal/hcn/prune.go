@@ -0,0 +1,89 @@
+//go:build windows
+
+package hcn
+
+import (
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// staleEndpoints returns the tracked endpoints missing from live that HCN
+// confirms are deleted. A listing taken before a concurrent sync programmed a
+// new endpoint lacks it too, so absence alone is not enough.
+func (m *Manager) staleEndpoints(live map[string]bool) map[string]bool {
+	m.mu.RLock()
+	candidates := make(map[string]bool)
+	for _, ruleSets := range m.appliedPolicies {
+		for _, ruleSet := range ruleSets {
+			if !live[ruleSet.EndpointID] {
+				candidates[ruleSet.EndpointID] = true
+			}
+		}
+	}
+	for endpointID := range m.pinned {
+		if !live[endpointID] {
+			candidates[endpointID] = true
+		}
+	}
+	m.mu.RUnlock()
+
+	stale := make(map[string]bool, len(candidates))
+	for endpointID := range candidates {
+		if _, err := m.client.GetEndpointByID(endpointID); err != nil && hcn.IsNotFoundError(err) {
+			stale[endpointID] = true
+		}
+	}
+	return stale
+}
+
+// pruneTrackingLocked drops the tracked RuleSets, pins and unreached-endpoint
+// records of stale endpoints, so removals do not address deleted endpoints
+// and the priority store does not keep them. It returns how many RuleSets
+// were dropped. Callers hold mu.
+func (m *Manager) pruneTrackingLocked(stale map[string]bool) int {
+	pruned := 0
+	for policyKey, ruleSets := range m.appliedPolicies {
+		kept := make([]RuleSet, 0, len(ruleSets))
+		for _, ruleSet := range ruleSets {
+			if !stale[ruleSet.EndpointID] {
+				kept = append(kept, ruleSet)
+			}
+		}
+		if len(kept) == len(ruleSets) {
+			continue
+		}
+		pruned += len(ruleSets) - len(kept)
+		m.recordHistoryLocked(policyKey, ruleSets, kept)
+		if len(kept) == 0 {
+			delete(m.appliedPolicies, policyKey)
+		} else {
+			m.appliedPolicies[policyKey] = kept
+		}
+	}
+	for endpointID := range stale {
+		delete(m.pinned, endpointID)
+	}
+	for policyKey, remaining := range m.remaining {
+		for endpointID := range remaining {
+			if stale[endpointID] {
+				delete(remaining, endpointID)
+			}
+		}
+		m.setRemainingLocked(policyKey, remaining)
+	}
+	return pruned
+}
+
+// pruneStaleEndpoints forgets what was programmed on deleted endpoints and
+// persists the trimmed tracking
+func (m *Manager) pruneStaleEndpoints(live map[string]bool) {
+	stale := m.staleEndpoints(live)
+	m.mu.Lock()
+	pruned := m.pruneTrackingLocked(stale)
+	m.pruneHistoryLocked(live)
+	m.mu.Unlock()
+	if pruned == 0 {
+		return
+	}
+	m.logger.Info("Pruned tracked rules of deleted endpoints", "endpoints", len(stale), "ruleSets", pruned)
+	m.savePriorities()
+}
@@ -0,0 +1,60 @@
+//go:build windows
+
+package hcn
+
+import (
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+func TestListEndpoints_PrunesDeletedEndpoints(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{{Id: "ep-1"}, {Id: "ep-2"}, {Id: "ep-3"}}
+
+	manager := NewManager(mockClient, logr.Discard())
+	if err := manager.ApplyACLRules("default/test", benchmarkRules(1)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	// ep-2 is deleted; ep-3 is missing from a listing that raced its creation
+	mockClient.endpoints = []hcn.HostComputeEndpoint{{Id: "ep-1"}}
+	mockClient.refreshed = map[string]*hcn.HostComputeEndpoint{"ep-3": {Id: "ep-3"}}
+	if _, err := manager.listEndpoints(); err != nil {
+		t.Fatalf("listEndpoints failed: %v", err)
+	}
+
+	ruleSets, _ := manager.GetAppliedPolicies("default/test")
+	if len(ruleSets) != 2 || ruleSets[0].EndpointID != "ep-1" || ruleSets[1].EndpointID != "ep-3" {
+		t.Fatalf("Expected ep-1 and ep-3 tracked, got %+v", ruleSets)
+	}
+
+	if err := manager.RemoveACLRules("default/test"); err != nil {
+		t.Fatalf("RemoveACLRules failed: %v", err)
+	}
+	if removed := mockClient.removedPolicies["ep-2"]; len(removed) != 0 {
+		t.Errorf("Expected no removal on the deleted endpoint, got %d", len(removed))
+	}
+	if removed := mockClient.removedPolicies["ep-1"]; len(removed) != 1 {
+		t.Errorf("Expected 1 removal on ep-1, got %d", len(removed))
+	}
+}
+
+func TestRemoveACLRules_EndpointDeletedBeforePrune(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{{Id: "ep-1"}, {Id: "ep-2"}}
+
+	manager := NewManager(mockClient, logr.Discard())
+	if err := manager.ApplyACLRules("default/test", benchmarkRules(1)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	mockClient.refreshed = map[string]*hcn.HostComputeEndpoint{"ep-2": nil}
+	if err := manager.RemoveACLRules("default/test"); err != nil {
+		t.Fatalf("Expected a deleted endpoint to need no removal, got %v", err)
+	}
+	if removed := mockClient.removedPolicies["ep-1"]; len(removed) != 1 {
+		t.Errorf("Expected 1 removal on ep-1, got %d", len(removed))
+	}
+}
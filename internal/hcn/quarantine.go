@@ -0,0 +1,97 @@
+//go:build windows
+
+package hcn
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// QuarantinePriority is the priority of the block-all rules programmed on
+// quarantined endpoints. It sits above the NetworkPolicy band so quarantine
+// always wins over allow rules.
+const QuarantinePriority uint16 = 50
+
+// QuarantineProvider isolates individual endpoints by blocking all of their
+// inbound and outbound traffic
+type QuarantineProvider struct {
+	mu sync.RWMutex
+
+	// endpoints maps quarantined endpoint ID -> reason
+	endpoints map[string]string
+}
+
+// NewQuarantineProvider creates a provider with no quarantined endpoints
+func NewQuarantineProvider() *QuarantineProvider {
+	return &QuarantineProvider{
+		endpoints: make(map[string]string),
+	}
+}
+
+// Quarantine marks an endpoint as isolated
+func (p *QuarantineProvider) Quarantine(endpointID, reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.endpoints[endpointID] = reason
+}
+
+// Release lifts the quarantine from an endpoint
+func (p *QuarantineProvider) Release(endpointID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.endpoints, endpointID)
+}
+
+// List returns the quarantined endpoint IDs, sorted
+func (p *QuarantineProvider) List() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	ids := make([]string, 0, len(p.endpoints))
+	for id := range p.endpoints {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Name implements RuleProvider
+func (p *QuarantineProvider) Name() string {
+	return "quarantine"
+}
+
+// DesiredRulesFor implements RuleProvider
+func (p *QuarantineProvider) DesiredRulesFor(endpoint hcn.HostComputeEndpoint) map[string][]ACLRule {
+	p.mu.RLock()
+	reason, quarantined := p.endpoints[endpoint.Id]
+	p.mu.RUnlock()
+
+	if !quarantined {
+		return nil
+	}
+
+	key := string(NewPolicyKey(SourceQuarantine, endpoint.Id))
+	name := key
+	if reason != "" {
+		name += " (" + reason + ")"
+	}
+
+	return map[string][]ACLRule{
+		key: {
+			{
+				Name:      name,
+				Action:    hcn.ActionTypeBlock,
+				Direction: hcn.DirectionTypeIn,
+				Priority:  QuarantinePriority,
+			},
+			{
+				Name:      name,
+				Action:    hcn.ActionTypeBlock,
+				Direction: hcn.DirectionTypeOut,
+				Priority:  QuarantinePriority,
+			},
+		},
+	}
+}
@@ -0,0 +1,90 @@
+//go:build windows
+
+package hcn
+
+import (
+	"sort"
+	"strings"
+)
+
+// PolicyStatus is the outcome of the last sync of a tracked policy
+type PolicyStatus string
+
+const (
+	// PolicyStatusApplied means the last sync programmed every endpoint
+	PolicyStatusApplied PolicyStatus = "applied"
+
+	// PolicyStatusFailed means the last sync failed on at least one endpoint
+	PolicyStatusFailed PolicyStatus = "failed"
+)
+
+// PolicyFilter selects tracked policies; empty fields match everything
+type PolicyFilter struct {
+	// Namespace matches the key segment before the policy name, so both
+	// "default/web" and "edge/default/web" are in namespace "default"
+	Namespace string
+
+	// EndpointID matches policies programmed on the endpoint
+	EndpointID string
+
+	// Status matches the outcome of the last sync
+	Status PolicyStatus
+}
+
+// PolicySummary describes one tracked policy without its rules
+type PolicySummary struct {
+	Key       string
+	Status    PolicyStatus
+	Endpoints int
+	Rules     int
+
+	// Error is the error of the last sync when Status is PolicyStatusFailed
+	Error string
+}
+
+// ListPolicies returns the tracked policies matching filter, sorted by key
+func (m *Manager) ListPolicies(filter PolicyFilter) []PolicySummary {
+	return m.Snapshot().ListPolicies(filter)
+}
+
+// ListPolicies returns the policies in the snapshot matching filter, sorted by key
+func (s Snapshot) ListPolicies(filter PolicyFilter) []PolicySummary {
+	var summaries []PolicySummary
+	for key, ruleSets := range s.policies {
+		if filter.Namespace != "" && policyNamespace(key) != filter.Namespace {
+			continue
+		}
+
+		summary := PolicySummary{Key: key, Status: PolicyStatusApplied, Endpoints: len(ruleSets)}
+		if syncErr, failed := s.syncErrors[key]; failed {
+			summary.Status, summary.Error = PolicyStatusFailed, syncErr
+		}
+		if filter.Status != "" && summary.Status != filter.Status {
+			continue
+		}
+
+		onEndpoint := filter.EndpointID == ""
+		for _, ruleSet := range ruleSets {
+			summary.Rules += len(ruleSet.Policies)
+			onEndpoint = onEndpoint || ruleSet.EndpointID == filter.EndpointID
+		}
+		if !onEndpoint {
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Key < summaries[j].Key
+	})
+	return summaries
+}
+
+// policyNamespace returns the namespace segment of a policy key
+func policyNamespace(key string) string {
+	segments := strings.Split(key, "/")
+	if len(segments) < 2 {
+		return ""
+	}
+	return segments[len(segments)-2]
+}
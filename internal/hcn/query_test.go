@@ -0,0 +1,78 @@
+//go:build windows
+
+package hcn
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+func TestListPolicies(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{{Id: "ep-1"}, {Id: "ep-2"}}
+
+	manager := NewManager(mockClient, logr.Discard())
+	for _, key := range []PolicyKey{"default/web", "edge/default/api", "kube-system/dns"} {
+		if err := manager.ApplyACLRules(key, benchmarkRules(2)); err != nil {
+			t.Fatalf("ApplyACLRules(%s) failed: %v", key, err)
+		}
+	}
+
+	mockClient.applyPolicyErr = errors.New("apply failed")
+	if err := manager.ApplyACLRules("default/broken", benchmarkRules(1)); err == nil {
+		t.Fatal("Expected apply error")
+	}
+	mockClient.applyPolicyErr = nil
+
+	// Only ep-1 carries kube-system/dns
+	manager.setEndpointTracking("kube-system/dns", "ep-2", nil, nil)
+
+	keys := func(summaries []PolicySummary) []string {
+		var keys []string
+		for _, summary := range summaries {
+			keys = append(keys, summary.Key)
+		}
+		return keys
+	}
+
+	tests := []struct {
+		name   string
+		filter PolicyFilter
+		want   []string
+	}{
+		{name: "all", want: []string{"default/broken", "default/web", "edge/default/api", "kube-system/dns"}},
+		{name: "namespace", filter: PolicyFilter{Namespace: "default"}, want: []string{"default/broken", "default/web", "edge/default/api"}},
+		{name: "endpoint", filter: PolicyFilter{EndpointID: "ep-2"}, want: []string{"default/web", "edge/default/api"}},
+		{name: "failed", filter: PolicyFilter{Status: PolicyStatusFailed}, want: []string{"default/broken"}},
+		{name: "combined", filter: PolicyFilter{Namespace: "kube-system", Status: PolicyStatusApplied}, want: []string{"kube-system/dns"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := keys(manager.ListPolicies(tt.filter))
+			if len(got) != len(tt.want) {
+				t.Fatalf("ListPolicies() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("ListPolicies() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	web := manager.ListPolicies(PolicyFilter{Namespace: "default", Status: PolicyStatusApplied})[0]
+	if web.Endpoints != 2 || web.Rules != 4 {
+		t.Errorf("Expected 2 endpoints and 4 rules for default/web, got %+v", web)
+	}
+
+	// A successful resync clears the failure
+	if err := manager.ApplyACLRules("default/broken", benchmarkRules(1)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	if failed := manager.ListPolicies(PolicyFilter{Status: PolicyStatusFailed}); len(failed) != 0 {
+		t.Errorf("Expected no failed policies, got %v", keys(failed))
+	}
+}
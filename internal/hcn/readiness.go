@@ -0,0 +1,99 @@
+//go:build windows
+
+package hcn
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// EndpointConvergence reports whether an endpoint carries the rules desired on it
+type EndpointConvergence struct {
+	// EndpointID is the endpoint owning the address; empty when none does yet
+	EndpointID string
+
+	// Pending lists the policy keys whose rules are not programmed on the
+	// endpoint yet, sorted
+	Pending []string
+}
+
+// Programmed reports whether the endpoint exists and every desired rule is on it
+func (c EndpointConvergence) Programmed() bool {
+	return c.EndpointID != "" && len(c.Pending) == 0
+}
+
+// ConvergeEndpoint programs the rules every provider wants on the endpoint
+// owning ip and reports which of them, or of policyKeys, are not in effect
+// there afterwards. policyKeys are the policies the caller knows apply to the
+// endpoint; those not desired on it or on its network yet stay pending, so an
+// endpoint is not reported programmed before the policies covering it were applied.
+func (m *Manager) ConvergeEndpoint(ctx context.Context, ip string, policyKeys []PolicyKey) (EndpointConvergence, error) {
+	endpoints, err := m.listEndpoints()
+	if err != nil {
+		return EndpointConvergence{}, fmt.Errorf("failed to list HCN endpoints: %w", err)
+	}
+	endpoint, found := m.index.ByIP(ip)
+	if !found {
+		return EndpointConvergence{}, nil
+	}
+
+	table := m.desiredRulesFor(endpoint)
+	keys := make([]string, 0, len(table))
+	for key := range table {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Syncs leave other endpoints converged and only touch this one when it lags
+	for _, key := range keys {
+		if m.endpointConverged(key, endpoint.Id, table[key]) {
+			continue
+		}
+		if err := m.syncPolicy(ctx, key, endpoints); err != nil {
+			m.logger.V(1).Info("Sync for endpoint convergence failed",
+				"policyKey", key,
+				"endpointID", endpoint.Id,
+				"error", err.Error())
+		}
+	}
+
+	convergence := EndpointConvergence{EndpointID: endpoint.Id}
+	pending := make(map[string]bool)
+	for _, key := range keys {
+		if !m.endpointConverged(key, endpoint.Id, table[key]) {
+			pending[key] = true
+		}
+	}
+	for _, policyKey := range policyKeys {
+		key := string(policyKey)
+		if _, desired := table[key]; desired {
+			continue
+		}
+		// Network-scoped policies are in effect once the endpoint's network carries them
+		if !m.networkConverged(key, endpoint.HostComputeNetwork) {
+			pending[key] = true
+		}
+	}
+	for key := range pending {
+		convergence.Pending = append(convergence.Pending, key)
+	}
+	sort.Strings(convergence.Pending)
+	return convergence, nil
+}
+
+// endpointConverged reports whether the rules tracked for policyKey on the
+// endpoint are rules and no interrupted sync still has to reach it
+func (m *Manager) endpointConverged(policyKey, endpointID string, rules []ACLRule) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.remaining[policyKey][endpointID] {
+		return false
+	}
+	for _, ruleSet := range m.appliedPolicies[policyKey] {
+		if ruleSet.EndpointID == endpointID {
+			return rulesEqual(ruleSet.Rules, rules)
+		}
+	}
+	return len(rules) == 0
+}
@@ -0,0 +1,60 @@
+//go:build windows
+
+package hcn
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestConvergeEndpoint(t *testing.T) {
+	client := NewFakeClient(2)
+	manager := NewManager(client, logr.Discard())
+	ctx := context.Background()
+
+	// No endpoint owns the address yet
+	convergence, err := manager.ConvergeEndpoint(ctx, "10.244.0.99", nil)
+	if err != nil {
+		t.Fatalf("ConvergeEndpoint failed: %v", err)
+	}
+	if convergence.Programmed() || convergence.EndpointID != "" {
+		t.Fatalf("Expected no endpoint, got %+v", convergence)
+	}
+
+	// A policy not applied yet keeps the endpoint pending
+	convergence, err = manager.ConvergeEndpoint(ctx, "10.244.0.2", []PolicyKey{"default/web"})
+	if err != nil {
+		t.Fatalf("ConvergeEndpoint failed: %v", err)
+	}
+	if convergence.EndpointID != "fake-endpoint-0" || len(convergence.Pending) != 1 || convergence.Pending[0] != "default/web" {
+		t.Fatalf("Expected default/web pending on fake-endpoint-0, got %+v", convergence)
+	}
+
+	// Desired rules not synced yet are programmed by the convergence
+	manager.desired.Set("default/web", benchmarkRules(2))
+	convergence, err = manager.ConvergeEndpoint(ctx, "10.244.0.2", []PolicyKey{"default/web"})
+	if err != nil {
+		t.Fatalf("ConvergeEndpoint failed: %v", err)
+	}
+	if !convergence.Programmed() {
+		t.Fatalf("Expected the endpoint programmed, got %+v", convergence)
+	}
+	endpoints, _ := client.ListEndpoints()
+	for _, endpoint := range endpoints {
+		if len(endpoint.Policies) != 2 {
+			t.Errorf("Expected 2 ACLs on %s, got %d", endpoint.Id, len(endpoint.Policies))
+		}
+	}
+
+	// Rules limited to other addresses are not waited for
+	manager.desired.SetForAddresses("default/other", benchmarkRules(1), []string{"10.244.0.3"})
+	convergence, err = manager.ConvergeEndpoint(ctx, "10.244.0.2", []PolicyKey{"default/web"})
+	if err != nil {
+		t.Fatalf("ConvergeEndpoint failed: %v", err)
+	}
+	if !convergence.Programmed() {
+		t.Fatalf("Expected the endpoint programmed, got %+v", convergence)
+	}
+}
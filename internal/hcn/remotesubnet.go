@@ -0,0 +1,399 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// RemoteSubnetMode selects how Block rules covering the provider address of
+// an overlay remote subnet route are handled. The CNI of an overlay network
+// (e.g. flannel or Calico VXLAN) adds a RemoteSubnetRoute network policy per
+// remote node, tunnelling the node's pod subnet to its provider address; a
+// Block rule matching that address also cuts the pods off the remote node
+// itself, which is rarely what a NetworkPolicy meant.
+type RemoteSubnetMode string
+
+const (
+	// RemoteSubnetIgnore programs Block rules as generated without looking at routes
+	RemoteSubnetIgnore RemoteSubnetMode = "ignore"
+
+	// RemoteSubnetWarn programs Block rules as generated and reports their conflicts
+	RemoteSubnetWarn RemoteSubnetMode = "warn"
+
+	// RemoteSubnetExclude reports conflicts and programs Block rules without
+	// the provider addresses of the endpoint's network
+	RemoteSubnetExclude RemoteSubnetMode = "exclude"
+)
+
+// remoteSubnetRefreshInterval bounds how often routes are listed from HNS
+const remoteSubnetRefreshInterval = 30 * time.Second
+
+// ParseRemoteSubnetMode parses a --remote-subnet-conflicts value
+func ParseRemoteSubnetMode(value string) (RemoteSubnetMode, error) {
+	switch mode := RemoteSubnetMode(value); mode {
+	case RemoteSubnetIgnore, RemoteSubnetWarn, RemoteSubnetExclude:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid remote subnet mode %q: must be ignore, warn or exclude", value)
+	}
+}
+
+// RemoteSubnetRoute is a RemoteSubnetRoute policy of an HNS network
+type RemoteSubnetRoute struct {
+	NetworkID         string `json:"networkID"`
+	DestinationPrefix string `json:"destinationPrefix"`
+	ProviderAddress   string `json:"providerAddress"`
+	IsolationID       uint16 `json:"isolationID,omitempty"`
+}
+
+// RemoteSubnetConflict is a desired Block rule covering the provider address of a route
+type RemoteSubnetConflict struct {
+	PolicyKey string            `json:"policyKey"`
+	Rule      string            `json:"rule"`
+	Priority  uint16            `json:"priority"`
+	Route     RemoteSubnetRoute `json:"route"`
+}
+
+// NetworkPolicyEntry is one policy of an HNS network
+type NetworkPolicyEntry struct {
+	Type     string          `json:"type"`
+	Settings json.RawMessage `json:"settings,omitempty"`
+
+	// PolicyKey is the policy that programmed it; empty for policies of the CNI or operators
+	PolicyKey string `json:"policyKey,omitempty"`
+}
+
+// NetworkPolicies lists the policies of one HNS network
+type NetworkPolicies struct {
+	NetworkID string               `json:"networkID"`
+	Name      string               `json:"name"`
+	Type      string               `json:"type"`
+	Policies  []NetworkPolicyEntry `json:"policies"`
+}
+
+// remoteSubnets caches the routes of the node's networks between refreshes
+type remoteSubnets struct {
+	mu        sync.RWMutex
+	mode      RemoteSubnetMode
+	refreshed time.Time
+
+	// routes maps network ID -> its remote subnet routes
+	routes map[string][]RemoteSubnetRoute
+
+	// reported are the conflicts already logged, so each is logged once
+	reported map[string]bool
+
+	// conflicts is the number of conflicts found by the last refresh
+	conflicts int
+}
+
+// SetRemoteSubnetMode selects how Block rules conflicting with remote subnet
+// routes are handled. It must be called before the Manager starts reconciling.
+func (m *Manager) SetRemoteSubnetMode(mode RemoteSubnetMode) {
+	m.remoteSubnets.mu.Lock()
+	defer m.remoteSubnets.mu.Unlock()
+	m.remoteSubnets.mode = mode
+	m.remoteSubnets.refreshed = time.Time{}
+}
+
+// ListNetworkPolicies returns the policies of every HNS network, marking
+// those programmed by the Manager with their policy key
+func (m *Manager) ListNetworkPolicies() ([]NetworkPolicies, error) {
+	client, ok := m.client.(NetworkClient)
+	if !ok {
+		return nil, ErrNetworkPoliciesUnsupported
+	}
+	networks, err := client.ListNetworks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list HNS networks: %w", err)
+	}
+
+	// Index what the Manager programmed by network and payload
+	owners := make(map[string]string)
+	m.mu.RLock()
+	for key, ruleSets := range m.networkApplied {
+		for _, ruleSet := range ruleSets {
+			for _, policy := range ruleSet.Policies {
+				owners[ruleSet.NetworkID+"/"+string(policy.Type)+":"+string(policy.Settings)] = key
+			}
+		}
+	}
+	m.mu.RUnlock()
+
+	listings := make([]NetworkPolicies, 0, len(networks))
+	for _, network := range networks {
+		listing := NetworkPolicies{
+			NetworkID: network.Id,
+			Name:      network.Name,
+			Type:      string(network.Type),
+			Policies:  make([]NetworkPolicyEntry, 0, len(network.Policies)),
+		}
+		for _, policy := range network.Policies {
+			listing.Policies = append(listing.Policies, NetworkPolicyEntry{
+				Type:      string(policy.Type),
+				Settings:  policy.Settings,
+				PolicyKey: owners[network.Id+"/"+string(policy.Type)+":"+string(policy.Settings)],
+			})
+		}
+		listings = append(listings, listing)
+	}
+	sort.Slice(listings, func(i, j int) bool { return listings[i].Name < listings[j].Name })
+	return listings, nil
+}
+
+// RemoteSubnetConflicts returns the desired Block rules covering the provider
+// address of a remote subnet route, as of the last route refresh
+func (m *Manager) RemoteSubnetConflicts() []RemoteSubnetConflict {
+	m.remoteSubnets.mu.RLock()
+	routes := m.remoteSubnets.routes
+	m.remoteSubnets.mu.RUnlock()
+
+	var conflicts []RemoteSubnetConflict
+	check := func(key string, rules []ACLRule) {
+		for _, rule := range rules {
+			if rule.Action != hcn.ActionTypeBlock {
+				continue
+			}
+			for _, networkRoutes := range routes {
+				for _, route := range networkRoutes {
+					if coversAddress(rule.RemoteAddresses, route.ProviderAddress) {
+						conflicts = append(conflicts, RemoteSubnetConflict{
+							PolicyKey: key,
+							Rule:      rule.Name,
+							Priority:  rule.Priority,
+							Route:     route,
+						})
+					}
+				}
+			}
+		}
+	}
+	for _, key := range m.desired.Keys() {
+		rules, _ := m.desired.Get(key)
+		check(key, rules)
+	}
+	for _, key := range m.networkKeys() {
+		m.mu.RLock()
+		rules := m.networkDesired[key]
+		m.mu.RUnlock()
+		check(key, rules)
+	}
+	return conflicts
+}
+
+// refreshRemoteSubnets relists the remote subnet routes of every network once
+// remoteSubnetRefreshInterval passed, and logs the conflicts not logged before
+func (m *Manager) refreshRemoteSubnets() {
+	rs := &m.remoteSubnets
+	rs.mu.RLock()
+	skip := rs.mode == RemoteSubnetIgnore || time.Since(rs.refreshed) < remoteSubnetRefreshInterval
+	rs.mu.RUnlock()
+	if skip {
+		return
+	}
+	client, ok := m.client.(NetworkClient)
+	if !ok {
+		return
+	}
+
+	networks, err := client.ListNetworks()
+	if err != nil {
+		m.logger.Error(err, "Failed to list HNS networks for remote subnet routes")
+		return
+	}
+	routes := make(map[string][]RemoteSubnetRoute)
+	for _, network := range networks {
+		for _, policy := range network.Policies {
+			if policy.Type != hcn.RemoteSubnetRoute {
+				continue
+			}
+			var setting hcn.RemoteSubnetRoutePolicySetting
+			if err := json.Unmarshal(policy.Settings, &setting); err != nil || setting.ProviderAddress == "" {
+				continue
+			}
+			routes[network.Id] = append(routes[network.Id], RemoteSubnetRoute{
+				NetworkID:         network.Id,
+				DestinationPrefix: setting.DestinationPrefix,
+				ProviderAddress:   setting.ProviderAddress,
+				IsolationID:       setting.IsolationId,
+			})
+		}
+	}
+
+	rs.mu.Lock()
+	rs.routes = routes
+	rs.refreshed = time.Now()
+	rs.mu.Unlock()
+
+	conflicts := m.RemoteSubnetConflicts()
+	reported := make(map[string]bool, len(conflicts))
+	rs.mu.Lock()
+	previous := rs.reported
+	rs.reported = reported
+	rs.conflicts = len(conflicts)
+	mode := rs.mode
+	rs.mu.Unlock()
+	for _, conflict := range conflicts {
+		id := conflict.PolicyKey + "/" + conflict.Rule + "/" + conflict.Route.NetworkID + "/" + conflict.Route.ProviderAddress
+		reported[id] = true
+		if previous[id] {
+			continue
+		}
+		m.logger.Info("Block rule covers the provider address of a remote subnet route",
+			"policyKey", conflict.PolicyKey,
+			"rule", conflict.Rule,
+			"priority", conflict.Priority,
+			"networkID", conflict.Route.NetworkID,
+			"destinationPrefix", conflict.Route.DestinationPrefix,
+			"providerAddress", conflict.Route.ProviderAddress,
+			"excluded", mode == RemoteSubnetExclude)
+	}
+}
+
+// remoteSubnetConflictCount returns the number of conflicts found by the last refresh
+func (m *Manager) remoteSubnetConflictCount() int {
+	m.remoteSubnets.mu.RLock()
+	defer m.remoteSubnets.mu.RUnlock()
+	return m.remoteSubnets.conflicts
+}
+
+// excludedRoutes returns the routes of networkID whose provider addresses
+// are removed from Block rules; none unless in RemoteSubnetExclude mode
+func (m *Manager) excludedRoutes(networkID string) []RemoteSubnetRoute {
+	m.remoteSubnets.mu.RLock()
+	defer m.remoteSubnets.mu.RUnlock()
+	if m.remoteSubnets.mode != RemoteSubnetExclude {
+		return nil
+	}
+	return m.remoteSubnets.routes[networkID]
+}
+
+// excludeProviderAddresses removes the provider addresses of routes from the
+// remote addresses of Block rules. rules is returned unchanged when nothing
+// is excluded.
+func excludeProviderAddresses(rules []ACLRule, routes []RemoteSubnetRoute) []ACLRule {
+	if len(routes) == 0 {
+		return rules
+	}
+
+	var excluded []ACLRule
+	for i, rule := range rules {
+		addresses := rule.RemoteAddresses
+		if rule.Action == hcn.ActionTypeBlock {
+			for _, route := range routes {
+				if coversAddress(addresses, route.ProviderAddress) {
+					addresses = excludeAddress(addresses, route.ProviderAddress)
+				}
+			}
+		}
+		if addresses == rule.RemoteAddresses {
+			if excluded != nil {
+				excluded = append(excluded, rule)
+			}
+			continue
+		}
+		if excluded == nil {
+			excluded = make([]ACLRule, i, len(rules))
+			copy(excluded, rules[:i])
+		}
+		if addresses == "" {
+			// Only the provider address was blocked
+			continue
+		}
+		rule.RemoteAddresses = addresses
+		excluded = append(excluded, rule)
+	}
+	if excluded == nil {
+		return rules
+	}
+	return excluded
+}
+
+// parseAddressPrefix parses an IP or CIDR entry of a remote address list
+func parseAddressPrefix(entry string) (netip.Prefix, bool) {
+	entry = strings.TrimSpace(entry)
+	if prefix, err := netip.ParsePrefix(entry); err == nil {
+		return prefix.Masked(), true
+	}
+	if addr, err := netip.ParseAddr(entry); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()), true
+	}
+	return netip.Prefix{}, false
+}
+
+// coversAddress reports whether a remote address list matches address; an
+// empty list matches any address
+func coversAddress(addresses, address string) bool {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return false
+	}
+	if addresses == "" {
+		return true
+	}
+	for _, entry := range strings.Split(addresses, ",") {
+		if prefix, ok := parseAddressPrefix(entry); ok && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludeAddress rewrites a remote address list so it no longer matches
+// address: every entry containing it is split into the prefixes around it.
+// An empty list, matching any address, is expanded to the wildcard of the
+// address's family minus the address plus the other family's wildcard.
+func excludeAddress(addresses, address string) string {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return addresses
+	}
+	if addresses == "" {
+		addresses = "0.0.0.0/0,::/0"
+	}
+
+	var kept []string
+	for _, entry := range strings.Split(addresses, ",") {
+		prefix, ok := parseAddressPrefix(entry)
+		if !ok || !prefix.Contains(addr) {
+			kept = append(kept, entry)
+			continue
+		}
+		for _, rest := range subtractAddress(prefix, addr) {
+			kept = append(kept, rest.String())
+		}
+	}
+	return strings.Join(kept, ",")
+}
+
+// subtractAddress returns the prefixes covering prefix except addr, largest first
+func subtractAddress(prefix netip.Prefix, addr netip.Addr) []netip.Prefix {
+	var rest []netip.Prefix
+	for bits := prefix.Bits(); bits < addr.BitLen(); bits++ {
+		// The half of the current prefix not holding addr is kept whole
+		half := netip.PrefixFrom(addr, bits+1).Masked()
+		rest = append(rest, netip.PrefixFrom(flipBit(half.Addr(), bits), bits+1))
+	}
+	return rest
+}
+
+// flipBit returns addr with bit i, counted from the most significant, inverted
+func flipBit(addr netip.Addr, i int) netip.Addr {
+	if addr.Is4() {
+		b := addr.As4()
+		b[i/8] ^= 0x80 >> (i % 8)
+		return netip.AddrFrom4(b)
+	}
+	b := addr.As16()
+	b[i/8] ^= 0x80 >> (i % 8)
+	return netip.AddrFrom16(b)
+}
@@ -0,0 +1,147 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+// addRemoteSubnetRoute adds a RemoteSubnetRoute policy to the fake network
+func addRemoteSubnetRoute(t *testing.T, client *FakeClient, prefix, providerAddress string) {
+	t.Helper()
+	settings, err := json.Marshal(hcn.RemoteSubnetRoutePolicySetting{
+		DestinationPrefix: prefix,
+		ProviderAddress:   providerAddress,
+		IsolationId:       4096,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := hcn.PolicyNetworkRequest{Policies: []hcn.NetworkPolicy{{Type: hcn.RemoteSubnetRoute, Settings: settings}}}
+	if err := client.AddNetworkPolicy(&hcn.HostComputeNetwork{Id: FakeNetworkID}, request); err != nil {
+		t.Fatalf("AddNetworkPolicy failed: %v", err)
+	}
+}
+
+func blockRule(remoteAddresses string) ACLRule {
+	return ACLRule{
+		Name:            "deny",
+		Action:          hcn.ActionTypeBlock,
+		Direction:       hcn.DirectionTypeOut,
+		RemoteAddresses: remoteAddresses,
+		Priority:        200,
+	}
+}
+
+func TestParseRemoteSubnetMode(t *testing.T) {
+	for _, value := range []string{"ignore", "warn", "exclude"} {
+		if mode, err := ParseRemoteSubnetMode(value); err != nil || string(mode) != value {
+			t.Errorf("ParseRemoteSubnetMode(%q) = %q, %v", value, mode, err)
+		}
+	}
+	if _, err := ParseRemoteSubnetMode("drop"); err == nil {
+		t.Error("Expected an invalid mode to be rejected")
+	}
+}
+
+func TestExcludeAddress(t *testing.T) {
+	tests := []struct {
+		addresses string
+		address   string
+		want      string
+	}{
+		{addresses: "10.0.0.0/30", address: "10.0.0.1", want: "10.0.0.2/31,10.0.0.0/32"},
+		{addresses: "10.0.0.5", address: "10.0.0.5", want: ""},
+		{addresses: "192.168.0.0/16,10.0.0.0/31", address: "10.0.0.0", want: "192.168.0.0/16,10.0.0.1/32"},
+		{addresses: "", address: "0.0.0.0", want: "128.0.0.0/1,64.0.0.0/2,32.0.0.0/3,16.0.0.0/4," +
+			"8.0.0.0/5,4.0.0.0/6,2.0.0.0/7,1.0.0.0/8,0.128.0.0/9,0.64.0.0/10,0.32.0.0/11,0.16.0.0/12," +
+			"0.8.0.0/13,0.4.0.0/14,0.2.0.0/15,0.1.0.0/16,0.0.128.0/17,0.0.64.0/18,0.0.32.0/19,0.0.16.0/20," +
+			"0.0.8.0/21,0.0.4.0/22,0.0.2.0/23,0.0.1.0/24,0.0.0.128/25,0.0.0.64/26,0.0.0.32/27,0.0.0.16/28," +
+			"0.0.0.8/29,0.0.0.4/30,0.0.0.2/31,0.0.0.1/32,::/0"},
+	}
+	for _, tt := range tests {
+		if got := excludeAddress(tt.addresses, tt.address); got != tt.want {
+			t.Errorf("excludeAddress(%q, %q) = %q, want %q", tt.addresses, tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestSubtractAddress_CoversPrefix(t *testing.T) {
+	prefix := netip.MustParsePrefix("172.16.0.0/20")
+	addr := netip.MustParseAddr("172.16.7.9")
+	rest := subtractAddress(prefix, addr)
+	if len(rest) != 12 {
+		t.Fatalf("Expected 12 prefixes, got %d", len(rest))
+	}
+	for _, p := range rest {
+		if p.Contains(addr) {
+			t.Errorf("Prefix %s still holds %s", p, addr)
+		}
+	}
+	if !coversAddress(excludeAddress(prefix.String(), "172.16.15.255"), "172.16.0.1") {
+		t.Error("Expected the rest of the prefix still covered")
+	}
+}
+
+func TestRemoteSubnetConflicts(t *testing.T) {
+	client := NewFakeClient(1)
+	addRemoteSubnetRoute(t, client, "10.244.1.0/24", "192.168.1.20")
+	manager := NewManager(client, logr.Discard())
+	manager.SetRemoteSubnetMode(RemoteSubnetWarn)
+
+	rules := []ACLRule{blockRule("192.168.0.0/16"), blockRule("172.16.0.0/12")}
+	if err := manager.ApplyACLRules("default/deny", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	conflicts := manager.RemoteSubnetConflicts()
+	if len(conflicts) != 1 || conflicts[0].PolicyKey != "default/deny" || conflicts[0].Route.ProviderAddress != "192.168.1.20" {
+		t.Fatalf("Expected one conflict on 192.168.1.20, got %+v", conflicts)
+	}
+
+	// Warn mode programs the rule as generated
+	applied, _ := manager.GetAppliedPolicies("default/deny")
+	for _, ruleSet := range applied {
+		if !reflect.DeepEqual(ruleSet.Rules, rules) {
+			t.Errorf("Expected the rules unchanged in warn mode, got %+v", ruleSet.Rules)
+		}
+	}
+}
+
+func TestRemoteSubnetExclude(t *testing.T) {
+	client := NewFakeClient(1)
+	addRemoteSubnetRoute(t, client, "10.244.1.0/24", "192.168.1.20")
+	manager := NewManager(client, logr.Discard())
+	manager.SetRemoteSubnetMode(RemoteSubnetExclude)
+
+	allow := benchmarkRules(1)[0]
+	allow.RemoteAddresses = "192.168.1.20"
+	rules := []ACLRule{blockRule("192.168.1.0/30"), blockRule("192.168.1.20"), allow}
+	if err := manager.ApplyACLRules("default/deny", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	endpoints, err := client.ListEndpoints()
+	if err != nil {
+		t.Fatalf("ListEndpoints failed: %v", err)
+	}
+	var programmed []string
+	for _, policy := range endpoints[0].Policies {
+		var setting hcn.AclPolicySetting
+		if err := json.Unmarshal(policy.Settings, &setting); err != nil {
+			t.Fatal(err)
+		}
+		programmed = append(programmed, string(setting.Action)+" "+setting.RemoteAddresses)
+	}
+	// The /30 does not hold the address; the single-address Block is dropped
+	want := []string{"Block 192.168.1.0/30", "Allow 192.168.1.20"}
+	if strings.Join(programmed, ";") != strings.Join(want, ";") {
+		t.Errorf("Expected %v programmed, got %v", want, programmed)
+	}
+}
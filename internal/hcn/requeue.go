@@ -0,0 +1,60 @@
+//go:build windows
+
+package hcn
+
+// RequeueRequest asks the controller owning a policy to reconcile it again,
+// because the enforcement layer found its rules out of date or unprogrammable
+type RequeueRequest struct {
+	PolicyKey PolicyKey
+
+	// Reason says why the policy is requeued, for logs
+	Reason string
+}
+
+const (
+	// RequeueSyncFailed means a periodic reconcile could not program the policy
+	RequeueSyncFailed = "sync-failed"
+
+	// RequeueDrift means the policy's rules had gone missing from an endpoint
+	RequeueDrift = "drift"
+
+	// RequeueEndpointRecreated means an endpoint the policy was being programmed
+	// on was recreated, possibly with a different IP
+	RequeueEndpointRecreated = "endpoint-recreated"
+
+	// RequeuePriorityCollision means an out-of-band ACL took the priority of
+	// one of the policy's rules on an endpoint
+	RequeuePriorityCollision = "priority-collision"
+)
+
+// EnableRequeues makes the Manager send a RequeueRequest for NetworkPolicy keys
+// it cannot keep converged on its own, and returns the channel they arrive on.
+// Requests are dropped when buffer requests are already waiting. It must be
+// called before the Manager starts reconciling.
+func (m *Manager) EnableRequeues(buffer int) <-chan RequeueRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.requeues == nil {
+		m.requeues = make(chan RequeueRequest, buffer)
+	}
+	return m.requeues
+}
+
+// requestRequeue asks the owner of policyKey to reconcile it again. Only keys
+// of the NetworkPolicy store are requeued; other providers have no controller.
+func (m *Manager) requestRequeue(policyKey, reason string) {
+	m.mu.RLock()
+	requeues := m.requeues
+	m.mu.RUnlock()
+	if requeues == nil {
+		return
+	}
+	if _, desired := m.desired.Get(policyKey); !desired {
+		return
+	}
+	select {
+	case requeues <- RequeueRequest{PolicyKey: PolicyKey(policyKey), Reason: reason}:
+	default:
+		m.logger.V(1).Info("Requeue channel full, dropping request", "policyKey", policyKey, "reason", reason)
+	}
+}
@@ -0,0 +1,60 @@
+//go:build windows
+
+package hcn
+
+import (
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+func TestRequeues_Drift(t *testing.T) {
+	client := NewFakeClient(2)
+	manager := NewManager(client, logr.Discard())
+	requeues := manager.EnableRequeues(4)
+	manager.RegisterProvider(NewStaticProvider([]StaticRuleSet{{Name: "node", Rules: benchmarkRules(1)}}))
+	if err := manager.ApplyACLRules("default/test", benchmarkRules(2)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	// HNS loses every rule on the first endpoint behind the agent's back
+	endpoints, _ := client.ListEndpoints()
+	request := hcn.PolicyEndpointRequest{Policies: endpoints[0].Policies}
+	if err := client.RemoveEndpointPolicy(&endpoints[0], hcn.RequestTypeRemove, request); err != nil {
+		t.Fatalf("RemoveEndpointPolicy failed: %v", err)
+	}
+	if err := manager.RepairDrift(); err != nil {
+		t.Fatalf("RepairDrift failed: %v", err)
+	}
+
+	// Only the NetworkPolicy key has a controller to requeue it
+	select {
+	case got := <-requeues:
+		if got.PolicyKey != "default/test" || got.Reason != RequeueDrift {
+			t.Errorf("Expected a drift requeue of default/test, got %+v", got)
+		}
+	default:
+		t.Fatal("Expected a requeue request after drift")
+	}
+	select {
+	case got := <-requeues:
+		t.Errorf("Expected a single requeue request, got %+v", got)
+	default:
+	}
+}
+
+func TestRequeues_DisabledAndFull(t *testing.T) {
+	manager := NewManager(NewFakeClient(1), logr.Discard())
+	manager.desired.Set("default/test", nil)
+
+	// Without EnableRequeues requests are dropped
+	manager.requestRequeue("default/test", RequeueSyncFailed)
+
+	requeues := manager.EnableRequeues(1)
+	manager.requestRequeue("default/test", RequeueSyncFailed)
+	manager.requestRequeue("default/test", RequeueSyncFailed)
+	if len(requeues) != 1 {
+		t.Errorf("Expected the buffer to hold 1 request, got %d", len(requeues))
+	}
+}
@@ -0,0 +1,141 @@
+//go:build windows
+
+package hcn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// ErrPolicyNotFound is returned when a policy key is neither desired nor tracked
+var ErrPolicyNotFound = errors.New("policy not found")
+
+// ForceResyncEndpoint re-programs every controller-owned policy on a single
+// endpoint from scratch: tracked policies are removed (best effort, they may
+// already be gone) and the endpoint's desired ACL table is applied again.
+// An endpoint pinned to a restored backup or suspended after repeated failures
+// returns to normal syncs.
+func (m *Manager) ForceResyncEndpoint(endpointID string) error {
+	m.logger.Info("Force resyncing endpoint", "endpointID", endpointID)
+
+	endpoint, err := m.client.GetEndpointByID(endpointID)
+	if err != nil {
+		return fmt.Errorf("failed to get endpoint %s: %w", endpointID, err)
+	}
+	m.unpin(endpointID)
+	m.backoff.success(endpointID)
+	m.backupEndpoint(endpointID, "resync")
+
+	desired := m.desiredRulesFor(*endpoint)
+	keys := make(map[string]bool, len(desired))
+	for key := range desired {
+		keys[key] = true
+	}
+
+	// Drop what we believe is programmed; drift means it may not be there
+	for _, key := range m.trackedKeys() {
+		keys[key] = true
+		unlock := m.policyLocks.lock(key)
+		ruleSets, _ := m.trackedRuleSets(key)
+		for _, ruleSet := range ruleSets {
+			if ruleSet.EndpointID != endpointID || len(ruleSet.Policies) == 0 {
+				continue
+			}
+			request := hcn.PolicyEndpointRequest{Policies: ruleSet.Policies}
+			if err := m.client.RemoveEndpointPolicy(endpoint, hcn.RequestTypeRemove, request); err != nil {
+				m.logger.V(1).Info("Ignoring failure to remove tracked policies during resync",
+					"endpointID", endpointID,
+					"policyKey", key,
+					"error", err.Error())
+			} else {
+				m.churn.record(key, endpointID, 0, len(ruleSet.Policies))
+			}
+		}
+		m.setEndpointTracking(key, endpointID, nil, nil)
+		unlock()
+	}
+
+	var syncErrors []error
+	for key := range keys {
+		policies, err := m.buildPolicies(desired[key])
+		if err != nil {
+			return fmt.Errorf("failed to build HCN policies for %s: %w", key, err)
+		}
+		unlock := m.policyLocks.lock(key)
+		programmed, err := m.reconcileEndpointPolicy(key, endpoint, nil, policies)
+		m.churn.record(key, endpointID, len(programmed), 0)
+		programmedRules := desired[key]
+		if err != nil {
+			programmedRules = m.rulesFor(programmed, desired[key])
+		}
+		m.setEndpointTracking(key, endpointID, programmed, programmedRules)
+		unlock()
+		if err != nil {
+			m.recordHNSError("apply", err)
+			syncErrors = append(syncErrors, fmt.Errorf("policy %s: %w", key, err))
+		}
+	}
+
+	if len(syncErrors) > 0 {
+		return fmt.Errorf("failed to resync %d/%d policies on endpoint %s: %w",
+			len(syncErrors), len(keys), endpointID, errors.Join(syncErrors...))
+	}
+
+	m.logger.Info("Successfully resynced endpoint", "endpointID", endpointID, "policyCount", len(keys))
+	return nil
+}
+
+// ForceResyncPolicy re-programs a single policy from scratch on every endpoint:
+// its tracked policies are removed and its desired rules applied again
+func (m *Manager) ForceResyncPolicy(policyKey PolicyKey) error {
+	key := string(policyKey)
+	m.logger.Info("Force resyncing policy", "policyKey", policyKey)
+
+	endpoints, err := m.listEndpoints()
+	if err != nil {
+		return fmt.Errorf("failed to list HCN endpoints: %w", err)
+	}
+
+	_, tracked := m.trackedRuleSets(key)
+	_, desired := m.desired.Get(key)
+	for i := 0; !desired && i < len(endpoints); i++ {
+		_, desired = m.desiredRulesFor(endpoints[i])[key]
+	}
+	if !tracked && !desired {
+		return fmt.Errorf("%w: %s", ErrPolicyNotFound, key)
+	}
+
+	if err := m.removeTracked(key); err != nil {
+		m.logger.V(1).Info("Ignoring failure to remove tracked policies during resync",
+			"policyKey", policyKey,
+			"error", err.Error())
+	}
+	if !desired {
+		return nil
+	}
+	return m.syncPolicy(context.Background(), key, endpoints)
+}
+
+// setEndpointTracking replaces the policies tracked for one endpoint under policyKey.
+// The tracked slice is rebuilt rather than modified since callers may hold it.
+func (m *Manager) setEndpointTracking(policyKey, endpointID string, programmed []hcn.EndpointPolicy, rules []ACLRule) {
+	defer m.savePriorities()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous := m.appliedPolicies[policyKey]
+	ruleSets := make([]RuleSet, 0, len(previous)+1)
+	for _, ruleSet := range previous {
+		if ruleSet.EndpointID != endpointID {
+			ruleSets = append(ruleSets, ruleSet)
+		}
+	}
+	if len(programmed) > 0 {
+		ruleSets = append(ruleSets, RuleSet{EndpointID: endpointID, Policies: programmed, Rules: rules})
+	}
+	m.recordHistoryLocked(policyKey, previous, ruleSets)
+	m.appliedPolicies[policyKey] = ruleSets
+}
@@ -0,0 +1,70 @@
+//go:build windows
+
+package hcn
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+func TestForceResyncEndpoint(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{
+		{Id: "ep-1", Name: "endpoint-1"},
+		{Id: "ep-2", Name: "endpoint-2"},
+	}
+	manager := NewManager(mockClient, logr.Discard())
+
+	rules := []ACLRule{{Name: "allow-http", Action: hcn.ActionTypeAllow, Direction: hcn.DirectionTypeIn, Protocol: "6", LocalPorts: "80", Priority: 100}}
+	if err := manager.ApplyACLRules("default/allow-http", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	// Simulate drift: the endpoint lost its policies behind our back
+	mockClient.appliedPolicies["ep-1"] = nil
+
+	if err := manager.ForceResyncEndpoint("ep-1"); err != nil {
+		t.Fatalf("ForceResyncEndpoint failed: %v", err)
+	}
+
+	if len(mockClient.removedPolicies["ep-1"]) != 1 {
+		t.Errorf("Expected tracked policy to be removed from ep-1, got %d", len(mockClient.removedPolicies["ep-1"]))
+	}
+	if len(mockClient.appliedPolicies["ep-1"]) != 1 {
+		t.Errorf("Expected policy to be reapplied to ep-1, got %d", len(mockClient.appliedPolicies["ep-1"]))
+	}
+	if len(mockClient.removedPolicies["ep-2"]) != 0 {
+		t.Error("Expected ep-2 to be left untouched")
+	}
+
+	ruleSets, _ := manager.GetAppliedPolicies("default/allow-http")
+	if len(ruleSets) != 2 {
+		t.Errorf("Expected tracking for both endpoints, got %d", len(ruleSets))
+	}
+}
+
+func TestForceResyncPolicy(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{{Id: "ep-1", Name: "endpoint-1"}}
+	manager := NewManager(mockClient, logr.Discard())
+
+	rules := []ACLRule{{Name: "allow-http", Action: hcn.ActionTypeAllow, Direction: hcn.DirectionTypeIn, Protocol: "6", LocalPorts: "80", Priority: 100}}
+	if err := manager.ApplyACLRules("default/allow-http", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	if err := manager.ForceResyncPolicy("default/allow-http"); err != nil {
+		t.Fatalf("ForceResyncPolicy failed: %v", err)
+	}
+	if len(mockClient.removedPolicies["ep-1"]) != 1 || len(mockClient.appliedPolicies["ep-1"]) != 2 {
+		t.Errorf("Expected policy to be removed and reapplied, removed=%d applied=%d",
+			len(mockClient.removedPolicies["ep-1"]), len(mockClient.appliedPolicies["ep-1"]))
+	}
+
+	if err := manager.ForceResyncPolicy("default/unknown"); !errors.Is(err, ErrPolicyNotFound) {
+		t.Errorf("Expected ErrPolicyNotFound for unknown policy, got %v", err)
+	}
+}
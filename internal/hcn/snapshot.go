@@ -0,0 +1,93 @@
+//go:build windows
+
+package hcn
+
+import (
+	"maps"
+	"sort"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// Snapshot is a read-consistent, immutable view of the Manager's tracking
+// state taken under a single lock. Reads return deep copies, so callers may
+// modify what they get without affecting the snapshot or the Manager.
+type Snapshot struct {
+	policies   map[string][]RuleSet
+	syncErrors map[string]string
+}
+
+// NewSnapshot creates a snapshot from tracking state, for alternative backends
+// and test doubles. The maps are copied; the rule sets must not be modified afterwards.
+func NewSnapshot(policies map[string][]RuleSet, syncErrors map[string]string) Snapshot {
+	return Snapshot{policies: maps.Clone(policies), syncErrors: maps.Clone(syncErrors)}
+}
+
+// Snapshot returns a read-consistent view of all tracked policies. Tracked rule
+// sets are replaced rather than modified, so taking a snapshot only copies the
+// maps; the copying of rule sets is deferred until they are read.
+func (m *Manager) Snapshot() Snapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return NewSnapshot(m.appliedPolicies, m.syncErrors)
+}
+
+// Keys returns the tracked policy keys, sorted
+func (s Snapshot) Keys() []string {
+	keys := make([]string, 0, len(s.policies))
+	for key := range s.policies {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GetAppliedPolicies returns a deep copy of the rule sets tracked for policyKey
+func (s Snapshot) GetAppliedPolicies(policyKey string) ([]RuleSet, bool) {
+	ruleSets, exists := s.policies[policyKey]
+	return copyRuleSets(ruleSets), exists
+}
+
+// DeepCopy returns a copy of the rule set that shares no memory with it
+func (r RuleSet) DeepCopy() RuleSet {
+	out := RuleSet{EndpointID: r.EndpointID}
+	if r.Policies != nil {
+		out.Policies = make([]hcn.EndpointPolicy, len(r.Policies))
+		for i, policy := range r.Policies {
+			policy.Settings = append(policy.Settings[:0:0], policy.Settings...)
+			out.Policies[i] = policy
+		}
+	}
+	if r.Rules != nil {
+		out.Rules = make([]ACLRule, len(r.Rules))
+		for i, rule := range r.Rules {
+			out.Rules[i] = rule.DeepCopy()
+		}
+	}
+	return out
+}
+
+// DeepCopy returns a copy of the rule that shares no memory with it
+func (r ACLRule) DeepCopy() ACLRule {
+	r.Labels = maps.Clone(r.Labels)
+	if r.Packed != nil {
+		packed := make([]ACLRule, len(r.Packed))
+		for i, rule := range r.Packed {
+			packed[i] = rule.DeepCopy()
+		}
+		r.Packed = packed
+	}
+	return r
+}
+
+// copyRuleSets deep copies a list of rule sets
+func copyRuleSets(ruleSets []RuleSet) []RuleSet {
+	if ruleSets == nil {
+		return nil
+	}
+	out := make([]RuleSet, len(ruleSets))
+	for i, ruleSet := range ruleSets {
+		out[i] = ruleSet.DeepCopy()
+	}
+	return out
+}
@@ -0,0 +1,70 @@
+//go:build windows
+
+package hcn
+
+import (
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/go-logr/logr"
+)
+
+func TestGetAppliedPolicies_ReturnsCopies(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{{Id: "ep-1"}}
+
+	manager := NewManager(mockClient, logr.Discard())
+	rules := benchmarkRules(1)
+	rules[0].Labels = map[string]string{"team": "payments"}
+	if err := manager.ApplyACLRules("default/test-policy", rules); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	ruleSets, _ := manager.GetAppliedPolicies("default/test-policy")
+	ruleSets[0].EndpointID = "changed"
+	ruleSets[0].Policies[0].Settings[0] = 'x'
+	ruleSets[0].Rules[0].Labels["team"] = "changed"
+
+	tracked, _ := manager.GetAppliedPolicies("default/test-policy")
+	if tracked[0].EndpointID != "ep-1" {
+		t.Errorf("Expected tracked endpoint to be unchanged, got %s", tracked[0].EndpointID)
+	}
+	if tracked[0].Policies[0].Settings[0] == 'x' {
+		t.Error("Expected tracked policy settings to be unchanged")
+	}
+	if tracked[0].Rules[0].Labels["team"] != "payments" {
+		t.Errorf("Expected tracked labels to be unchanged, got %v", tracked[0].Rules[0].Labels)
+	}
+}
+
+func TestSnapshot_Consistent(t *testing.T) {
+	mockClient := newMockHCNClient()
+	mockClient.endpoints = []hcn.HostComputeEndpoint{{Id: "ep-1"}, {Id: "ep-2"}}
+
+	manager := NewManager(mockClient, logr.Discard())
+	if err := manager.ApplyACLRules("default/a", benchmarkRules(1)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+
+	snapshot := manager.Snapshot()
+
+	// Later changes are not visible in the snapshot
+	if err := manager.ApplyACLRules("default/b", benchmarkRules(1)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	manager.setEndpointTracking("default/a", "ep-2", nil, nil)
+	if err := manager.RemoveACLRules("default/a"); err != nil {
+		t.Fatalf("RemoveACLRules failed: %v", err)
+	}
+
+	if keys := snapshot.Keys(); len(keys) != 1 || keys[0] != "default/a" {
+		t.Errorf("Expected snapshot keys [default/a], got %v", keys)
+	}
+	ruleSets, exists := snapshot.GetAppliedPolicies("default/a")
+	if !exists || len(ruleSets) != 2 {
+		t.Errorf("Expected default/a on 2 endpoints in the snapshot, got %v", ruleSets)
+	}
+	if _, exists := snapshot.GetAppliedPolicies("default/b"); exists {
+		t.Error("Expected default/b not to be in the snapshot")
+	}
+}
@@ -0,0 +1,99 @@
+//go:build windows
+
+package hcn
+
+import (
+	"bytes"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// StateEncryption selects how the priority assignments file and the endpoint
+// backups are protected at rest. The rules they record name the addresses,
+// ports and peers of every policy on the node, which reveals the cluster's
+// topology to whoever reads them.
+type StateEncryption string
+
+const (
+	// StateEncryptionNone writes the file as plain JSON
+	StateEncryptionNone StateEncryption = "none"
+
+	// StateEncryptionUser encrypts the file with DPAPI for the account the
+	// agent runs as; no other account can read it
+	StateEncryptionUser StateEncryption = "user"
+
+	// StateEncryptionMachine encrypts the file with DPAPI for the node; any
+	// account on it can read the file, but a copy taken elsewhere is useless
+	StateEncryptionMachine StateEncryption = "machine"
+)
+
+// ParseStateEncryption parses a --state-encryption value
+func ParseStateEncryption(value string) (StateEncryption, error) {
+	switch mode := StateEncryption(value); mode {
+	case StateEncryptionNone, StateEncryptionUser, StateEncryptionMachine:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid state encryption %q: must be none, user or machine", value)
+	}
+}
+
+// dpapiHeader starts an encrypted state file, ahead of the DPAPI blob
+var dpapiHeader = []byte("networkpolicy-agent/dpapi/v1\n")
+
+// dpapiEntropy is mixed into every blob so other DPAPI users of the account
+// or machine cannot decrypt the files by accident. It predates the encryption
+// of backups and is kept so existing files stay readable.
+var dpapiEntropy = []byte("networkpolicy-agent priority assignments")
+
+// isEncryptedState reports whether data is an encrypted state file
+func isEncryptedState(data []byte) bool {
+	return bytes.HasPrefix(data, dpapiHeader)
+}
+
+// protectState encrypts plaintext for mode; StateEncryptionNone returns it as is
+func protectState(plaintext []byte, mode StateEncryption) ([]byte, error) {
+	if mode == StateEncryptionNone || mode == "" {
+		return plaintext, nil
+	}
+	flags := uint32(windows.CRYPTPROTECT_UI_FORBIDDEN)
+	if mode == StateEncryptionMachine {
+		flags |= windows.CRYPTPROTECT_LOCAL_MACHINE
+	}
+	var out windows.DataBlob
+	if err := windows.CryptProtectData(dataBlob(plaintext), nil, dataBlob(dpapiEntropy), 0, nil, flags, &out); err != nil {
+		return nil, fmt.Errorf("failed to encrypt state: %w", err)
+	}
+	return append(append([]byte(nil), dpapiHeader...), takeBlob(&out)...), nil
+}
+
+// unprotectState decrypts an encrypted state file; plain files are returned as is
+func unprotectState(data []byte) ([]byte, error) {
+	if !isEncryptedState(data) {
+		return data, nil
+	}
+	var out windows.DataBlob
+	in := dataBlob(data[len(dpapiHeader):])
+	if err := windows.CryptUnprotectData(in, nil, dataBlob(dpapiEntropy), 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, fmt.Errorf("failed to decrypt state: %w", err)
+	}
+	return takeBlob(&out), nil
+}
+
+// dataBlob wraps data for a DPAPI call
+func dataBlob(data []byte) *windows.DataBlob {
+	if len(data) == 0 {
+		return &windows.DataBlob{}
+	}
+	return &windows.DataBlob{Size: uint32(len(data)), Data: &data[0]}
+}
+
+// takeBlob copies a blob returned by DPAPI and frees it
+func takeBlob(blob *windows.DataBlob) []byte {
+	if blob.Data == nil {
+		return nil
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(blob.Data)))
+	return append([]byte(nil), unsafe.Slice(blob.Data, blob.Size)...)
+}
@@ -0,0 +1,121 @@
+//go:build windows
+
+package hcn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// UnclaimedPolicyKey tracks, after a stateless warm start, the ACLs of a
+// previous run that no policy has claimed yet. It is never desired, so the
+// first full Reconcile removes what is left of it.
+const UnclaimedPolicyKey = "stateless/unclaimed"
+
+// WarmStartFromEndpoints reconstructs what a previous run programmed from the
+// endpoints alone, for hosts where no state file may be written. HNS ACLs
+// carry no owner field, so the ACLs with a priority in one of the owned
+// ranges and outside the reserved ones are taken as the agent's own. They
+// are tracked under UnclaimedPolicyKey; a policy syncing an endpoint takes
+// over its ACLs with the payloads it desires instead of adding them again,
+// and the first full Reconcile removes the rest. It must be called before the
+// Manager starts reconciling and excludes SetPriorityStore.
+func (m *Manager) WarmStartFromEndpoints(owned, reserved []PriorityRange) error {
+	if m.priorities != nil {
+		return errors.New("stateless warm start cannot be combined with a priority store")
+	}
+	endpoints, err := m.listEndpoints()
+	if err != nil {
+		return fmt.Errorf("failed to list HCN endpoints: %w", err)
+	}
+
+	var ruleSets []RuleSet
+	var adopted int
+	for _, endpoint := range endpoints {
+		var policies []hcn.EndpointPolicy
+		for _, policy := range endpoint.Policies {
+			if policy.Type != hcn.ACL {
+				continue
+			}
+			var setting hcn.AclPolicySetting
+			if err := json.Unmarshal(policy.Settings, &setting); err != nil {
+				continue
+			}
+			if !inRanges(setting.Priority, owned) || inRanges(setting.Priority, reserved) {
+				continue
+			}
+			policies = append(policies, policy)
+		}
+		if len(policies) == 0 {
+			continue
+		}
+		ruleSets = append(ruleSets, RuleSet{
+			EndpointID: endpoint.Id,
+			Policies:   policies,
+			Rules:      m.rulesFor(policies),
+		})
+		adopted += len(policies)
+	}
+
+	m.mu.Lock()
+	if len(ruleSets) > 0 {
+		m.appliedPolicies[UnclaimedPolicyKey] = ruleSets
+	}
+	m.stateless = true
+	m.mu.Unlock()
+
+	m.logger.Info("Reconstructed programmed rules from endpoints",
+		"endpointCount", len(ruleSets),
+		"ruleCount", adopted)
+	return nil
+}
+
+// claimUnclaimed moves the unclaimed ACLs of an endpoint with a payload in
+// desired to the rules programmed for policyKey, and returns them with prior
+func (m *Manager) claimUnclaimed(policyKey, endpointID string, prior, desired []hcn.EndpointPolicy) []hcn.EndpointPolicy {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.stateless || policyKey == UnclaimedPolicyKey {
+		return prior
+	}
+	unclaimed := m.appliedPolicies[UnclaimedPolicyKey]
+	for i, ruleSet := range unclaimed {
+		if ruleSet.EndpointID != endpointID {
+			continue
+		}
+		_, toAdd := diffPolicies(prior, desired)
+		wanted := make(map[string]bool, len(toAdd))
+		for _, policy := range toAdd {
+			wanted[policyID(policy)] = true
+		}
+		var claimed, rest []hcn.EndpointPolicy
+		for _, policy := range ruleSet.Policies {
+			if wanted[policyID(policy)] {
+				claimed = append(claimed, policy)
+			} else {
+				rest = append(rest, policy)
+			}
+		}
+		if len(claimed) == 0 {
+			return prior
+		}
+
+		// Copy on write: the tracked slices may be shared with snapshots
+		updated := make([]RuleSet, 0, len(unclaimed))
+		updated = append(updated, unclaimed[:i]...)
+		if len(rest) > 0 {
+			updated = append(updated, RuleSet{EndpointID: endpointID, Policies: rest, Rules: m.rulesFor(rest, ruleSet.Rules)})
+		}
+		updated = append(updated, unclaimed[i+1:]...)
+		if len(updated) > 0 {
+			m.appliedPolicies[UnclaimedPolicyKey] = updated
+		} else {
+			delete(m.appliedPolicies, UnclaimedPolicyKey)
+		}
+		return append(append([]hcn.EndpointPolicy(nil), prior...), claimed...)
+	}
+	return prior
+}
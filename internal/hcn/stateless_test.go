@@ -0,0 +1,57 @@
+//go:build windows
+
+package hcn
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestWarmStartFromEndpoints(t *testing.T) {
+	client := &countingClient{FakeClient: NewFakeClient(2)}
+	owned := []PriorityRange{{Start: 100, End: MaxPriority}}
+
+	first := NewManager(client, logr.Discard())
+	if err := first.ApplyACLRules("default/web", benchmarkRules(3)); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	stale := benchmarkRules(1)
+	stale[0].Priority = 500
+	if err := first.ApplyACLRules("default/deleted", stale); err != nil {
+		t.Fatalf("ApplyACLRules failed: %v", err)
+	}
+	// A rule of another agent below the owned band
+	addOutOfBandACL(t, client.FakeClient, "fake-endpoint-0", 10)
+
+	// The restarted agent only knows what the endpoints carry
+	client.applies, client.removes = 0, 0
+	second := NewManager(client, logr.Discard())
+	if err := second.WarmStartFromEndpoints(owned, nil); err != nil {
+		t.Fatalf("WarmStartFromEndpoints failed: %v", err)
+	}
+	if ruleSets, _ := second.GetAppliedPolicies(UnclaimedPolicyKey); len(ruleSets) != 2 || len(ruleSets[0].Policies) != 4 {
+		t.Fatalf("Expected 4 unclaimed ACLs on each endpoint, got %+v", ruleSets)
+	}
+	if err := second.ApplyACLRules("default/web", benchmarkRules(3)); err != nil {
+		t.Fatalf("ApplyACLRules after restart failed: %v", err)
+	}
+	if client.applies != 0 || client.removes != 0 {
+		t.Errorf("Expected the rules taken over, got %d adds and %d removes", client.applies, client.removes)
+	}
+
+	// The first full reconcile removes what no policy took over
+	if err := second.Reconcile(); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if _, tracked := second.GetAppliedPolicies(UnclaimedPolicyKey); tracked {
+		t.Error("Expected no unclaimed ACLs left")
+	}
+	for i, want := range []int{4, 3} {
+		endpoint, _ := client.GetEndpointByID(fmt.Sprintf("fake-endpoint-%d", i))
+		if len(endpoint.Policies) != want {
+			t.Errorf("Expected %d policies on endpoint %d, got %d", want, i, len(endpoint.Policies))
+		}
+	}
+}
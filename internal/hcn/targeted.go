@@ -0,0 +1,107 @@
+//go:build windows
+
+package hcn
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+// targetedRules are rules that apply only to endpoints owning one of the target IPs
+type targetedRules struct {
+	targets map[string]bool
+	rules   []ACLRule
+}
+
+// TargetedProvider serves rules scoped to a subset of endpoints, selected by
+// IP address (e.g. the pods of one namespace)
+type TargetedProvider struct {
+	name string
+
+	mu sync.RWMutex
+
+	// entries maps policyKey -> rules and the endpoint IPs they target
+	entries map[string]targetedRules
+}
+
+// NewTargetedProvider creates an empty provider identified by name in logs
+func NewTargetedProvider(name string) *TargetedProvider {
+	return &TargetedProvider{
+		name:    name,
+		entries: make(map[string]targetedRules),
+	}
+}
+
+// Set records the rules for policyKey and the endpoint IPs they apply to,
+// replacing any previous entry
+func (p *TargetedProvider) Set(policyKey PolicyKey, targetIPs []string, rules []ACLRule) {
+	entry := targetedRules{
+		targets: make(map[string]bool, len(targetIPs)),
+		rules:   make([]ACLRule, len(rules)),
+	}
+	for _, ip := range targetIPs {
+		entry.targets[ip] = true
+	}
+	copy(entry.rules, rules)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries[string(policyKey)] = entry
+}
+
+// Delete removes the rules for policyKey
+func (p *TargetedProvider) Delete(policyKey PolicyKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.entries, string(policyKey))
+}
+
+// Keys returns all policy keys with rules, sorted
+func (p *TargetedProvider) Keys() []PolicyKey {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	keys := make([]string, 0, len(p.entries))
+	for key := range p.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return typedKeys(keys)
+}
+
+// Name implements RuleProvider
+func (p *TargetedProvider) Name() string {
+	return p.name
+}
+
+// DesiredRulesFor implements RuleProvider
+func (p *TargetedProvider) DesiredRulesFor(endpoint hcn.HostComputeEndpoint) map[string][]ACLRule {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var table map[string][]ACLRule
+	for key, entry := range p.entries {
+		if !entry.matches(endpoint) {
+			continue
+		}
+		if table == nil {
+			table = make(map[string][]ACLRule)
+		}
+		rules := make([]ACLRule, len(entry.rules))
+		copy(rules, entry.rules)
+		table[key] = rules
+	}
+	return table
+}
+
+// matches reports whether the endpoint owns one of the target IPs
+func (e targetedRules) matches(endpoint hcn.HostComputeEndpoint) bool {
+	for _, ipConfig := range endpoint.IpConfigurations {
+		if e.targets[ipConfig.IpAddress] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+//go:build windows
+
+package hcn
+
+import (
+	"testing"
+
+	"github.com/Microsoft/hcsshim/hcn"
+)
+
+func TestTargetedProvider(t *testing.T) {
+	provider := NewTargetedProvider("namespacedefault")
+	provider.Set("namespacedefault/team-a/baseline", []string{"10.0.0.1"}, []ACLRule{
+		{Name: "deny-in", Action: hcn.ActionTypeBlock, Direction: hcn.DirectionTypeIn, Priority: 65499},
+	})
+
+	inNamespace := hcn.HostComputeEndpoint{
+		Id:               "ep-1",
+		IpConfigurations: []hcn.IpConfig{{IpAddress: "10.0.0.1"}},
+	}
+	outside := hcn.HostComputeEndpoint{
+		Id:               "ep-2",
+		IpConfigurations: []hcn.IpConfig{{IpAddress: "10.0.0.2"}},
+	}
+
+	if rules := provider.DesiredRulesFor(inNamespace)["namespacedefault/team-a/baseline"]; len(rules) != 1 {
+		t.Errorf("Expected 1 rule on targeted endpoint, got %d", len(rules))
+	}
+	if table := provider.DesiredRulesFor(outside); len(table) != 0 {
+		t.Errorf("Expected no rules on untargeted endpoint, got %v", table)
+	}
+
+	provider.Delete("namespacedefault/team-a/baseline")
+	if table := provider.DesiredRulesFor(inNamespace); len(table) != 0 {
+		t.Errorf("Expected no rules after Delete, got %v", table)
+	}
+}
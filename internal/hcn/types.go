@@ -3,6 +3,10 @@
 package hcn
 
 import (
+	"context"
+	"maps"
+	"slices"
+
 	"github.com/Microsoft/hcsshim/hcn"
 )
 
@@ -20,17 +24,51 @@ type ACLRule struct {
 	// Protocol is the IP protocol number as a string (e.g., "6" for TCP, "17" for UDP)
 	Protocol string
 
-	// LocalPorts specifies the local port(s) for this rule (comma-separated)
+	// LocalPorts specifies the local port(s) for this rule: comma-separated
+	// ports or "start-end" ranges, e.g. "80,8000-9000"
 	LocalPorts string
 
-	// RemotePorts specifies the remote port(s) for this rule (comma-separated)
+	// RemotePorts specifies the remote port(s) for this rule, in the form of LocalPorts
 	RemotePorts string
 
 	// RemoteAddresses specifies the remote IP address(es) or CIDR blocks
 	RemoteAddresses string
 
+	// LocalAddresses restricts the rule to some of the endpoint's own IP
+	// addresses; empty matches all of them. It needs ACLCapabilities.LocalAddresses.
+	LocalAddresses string
+
+	// RuleType selects where HNS enforces the rule; empty uses HNS's default
+	// (Switch). It is only sent with ACLCapabilities.RuleType.
+	RuleType hcn.RuleType
+
 	// Priority determines the order of rule evaluation (lower = higher priority)
 	Priority uint16
+
+	// Labels annotate the rule for auditing (team, ticket, source selector).
+	// They are tracked and shown by fwctl but never sent to HNS.
+	Labels map[string]string
+
+	// Packed are the rules merged into this one by PackRules, in their
+	// original order; empty for rules programmed as generated. Like Labels
+	// they are only tracked, to trace an ACL back to the rules of its policy.
+	Packed []ACLRule
+}
+
+// Equal reports whether two rules are identical, labels and packed rules included
+func (r ACLRule) Equal(other ACLRule) bool {
+	return r.Name == other.Name &&
+		r.Action == other.Action &&
+		r.Direction == other.Direction &&
+		r.Protocol == other.Protocol &&
+		r.LocalPorts == other.LocalPorts &&
+		r.RemotePorts == other.RemotePorts &&
+		r.RemoteAddresses == other.RemoteAddresses &&
+		r.LocalAddresses == other.LocalAddresses &&
+		r.RuleType == other.RuleType &&
+		r.Priority == other.Priority &&
+		maps.Equal(r.Labels, other.Labels) &&
+		slices.EqualFunc(r.Packed, other.Packed, ACLRule.Equal)
 }
 
 // RuleSet tracks HCN policies applied to a specific endpoint
@@ -38,8 +76,13 @@ type RuleSet struct {
 	// EndpointID is the HCN endpoint identifier
 	EndpointID string
 
-	// Policies are the actual HCN policies that were applied (for removal)
+	// Policies are the actual HCN policies that were applied (for removal).
+	// The slice may be shared with other RuleSets and must not be modified.
 	Policies []hcn.EndpointPolicy
+
+	// Rules are the ACL rules Policies were built from, in the same order.
+	// The slice may be shared with other RuleSets and must not be modified.
+	Rules []ACLRule
 }
 
 // HCNClient interface abstracts HCN operations for testing
@@ -57,22 +100,150 @@ type HCNClient interface {
 	RemoveEndpointPolicy(endpoint *hcn.HostComputeEndpoint, requestType hcn.RequestType, request hcn.PolicyEndpointRequest) error
 }
 
+// HCNManager is the ACL programming surface consumed by controllers.
+// Manager is the production implementation; alternative backends and test
+// doubles implement it to stand in for HCN.
+type HCNManager interface {
+	// ApplyACLRules declares the rules for policyKey and programs them on endpoints
+	ApplyACLRules(policyKey PolicyKey, rules []ACLRule) error
+
+	// RemoveACLRules removes all rules programmed for policyKey
+	RemoveACLRules(policyKey PolicyKey) error
+
+	// GetAppliedPolicies returns a copy of the rule sets tracked for policyKey
+	GetAppliedPolicies(policyKey PolicyKey) ([]RuleSet, bool)
+
+	// ListTrackedPolicies returns all tracked policy keys
+	ListTrackedPolicies() []PolicyKey
+
+	// Reconcile converges all endpoints toward the rules of every registered provider
+	Reconcile() error
+}
+
+// ContextApplier is implemented by HCNManagers whose applies can be bounded by
+// a context and resumed by the next apply after an interruption
+type ContextApplier interface {
+	// ApplyACLRulesContext is ApplyACLRules stopping early once ctx is done
+	ApplyACLRulesContext(ctx context.Context, policyKey PolicyKey, rules []ACLRule) error
+}
+
+// EndpointApplier is implemented by HCNManagers that can limit a policy's rules
+// to an explicit list of endpoints, for callers that know which endpoints a
+// policy selects (a CNI hook, a pod watcher, an operator CLI)
+type EndpointApplier interface {
+	// ApplyACLRulesToEndpoints is ApplyACLRulesContext limited to endpointIDs
+	ApplyACLRulesToEndpoints(ctx context.Context, policyKey PolicyKey, rules []ACLRule, endpointIDs []string) error
+}
+
+// AddressApplier is implemented by HCNManagers that can limit a policy's rules
+// to the endpoints owning a list of IPs, for callers that know the pods a
+// policy selects but not their endpoints
+type AddressApplier interface {
+	// ApplyACLRulesToAddresses is ApplyACLRulesContext limited to the endpoints of addresses
+	ApplyACLRulesToAddresses(ctx context.Context, policyKey PolicyKey, rules []ACLRule, addresses []string) error
+}
+
+// EndpointConverger is implemented by HCNManagers that can report whether the
+// endpoint of a pod carries its rules, for holding pods back until it does
+type EndpointConverger interface {
+	// ConvergeEndpoint programs the endpoint owning ip and reports what is still pending on it
+	ConvergeEndpoint(ctx context.Context, ip string, policyKeys []PolicyKey) (EndpointConvergence, error)
+}
+
+// EnforcementPauser is implemented by HCNManagers that can lift their rules
+// from the endpoint of a single pod, for debugging it
+type EnforcementPauser interface {
+	// SetEnforcementPaused pauses or resumes enforcement on the endpoint owning ip
+	SetEnforcementPaused(ctx context.Context, ip, pod string, paused bool) error
+}
+
+// EndpointExempter is implemented by HCNManagers that can exempt the endpoint
+// of a single pod from every rule, for system pods the node depends on
+type EndpointExempter interface {
+	// SetEndpointExempt exempts the endpoint owning ip from every rule, or lifts the exemption
+	SetEndpointExempt(ctx context.Context, ip, pod string, exempt bool) error
+}
+
+// TableReader is implemented by HCNManagers that expose the complete ACL table
+// desired on each endpoint, for checking what an endpoint enforces
+type TableReader interface {
+	// GetAppliedPolicies returns a copy of the rule sets tracked for policyKey
+	GetAppliedPolicies(policyKey PolicyKey) ([]RuleSet, bool)
+
+	// EndpointByID returns the endpoint with the given ID as of the last endpoint listing
+	EndpointByID(id string) (hcn.HostComputeEndpoint, bool)
+
+	// DesiredTable returns the rules of every provider desired on endpoint, by policy key
+	DesiredTable(endpoint hcn.HostComputeEndpoint) map[string][]ACLRule
+}
+
+// Manager must satisfy HCNManager, ContextApplier, EndpointApplier, AddressApplier,
+// EndpointConverger, EnforcementPauser, EndpointExempter, NetworkApplier and TableReader
+var (
+	_ HCNManager        = &Manager{}
+	_ ContextApplier    = &Manager{}
+	_ EndpointApplier   = &Manager{}
+	_ AddressApplier    = &Manager{}
+	_ EndpointConverger = &Manager{}
+	_ EnforcementPauser = &Manager{}
+	_ EndpointExempter  = &Manager{}
+	_ NetworkApplier    = &Manager{}
+	_ TableReader       = &Manager{}
+)
+
+// ClientOptions configures how the production HCN client discovers endpoints
+type ClientOptions struct {
+	// IncludeNamespaceEndpoints additionally enumerates endpoints attached to HNS
+	// namespaces (network compartments) that the default listing may miss
+	IncludeNamespaceEndpoints bool
+
+	// Namespaces restricts listing to endpoints attached to these HNS namespace IDs.
+	// Empty means endpoints in all namespaces.
+	Namespaces []string
+}
+
 // realHCNClient is the production implementation using actual hcsshim calls
-type realHCNClient struct{}
+type realHCNClient struct {
+	opts ClientOptions
+
+	// hcsshim entry points, replaceable in tests
+	listEndpoints          func() ([]hcn.HostComputeEndpoint, error)
+	listNamespaces         func() ([]hcn.HostComputeNamespace, error)
+	namespaceEndpointIDs   func(namespaceID string) ([]string, error)
+	getEndpointByIDFromHCN func(id string) (*hcn.HostComputeEndpoint, error)
+	getNamespaceByID       func(id string) (*hcn.HostComputeNamespace, error)
+	listNetworks           func() ([]hcn.HostComputeNetwork, error)
+}
 
 // NewHCNClient creates a new HCN client
 func NewHCNClient() HCNClient {
-	return &realHCNClient{}
+	return NewHCNClientWithOptions(ClientOptions{})
+}
+
+// NewHCNClientWithOptions creates a new HCN client with compartment-aware discovery
+func NewHCNClientWithOptions(opts ClientOptions) HCNClient {
+	return &realHCNClient{
+		opts:                   opts,
+		listEndpoints:          hcn.ListEndpoints,
+		listNamespaces:         hcn.ListNamespaces,
+		namespaceEndpointIDs:   hcn.GetNamespaceEndpointIds,
+		getEndpointByIDFromHCN: hcn.GetEndpointByID,
+		getNamespaceByID:       hcn.GetNamespaceByID,
+		listNetworks:           hcn.ListNetworks,
+	}
 }
 
 // ListEndpoints implements HCNClient
 func (c *realHCNClient) ListEndpoints() ([]hcn.HostComputeEndpoint, error) {
-	return hcn.ListEndpoints()
+	if !c.opts.IncludeNamespaceEndpoints && len(c.opts.Namespaces) == 0 {
+		return c.listEndpoints()
+	}
+	return c.listCompartmentEndpoints()
 }
 
 // GetEndpointByID implements HCNClient
 func (c *realHCNClient) GetEndpointByID(id string) (*hcn.HostComputeEndpoint, error) {
-	return hcn.GetEndpointByID(id)
+	return c.getEndpointByIDFromHCN(id)
 }
 
 // ApplyEndpointPolicy implements HCNClient
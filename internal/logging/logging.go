@@ -0,0 +1,114 @@
+//go:build windows
+
+// Package logging builds the zap-backed logr.Logger shared by the agent and
+// its command-line tools, so every binary is configured with the same flags
+package logging
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// timeEncoders are the supported timestamp formats by name
+var timeEncoders = map[string]zapcore.TimeEncoder{
+	"iso8601":     zapcore.ISO8601TimeEncoder,
+	"rfc3339":     zapcore.RFC3339TimeEncoder,
+	"rfc3339nano": zapcore.RFC3339NanoTimeEncoder,
+	"epoch":       zapcore.EpochTimeEncoder,
+	"millis":      zapcore.EpochMillisTimeEncoder,
+	"nanos":       zapcore.EpochNanosTimeEncoder,
+}
+
+// Options configures a logger
+type Options struct {
+	// Level is "debug", "info", "error" or a logr verbosity such as "2", which
+	// also enables V(1) and V(2) messages
+	Level string
+
+	// Encoding is "json" or "console"
+	Encoding string
+
+	// Sampling drops repeated messages beyond the first 100 per second, keeping
+	// every 100th, so a flapping endpoint cannot flood the log
+	Sampling bool
+
+	// TimeFormat is "iso8601", "rfc3339", "rfc3339nano", "epoch", "millis" or "nanos"
+	TimeFormat string
+}
+
+// BindFlags registers the logger flags on fs, with defaults taken from o
+func (o *Options) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Level, "log-level", o.Level,
+		`Log level: "debug", "info", "error" or a verbosity such as 2 (enables V(2) messages).`)
+	fs.StringVar(&o.Encoding, "log-encoding", o.Encoding, `Log encoding: "json" or "console".`)
+	fs.BoolVar(&o.Sampling, "log-sampling", o.Sampling,
+		"Sample repeated log messages beyond the first 100 per second.")
+	fs.StringVar(&o.TimeFormat, "log-timestamp", o.TimeFormat,
+		`Timestamp format: "iso8601", "rfc3339", "rfc3339nano", "epoch", "millis" or "nanos".`)
+}
+
+// New builds a logger from the options
+func New(opts Options) (logr.Logger, error) {
+	level, err := parseLevel(opts.Level)
+	if err != nil {
+		return logr.Logger{}, err
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	timeEncoder, known := timeEncoders[strings.ToLower(opts.TimeFormat)]
+	if !known {
+		return logr.Logger{}, fmt.Errorf("invalid log timestamp format %q", opts.TimeFormat)
+	}
+	encoderConfig.EncodeTime = timeEncoder
+
+	switch opts.Encoding {
+	case "json":
+	case "console":
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+	default:
+		return logr.Logger{}, fmt.Errorf("invalid log encoding %q: must be json or console", opts.Encoding)
+	}
+
+	config := zap.Config{
+		Level:            zap.NewAtomicLevelAt(level),
+		Encoding:         opts.Encoding,
+		EncoderConfig:    encoderConfig,
+		OutputPaths:      []string{"stderr"},
+		ErrorOutputPaths: []string{"stderr"},
+	}
+	if opts.Sampling {
+		config.Sampling = &zap.SamplingConfig{Initial: 100, Thereafter: 100}
+	}
+
+	zapLog, err := config.Build()
+	if err != nil {
+		return logr.Logger{}, fmt.Errorf("failed to build logger: %w", err)
+	}
+	return zapr.NewLogger(zapLog), nil
+}
+
+// parseLevel maps a level name or logr verbosity to a zap level. zapr logs
+// V(n) messages at zap level -n.
+func parseLevel(level string) (zapcore.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return zapcore.DebugLevel, nil
+	case "info":
+		return zapcore.InfoLevel, nil
+	case "error":
+		return zapcore.ErrorLevel, nil
+	}
+
+	verbosity, err := strconv.Atoi(level)
+	if err != nil || verbosity < 0 || verbosity > 127 {
+		return 0, fmt.Errorf("invalid log level %q: must be debug, info, error or a verbosity between 0 and 127", level)
+	}
+	return zapcore.Level(-verbosity), nil
+}
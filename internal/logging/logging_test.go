@@ -0,0 +1,72 @@
+//go:build windows
+
+package logging
+
+import (
+	"flag"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		level   string
+		want    zapcore.Level
+		wantErr bool
+	}{
+		{level: "debug", want: zapcore.DebugLevel},
+		{level: "INFO", want: zapcore.InfoLevel},
+		{level: "error", want: zapcore.ErrorLevel},
+		{level: "2", want: zapcore.Level(-2)},
+		{level: "-1", wantErr: true},
+		{level: "verbose", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			got, err := parseLevel(tt.level)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLevel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseLevel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	valid := Options{Level: "2", Encoding: "json", TimeFormat: "rfc3339"}
+
+	logger, err := New(valid)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if !logger.V(2).Enabled() || logger.V(3).Enabled() {
+		t.Error("Expected verbosity 2 to enable V(2) but not V(3)")
+	}
+
+	for name, opts := range map[string]Options{
+		"encoding":  {Level: "info", Encoding: "text", TimeFormat: "iso8601"},
+		"timestamp": {Level: "info", Encoding: "console", TimeFormat: "unix"},
+		"level":     {Level: "trace", Encoding: "console", TimeFormat: "iso8601"},
+	} {
+		if _, err := New(opts); err == nil {
+			t.Errorf("Expected invalid %s to be rejected", name)
+		}
+	}
+}
+
+func TestBindFlags(t *testing.T) {
+	opts := Options{Level: "info", Encoding: "console", TimeFormat: "iso8601"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	opts.BindFlags(fs)
+
+	if err := fs.Parse([]string{"--log-encoding=json", "--log-sampling"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if opts.Level != "info" || opts.Encoding != "json" || !opts.Sampling || opts.TimeFormat != "iso8601" {
+		t.Errorf("Expected flags over defaults, got %+v", opts)
+	}
+}
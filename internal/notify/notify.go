@@ -0,0 +1,57 @@
+//go:build windows
+
+// Package notify delivers enforcement changes to external systems (SIEM,
+// ChatOps) as JSON events, so they need not scrape the agent's logs
+package notify
+
+import "time"
+
+// EventType says what happened to a policy's rules
+type EventType string
+
+const (
+	// EventApplied means the policy's rules were programmed on the node
+	EventApplied EventType = "applied"
+
+	// EventRemoved means the policy's rules were removed from the node
+	EventRemoved EventType = "removed"
+
+	// EventFailed means the policy's rules could not be programmed or removed
+	EventFailed EventType = "failed"
+)
+
+// Event is the JSON notification of one enforcement change
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+
+	// Node is the node whose endpoints changed
+	Node string `json:"node"`
+
+	// Source names the policy source cluster; empty for the agent's own
+	Source string `json:"source,omitempty"`
+
+	PolicyKey string `json:"policyKey"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+
+	// Generation and ResourceVersion identify the enforced version of the
+	// policy; unset for removals
+	Generation      int64  `json:"generation,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+
+	// ModifiedBy is the field manager that last changed the policy, when known
+	ModifiedBy string `json:"modifiedBy,omitempty"`
+
+	// RuleCount is the number of ACL rules programmed for the policy
+	RuleCount int `json:"ruleCount,omitempty"`
+
+	// Reason and Error describe a failure
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Notifier receives enforcement events. Notify must not block the caller.
+type Notifier interface {
+	Notify(event Event)
+}
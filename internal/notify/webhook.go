@@ -0,0 +1,204 @@
+//go:build windows
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// webhookEvents counts the events handed to the webhook sink by outcome
+var webhookEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "networkpolicy_agent",
+	Subsystem: "notify",
+	Name:      "webhook_events_total",
+	Help:      "Enforcement events sent to the notification webhook, by result (delivered, failed, dropped).",
+}, []string{"result"})
+
+func init() {
+	metrics.Registry.MustRegister(webhookEvents)
+}
+
+// WebhookOptions configures a WebhookSink
+type WebhookOptions struct {
+	// URL receives every event as a JSON POST
+	URL string
+
+	// BearerTokenFile, when set, is read before each request and sent as a
+	// bearer token, so rotated tokens are picked up
+	BearerTokenFile string
+
+	// Timeout bounds each request
+	Timeout time.Duration
+
+	// QueueSize is how many events wait for delivery; newer events are
+	// dropped while the queue is full
+	QueueSize int
+
+	// MaxAttempts is how often an event is sent before it is given up
+	MaxAttempts int
+}
+
+// DefaultWebhookOptions returns the options used for unset fields
+func DefaultWebhookOptions() WebhookOptions {
+	return WebhookOptions{
+		Timeout:     5 * time.Second,
+		QueueSize:   1024,
+		MaxAttempts: 3,
+	}
+}
+
+// WebhookSink posts events to an HTTP endpoint from a background worker, so
+// a slow or unreachable receiver never delays reconciles. It implements
+// manager.Runnable.
+type WebhookSink struct {
+	opts   WebhookOptions
+	client *http.Client
+	events chan Event
+	logger logr.Logger
+
+	// retryDelay is the wait before the second attempt, doubled for each further one
+	retryDelay time.Duration
+}
+
+// NewWebhookSink creates a sink posting to opts.URL
+func NewWebhookSink(opts WebhookOptions, logger logr.Logger) (*WebhookSink, error) {
+	target, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	if target.Scheme != "http" && target.Scheme != "https" || target.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL %q: must be an absolute http or https URL", opts.URL)
+	}
+	defaults := DefaultWebhookOptions()
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaults.Timeout
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaults.QueueSize
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaults.MaxAttempts
+	}
+	return &WebhookSink{
+		opts:       opts,
+		client:     &http.Client{Timeout: opts.Timeout},
+		events:     make(chan Event, opts.QueueSize),
+		logger:     logger,
+		retryDelay: time.Second,
+	}, nil
+}
+
+// Notify queues event for delivery, dropping it when the queue is full
+func (s *WebhookSink) Notify(event Event) {
+	select {
+	case s.events <- event:
+	default:
+		webhookEvents.WithLabelValues("dropped").Inc()
+		s.logger.Info("Notification queue full, dropping event",
+			"type", event.Type,
+			"policyKey", event.PolicyKey)
+	}
+}
+
+// Start delivers queued events until ctx is done
+func (s *WebhookSink) Start(ctx context.Context) error {
+	s.logger.Info("Sending enforcement events to webhook", "url", s.redactedURL())
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-s.events:
+			if err := s.deliver(ctx, event); err != nil {
+				webhookEvents.WithLabelValues("failed").Inc()
+				s.logger.Error(err, "Failed to deliver enforcement event",
+					"type", event.Type,
+					"policyKey", event.PolicyKey)
+				continue
+			}
+			webhookEvents.WithLabelValues("delivered").Inc()
+		}
+	}
+}
+
+// NeedLeaderElection implements LeaderElectionRunnable; every node reports its own changes
+func (s *WebhookSink) NeedLeaderElection() bool {
+	return false
+}
+
+// deliver posts event, retrying with backoff on errors and 5xx or 429 responses
+func (s *WebhookSink) deliver(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	delay := s.retryDelay
+	var errs []error
+	for attempt := 1; ; attempt++ {
+		retry, err := s.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("attempt %d: %w", attempt, err))
+		if !retry || attempt == s.opts.MaxAttempts {
+			return errors.Join(errs...)
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Join(append(errs, ctx.Err())...)
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// post sends one request and reports whether a failure is worth retrying
+func (s *WebhookSink) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.opts.BearerTokenFile != "" {
+		token, err := os.ReadFile(s.opts.BearerTokenFile)
+		if err != nil {
+			return true, fmt.Errorf("failed to read bearer token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook responded %s", resp.Status)
+}
+
+// redactedURL returns the webhook URL without credentials or query, for logs
+func (s *WebhookSink) redactedURL() string {
+	target, err := url.Parse(s.opts.URL)
+	if err != nil {
+		return ""
+	}
+	target.User = nil
+	target.RawQuery = ""
+	return target.String()
+}
@@ -0,0 +1,119 @@
+//go:build windows
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// receiver is a webhook endpoint answering with a scripted list of status codes
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	events   []Event
+	tokens   []string
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	var event Event
+	if err := json.NewDecoder(req.Body).Decode(&event); err == nil {
+		rc.events = append(rc.events, event)
+	}
+	rc.tokens = append(rc.tokens, req.Header.Get("Authorization"))
+	w.WriteHeader(status)
+}
+
+func newTestSink(t *testing.T, opts WebhookOptions) *WebhookSink {
+	t.Helper()
+	sink, err := NewWebhookSink(opts, logr.Discard())
+	if err != nil {
+		t.Fatalf("NewWebhookSink failed: %v", err)
+	}
+	sink.retryDelay = time.Millisecond
+	return sink
+}
+
+func TestWebhookSink_Deliver(t *testing.T) {
+	rc := &receiver{statuses: []int{http.StatusServiceUnavailable, http.StatusOK}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sink := newTestSink(t, WebhookOptions{URL: server.URL, BearerTokenFile: tokenFile})
+
+	event := Event{Type: EventApplied, PolicyKey: "default/web", Namespace: "default", Name: "web", RuleCount: 3}
+	if err := sink.deliver(context.Background(), event); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+	if len(rc.events) != 2 {
+		t.Fatalf("Expected the event retried once after a 503, got %d requests", len(rc.events))
+	}
+	if rc.events[1].PolicyKey != "default/web" || rc.events[1].Type != EventApplied || rc.events[1].RuleCount != 3 {
+		t.Errorf("Unexpected event %+v", rc.events[1])
+	}
+	if rc.tokens[1] != "Bearer secret" {
+		t.Errorf("Expected the bearer token sent, got %q", rc.tokens[1])
+	}
+}
+
+func TestWebhookSink_Deliver_GivesUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		requests int
+	}{
+		{name: "client error", statuses: []int{http.StatusBadRequest}, requests: 1},
+		{name: "server errors", statuses: []int{500, 502, 503, 504}, requests: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &receiver{statuses: tt.statuses}
+			server := httptest.NewServer(rc)
+			defer server.Close()
+
+			sink := newTestSink(t, WebhookOptions{URL: server.URL})
+			if err := sink.deliver(context.Background(), Event{Type: EventFailed}); err == nil {
+				t.Fatal("Expected delivery to fail")
+			}
+			if len(rc.events) != tt.requests {
+				t.Errorf("Expected %d requests, got %d", tt.requests, len(rc.events))
+			}
+		})
+	}
+}
+
+func TestWebhookSink_NotifyDropsWhenFull(t *testing.T) {
+	sink := newTestSink(t, WebhookOptions{URL: "http://127.0.0.1:1/events", QueueSize: 1})
+	sink.Notify(Event{PolicyKey: "default/a"})
+	sink.Notify(Event{PolicyKey: "default/b"})
+	if len(sink.events) != 1 || (<-sink.events).PolicyKey != "default/a" {
+		t.Error("Expected the event arriving at a full queue to be dropped")
+	}
+}
+
+func TestNewWebhookSink_InvalidURL(t *testing.T) {
+	for _, target := range []string{"", "example.com/events", "ftp://example.com/events", "http://"} {
+		if _, err := NewWebhookSink(WebhookOptions{URL: target}, logr.Discard()); err == nil {
+			t.Errorf("Expected %q to be rejected", target)
+		}
+	}
+}
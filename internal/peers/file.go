@@ -0,0 +1,85 @@
+//go:build windows
+
+package peers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Host is an entry of a static hosts file: a workload, its labels and IPs
+type Host struct {
+	Namespace string            `json:"namespace"`
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	IPs       []string          `json:"ips"`
+}
+
+// FileResolver resolves selector peers against a fixed list of hosts, for
+// air-gapped testing and nodes that cannot read pods
+type FileResolver struct {
+	hosts []Host
+}
+
+// NewFileResolver creates a resolver over hosts
+func NewFileResolver(hosts []Host) *FileResolver {
+	return &FileResolver{hosts: hosts}
+}
+
+// LoadFileResolver reads a JSON list of Hosts from path
+func LoadFileResolver(path string) (*FileResolver, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hosts file: %w", err)
+	}
+
+	var hosts []Host
+	if err := json.Unmarshal(data, &hosts); err != nil {
+		return nil, fmt.Errorf("failed to parse hosts file %s: %w", path, err)
+	}
+
+	for i, host := range hosts {
+		if host.Namespace == "" || host.Name == "" {
+			return nil, fmt.Errorf("host %d in %s needs a namespace and a name", i, path)
+		}
+		for _, ip := range host.IPs {
+			if net.ParseIP(ip) == nil {
+				return nil, fmt.Errorf("host %s/%s in %s has invalid IP %q", host.Namespace, host.Name, path, ip)
+			}
+		}
+	}
+
+	return NewFileResolver(hosts), nil
+}
+
+// Name implements PeerResolver
+func (r *FileResolver) Name() string {
+	return string(KindFile)
+}
+
+// ResolvePeer implements PeerResolver; reader is not used. Hosts files carry
+// no namespace labels, so namespaceSelector peers are unresolvable.
+func (r *FileResolver) ResolvePeer(ctx context.Context, reader client.Reader, namespace string, peer networkingv1.NetworkPolicyPeer) ([]string, error) {
+	if peer.NamespaceSelector != nil {
+		return nil, ErrUnresolvable
+	}
+	selector, err := PodSelector(peer)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []string
+	for _, host := range r.hosts {
+		if host.Namespace == namespace && selector.Matches(labels.Set(host.Labels)) {
+			ips = append(ips, host.IPs...)
+		}
+	}
+	return sortedUnique(ips), nil
+}
@@ -0,0 +1,57 @@
+//go:build windows
+
+package peers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLoadFileResolver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.json")
+	hosts := `[
+		{"namespace": "default", "name": "web-0", "labels": {"app": "web"}, "ips": ["10.0.0.1", "fd00::1"]},
+		{"namespace": "default", "name": "db-0", "labels": {"app": "db"}, "ips": ["10.0.0.2"]},
+		{"namespace": "other", "name": "web-0", "labels": {"app": "web"}, "ips": ["10.9.0.1"]}
+	]`
+	if err := os.WriteFile(path, []byte(hosts), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	resolver, err := LoadFileResolver(path)
+	if err != nil {
+		t.Fatalf("LoadFileResolver failed: %v", err)
+	}
+	peer := networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}}
+	ips, err := resolver.ResolvePeer(context.Background(), nil, "default", peer)
+	if err != nil {
+		t.Fatalf("ResolvePeer failed: %v", err)
+	}
+	if want := []string{"10.0.0.1", "fd00::1"}; !reflect.DeepEqual(ips, want) {
+		t.Errorf("ResolvePeer() = %v, want %v", ips, want)
+	}
+}
+
+func TestLoadFileResolver_Invalid(t *testing.T) {
+	for name, hosts := range map[string]string{
+		"not json":     `{`,
+		"no namespace": `[{"name": "web-0", "ips": ["10.0.0.1"]}]`,
+		"invalid ip":   `[{"namespace": "default", "name": "web-0", "ips": ["10.0.0.300"]}]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "hosts.json")
+			if err := os.WriteFile(path, []byte(hosts), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadFileResolver(path); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+//go:build windows
+
+package peers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// InformerResolver resolves selector peers to the IPs of the running pods
+// they match, read through the controller's informer cache
+type InformerResolver struct{}
+
+// Name implements PeerResolver
+func (InformerResolver) Name() string {
+	return string(KindInformer)
+}
+
+// ResolvePeer implements PeerResolver. Host-network pods are skipped since
+// their IP is the node's.
+func (InformerResolver) ResolvePeer(ctx context.Context, reader client.Reader, namespace string, peer networkingv1.NetworkPolicyPeer) ([]string, error) {
+	selector, err := PodSelector(peer)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces, err := PeerNamespaces(ctx, reader, namespace, peer)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []string
+	for _, namespace := range namespaces {
+		// Read-only: skip the deep copy of every matched pod
+		var pods corev1.PodList
+		if err := reader.List(ctx, &pods, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}, client.UnsafeDisableDeepCopy); err != nil {
+			return nil, err
+		}
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if pod.Spec.HostNetwork || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+				continue
+			}
+			for _, podIP := range pod.Status.PodIPs {
+				ips = append(ips, podIP.IP)
+			}
+		}
+	}
+	return sortedUnique(ips), nil
+}
+
+// WatchObject implements ObjectWatcher: pod changes requeue the NetworkPolicies
+// of their namespace and those selecting it
+func (InformerResolver) WatchObject() client.Object {
+	return &corev1.Pod{}
+}
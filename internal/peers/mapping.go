@@ -0,0 +1,55 @@
+//go:build windows
+
+package peers
+
+import (
+	"context"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1alpha1 "github.com/knabben/firewall-controller/api/v1alpha1"
+)
+
+// MappingResolver resolves selector peers to the addresses of the PeerMapping
+// objects whose labels they match, for workloads the agent cannot see as pods
+type MappingResolver struct{}
+
+// Name implements PeerResolver
+func (MappingResolver) Name() string {
+	return string(KindCRD)
+}
+
+// ResolvePeer implements PeerResolver
+func (MappingResolver) ResolvePeer(ctx context.Context, reader client.Reader, namespace string, peer networkingv1.NetworkPolicyPeer) ([]string, error) {
+	selector, err := PodSelector(peer)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces, err := PeerNamespaces(ctx, reader, namespace, peer)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []string
+	for _, namespace := range namespaces {
+		var mappings v1alpha1.PeerMappingList
+		if err := reader.List(ctx, &mappings, client.InNamespace(namespace)); err != nil {
+			return nil, err
+		}
+		for _, mapping := range mappings.Items {
+			if selector.Matches(labels.Set(mapping.Spec.Labels)) {
+				ips = append(ips, mapping.Spec.Addresses...)
+			}
+		}
+	}
+	return sortedUnique(ips), nil
+}
+
+// WatchObject implements ObjectWatcher: PeerMapping changes requeue the
+// NetworkPolicies of their namespace and those selecting it
+func (MappingResolver) WatchObject() client.Object {
+	return &v1alpha1.PeerMapping{}
+}
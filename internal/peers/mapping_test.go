@@ -0,0 +1,41 @@
+//go:build windows
+
+package peers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	v1alpha1 "github.com/knabben/firewall-controller/api/v1alpha1"
+)
+
+func TestMappingResolver(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(scheme)
+	mapping := func(namespace, name string, labels map[string]string, addresses ...string) *v1alpha1.PeerMapping {
+		return &v1alpha1.PeerMapping{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       v1alpha1.PeerMappingSpec{Labels: labels, Addresses: addresses},
+		}
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		mapping("default", "legacy-db", map[string]string{"app": "db"}, "10.20.0.16", "10.20.0.15"),
+		mapping("default", "legacy-web", map[string]string{"app": "web"}, "10.20.0.20"),
+		mapping("other", "legacy-db", map[string]string{"app": "db"}, "10.30.0.1"),
+	).Build()
+
+	peer := networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}}
+	ips, err := MappingResolver{}.ResolvePeer(context.Background(), reader, "default", peer)
+	if err != nil {
+		t.Fatalf("ResolvePeer failed: %v", err)
+	}
+	if want := []string{"10.20.0.15", "10.20.0.16"}; !reflect.DeepEqual(ips, want) {
+		t.Errorf("ResolvePeer() = %v, want %v", ips, want)
+	}
+}
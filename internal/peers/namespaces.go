@@ -0,0 +1,88 @@
+//go:build windows
+
+package peers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PeerNamespaces returns the names of the namespaces peer selects pods in, for
+// a NetworkPolicy in namespace: the policy's own without a namespaceSelector,
+// otherwise those whose labels match it, sorted
+func PeerNamespaces(ctx context.Context, reader client.Reader, namespace string, peer networkingv1.NetworkPolicyPeer) ([]string, error) {
+	if peer.NamespaceSelector == nil {
+		return []string{namespace}, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(peer.NamespaceSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespaceSelector: %w", err)
+	}
+
+	var namespaces corev1.NamespaceList
+	if err := reader.List(ctx, &namespaces, client.MatchingLabelsSelector{Selector: selector}, client.UnsafeDisableDeepCopy); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(namespaces.Items))
+	for i := range namespaces.Items {
+		names = append(names, namespaces.Items[i].Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// SelectedNamespaces returns the namespaces, other than its own, that the
+// namespaceSelector peers of np select, sorted
+func SelectedNamespaces(ctx context.Context, reader client.Reader, np *networkingv1.NetworkPolicy) ([]string, error) {
+	seen := map[string]bool{np.Namespace: true}
+	var selected []string
+	for _, peer := range selectorPeers(np) {
+		if peer.NamespaceSelector == nil {
+			continue
+		}
+		names, err := PeerNamespaces(ctx, reader, np.Namespace, peer)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				selected = append(selected, name)
+			}
+		}
+	}
+	sort.Strings(selected)
+	return selected, nil
+}
+
+// HasNamespaceSelectorPeers reports whether np has peers selecting other namespaces
+func HasNamespaceSelectorPeers(np *networkingv1.NetworkPolicy) bool {
+	for _, peer := range selectorPeers(np) {
+		if peer.NamespaceSelector != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectsNamespace reports whether a namespaceSelector peer of np matches the
+// labels of namespace, so objects there may change its resolved peers
+func SelectsNamespace(np *networkingv1.NetworkPolicy, namespace *corev1.Namespace) bool {
+	for _, peer := range selectorPeers(np) {
+		if peer.NamespaceSelector == nil {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(peer.NamespaceSelector)
+		if err == nil && selector.Matches(labels.Set(namespace.Labels)) {
+			return true
+		}
+	}
+	return false
+}
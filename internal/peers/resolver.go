@@ -0,0 +1,182 @@
+//go:build windows
+
+// Package peers resolves the pod and namespace selector peers of
+// NetworkPolicies to IP addresses. The source of truth is pluggable, so the
+// converter works whether the agent can watch pods, reads CRD-based mappings
+// or runs air-gapped from a static file.
+package peers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/knabben/firewall-controller/internal/converter"
+)
+
+// ErrUnresolvable is returned by resolvers for peers they cannot translate;
+// such peers are skipped like before resolution existed
+var ErrUnresolvable = errors.New("peer cannot be resolved")
+
+// PeerResolver resolves a NetworkPolicy peer to the IPs of the pods it selects
+type PeerResolver interface {
+	// Name identifies the resolver in logs
+	Name() string
+
+	// ResolvePeer returns the IPs selected by peer for a NetworkPolicy in
+	// namespace. reader reads from the cluster the policy came from.
+	// Peers the resolver cannot translate return ErrUnresolvable.
+	ResolvePeer(ctx context.Context, reader client.Reader, namespace string, peer networkingv1.NetworkPolicyPeer) ([]string, error)
+}
+
+// ObjectWatcher is implemented by resolvers whose answers follow a kind of
+// object; changes to such objects requeue the NetworkPolicies of their namespace
+type ObjectWatcher interface {
+	// WatchObject returns an empty object of the watched kind
+	WatchObject() client.Object
+}
+
+// Kind selects a PeerResolver implementation
+type Kind string
+
+const (
+	// KindNone leaves selector peers unresolved
+	KindNone Kind = "none"
+
+	// KindInformer resolves selectors against the pods in the informer cache
+	KindInformer Kind = "informer"
+
+	// KindFile resolves selectors against a static hosts file
+	KindFile Kind = "file"
+
+	// KindCRD resolves selectors against PeerMapping objects
+	KindCRD Kind = "crd"
+)
+
+// New returns the resolver of kind; path is the hosts file of KindFile.
+// KindNone returns a nil resolver.
+func New(kind Kind, path string) (PeerResolver, error) {
+	switch kind {
+	case KindNone, "":
+		return nil, nil
+	case KindInformer:
+		return InformerResolver{}, nil
+	case KindFile:
+		if path == "" {
+			return nil, fmt.Errorf("peer resolver %q requires a hosts file", kind)
+		}
+		return LoadFileResolver(path)
+	case KindCRD:
+		return MappingResolver{}, nil
+	default:
+		return nil, fmt.Errorf("invalid peer resolver %q: must be none, informer, file or crd", kind)
+	}
+}
+
+// Resolve resolves every selector peer of np, keyed by converter.PeerKey.
+// Peers the resolver cannot translate are left out, so conversion skips them.
+func Resolve(ctx context.Context, resolver PeerResolver, reader client.Reader, np *networkingv1.NetworkPolicy) (map[string][]string, error) {
+	resolved := make(map[string][]string)
+	for _, peer := range selectorPeers(np) {
+		key := converter.PeerKey(peer)
+		if _, done := resolved[key]; done {
+			continue
+		}
+		ips, err := resolver.ResolvePeer(ctx, reader, np.Namespace, peer)
+		if errors.Is(err, ErrUnresolvable) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s with the %s resolver: %w", key, resolver.Name(), err)
+		}
+		resolved[key] = ips
+	}
+	return resolved, nil
+}
+
+// Resolves reports whether resolver translates peer to addresses rather than
+// skipping it. It asks the resolver without reading the cluster, to describe
+// what the agent enforces (see converter.BuildEnforcementMatrix).
+func Resolves(resolver PeerResolver, peer networkingv1.NetworkPolicyPeer) bool {
+	if resolver == nil {
+		return false
+	}
+	_, err := resolver.ResolvePeer(context.Background(), emptyReader{}, "", peer)
+	return !errors.Is(err, ErrUnresolvable)
+}
+
+// emptyReader is a client.Reader of an empty cluster
+type emptyReader struct{}
+
+// Get implements client.Reader
+func (emptyReader) Get(_ context.Context, key client.ObjectKey, _ client.Object, _ ...client.GetOption) error {
+	return apierrors.NewNotFound(schema.GroupResource{}, key.Name)
+}
+
+// List implements client.Reader
+func (emptyReader) List(context.Context, client.ObjectList, ...client.ListOption) error {
+	return nil
+}
+
+// HasSelectorPeers reports whether np has pod or namespace selector peers
+func HasSelectorPeers(np *networkingv1.NetworkPolicy) bool {
+	return len(selectorPeers(np)) > 0
+}
+
+// selectorPeers returns the peers of np that select pods rather than IP blocks
+func selectorPeers(np *networkingv1.NetworkPolicy) []networkingv1.NetworkPolicyPeer {
+	var peers []networkingv1.NetworkPolicyPeer
+	add := func(list []networkingv1.NetworkPolicyPeer) {
+		for _, peer := range list {
+			if peer.IPBlock == nil && (peer.PodSelector != nil || peer.NamespaceSelector != nil) {
+				peers = append(peers, peer)
+			}
+		}
+	}
+	for _, rule := range np.Spec.Ingress {
+		add(rule.From)
+	}
+	for _, rule := range np.Spec.Egress {
+		add(rule.To)
+	}
+	return peers
+}
+
+// PodSelector returns the selector of the pods a peer selects in each of its
+// namespaces (see PeerNamespaces); a namespaceSelector alone selects them all
+func PodSelector(peer networkingv1.NetworkPolicyPeer) (labels.Selector, error) {
+	if peer.PodSelector == nil {
+		if peer.NamespaceSelector == nil {
+			return nil, ErrUnresolvable
+		}
+		return labels.Everything(), nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(peer.PodSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid podSelector: %w", err)
+	}
+	return selector, nil
+}
+
+// sortedUnique returns the distinct addresses, sorted, so rules are stable
+// across resolutions
+func sortedUnique(addresses []string) []string {
+	seen := make(map[string]bool, len(addresses))
+	out := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if !seen[address] {
+			seen[address] = true
+			out = append(out, address)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
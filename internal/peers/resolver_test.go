@@ -0,0 +1,153 @@
+//go:build windows
+
+package peers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/knabben/firewall-controller/internal/converter"
+)
+
+func testPod(name string, labels map[string]string, ip string, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+		Status:     corev1.PodStatus{Phase: phase, PodIPs: []corev1.PodIP{{IP: ip}}},
+	}
+}
+
+func selectorPolicy(peers ...networkingv1.NetworkPolicyPeer) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{From: peers}},
+		},
+	}
+}
+
+func TestResolve_Informer(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		testPod("web-1", map[string]string{"app": "web"}, "10.0.0.2", corev1.PodRunning),
+		testPod("web-0", map[string]string{"app": "web"}, "10.0.0.1", corev1.PodRunning),
+		testPod("web-done", map[string]string{"app": "web"}, "10.0.0.3", corev1.PodSucceeded),
+		testPod("db-0", map[string]string{"app": "db"}, "10.0.0.4", corev1.PodRunning),
+	).Build()
+
+	web := networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}}
+	cache := networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "cache"}}}
+	otherNamespaces := networkingv1.NetworkPolicyPeer{NamespaceSelector: &metav1.LabelSelector{}}
+	np := selectorPolicy(web, cache, otherNamespaces, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: "10.1.0.0/16"}})
+
+	if !HasSelectorPeers(np) {
+		t.Fatal("Expected the policy to have selector peers")
+	}
+	resolved, err := Resolve(context.Background(), InformerResolver{}, reader, np)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	// No Namespace matches the namespaceSelector in this cluster
+	expected := map[string][]string{
+		converter.PeerKey(web):             {"10.0.0.1", "10.0.0.2"},
+		converter.PeerKey(cache):           {},
+		converter.PeerKey(otherNamespaces): {},
+	}
+	if !reflect.DeepEqual(resolved, expected) {
+		t.Errorf("Resolve() = %v, want %v", resolved, expected)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, kind := range []Kind{KindNone, KindInformer, KindCRD} {
+		if _, err := New(kind, ""); err != nil {
+			t.Errorf("New(%s) failed: %v", kind, err)
+		}
+	}
+	if resolver, _ := New(KindNone, ""); resolver != nil {
+		t.Error("Expected no resolver for none")
+	}
+	if _, err := New(KindFile, ""); err == nil {
+		t.Error("Expected the file resolver to require a hosts file")
+	}
+	if _, err := New("dns", ""); err == nil {
+		t.Error("Expected an unknown resolver to be rejected")
+	}
+}
+
+func TestResolve_NamespaceSelector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	namespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	monitoring := testPod("prometheus-0", map[string]string{"app": "prometheus"}, "10.1.0.1", corev1.PodRunning)
+	monitoring.Namespace = "monitoring"
+	exporter := testPod("exporter-0", map[string]string{"app": "exporter"}, "10.1.0.2", corev1.PodRunning)
+	exporter.Namespace = "monitoring"
+	batch := testPod("job-0", map[string]string{"app": "prometheus"}, "10.2.0.1", corev1.PodRunning)
+	batch.Namespace = "batch"
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		namespace("default", nil),
+		namespace("monitoring", map[string]string{"team": "observability"}),
+		namespace("batch", map[string]string{"team": "data"}),
+		monitoring, exporter, batch,
+		testPod("web-0", map[string]string{"app": "prometheus"}, "10.0.0.1", corev1.PodRunning),
+	).Build()
+
+	observability := &metav1.LabelSelector{MatchLabels: map[string]string{"team": "observability"}}
+	wholeNamespace := networkingv1.NetworkPolicyPeer{NamespaceSelector: observability}
+	prometheus := networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: observability,
+		PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "prometheus"}},
+	}
+	anyPrometheus := networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: &metav1.LabelSelector{},
+		PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "prometheus"}},
+	}
+	np := selectorPolicy(wholeNamespace, prometheus, anyPrometheus)
+
+	resolved, err := Resolve(context.Background(), InformerResolver{}, reader, np)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	expected := map[string][]string{
+		converter.PeerKey(wholeNamespace): {"10.1.0.1", "10.1.0.2"},
+		converter.PeerKey(prometheus):     {"10.1.0.1"},
+		converter.PeerKey(anyPrometheus):  {"10.0.0.1", "10.1.0.1", "10.2.0.1"},
+	}
+	if !reflect.DeepEqual(resolved, expected) {
+		t.Errorf("Resolve() = %v, want %v", resolved, expected)
+	}
+
+	selected, err := SelectedNamespaces(context.Background(), reader, np)
+	if err != nil {
+		t.Fatalf("SelectedNamespaces failed: %v", err)
+	}
+	if !reflect.DeepEqual(selected, []string{"batch", "monitoring"}) {
+		t.Errorf("SelectedNamespaces() = %v, want [batch monitoring]", selected)
+	}
+	if !SelectsNamespace(selectorPolicy(prometheus), namespace("monitoring", map[string]string{"team": "observability"})) ||
+		SelectsNamespace(selectorPolicy(prometheus), namespace("batch", map[string]string{"team": "data"})) {
+		t.Error("Expected SelectsNamespace to follow the namespace labels")
+	}
+}
+
+func TestFileResolver_NamespaceSelectorUnresolvable(t *testing.T) {
+	peer := networkingv1.NetworkPolicyPeer{
+		PodSelector:       &metav1.LabelSelector{},
+		NamespaceSelector: &metav1.LabelSelector{},
+	}
+	if _, err := NewFileResolver(nil).ResolvePeer(context.Background(), nil, "default", peer); !errors.Is(err, ErrUnresolvable) {
+		t.Errorf("Expected namespaceSelector peers to be unresolvable from a hosts file, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+//go:build windows
+
+// Package perfcounters publishes the agent's health as Windows performance
+// counters, so Windows monitoring agents (SCOM, Datadog, perfmon) can pick it
+// up without scraping Prometheus
+package perfcounters
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	// providerGUID and counterSetGUID must match config/perfcounters/networkpolicy-agent.man
+	providerGUID   = windows.GUID{Data1: 0xc7b4c3d2, Data2: 0x6f0e, Data3: 0x4a57, Data4: [8]byte{0x9b, 0x3e, 0x2f, 0x8a, 0x1d, 0x5e, 0x9c, 0x41}}
+	counterSetGUID = windows.GUID{Data1: 0x5e2a9f14, Data2: 0x8c3b, Data3: 0x4d6e, Data4: [8]byte{0xa1, 0xf7, 0x0b, 0x9c, 0x8d, 0x7e, 0x6f, 0x52}}
+)
+
+// instanceName names the single counter set instance
+const instanceName = "networkpolicy-agent"
+
+// Values are the numbers published as counters. ApplyFailures is the health
+// signal: it stays flat while every apply succeeds. Applies shows the agent
+// is working, as a rate; RulesProgrammed only grows with rule churn.
+type Values struct {
+	// RulesProgrammed is the number of ACL policies added to endpoints
+	RulesProgrammed uint64
+
+	// Applies is the number of policy applications, published as a rate
+	Applies uint64
+
+	// ApplyFailures is the number of policy applications that failed on any endpoint
+	ApplyFailures uint64
+
+	// ApplyLatency is the latency of the most recent policy application
+	ApplyLatency time.Duration
+
+	TrackedPolicies uint64
+	Endpoints       uint64
+}
+
+// counter describes one published counter; ids must match the manifest
+type counter struct {
+	id          uint32
+	counterType uint32
+	value       func(Values) uint64
+}
+
+var counters = []counter{
+	{id: 1, counterType: perfCounterLargeRawcount, value: func(v Values) uint64 { return v.RulesProgrammed }},
+	{id: 2, counterType: perfCounterBulkCount, value: func(v Values) uint64 { return v.Applies }},
+	{id: 3, counterType: perfCounterLargeRawcount, value: func(v Values) uint64 { return v.ApplyFailures }},
+	{id: 4, counterType: perfCounterLargeRawcount, value: func(v Values) uint64 { return uint64(v.ApplyLatency.Milliseconds()) }},
+	{id: 5, counterType: perfCounterLargeRawcount, value: func(v Values) uint64 { return v.TrackedPolicies }},
+	{id: 6, counterType: perfCounterLargeRawcount, value: func(v Values) uint64 { return v.Endpoints }},
+}
+
+// Publisher periodically copies Values from a source into the counters. It
+// implements manager.Runnable.
+type Publisher struct {
+	source   func() Values
+	interval time.Duration
+	logger   logr.Logger
+}
+
+// NewPublisher creates a publisher reading source every interval
+func NewPublisher(source func() Values, interval time.Duration, logger logr.Logger) *Publisher {
+	return &Publisher{source: source, interval: interval, logger: logger}
+}
+
+// Start registers the counters and updates them until ctx is done. Failing to
+// register the counters is logged rather than returned: they are optional and
+// must not stop the agent.
+func (p *Publisher) Start(ctx context.Context) error {
+	provider, err := startProvider(counters)
+	if err != nil {
+		p.logger.Error(err, "Unable to register Windows performance counters")
+		return nil
+	}
+	defer provider.stop()
+	p.logger.Info("Publishing Windows performance counters", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.publish(provider)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable; every node
+// publishes its own counters
+func (p *Publisher) NeedLeaderElection() bool {
+	return false
+}
+
+// publish copies the current values into the counters
+func (p *Publisher) publish(provider *provider) {
+	values := p.source()
+	for _, c := range counters {
+		if err := provider.set(c.id, c.value(values)); err != nil {
+			p.logger.V(1).Info("Failed to update performance counter", "error", err.Error())
+		}
+	}
+}
@@ -0,0 +1,48 @@
+//go:build windows
+
+package perfcounters
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestCounterSetTemplate(t *testing.T) {
+	template := counterSetTemplate(counters)
+	if want := 40 + 32*len(counters); len(template) != want {
+		t.Fatalf("Expected a %d byte template, got %d", want, len(template))
+	}
+	if n := binary.LittleEndian.Uint32(template[32:]); n != uint32(len(counters)) {
+		t.Errorf("Expected NumCounters %d, got %d", len(counters), n)
+	}
+
+	for i, c := range counters {
+		info := template[40+32*i:]
+		if id := binary.LittleEndian.Uint32(info[0:]); id != c.id {
+			t.Errorf("Counter %d: expected id %d, got %d", i, c.id, id)
+		}
+		if size := binary.LittleEndian.Uint32(info[16:]); size != 8 {
+			t.Errorf("Counter %d: expected size 8, got %d", i, size)
+		}
+		if offset := binary.LittleEndian.Uint32(info[28:]); offset != uint32(i*8) {
+			t.Errorf("Counter %d: expected offset %d, got %d", i, i*8, offset)
+		}
+	}
+}
+
+func TestCounters(t *testing.T) {
+	values := Values{RulesProgrammed: 10, Applies: 7, ApplyFailures: 2, ApplyLatency: 1500 * time.Millisecond, TrackedPolicies: 3, Endpoints: 4}
+	want := map[uint32]uint64{1: 10, 2: 7, 3: 2, 4: 1500, 5: 3, 6: 4}
+
+	seen := make(map[uint32]bool)
+	for _, c := range counters {
+		if seen[c.id] {
+			t.Errorf("Duplicate counter id %d", c.id)
+		}
+		seen[c.id] = true
+		if got := c.value(values); got != want[c.id] {
+			t.Errorf("Counter %d = %d, want %d", c.id, got, want[c.id])
+		}
+	}
+}
@@ -0,0 +1,137 @@
+//go:build windows
+
+package perfcounters
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	advapi32 = windows.NewLazySystemDLL("advapi32.dll")
+
+	procPerfStartProvider            = advapi32.NewProc("PerfStartProvider")
+	procPerfStopProvider             = advapi32.NewProc("PerfStopProvider")
+	procPerfSetCounterSetInfo        = advapi32.NewProc("PerfSetCounterSetInfo")
+	procPerfCreateInstance           = advapi32.NewProc("PerfCreateInstance")
+	procPerfDeleteInstance           = advapi32.NewProc("PerfDeleteInstance")
+	procPerfSetULongLongCounterValue = advapi32.NewProc("PerfSetULongLongCounterValue")
+)
+
+const (
+	// perfCounterLargeRawcount is PERF_COUNTER_LARGE_RAWCOUNT: a 64-bit value shown as is
+	perfCounterLargeRawcount = 0x00010100
+
+	// perfCounterBulkCount is PERF_COUNTER_BULK_COUNT: a 64-bit total shown as a rate per second
+	perfCounterBulkCount = 0x10410500
+
+	// perfDetailNovice is PERF_DETAIL_NOVICE
+	perfDetailNovice = 100
+
+	// perfCountersetSingleInstance is PERF_COUNTERSET_SINGLE_INSTANCE
+	perfCountersetSingleInstance = 0
+)
+
+// counterSetInfo mirrors PERF_COUNTERSET_INFO
+type counterSetInfo struct {
+	CounterSetGUID windows.GUID
+	ProviderGUID   windows.GUID
+	NumCounters    uint32
+	InstanceType   uint32
+}
+
+// counterInfo mirrors PERF_COUNTER_INFO
+type counterInfo struct {
+	CounterID   uint32
+	Type        uint32
+	Attrib      uint64
+	Size        uint32
+	DetailLevel uint32
+	Scale       int32
+	Offset      uint32
+}
+
+// counterSetTemplate encodes the PERF_COUNTERSET_INFO block describing counters,
+// each stored as a 64-bit value in declaration order
+func counterSetTemplate(counters []counter) []byte {
+	var buf bytes.Buffer
+	// Writes to a bytes.Buffer cannot fail
+	_ = binary.Write(&buf, binary.LittleEndian, counterSetInfo{
+		CounterSetGUID: counterSetGUID,
+		ProviderGUID:   providerGUID,
+		NumCounters:    uint32(len(counters)),
+		InstanceType:   perfCountersetSingleInstance,
+	})
+	for i, c := range counters {
+		_ = binary.Write(&buf, binary.LittleEndian, counterInfo{
+			CounterID:   c.id,
+			Type:        c.counterType,
+			Size:        8,
+			DetailLevel: perfDetailNovice,
+			Offset:      uint32(i * 8),
+		})
+	}
+	return buf.Bytes()
+}
+
+// provider is a registered PerfLib V2 provider with one counter set instance
+type provider struct {
+	handle   windows.Handle
+	instance uintptr
+}
+
+// startProvider registers the provider and creates the counter set instance.
+// The counters only become visible to consumers once the manifest is installed
+// with lodctr.
+func startProvider(counters []counter) (*provider, error) {
+	if err := advapi32.Load(); err != nil {
+		return nil, err
+	}
+
+	p := &provider{}
+	guid := providerGUID
+	if rc, _, _ := procPerfStartProvider.Call(uintptr(unsafe.Pointer(&guid)), 0, uintptr(unsafe.Pointer(&p.handle))); rc != 0 {
+		return nil, fmt.Errorf("PerfStartProvider: %w", windows.Errno(rc))
+	}
+
+	template := counterSetTemplate(counters)
+	if rc, _, _ := procPerfSetCounterSetInfo.Call(uintptr(p.handle), uintptr(unsafe.Pointer(&template[0])), uintptr(len(template))); rc != 0 {
+		p.stop()
+		return nil, fmt.Errorf("PerfSetCounterSetInfo: %w", windows.Errno(rc))
+	}
+
+	name, err := windows.UTF16PtrFromString(instanceName)
+	if err != nil {
+		p.stop()
+		return nil, err
+	}
+	setGUID := counterSetGUID
+	instance, _, callErr := procPerfCreateInstance.Call(uintptr(p.handle), uintptr(unsafe.Pointer(&setGUID)), uintptr(unsafe.Pointer(name)), 0)
+	if instance == 0 {
+		p.stop()
+		return nil, fmt.Errorf("PerfCreateInstance: %w", callErr)
+	}
+	p.instance = instance
+	return p, nil
+}
+
+// set updates one counter of the instance
+func (p *provider) set(id uint32, value uint64) error {
+	if rc, _, _ := procPerfSetULongLongCounterValue.Call(uintptr(p.handle), p.instance, uintptr(id), uintptr(value)); rc != 0 {
+		return fmt.Errorf("PerfSetULongLongCounterValue(%d): %w", id, windows.Errno(rc))
+	}
+	return nil
+}
+
+// stop deletes the instance and unregisters the provider
+func (p *provider) stop() {
+	if p.instance != 0 {
+		_, _, _ = procPerfDeleteInstance.Call(uintptr(p.handle), p.instance)
+		p.instance = 0
+	}
+	_, _, _ = procPerfStopProvider.Call(uintptr(p.handle))
+}
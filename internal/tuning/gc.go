@@ -0,0 +1,114 @@
+//go:build windows
+
+// Package tuning applies Go runtime settings that trade memory for shorter GC
+// pauses on nodes with thousands of rules
+package tuning
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strconv"
+)
+
+const (
+	// PerfModeGOGC is the GC target used by perf mode: the heap may grow to 5x
+	// the live set between collections, cutting GC frequency during mass resyncs
+	PerfModeGOGC = 400
+
+	// GCOff is the GC percentage that turns the proportional GC off, as set
+	// by GOGC=off
+	GCOff = -1
+
+	// unset marks a knob left at the runtime's default (or GOGC/GOMEMLIMIT env)
+	unset = -1
+)
+
+// GCOptions configures the Go garbage collector
+type GCOptions struct {
+	// GOGC is the GC target percentage with the meanings of the GOGC
+	// environment variable: "off" or a negative value turns the GC off and 0
+	// collects continuously. Empty keeps the runtime default.
+	GOGC string
+
+	// MemoryLimit is the soft memory limit in bytes; 0 keeps the runtime default
+	MemoryLimit int64
+
+	// PerfMode raises GOGC to PerfModeGOGC unless GOGC is set explicitly.
+	// It requires MemoryLimit so the GC still runs before the container limit.
+	PerfMode bool
+}
+
+// GCSettings are the runtime settings in effect after Apply
+type GCSettings struct {
+	GOGC        int
+	MemoryLimit int64
+}
+
+// Validate checks the options for inconsistent values
+func (o GCOptions) Validate() error {
+	gogc, err := o.percent()
+	if err != nil {
+		return err
+	}
+	if o.MemoryLimit < 0 {
+		return fmt.Errorf("invalid memory limit %d", o.MemoryLimit)
+	}
+	if o.PerfMode && o.MemoryLimit == 0 {
+		return fmt.Errorf("perf mode requires a memory limit")
+	}
+	if o.GOGC != "" && gogc == GCOff && o.MemoryLimit == 0 {
+		return fmt.Errorf("GOGC off disables the GC and requires a memory limit")
+	}
+	return nil
+}
+
+// percent parses GOGC like the runtime parses the environment variable,
+// returning unset when it is empty
+func (o GCOptions) percent() (int, error) {
+	switch o.GOGC {
+	case "":
+		return unset, nil
+	case "off":
+		return GCOff, nil
+	}
+	gogc, err := strconv.Atoi(o.GOGC)
+	if err != nil {
+		return 0, fmt.Errorf("invalid GOGC %q: must be off or a percentage", o.GOGC)
+	}
+	if gogc < 0 {
+		return GCOff, nil
+	}
+	return gogc, nil
+}
+
+// Apply configures the runtime and returns the resulting settings
+func Apply(opts GCOptions) (GCSettings, error) {
+	if err := opts.Validate(); err != nil {
+		return GCSettings{}, err
+	}
+
+	gogc, _ := opts.percent()
+	switch {
+	case opts.GOGC != "":
+		// Off leaves collection to the memory limit; 0 collects continuously
+		debug.SetGCPercent(gogc)
+	case opts.PerfMode:
+		debug.SetGCPercent(PerfModeGOGC)
+	}
+
+	if opts.MemoryLimit > 0 {
+		debug.SetMemoryLimit(opts.MemoryLimit)
+	}
+
+	return current(), nil
+}
+
+// current reads back the runtime's GC settings without changing them
+func current() GCSettings {
+	gogc := debug.SetGCPercent(unset)
+	debug.SetGCPercent(gogc)
+	return GCSettings{
+		GOGC:        gogc,
+		MemoryLimit: debug.SetMemoryLimit(unset),
+	}
+}
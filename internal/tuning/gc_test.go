@@ -0,0 +1,78 @@
+//go:build windows
+
+package tuning
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	previousGOGC := debug.SetGCPercent(100)
+	previousLimit := debug.SetMemoryLimit(-1)
+	defer func() {
+		debug.SetGCPercent(previousGOGC)
+		debug.SetMemoryLimit(previousLimit)
+	}()
+
+	settings, err := Apply(GCOptions{MemoryLimit: 512 << 20, PerfMode: true})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if settings.GOGC != PerfModeGOGC {
+		t.Errorf("Expected perf mode GOGC %d, got %d", PerfModeGOGC, settings.GOGC)
+	}
+	if settings.MemoryLimit != 512<<20 {
+		t.Errorf("Expected memory limit %d, got %d", 512<<20, settings.MemoryLimit)
+	}
+
+	// An explicit GOGC wins over perf mode
+	settings, err = Apply(GCOptions{GOGC: "150", MemoryLimit: 512 << 20, PerfMode: true})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if settings.GOGC != 150 {
+		t.Errorf("Expected explicit GOGC 150, got %d", settings.GOGC)
+	}
+
+	// 0 is passed through as GOGC=0 is, not turned into off
+	settings, err = Apply(GCOptions{GOGC: "0"})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if settings.GOGC != 0 {
+		t.Errorf("Expected GOGC 0, got %d", settings.GOGC)
+	}
+
+	settings, err = Apply(GCOptions{GOGC: "off", MemoryLimit: 512 << 20})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if settings.GOGC != GCOff {
+		t.Errorf("Expected the GC off, got %d", settings.GOGC)
+	}
+}
+
+func TestGCOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      GCOptions
+		expectErr bool
+	}{
+		{name: "defaults", opts: GCOptions{}},
+		{name: "gc off", opts: GCOptions{GOGC: "off", MemoryLimit: 1 << 30}},
+		{name: "gc off without limit", opts: GCOptions{GOGC: "off"}, expectErr: true},
+		{name: "negative gogc without limit", opts: GCOptions{GOGC: "-1"}, expectErr: true},
+		{name: "continuous gc", opts: GCOptions{GOGC: "0"}},
+		{name: "invalid gogc", opts: GCOptions{GOGC: "fast"}, expectErr: true},
+		{name: "perf mode without limit", opts: GCOptions{PerfMode: true}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); (err != nil) != tt.expectErr {
+				t.Errorf("Validate() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
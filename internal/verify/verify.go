@@ -0,0 +1,239 @@
+//go:build windows
+
+// Package verify checks that HNS enforces programmed ACL rules by sending
+// synthetic TCP connection attempts to endpoints and comparing the outcome
+// with the verdict the rules predict.
+package verify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+	"golang.org/x/sys/windows"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+// Verdict is what happens to a connection attempt
+type Verdict string
+
+const (
+	// Allowed connections reach the endpoint, which accepts or refuses them
+	Allowed Verdict = "Allowed"
+
+	// Blocked connections are dropped before reaching the endpoint
+	Blocked Verdict = "Blocked"
+)
+
+// Probe is a TCP connection attempt to a port of an endpoint
+type Probe struct {
+	EndpointID string
+	Address    netip.Addr
+	Port       uint16
+
+	// Expect is the verdict of the rules programmed on the endpoint
+	Expect Verdict
+
+	// Rule names the rule deciding Expect; empty when no rule matches
+	Rule string
+}
+
+func (p Probe) String() string {
+	return fmt.Sprintf("%s:%d on endpoint %s", p.Address, p.Port, p.EndpointID)
+}
+
+// Result is the outcome of a Probe
+type Result struct {
+	Probe Probe
+
+	// Observed is the verdict seen on the wire; empty when Err is set
+	Observed Verdict
+
+	// Err is set when the attempt failed for another reason than the rules
+	Err error
+}
+
+// Failed reports whether HNS did not enforce the verdict its rules predict
+func (r Result) Failed() bool {
+	return r.Err == nil && r.Observed != r.Probe.Expect
+}
+
+func (r Result) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("%s: %v", r.Probe, r.Err)
+	}
+	rule := r.Probe.Rule
+	if rule == "" {
+		rule = "no rule"
+	}
+	return fmt.Sprintf("%s: expected %s by %s, observed %s", r.Probe, r.Probe.Expect, rule, r.Observed)
+}
+
+// PlanProbes returns up to maxProbes probes from source to the TCP ports the
+// ingress rules of policyRules open or close on an endpoint, with the verdict
+// of the endpoint's whole ACL table. Ports whose verdict depends on the
+// connection's source port cannot be predicted and are skipped.
+func PlanProbes(endpointID string, address, source netip.Addr, policyRules []hcnpkg.ACLRule, table []hcnpkg.ACLRule, maxProbes int) []Probe {
+	var probes []Probe
+	var seen []uint16
+	for _, rule := range policyRules {
+		if rule.Direction != hcnlib.DirectionTypeIn || (rule.Protocol != "" && rule.Protocol != "6") {
+			continue
+		}
+		for _, port := range rulePorts(rule.LocalPorts) {
+			if len(probes) == maxProbes {
+				return probes
+			}
+			if slices.Contains(seen, port) {
+				continue
+			}
+			seen = append(seen, port)
+			expect, decidedBy, ok := Expect(table, address, source, port)
+			if !ok {
+				continue
+			}
+			probes = append(probes, Probe{EndpointID: endpointID, Address: address, Port: port, Expect: expect, Rule: decidedBy})
+		}
+	}
+	return probes
+}
+
+// Expect returns the verdict of rules for a TCP connection from source to
+// port of address, and the name of the rule deciding it. Rules are matched
+// by priority, Block winning ties, and connections no rule matches are
+// allowed like HNS does. ok is false when a matching rule restricts the
+// source port, which a probe does not choose.
+func Expect(rules []hcnpkg.ACLRule, address, source netip.Addr, port uint16) (Verdict, string, bool) {
+	var winner *hcnpkg.ACLRule
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Direction != hcnlib.DirectionTypeIn || (rule.Protocol != "" && rule.Protocol != "6") {
+			continue
+		}
+		if rule.Action != hcnlib.ActionTypeAllow && rule.Action != hcnlib.ActionTypeBlock {
+			continue
+		}
+		if !matchesPorts(rule.LocalPorts, port) || !matchesAddresses(rule.RemoteAddresses, source) ||
+			!matchesAddresses(rule.LocalAddresses, address) {
+			continue
+		}
+		if rule.RemotePorts != "" {
+			return "", "", false
+		}
+		if winner == nil || rule.Priority < winner.Priority ||
+			(rule.Priority == winner.Priority && rule.Action == hcnlib.ActionTypeBlock) {
+			winner = rule
+		}
+	}
+	switch {
+	case winner == nil:
+		return Allowed, "", true
+	case winner.Action == hcnlib.ActionTypeBlock:
+		return Blocked, winner.Name, true
+	default:
+		return Allowed, winner.Name, true
+	}
+}
+
+// rulePorts returns the port of each entry of a port list, the first one of ranges
+func rulePorts(ports string) []uint16 {
+	var result []uint16
+	for _, entry := range strings.Split(ports, ",") {
+		start, _, _ := strings.Cut(strings.TrimSpace(entry), "-")
+		if port, err := strconv.ParseUint(start, 10, 16); err == nil && port > 0 {
+			result = append(result, uint16(port))
+		}
+	}
+	return result
+}
+
+// matchesPorts reports whether a port list matches port; empty matches all
+func matchesPorts(ports string, port uint16) bool {
+	if ports == "" {
+		return true
+	}
+	for _, entry := range strings.Split(ports, ",") {
+		start, end, isRange := strings.Cut(strings.TrimSpace(entry), "-")
+		if !isRange {
+			end = start
+		}
+		low, errLow := strconv.ParseUint(start, 10, 16)
+		high, errHigh := strconv.ParseUint(end, 10, 16)
+		if errLow == nil && errHigh == nil && uint64(port) >= low && uint64(port) <= high {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAddresses reports whether an address list of IPs and CIDRs matches addr; empty matches all
+func matchesAddresses(addresses string, addr netip.Addr) bool {
+	if addresses == "" {
+		return true
+	}
+	for _, entry := range strings.Split(addresses, ",") {
+		entry = strings.TrimSpace(entry)
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			if prefix.Contains(addr) {
+				return true
+			}
+		} else if ip, err := netip.ParseAddr(entry); err == nil && ip == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// Prober sends probes
+type Prober interface {
+	// Probe returns the verdict observed for a connection attempt
+	Probe(ctx context.Context, probe Probe) (Verdict, error)
+
+	// Source returns the address probes to address are sent from
+	Source(address netip.Addr) (netip.Addr, error)
+}
+
+// TCPProber probes with TCP connects from the node. Nothing has to listen on
+// the port: a refused connection reached the endpoint as much as an accepted
+// one, while a connection HNS drops times out.
+type TCPProber struct {
+	// Timeout is how long an attempt may take before it counts as Blocked
+	Timeout time.Duration
+}
+
+// Probe implements Prober
+func (p TCPProber) Probe(ctx context.Context, probe Probe) (Verdict, error) {
+	dialer := net.Dialer{Timeout: p.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", netip.AddrPortFrom(probe.Address, probe.Port).String())
+	if err == nil {
+		conn.Close()
+		return Allowed, nil
+	}
+	if errors.Is(err, windows.WSAECONNREFUSED) {
+		return Allowed, nil
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() && ctx.Err() == nil {
+		return Blocked, nil
+	}
+	return "", err
+}
+
+// Source implements Prober; connecting a UDP socket picks the source address
+// the node routes to address from without sending anything
+func (p TCPProber) Source(address netip.Addr) (netip.Addr, error) {
+	conn, err := net.DialUDP("udp", nil, net.UDPAddrFromAddrPort(netip.AddrPortFrom(address, 9)))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).AddrPort().Addr().Unmap(), nil
+}
@@ -0,0 +1,75 @@
+//go:build windows
+
+package verify
+
+import (
+	"net/netip"
+	"testing"
+
+	hcnlib "github.com/Microsoft/hcsshim/hcn"
+
+	hcnpkg "github.com/knabben/firewall-controller/internal/hcn"
+)
+
+func TestExpect(t *testing.T) {
+	address := netip.MustParseAddr("10.244.0.5")
+	node := netip.MustParseAddr("10.0.0.4")
+	table := []hcnpkg.ACLRule{
+		{Name: "allow-http", Action: hcnlib.ActionTypeAllow, Direction: hcnlib.DirectionTypeIn, Protocol: "6",
+			LocalPorts: "80", RemoteAddresses: "10.0.0.0/24", Priority: 100},
+		{Name: "allow-https-elsewhere", Action: hcnlib.ActionTypeAllow, Direction: hcnlib.DirectionTypeIn, Protocol: "6",
+			LocalPorts: "443", RemoteAddresses: "192.168.0.0/16", Priority: 101},
+		{Name: "allow-dns-client", Action: hcnlib.ActionTypeAllow, Direction: hcnlib.DirectionTypeIn, Protocol: "6",
+			LocalPorts: "5353", RemotePorts: "53", Priority: 102},
+		{Name: "isolate", Action: hcnlib.ActionTypeBlock, Direction: hcnlib.DirectionTypeIn, Priority: 65497},
+	}
+
+	tests := []struct {
+		name   string
+		port   uint16
+		want   Verdict
+		rule   string
+		wantOK bool
+	}{
+		{name: "allowed port", port: 80, want: Allowed, rule: "allow-http", wantOK: true},
+		{name: "port allowed for other sources", port: 443, want: Blocked, rule: "isolate", wantOK: true},
+		{name: "unlisted port", port: 8080, want: Blocked, rule: "isolate", wantOK: true},
+		{name: "source port dependent", port: 5353, wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rule, ok := Expect(table, address, node, tt.port)
+			if ok != tt.wantOK || got != tt.want || rule != tt.rule {
+				t.Errorf("Expect(%d) = %q %q %v, want %q %q %v", tt.port, got, rule, ok, tt.want, tt.rule, tt.wantOK)
+			}
+		})
+	}
+
+	if got, rule, _ := Expect(nil, address, node, 80); got != Allowed || rule != "" {
+		t.Errorf("Expected traffic no rule matches allowed, got %q by %q", got, rule)
+	}
+}
+
+func TestPlanProbes(t *testing.T) {
+	address := netip.MustParseAddr("10.244.0.5")
+	node := netip.MustParseAddr("10.0.0.4")
+	rules := []hcnpkg.ACLRule{
+		{Name: "allow-web", Action: hcnlib.ActionTypeAllow, Direction: hcnlib.DirectionTypeIn, Protocol: "6",
+			LocalPorts: "80,8000-9000", Priority: 100},
+		{Name: "allow-dns", Action: hcnlib.ActionTypeAllow, Direction: hcnlib.DirectionTypeIn, Protocol: "17",
+			LocalPorts: "53", Priority: 101},
+		{Name: "allow-egress", Action: hcnlib.ActionTypeAllow, Direction: hcnlib.DirectionTypeOut, Protocol: "6",
+			RemotePorts: "443", Priority: 102},
+	}
+
+	probes := PlanProbes("ep-1", address, node, rules, rules, 8)
+	if len(probes) != 2 || probes[0].Port != 80 || probes[1].Port != 8000 {
+		t.Fatalf("Expected probes of TCP ingress ports 80 and 8000, got %+v", probes)
+	}
+	if probes[0].Expect != Allowed || probes[0].Rule != "allow-web" || probes[0].EndpointID != "ep-1" {
+		t.Errorf("Unexpected probe: %+v", probes[0])
+	}
+	if probes := PlanProbes("ep-1", address, node, rules, rules, 1); len(probes) != 1 {
+		t.Errorf("Expected probes capped at 1, got %d", len(probes))
+	}
+}
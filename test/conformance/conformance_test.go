@@ -0,0 +1,118 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conformance
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+var (
+	// Environment Variables, set by `make test-conformance`:
+	// - E2E_TEST_BINARY: the upstream e2e.test binary; the suite is skipped without it.
+	// - E2E_K8S_VERSION: the Kubernetes release e2e.test comes from, for the report.
+	// - CONFORMANCE_FOCUS / CONFORMANCE_SKIP: the ginkgo focus and skip of the run.
+	// - CONFORMANCE_REPORT_DIR: where the JUnit, JSON and Markdown reports are written.
+	// - IMG: the agent image under test, for the report.
+	e2eTestBinary = os.Getenv("E2E_TEST_BINARY")
+	e2eK8sVersion = os.Getenv("E2E_K8S_VERSION")
+	focus         = envOr("CONFORMANCE_FOCUS", `\[sig-network\] Netpol`)
+	skip          = envOr("CONFORMANCE_SKIP", `\[Feature:SCTPConnectivity\]`)
+	reportDir     = envOr("CONFORMANCE_REPORT_DIR", "conformance-report")
+	agentImage    = os.Getenv("IMG")
+)
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// TestNetworkPolicyConformance runs the upstream NetworkPolicy e2e tests
+// against the cluster of KUBECONFIG, with the Windows variants of their test
+// images, and writes which of them pass. Failing conformance tests do not fail
+// the run: the report is the result. The agent must already run on the
+// cluster's Windows nodes (`make deploy`).
+func TestNetworkPolicyConformance(t *testing.T) {
+	if e2eTestBinary == "" {
+		t.Skip("E2E_TEST_BINARY is not set; run `make test-conformance`")
+	}
+	focusRegexp, err := regexp.Compile(focus)
+	if err != nil {
+		t.Fatalf("Invalid CONFORMANCE_FOCUS: %v", err)
+	}
+	if err := os.MkdirAll(reportDir, 0o755); err != nil {
+		t.Fatalf("Failed to create report directory: %v", err)
+	}
+
+	args := []string{
+		"--provider=local",
+		"--node-os-distro=windows",
+		"--report-dir=" + reportDir,
+		"--ginkgo.focus=" + focus,
+		"--ginkgo.skip=" + skip,
+		"--ginkgo.timeout=3h",
+	}
+	if kubeconfig := os.Getenv("KUBECONFIG"); kubeconfig != "" {
+		args = append(args, "--kubeconfig="+kubeconfig)
+	}
+	cmd := exec.Command(e2eTestBinary, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	t.Logf("running: %s", cmd.String())
+	if err := cmd.Run(); err != nil {
+		// e2e.test exits non-zero when a test fails; only a missing report
+		// means the run itself broke
+		t.Logf("e2e.test exited: %v", err)
+	}
+
+	junit, err := os.Open(filepath.Join(reportDir, "junit_01.xml"))
+	if err != nil {
+		t.Fatalf("e2e.test wrote no JUnit report: %v", err)
+	}
+	defer junit.Close()
+
+	results, err := ParseJUnit(junit, focusRegexp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 {
+		t.Fatalf("No test matched the focus %q", focus)
+	}
+
+	report := NewReport(results)
+	report.KubernetesVersion = e2eK8sVersion
+	report.AgentImage = agentImage
+	report.Focus = focus
+	report.Skip = skip
+	if err := report.WriteJSON(filepath.Join(reportDir, "conformance.json")); err != nil {
+		t.Fatalf("Failed to write JSON report: %v", err)
+	}
+	markdown, err := os.Create(filepath.Join(reportDir, "conformance.md"))
+	if err != nil {
+		t.Fatalf("Failed to create Markdown report: %v", err)
+	}
+	defer markdown.Close()
+	if err := report.WriteMarkdown(markdown); err != nil {
+		t.Fatalf("Failed to write Markdown report: %v", err)
+	}
+	t.Logf("%d passed, %d failed, %d skipped; report in %s", report.Passed, report.Failed, report.Skipped, reportDir)
+}
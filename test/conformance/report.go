@@ -0,0 +1,175 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package conformance runs the upstream Kubernetes NetworkPolicy e2e tests
+// against a cluster whose Windows nodes run the agent, and reports which of
+// them pass.
+package conformance
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Result is the outcome of one conformance test
+type Result string
+
+const (
+	// ResultPassed means the test ran and passed
+	ResultPassed Result = "passed"
+
+	// ResultFailed means the test ran and failed
+	ResultFailed Result = "failed"
+
+	// ResultSkipped means the test matched the focus but was skipped, for
+	// example because it needs a feature the cluster lacks
+	ResultSkipped Result = "skipped"
+)
+
+// TestResult is the outcome of one conformance test
+type TestResult struct {
+	Name    string `json:"name"`
+	Result  Result `json:"result"`
+	Message string `json:"message,omitempty"`
+}
+
+// Report lists the outcome of every conformance test that matched the focus
+type Report struct {
+	KubernetesVersion string       `json:"kubernetesVersion"`
+	AgentImage        string       `json:"agentImage"`
+	Focus             string       `json:"focus"`
+	Skip              string       `json:"skip,omitempty"`
+	Passed            int          `json:"passed"`
+	Failed            int          `json:"failed"`
+	Skipped           int          `json:"skipped"`
+	Tests             []TestResult `json:"tests"`
+}
+
+// junitTestSuites is the JUnit report written by e2e.test
+type junitTestSuites struct {
+	Suites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Cases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name    string        `xml:"name,attr"`
+	Status  string        `xml:"status,attr"`
+	Skipped *junitMessage `xml:"skipped"`
+	Failure *junitMessage `xml:"failure"`
+	Error   *junitMessage `xml:"error"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// specPrefix marks the test cases of It nodes; suite setup and reporting
+// nodes are also written to the report but are not conformance tests
+const specPrefix = "[It] "
+
+// ParseJUnit reads the JUnit report of an e2e.test run into the outcome of
+// every test matching focus. Tests ginkgo filtered out are reported as
+// skipped too, so the focus is applied again to leave them out.
+func ParseJUnit(r io.Reader, focus *regexp.Regexp) ([]TestResult, error) {
+	var suites junitTestSuites
+	if err := xml.NewDecoder(r).Decode(&suites); err != nil {
+		return nil, fmt.Errorf("failed to decode JUnit report: %w", err)
+	}
+
+	var results []TestResult
+	for _, suite := range suites.Suites {
+		for _, testCase := range suite.Cases {
+			name, ok := strings.CutPrefix(testCase.Name, specPrefix)
+			if !ok || !focus.MatchString(name) {
+				continue
+			}
+			results = append(results, testCase.result(name))
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results, nil
+}
+
+// result returns the outcome of the test case named name
+func (c junitTestCase) result(name string) TestResult {
+	switch {
+	case c.Failure != nil:
+		return TestResult{Name: name, Result: ResultFailed, Message: c.Failure.Message}
+	case c.Error != nil:
+		return TestResult{Name: name, Result: ResultFailed, Message: c.Error.Message}
+	case c.Skipped != nil || c.Status == "skipped" || c.Status == "pending":
+		message := ""
+		if c.Skipped != nil {
+			message = c.Skipped.Message
+		}
+		return TestResult{Name: name, Result: ResultSkipped, Message: message}
+	default:
+		return TestResult{Name: name, Result: ResultPassed}
+	}
+}
+
+// NewReport counts results into a report
+func NewReport(results []TestResult) Report {
+	report := Report{Tests: results}
+	for _, result := range results {
+		switch result.Result {
+		case ResultPassed:
+			report.Passed++
+		case ResultFailed:
+			report.Failed++
+		case ResultSkipped:
+			report.Skipped++
+		}
+	}
+	return report
+}
+
+// WriteJSON writes the report to path as JSON
+func (r Report) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// WriteMarkdown writes the report to w as the table published with a release
+func (r Report) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# NetworkPolicy Conformance on Windows\n\n")
+	fmt.Fprintf(&b, "- Agent image: `%s`\n", r.AgentImage)
+	fmt.Fprintf(&b, "- Kubernetes e2e tests: `%s`\n", r.KubernetesVersion)
+	fmt.Fprintf(&b, "- Focus: `%s`\n", r.Focus)
+	if r.Skip != "" {
+		fmt.Fprintf(&b, "- Skip: `%s`\n", r.Skip)
+	}
+	fmt.Fprintf(&b, "\n%d passed, %d failed, %d skipped\n\n", r.Passed, r.Failed, r.Skipped)
+	fmt.Fprintf(&b, "| Result | Test |\n|--------|------|\n")
+	for _, test := range r.Tests {
+		fmt.Fprintf(&b, "| %s | %s |\n", test.Result, strings.ReplaceAll(test.Name, "|", `\|`))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conformance
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const sampleJUnit = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="5" failures="1">
+  <testsuite name="Kubernetes e2e suite" tests="5">
+    <testcase name="[SynchronizedBeforeSuite]" status="passed"></testcase>
+    <testcase name="[It] [sig-network] Netpol NetworkPolicy between server and client should support a 'default-deny-ingress' policy" status="passed"></testcase>
+    <testcase name="[It] [sig-network] Netpol NetworkPolicy between server and client should enforce policy based on Ports" status="failed">
+      <failure message="connectivity mismatch" type="failed"></failure>
+    </testcase>
+    <testcase name="[It] [sig-network] Netpol NetworkPolicy between server and client should support SCTP" status="skipped">
+      <skipped message="skipped - SCTP is not supported"></skipped>
+    </testcase>
+    <testcase name="[It] [sig-storage] CSI mock volume should work" status="skipped">
+      <skipped message="skipped"></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>`
+
+func TestParseJUnit(t *testing.T) {
+	results, err := ParseJUnit(strings.NewReader(sampleJUnit), regexp.MustCompile(`\[sig-network\] Netpol`))
+	if err != nil {
+		t.Fatalf("ParseJUnit failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected the 3 focused tests, got %+v", results)
+	}
+
+	want := map[string]Result{
+		"should enforce policy based on Ports":           ResultFailed,
+		"should support SCTP":                            ResultSkipped,
+		"should support a 'default-deny-ingress' policy": ResultPassed,
+	}
+	for _, result := range results {
+		name := strings.TrimPrefix(result.Name, "[sig-network] Netpol NetworkPolicy between server and client ")
+		if result.Result != want[name] {
+			t.Errorf("Expected %q %s, got %s", name, want[name], result.Result)
+		}
+	}
+	if results[0].Message != "connectivity mismatch" {
+		t.Errorf("Expected the failure message kept, got %q", results[0].Message)
+	}
+
+	report := NewReport(results)
+	if report.Passed != 1 || report.Failed != 1 || report.Skipped != 1 {
+		t.Errorf("Expected 1 passed, 1 failed, 1 skipped, got %+v", report)
+	}
+}
+
+func TestParseJUnit_Invalid(t *testing.T) {
+	if _, err := ParseJUnit(strings.NewReader("not xml"), regexp.MustCompile(".")); err == nil {
+		t.Error("Expected an error for a malformed report")
+	}
+}
+
+func TestReport_WriteMarkdown(t *testing.T) {
+	report := NewReport([]TestResult{{Name: "a | b", Result: ResultPassed}})
+	report.Focus = "Netpol"
+
+	var b strings.Builder
+	if err := report.WriteMarkdown(&b); err != nil {
+		t.Fatalf("WriteMarkdown failed: %v", err)
+	}
+	if !strings.Contains(b.String(), `| passed | a \| b |`) {
+		t.Errorf("Expected an escaped table row, got:\n%s", b.String())
+	}
+	if !strings.Contains(b.String(), "1 passed, 0 failed, 0 skipped") {
+		t.Errorf("Expected the totals, got:\n%s", b.String())
+	}
+}